@@ -0,0 +1,14 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// disableEcho is not supported on this platform: passphrases must come from
+// the environment.
+func disableEcho(_ *os.File) (func(), error) {
+	return nil, errors.New("passphrase prompt not supported on this platform")
+}
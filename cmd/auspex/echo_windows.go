@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// disableEcho turns off console echo on f and returns a function that
+// restores it. It fails if f is not a console.
+func disableEcho(f *os.File) (func(), error) {
+	h := windows.Handle(f.Fd())
+	var old uint32
+	if err := windows.GetConsoleMode(h, &old); err != nil {
+		return nil, err
+	}
+	mode := old&^windows.ENABLE_ECHO_INPUT | windows.ENABLE_PROCESSED_INPUT | windows.ENABLE_LINE_INPUT
+	if err := windows.SetConsoleMode(h, mode); err != nil {
+		return nil, err
+	}
+	return func() { _ = windows.SetConsoleMode(h, old) }, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+// export.go: the `auspex export` command.
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/api"
+	"github.com/dpleshakov/auspex/internal/config"
+	"github.com/dpleshakov/auspex/internal/db"
+	"github.com/dpleshakov/auspex/internal/export"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// runExport writes the blueprints or jobs export from the local database, as
+// GET /api/export/{dataset} would serve it, without starting the server:
+//
+//	auspex export [-format csv|xlsx|ndjson] [-o file] blueprints|jobs [param=value ...]
+//
+// The params are the filters, q and sort of GET /api/blueprints, e.g.
+// owner_id=90000001,90000002 status=idle. The output goes to the file named
+// like the endpoint's download by default, or to standard output with -o -.
+// Tokens are not read, so no token key is needed.
+func runExport(args []string) error {
+	fset := flag.NewFlagSet("export", flag.ExitOnError)
+	formatName := fset.String("format", string(export.FormatCSV), "output format: csv, xlsx or ndjson")
+	output := fset.String("o", "", `output file; "-" for standard output (default auspex-<dataset>-<date>.<format>)`)
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: auspex export [-format csv|xlsx|ndjson] [-o file] blueprints|jobs [param=value ...]")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() < 1 {
+		fset.Usage()
+		return errors.New("export: dataset required")
+	}
+	dataset := fset.Arg(0)
+	if dataset != api.ExportBlueprints && dataset != api.ExportJobs {
+		return fmt.Errorf("export: unknown dataset %q (want %s or %s)", dataset, api.ExportBlueprints, api.ExportJobs)
+	}
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	params := url.Values{}
+	for _, arg := range fset.Args()[1:] {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("export: invalid parameter %q, want name=value", arg)
+		}
+		params.Add(name, value)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("db: %v", err)
+	}
+	defer database.Close() //nolint:errcheck // Close on exit, error is inconsequential
+
+	path := *output
+	if path == "" {
+		path = api.ExportFileName(dataset, format, time.Now())
+	}
+	if path == "-" {
+		if err := api.Export(context.Background(), store.New(database), os.Stdout, dataset, format, params); err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		return nil
+	}
+
+	// Written next to path and moved into place once complete, so that a
+	// failed export leaves an existing file untouched.
+	f, err := os.CreateTemp(filepath.Dir(path), ".auspex-export-*")
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	err = api.Export(context.Background(), store.New(database), f, dataset, format, params)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("export: %w", err)
+	}
+	log.Printf("exported %s to %s", dataset, path)
+	return nil
+}
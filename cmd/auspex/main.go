@@ -3,24 +3,32 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
+	"net/mail"
 	"os"
 	"os/signal"
+	"strconv"
 	stdsync "sync"
 	"syscall"
 	"time"
 
+	"github.com/dpleshakov/auspex/internal/alert"
 	"github.com/dpleshakov/auspex/internal/api"
 	"github.com/dpleshakov/auspex/internal/auth"
 	"github.com/dpleshakov/auspex/internal/config"
 	"github.com/dpleshakov/auspex/internal/db"
 	"github.com/dpleshakov/auspex/internal/esi"
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/notify"
+	"github.com/dpleshakov/auspex/internal/push"
 	"github.com/dpleshakov/auspex/internal/store"
 	syncp "github.com/dpleshakov/auspex/internal/sync"
+	"github.com/dpleshakov/auspex/internal/webhook"
 )
 
 // staticFiles holds the compiled frontend, embedded at build time.
@@ -44,6 +52,20 @@ func main() {
 		os.Exit(0)
 	}
 
+	if flag.Arg(0) == "rekey" {
+		if err := runRekey(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "export" {
+		if err := runExport(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	log.Printf("starting auspex %s (%s)", version, commit)
 
 	if err := run(); err != nil {
@@ -63,7 +85,11 @@ func run() error {
 	}
 	defer database.Close() //nolint:errcheck // Close on shutdown, error is inconsequential
 
-	queries := store.New(database)
+	tokenCipher, err := openTokenCipher(context.Background(), cfg, database)
+	if err != nil {
+		return fmt.Errorf("token encryption: %v", err)
+	}
+	queries := store.NewEncrypted(database, tokenCipher)
 
 	esiClient := esi.NewClient(http.DefaultClient)
 
@@ -71,29 +97,54 @@ func run() error {
 		cfg.ESI.ClientID,
 		cfg.ESI.ClientSecret,
 		cfg.ESI.CallbackURL,
+		cfg.ESI.SSOMetadataURL,
 		queries,
 		nil,
 	)
+	if authProvider.PKCE() {
+		log.Printf("esi.client_secret not set: using EVE SSO PKCE flow")
+	}
 
 	// auth.Client wraps esiClient with automatic token injection and refresh.
 	// It shares the oauth2.Config from authProvider so credentials are consistent.
 	authClient := auth.NewClient(esiClient, queries, authProvider.OAuthConfig(), nil)
+	authProvider.SetTokenCache(authClient)
 
 	interval := time.Duration(cfg.RefreshInterval) * time.Minute
-	worker := syncp.New(queries, authClient, interval)
+	worker := syncp.New(queries, authClient, authClient, interval)
+
+	// The worker publishes its progress on the bus; /api/events streams it.
+	bus := events.NewBus(events.DefaultHistorySize)
+	worker.SetEventBus(bus)
 
 	distFS, err := fs.Sub(staticFiles, "web/dist")
 	if err != nil {
 		return fmt.Errorf("preparing static files: %v", err)
 	}
 
-	router := api.NewRouter(queries, worker, authProvider, distFS)
+	// Delivers events to the webhooks managed through /api/webhooks; the
+	// router sends their test events through it too.
+	dispatcher := webhook.New(queries, nil)
+
+	// Sends Web Push notifications to the browsers subscribed through
+	// /api/push, and the router's test notifications.
+	pusher := push.New(queries, nil)
+
+	router := api.NewRouter(queries, worker, authProvider, bus, distFS,
+		api.WithWebhooks(dispatcher), api.WithPush(pusher))
 
 	srv := &http.Server{
 		Addr:              fmt.Sprintf(":%d", cfg.Port),
 		Handler:           router,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
+	// Open event streams never finish on their own; end them on shutdown.
+	srv.RegisterOnShutdown(bus.Close)
+
+	// Generate the Web Push VAPID keys on first run.
+	if _, err := push.LoadKeys(context.Background(), queries); err != nil {
+		return fmt.Errorf("push keys: %v", err)
+	}
 
 	// Start the sync worker in the background.
 	// The worker runs an initial cycle immediately, then ticks every RefreshInterval.
@@ -106,6 +157,61 @@ func run() error {
 		worker.Run(workerCtx)
 	}()
 
+	// The channels alert rules can send to, as they are configured.
+	channels := map[string]alert.Channel{}
+
+	// Post alerts to the configured Discord webhooks.
+	if discord := cfg.Notifications.Discord; len(discord.Webhooks) > 0 {
+		notifier := notify.New(queries, &http.Client{Timeout: 10 * time.Second}, notifyOptions(discord))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			notifier.Run(workerCtx, bus)
+		}()
+		channels[alert.ChannelDiscord] = discordChannel(notifier)
+		log.Printf("posting alerts to %d Discord webhook(s)", len(discord.Webhooks))
+	}
+
+	// Email the digest.
+	if email := cfg.Notifications.Email; len(email.To) > 0 {
+		mailer := notify.NewMailer(queries, emailOptions(email))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mailer.Run(workerCtx)
+		}()
+		channels[alert.ChannelEmail] = emailChannel(mailer)
+		log.Printf("emailing a %s digest to %d recipient(s)", email.Schedule, len(email.To))
+	}
+
+	// Deliver events to the webhooks managed through /api/webhooks.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dispatcher.Run(workerCtx, bus)
+	}()
+
+	// Send Web Push notifications to the browsers subscribed through
+	// /api/push.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pusher.Run(workerCtx, bus)
+	}()
+	channels[alert.ChannelPush] = pushChannel(pusher)
+
+	// Evaluate the alert rules managed through /api/alerts after every sync
+	// cycle.
+	engine := alert.New(queries, channels)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		engine.Run(workerCtx, bus)
+	}()
+
+	// Drop OAuth states of abandoned logins.
+	go authProvider.SweepStates(workerCtx)
+
 	// Handle SIGINT and SIGTERM for graceful shutdown.
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -136,3 +242,86 @@ func run() error {
 
 	return nil
 }
+
+// notifyOptions converts the Discord notifications config to notifier options.
+func notifyOptions(c config.DiscordConfig) notify.Options {
+	opts := notify.Options{
+		IdleAfter:     time.Duration(c.IdleAfter) * time.Hour,
+		ResearchSlots: int64(c.ResearchSlots),
+		DigestHour:    c.DigestHour,
+	}
+	for _, wh := range c.Webhooks {
+		opts.Webhooks = append(opts.Webhooks, notify.Webhook{URL: wh.URL, Kinds: wh.Alerts, Digest: wh.Digest})
+	}
+	return opts
+}
+
+// emailOptions converts the email notifications config, validated by
+// config.Load, to mailer options.
+func emailOptions(c config.EmailConfig) notify.EmailOptions {
+	from, _ := mail.ParseAddress(c.From)
+	opts := notify.EmailOptions{
+		SMTP: notify.SMTPServer{
+			Host:     c.SMTP.Host,
+			Port:     c.SMTP.Port,
+			Security: c.SMTP.Security,
+			Username: c.SMTP.Username,
+			Password: c.SMTP.Password,
+		},
+		From:          from,
+		Weekly:        c.Schedule == "weekly",
+		Weekday:       c.DigestWeekday(),
+		Hour:          c.Hour,
+		Location:      c.Location(),
+		ResearchSlots: int64(c.ResearchSlots),
+	}
+	for _, to := range c.To {
+		addr, _ := mail.ParseAddress(to)
+		opts.To = append(opts.To, addr)
+	}
+	return opts
+}
+
+// discordChannel posts rule alerts to the Discord webhooks that take the rule
+// alert kind.
+func discordChannel(n *notify.Notifier) alert.Channel {
+	return alert.ChannelFunc(func(ctx context.Context, a alert.Alert) error {
+		return n.Post(ctx, []notify.Alert{{
+			Kind:    notify.KindRule,
+			Subject: "rule:" + strconv.FormatInt(a.RuleID, 10),
+			Title:   a.Rule,
+			Summary: fmt.Sprintf("%s: %d match(es)", a.Rule, len(a.Matches)),
+			Text:    a.Text(),
+			Time:    a.Time,
+		}})
+	})
+}
+
+// emailChannel emails rule alerts to the digest recipients.
+func emailChannel(m *notify.Mailer) alert.Channel {
+	return alert.ChannelFunc(func(ctx context.Context, a alert.Alert) error {
+		return m.SendAlert(ctx, "Auspex alert: "+a.Rule, a.Text())
+	})
+}
+
+// pushChannel sends rule alerts to the subscribed browsers. An alert no
+// browser received is an error.
+func pushChannel(p *push.Service) alert.Channel {
+	return alert.ChannelFunc(func(ctx context.Context, a alert.Alert) error {
+		res, err := p.Send(ctx, push.Notification{
+			Title: "Alert: " + a.Rule,
+			Body:  a.Text(),
+			Tag:   "rule:" + strconv.FormatInt(a.RuleID, 10),
+			URL:   "/",
+		})
+		switch {
+		case err != nil:
+			return err
+		case res.Sent == 0 && res.Failed+res.Expired == 0:
+			return errors.New("no browser is subscribed")
+		case res.Sent == 0:
+			return fmt.Errorf("failed for all %d subscription(s)", res.Failed+res.Expired)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,223 @@
+package main
+
+// tokenkey.go: obtaining the key the stored OAuth tokens are encrypted with,
+// and the `auspex rekey` command.
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dpleshakov/auspex/internal/config"
+	"github.com/dpleshakov/auspex/internal/db"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+const (
+	// passphraseEnv supplies the token passphrase without a prompt.
+	passphraseEnv = "AUSPEX_TOKEN_PASSPHRASE" //nolint:gosec // G101: variable name, not a credential
+	// newPassphraseEnv supplies the new passphrase to `auspex rekey`.
+	newPassphraseEnv = "AUSPEX_NEW_TOKEN_PASSPHRASE" //nolint:gosec // G101: variable name, not a credential
+)
+
+// openTokenCipher resolves the token key, checks it against the database and
+// encrypts any tokens still stored in plaintext.
+func openTokenCipher(ctx context.Context, cfg *config.Config, database *sql.DB) (*store.TokenCipher, error) {
+	key, err := resolveTokenKey(ctx, cfg, database)
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := store.OpenTokenCipher(ctx, database, key)
+	if err != nil {
+		return nil, err
+	}
+	n, err := store.EncryptPlaintextTokens(ctx, database, cipher)
+	if err != nil {
+		return nil, fmt.Errorf("encrypting stored tokens: %w", err)
+	}
+	if n > 0 {
+		log.Printf("encrypted stored tokens of %d character(s)", n)
+	}
+	return cipher, nil
+}
+
+// resolveTokenKey returns the token key. Once encryption is set up the key
+// must come from the same source as before: a passphrase (from
+// AUSPEX_TOKEN_PASSPHRASE or a terminal prompt) or the key file. On first
+// start a passphrase from the environment is used if set; otherwise an
+// existing key file is read or a new one is generated.
+func resolveTokenKey(ctx context.Context, cfg *config.Config, database *sql.DB) (store.TokenKey, error) {
+	source, err := store.TokenKeySource(ctx, database)
+	if err != nil {
+		return store.TokenKey{}, err
+	}
+	passphrase := os.Getenv(passphraseEnv)
+
+	switch source {
+	case store.KeySourcePassphrase:
+		if passphrase == "" {
+			if passphrase, err = promptPassphrase("Token passphrase: "); err != nil {
+				return store.TokenKey{}, fmt.Errorf("tokens are encrypted with a passphrase: set %s or run in a terminal: %w", passphraseEnv, err)
+			}
+		}
+		return store.TokenKey{Passphrase: passphrase}, nil
+	case store.KeySourceKeyFile:
+		key, err := readKeyFile(cfg.TokenKeyFile)
+		if err != nil {
+			return store.TokenKey{}, fmt.Errorf("tokens are encrypted with a key file: %w", err)
+		}
+		return store.TokenKey{Key: key}, nil
+	}
+
+	if passphrase != "" {
+		return store.TokenKey{Passphrase: passphrase}, nil
+	}
+	key, err := readKeyFile(cfg.TokenKeyFile)
+	if err == nil {
+		return store.TokenKey{Key: key}, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return store.TokenKey{}, err
+	}
+	key = store.NewTokenKey()
+	if err := writeKeyFile(cfg.TokenKeyFile, key); err != nil {
+		return store.TokenKey{}, err
+	}
+	log.Printf("generated token encryption key %s: back it up together with %s", cfg.TokenKeyFile, cfg.DBPath)
+	return store.TokenKey{Key: key}, nil
+}
+
+// readKeyFile reads a hex-encoded token key.
+func readKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // G304: path comes from config
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != store.TokenKeySize {
+		return nil, fmt.Errorf("key file %q must hold %d hex-encoded bytes", path, store.TokenKeySize)
+	}
+	return key, nil
+}
+
+// writeKeyFile writes key hex-encoded to path, readable by the owner only.
+// It fails if path already exists.
+func writeKeyFile(path string, key []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600) //nolint:gosec // G304: path comes from config
+	if err != nil {
+		return fmt.Errorf("creating key file: %w", err)
+	}
+	if _, err := fmt.Fprintln(f, hex.EncodeToString(key)); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing key file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing key file: %w", err)
+	}
+	return nil
+}
+
+// promptPassphrase reads a passphrase from the terminal without echoing it.
+func promptPassphrase(prompt string) (string, error) {
+	restore, err := disableEcho(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	fmt.Fprint(os.Stderr, prompt) //nolint:errcheck // terminal output
+	defer fmt.Fprintln(os.Stderr) //nolint:errcheck // terminal output
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase: %w", err)
+	}
+	passphrase := strings.TrimRight(line, "\r\n")
+	if passphrase == "" {
+		return "", errors.New("empty passphrase")
+	}
+	return passphrase, nil
+}
+
+// runRekey implements `auspex rekey`: it re-encrypts the stored tokens with a
+// new passphrase, or with a newly generated key file when -key-file is set.
+func runRekey(args []string) error {
+	fset := flag.NewFlagSet("rekey", flag.ExitOnError)
+	toKeyFile := fset.Bool("key-file", false, "switch to a newly generated key file instead of a passphrase")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("db: %v", err)
+	}
+	defer database.Close() //nolint:errcheck // Close on exit, error is inconsequential
+
+	ctx := context.Background()
+	oldSource, err := store.TokenKeySource(ctx, database)
+	if err != nil {
+		return err
+	}
+	old, err := openTokenCipher(ctx, cfg, database)
+	if err != nil {
+		return fmt.Errorf("current token key: %w", err)
+	}
+
+	if *toKeyFile {
+		return rekeyToKeyFile(ctx, cfg, database, old)
+	}
+
+	passphrase := os.Getenv(newPassphraseEnv)
+	if passphrase == "" {
+		if passphrase, err = promptPassphrase("New token passphrase: "); err != nil {
+			return fmt.Errorf("set %s or run in a terminal: %w", newPassphraseEnv, err)
+		}
+		repeat, err := promptPassphrase("Repeat new token passphrase: ")
+		if err != nil {
+			return err
+		}
+		if repeat != passphrase {
+			return errors.New("passphrases do not match")
+		}
+	}
+	if _, err := store.Rekey(ctx, database, old, store.TokenKey{Passphrase: passphrase}); err != nil {
+		return fmt.Errorf("rekey: %w", err)
+	}
+	log.Printf("stored tokens re-encrypted with the new passphrase")
+	if oldSource == store.KeySourceKeyFile {
+		log.Printf("key file %s is no longer used and can be deleted", cfg.TokenKeyFile)
+	}
+	return nil
+}
+
+// rekeyToKeyFile re-encrypts the tokens with a new random key. The key is
+// written next to the current key file first and moved into place only after
+// the database has been updated, so a failure leaves a working pair behind.
+func rekeyToKeyFile(ctx context.Context, cfg *config.Config, database *sql.DB, old *store.TokenCipher) error {
+	key := store.NewTokenKey()
+	pending := cfg.TokenKeyFile + ".new"
+	if err := writeKeyFile(pending, key); err != nil {
+		return err
+	}
+	if _, err := store.Rekey(ctx, database, old, store.TokenKey{Key: key}); err != nil {
+		_ = os.Remove(pending)
+		return fmt.Errorf("rekey: %w", err)
+	}
+	if err := os.Rename(pending, cfg.TokenKeyFile); err != nil {
+		return fmt.Errorf("tokens are now encrypted with the key in %s; move it to %s: %w", pending, cfg.TokenKeyFile, err)
+	}
+	log.Printf("stored tokens re-encrypted with a new key in %s: back it up together with %s", cfg.TokenKeyFile, cfg.DBPath)
+	return nil
+}
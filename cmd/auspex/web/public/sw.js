@@ -0,0 +1,35 @@
+// Service worker for Web Push notifications. The server sends a JSON payload
+// of { title, body, tag, url }; see internal/push.
+
+self.addEventListener('push', (event) => {
+  let data = {}
+  try {
+    data = event.data ? event.data.json() : {}
+  } catch (_) {
+    data = { body: event.data.text() }
+  }
+  event.waitUntil(
+    self.registration.showNotification(data.title || 'Auspex', {
+      body: data.body || '',
+      tag: data.tag || undefined,
+      icon: '/apple-touch-icon.png',
+      data: { url: data.url || '/' },
+    }),
+  )
+})
+
+// Focus an open dashboard on click, or open one.
+self.addEventListener('notificationclick', (event) => {
+  event.notification.close()
+  const url = new URL(event.notification.data?.url || '/', self.location.origin).href
+  event.waitUntil(
+    self.clients.matchAll({ type: 'window', includeUncontrolled: true }).then((windows) => {
+      for (const client of windows) {
+        if (client.url.startsWith(self.location.origin) && 'focus' in client) {
+          return client.navigate(url).then((c) => (c || client).focus())
+        }
+      }
+      return self.clients.openWindow(url)
+    }),
+  )
+})
@@ -3,29 +3,62 @@ import SummaryBar from './components/SummaryBar.jsx'
 import CharactersSection from './components/CharactersSection.jsx'
 import BlueprintTable from './components/BlueprintTable.jsx'
 import CharactersPage from './components/CharactersPage.jsx'
-import { getBlueprints, getJobsSummary, postSync, getSyncStatus } from './api/client.js'
+import PushToggle from './components/PushToggle.jsx'
+import { getJobsSummary, postSync, subscribeEvents } from './api/client.js'
 
 const AUTO_REFRESH_MS = 10 * 60 * 1000  // 10 minutes
-const SYNC_POLL_MS = 2000               // 2 s between force-refresh polls
-const SYNC_POLL_MAX_MS = 60_000         // give up waiting after 60 s
+const SYNC_WAIT_MAX_MS = 60_000         // give up waiting for a forced sync after 60 s
+const RELOAD_DEBOUNCE_MS = 300          // coalesce bursts of events into one reload
+
+// Events from /api/events after which the dashboard data is reloaded.
+// resync means events were missed while disconnected.
+const RELOAD_EVENTS = [
+  'subject_synced',
+  'blueprint_added',
+  'blueprint_removed',
+  'job_ready',
+  'resync',
+]
 
 const TABS = { BLUEPRINTS: 'blueprints', CHARACTERS: 'characters' }
 
+// Messages for the auth_error codes the OAuth callback redirects with.
+const AUTH_ERRORS = {
+  canceled: 'Login canceled — no character was added.',
+  sso_error: 'EVE SSO could not complete the login. Please try again.',
+}
+
+// readAuthError returns the auth_error code from the URL and removes it, so
+// the message is not shown again on reload.
+function readAuthError() {
+  const params = new URLSearchParams(window.location.search)
+  const code = params.get('auth_error')
+  if (code) {
+    params.delete('auth_error')
+    const query = params.toString()
+    window.history.replaceState(null, '', window.location.pathname + (query ? `?${query}` : ''))
+  }
+  return code
+}
+
 export default function App() {
-  const [blueprints, setBlueprints] = useState(null)
+  // Bumped on every reload; BlueprintTable refetches its page when it changes.
+  const [dataVersion, setDataVersion] = useState(0)
   const [summary, setSummary] = useState(null)
   const [loading, setLoading] = useState(true)
   const [error, setError] = useState(null)
   const [isRefreshing, setIsRefreshing] = useState(false)
   const [activeTab, setActiveTab] = useState(TABS.BLUEPRINTS)
+  const [authError, setAuthError] = useState(readAuthError)
 
-  const syncPollRef = useRef(null)
+  const syncWaitRef = useRef(null)
+  const reloadRef = useRef(null)
 
   const loadData = useCallback(async () => {
     try {
-      const [bps, sum] = await Promise.all([getBlueprints(), getJobsSummary()])
-      setBlueprints(bps ?? [])
+      const sum = await getJobsSummary()
       setSummary(sum)
+      setDataVersion(v => v + 1)
       setError(null)
     } catch (err) {
       setError(err.message)
@@ -41,12 +74,27 @@ export default function App() {
     return () => clearInterval(timer)
   }, [loadData])
 
-  // Cleanup sync poll on unmount.
+  // Live updates: reload shortly after the sync worker stores new data, and
+  // finish a forced refresh when its cycle ends.
   useEffect(() => {
+    const close = subscribeEvents([...RELOAD_EVENTS, 'cycle_finished'], (type, data) => {
+      if (type === 'cycle_finished') {
+        if (data.forced && syncWaitRef.current !== null) {
+          clearTimeout(syncWaitRef.current)
+          syncWaitRef.current = null
+          loadData().then(() => setIsRefreshing(false))
+        }
+        return
+      }
+      clearTimeout(reloadRef.current)
+      reloadRef.current = setTimeout(loadData, RELOAD_DEBOUNCE_MS)
+    })
     return () => {
-      if (syncPollRef.current !== null) clearInterval(syncPollRef.current)
+      close()
+      clearTimeout(reloadRef.current)
+      if (syncWaitRef.current !== null) clearTimeout(syncWaitRef.current)
     }
-  }, [])
+  }, [loadData])
 
   async function handleRefresh() {
     if (isRefreshing) return
@@ -59,39 +107,15 @@ export default function App() {
       return
     }
 
-    // Record when the sync was triggered. Any last_sync timestamp after this
-    // point means the forced sync cycle has completed.
-    const refTime = new Date()
-    const deadline = Date.now() + SYNC_POLL_MAX_MS
-
-    syncPollRef.current = setInterval(async () => {
-      // Safety valve: if no completion signal arrives within the timeout
-      // (e.g. no characters added yet, or persistent ESI errors), give up
-      // waiting and reload data anyway so the UI doesn't stay stuck.
-      if (Date.now() >= deadline) {
-        clearInterval(syncPollRef.current)
-        syncPollRef.current = null
-        await loadData()
-        setIsRefreshing(false)
-        return
-      }
-
-      try {
-        const statuses = await getSyncStatus()
-        const done =
-          Array.isArray(statuses) &&
-          statuses.some(row => row.last_sync && new Date(row.last_sync) > refTime)
-
-        if (done) {
-          clearInterval(syncPollRef.current)
-          syncPollRef.current = null
-          await loadData()
-          setIsRefreshing(false)
-        }
-      } catch {
-        // Ignore transient errors; keep polling.
-      }
-    }, SYNC_POLL_MS)
+    // The cycle_finished event of the forced cycle ends the wait (see the
+    // live updates effect above). Safety valve: if it does not arrive within
+    // the timeout (e.g. the event stream is down), reload data anyway so the
+    // UI doesn't stay stuck.
+    syncWaitRef.current = setTimeout(async () => {
+      syncWaitRef.current = null
+      await loadData()
+      setIsRefreshing(false)
+    }, SYNC_WAIT_MAX_MS)
   }
 
   const tabClass = (tab) => `app-tab${activeTab === tab ? ' app-tab--active' : ''}`
@@ -124,12 +148,22 @@ export default function App() {
           </nav>
         </div>
         <div className="app-header__actions">
+          <PushToggle />
           <a className="app-add-char-link" href="/auth/eve/login">
             + Add character
           </a>
         </div>
       </header>
 
+      {authError && (
+        <div className="app-notice">
+          <span>{AUTH_ERRORS[authError] ?? AUTH_ERRORS.sso_error}</span>
+          <button className="app-notice__close" onClick={() => setAuthError(null)} aria-label="Dismiss">
+            ×
+          </button>
+        </div>
+      )}
+
       {loading ? (
         <div className="app-loading">Loading…</div>
       ) : error ? (
@@ -138,7 +172,7 @@ export default function App() {
         <>
           <SummaryBar summary={summary} />
           <CharactersSection summary={summary} />
-          <BlueprintTable blueprints={blueprints} />
+          <BlueprintTable version={dataVersion} />
         </>
       ) : (
         <CharactersPage />
@@ -30,6 +30,10 @@ export function deleteCharacter(id) {
   return request('DELETE', `/api/characters/${id}`)
 }
 
+export function confirmTransfer(id) {
+  return request('POST', `/api/characters/${id}/confirm-transfer`)
+}
+
 // Corporations
 
 export function getCorporations() {
@@ -50,21 +54,158 @@ export function patchDelegate(corporationId, characterId) {
 
 // Blueprints
 
-// filters: { status?, owner_id?, owner_type?, category_id? }
-export function getBlueprints(filters = {}) {
-  const params = new URLSearchParams()
-  if (filters.status)      params.set('status',      filters.status)
-  if (filters.owner_id)    params.set('owner_id',    filters.owner_id)
-  if (filters.owner_type)  params.set('owner_type',  filters.owner_type)
-  if (filters.category_id) params.set('category_id', filters.category_id)
-  const qs = params.toString()
-  return request('GET', `/api/blueprints${qs ? `?${qs}` : ''}`)
+// params: { status?, activity?, owner_type?, owner_id?, category_id?, region_id?,
+//           system_id?, security?, q?, sort?, limit?, cursor? }
+// Array values are sent as comma-separated lists. Resolves to
+// { items, total, next_cursor, facets }.
+export function getBlueprints(params = {}) {
+  return request('GET', `/api/blueprints${queryString(params)}`)
+}
+
+// Returns the download URL of the blueprints or jobs export ('blueprints' |
+// 'jobs') in format ('csv' | 'xlsx' | 'ndjson'), filtered and sorted by params
+// as getBlueprints is; paging params are ignored.
+export function exportURL(dataset, format, params = {}) {
+  return `/api/export/${dataset}${queryString({ ...params, format })}`
+}
+
+// queryString encodes params for a URL, with a leading '?' unless empty.
+// Empty values are left out and arrays are sent as comma-separated lists.
+function queryString(params) {
+  const qs = new URLSearchParams()
+  for (const [key, value] of Object.entries(params)) {
+    if (value === undefined || value === null || value === '') continue
+    qs.set(key, Array.isArray(value) ? value.join(',') : value)
+  }
+  const query = qs.toString()
+  return query ? `?${query}` : ''
 }
 
 export function getJobsSummary() {
   return request('GET', '/api/jobs/summary')
 }
 
+// Search
+
+// Resolves to { query, groups: [{ type, total, hits }] }; hit fields are HTML
+// with the matched words in <mark> elements.
+export function search(q, limit) {
+  const qs = new URLSearchParams({ q })
+  if (limit) qs.set('limit', limit)
+  return request('GET', `/api/search?${qs}`)
+}
+
+// Calendar feeds
+
+export function getCalendarFeeds() {
+  return request('GET', '/api/calendar/feeds')
+}
+
+// filters: GET /api/blueprints filters, e.g. { owner_id: [1, 2], activity: 'copying' }.
+// Resolves to the new feed with its subscription url.
+export function createCalendarFeed({ name, filters = {} }) {
+  const values = {}
+  for (const [key, value] of Object.entries(filters)) {
+    values[key] = Array.isArray(value) ? value.join(',') : String(value)
+  }
+  return request('POST', '/api/calendar/feeds', { name, filters: values })
+}
+
+export function deleteCalendarFeed(id) {
+  return request('DELETE', `/api/calendar/feeds/${id}`)
+}
+
+// Webhooks
+
+export function getWebhooks() {
+  return request('GET', '/api/webhooks')
+}
+
+// webhook: { name?, url, events?, template?, headers?, enabled? }. An empty
+// events list subscribes to every event type. Resolves to the webhook with
+// its signing secret.
+export function createWebhook(webhook) {
+  return request('POST', '/api/webhooks', webhook)
+}
+
+export function updateWebhook(id, webhook) {
+  return request('PUT', `/api/webhooks/${id}`, webhook)
+}
+
+export function deleteWebhook(id) {
+  return request('DELETE', `/api/webhooks/${id}`)
+}
+
+export function getWebhookDeliveries(id, limit) {
+  return request('GET', `/api/webhooks/${id}/deliveries${queryString({ limit })}`)
+}
+
+// Sends a sample event of eventType (or a plain test event) and resolves to
+// the delivery.
+export function testWebhook(id, eventType) {
+  return request('POST', `/api/webhooks/${id}/test`, eventType ? { event_type: eventType } : undefined)
+}
+
+// Push notifications
+
+// Resolves to { public_key }, the applicationServerKey for
+// PushManager.subscribe.
+export function getPushKey() {
+  return request('GET', '/api/push/key')
+}
+
+export function getPushSubscriptions() {
+  return request('GET', '/api/push/subscriptions')
+}
+
+// subscription: a PushSubscription, or its toJSON().
+export function createPushSubscription(subscription) {
+  return request('POST', '/api/push/subscriptions', subscription)
+}
+
+export function deletePushSubscription(id) {
+  return request('DELETE', `/api/push/subscriptions/${id}`)
+}
+
+// Sends a test notification to every subscription and resolves to
+// { sent, failed, expired }.
+export function testPush() {
+  return request('POST', '/api/push/test')
+}
+
+// Alert rules
+
+export function getAlertRules() {
+  return request('GET', '/api/alerts')
+}
+
+// rule: { name, expression, channels?, cooldown?, quiet_hours?, timezone?,
+// enabled? }. channels are 'push', 'discord' and 'email'; cooldown is a
+// duration such as '6h' (default '24h'); quiet_hours is { start, end } as
+// 'HH:MM' in timezone. An invalid expression is rejected with its position.
+export function createAlertRule(rule) {
+  return request('POST', '/api/alerts', rule)
+}
+
+export function updateAlertRule(id, rule) {
+  return request('PUT', `/api/alerts/${id}`, rule)
+}
+
+export function deleteAlertRule(id) {
+  return request('DELETE', `/api/alerts/${id}`)
+}
+
+// Resolves to { scope, matches: [{ subject, description }] }: what the rule
+// matches now, regardless of its cooldown and quiet hours.
+export function getAlertMatches(id) {
+  return request('GET', `/api/alerts/${id}/matches`)
+}
+
+// Fired alerts, newest first: of every rule, or of ruleId.
+export function getAlertHistory(ruleId, limit) {
+  return request('GET', `/api/alerts/history${queryString({ rule_id: ruleId, limit })}`)
+}
+
 // Sync
 
 export function postSync() {
@@ -74,3 +215,17 @@ export function postSync() {
 export function getSyncStatus() {
   return request('GET', '/api/sync/status')
 }
+
+// Events
+
+// subscribeEvents opens the /api/events stream and calls onEvent(type, data)
+// for each event of the given types. The browser reconnects on its own and
+// resumes from the last event received. Returns a function that closes the
+// stream.
+export function subscribeEvents(types, onEvent) {
+  const source = new EventSource('/api/events')
+  for (const type of types) {
+    source.addEventListener(type, (e) => onEvent(type, JSON.parse(e.data)))
+  }
+  return () => source.close()
+}
@@ -2,10 +2,9 @@ import { useState, useEffect, useMemo } from 'react'
 import {
   useReactTable,
   getCoreRowModel,
-  getSortedRowModel,
   flexRender,
 } from '@tanstack/react-table'
-import { getBlueprints } from '../api/client.js'
+import { exportURL, getBlueprints } from '../api/client.js'
 
 const ALARM_HOURS = 24
 
@@ -44,15 +43,6 @@ function getRowClass(row) {
   return ''
 }
 
-// Numeric priority for default sort (lower = higher in table).
-function getStatusPriority(row) {
-  switch (getFullStatusLabel(row)) {
-    case 'Ready':  return 0
-    case 'Idle':   return 2
-    default:       return 1  // active variants — between ready and idle
-  }
-}
-
 // Formats an ISO date string as local date + time (short), always in 24-hour format.
 function formatLocalDate(isoStr) {
   if (!isoStr) return '—'
@@ -65,64 +55,116 @@ function formatLocalDate(isoStr) {
 
 const STATUS_OPTIONS = ['All', 'Ready', 'Idle', 'ME Research', 'TE Research', 'Copying']
 
+// Query parameters of /api/blueprints for each status option.
+const STATUS_PARAMS = {
+  'Ready':       { status: 'ready' },
+  'Idle':        { status: 'idle' },
+  'ME Research': { status: 'active', activity: 'me_research' },
+  'TE Research': { status: 'active', activity: 'te_research' },
+  'Copying':     { status: 'active', activity: 'copying' },
+}
+
+const SECURITY_OPTIONS = ['All', 'High', 'Low', 'Null']
+
+const PAGE_SIZE = 100
+const SEARCH_DEBOUNCE_MS = 300
+
+// Server sort keys of the sortable columns.
+const SORT_KEYS = {
+  type_name: 'type_name',
+  owner_name: 'owner',
+  me_level: 'me',
+  te_level: 'te',
+  status: 'status',
+  end_date: 'end_date',
+}
+
+// getSecurityClass buckets a system security status the way the game client
+// rounds it: 0.45 and above is high-sec, anything above 0.0 is low-sec.
+function getSecurityClass(status) {
+  if (status === null || status === undefined) return null
+  if (status >= 0.45) return 'High'
+  if (status > 0) return 'Low'
+  return 'Null'
+}
+
 const DEFAULT_SORT = [{ id: 'status', desc: false }, { id: 'end_date', desc: false }]
 
-export default function BlueprintTable({ blueprints: externalBlueprints }) {
-  const [blueprints, setBlueprints] = useState([])
+// Filtering, sorting and paging happen on the server; the table shows one
+// page at a time. version changes whenever the dashboard reloads its data.
+export default function BlueprintTable({ version }) {
+  const [page, setPage] = useState(null)
   const [loading, setLoading] = useState(true)
   const [error, setError] = useState(null)
 
   const [statusFilter, setStatusFilter] = useState('All')
   const [ownerFilter, setOwnerFilter] = useState('All')
   const [categoryFilter, setCategoryFilter] = useState('All')
+  const [regionFilter, setRegionFilter] = useState('All')
+  const [securityFilter, setSecurityFilter] = useState('All')
+  const [search, setSearch] = useState('')
+  const [query, setQuery] = useState('')  // search, debounced
   const [sorting, setSorting] = useState(DEFAULT_SORT)
+  // Cursors of the pages visited so far for the query in key; the last one is
+  // the current page. Any other query starts over from the first page.
+  const [paging, setPaging] = useState({ key: '', cursors: [null] })
 
   useEffect(() => {
-    if (externalBlueprints !== undefined) {
-      setBlueprints(externalBlueprints ?? [])
-      setLoading(false)
-      return
-    }
-
-    setLoading(true)
-    getBlueprints()
+    const timer = setTimeout(() => setQuery(search.trim()), SEARCH_DEBOUNCE_MS)
+    return () => clearTimeout(timer)
+  }, [search])
+
+  const params = useMemo(() => ({
+    ...(STATUS_PARAMS[statusFilter] ?? {}),
+    owner_id: ownerFilter !== 'All' ? ownerFilter : null,
+    category_id: categoryFilter !== 'All' ? categoryFilter : null,
+    region_id: regionFilter !== 'All' ? regionFilter : null,
+    security: securityFilter !== 'All' ? securityFilter.toLowerCase() : null,
+    q: query,
+    sort: sorting.map(s => `${s.desc ? '-' : ''}${SORT_KEYS[s.id]}`).join(','),
+    limit: PAGE_SIZE,
+  }), [statusFilter, ownerFilter, categoryFilter, regionFilter, securityFilter, query, sorting])
+
+  const paramsKey = JSON.stringify(params)
+  const cursors = paging.key === paramsKey ? paging.cursors : [null]
+  const cursor = cursors[cursors.length - 1]
+
+  function goToPage(next) {
+    setPaging({ key: paramsKey, cursors: next })
+  }
+
+  useEffect(() => {
+    let canceled = false
+    getBlueprints({ ...params, cursor })
       .then(data => {
-        setBlueprints(data ?? [])
+        if (canceled) return
+        setPage(data)
+        setError(null)
         setLoading(false)
       })
       .catch(err => {
+        if (canceled) return
         setError(err.message)
         setLoading(false)
       })
-  }, [externalBlueprints])
-
-  // Unique owner and category values for dropdowns, derived from full data set.
-  const owners = useMemo(() => {
-    const set = new Set(blueprints.map(bp => bp.owner_name).filter(Boolean))
-    return ['All', ...Array.from(set).sort()]
-  }, [blueprints])
-
-  const categories = useMemo(() => {
-    const set = new Set(blueprints.map(bp => bp.category_name).filter(Boolean))
-    return ['All', ...Array.from(set).sort()]
-  }, [blueprints])
-
-  // Apply filters before passing data to TanStack Table.
-  const filteredBlueprints = useMemo(() => {
-    return blueprints.filter(bp => {
-      if (statusFilter !== 'All' && getFullStatusLabel(bp) !== statusFilter) return false
-      if (ownerFilter !== 'All' && bp.owner_name !== ownerFilter) return false
-      if (categoryFilter !== 'All' && bp.category_name !== categoryFilter) return false
-      return true
-    })
-  }, [blueprints, statusFilter, ownerFilter, categoryFilter])
-
-  const isFiltered = statusFilter !== 'All' || ownerFilter !== 'All' || categoryFilter !== 'All'
+    return () => { canceled = true }
+  }, [params, cursor, version])
+
+  // Dropdown choices, from the facet counts over the whole library.
+  const owners = page?.facets.owners ?? []
+  const categories = page?.facets.categories ?? []
+  const regions = page?.facets.regions ?? []
+
+  const isFiltered = statusFilter !== 'All' || ownerFilter !== 'All' || categoryFilter !== 'All' ||
+    regionFilter !== 'All' || securityFilter !== 'All' || search !== ''
 
   function clearFilters() {
     setStatusFilter('All')
     setOwnerFilter('All')
     setCategoryFilter('All')
+    setRegionFilter('All')
+    setSecurityFilter('All')
+    setSearch('')
     setSorting(DEFAULT_SORT)
   }
 
@@ -134,6 +176,7 @@ export default function BlueprintTable({ blueprints: externalBlueprints }) {
     {
       accessorKey: 'category_name',
       header: 'Category',
+      enableSorting: false,
     },
     {
       accessorKey: 'owner_name',
@@ -142,7 +185,22 @@ export default function BlueprintTable({ blueprints: externalBlueprints }) {
     {
       accessorKey: 'location_name',
       header: 'Location',
-      cell: ({ getValue }) => getValue() ?? 'Resolving\u2026',
+      enableSorting: false,
+      cell: ({ row, getValue }) => {
+        const name = getValue()
+        if (!name) return 'Resolving\u2026'
+        const sec = row.original.security_status
+        const cls = getSecurityClass(sec)
+        if (!cls) return name
+        return (
+          <>
+            <span className={`bp-security bp-security--${cls.toLowerCase()}`}>
+              {sec.toFixed(1)}
+            </span>
+            {name}
+          </>
+        )
+      },
     },
     {
       accessorKey: 'me_level',
@@ -163,33 +221,22 @@ export default function BlueprintTable({ blueprints: externalBlueprints }) {
         if (label === 'Idle') return <span className="bp-status--idle">{label}</span>
         return label
       },
-      sortingFn: (rowA, rowB) =>
-        getStatusPriority(rowA.original) - getStatusPriority(rowB.original),
     },
     {
       id: 'end_date',
       header: 'Date End',
       accessorFn: row => row.job?.end_date,
       cell: ({ row }) => formatLocalDate(row.original.job?.end_date),
-      sortUndefined: 'last',
-      sortingFn: (rowA, rowB) => {
-        const a = rowA.original.job?.end_date
-        const b = rowB.original.job?.end_date
-        if (!a && !b) return 0
-        if (!a) return 1
-        if (!b) return -1
-        return new Date(a) - new Date(b)
-      },
     },
   ], [])
 
   const table = useReactTable({
-    data: filteredBlueprints,
+    data: page?.items ?? [],
     columns,
     state: { sorting },
     onSortingChange: setSorting,
     getCoreRowModel: getCoreRowModel(),
-    getSortedRowModel: getSortedRowModel(),
+    manualSorting: true,
     enableMultiSort: true,
     enableSortingRemoval: false,
   })
@@ -198,17 +245,32 @@ export default function BlueprintTable({ blueprints: externalBlueprints }) {
     return <div className="bp-table-state">Loading blueprints…</div>
   }
 
-  if (error) {
+  if (error && !page) {
     return <div className="bp-table-state bp-table-state--error">Failed to load blueprints: {error}</div>
   }
 
-  if (blueprints.length === 0) {
+  // No owner facet means no blueprints at all, whatever the filters.
+  if (owners.length === 0) {
     return <div className="bp-table-state bp-table-state--empty">No blueprints found.</div>
   }
 
+  const first = (cursors.length - 1) * PAGE_SIZE
+  const pageEnd = first + page.items.length
+
   return (
     <div>
       <div className="bp-filters">
+        <label className="bp-filters__group">
+          <span className="bp-filters__label">Search</span>
+          <input
+            className="bp-filters__search"
+            type="search"
+            placeholder="Type, group, location, owner"
+            value={search}
+            onChange={e => setSearch(e.target.value)}
+          />
+        </label>
+
         <label className="bp-filters__group">
           <span className="bp-filters__label">Status</span>
           <select
@@ -227,7 +289,8 @@ export default function BlueprintTable({ blueprints: externalBlueprints }) {
             value={ownerFilter}
             onChange={e => setOwnerFilter(e.target.value)}
           >
-            {owners.map(o => <option key={o}>{o}</option>)}
+            <option value="All">All</option>
+            {owners.map(o => <option key={o.owner_id} value={o.owner_id}>{o.name}</option>)}
           </select>
         </label>
 
@@ -238,7 +301,31 @@ export default function BlueprintTable({ blueprints: externalBlueprints }) {
             value={categoryFilter}
             onChange={e => setCategoryFilter(e.target.value)}
           >
-            {categories.map(c => <option key={c}>{c}</option>)}
+            <option value="All">All</option>
+            {categories.map(c => <option key={c.id} value={c.id}>{c.name}</option>)}
+          </select>
+        </label>
+
+        <label className="bp-filters__group">
+          <span className="bp-filters__label">Region</span>
+          <select
+            className="bp-filters__select"
+            value={regionFilter}
+            onChange={e => setRegionFilter(e.target.value)}
+          >
+            <option value="All">All</option>
+            {regions.map(r => <option key={r.id} value={r.id}>{r.name}</option>)}
+          </select>
+        </label>
+
+        <label className="bp-filters__group">
+          <span className="bp-filters__label">Security</span>
+          <select
+            className="bp-filters__select"
+            value={securityFilter}
+            onChange={e => setSecurityFilter(e.target.value)}
+          >
+            {SECURITY_OPTIONS.map(s => <option key={s}>{s}</option>)}
           </select>
         </label>
 
@@ -247,8 +334,18 @@ export default function BlueprintTable({ blueprints: externalBlueprints }) {
             Clear filters
           </button>
         )}
+
+        <span className="bp-filters__export">
+          Export
+          <a href={exportURL('blueprints', 'csv', params)} download>CSV</a>
+          <a href={exportURL('blueprints', 'xlsx', params)} download>XLSX</a>
+        </span>
       </div>
 
+      {error && (
+        <div className="bp-table-state bp-table-state--error">Failed to load blueprints: {error}</div>
+      )}
+
       <div className="bp-table-wrapper">
         <table className="bp-table">
           <thead>
@@ -287,10 +384,30 @@ export default function BlueprintTable({ blueprints: externalBlueprints }) {
           </tbody>
         </table>
 
-        {filteredBlueprints.length === 0 && (
+        {page.total === 0 ? (
           <div className="bp-table-state bp-table-state--empty">
             No blueprints match the current filters.
           </div>
+        ) : (
+          <div className="bp-pager">
+            <span className="bp-pager__range">
+              {first + 1}–{pageEnd} of {page.total}
+            </span>
+            <button
+              className="bp-pager__btn"
+              disabled={cursors.length === 1}
+              onClick={() => goToPage(cursors.slice(0, -1))}
+            >
+              ← Prev
+            </button>
+            <button
+              className="bp-pager__btn"
+              disabled={!page.next_cursor}
+              onClick={() => goToPage([...cursors, page.next_cursor])}
+            >
+              Next →
+            </button>
+          </div>
         )}
       </div>
     </div>
@@ -1,5 +1,5 @@
 import { useState, useEffect, useCallback } from 'react'
-import { getCharacters, getCorporations, patchDelegate, deleteCharacter, getBlueprints, getSyncStatus } from '../api/client.js'
+import { getCharacters, getCorporations, patchDelegate, deleteCharacter, confirmTransfer, getBlueprints, getSyncStatus } from '../api/client.js'
 
 const NPC_CORP_MIN = 1_000_000
 const NPC_CORP_MAX = 2_000_000
@@ -19,7 +19,7 @@ export default function CharactersPage() {
   // null = initial load not complete yet
   const [characters, setCharacters] = useState(null)
   const [corporations, setCorporations] = useState(null)
-  const [blueprints, setBlueprints] = useState(null)
+  const [ownerFacets, setOwnerFacets] = useState(null)
   const [syncStatuses, setSyncStatuses] = useState(null)
   const [error, setError] = useState(null)
 
@@ -28,12 +28,12 @@ export default function CharactersPage() {
       const [chars, corps, bps, syncs] = await Promise.all([
         getCharacters(),
         getCorporations(),
-        getBlueprints(),
+        getBlueprints({ limit: 1 }), // only the facet counts are used
         getSyncStatus(),
       ])
       setCharacters(chars ?? [])
       setCorporations(corps ?? [])
-      setBlueprints(bps ?? [])
+      setOwnerFacets(bps?.facets?.owners ?? [])
       setSyncStatuses(syncs ?? [])
       setError(null)
     } catch (err) {
@@ -52,6 +52,23 @@ export default function CharactersPage() {
     }
   }
 
+  async function handleConfirmTransfer(char) {
+    const message = `${char.name} now belongs to another EVE account. Resume syncing and merge the new owner's data with the stored blueprints and jobs? To start fresh instead, delete the character and add it again.`
+    if (!window.confirm(message)) return
+
+    try {
+      await confirmTransfer(char.id)
+      loadData()
+    } catch (err) {
+      setError(err.message)
+    }
+  }
+
+  // Corporation roles of a member, or null until they have been fetched.
+  function memberRoles(corpId, charId) {
+    return corpsMap.get(corpId)?.members?.find(m => m.id === charId)?.roles ?? null
+  }
+
   async function handleDelete(char) {
     const otherSameCorp = characters.filter(c => c.id !== char.id && c.corporation_id === char.corporation_id)
     const isLastInPlayerCorp = otherSameCorp.length === 0 && !isNpcCorp(char.corporation_id)
@@ -87,11 +104,11 @@ export default function CharactersPage() {
     return <div className="chars-page__error">Error: {error}</div>
   }
 
-  // Precompute blueprint counts per character
+  // Blueprint counts per character, from the owner facets of /api/blueprints
   const bpCountByChar = {}
-  for (const bp of (blueprints ?? [])) {
-    if (bp.owner_type === 'character') {
-      bpCountByChar[bp.owner_id] = (bpCountByChar[bp.owner_id] ?? 0) + 1
+  for (const owner of (ownerFacets ?? [])) {
+    if (owner.owner_type === 'character') {
+      bpCountByChar[owner.owner_id] = owner.count
     }
   }
 
@@ -135,7 +152,12 @@ export default function CharactersPage() {
               <table className="chars-group__table">
                 <thead>
                   <tr className="chars-corp-row">
-                    <th className="chars-corp-row__name" colSpan={npc ? 4 : 5}>{corpName}</th>
+                    <th className="chars-corp-row__name" colSpan={npc ? 4 : 5}>
+                      {corpName}
+                      {chars[0].alliance_name && (
+                        <span className="chars-corp-row__alliance">{chars[0].alliance_name}</span>
+                      )}
+                    </th>
                   </tr>
                   <tr className="chars-thead">
                     <th className="chars-thead__th chars-thead__th--name">Name</th>
@@ -148,7 +170,36 @@ export default function CharactersPage() {
                 <tbody>
                   {chars.map(char => (
                     <tr key={char.id} className="chars-row">
-                      <td className="chars-row__name">{char.name}</td>
+                      <td className="chars-row__name">
+                        {char.name}
+                        {char.needs_reauth && (
+                          <a
+                            className="chars-row__reauth"
+                            href={char.reauth_url}
+                            title="EVE SSO rejected this character's token. Log in again to resume syncing."
+                          >
+                            ⚠ Re-authorize
+                          </a>
+                        )}
+                        {char.transferred && (
+                          <button
+                            className="chars-row__transferred"
+                            onClick={() => handleConfirmTransfer(char)}
+                            title="The character changed owner. Sync is paused until the transfer is confirmed."
+                          >
+                            ⚠ Transferred — confirm
+                          </button>
+                        )}
+                        {!char.needs_reauth && char.upgrade_url && (
+                          <a
+                            className="chars-row__upgrade"
+                            href={char.upgrade_url}
+                            title={`Missing scopes: ${char.missing_scopes.join(', ')}`}
+                          >
+                            Grant missing scopes
+                          </a>
+                        )}
+                      </td>
                       {!npc && (
                         <td className="chars-row__delegate">
                           {char.is_delegate ? (
@@ -161,6 +212,19 @@ export default function CharactersPage() {
                               ○ Make delegate
                             </button>
                           )}
+                          {!char.is_delegate && corpsMap.get(corpId)?.active_character_id === char.id && (
+                            <span
+                              className="chars-row__in-use"
+                              title="The delegate lacks the corporation roles; this character's token is used for corporation data."
+                            >
+                              ◆ In use
+                            </span>
+                          )}
+                          {memberRoles(corpId, char.id) && (
+                            <span className="chars-row__roles">
+                              {memberRoles(corpId, char.id).join(', ') || 'No roles'}
+                            </span>
+                          )}
                           {char.sync_error && (
                             <span className="chars-row__sync-error" title={char.sync_error}>
                               ⚠ no access
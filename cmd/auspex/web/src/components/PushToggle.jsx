@@ -0,0 +1,102 @@
+import { useState, useEffect } from 'react'
+import {
+  getPushKey,
+  getPushSubscriptions,
+  createPushSubscription,
+  deletePushSubscription,
+} from '../api/client.js'
+
+const SUPPORTED =
+  'serviceWorker' in navigator && 'PushManager' in window && 'Notification' in window
+
+// urlBase64ToUint8Array decodes the server's base64url public key into the
+// form PushManager.subscribe takes.
+function urlBase64ToUint8Array(s) {
+  const base64 = (s + '='.repeat((4 - (s.length % 4)) % 4)).replace(/-/g, '+').replace(/_/g, '/')
+  return Uint8Array.from(atob(base64), c => c.charCodeAt(0))
+}
+
+function sameBytes(buffer, bytes) {
+  if (!buffer) return false
+  const a = new Uint8Array(buffer)
+  return a.length === bytes.length && a.every((b, i) => b === bytes[i])
+}
+
+// PushToggle turns Web Push notifications for ready jobs and freed slots on
+// and off in this browser. Hidden where push is not supported, such as over
+// plain HTTP other than on localhost.
+export default function PushToggle() {
+  const [registration, setRegistration] = useState(null)
+  const [subscribed, setSubscribed] = useState(false)
+  const [busy, setBusy] = useState(false)
+  const [error, setError] = useState(null)
+
+  useEffect(() => {
+    if (!SUPPORTED) return
+    navigator.serviceWorker
+      .register('/sw.js')
+      .then(async reg => {
+        setRegistration(reg)
+        setSubscribed((await reg.pushManager.getSubscription()) !== null)
+      })
+      .catch(err => setError(err.message))
+  }, [])
+
+  if (!SUPPORTED || !registration) return null
+
+  async function enable() {
+    if ((await Notification.requestPermission()) !== 'granted') {
+      throw new Error('Notifications are blocked for this site')
+    }
+    const { public_key: publicKey } = await getPushKey()
+    const key = urlBase64ToUint8Array(publicKey)
+    let sub = await registration.pushManager.getSubscription()
+    if (sub && !sameBytes(sub.options.applicationServerKey, key)) {
+      // Subscribed with another key, e.g. before the database was reset.
+      await sub.unsubscribe()
+      sub = null
+    }
+    if (!sub) {
+      sub = await registration.pushManager.subscribe({ userVisibleOnly: true, applicationServerKey: key })
+    }
+    await createPushSubscription(sub.toJSON())
+  }
+
+  async function disable() {
+    const sub = await registration.pushManager.getSubscription()
+    if (!sub) return
+    const stored = await getPushSubscriptions()
+    const match = stored.find(s => s.endpoint === sub.endpoint)
+    if (match) await deletePushSubscription(match.id)
+    await sub.unsubscribe()
+  }
+
+  async function toggle() {
+    setBusy(true)
+    setError(null)
+    try {
+      if (subscribed) {
+        await disable()
+        setSubscribed(false)
+      } else {
+        await enable()
+        setSubscribed(true)
+      }
+    } catch (err) {
+      setError(err.message)
+    } finally {
+      setBusy(false)
+    }
+  }
+
+  return (
+    <button
+      className="app-refresh-btn"
+      onClick={toggle}
+      disabled={busy}
+      title={error ?? (subscribed ? 'Stop notifications in this browser' : 'Notify this browser when jobs are ready or slots free up')}
+    >
+      {subscribed ? 'Notifications on' : 'Notifications off'}
+    </button>
+  )
+}
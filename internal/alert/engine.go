@@ -0,0 +1,241 @@
+// Package alert evaluates user-defined alert rules after every sync cycle.
+//
+// A rule is a boolean expression over facts about the blueprint library, such
+// as
+//
+//	job.activity == "copying" && job.remaining < duration("2h")
+//
+// (see Compile for the language). The facts a rule refers to decide what it
+// is evaluated for: once, or once per owner, character, blueprint or job.
+// Every subject that matches and has not fired for the rule within its
+// cooldown is sent, in one alert per rule, to the rule's channels, recorded
+// in alert_history, and published on the events bus as alert_fired. A rule
+// does not fire during its quiet hours; subjects that still match afterwards
+// fire then.
+package alert
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+const (
+	// MaxCooldown is the longest cooldown a rule can have. History is kept
+	// longer, so that a cooldown never outlives the firing it counts from.
+	MaxCooldown = 30 * 24 * time.Hour
+
+	// historyRetention is how long fired alerts are kept.
+	historyRetention = 90 * 24 * time.Hour
+
+	// maxListed is the number of matches listed in the text of an alert.
+	maxListed = 10
+)
+
+// Alert is what a rule sends to its channels after a sync cycle: the
+// subjects it matched.
+type Alert struct {
+	RuleID  int64
+	Rule    string
+	Matches []Match
+	Time    time.Time
+}
+
+// Match is a subject a rule matched.
+type Match struct {
+	Subject     string `json:"subject"` // e.g. "job:512345678"
+	Description string `json:"description"`
+}
+
+// Text lists the matches of a, one per line, up to 10.
+func (a Alert) Text() string {
+	var b strings.Builder
+	for i, m := range a.Matches {
+		if i == maxListed {
+			fmt.Fprintf(&b, "and %d more\n", len(a.Matches)-maxListed)
+			break
+		}
+		b.WriteString(m.Description + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Channel delivers alerts, e.g. to Discord.
+type Channel interface {
+	Send(ctx context.Context, a Alert) error
+}
+
+// ChannelFunc adapts a function to a Channel.
+type ChannelFunc func(ctx context.Context, a Alert) error
+
+// Send calls f.
+func (f ChannelFunc) Send(ctx context.Context, a Alert) error { return f(ctx, a) }
+
+// Engine evaluates the alert rules in the store. Create one with New and
+// start it with Run.
+type Engine struct {
+	q        store.Querier
+	channels map[string]Channel // by channel name; a missing one is not configured
+	bus      *events.Bus        // alert_fired is published to; set by Run
+	now      func() time.Time
+}
+
+// New creates an Engine that reads rules from q and sends alerts to channels,
+// keyed by channel name. Alerts for a channel not in channels are recorded
+// with an error.
+func New(q store.Querier, channels map[string]Channel) *Engine {
+	return &Engine{q: q, channels: channels, now: time.Now}
+}
+
+// Run evaluates the rules after every sync cycle published on bus, until ctx
+// is canceled or the bus is closed. If it falls behind and cycles are lost,
+// it evaluates them at once.
+func (e *Engine) Run(ctx context.Context, bus *events.Bus) {
+	e.bus = bus
+	events.Follow(ctx, bus, func(ev events.Event) { e.handle(ctx, ev) }, func() {
+		if err := e.Evaluate(ctx); err != nil {
+			log.Printf("alert: %v", err)
+		}
+	})
+}
+
+func (e *Engine) handle(ctx context.Context, ev events.Event) {
+	if ev.Type != events.TypeCycleFinished {
+		return
+	}
+	if err := e.Evaluate(ctx); err != nil {
+		log.Printf("alert: %v", err)
+	}
+}
+
+// Evaluate evaluates every enabled rule once and fires the ones that match.
+func (e *Engine) Evaluate(ctx context.Context) error {
+	now := e.now()
+	rows, err := e.q.ListAlertRules(ctx)
+	if err != nil {
+		return fmt.Errorf("listing alert rules: %w", err)
+	}
+	var rules []*Rule
+	for _, row := range rows {
+		if row.Enabled == 0 {
+			continue
+		}
+		r, err := NewRule(row)
+		if err != nil {
+			// Rules are validated when saved; a fact removed since is
+			// reported here.
+			log.Printf("alert: rule %d: %v", row.ID, err)
+			continue
+		}
+		rules = append(rules, r)
+	}
+	if len(rules) > 0 {
+		snap, err := loadSnapshot(ctx, e.q, now)
+		if err != nil {
+			return err
+		}
+		for _, r := range rules {
+			if r.Quiet != nil && r.Quiet.contains(now.In(r.Location)) {
+				continue
+			}
+			matches, err := e.due(ctx, r, snap.match(r), now)
+			if err != nil {
+				return err
+			}
+			if len(matches) > 0 {
+				e.fire(ctx, r, matches, now)
+			}
+		}
+	}
+	if err := e.q.DeleteAlertHistoryBefore(ctx, now.Add(-historyRetention).UTC()); err != nil {
+		return fmt.Errorf("pruning alert history: %w", err)
+	}
+	return nil
+}
+
+// due returns the matches of r that have not fired within its cooldown.
+func (e *Engine) due(ctx context.Context, r *Rule, matches []Match, now time.Time) ([]Match, error) {
+	var out []Match
+	for _, m := range matches {
+		last, err := e.q.GetLastAlertFiring(ctx, store.GetLastAlertFiringParams{RuleID: r.ID, Subject: m.Subject})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("getting last firing of rule %d: %w", r.ID, err)
+		}
+		if err == nil && now.Sub(last) < r.Cooldown {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// fire sends an alert for matches to the channels of r, records it, and
+// publishes it. A channel that fails does not stop the others; the errors
+// are recorded with the alert. It is recorded even if every channel failed,
+// so that a failing channel is not retried every cycle.
+func (e *Engine) fire(ctx context.Context, r *Rule, matches []Match, now time.Time) {
+	a := Alert{RuleID: r.ID, Rule: r.Name, Matches: matches, Time: now}
+	var errs []string
+	for _, name := range r.Channels {
+		ch, ok := e.channels[name]
+		if !ok {
+			errs = append(errs, name+": not configured")
+			continue
+		}
+		if err := ch.Send(ctx, a); err != nil {
+			log.Printf("alert: rule %d: sending to %s: %v", r.ID, name, err)
+			errs = append(errs, name+": "+err.Error())
+		}
+	}
+
+	// Record even if ctx was canceled while sending.
+	ctx = context.WithoutCancel(ctx)
+	errText := sql.NullString{String: strings.Join(errs, "; "), Valid: len(errs) > 0}
+	for _, m := range matches {
+		if err := e.q.CreateAlertHistory(ctx, store.CreateAlertHistoryParams{
+			RuleID:      r.ID,
+			Subject:     m.Subject,
+			Description: m.Description,
+			Channels:    FormatChannels(r.Channels),
+			Error:       errText,
+			FiredAt:     now.UTC(),
+		}); err != nil {
+			log.Printf("alert: rule %d: recording %s: %v", r.ID, m.Subject, err)
+		}
+	}
+
+	data := events.Alert{RuleID: r.ID, Rule: r.Name, Matches: make([]events.AlertMatch, len(matches))}
+	for i, m := range matches {
+		data.Matches[i] = events.AlertMatch{Subject: m.Subject, Description: m.Description}
+	}
+	e.bus.Publish(events.TypeAlertFired, data)
+}
+
+// Matches returns the subjects r matches now, regardless of its cooldown and
+// quiet hours: what it would fire for if it had never fired.
+func Matches(ctx context.Context, q store.Querier, r *Rule) ([]Match, error) {
+	snap, err := loadSnapshot(ctx, q, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return snap.match(r), nil
+}
+
+// match returns the subjects in s that r matches. A subject r cannot be
+// evaluated for, for lack of a fact it refers to, does not match.
+func (s *snapshot) match(r *Rule) []Match {
+	var out []Match
+	for _, sub := range s.subjects(r.Expr.Scope()) {
+		if ok, err := r.Expr.eval(sub); err == nil && ok {
+			out = append(out, Match{Subject: sub.key, Description: sub.description})
+		}
+	}
+	return out
+}
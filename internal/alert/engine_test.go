@@ -0,0 +1,256 @@
+package alert
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/db"
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	sqlDB, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return sqlDB
+}
+
+func mustExec(t *testing.T, sqlDB *sql.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := sqlDB.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// seedLibrary adds Alice, with an idle blueprint (11) and one copying (10)
+// that ends 90 minutes after now.
+func seedLibrary(t *testing.T, sqlDB *sql.DB, now time.Time) {
+	t.Helper()
+	mustExec(t, sqlDB,
+		`INSERT INTO characters (id, name, access_token, refresh_token, token_expiry, corporation_id, corporation_name)
+		 VALUES (1, 'Alice', 'tok', 'rtok', ?, 0, '')`, now.Add(time.Hour))
+	mustExec(t, sqlDB, `INSERT INTO eve_categories (id, name) VALUES (1, 'Category')`)
+	mustExec(t, sqlDB, `INSERT INTO eve_groups (id, category_id, name) VALUES (1, 1, 'Group')`)
+	mustExec(t, sqlDB, `INSERT INTO eve_types (id, group_id, name) VALUES (1, 1, 'Rifter Blueprint')`)
+	mustExec(t, sqlDB,
+		`INSERT INTO blueprints (id, owner_type, owner_id, type_id, location_id, me_level, te_level, updated_at)
+		 VALUES (10, 'character', 1, 1, 0, 10, 20, ?), (11, 'character', 1, 1, 0, 0, 0, ?)`, now, now)
+	mustExec(t, sqlDB,
+		`INSERT INTO jobs (id, blueprint_id, owner_type, owner_id, installer_id, activity, status, start_date, end_date, updated_at)
+		 VALUES (100, 10, 'character', 1, 1, 'copying', 'active', ?, ?, ?)`,
+		now.Add(-time.Hour), now.Add(90*time.Minute), now)
+}
+
+func createRule(t *testing.T, q *store.Queries, p store.CreateAlertRuleParams) store.AlertRule {
+	t.Helper()
+	if p.Timezone == "" {
+		p.Timezone = "UTC"
+	}
+	p.Enabled = 1
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = p.CreatedAt
+	r, err := q.CreateAlertRule(context.Background(), p)
+	if err != nil {
+		t.Fatalf("CreateAlertRule: %v", err)
+	}
+	return r
+}
+
+// recorder is a Channel that records the alerts sent to it.
+type recorder struct {
+	alerts []Alert
+	err    error
+}
+
+func (r *recorder) Send(_ context.Context, a Alert) error {
+	r.alerts = append(r.alerts, a)
+	return r.err
+}
+
+func subjects(alerts []Alert) []string {
+	var out []string
+	for _, a := range alerts {
+		for _, m := range a.Matches {
+			out = append(out, m.Subject)
+		}
+	}
+	return out
+}
+
+func TestEngine_Cooldown(t *testing.T) {
+	sqlDB := newTestDB(t)
+	q := store.New(sqlDB)
+	clock := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	seedLibrary(t, sqlDB, clock)
+	rule := createRule(t, q, store.CreateAlertRuleParams{
+		Name:       "Copies ending soon",
+		Expression: `job.activity == "copying" && job.remaining < duration("2h")`,
+		Channels:   "push,email",
+		Cooldown:   3600,
+	})
+
+	push := &recorder{}
+	e := New(q, map[string]Channel{ChannelPush: push})
+	e.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	evaluate := func() {
+		t.Helper()
+		if err := e.Evaluate(ctx); err != nil {
+			t.Fatalf("Evaluate: %v", err)
+		}
+	}
+	evaluate()
+	clock = clock.Add(30 * time.Minute) // within the cooldown
+	evaluate()
+	clock = clock.Add(45 * time.Minute) // past it
+	evaluate()
+
+	if got := strings.Join(subjects(push.alerts), ","); got != "job:100,job:100" {
+		t.Errorf("pushed %q, want job:100 twice", got)
+	}
+	if d := push.alerts[0].Matches[0].Description; d != "Rifter Blueprint (Alice): Copying, 1h30m left" {
+		t.Errorf("description = %q", d)
+	}
+	history, err := q.ListAlertHistoryByRule(ctx, store.ListAlertHistoryByRuleParams{RuleID: rule.ID, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListAlertHistoryByRule: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("history has %d rows, want 2", len(history))
+	}
+	if h := history[0]; h.Channels != "push,email" || h.Error.String != "email: not configured" {
+		t.Errorf("history = %+v, want the email channel reported not configured", h)
+	}
+}
+
+func TestEngine_QuietHours(t *testing.T) {
+	sqlDB := newTestDB(t)
+	q := store.New(sqlDB)
+	clock := time.Date(2026, 10, 19, 21, 30, 0, 0, time.UTC) // 23:30 in Paris
+	seedLibrary(t, sqlDB, clock)
+	createRule(t, q, store.CreateAlertRuleParams{
+		Name:       "Idle blueprints",
+		Expression: `idle_bpos > 0`,
+		Channels:   "push",
+		Cooldown:   3600,
+		QuietStart: "22:00",
+		QuietEnd:   "07:00",
+		Timezone:   "Europe/Paris",
+	})
+
+	push := &recorder{}
+	e := New(q, map[string]Channel{ChannelPush: push})
+	e.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	for _, d := range []time.Duration{0, 3 * time.Hour, 4 * time.Hour} { // 23:30, 02:30, 06:30
+		clock = clock.Add(d)
+		if err := e.Evaluate(ctx); err != nil {
+			t.Fatalf("Evaluate: %v", err)
+		}
+	}
+	if len(push.alerts) != 0 {
+		t.Fatalf("pushed %d alerts during quiet hours", len(push.alerts))
+	}
+	clock = clock.Add(time.Hour) // 07:30
+	if err := e.Evaluate(ctx); err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if got := strings.Join(subjects(push.alerts), ","); got != "global" {
+		t.Errorf("pushed %q, want global", got)
+	}
+}
+
+func TestEngine_Scopes(t *testing.T) {
+	sqlDB := newTestDB(t)
+	q := store.New(sqlDB)
+	clock := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	seedLibrary(t, sqlDB, clock)
+	createRule(t, q, store.CreateAlertRuleParams{
+		Name: "Free slots", Expression: `character.used_slots < 2`, Channels: "push", Cooldown: 172800,
+	})
+	createRule(t, q, store.CreateAlertRuleParams{
+		Name: "Idle for a day", Expression: `blueprint.idle_for >= duration("24h")`, Channels: "push", Cooldown: 172800,
+	})
+	disabled := createRule(t, q, store.CreateAlertRuleParams{
+		Name: "Everything", Expression: `true`, Channels: "push",
+	})
+	mustExec(t, sqlDB, `UPDATE alert_rules SET enabled = 0 WHERE id = ?`, disabled.ID)
+
+	push := &recorder{}
+	e := New(q, map[string]Channel{ChannelPush: push})
+	e.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	// The first cycle notes since when blueprint 11 is idle.
+	if err := e.Evaluate(ctx); err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	clock = clock.Add(25 * time.Hour)
+	if err := e.Evaluate(ctx); err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if got := strings.Join(subjects(push.alerts), ","); got != "character:1,blueprint:11" {
+		t.Errorf("pushed %q, want character:1 then blueprint:11", got)
+	}
+}
+
+func TestEngine_ChannelErrorRecordedAndPublished(t *testing.T) {
+	sqlDB := newTestDB(t)
+	q := store.New(sqlDB)
+	clock := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	seedLibrary(t, sqlDB, clock)
+	rule := createRule(t, q, store.CreateAlertRuleParams{
+		Name: "Ready soon", Expression: `job.remaining < duration("2h")`, Channels: "discord", Cooldown: 3600,
+	})
+
+	bus := events.NewBus(16)
+	sub, _, _ := bus.Subscribe(0)
+	defer sub.Close()
+	discord := &recorder{err: errors.New("webhook responded 500")}
+	e := New(q, map[string]Channel{ChannelDiscord: discord})
+	e.bus = bus
+	e.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	if err := e.Evaluate(ctx); err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	history, err := q.ListAlertHistory(ctx, 10)
+	if err != nil {
+		t.Fatalf("ListAlertHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].Error.String != "discord: webhook responded 500" {
+		t.Errorf("history = %+v, want the discord error", history)
+	}
+
+	select {
+	case ev := <-sub.Events():
+		a, ok := ev.Data.(events.Alert)
+		if ev.Type != events.TypeAlertFired || !ok || a.RuleID != rule.ID || len(a.Matches) != 1 || a.Matches[0].Subject != "job:100" {
+			t.Errorf("event = %+v, want alert_fired for job:100", ev)
+		}
+	default:
+		t.Error("no alert_fired event published")
+	}
+}
+
+func TestAlert_Text(t *testing.T) {
+	a := Alert{Rule: "Idle"}
+	for i := range 12 {
+		a.Matches = append(a.Matches, Match{Description: string(rune('a' + i))})
+	}
+	want := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nand 2 more"
+	if got := a.Text(); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
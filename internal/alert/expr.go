@@ -0,0 +1,685 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxExpression is the longest expression accepted, in bytes.
+const maxExpression = 2000
+
+// Type is the type of a value in an expression.
+type Type int
+
+// Value types.
+const (
+	Bool Type = iota + 1
+	Number
+	String
+	Duration
+)
+
+func (t Type) String() string {
+	switch t {
+	case Bool:
+		return "bool"
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	case Duration:
+		return "duration"
+	}
+	return "invalid"
+}
+
+// Expr is a compiled rule expression. Create one with Compile.
+type Expr struct {
+	src   string
+	root  node
+	scope Scope
+}
+
+// String returns the source of e.
+func (e *Expr) String() string { return e.src }
+
+// Scope returns what e is evaluated for, from the facts it refers to.
+func (e *Expr) Scope() Scope { return e.scope }
+
+// Compile parses src and checks that it is a boolean expression over known
+// facts.
+func Compile(src string) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, errors.New("expression is empty")
+	}
+	if len(src) > maxExpression {
+		return nil, fmt.Errorf("expression is longer than %d characters", maxExpression)
+	}
+	p := &parser{lex: lexer{src: src}}
+	p.next()
+	root, err := p.parseOr()
+	if err == nil && p.err != nil {
+		err = p.err // a lexer error ends the token stream early
+	}
+	if err == nil && p.tok.kind != tokEOF {
+		err = p.errorf("unexpected %s", p.tok)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := &Expr{src: src, root: root}
+	typ, err := e.check(root)
+	if err != nil {
+		return nil, err
+	}
+	if typ != Bool {
+		return nil, fmt.Errorf("expression is a %s, not a condition", typ)
+	}
+	return e, nil
+}
+
+// --- Lexer ---
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp // operators and punctuation
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // byte offset in the source
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return "string " + t.text
+	}
+	return strconv.Quote(t.text)
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+// operators are the operator tokens, longest first.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "(", ")", ","}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && strings.ContainsRune(" \t\r\n", rune(l.src[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos == len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case isIdentStart(c):
+		for l.pos < len(l.src) && (isIdentStart(l.src[l.pos]) || isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}, nil
+	case isDigit(c):
+		for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}, nil
+	case c == '"':
+		l.pos++
+		for l.pos < len(l.src) && l.src[l.pos] != '"' {
+			if l.src[l.pos] == '\\' {
+				l.pos++
+			}
+			l.pos++
+		}
+		if l.pos >= len(l.src) {
+			return token{}, fmt.Errorf("position %d: unterminated string", start+1)
+		}
+		l.pos++
+		s, err := strconv.Unquote(l.src[start:l.pos])
+		if err != nil {
+			return token{}, fmt.Errorf("position %d: invalid string %s", start+1, l.src[start:l.pos])
+		}
+		return token{kind: tokString, text: s, pos: start}, nil
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokOp, text: op, pos: start}, nil
+		}
+	}
+	r := []rune(l.src[l.pos:])[0]
+	if !unicode.IsPrint(r) {
+		return token{}, fmt.Errorf("position %d: unexpected character %U", start+1, r)
+	}
+	return token{}, fmt.Errorf("position %d: unexpected character %q", start+1, r)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// --- Parser ---
+
+// node is a node of the syntax tree.
+type node interface{ position() int }
+
+type (
+	literal struct {
+		pos   int
+		value any // bool, float64 or string
+	}
+	ident struct {
+		pos  int
+		name string
+	}
+	unary struct {
+		pos int
+		op  string
+		x   node
+	}
+	binary struct {
+		pos  int
+		op   string
+		x, y node
+		typ  Type // of x, set by check
+	}
+	call struct {
+		pos  int
+		fn   string
+		args []node
+	}
+)
+
+func (n *literal) position() int { return n.pos }
+func (n *ident) position() int   { return n.pos }
+func (n *unary) position() int   { return n.pos }
+func (n *binary) position() int  { return n.pos }
+func (n *call) position() int    { return n.pos }
+
+type parser struct {
+	lex lexer
+	tok token
+	err error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lex.next()
+	if p.err != nil {
+		p.tok = token{kind: tokEOF, pos: p.lex.pos}
+	}
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	if p.err != nil {
+		return p.err
+	}
+	return fmt.Errorf("position %d: %s", p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	if p.tok.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBinary parses operands joined by ops, left to right.
+func (p *parser) parseBinary(operand func() (node, error), ops ...string) (node, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(ops...) {
+		op := p.tok
+		p.next()
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{pos: op.pos, op: op.text, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseOr() (node, error) { return p.parseBinary(p.parseAnd, "||") }
+
+func (p *parser) parseAnd() (node, error) { return p.parseBinary(p.parseComparison, "&&") }
+
+// parseComparison parses a comparison, which does not chain: a < b < c is an
+// error rather than a comparison of a bool.
+func (p *parser) parseComparison() (node, error) {
+	x, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("==", "!=", "<", "<=", ">", ">=") {
+		return x, nil
+	}
+	op := p.tok
+	p.next()
+	y, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("==", "!=", "<", "<=", ">", ">=") {
+		return nil, p.errorf("comparisons cannot be chained; use &&")
+	}
+	return &binary{pos: op.pos, op: op.text, x: x, y: y}, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	return p.parseBinary(p.parseUnary, "*", "/")
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("!", "-") {
+		op := p.tok
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{pos: op.pos, op: op.text, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		p.next()
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("position %d: invalid number %s", tok.pos+1, tok.text)
+		}
+		return &literal{pos: tok.pos, value: v}, nil
+	case tokString:
+		p.next()
+		return &literal{pos: tok.pos, value: tok.text}, nil
+	case tokIdent:
+		p.next()
+		switch tok.text {
+		case "true":
+			return &literal{pos: tok.pos, value: true}, nil
+		case "false":
+			return &literal{pos: tok.pos, value: false}, nil
+		}
+		if !p.isOp("(") {
+			return &ident{pos: tok.pos, name: tok.text}, nil
+		}
+		p.next()
+		c := &call{pos: tok.pos, fn: tok.text}
+		for !p.isOp(")") {
+			if len(c.args) > 0 {
+				if !p.isOp(",") {
+					return nil, p.errorf("expected , or ) in call of %s, found %s", c.fn, p.tok)
+				}
+				p.next()
+			}
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			c.args = append(c.args, arg)
+		}
+		p.next()
+		return c, nil
+	case tokOp:
+		if tok.text == "(" {
+			p.next()
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOp(")") {
+				return nil, p.errorf("expected ), found %s", p.tok)
+			}
+			p.next()
+			return x, nil
+		}
+	}
+	return nil, p.errorf("unexpected %s", tok)
+}
+
+// --- Type checking ---
+
+// functions are the functions an expression can call.
+var functions = map[string]struct {
+	params []Type
+	result Type
+}{
+	"duration": {[]Type{String}, Duration}, // e.g. duration("1h30m")
+	"hours":    {[]Type{Duration}, Number},
+	"days":     {[]Type{Duration}, Number},
+	"contains": {[]Type{String, String}, Bool}, // case-insensitive
+	"lower":    {[]Type{String}, String},
+}
+
+// check returns the type of n, and raises e's scope to that of the facts n
+// refers to.
+func (e *Expr) check(n node) (Type, error) {
+	errorf := func(format string, args ...any) (Type, error) {
+		return 0, fmt.Errorf("position %d: %s", n.position()+1, fmt.Sprintf(format, args...))
+	}
+	switch n := n.(type) {
+	case *literal:
+		switch n.value.(type) {
+		case bool:
+			return Bool, nil
+		case float64:
+			return Number, nil
+		default:
+			return String, nil
+		}
+
+	case *ident:
+		f, ok := facts[n.name]
+		if !ok {
+			return errorf("unknown fact %s", n.name)
+		}
+		e.scope = max(e.scope, f.scope)
+		return f.typ, nil
+
+	case *unary:
+		x, err := e.check(n.x)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case n.op == "!" && x == Bool:
+			return Bool, nil
+		case n.op == "-" && (x == Number || x == Duration):
+			return x, nil
+		}
+		return errorf("operator %s does not apply to a %s", n.op, x)
+
+	case *binary:
+		x, err := e.check(n.x)
+		if err != nil {
+			return 0, err
+		}
+		y, err := e.check(n.y)
+		if err != nil {
+			return 0, err
+		}
+		n.typ = x
+		if t, ok := binaryType(n.op, x, y); ok {
+			return t, nil
+		}
+		return errorf("operator %s does not apply to a %s and a %s", n.op, x, y)
+
+	case *call:
+		fn, ok := functions[n.fn]
+		if !ok {
+			return errorf("unknown function %s", n.fn)
+		}
+		if len(n.args) != len(fn.params) {
+			return errorf("%s takes %d argument(s), not %d", n.fn, len(fn.params), len(n.args))
+		}
+		for i, arg := range n.args {
+			t, err := e.check(arg)
+			if err != nil {
+				return 0, err
+			}
+			if t != fn.params[i] {
+				return errorf("argument %d of %s must be a %s, not a %s", i+1, n.fn, fn.params[i], t)
+			}
+		}
+		if n.fn == "duration" {
+			// Parsed here so that a typo is reported when the rule is saved.
+			lit, ok := n.args[0].(*literal)
+			if !ok {
+				return errorf("duration takes a string literal, e.g. duration(\"2h\")")
+			}
+			d, err := time.ParseDuration(lit.value.(string))
+			if err != nil {
+				return errorf("invalid duration %q; use units h, m and s, e.g. \"1h30m\"", lit.value)
+			}
+			lit.value = d
+		}
+		return fn.result, nil
+	}
+	return 0, fmt.Errorf("unexpected node %T", n)
+}
+
+// binaryType returns the type of x op y, and false if op does not apply.
+func binaryType(op string, x, y Type) (Type, bool) {
+	switch op {
+	case "&&", "||":
+		return Bool, x == Bool && y == Bool
+	case "==", "!=":
+		return Bool, x == y
+	case "<", "<=", ">", ">=":
+		return Bool, x == y && x != Bool
+	case "+":
+		return x, x == y && x != Bool
+	case "-":
+		return x, x == y && (x == Number || x == Duration)
+	case "*":
+		switch {
+		case x == Number && y == Number:
+			return Number, true
+		case x == Duration && y == Number:
+			return Duration, true
+		case x == Number && y == Duration:
+			return Duration, true
+		}
+	case "/":
+		switch {
+		case x == Number && y == Number:
+			return Number, true
+		case x == Duration && y == Number:
+			return Duration, true
+		case x == Duration && y == Duration:
+			return Number, true
+		}
+	}
+	return 0, false
+}
+
+// --- Evaluation ---
+
+// errMissing is returned when an expression refers to a fact the subject
+// does not have, e.g. the installer of a job by a character not tracked.
+var errMissing = errors.New("fact not available")
+
+// eval evaluates e for s. A subject that lacks a fact e refers to yields
+// errMissing, and does not match.
+func (e *Expr) eval(s *subject) (bool, error) {
+	v, err := evalNode(e.root, s)
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+func evalNode(n node, s *subject) (any, error) {
+	switch n := n.(type) {
+	case *literal:
+		return n.value, nil
+
+	case *ident:
+		v, ok := facts[n.name].get(s)
+		if !ok {
+			return nil, errMissing
+		}
+		return v, nil
+
+	case *unary:
+		x, err := evalNode(n.x, s)
+		if err != nil {
+			return nil, err
+		}
+		switch x := x.(type) {
+		case bool:
+			return !x, nil
+		case float64:
+			return -x, nil
+		case time.Duration:
+			return -x, nil
+		}
+
+	case *binary:
+		x, err := evalNode(n.x, s)
+		if err != nil {
+			return nil, err
+		}
+		// Short-circuit, so that a missing fact on the right does not
+		// matter when the left decides.
+		switch n.op {
+		case "&&":
+			if !x.(bool) {
+				return false, nil
+			}
+			return evalNode(n.y, s)
+		case "||":
+			if x.(bool) {
+				return true, nil
+			}
+			return evalNode(n.y, s)
+		}
+		y, err := evalNode(n.y, s)
+		if err != nil {
+			return nil, err
+		}
+		return evalBinary(n.op, x, y)
+
+	case *call:
+		args := make([]any, len(n.args))
+		for i, arg := range n.args {
+			v, err := evalNode(arg, s)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		switch n.fn {
+		case "duration":
+			return args[0], nil // parsed by check
+		case "hours":
+			return args[0].(time.Duration).Hours(), nil
+		case "days":
+			return args[0].(time.Duration).Hours() / 24, nil
+		case "contains":
+			return strings.Contains(strings.ToLower(args[0].(string)), strings.ToLower(args[1].(string))), nil
+		case "lower":
+			return strings.ToLower(args[0].(string)), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot evaluate %T", n)
+}
+
+// evalBinary applies an operator other than && and || to values of the types
+// check accepted.
+func evalBinary(op string, x, y any) (any, error) {
+	switch op {
+	case "==":
+		return x == y, nil
+	case "!=":
+		return x != y, nil
+	}
+	switch x := x.(type) {
+	case float64:
+		if d, ok := y.(time.Duration); ok { // number * duration
+			return time.Duration(x * float64(d)), nil
+		}
+		y := y.(float64)
+		switch op {
+		case "<":
+			return x < y, nil
+		case "<=":
+			return x <= y, nil
+		case ">":
+			return x > y, nil
+		case ">=":
+			return x >= y, nil
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/":
+			if y == 0 {
+				return nil, errors.New("division by zero")
+			}
+			return x / y, nil
+		}
+	case time.Duration:
+		switch y := y.(type) {
+		case float64:
+			if op == "*" {
+				return time.Duration(float64(x) * y), nil
+			}
+			if y == 0 {
+				return nil, errors.New("division by zero")
+			}
+			return time.Duration(float64(x) / y), nil
+		case time.Duration:
+			switch op {
+			case "<":
+				return x < y, nil
+			case "<=":
+				return x <= y, nil
+			case ">":
+				return x > y, nil
+			case ">=":
+				return x >= y, nil
+			case "+":
+				return x + y, nil
+			case "-":
+				return x - y, nil
+			case "/":
+				if y == 0 {
+					return nil, errors.New("division by zero")
+				}
+				return float64(x) / float64(y), nil
+			}
+		}
+	case string:
+		y := y.(string)
+		switch op {
+		case "<":
+			return x < y, nil
+		case "<=":
+			return x <= y, nil
+		case ">":
+			return x > y, nil
+		case ">=":
+			return x >= y, nil
+		case "+":
+			return x + y, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot apply %s to %T and %T", op, x, y)
+}
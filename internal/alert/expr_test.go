@@ -0,0 +1,159 @@
+package alert
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// testSubject is a copy job on a blueprint of Main Corp, 90 minutes from
+// done, installed by a character not tracked.
+func testSubject() *subject {
+	corp := &owner{typ: "corporation", id: 98000001, name: "Main Corp", counts: counts{blueprints: 30, idle: 25, active: 5}}
+	return &subject{
+		key:    "job:500",
+		counts: &corp.counts,
+		owner:  corp,
+		blueprint: &blueprint{
+			row: store.ListBlueprintsRow{
+				ID:               10,
+				OwnerType:        "corporation",
+				OwnerID:          98000001,
+				OwnerName:        "Main Corp",
+				TypeName:         "Rifter Blueprint",
+				CategoryName:     "Ship",
+				MeLevel:          10,
+				TeLevel:          20,
+				SecurityStatus:   sql.NullFloat64{Float64: 0.9, Valid: true},
+				JobID:            sql.NullInt64{Int64: 500, Valid: true},
+				JobActivity:      sql.NullString{String: "copying", Valid: true},
+				JobStatus:        sql.NullString{String: "active", Valid: true},
+				JobInstallerName: sql.NullString{String: "Alt", Valid: true},
+			},
+			status:    statusActive,
+			idleFor:   -1,
+			remaining: 90 * time.Minute,
+		},
+	}
+}
+
+func TestCompile_Scope(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Scope
+	}{
+		{`idle_bpos > 20`, ScopeGlobal},
+		{`owner.name == "Main Corp" && idle_bpos > 20`, ScopeOwner},
+		{`character.used_slots < 11`, ScopeCharacter},
+		{`owner.type == "character" && blueprint.me < 10`, ScopeBlueprint},
+		{`job.activity == "copying" && job.remaining < duration("2h")`, ScopeJob},
+	}
+	for _, tt := range tests {
+		e, err := Compile(tt.src)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.src, err)
+			continue
+		}
+		if e.Scope() != tt.want {
+			t.Errorf("Compile(%q).Scope() = %s, want %s", tt.src, e.Scope(), tt.want)
+		}
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{``, "expression is empty"},
+		{`1 + 2`, "is a number, not a condition"},
+		{`job.activity == 1`, "position 14: operator == does not apply to a string and a number"},
+		{`job.remaining < 2`, "does not apply to a duration and a number"},
+		{`job.activty == "copying"`, "position 1: unknown fact job.activty"},
+		{`job.remaining < duration("2 hours")`, `invalid duration "2 hours"`},
+		{`job.remaining < duration(job.activity)`, "duration takes a string literal"},
+		{`1 < 2 < 3`, "comparisons cannot be chained"},
+		{`(1 < 2`, "expected ), found end of expression"},
+		{`job.activity == "copying`, "position 17: unterminated string"},
+		{`1 @ 2`, `position 3: unexpected character '@'`},
+		{`lower() == ""`, "lower takes 1 argument(s), not 0"},
+		{`upper("a") == "A"`, "unknown function upper"},
+		{`true &&`, "unexpected end of expression"},
+		{`!1`, "operator ! does not apply to a number"},
+		{strings.Repeat("true && ", 300) + "true", "longer than 2000 characters"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Compile(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestExpr_Eval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{`job.activity == "copying" && job.remaining < duration("2h")`, true},
+		{`job.activity == "copying" && job.remaining < duration("1h")`, false},
+		{`owner.name == "Main Corp" && idle_bpos > 20`, true},
+		{`blueprints - idle_bpos == active_jobs && ready_jobs == 0`, true},
+		{`1 + 2 * 3 == 7 && (1 + 2) * 3 == 9`, true},
+		{`hours(job.remaining) == 1.5 && days(duration("36h")) == 1.5`, true},
+		{`job.remaining / 2 == duration("45m") && 2 * job.remaining == duration("3h")`, true},
+		{`job.remaining / duration("30m") == 3`, true},
+		{`-job.remaining < duration("0s")`, true},
+		{`contains(blueprint.type, "RIFTER") && lower(owner.name) == "main corp"`, true},
+		{`blueprint.type + "!" == "Rifter Blueprint!"`, true},
+		{`blueprint.security >= 0.5 && blueprint.category != "Module"`, true},
+		{`!(blueprint.me >= 10) || blueprint.te == 20`, true},
+		{`job.installer == "Alt" && blueprint.status == "active"`, true},
+		{`"a" < "b"`, true},
+		// A fact on the right of || or && is not needed when the left decides.
+		{`true || character.name == "Alt"`, true},
+		{`false && character.name == "Alt"`, false},
+	}
+	for _, tt := range tests {
+		e, err := Compile(tt.src)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.src, err)
+			continue
+		}
+		got, err := e.eval(testSubject())
+		if err != nil {
+			t.Errorf("eval(%q): %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("eval(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestExpr_Eval_Errors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want error
+	}{
+		// The installer is not a tracked character, and the blueprint is not
+		// idle.
+		{`character.name == "Alt"`, errMissing},
+		{`blueprint.idle_for > duration("1h")`, errMissing},
+		{`blueprint.region == "The Forge"`, errMissing},
+		{`blueprints / ready_jobs > 1`, nil},
+	}
+	for _, tt := range tests {
+		e, err := Compile(tt.src)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", tt.src, err)
+		}
+		_, err = e.eval(testSubject())
+		if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("eval(%q) error = %v, want %v", tt.src, err, tt.want)
+		}
+	}
+}
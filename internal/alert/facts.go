@@ -0,0 +1,344 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// Scope is what a rule is evaluated for: once, or once per owner, character,
+// blueprint or job. It is the narrowest scope of the facts the rule refers
+// to; a rule about job.remaining is evaluated per job.
+type Scope int
+
+// Scopes, from the widest.
+const (
+	ScopeGlobal Scope = iota
+	ScopeOwner
+	ScopeCharacter
+	ScopeBlueprint
+	ScopeJob
+)
+
+func (s Scope) String() string {
+	switch s {
+	case ScopeGlobal:
+		return "global"
+	case ScopeOwner:
+		return "owner"
+	case ScopeCharacter:
+		return "character"
+	case ScopeBlueprint:
+		return "blueprint"
+	case ScopeJob:
+		return "job"
+	}
+	return "invalid"
+}
+
+// Statuses of blueprints and jobs, as in the dashboard.
+const (
+	statusIdle   = "idle"
+	statusActive = "active"
+	statusReady  = "ready"
+)
+
+// fact is a value an expression can refer to by name.
+type fact struct {
+	typ   Type
+	scope Scope
+	get   func(*subject) (any, bool) // false if the subject lacks the fact
+}
+
+// facts are the facts an expression can refer to, by name.
+var facts = map[string]fact{
+	// Counts of the owner in scope, or of every owner in the global scope.
+	"blueprints":  {Number, ScopeGlobal, func(s *subject) (any, bool) { return float64(s.counts.blueprints), true }},
+	"idle_bpos":   {Number, ScopeGlobal, func(s *subject) (any, bool) { return float64(s.counts.idle), true }},
+	"active_jobs": {Number, ScopeGlobal, func(s *subject) (any, bool) { return float64(s.counts.active), true }},
+	"ready_jobs":  {Number, ScopeGlobal, func(s *subject) (any, bool) { return float64(s.counts.ready), true }},
+
+	"owner.id":   ownerFact(Number, func(o *owner) any { return float64(o.id) }),
+	"owner.type": ownerFact(String, func(o *owner) any { return o.typ }),
+	"owner.name": ownerFact(String, func(o *owner) any { return o.name }),
+
+	"character.id":          characterFact(Number, func(c *character) any { return float64(c.id) }),
+	"character.name":        characterFact(String, func(c *character) any { return c.name }),
+	"character.corporation": characterFact(String, func(c *character) any { return c.corporation }),
+	"character.used_slots":  characterFact(Number, func(c *character) any { return float64(c.usedSlots) }),
+
+	"blueprint.id":       blueprintFact(Number, func(b *blueprint) (any, bool) { return float64(b.row.ID), true }),
+	"blueprint.type":     blueprintFact(String, func(b *blueprint) (any, bool) { return b.row.TypeName, true }),
+	"blueprint.group":    blueprintFact(String, func(b *blueprint) (any, bool) { return b.row.GroupName, true }),
+	"blueprint.category": blueprintFact(String, func(b *blueprint) (any, bool) { return b.row.CategoryName, true }),
+	"blueprint.me":       blueprintFact(Number, func(b *blueprint) (any, bool) { return float64(b.row.MeLevel), true }),
+	"blueprint.te":       blueprintFact(Number, func(b *blueprint) (any, bool) { return float64(b.row.TeLevel), true }),
+	"blueprint.location": blueprintFact(String, func(b *blueprint) (any, bool) { return b.row.LocationName.String, b.row.LocationName.Valid }),
+	"blueprint.system":   blueprintFact(String, func(b *blueprint) (any, bool) { return b.row.SystemName.String, b.row.SystemName.Valid }),
+	"blueprint.region":   blueprintFact(String, func(b *blueprint) (any, bool) { return b.row.RegionName.String, b.row.RegionName.Valid }),
+	"blueprint.security": blueprintFact(Number, func(b *blueprint) (any, bool) { return b.row.SecurityStatus.Float64, b.row.SecurityStatus.Valid }),
+	"blueprint.status":   blueprintFact(String, func(b *blueprint) (any, bool) { return b.status, true }),
+	"blueprint.idle_for": blueprintFact(Duration, func(b *blueprint) (any, bool) { return b.idleFor, b.status == statusIdle && b.idleFor >= 0 }),
+
+	"job.id":           jobFact(Number, func(b *blueprint) any { return float64(b.row.JobID.Int64) }),
+	"job.activity":     jobFact(String, func(b *blueprint) any { return b.row.JobActivity.String }),
+	"job.status":       jobFact(String, func(b *blueprint) any { return b.status }),
+	"job.remaining":    jobFact(Duration, func(b *blueprint) any { return b.remaining }),
+	"job.duration":     jobFact(Duration, func(b *blueprint) any { return b.row.JobEndDate.Time.Sub(b.row.JobStartDate.Time) }),
+	"job.installer":    jobFact(String, func(b *blueprint) any { return b.row.JobInstallerName.String }),
+	"job.installer_id": jobFact(Number, func(b *blueprint) any { return float64(b.row.JobInstallerID.Int64) }),
+}
+
+func ownerFact(typ Type, get func(*owner) any) fact {
+	return fact{typ, ScopeOwner, func(s *subject) (any, bool) {
+		if s.owner == nil {
+			return nil, false
+		}
+		return get(s.owner), true
+	}}
+}
+
+func characterFact(typ Type, get func(*character) any) fact {
+	return fact{typ, ScopeCharacter, func(s *subject) (any, bool) {
+		if s.character == nil {
+			return nil, false
+		}
+		return get(s.character), true
+	}}
+}
+
+func blueprintFact(typ Type, get func(*blueprint) (any, bool)) fact {
+	return fact{typ, ScopeBlueprint, func(s *subject) (any, bool) {
+		if s.blueprint == nil {
+			return nil, false
+		}
+		return get(s.blueprint)
+	}}
+}
+
+func jobFact(typ Type, get func(*blueprint) any) fact {
+	return fact{typ, ScopeJob, func(s *subject) (any, bool) {
+		if s.blueprint == nil || !s.blueprint.row.JobID.Valid {
+			return nil, false
+		}
+		return get(s.blueprint), true
+	}}
+}
+
+type owner struct {
+	typ    string // "character" or "corporation"
+	id     int64
+	name   string
+	counts counts
+}
+
+type character struct {
+	id          int64
+	name        string
+	corporation string
+	usedSlots   int64
+}
+
+type blueprint struct {
+	row       store.ListBlueprintsRow
+	status    string        // statusIdle, statusActive or statusReady
+	idleFor   time.Duration // if idle; negative if not recorded yet
+	remaining time.Duration // of the job, if any; zero once ready
+}
+
+type counts struct {
+	blueprints, idle, active, ready int64
+}
+
+func (c *counts) add(status string) {
+	c.blueprints++
+	switch status {
+	case statusIdle:
+		c.idle++
+	case statusActive:
+		c.active++
+	case statusReady:
+		c.ready++
+	}
+}
+
+// subject is what a rule is evaluated for: the whole library, an owner, a
+// character, a blueprint, or a blueprint's job. Facts the subject has no
+// value for are nil.
+type subject struct {
+	key         string // identifies the subject across cycles, e.g. "job:123"
+	description string
+	counts      *counts
+	owner       *owner
+	character   *character
+	blueprint   *blueprint
+}
+
+// snapshot is the state of the library at the end of a sync cycle.
+type snapshot struct {
+	total      counts
+	owners     []*owner // characters, then corporations
+	byOwner    map[string]*owner
+	characters map[int64]*character
+	blueprints []*blueprint
+}
+
+// loadSnapshot reads the library from q. It also updates blueprint_idle, which
+// records since when blueprints have been idle.
+func loadSnapshot(ctx context.Context, q store.Querier, now time.Time) (*snapshot, error) {
+	chars, err := q.ListCharacters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing characters: %w", err)
+	}
+	corps, err := q.ListCorporations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing corporations: %w", err)
+	}
+	usage, err := q.ListCharacterSlotUsage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing slot usage: %w", err)
+	}
+	rows, err := q.ListBlueprints(ctx, store.ListBlueprintsParams{})
+	if err != nil {
+		return nil, fmt.Errorf("listing blueprints: %w", err)
+	}
+	if err := q.MarkIdleBlueprints(ctx, now.UTC()); err != nil {
+		return nil, fmt.Errorf("marking idle blueprints: %w", err)
+	}
+	if err := q.ClearBusyBlueprints(ctx); err != nil {
+		return nil, fmt.Errorf("clearing busy blueprints: %w", err)
+	}
+	idle, err := q.ListIdleBlueprintsSince(ctx, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("listing idle blueprints: %w", err)
+	}
+
+	s := &snapshot{
+		byOwner:    make(map[string]*owner, len(chars)+len(corps)),
+		characters: make(map[int64]*character, len(chars)),
+	}
+	addOwner := func(typ string, id int64, name string) *owner {
+		o := &owner{typ: typ, id: id, name: name}
+		s.owners = append(s.owners, o)
+		s.byOwner[ownerKey(typ, id)] = o
+		return o
+	}
+	for _, c := range chars {
+		addOwner("character", c.ID, c.Name)
+		s.characters[c.ID] = &character{id: c.ID, name: c.Name, corporation: c.CorporationName}
+	}
+	for _, c := range corps {
+		addOwner("corporation", c.ID, c.Name)
+	}
+	for _, u := range usage {
+		if c := s.characters[u.ID]; c != nil {
+			c.usedSlots = u.UsedSlots
+		}
+	}
+
+	idleSince := make(map[int64]time.Time, len(idle))
+	for _, b := range idle {
+		idleSince[b.BlueprintID] = b.IdleSince
+	}
+	for _, r := range rows {
+		b := &blueprint{row: r, status: statusIdle, idleFor: -1}
+		switch {
+		case !r.JobID.Valid:
+			if since, ok := idleSince[r.ID]; ok {
+				b.idleFor = max(now.Sub(since), 0)
+			}
+		case r.JobStatus.String == "ready" || !r.JobEndDate.Time.After(now):
+			b.status = statusReady
+		default:
+			b.status = statusActive
+			b.remaining = r.JobEndDate.Time.Sub(now)
+		}
+		s.blueprints = append(s.blueprints, b)
+		s.total.add(b.status)
+		o := s.byOwner[ownerKey(r.OwnerType, r.OwnerID)]
+		if o == nil {
+			// An owner removed since its blueprints were synced.
+			o = addOwner(r.OwnerType, r.OwnerID, r.OwnerName)
+		}
+		o.counts.add(b.status)
+	}
+	return s, nil
+}
+
+// subjects returns the subjects a rule of scope is evaluated for.
+func (s *snapshot) subjects(scope Scope) []*subject {
+	var out []*subject
+	switch scope {
+	case ScopeGlobal:
+		out = append(out, &subject{key: "global", description: "All owners", counts: &s.total})
+	case ScopeOwner:
+		for _, o := range s.owners {
+			out = append(out, &subject{
+				key:         ownerKey(o.typ, o.id),
+				description: o.name,
+				counts:      &o.counts,
+				owner:       o,
+				character:   s.ownCharacter(o),
+			})
+		}
+	case ScopeCharacter:
+		for _, o := range s.owners {
+			if c := s.ownCharacter(o); c != nil {
+				out = append(out, &subject{
+					key:         ownerKey(o.typ, o.id),
+					description: o.name,
+					counts:      &o.counts,
+					owner:       o,
+					character:   c,
+				})
+			}
+		}
+	case ScopeBlueprint, ScopeJob:
+		for _, b := range s.blueprints {
+			if scope == ScopeJob && !b.row.JobID.Valid {
+				continue
+			}
+			o := s.byOwner[ownerKey(b.row.OwnerType, b.row.OwnerID)]
+			sub := &subject{
+				key:         "blueprint:" + strconv.FormatInt(b.row.ID, 10),
+				description: fmt.Sprintf("%s (%s)", b.row.TypeName, b.row.OwnerName),
+				counts:      &o.counts,
+				owner:       o,
+				character:   s.ownCharacter(o),
+				blueprint:   b,
+			}
+			if scope == ScopeJob {
+				sub.key = "job:" + strconv.FormatInt(b.row.JobID.Int64, 10)
+				sub.description += ": " + jobDescription(b)
+				// The character of a job is the one who installed it.
+				sub.character = s.characters[b.row.JobInstallerID.Int64]
+			}
+			out = append(out, sub)
+		}
+	}
+	return out
+}
+
+// ownCharacter returns the character o is, or nil if o is a corporation.
+func (s *snapshot) ownCharacter(o *owner) *character {
+	if o.typ != "character" {
+		return nil
+	}
+	return s.characters[o.id]
+}
+
+func jobDescription(b *blueprint) string {
+	label := events.ActivityLabel(b.row.JobActivity.String)
+	if b.status == statusReady {
+		return label + ", ready"
+	}
+	return fmt.Sprintf("%s, %s left", label, FormatDuration(b.remaining.Round(time.Minute)))
+}
+
+func ownerKey(ownerType string, id int64) string {
+	return ownerType + ":" + strconv.FormatInt(id, 10)
+}
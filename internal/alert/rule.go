@@ -0,0 +1,157 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// Channel names, as in the channels of a rule.
+const (
+	ChannelPush    = "push"    // Web Push to the subscribed browsers
+	ChannelDiscord = "discord" // the Discord webhooks that take rule alerts
+	ChannelEmail   = "email"   // the digest recipients
+)
+
+// Channels lists every channel name.
+var Channels = []string{ChannelPush, ChannelDiscord, ChannelEmail}
+
+// DefaultCooldown is the cooldown of a rule that does not set one.
+const DefaultCooldown = 24 * time.Hour
+
+// Rule is an alert rule as it is evaluated.
+type Rule struct {
+	ID       int64
+	Name     string
+	Expr     *Expr
+	Channels []string
+	Cooldown time.Duration  // before the same subject fires again
+	Quiet    *QuietHours    // nil if none
+	Location *time.Location // of the quiet hours
+}
+
+// QuietHours is a daily period a rule does not fire in, from Start up to End
+// in minutes after midnight. A period with End before Start spans midnight.
+type QuietHours struct {
+	Start, End int
+}
+
+// contains reports whether t, in its location, is within q.
+func (q *QuietHours) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if q.Start <= q.End {
+		return q.Start <= m && m < q.End
+	}
+	return m >= q.Start || m < q.End
+}
+
+// NewRule compiles a rule as it is stored.
+func NewRule(r store.AlertRule) (*Rule, error) {
+	expr, err := Compile(r.Expression)
+	if err != nil {
+		return nil, err
+	}
+	quiet, err := ParseQuietHours(r.QuietStart, r.QuietEnd)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := loadLocation(r.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &Rule{
+		ID:       r.ID,
+		Name:     r.Name,
+		Expr:     expr,
+		Channels: ParseChannels(r.Channels),
+		Cooldown: time.Duration(r.Cooldown) * time.Second,
+		Quiet:    quiet,
+		Location: loc,
+	}, nil
+}
+
+// Validate checks the fields of a rule before it is stored.
+func Validate(expression string, channels []string, quietStart, quietEnd, timezone string) error {
+	if _, err := Compile(expression); err != nil {
+		return fmt.Errorf("expression: %w", err)
+	}
+	for _, c := range channels {
+		if !slices.Contains(Channels, c) {
+			return fmt.Errorf("unknown channel %q", c)
+		}
+	}
+	if _, err := ParseQuietHours(quietStart, quietEnd); err != nil {
+		return err
+	}
+	_, err := loadLocation(timezone)
+	return err
+}
+
+// ParseQuietHours parses quiet hours as HH:MM times. Both empty means none.
+func ParseQuietHours(start, end string) (*QuietHours, error) {
+	if start == "" && end == "" {
+		return nil, nil
+	}
+	s, err := parseClock(start)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours start: %w", err)
+	}
+	e, err := parseClock(end)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours end: %w", err)
+	}
+	if s == e {
+		return nil, errors.New("quiet hours start and end must differ")
+	}
+	return &QuietHours{Start: s, End: e}, nil
+}
+
+// parseClock returns the minutes after midnight of an HH:MM time.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an HH:MM time", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
+
+// ParseChannels returns the channels stored in a rule's channels column.
+func ParseChannels(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+// FormatChannels returns the channels column value for channels.
+func FormatChannels(channels []string) string {
+	return strings.Join(channels, ",")
+}
+
+// FormatDuration formats d without the zero units time.Duration.String adds:
+// "24h" rather than "24h0m0s".
+func FormatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
@@ -0,0 +1,351 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/alert"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// Alert rules are boolean expressions over the blueprint library, evaluated
+// after every sync cycle. See package alert for the language, the cooldowns
+// and quiet hours, and the channels.
+
+const (
+	defaultAlertHistoryLimit = 50
+	maxAlertHistoryLimit     = 500
+)
+
+type alertRuleJSON struct {
+	ID         int64           `json:"id"`
+	Name       string          `json:"name"`
+	Expression string          `json:"expression"`
+	Scope      string          `json:"scope"` // what the rule is evaluated for, from its expression
+	Channels   []string        `json:"channels"`
+	Cooldown   string          `json:"cooldown"` // Go duration, e.g. "24h"
+	QuietHours *quietHoursJSON `json:"quiet_hours"`
+	Timezone   string          `json:"timezone"`
+	Enabled    bool            `json:"enabled"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+type quietHoursJSON struct {
+	Start string `json:"start"` // HH:MM
+	End   string `json:"end"`
+}
+
+type alertRuleRequest struct {
+	Name       string          `json:"name"`
+	Expression string          `json:"expression"`
+	Channels   []string        `json:"channels"`
+	Cooldown   string          `json:"cooldown"`    // default 24h
+	QuietHours *quietHoursJSON `json:"quiet_hours"` // default none
+	Timezone   string          `json:"timezone"`    // default UTC
+	Enabled    *bool           `json:"enabled"`     // default true
+
+	cooldown time.Duration
+}
+
+type alertHistoryJSON struct {
+	ID          int64     `json:"id"`
+	RuleID      int64     `json:"rule_id"`
+	Subject     string    `json:"subject"`
+	Description string    `json:"description"`
+	Channels    []string  `json:"channels"`
+	Error       *string   `json:"error"`
+	FiredAt     time.Time `json:"fired_at"`
+}
+
+type alertMatchesJSON struct {
+	Scope   string        `json:"scope"`
+	Matches []alert.Match `json:"matches"`
+}
+
+// Handles:
+//
+//	GET    /api/alerts
+//	POST   /api/alerts
+//	GET    /api/alerts/history       (query params: rule_id; limit, default 50, max 500)
+//	GET    /api/alerts/{id}
+//	PUT    /api/alerts/{id}
+//	DELETE /api/alerts/{id}
+//	GET    /api/alerts/{id}/matches
+func (r *router) handleGetAlertRules(w http.ResponseWriter, req *http.Request) {
+	rules, err := r.q.ListAlertRules(req.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list alert rules")
+		return
+	}
+	resp := make([]alertRuleJSON, len(rules))
+	for i, rule := range rules {
+		resp[i] = newAlertRuleJSON(rule)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *router) handleCreateAlertRule(w http.ResponseWriter, req *http.Request) {
+	body, ok := decodeAlertRuleRequest(w, req)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	rule, err := r.q.CreateAlertRule(req.Context(), store.CreateAlertRuleParams{
+		Name:       body.Name,
+		Expression: body.Expression,
+		Channels:   alert.FormatChannels(body.Channels),
+		Cooldown:   int64(body.cooldown / time.Second),
+		QuietStart: body.QuietHours.Start,
+		QuietEnd:   body.QuietHours.End,
+		Timezone:   body.Timezone,
+		Enabled:    boolInt(*body.Enabled),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create alert rule")
+		return
+	}
+	writeJSON(w, http.StatusCreated, newAlertRuleJSON(rule))
+}
+
+func (r *router) handleGetAlertRule(w http.ResponseWriter, req *http.Request) {
+	rule, ok := r.alertRuleByID(w, req)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, newAlertRuleJSON(rule))
+}
+
+// handleUpdateAlertRule replaces a rule. Its history is kept, so a subject
+// that fired before an edit stays in its cooldown.
+func (r *router) handleUpdateAlertRule(w http.ResponseWriter, req *http.Request) {
+	id, err := parseID(req, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid alert rule id")
+		return
+	}
+	body, ok := decodeAlertRuleRequest(w, req)
+	if !ok {
+		return
+	}
+	rule, err := r.q.UpdateAlertRule(req.Context(), store.UpdateAlertRuleParams{
+		Name:       body.Name,
+		Expression: body.Expression,
+		Channels:   alert.FormatChannels(body.Channels),
+		Cooldown:   int64(body.cooldown / time.Second),
+		QuietStart: body.QuietHours.Start,
+		QuietEnd:   body.QuietHours.End,
+		Timezone:   body.Timezone,
+		Enabled:    boolInt(*body.Enabled),
+		UpdatedAt:  time.Now().UTC(),
+		ID:         id,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "alert rule not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update alert rule")
+		return
+	}
+	writeJSON(w, http.StatusOK, newAlertRuleJSON(rule))
+}
+
+func (r *router) handleDeleteAlertRule(w http.ResponseWriter, req *http.Request) {
+	id, err := parseID(req, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid alert rule id")
+		return
+	}
+	n, err := r.q.DeleteAlertRule(req.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete alert rule")
+		return
+	}
+	if n == 0 {
+		writeError(w, http.StatusNotFound, "alert rule not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetAlertHistory returns the latest alerts fired, newest first: of
+// every rule, or of the one in rule_id.
+func (r *router) handleGetAlertHistory(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	limit := defaultAlertHistoryLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAlertHistoryLimit {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	var (
+		rows []store.AlertHistory
+		err  error
+	)
+	if v := query.Get("rule_id"); v != "" {
+		ruleID, parseErr := strconv.ParseInt(v, 10, 64)
+		if parseErr != nil {
+			writeError(w, http.StatusBadRequest, "invalid rule_id")
+			return
+		}
+		rows, err = r.q.ListAlertHistoryByRule(req.Context(), store.ListAlertHistoryByRuleParams{
+			RuleID: ruleID,
+			Limit:  int64(limit),
+		})
+	} else {
+		rows, err = r.q.ListAlertHistory(req.Context(), int64(limit))
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list alert history")
+		return
+	}
+	resp := make([]alertHistoryJSON, len(rows))
+	for i, h := range rows {
+		resp[i] = newAlertHistoryJSON(h)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleGetAlertMatches returns what a rule matches now, regardless of its
+// cooldown and quiet hours, and whether it is enabled.
+func (r *router) handleGetAlertMatches(w http.ResponseWriter, req *http.Request) {
+	row, ok := r.alertRuleByID(w, req)
+	if !ok {
+		return
+	}
+	rule, err := alert.NewRule(row)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	matches, err := alert.Matches(req.Context(), r.q, rule)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to evaluate alert rule")
+		return
+	}
+	if matches == nil {
+		matches = []alert.Match{}
+	}
+	writeJSON(w, http.StatusOK, alertMatchesJSON{Scope: rule.Expr.Scope().String(), Matches: matches})
+}
+
+// alertRuleByID returns the alert rule in the id path parameter, or writes an
+// error response and returns false.
+func (r *router) alertRuleByID(w http.ResponseWriter, req *http.Request) (store.AlertRule, bool) {
+	id, err := parseID(req, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid alert rule id")
+		return store.AlertRule{}, false
+	}
+	rule, err := r.q.GetAlertRule(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "alert rule not found")
+		return store.AlertRule{}, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get alert rule")
+		return store.AlertRule{}, false
+	}
+	return rule, true
+}
+
+// decodeAlertRuleRequest decodes and validates the body of a POST or PUT, or
+// writes an error response and returns false. Defaults are filled in: a 24h
+// cooldown, no quiet hours, UTC, and enabled.
+func decodeAlertRuleRequest(w http.ResponseWriter, req *http.Request) (alertRuleRequest, bool) {
+	var body alertRuleRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return body, false
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return body, false
+	}
+	body.cooldown = alert.DefaultCooldown
+	if body.Cooldown != "" {
+		d, err := time.ParseDuration(body.Cooldown)
+		if err != nil || d < 0 || d > alert.MaxCooldown {
+			writeError(w, http.StatusBadRequest, "cooldown must be a duration from 0s to "+alert.FormatDuration(alert.MaxCooldown))
+			return body, false
+		}
+		body.cooldown = d.Round(time.Second)
+	}
+	if body.QuietHours == nil {
+		body.QuietHours = &quietHoursJSON{}
+	}
+	if body.Timezone == "" {
+		body.Timezone = "UTC"
+	}
+	if err := alert.Validate(body.Expression, body.Channels, body.QuietHours.Start, body.QuietHours.End, body.Timezone); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return body, false
+	}
+
+	// Store channels once each, in the order of alert.Channels.
+	var channels []string
+	for _, c := range alert.Channels {
+		if slices.Contains(body.Channels, c) {
+			channels = append(channels, c)
+		}
+	}
+	body.Channels = channels
+	if body.Enabled == nil {
+		enabled := true
+		body.Enabled = &enabled
+	}
+	return body, true
+}
+
+func newAlertRuleJSON(r store.AlertRule) alertRuleJSON {
+	scope := ""
+	if expr, err := alert.Compile(r.Expression); err == nil {
+		scope = expr.Scope().String()
+	}
+	var quiet *quietHoursJSON
+	if r.QuietStart != "" {
+		quiet = &quietHoursJSON{Start: r.QuietStart, End: r.QuietEnd}
+	}
+	return alertRuleJSON{
+		ID:         r.ID,
+		Name:       r.Name,
+		Expression: r.Expression,
+		Scope:      scope,
+		Channels:   alert.ParseChannels(r.Channels),
+		Cooldown:   alert.FormatDuration(time.Duration(r.Cooldown) * time.Second),
+		QuietHours: quiet,
+		Timezone:   r.Timezone,
+		Enabled:    r.Enabled != 0,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+	}
+}
+
+func newAlertHistoryJSON(h store.AlertHistory) alertHistoryJSON {
+	var errText *string
+	if h.Error.Valid {
+		errText = &h.Error.String
+	}
+	return alertHistoryJSON{
+		ID:          h.ID,
+		RuleID:      h.RuleID,
+		Subject:     h.Subject,
+		Description: h.Description,
+		Channels:    alert.ParseChannels(h.Channels),
+		Error:       errText,
+		FiredAt:     h.FiredAt,
+	}
+}
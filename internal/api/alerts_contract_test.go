@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContract_Alert_Lifecycle(t *testing.T) {
+	sqlDB := newContractDB(t)
+	seedCharacter(t, sqlDB, 1001, "Alice", 0)
+	seedBlueprint(t, sqlDB, BlueprintSeed{ID: 10, OwnerID: 1001})
+	seedBlueprint(t, sqlDB, BlueprintSeed{ID: 11, OwnerID: 1001})
+	seedJob(t, sqlDB, JobSeed{ID: 100, BlueprintID: 10, OwnerID: 1001, InstallerID: 1001, Activity: "copying", EndDate: time.Now().Add(time.Hour)})
+	srv := newContractServer(t, sqlDB)
+
+	create := `{"name":"Copies ending soon","expression":"job.activity == \"copying\" && job.remaining < duration(\"2h\")",` +
+		`"channels":["push"],"quiet_hours":{"start":"23:00","end":"07:00"}}`
+	resp, err := http.Post(srv.URL+"/api/alerts", "application/json", strings.NewReader(create))
+	if err != nil {
+		t.Fatalf("POST /api/alerts: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	var rule map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&rule); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	assertField[float64](t, rule, "id")
+	assertField[string](t, rule, "name")
+	assertField[string](t, rule, "expression")
+	assertField[string](t, rule, "scope")
+	assertField[[]any](t, rule, "channels")
+	assertField[string](t, rule, "cooldown")
+	assertField[map[string]any](t, rule, "quiet_hours")
+	assertField[string](t, rule, "timezone")
+	assertField[bool](t, rule, "enabled")
+	assertField[string](t, rule, "created_at")
+	assertField[string](t, rule, "updated_at")
+	if rule["scope"] != "job" || rule["cooldown"] != "24h" || rule["timezone"] != "UTC" {
+		t.Errorf("rule = %v", rule)
+	}
+	base := srv.URL + "/api/alerts/" + jsonNumber(rule["id"])
+
+	matches, err := http.Get(base + "/matches")
+	if err != nil {
+		t.Fatalf("GET matches: %v", err)
+	}
+	defer func() { _ = matches.Body.Close() }()
+	var result struct {
+		Scope   string `json:"scope"`
+		Matches []struct {
+			Subject     string `json:"subject"`
+			Description string `json:"description"`
+		} `json:"matches"`
+	}
+	if err := json.NewDecoder(matches.Body).Decode(&result); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].Subject != "job:100" {
+		t.Errorf("matches = %+v, want job:100", result)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, base, strings.NewReader(`{"name":"Idle","expression":"idle_bpos > 0","enabled":false}`))
+	put, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT: %v", err)
+	}
+	defer func() { _ = put.Body.Close() }()
+	var updated map[string]any
+	if err := json.NewDecoder(put.Body).Decode(&updated); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if updated["enabled"] != false || updated["scope"] != "global" || updated["quiet_hours"] != nil || len(updated["channels"].([]any)) != 0 {
+		t.Errorf("updated = %v", updated)
+	}
+
+	history, err := http.Get(srv.URL + "/api/alerts/history?rule_id=" + jsonNumber(rule["id"]))
+	if err != nil {
+		t.Fatalf("GET history: %v", err)
+	}
+	defer func() { _ = history.Body.Close() }()
+	var fired []map[string]any
+	if err := json.NewDecoder(history.Body).Decode(&fired); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if fired == nil || len(fired) != 0 {
+		t.Errorf("history = %v, want an empty list", fired)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, base, http.NoBody)
+	del, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	_ = del.Body.Close()
+	if del.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", del.StatusCode)
+	}
+	gone, err := http.Get(base)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	_ = gone.Body.Close()
+	if gone.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted rule: expected 404, got %d", gone.StatusCode)
+	}
+}
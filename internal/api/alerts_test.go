@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+func TestCreateAlertRule(t *testing.T) {
+	var got store.CreateAlertRuleParams
+	mux := NewRouter(&mockQuerier{
+		CreateAlertRuleFn: func(_ context.Context, arg store.CreateAlertRuleParams) (store.AlertRule, error) {
+			got = arg
+			return store.AlertRule{
+				ID: 4, Name: arg.Name, Expression: arg.Expression, Channels: arg.Channels, Cooldown: arg.Cooldown,
+				QuietStart: arg.QuietStart, QuietEnd: arg.QuietEnd, Timezone: arg.Timezone, Enabled: arg.Enabled,
+			}, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	body := `{"name":" Copies ending soon ","expression":"job.activity == \"copying\" && job.remaining < duration(\"2h\")",` +
+		`"channels":["email","push","email"],"cooldown":"90m","quiet_hours":{"start":"22:00","end":"07:00"},"timezone":"Europe/Paris"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/alerts", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+	}
+	if got.Name != "Copies ending soon" || got.Channels != "push,email" || got.Cooldown != 5400 || got.Enabled != 1 {
+		t.Errorf("stored %+v", got)
+	}
+
+	var resp alertRuleJSON
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if resp.Scope != "job" || resp.Cooldown != "1h30m" || resp.QuietHours == nil || resp.QuietHours.End != "07:00" {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestCreateAlertRule_Defaults(t *testing.T) {
+	var got store.CreateAlertRuleParams
+	mux := NewRouter(&mockQuerier{
+		CreateAlertRuleFn: func(_ context.Context, arg store.CreateAlertRuleParams) (store.AlertRule, error) {
+			got = arg
+			return store.AlertRule{ID: 1}, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/alerts", strings.NewReader(`{"name":"Idle","expression":"idle_bpos > 20"}`))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+	}
+	if got.Cooldown != 86400 || got.Timezone != "UTC" || got.QuietStart != "" || got.Channels != "" || got.Enabled != 1 {
+		t.Errorf("stored %+v", got)
+	}
+}
+
+func TestCreateAlertRule_Invalid(t *testing.T) {
+	mux := NewRouter(&mockQuerier{
+		CreateAlertRuleFn: func(_ context.Context, _ store.CreateAlertRuleParams) (store.AlertRule, error) {
+			t.Error("alert rule created despite an invalid request")
+			return store.AlertRule{}, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	for _, body := range []string{
+		`{"expression":"idle_bpos > 20"}`,
+		`{"name":"x","expression":"idle_bpos + 20"}`,
+		`{"name":"x","expression":"idle_bpos >"}`,
+		`{"name":"x","expression":"idle_bpos > 20","channels":["slack"]}`,
+		`{"name":"x","expression":"idle_bpos > 20","cooldown":"1 day"}`,
+		`{"name":"x","expression":"idle_bpos > 20","cooldown":"-1h"}`,
+		`{"name":"x","expression":"idle_bpos > 20","cooldown":"1000h"}`,
+		`{"name":"x","expression":"idle_bpos > 20","quiet_hours":{"start":"22:00"}}`,
+		`{"name":"x","expression":"idle_bpos > 20","quiet_hours":{"start":"25:00","end":"07:00"}}`,
+		`{"name":"x","expression":"idle_bpos > 20","timezone":"Mars/Olympus"}`,
+		`not json`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/alerts", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestUpdateAlertRule_NotFound(t *testing.T) {
+	mux := NewRouter(&mockQuerier{
+		UpdateAlertRuleFn: func(_ context.Context, _ store.UpdateAlertRuleParams) (store.AlertRule, error) {
+			return store.AlertRule{}, sql.ErrNoRows
+		},
+	}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodPut, "/api/alerts/9", strings.NewReader(`{"name":"Idle","expression":"idle_bpos > 20"}`))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestGetAlertHistory_ByRule(t *testing.T) {
+	var got store.ListAlertHistoryByRuleParams
+	mux := NewRouter(&mockQuerier{
+		ListAlertHistoryByRuleFn: func(_ context.Context, arg store.ListAlertHistoryByRuleParams) ([]store.AlertHistory, error) {
+			got = arg
+			return []store.AlertHistory{{
+				ID: 1, RuleID: 2, Subject: "job:5", Channels: "push",
+				Error: sql.NullString{String: "push: no browser is subscribed", Valid: true},
+			}}, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/alerts/history?rule_id=2&limit=5", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if got.RuleID != 2 || got.Limit != 5 {
+		t.Errorf("query params = %+v", got)
+	}
+	var resp []alertHistoryJSON
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if len(resp) != 1 || resp[0].Error == nil || len(resp[0].Channels) != 1 {
+		t.Errorf("response = %+v", resp)
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=501", "?rule_id=x"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/alerts/history"+query, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
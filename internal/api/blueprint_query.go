@@ -0,0 +1,321 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// GET /api/blueprints query handling: filters, text search, sorting and cursor
+// pagination. The query is parsed here and run by store.BlueprintPager, which
+// filters, sorts and pages in SQL; sqlc cannot express a variable ORDER BY or
+// negated value lists. A page costs one query for its rows and one counting
+// the matches, whatever the size of the library.
+
+const (
+	// defaultBlueprintLimit and maxBlueprintLimit bound the page size.
+	defaultBlueprintLimit = 100
+	maxBlueprintLimit     = 1000
+
+	// defaultBlueprintSort is the dashboard order: ready first, then running
+	// jobs by end date, then idle blueprints.
+	defaultBlueprintSort = "status,end_date"
+)
+
+// Derived blueprint statuses. A job that is still "active" in ESI but whose
+// end date has passed counts as ready, as on the dashboard.
+const (
+	blueprintStatusReady  = "ready"
+	blueprintStatusActive = "active"
+	blueprintStatusIdle   = "idle"
+)
+
+var (
+	blueprintStatuses   = map[string]bool{blueprintStatusReady: true, blueprintStatusActive: true, blueprintStatusIdle: true}
+	blueprintActivities = map[string]bool{"me_research": true, "te_research": true, "copying": true}
+	ownerTypes          = map[string]bool{"character": true, "corporation": true}
+
+	// blueprintSortFields are the accepted keys of the sort parameter.
+	blueprintSortFields = map[string]bool{"status": true, "end_date": true, "type_name": true, "me": true, "te": true, "owner": true}
+)
+
+// parseValueFilter parses parameter name of q, a multi-valued filter: "a,b"
+// matches either value, "!a,b" matches neither. Repeated parameters are
+// treated as one comma-separated list.
+func parseValueFilter[T comparable](q url.Values, name string, parse func(string) (T, bool)) (store.ValueFilter[T], error) {
+	raw := strings.Join(q[name], ",")
+	if raw == "" {
+		return store.ValueFilter[T]{}, nil
+	}
+	var f store.ValueFilter[T]
+	if rest, ok := strings.CutPrefix(raw, "!"); ok {
+		f.Negate = true
+		raw = rest
+	}
+	for _, s := range strings.Split(raw, ",") {
+		v, ok := parse(strings.TrimSpace(s))
+		if !ok {
+			return store.ValueFilter[T]{}, errors.New("invalid " + name)
+		}
+		if !slices.Contains(f.Values, v) {
+			f.Values = append(f.Values, v)
+		}
+	}
+	return f, nil
+}
+
+func parseIDValue(s string) (int64, bool) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	return id, err == nil
+}
+
+// oneOf returns a parser accepting the keys of allowed.
+func oneOf(allowed map[string]bool) func(string) (string, bool) {
+	return func(s string) (string, bool) { return s, allowed[s] }
+}
+
+// blueprintKey holds the values blueprints are sorted by (see
+// store.BlueprintKey). The key of the last blueprint of a page is also the
+// pagination cursor: the next page starts after it, so blueprints added or
+// removed in between do not shift pages.
+type blueprintKey struct {
+	Status   int64      `json:"s"`           // 0 ready, 1 active, 2 idle
+	EndDate  *time.Time `json:"e,omitempty"` // job end date; nil for idle blueprints
+	TypeName string     `json:"t"`           // lower-cased
+	Me       int64      `json:"m"`
+	Te       int64      `json:"x"`
+	Owner    string     `json:"o"` // lower-cased
+	ID       int64      `json:"i"`
+}
+
+// blueprintCursor is the decoded cursor parameter. Sort is the sort order it
+// was issued for; a cursor cannot be used with another.
+type blueprintCursor struct {
+	Sort string       `json:"sort"`
+	Key  blueprintKey `json:"key"`
+}
+
+func encodeBlueprintCursor(c blueprintCursor) string {
+	b, _ := json.Marshal(c) // plain struct, cannot fail
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeBlueprintCursor(s string) (blueprintCursor, error) {
+	var c blueprintCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	return c, err
+}
+
+// blueprintQuery is the parsed query of GET /api/blueprints.
+type blueprintQuery struct {
+	filter   store.BlueprintFilter
+	sort     []store.BlueprintSort
+	sortSpec string // sort in canonical form, as recorded in cursors
+	limit    int
+	after    *blueprintKey
+}
+
+// parseBlueprintQuery parses the query parameters of GET /api/blueprints. Its
+// errors are client errors, worded for the response.
+func parseBlueprintQuery(q url.Values) (*blueprintQuery, error) {
+	bq := &blueprintQuery{limit: defaultBlueprintLimit}
+	var err error
+
+	if bq.filter.OwnerType, err = parseValueFilter(q, "owner_type", oneOf(ownerTypes)); err != nil {
+		return nil, err
+	}
+	if bq.filter.OwnerID, err = parseValueFilter(q, "owner_id", parseIDValue); err != nil {
+		return nil, err
+	}
+	if bq.filter.CategoryID, err = parseValueFilter(q, "category_id", parseIDValue); err != nil {
+		return nil, err
+	}
+	if bq.filter.Status, err = parseValueFilter(q, "status", oneOf(blueprintStatuses)); err != nil {
+		return nil, err
+	}
+	if bq.filter.Activity, err = parseValueFilter(q, "activity", oneOf(blueprintActivities)); err != nil {
+		return nil, err
+	}
+	if bq.filter.RegionID, err = parseValueFilter(q, "region_id", parseIDValue); err != nil {
+		return nil, err
+	}
+	if bq.filter.SystemID, err = parseValueFilter(q, "system_id", parseIDValue); err != nil {
+		return nil, err
+	}
+	if bq.filter.Security, err = parseValueFilter(q, "security", oneOf(securityClasses)); err != nil {
+		return nil, err
+	}
+
+	bq.filter.Terms = strings.Fields(sqlLower(q.Get("q")))
+
+	spec := q.Get("sort")
+	if spec == "" {
+		spec = defaultBlueprintSort
+	}
+	seen := make(map[string]bool)
+	names := make([]string, 0, len(blueprintSortFields))
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		f := store.BlueprintSort{Field: s}
+		if rest, ok := strings.CutPrefix(s, "-"); ok {
+			f = store.BlueprintSort{Field: rest, Desc: true}
+		}
+		if !blueprintSortFields[f.Field] || seen[f.Field] {
+			return nil, errors.New("invalid sort")
+		}
+		seen[f.Field] = true
+		bq.sort = append(bq.sort, f)
+		names = append(names, s)
+	}
+	bq.sortSpec = strings.Join(names, ",")
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxBlueprintLimit {
+			return nil, errors.New("invalid limit")
+		}
+		bq.limit = n
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeBlueprintCursor(v)
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+		if c.Sort != bq.sortSpec {
+			return nil, errors.New("cursor does not match sort")
+		}
+		bq.after = &c.Key
+	}
+	return bq, nil
+}
+
+// blueprintPage is the result of running a blueprintQuery.
+type blueprintPage struct {
+	rows       []store.ListBlueprintsRow
+	total      int64  // blueprints matching the filters, across all pages
+	nextCursor string // empty on the last page
+}
+
+// page reads the page of bq from p. now decides whether a job past its end
+// date is ready.
+func (bq *blueprintQuery) page(ctx context.Context, p store.BlueprintPager, now time.Time) (blueprintPage, error) {
+	filter := bq.filter
+	filter.Now = now
+	params := store.BlueprintPageParams{Filter: filter, Sort: bq.sort, Limit: bq.limit + 1}
+	if bq.after != nil {
+		after := store.BlueprintKey(*bq.after)
+		params.After = &after
+	}
+	rows, err := p.ListBlueprintPage(ctx, params)
+	if err != nil {
+		return blueprintPage{}, fmt.Errorf("listing blueprints: %w", err)
+	}
+	total, err := p.CountBlueprintMatches(ctx, filter)
+	if err != nil {
+		return blueprintPage{}, fmt.Errorf("counting blueprints: %w", err)
+	}
+
+	page := blueprintPage{rows: rows, total: total}
+	if len(rows) > bq.limit {
+		page.rows = rows[:bq.limit]
+		last := &page.rows[bq.limit-1]
+		page.nextCursor = encodeBlueprintCursor(blueprintCursor{Sort: bq.sortSpec, Key: newBlueprintKey(last, now)})
+	}
+	return page, nil
+}
+
+// all reads every blueprint matching bq from p, in its order, ignoring the
+// limit and cursor. With jobsOnly, only blueprints with a job are read.
+func (bq *blueprintQuery) all(ctx context.Context, p store.BlueprintPager, now time.Time, jobsOnly bool) ([]store.ListBlueprintsRow, error) {
+	filter := bq.filter
+	filter.Now = now
+	filter.HasJob = jobsOnly
+	rows, err := p.ListBlueprintPage(ctx, store.BlueprintPageParams{Filter: filter, Sort: bq.sort})
+	if err != nil {
+		return nil, fmt.Errorf("listing blueprints: %w", err)
+	}
+	return rows, nil
+}
+
+// blueprintPager returns q as a store.BlueprintPager. Every Querier of package
+// store is one.
+func blueprintPager(q store.Querier) (store.BlueprintPager, error) {
+	p, ok := q.(store.BlueprintPager)
+	if !ok {
+		return nil, fmt.Errorf("%T cannot page blueprints", q)
+	}
+	return p, nil
+}
+
+func newBlueprintKey(row *store.ListBlueprintsRow, now time.Time) blueprintKey {
+	k := blueprintKey{
+		TypeName: sqlLower(row.TypeName),
+		Me:       row.MeLevel,
+		Te:       row.TeLevel,
+		Owner:    sqlLower(row.OwnerName),
+		ID:       row.ID,
+	}
+	switch blueprintStatus(row, now) {
+	case blueprintStatusReady:
+		k.Status = 0
+	case blueprintStatusActive:
+		k.Status = 1
+	default:
+		k.Status = 2
+	}
+	if row.JobID.Valid {
+		end := row.JobEndDate.Time.UTC()
+		k.EndDate = &end
+	}
+	return k
+}
+
+// sqlLower lower-cases s as SQLite's lower() does: ASCII letters only. Keys
+// compared against the database have to be lower-cased the same way.
+func sqlLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// blueprintStatus returns the derived status of row at now.
+func blueprintStatus(row *store.ListBlueprintsRow, now time.Time) string {
+	switch {
+	case !row.JobID.Valid:
+		return blueprintStatusIdle
+	case row.JobStatus.String == blueprintStatusReady || !row.JobEndDate.Time.After(now):
+		return blueprintStatusReady
+	default:
+		return blueprintStatusActive
+	}
+}
+
+// blueprintLocationName returns the station or structure name of row followed
+// by the hangar division and containers the blueprint is in, if any, or nil
+// while the location is not resolved.
+func blueprintLocationName(row *store.ListBlueprintsRow) *string {
+	if !row.LocationName.Valid {
+		return nil
+	}
+	name := row.LocationName.String
+	if row.LocationPath.String != "" {
+		name += " › " + row.LocationPath.String
+	}
+	return &name
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// libraryRows is a small blueprint library covering every sort and filter key.
+func libraryRows(now time.Time) []store.ListBlueprintsRow {
+	job := func(id int64, status string, end time.Time) (sql.NullInt64, sql.NullString, sql.NullTime) {
+		return sql.NullInt64{Int64: 500 + id, Valid: true}, sql.NullString{String: status, Valid: true}, sql.NullTime{Time: end, Valid: true}
+	}
+	rows := []store.ListBlueprintsRow{
+		{ID: 1, OwnerType: "character", OwnerID: 100, OwnerName: "Alpha", TypeName: "Rifter Blueprint", GroupName: "Frigate Blueprint", CategoryID: 9, CategoryName: "Blueprint", MeLevel: 10, TeLevel: 20},
+		{ID: 2, OwnerType: "character", OwnerID: 101, OwnerName: "bravo", TypeName: "Drake Blueprint", GroupName: "Battlecruiser Blueprint", CategoryID: 9, CategoryName: "Blueprint", MeLevel: 8, TeLevel: 16},
+		{ID: 3, OwnerType: "corporation", OwnerID: 200, OwnerName: "Charlie Corp", TypeName: "Tritanium Blueprint", GroupName: "Material Blueprint", CategoryID: 16, CategoryName: "Skill", MeLevel: 10, TeLevel: 18},
+		{ID: 4, OwnerType: "corporation", OwnerID: 200, OwnerName: "Charlie Corp", TypeName: "Drake Blueprint", GroupName: "Battlecruiser Blueprint", CategoryID: 9, CategoryName: "Blueprint", MeLevel: 0, TeLevel: 0},
+		{ID: 5, OwnerType: "character", OwnerID: 100, OwnerName: "Alpha", TypeName: "Caracal Blueprint", GroupName: "Cruiser Blueprint", CategoryID: 9, CategoryName: "Blueprint", MeLevel: 5, TeLevel: 10},
+	}
+	rows[1].JobID, rows[1].JobStatus, rows[1].JobEndDate = job(2, "active", now.Add(2*time.Hour))
+	rows[2].JobID, rows[2].JobStatus, rows[2].JobEndDate = job(3, "ready", now.Add(-time.Hour))
+	rows[3].JobID, rows[3].JobStatus, rows[3].JobEndDate = job(4, "active", now.Add(time.Hour))
+	rows[4].LocationName = sql.NullString{String: "Jita IV - Moon 4 - Caldari Navy Assembly Plant", Valid: true}
+	rows[4].LocationPath = sql.NullString{String: "Corp Hangar 2 (Research)", Valid: true}
+	for i := range rows {
+		rows[i].JobActivity = sql.NullString{String: "copying", Valid: rows[i].JobID.Valid}
+	}
+	return rows
+}
+
+func TestGetBlueprints_MultiValueAndNegatedFilters(t *testing.T) {
+	rows := libraryRows(time.Now())
+
+	for query, want := range map[string][]int64{
+		"owner_id=100,101":                    {1, 2, 5},
+		"owner_id=100&owner_id=200":           {1, 3, 4, 5},
+		"owner_id=!100":                       {2, 3, 4},
+		"category_id=!16":                     {1, 2, 4, 5},
+		"category_id=9,16":                    {1, 2, 3, 4, 5},
+		"status=active,ready":                 {2, 3, 4},
+		"status=!idle&owner_type=corporation": {3, 4},
+		"activity=!copying":                   {1, 5},          // idle blueprints have no activity
+		"region_id=!10000002":                 {1, 2, 3, 4, 5}, // unresolved locations
+	} {
+		page := getBlueprintPage(t, rows, query+"&sort=me")
+		got := pageIDs(page)
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: blueprints = %v, want %v", query, got, want)
+		}
+		if page.Total != int64(len(want)) {
+			t.Errorf("%s: total = %d, want %d", query, page.Total, len(want))
+		}
+	}
+}
+
+func TestGetBlueprints_TextSearch(t *testing.T) {
+	rows := libraryRows(time.Now())
+
+	for q, want := range map[string][]int64{
+		"drake":             {2, 4}, // type name
+		"battlecruiser":     {2, 4}, // group name
+		"BRAVO":             {2},    // owner name, any case
+		"jita research":     {5},    // location and hangar path, all words
+		"charlie tritanium": {3},    // words in different fields
+		"blueprint caracal": {5},
+		"corp caracal":      {5}, // "Corp Hangar"
+		"rifter drake":      {},  // no single blueprint has both
+	} {
+		page := getBlueprintPage(t, rows, "q="+url.QueryEscape(q))
+		got := pageIDs(page)
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("q=%q: blueprints = %v, want %v", q, got, want)
+		}
+	}
+}
+
+func TestGetBlueprints_Sort(t *testing.T) {
+	rows := libraryRows(time.Now())
+
+	for sort, want := range map[string][]int64{
+		"":                 {3, 4, 2, 1, 5}, // default: ready, active by end date, idle
+		"status,-end_date": {3, 2, 4, 1, 5},
+		"-end_date":        {2, 4, 3, 1, 5}, // no job last either way
+		"type_name":        {5, 2, 4, 1, 3},
+		"type_name,-owner": {5, 4, 2, 1, 3}, // owner names compared ignoring case
+		"-me,te":           {3, 1, 2, 5, 4},
+		"owner,-te":        {1, 5, 2, 3, 4},
+	} {
+		page := getBlueprintPage(t, rows, "sort="+sort)
+		if got := pageIDs(page); !slices.Equal(got, want) {
+			t.Errorf("sort=%s: blueprints = %v, want %v", sort, got, want)
+		}
+	}
+}
+
+func TestGetBlueprints_CursorPagination(t *testing.T) {
+	rows := libraryRows(time.Now())
+
+	var got []int64
+	query := "sort=type_name&limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination did not end")
+		}
+		page := getBlueprintPage(t, rows, query)
+		if page.Total != int64(len(rows)) {
+			t.Errorf("total = %d, want %d", page.Total, len(rows))
+		}
+		got = append(got, pageIDs(page)...)
+		if page.NextCursor == nil {
+			break
+		}
+		query = "sort=type_name&limit=2&cursor=" + *page.NextCursor
+	}
+	if want := []int64{5, 2, 4, 1, 3}; !slices.Equal(got, want) {
+		t.Errorf("blueprints across pages = %v, want %v", got, want)
+	}
+}
+
+func TestGetBlueprints_CursorSurvivesRemovedBlueprint(t *testing.T) {
+	rows := libraryRows(time.Now())
+
+	mux, sqlDB := newLibraryRouter(t, rows)
+
+	first := serveBlueprintPage(t, mux, "sort=type_name&limit=2") // 5, 2
+	// Blueprint 2, the last of the first page, is deleted before the next
+	// request; the next page still starts right after it.
+	for _, query := range []string{`DELETE FROM jobs WHERE blueprint_id = 2`, `DELETE FROM blueprints WHERE id = 2`} {
+		if _, err := sqlDB.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	next := serveBlueprintPage(t, mux, "sort=type_name&limit=2&cursor="+*first.NextCursor)
+
+	if got := pageIDs(next); !slices.Equal(got, []int64{4, 1}) {
+		t.Errorf("second page = %v, want [4 1]", got)
+	}
+}
+
+func TestGetBlueprints_Facets(t *testing.T) {
+	rows := libraryRows(time.Now())
+
+	// Facets cover the whole library regardless of filters.
+	page := getBlueprintPage(t, rows, "owner_id=101")
+
+	wantOwners := []ownerFacetJSON{
+		{OwnerType: "character", OwnerID: 100, Name: "Alpha", Count: 2},
+		{OwnerType: "character", OwnerID: 101, Name: "bravo", Count: 1},
+		{OwnerType: "corporation", OwnerID: 200, Name: "Charlie Corp", Count: 2},
+	}
+	if !slices.Equal(page.Facets.Owners, wantOwners) {
+		t.Errorf("owner facets = %+v, want %+v", page.Facets.Owners, wantOwners)
+	}
+	wantCategories := []facetJSON{{ID: 9, Name: "Blueprint", Count: 4}, {ID: 16, Name: "Skill", Count: 1}}
+	if !slices.Equal(page.Facets.Categories, wantCategories) {
+		t.Errorf("category facets = %+v, want %+v", page.Facets.Categories, wantCategories)
+	}
+	if len(page.Facets.Regions) != 0 {
+		t.Errorf("region facets = %+v, want none for unresolved locations", page.Facets.Regions)
+	}
+}
+
+func TestGetBlueprints_InvalidQuery(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
+	cursor := encodeBlueprintCursor(blueprintCursor{Sort: "type_name", Key: blueprintKey{ID: 1}})
+
+	for _, query := range []string{
+		"owner_id=1,x",
+		"owner_id=!",
+		"owner_type=alliance",
+		"status=paused",
+		"activity=invention",
+		"sort=name",
+		"sort=me,-me",
+		"limit=0",
+		"limit=1001",
+		"limit=ten",
+		"cursor=not-a-cursor",
+		"cursor=" + cursor, // issued for another sort order
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/blueprints?"+query, http.NoBody)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+// benchmarkLibrary returns n blueprints spread over 10 owners, 3 categories
+// and 50 systems in 5 regions, a third of them with a job.
+func benchmarkLibrary(n int, now time.Time) []store.ListBlueprintsRow {
+	categories := []string{"Ship", "Module", "Charge"}
+	rows := make([]store.ListBlueprintsRow, n)
+	for i := range rows {
+		id := int64(i + 1)
+		cat := int64(i % len(categories))
+		sys := int64(i % 50)
+		row := store.ListBlueprintsRow{
+			ID:             id,
+			OwnerType:      "character",
+			OwnerID:        100 + id%10,
+			OwnerName:      fmt.Sprintf("Pilot %d", id%10),
+			TypeID:         1000 + id%400,
+			TypeName:       fmt.Sprintf("Item %d Blueprint", id%400),
+			GroupID:        10 + id%400/20,
+			GroupName:      fmt.Sprintf("Group %d", id%400/20),
+			CategoryID:     cat + 1,
+			CategoryName:   categories[cat],
+			LocationID:     60000000 + sys,
+			LocationName:   sql.NullString{String: fmt.Sprintf("Station %d", sys), Valid: true},
+			LocationPath:   sql.NullString{String: fmt.Sprintf("Corp Hangar %d", id%7+1), Valid: true},
+			SystemID:       sql.NullInt64{Int64: 30000000 + sys, Valid: true},
+			SystemName:     sql.NullString{String: fmt.Sprintf("System %d", sys), Valid: true},
+			RegionID:       sql.NullInt64{Int64: 10000000 + sys%5, Valid: true},
+			RegionName:     sql.NullString{String: fmt.Sprintf("Region %d", sys%5), Valid: true},
+			SecurityStatus: sql.NullFloat64{Float64: float64(sys%20)/10 - 1, Valid: true},
+			MeLevel:        id % 11,
+			TeLevel:        id % 11 * 2,
+		}
+		if i%3 == 0 {
+			row.JobID = sql.NullInt64{Int64: id, Valid: true}
+			row.JobActivity = sql.NullString{String: "copying", Valid: true}
+			row.JobStatus = sql.NullString{String: "active", Valid: true}
+			row.JobEndDate = sql.NullTime{Time: now.Add(time.Duration(i-n/2) * time.Minute), Valid: true}
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+func BenchmarkGetBlueprints(b *testing.B) {
+	// Keep the request log out of the benchmark output.
+	defaultLogger := middleware.DefaultLogger
+	middleware.DefaultLogger = middleware.RequestLogger(&middleware.DefaultLogFormatter{Logger: log.New(io.Discard, "", 0)})
+	b.Cleanup(func() { middleware.DefaultLogger = defaultLogger })
+	mux, _ := newLibraryRouter(b, benchmarkLibrary(5000, time.Now()))
+
+	for name, query := range map[string]string{
+		"first page": "",
+		"filtered":   "category_id=1&security=high&sort=-me,type_name&limit=50",
+		"search":     "q=" + url.QueryEscape("item 12 hangar"),
+	} {
+		b.Run(name, func(b *testing.B) {
+			for b.Loop() {
+				rr := httptest.NewRecorder()
+				mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/blueprints?"+query, http.NoBody))
+				if rr.Code != http.StatusOK {
+					b.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+				}
+			}
+		})
+	}
+}
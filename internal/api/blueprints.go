@@ -1,8 +1,11 @@
 package api
 
 import (
+	"cmp"
+	"context"
 	"net/http"
-	"strconv"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/dpleshakov/auspex/internal/store"
@@ -17,19 +20,64 @@ type jobJSON struct {
 }
 
 type blueprintJSON struct {
-	ID           int64    `json:"id"`
-	OwnerType    string   `json:"owner_type"`
-	OwnerID      int64    `json:"owner_id"`
-	OwnerName    string   `json:"owner_name"`
-	TypeID       int64    `json:"type_id"`
-	TypeName     string   `json:"type_name"`
-	CategoryID   int64    `json:"category_id"`
-	CategoryName string   `json:"category_name"`
-	LocationID   int64    `json:"location_id"`
-	LocationName *string  `json:"location_name"`
-	MeLevel      int64    `json:"me_level"`
-	TeLevel      int64    `json:"te_level"`
-	Job          *jobJSON `json:"job"`
+	ID             int64    `json:"id"`
+	OwnerType      string   `json:"owner_type"`
+	OwnerID        int64    `json:"owner_id"`
+	OwnerName      string   `json:"owner_name"`
+	TypeID         int64    `json:"type_id"`
+	TypeName       string   `json:"type_name"`
+	CategoryID     int64    `json:"category_id"`
+	CategoryName   string   `json:"category_name"`
+	LocationID     int64    `json:"location_id"`
+	LocationName   *string  `json:"location_name"`
+	SystemID       *int64   `json:"system_id"`
+	SystemName     *string  `json:"system_name"`
+	RegionID       *int64   `json:"region_id"`
+	RegionName     *string  `json:"region_name"`
+	SecurityStatus *float64 `json:"security_status"`
+	MeLevel        int64    `json:"me_level"`
+	TeLevel        int64    `json:"te_level"`
+	Job            *jobJSON `json:"job"`
+}
+
+type blueprintPageJSON struct {
+	Items      []blueprintJSON     `json:"items"`
+	Total      int64               `json:"total"`
+	NextCursor *string             `json:"next_cursor"`
+	Facets     blueprintFacetsJSON `json:"facets"`
+}
+
+type blueprintFacetsJSON struct {
+	Owners     []ownerFacetJSON `json:"owners"`
+	Categories []facetJSON      `json:"categories"`
+	Regions    []facetJSON      `json:"regions"`
+}
+
+type ownerFacetJSON struct {
+	OwnerType string `json:"owner_type"`
+	OwnerID   int64  `json:"owner_id"`
+	Name      string `json:"name"`
+	Count     int64  `json:"count"`
+}
+
+type facetJSON struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+type blueprintEventJSON struct {
+	ID          int64     `json:"id"`
+	BlueprintID int64     `json:"blueprint_id"`
+	OwnerType   string    `json:"owner_type"`
+	OwnerID     int64     `json:"owner_id"`
+	OwnerName   string    `json:"owner_name"`
+	TypeID      int64     `json:"type_id"`
+	TypeName    string    `json:"type_name"`
+	Event       string    `json:"event"`
+	OldValue    *string   `json:"old_value"`
+	NewValue    *string   `json:"new_value"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type characterSlotJSON struct {
@@ -45,73 +93,185 @@ type summaryJSON struct {
 	Characters        []characterSlotJSON `json:"characters"`
 }
 
+// securityClasses are the accepted values of the security filter of
+// GET /api/blueprints: high-sec (0.45 and above), low-sec (above 0.0) and
+// null-sec (0.0 and below), by true security status.
+var securityClasses = map[string]bool{"high": true, "low": true, "null": true}
+
 // Handles:
 //
-//	GET /api/blueprints  (query params: status, owner_id, owner_type, category_id)
+//	GET /api/blueprints  (query params: owner_type, owner_id, category_id, status, activity,
+//	                      region_id, system_id, security, q, sort, limit, cursor)
+//
+// Returns one page of the blueprints matching the filters, the number of them
+// across all pages, and facet counts over the whole library for the filter
+// choices. See blueprint_query.go for the parameter syntax.
 func (r *router) handleGetBlueprints(w http.ResponseWriter, req *http.Request) {
-	q := req.URL.Query()
+	bq, err := parseBlueprintQuery(req.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	params := store.ListBlueprintsParams{}
+	ctx := req.Context()
+	p, err := blueprintPager(r.q)
+	var page blueprintPage
+	if err == nil {
+		page, err = bq.page(ctx, p, time.Now())
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list blueprints")
+		return
+	}
+	facets, err := r.blueprintFacets(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to count blueprints")
+		return
+	}
 
-	if v := q.Get("owner_type"); v != "" {
-		params.OwnerType = v
+	resp := blueprintPageJSON{
+		Items:  make([]blueprintJSON, len(page.rows)),
+		Total:  page.total,
+		Facets: facets,
 	}
-	if v := q.Get("owner_id"); v != "" {
-		id, err := strconv.ParseInt(v, 10, 64)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, "invalid owner_id")
-			return
+	if page.nextCursor != "" {
+		resp.NextCursor = &page.nextCursor
+	}
+	for i := range page.rows {
+		resp.Items[i] = newBlueprintJSON(&page.rows[i])
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func newBlueprintJSON(row *store.ListBlueprintsRow) blueprintJSON {
+	bp := blueprintJSON{
+		ID:           row.ID,
+		OwnerType:    row.OwnerType,
+		OwnerID:      row.OwnerID,
+		OwnerName:    row.OwnerName,
+		TypeID:       row.TypeID,
+		TypeName:     row.TypeName,
+		CategoryID:   row.CategoryID,
+		CategoryName: row.CategoryName,
+		LocationID:   row.LocationID,
+		LocationName: blueprintLocationName(row),
+		MeLevel:      row.MeLevel,
+		TeLevel:      row.TeLevel,
+	}
+	// System, region and security are null until the location is resolved.
+	if row.SystemName.Valid {
+		bp.SystemID = &row.SystemID.Int64
+		bp.SystemName = &row.SystemName.String
+		bp.RegionID = &row.RegionID.Int64
+		bp.RegionName = &row.RegionName.String
+		bp.SecurityStatus = &row.SecurityStatus.Float64
+	}
+	if row.JobID.Valid {
+		bp.Job = &jobJSON{
+			ID:        row.JobID.Int64,
+			Activity:  row.JobActivity.String,
+			Status:    row.JobStatus.String,
+			StartDate: row.JobStartDate.Time,
+			EndDate:   row.JobEndDate.Time,
 		}
-		params.OwnerID = id
 	}
-	if v := q.Get("category_id"); v != "" {
-		id, err := strconv.ParseInt(v, 10, 64)
+	return bp
+}
+
+// blueprintFacets counts the whole library by owner, category and region,
+// each sorted by name ignoring case. Blueprints in unresolved locations are not
+// counted in any region.
+func (r *router) blueprintFacets(ctx context.Context) (blueprintFacetsJSON, error) {
+	owners, err := r.q.ListBlueprintOwnerCounts(ctx)
+	if err != nil {
+		return blueprintFacetsJSON{}, err
+	}
+	categories, err := r.q.ListBlueprintCategoryCounts(ctx)
+	if err != nil {
+		return blueprintFacetsJSON{}, err
+	}
+	regions, err := r.q.ListBlueprintRegionCounts(ctx)
+	if err != nil {
+		return blueprintFacetsJSON{}, err
+	}
+
+	facets := blueprintFacetsJSON{
+		Owners:     make([]ownerFacetJSON, len(owners)),
+		Categories: make([]facetJSON, len(categories)),
+		Regions:    make([]facetJSON, len(regions)),
+	}
+	for i, o := range owners {
+		facets.Owners[i] = ownerFacetJSON{OwnerType: o.OwnerType, OwnerID: o.OwnerID, Name: o.OwnerName, Count: o.Count}
+	}
+	for i, c := range categories {
+		facets.Categories[i] = facetJSON{ID: c.CategoryID, Name: c.CategoryName, Count: c.Count}
+	}
+	for i, rg := range regions {
+		facets.Regions[i] = facetJSON{ID: rg.RegionID, Name: rg.RegionName, Count: rg.Count}
+	}
+
+	slices.SortFunc(facets.Owners, func(a, b ownerFacetJSON) int {
+		return cmp.Or(compareFold(a.Name, b.Name), cmp.Compare(a.OwnerID, b.OwnerID))
+	})
+	for _, fs := range [][]facetJSON{facets.Categories, facets.Regions} {
+		slices.SortFunc(fs, func(a, b facetJSON) int {
+			return cmp.Or(compareFold(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+		})
+	}
+	return facets, nil
+}
+
+// compareFold compares a and b ignoring case.
+func compareFold(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// defaultChangesWindow is how far back GET /api/blueprints/changes looks when
+// the since parameter is omitted.
+const defaultChangesWindow = 24 * time.Hour
+
+// Handles:
+//
+//	GET /api/blueprints/changes  (query param: since, RFC 3339; default: last 24 hours)
+func (r *router) handleGetBlueprintChanges(w http.ResponseWriter, req *http.Request) {
+	since := time.Now().Add(-defaultChangesWindow)
+	if v := req.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, "invalid category_id")
+			writeError(w, http.StatusBadRequest, "invalid since")
 			return
 		}
-		params.CategoryID = id
-	}
-	if v := q.Get("status"); v != "" {
-		params.Status = v
+		since = t
 	}
 
-	rows, err := r.q.ListBlueprints(req.Context(), params)
+	rows, err := r.q.ListBlueprintEventsSince(req.Context(), since.UTC())
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to list blueprints")
+		writeError(w, http.StatusInternalServerError, "failed to list blueprint changes")
 		return
 	}
 
-	resp := make([]blueprintJSON, len(rows))
+	resp := make([]blueprintEventJSON, len(rows))
 	for i, row := range rows {
-		var locationName *string
-		if row.LocationName.Valid {
-			locationName = &row.LocationName.String
+		var oldValue, newValue *string
+		if row.OldValue.Valid {
+			oldValue = &row.OldValue.String
 		}
-		bp := blueprintJSON{
-			ID:           row.ID,
-			OwnerType:    row.OwnerType,
-			OwnerID:      row.OwnerID,
-			OwnerName:    row.OwnerName,
-			TypeID:       row.TypeID,
-			TypeName:     row.TypeName,
-			CategoryID:   row.CategoryID,
-			CategoryName: row.CategoryName,
-			LocationID:   row.LocationID,
-			LocationName: locationName,
-			MeLevel:      row.MeLevel,
-			TeLevel:      row.TeLevel,
+		if row.NewValue.Valid {
+			newValue = &row.NewValue.String
 		}
-		if row.JobID.Valid {
-			bp.Job = &jobJSON{
-				ID:        row.JobID.Int64,
-				Activity:  row.JobActivity.String,
-				Status:    row.JobStatus.String,
-				StartDate: row.JobStartDate.Time,
-				EndDate:   row.JobEndDate.Time,
-			}
+		resp[i] = blueprintEventJSON{
+			ID:          row.ID,
+			BlueprintID: row.BlueprintID,
+			OwnerType:   row.OwnerType,
+			OwnerID:     row.OwnerID,
+			OwnerName:   row.OwnerName,
+			TypeID:      row.TypeID,
+			TypeName:    row.TypeName,
+			Event:       row.Event,
+			OldValue:    oldValue,
+			NewValue:    newValue,
+			CreatedAt:   row.CreatedAt,
 		}
-		resp[i] = bp
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
@@ -20,12 +20,24 @@ func TestContract_GetBlueprints_EmptyDB(t *testing.T) {
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
-	var body []any
+	var body map[string]any
 	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
 		t.Fatalf("decode body: %v", err)
 	}
-	if len(body) != 0 {
-		t.Errorf("expected empty array, got %d items", len(body))
+	items, ok := body["items"].([]any)
+	if !ok || len(items) != 0 {
+		t.Errorf("items = %v, want empty array", body["items"])
+	}
+	assertField[float64](t, body, "total")
+	assertNull(t, body, "next_cursor")
+	facets, ok := body["facets"].(map[string]any)
+	if !ok {
+		t.Fatalf("\"facets\": want object, got %T", body["facets"])
+	}
+	for _, name := range []string{"owners", "categories", "regions"} {
+		if _, ok := facets[name].([]any); !ok {
+			t.Errorf("facets.%s: want array, got %T", name, facets[name])
+		}
 	}
 }
 
@@ -44,10 +56,11 @@ func TestContract_GetBlueprints_NoJob(t *testing.T) {
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
-	var items []map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+	var page struct{ Items []map[string]any }
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
 		t.Fatalf("decode body: %v", err)
 	}
+	items := page.Items
 	if len(items) != 1 {
 		t.Fatalf("expected 1 blueprint, got %d", len(items))
 	}
@@ -93,10 +106,11 @@ func TestContract_GetBlueprints_WithJob(t *testing.T) {
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
-	var items []map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+	var page struct{ Items []map[string]any }
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
 		t.Fatalf("decode body: %v", err)
 	}
+	items := page.Items
 	if len(items) != 1 {
 		t.Fatalf("expected 1 blueprint, got %d", len(items))
 	}
@@ -135,19 +149,116 @@ func TestContract_GetBlueprints_FilterByOwner(t *testing.T) {
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
-	var items []map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+	var page struct{ Items []map[string]any }
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
 		t.Fatalf("decode body: %v", err)
 	}
+	items := page.Items
 	if len(items) != 1 {
 		t.Errorf("expected 1 blueprint for owner 3003, got %d", len(items))
 	}
 }
 
+func TestContract_GetBlueprints_Paging(t *testing.T) {
+	sqlDB := newContractDB(t)
+	seedCharacter(t, sqlDB, 3005, "Pager", 0)
+	for i, me := range []int64{4, 10, 7} {
+		seedBlueprint(t, sqlDB, BlueprintSeed{ID: 9010 + int64(i), OwnerType: "character", OwnerID: 3005, MeLevel: me})
+	}
+	srv := newContractServer(t, sqlDB)
+
+	var got []float64
+	url := srv.URL + "/api/blueprints?sort=-me&limit=2"
+	for url != "" {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("GET /api/blueprints: %v", err)
+		}
+		var page map[string]any
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		if page["total"] != float64(3) {
+			t.Errorf("total = %v, want 3", page["total"])
+		}
+		for _, item := range page["items"].([]any) {
+			got = append(got, item.(map[string]any)["me_level"].(float64))
+		}
+		url = ""
+		if cursor, ok := page["next_cursor"].(string); ok {
+			url = srv.URL + "/api/blueprints?sort=-me&limit=2&cursor=" + cursor
+		}
+		if len(got) > 3 {
+			t.Fatal("pagination did not end")
+		}
+	}
+	if len(got) != 3 || got[0] != 10 || got[1] != 7 || got[2] != 4 {
+		t.Errorf("me levels across pages = %v, want [10 7 4]", got)
+	}
+}
+
 // TestContract_GetJobsSummary_ActiveJobPastEndDateCountedAsReady verifies that
 // a job with status="active" whose end_date has already passed is counted in
 // ready_jobs — i.e. treated as ready to collect regardless of whether ESI has
 // transitioned the status field yet.
+func TestContract_GetBlueprintChanges_Fields(t *testing.T) {
+	sqlDB := newContractDB(t)
+	seedCharacter(t, sqlDB, 3001, "Researcher", 0)
+	seedBlueprint(t, sqlDB, BlueprintSeed{ID: 9001, OwnerType: "character", OwnerID: 3001})
+	for _, ev := range []struct {
+		event    string
+		old, new any
+		at       time.Time
+	}{
+		{"me_changed", "5", "7", time.Now().UTC().Add(-time.Hour)},
+		{"removed", nil, nil, time.Now().UTC().Add(-48 * time.Hour)}, // outside the default window
+	} {
+		if _, err := sqlDB.Exec(
+			`INSERT INTO blueprint_events (blueprint_id, owner_type, owner_id, type_id, event, old_value, new_value, created_at)
+			 VALUES (9001, 'character', 3001, 1, ?, ?, ?, ?)`,
+			ev.event, ev.old, ev.new, ev.at,
+		); err != nil {
+			t.Fatalf("seeding blueprint event %s: %v", ev.event, err)
+		}
+	}
+	srv := newContractServer(t, sqlDB)
+
+	resp, err := http.Get(srv.URL + "/api/blueprints/changes")
+	if err != nil {
+		t.Fatalf("GET /api/blueprints/changes: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var items []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("expected 1 event within the default window, got %d", len(items))
+	}
+	ev := items[0]
+
+	assertField[float64](t, ev, "id")
+	assertField[float64](t, ev, "blueprint_id")
+	assertField[string](t, ev, "owner_type")
+	assertField[float64](t, ev, "owner_id")
+	assertField[string](t, ev, "owner_name")
+	assertField[float64](t, ev, "type_id")
+	assertField[string](t, ev, "type_name")
+	assertField[string](t, ev, "event")
+	assertField[string](t, ev, "old_value")
+	assertField[string](t, ev, "new_value")
+	assertField[string](t, ev, "created_at")
+	if ev["owner_name"] != "Researcher" {
+		t.Errorf("owner_name = %v, want Researcher", ev["owner_name"])
+	}
+}
+
 func TestContract_GetJobsSummary_ActiveJobPastEndDateCountedAsReady(t *testing.T) {
 	sqlDB := newContractDB(t)
 	seedCharacter(t, sqlDB, 5001, "Tester", 0)
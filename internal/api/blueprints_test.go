@@ -7,6 +7,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 	"time"
 
@@ -16,7 +17,7 @@ import (
 // --- GET /api/blueprints ---
 
 func TestGetBlueprints_Empty(t *testing.T) {
-	mux := NewRouter(&mockQuerier{}, nil, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/blueprints", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -25,12 +26,18 @@ func TestGetBlueprints_Empty(t *testing.T) {
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
 	}
-	var got []any
+	var got map[string]any
 	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
-	if len(got) != 0 {
-		t.Fatalf("expected empty array, got %d items", len(got))
+	if items, ok := got["items"].([]any); !ok || len(items) != 0 {
+		t.Fatalf("items = %v, want empty array", got["items"])
+	}
+	if got["total"] != float64(0) {
+		t.Errorf("total = %v, want 0", got["total"])
+	}
+	if got["next_cursor"] != nil {
+		t.Errorf("next_cursor = %v, want null", got["next_cursor"])
 	}
 }
 
@@ -47,7 +54,7 @@ func TestGetBlueprints_NullJobWhenNoJob(t *testing.T) {
 				},
 			}, nil
 		},
-	}, nil, nil, testFS())
+	}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/blueprints", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -56,10 +63,11 @@ func TestGetBlueprints_NullJobWhenNoJob(t *testing.T) {
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
 	}
-	var got []map[string]any
-	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+	var page struct{ Items []map[string]any }
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
+	got := page.Items
 	if len(got) != 1 {
 		t.Fatalf("expected 1 blueprint, got %d", len(got))
 	}
@@ -88,7 +96,7 @@ func TestGetBlueprints_NonNullJobWhenJobExists(t *testing.T) {
 				},
 			}, nil
 		},
-	}, nil, nil, testFS())
+	}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/blueprints", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -97,10 +105,11 @@ func TestGetBlueprints_NonNullJobWhenJobExists(t *testing.T) {
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
 	}
-	var got []map[string]any
-	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+	var page struct{ Items []map[string]any }
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
+	got := page.Items
 	if len(got) != 1 {
 		t.Fatalf("expected 1 blueprint, got %d", len(got))
 	}
@@ -120,50 +129,222 @@ func TestGetBlueprints_NonNullJobWhenJobExists(t *testing.T) {
 	}
 }
 
+// getBlueprintPage serves GET /api/blueprints?query over a database holding
+// rows and decodes the page, failing the test unless the response is 200.
+func getBlueprintPage(t *testing.T, rows []store.ListBlueprintsRow, query string) blueprintPageJSON {
+	t.Helper()
+	mux, _ := newLibraryRouter(t, rows)
+	return serveBlueprintPage(t, mux, query)
+}
+
+// serveBlueprintPage serves GET /api/blueprints?query from mux and decodes
+// the page, failing the test unless the response is 200.
+func serveBlueprintPage(t *testing.T, mux http.Handler, query string) blueprintPageJSON {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/blueprints?"+query, http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("%s: expected 200, got %d: %s", query, rr.Code, rr.Body)
+	}
+	var page blueprintPageJSON
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("%s: decode: %v", query, err)
+	}
+	return page
+}
+
+// pageIDs returns the blueprint IDs of page in order.
+func pageIDs(page blueprintPageJSON) []int64 {
+	ids := make([]int64, len(page.Items))
+	for i, bp := range page.Items {
+		ids[i] = bp.ID
+	}
+	return ids
+}
+
 func TestGetBlueprints_FilterOwnerType(t *testing.T) {
-	var captured store.ListBlueprintsParams
+	rows := []store.ListBlueprintsRow{
+		{ID: 1, OwnerType: "character", OwnerID: 100},
+		{ID: 2, OwnerType: "corporation", OwnerID: 200},
+	}
+
+	page := getBlueprintPage(t, rows, "owner_type=corporation")
+	if got := pageIDs(page); !slices.Equal(got, []int64{2}) {
+		t.Errorf("blueprints = %v, want [2]", got)
+	}
+}
+
+func TestGetBlueprints_FilterStatus(t *testing.T) {
+	now := time.Now()
+	job := func(id int64, status string, end time.Time) store.ListBlueprintsRow {
+		return store.ListBlueprintsRow{
+			ID:          id,
+			JobID:       sql.NullInt64{Int64: id, Valid: true},
+			JobActivity: sql.NullString{String: "copying", Valid: true},
+			JobStatus:   sql.NullString{String: status, Valid: true},
+			JobEndDate:  sql.NullTime{Time: end, Valid: true},
+		}
+	}
+	rows := []store.ListBlueprintsRow{
+		{ID: 1},
+		job(2, "active", now.Add(time.Hour)),
+		job(3, "ready", now.Add(-time.Hour)),
+		job(4, "active", now.Add(-time.Minute)), // ended, ESI not updated yet
+	}
+
+	for query, want := range map[string][]int64{
+		"status=idle":   {1},
+		"status=active": {2},
+		"status=ready":  {3, 4},
+	} {
+		page := getBlueprintPage(t, rows, query+"&sort=-status")
+		if got := pageIDs(page); !slices.Equal(got, want) {
+			t.Errorf("%s: blueprints = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestGetBlueprints_FilterLocation(t *testing.T) {
+	resolved := func(id, systemID, regionID int64, security float64) store.ListBlueprintsRow {
+		return store.ListBlueprintsRow{
+			ID:             id,
+			SystemID:       sql.NullInt64{Int64: systemID, Valid: true},
+			SystemName:     sql.NullString{String: "System", Valid: true},
+			RegionID:       sql.NullInt64{Int64: regionID, Valid: true},
+			RegionName:     sql.NullString{String: "Region", Valid: true},
+			SecurityStatus: sql.NullFloat64{Float64: security, Valid: true},
+		}
+	}
+	rows := []store.ListBlueprintsRow{
+		resolved(1, 30000142, 10000002, 0.9459), // Jita
+		resolved(2, 30000144, 10000002, 0.46),   // Perimeter
+		resolved(3, 30002053, 10000042, 0.3),    // low-sec
+		resolved(4, 30004759, 10000060, -0.1),   // null-sec
+		{ID: 5},                                 // not yet resolved
+	}
+
+	for query, want := range map[string][]int64{
+		"region_id=10000002&system_id=30000142&security=high": {1},
+		"region_id=10000002": {1, 2},
+		"security=low":       {3},
+		"security=null":      {4},
+	} {
+		page := getBlueprintPage(t, rows, query+"&sort=type_name")
+		if got := pageIDs(page); !slices.Equal(got, want) {
+			t.Errorf("%s: blueprints = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestGetBlueprints_InvalidLocationFilters(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
+
+	for _, query := range []string{"region_id=forge", "system_id=jita", "security=wormhole"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/blueprints?"+query, http.NoBody)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestGetBlueprints_LocationMetadata(t *testing.T) {
 	mux := NewRouter(&mockQuerier{
-		ListBlueprintsFn: func(_ context.Context, arg store.ListBlueprintsParams) ([]store.ListBlueprintsRow, error) {
-			captured = arg
-			return nil, nil
+		ListBlueprintsFn: func(_ context.Context, _ store.ListBlueprintsParams) ([]store.ListBlueprintsRow, error) {
+			return []store.ListBlueprintsRow{
+				{
+					ID: 1, LocationID: 60003760,
+					LocationName:   sql.NullString{String: "Jita IV - Moon 4 - Caldari Navy Assembly Plant", Valid: true},
+					SystemID:       sql.NullInt64{Int64: 30000142, Valid: true},
+					SystemName:     sql.NullString{String: "Jita", Valid: true},
+					RegionID:       sql.NullInt64{Int64: 10000002, Valid: true},
+					RegionName:     sql.NullString{String: "The Forge", Valid: true},
+					SecurityStatus: sql.NullFloat64{Float64: 0.9459, Valid: true},
+				},
+				{ID: 2, LocationID: 1_000_000_000_001}, // not yet resolved
+			}, nil
 		},
-	}, nil, nil, testFS())
+	}, nil, nil, nil, testFS())
 
-	req := httptest.NewRequest(http.MethodGet, "/api/blueprints?owner_type=corporation", http.NoBody)
+	req := httptest.NewRequest(http.MethodGet, "/api/blueprints", http.NoBody)
 	rr := httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
 	}
-	if captured.OwnerType != "corporation" {
-		t.Errorf("OwnerType = %v, want corporation", captured.OwnerType)
+	var page struct{ Items []map[string]any }
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got := page.Items
+	if len(got) != 2 {
+		t.Fatalf("expected 2 blueprints, got %d", len(got))
+	}
+	want := map[string]any{
+		"system_id":       float64(30000142),
+		"system_name":     "Jita",
+		"region_id":       float64(10000002),
+		"region_name":     "The Forge",
+		"security_status": 0.9459,
+	}
+	for field, v := range want {
+		if got[0][field] != v {
+			t.Errorf("resolved %s = %v, want %v", field, got[0][field], v)
+		}
+		if got[1][field] != nil {
+			t.Errorf("unresolved %s = %v, want null", field, got[1][field])
+		}
 	}
 }
 
-func TestGetBlueprints_FilterStatus(t *testing.T) {
-	var captured store.ListBlueprintsParams
+func TestGetBlueprints_LocationPath(t *testing.T) {
 	mux := NewRouter(&mockQuerier{
-		ListBlueprintsFn: func(_ context.Context, arg store.ListBlueprintsParams) ([]store.ListBlueprintsRow, error) {
-			captured = arg
-			return nil, nil
+		ListBlueprintsFn: func(_ context.Context, _ store.ListBlueprintsParams) ([]store.ListBlueprintsRow, error) {
+			return []store.ListBlueprintsRow{
+				{
+					ID: 1, LocationID: 1_052_718_900_001,
+					LocationName: sql.NullString{String: "Jita IV - Moon 4 - Caldari Navy Assembly Plant", Valid: true},
+					LocationPath: sql.NullString{String: "Corp Hangar 3 (Research BPOs) › Station Container 'T2 BPOs'", Valid: true},
+				},
+				{
+					ID: 2, LocationID: 60003760,
+					LocationName: sql.NullString{String: "Jita IV - Moon 4 - Caldari Navy Assembly Plant", Valid: true},
+					LocationPath: sql.NullString{String: "", Valid: true},
+				},
+			}, nil
 		},
-	}, nil, nil, testFS())
+	}, nil, nil, nil, testFS())
 
-	req := httptest.NewRequest(http.MethodGet, "/api/blueprints?status=idle", http.NoBody)
+	req := httptest.NewRequest(http.MethodGet, "/api/blueprints", http.NoBody)
 	rr := httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusOK {
-		t.Fatalf("expected 200, got %d", rr.Code)
+	var page blueprintPageJSON
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("decode: %v", err)
 	}
-	if captured.Status != "idle" {
-		t.Errorf("Status = %v, want idle", captured.Status)
+	got := page.Items
+	want := []string{
+		"Jita IV - Moon 4 - Caldari Navy Assembly Plant › Corp Hangar 3 (Research BPOs) › Station Container 'T2 BPOs'",
+		"Jita IV - Moon 4 - Caldari Navy Assembly Plant",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d blueprints, got %d", len(want), len(got))
+	}
+	for i, bp := range got {
+		if bp.LocationName == nil || *bp.LocationName != want[i] {
+			t.Errorf("blueprint %d location_name = %v, want %q", bp.ID, bp.LocationName, want[i])
+		}
 	}
 }
 
 func TestGetBlueprints_InvalidOwnerID(t *testing.T) {
-	mux := NewRouter(&mockQuerier{}, nil, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/blueprints?owner_id=notanumber", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -175,7 +356,7 @@ func TestGetBlueprints_InvalidOwnerID(t *testing.T) {
 }
 
 func TestGetBlueprints_InvalidCategoryID(t *testing.T) {
-	mux := NewRouter(&mockQuerier{}, nil, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/blueprints?category_id=xyz", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -191,7 +372,7 @@ func TestGetBlueprints_DBError(t *testing.T) {
 		ListBlueprintsFn: func(_ context.Context, _ store.ListBlueprintsParams) ([]store.ListBlueprintsRow, error) {
 			return nil, errors.New("db error")
 		},
-	}, nil, nil, testFS())
+	}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/blueprints", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -202,6 +383,106 @@ func TestGetBlueprints_DBError(t *testing.T) {
 	}
 }
 
+// --- GET /api/blueprints/changes ---
+
+func TestGetBlueprintChanges_DefaultsToLast24Hours(t *testing.T) {
+	var captured time.Time
+	mux := NewRouter(&mockQuerier{
+		ListBlueprintEventsSinceFn: func(_ context.Context, since time.Time) ([]store.ListBlueprintEventsSinceRow, error) {
+			captured = since
+			return nil, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/blueprints/changes", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if d := time.Since(captured); d < 24*time.Hour || d > 24*time.Hour+time.Minute {
+		t.Errorf("since = %v, want about 24h ago", captured)
+	}
+	var got []any
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Fatalf("expected empty array, got %v", got)
+	}
+}
+
+func TestGetBlueprintChanges_SinceAndNullValues(t *testing.T) {
+	var captured time.Time
+	mux := NewRouter(&mockQuerier{
+		ListBlueprintEventsSinceFn: func(_ context.Context, since time.Time) ([]store.ListBlueprintEventsSinceRow, error) {
+			captured = since
+			return []store.ListBlueprintEventsSinceRow{
+				{
+					ID: 2, BlueprintID: 1001, OwnerType: "character", OwnerID: 100,
+					Event:    "me_changed",
+					OldValue: sql.NullString{String: "5", Valid: true},
+					NewValue: sql.NullString{String: "7", Valid: true},
+				},
+				{ID: 1, BlueprintID: 1002, OwnerType: "character", OwnerID: 100, Event: "removed"},
+			}, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/blueprints/changes?since=2026-10-01T12:00:00%2B02:00", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if want := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC); !captured.Equal(want) || captured.Location() != time.UTC {
+		t.Errorf("since = %v, want %v (UTC)", captured, want)
+	}
+	var got []map[string]any
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(got))
+	}
+	if got[0]["old_value"] != "5" || got[0]["new_value"] != "7" {
+		t.Errorf("me_changed values: got %v → %v, want 5 → 7", got[0]["old_value"], got[0]["new_value"])
+	}
+	if got[1]["old_value"] != nil || got[1]["new_value"] != nil {
+		t.Errorf("removed values: expected nulls, got %v → %v", got[1]["old_value"], got[1]["new_value"])
+	}
+}
+
+func TestGetBlueprintChanges_InvalidSince(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/blueprints/changes?since=yesterday", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestGetBlueprintChanges_DBError(t *testing.T) {
+	mux := NewRouter(&mockQuerier{
+		ListBlueprintEventsSinceFn: func(_ context.Context, _ time.Time) ([]store.ListBlueprintEventsSinceRow, error) {
+			return nil, errors.New("db error")
+		},
+	}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/blueprints/changes", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", rr.Code)
+	}
+}
+
 // --- GET /api/jobs/summary ---
 
 func TestGetJobsSummary_Counts(t *testing.T) {
@@ -209,7 +490,7 @@ func TestGetJobsSummary_Counts(t *testing.T) {
 		CountIdleBlueprintsFn:    func(_ context.Context) (int64, error) { return 5, nil },
 		CountReadyJobsFn:         func(_ context.Context) (int64, error) { return 3, nil },
 		ListCharacterSlotUsageFn: func(_ context.Context) ([]store.ListCharacterSlotUsageRow, error) { return nil, nil },
-	}, nil, nil, testFS())
+	}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/jobs/summary", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -238,7 +519,7 @@ func TestGetJobsSummary_IncludesCharacters(t *testing.T) {
 				{ID: 2, Name: "Bob", UsedSlots: 0},
 			}, nil
 		},
-	}, nil, nil, testFS())
+	}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/jobs/summary", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -267,7 +548,7 @@ func TestGetJobsSummary_DBErrorOnIdle(t *testing.T) {
 		CountIdleBlueprintsFn: func(_ context.Context) (int64, error) {
 			return 0, errors.New("db error")
 		},
-	}, nil, nil, testFS())
+	}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/jobs/summary", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -283,7 +564,7 @@ func TestGetJobsSummary_DBErrorOnSlotUsage(t *testing.T) {
 		ListCharacterSlotUsageFn: func(_ context.Context) ([]store.ListCharacterSlotUsageRow, error) {
 			return nil, errors.New("db error")
 		},
-	}, nil, nil, testFS())
+	}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/jobs/summary", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -0,0 +1,333 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// GET /calendar.ics serves an iCalendar (RFC 5545) feed of job completions for
+// calendar apps to subscribe to: one event per undelivered job at its end
+// date. Feeds are created through /api/calendar/feeds, each with a secret
+// token that is the only credential of the feed URL, and its own blueprint
+// filters.
+
+const (
+	// defaultCalendarFeedName names a feed created without a name.
+	defaultCalendarFeedName = "Auspex jobs"
+
+	// calendarRefreshInterval is the polling interval suggested to calendar
+	// apps. Most apps poll far less often whatever the feed asks for.
+	calendarRefreshInterval = "PT15M"
+)
+
+// calendarFilters are the GET /api/blueprints parameters a feed may be
+// filtered by. sort, limit and cursor do not apply to a feed.
+var calendarFilters = map[string]bool{
+	"owner_type": true, "owner_id": true, "category_id": true, "status": true, "activity": true,
+	"region_id": true, "system_id": true, "security": true, "q": true,
+}
+
+type calendarFeedJSON struct {
+	ID        int64             `json:"id"`
+	Name      string            `json:"name"`
+	Filters   map[string]string `json:"filters"`
+	URL       string            `json:"url"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type createCalendarFeedRequest struct {
+	Name    string            `json:"name"`
+	Filters map[string]string `json:"filters"`
+}
+
+// Handles:
+//
+//	GET    /api/calendar/feeds
+//	POST   /api/calendar/feeds
+//	DELETE /api/calendar/feeds/{id}
+//	GET    /calendar.ics  (query param: token)
+func (r *router) handleGetCalendarFeeds(w http.ResponseWriter, req *http.Request) {
+	feeds, err := r.q.ListCalendarFeeds(req.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list calendar feeds")
+		return
+	}
+	resp := make([]calendarFeedJSON, len(feeds))
+	for i, f := range feeds {
+		resp[i] = newCalendarFeedJSON(req, f)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *router) handleCreateCalendarFeed(w http.ResponseWriter, req *http.Request) {
+	var body createCalendarFeedRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		name = defaultCalendarFeedName
+	}
+
+	filters := make(url.Values, len(body.Filters))
+	for _, k := range sortedKeys(body.Filters) {
+		if !calendarFilters[k] {
+			writeError(w, http.StatusBadRequest, "unknown filter "+k)
+			return
+		}
+		if v := body.Filters[k]; v != "" {
+			filters.Set(k, v)
+		}
+	}
+	if _, err := parseBlueprintQuery(filters); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	feed, err := r.q.CreateCalendarFeed(req.Context(), store.CreateCalendarFeedParams{
+		Name:      name,
+		Token:     rand.Text(),
+		Filters:   filters.Encode(),
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create calendar feed")
+		return
+	}
+	writeJSON(w, http.StatusCreated, newCalendarFeedJSON(req, feed))
+}
+
+func (r *router) handleDeleteCalendarFeed(w http.ResponseWriter, req *http.Request) {
+	id, err := parseID(req, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid calendar feed id")
+		return
+	}
+	n, err := r.q.DeleteCalendarFeed(req.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete calendar feed")
+		return
+	}
+	if n == 0 {
+		writeError(w, http.StatusNotFound, "calendar feed not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *router) handleGetCalendar(w http.ResponseWriter, req *http.Request) {
+	token := req.URL.Query().Get("token")
+	if token == "" {
+		writeError(w, http.StatusNotFound, "calendar feed not found")
+		return
+	}
+	ctx := req.Context()
+	feed, err := r.q.GetCalendarFeedByToken(ctx, token)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "calendar feed not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get calendar feed")
+		return
+	}
+
+	filters, err := url.ParseQuery(feed.Filters)
+	var bq *blueprintQuery
+	if err == nil {
+		bq, err = parseBlueprintQuery(filters)
+	}
+	if err != nil {
+		log.Printf("api: calendar feed %d: invalid filters %q: %v", feed.ID, feed.Filters, err)
+		writeError(w, http.StatusInternalServerError, "invalid calendar feed filters")
+		return
+	}
+
+	p, err := blueprintPager(r.q)
+	var rows []store.ListBlueprintsRow
+	if err == nil {
+		rows, err = bq.all(ctx, p, time.Now(), true)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list blueprints")
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/calendar; charset=utf-8")
+	h.Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(jobCalendar(feed.Name, rows, time.Now()))
+}
+
+func newCalendarFeedJSON(req *http.Request, f store.CalendarFeed) calendarFeedJSON {
+	filters := map[string]string{}
+	values, _ := url.ParseQuery(f.Filters) // written by handleCreateCalendarFeed
+	for k := range values {
+		filters[k] = values.Get(k)
+	}
+	return calendarFeedJSON{
+		ID:        f.ID,
+		Name:      f.Name,
+		Filters:   filters,
+		URL:       calendarFeedURL(req, f.Token),
+		CreatedAt: f.CreatedAt,
+	}
+}
+
+// calendarFeedURL returns the absolute URL of the feed with token, on the host
+// the request was made to. Behind a TLS-terminating proxy the scheme is taken
+// from X-Forwarded-Proto.
+func calendarFeedURL(req *http.Request, token string) string {
+	scheme := "http"
+	if req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: req.Host, Path: "/calendar.ics", RawQuery: url.Values{"token": {token}}.Encode()}
+	return u.String()
+}
+
+// jobCalendar returns the iCalendar feed named name of the jobs on rows, in
+// order. Rows without a job are skipped.
+//
+// The UID of an event is derived from the job ID alone, so that calendar apps
+// update an event in place when the feed changes. Events are timed in UTC and
+// have no duration; each carries an alarm at its start.
+func jobCalendar(name string, rows []store.ListBlueprintsRow, now time.Time) []byte {
+	var c icalWriter
+	stamp := now.UTC().Format(icalTimeFormat)
+
+	c.line("BEGIN", "VCALENDAR")
+	c.line("VERSION", "2.0")
+	c.line("PRODID", "-//Auspex//Auspex//EN")
+	c.line("CALSCALE", "GREGORIAN")
+	c.line("METHOD", "PUBLISH")
+	c.line("NAME", icalText(name))
+	c.line("X-WR-CALNAME", icalText(name))
+	c.line("REFRESH-INTERVAL;VALUE=DURATION", calendarRefreshInterval)
+	c.line("X-PUBLISHED-TTL", calendarRefreshInterval)
+
+	for i := range rows {
+		row := &rows[i]
+		if !row.JobID.Valid {
+			continue
+		}
+		summary := jobSummary(row)
+
+		c.line("BEGIN", "VEVENT")
+		c.line("UID", fmt.Sprintf("job-%d@auspex", row.JobID.Int64))
+		c.line("DTSTAMP", stamp)
+		c.line("DTSTART", row.JobEndDate.Time.UTC().Format(icalTimeFormat))
+		c.line("SUMMARY", icalText(summary))
+		if row.LocationName.Valid {
+			c.line("LOCATION", icalText(row.LocationName.String))
+		}
+		c.line("DESCRIPTION", icalText(jobDescription(row)))
+		c.line("TRANSP", "TRANSPARENT")
+		c.line("BEGIN", "VALARM")
+		c.line("ACTION", "DISPLAY")
+		c.line("DESCRIPTION", icalText(summary))
+		c.line("TRIGGER", "PT0S")
+		c.line("END", "VALARM")
+		c.line("END", "VEVENT")
+	}
+
+	c.line("END", "VCALENDAR")
+	return c.buf.Bytes()
+}
+
+// jobSummary returns the event title of the job on row, e.g.
+// "Drake Blueprint — Copying (Installer Name)".
+func jobSummary(row *store.ListBlueprintsRow) string {
+	s := row.TypeName + " — " + events.ActivityLabel(row.JobActivity.String)
+	if row.JobInstallerName.Valid && row.JobInstallerName.String != "" {
+		s += " (" + row.JobInstallerName.String + ")"
+	}
+	return s
+}
+
+// jobDescription returns the event description of the job on row: the owner,
+// the blueprint levels and where in the station it is.
+func jobDescription(row *store.ListBlueprintsRow) string {
+	lines := []string{
+		"Owner: " + row.OwnerName,
+		fmt.Sprintf("ME %d / TE %d", row.MeLevel, row.TeLevel),
+	}
+	if name := blueprintLocationName(row); name != nil {
+		lines = append(lines, "Location: "+*name)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// icalTimeFormat is the iCalendar DATE-TIME form in UTC.
+const icalTimeFormat = "20060102T150405Z"
+
+// icalWriter builds iCalendar content lines: CRLF-terminated and folded at 75
+// octets without splitting a UTF-8 sequence.
+type icalWriter struct {
+	buf bytes.Buffer
+}
+
+const icalLineOctets = 75
+
+// line writes the content line name:value. value must already be escaped.
+func (c *icalWriter) line(name, value string) {
+	s := name + ":" + value
+	n := 0 // octets on the current physical line
+	for len(s) > 0 {
+		_, size := utf8.DecodeRuneInString(s)
+		if n+size > icalLineOctets {
+			c.buf.WriteString("\r\n ")
+			n = 1
+		}
+		c.buf.WriteString(s[:size])
+		n += size
+		s = s[size:]
+	}
+	c.buf.WriteString("\r\n")
+}
+
+// icalText escapes s as an iCalendar TEXT value. Line breaks become \n and
+// other control characters, which TEXT cannot hold, are dropped.
+func icalText(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ReplaceAll(s, "\r\n", "\n") {
+		switch {
+		case r == '\\' || r == ';' || r == ',':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r < 0x20 || r == 0x7f:
+			// dropped
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
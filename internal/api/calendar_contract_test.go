@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContract_CalendarFeed_Lifecycle(t *testing.T) {
+	sqlDB := newContractDB(t)
+	seedCharacter(t, sqlDB, 3001, "Builder", 0)
+	seedCharacter(t, sqlDB, 3002, "Other", 0)
+	seedBlueprint(t, sqlDB, BlueprintSeed{ID: 9001, OwnerID: 3001, TypeID: 11})
+	seedBlueprint(t, sqlDB, BlueprintSeed{ID: 9002, OwnerID: 3002, TypeID: 12})
+	end := time.Date(2026, 10, 20, 18, 30, 0, 0, time.UTC)
+	seedJob(t, sqlDB, JobSeed{ID: 7001, BlueprintID: 9001, OwnerID: 3001, InstallerID: 3001, Activity: "copying", EndDate: end})
+	seedJob(t, sqlDB, JobSeed{ID: 7002, BlueprintID: 9002, OwnerID: 3002, InstallerID: 3002})
+	srv := newContractServer(t, sqlDB)
+
+	resp, err := http.Post(srv.URL+"/api/calendar/feeds", "application/json",
+		strings.NewReader(`{"name":"Builder","filters":{"owner_id":"3001"}}`))
+	if err != nil {
+		t.Fatalf("POST /api/calendar/feeds: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	var feed map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	assertField[float64](t, feed, "id")
+	assertField[string](t, feed, "name")
+	assertField[map[string]any](t, feed, "filters")
+	assertField[string](t, feed, "url")
+	assertField[string](t, feed, "created_at")
+
+	ics, err := http.Get(feed["url"].(string))
+	if err != nil {
+		t.Fatalf("GET /calendar.ics: %v", err)
+	}
+	defer func() { _ = ics.Body.Close() }()
+	if ics.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", ics.StatusCode)
+	}
+	body, _ := io.ReadAll(ics.Body)
+	events := calendarEvents(unfoldCalendar(t, string(body)))
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want the one job of character 3001:\n%s", len(events), body)
+	}
+	if events[0]["UID"] != "job-7001@auspex" || events[0]["DTSTART"] != "20261020T183000Z" {
+		t.Errorf("event = %v", events[0])
+	}
+	if events[0]["SUMMARY"] != "Type — Copying (Builder)" {
+		t.Errorf("SUMMARY = %q", events[0]["SUMMARY"])
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/api/calendar/feeds/"+jsonNumber(feed["id"]), http.NoBody)
+	del, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE /api/calendar/feeds: %v", err)
+	}
+	_ = del.Body.Close()
+	if del.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", del.StatusCode)
+	}
+
+	gone, err := http.Get(feed["url"].(string))
+	if err != nil {
+		t.Fatalf("GET /calendar.ics: %v", err)
+	}
+	_ = gone.Body.Close()
+	if gone.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted feed: expected 404, got %d", gone.StatusCode)
+	}
+}
+
+// jsonNumber formats a number decoded from JSON as an integer.
+func jsonNumber(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,303 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// calendarRows returns libraryRows with distinct job IDs and installers.
+func calendarRows(now time.Time) []store.ListBlueprintsRow {
+	rows := libraryRows(now)
+	for i := range rows {
+		if rows[i].JobID.Valid {
+			rows[i].JobID.Int64 = 500 + rows[i].ID
+			rows[i].JobInstallerName = sql.NullString{String: "Installer " + rows[i].OwnerName, Valid: true}
+		}
+	}
+	rows[3].LocationName = sql.NullString{String: "Jita IV - Moon 4 - Caldari Navy Assembly Plant", Valid: true}
+	rows[3].LocationPath = sql.NullString{String: "Corp Hangar 2 (Research)", Valid: true}
+	rows[3].JobActivity.String = "me_research"
+	return rows
+}
+
+// getCalendar requests /calendar.ics?token=token from a router over rows with
+// one feed, "secret", filtered by filters.
+func getCalendar(t *testing.T, rows []store.ListBlueprintsRow, filters, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	mux, sqlDB := newLibraryRouter(t, rows)
+	if _, err := store.New(sqlDB).CreateCalendarFeed(context.Background(), store.CreateCalendarFeedParams{
+		Name: "Alpha, jobs", Token: "secret", Filters: filters, CreatedAt: time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("creating feed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/calendar.ics?token="+token, http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+// unfoldCalendar splits an iCalendar body into unfolded content lines.
+func unfoldCalendar(t *testing.T, body string) []string {
+	t.Helper()
+	if !strings.HasSuffix(body, "\r\n") {
+		t.Fatalf("body does not end with CRLF")
+	}
+	physical := strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n")
+	var lines []string
+	for _, l := range physical {
+		if len(l) > icalLineOctets {
+			t.Errorf("line longer than %d octets: %q", icalLineOctets, l)
+		}
+		if strings.ContainsAny(l, "\r\n") {
+			t.Errorf("bare line break in %q", l)
+		}
+		if rest, ok := strings.CutPrefix(l, " "); ok && len(lines) > 0 {
+			lines[len(lines)-1] += rest
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// calendarEvents returns the properties of each VEVENT in lines, keyed by
+// name without parameters. VALARM properties are left out.
+func calendarEvents(lines []string) []map[string]string {
+	var events []map[string]string
+	var ev map[string]string
+	inAlarm := false
+	for _, l := range lines {
+		name, value, _ := strings.Cut(l, ":")
+		switch {
+		case l == "BEGIN:VEVENT":
+			ev = map[string]string{}
+		case l == "END:VEVENT":
+			events = append(events, ev)
+			ev = nil
+		case l == "BEGIN:VALARM":
+			inAlarm = true
+		case l == "END:VALARM":
+			inAlarm = false
+		case ev != nil && !inAlarm:
+			name, _, _ = strings.Cut(name, ";")
+			ev[name] = value
+		}
+	}
+	return events
+}
+
+func TestGetCalendar_Events(t *testing.T) {
+	now := time.Now()
+	rows := calendarRows(now)
+	rr := getCalendar(t, rows, "", "secret")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	lines := unfoldCalendar(t, rr.Body.String())
+	if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+		t.Errorf("calendar is not wrapped in VCALENDAR: %q … %q", lines[0], lines[len(lines)-1])
+	}
+	if !strings.Contains(rr.Body.String(), "\r\nX-WR-CALNAME:Alpha\\, jobs\r\n") {
+		t.Errorf("calendar name not escaped:\n%s", rr.Body)
+	}
+
+	events := calendarEvents(lines)
+	var uids []string
+	for _, ev := range events {
+		uids = append(uids, ev["UID"])
+	}
+	// Every undelivered job, ready first, then by end date.
+	if want := []string{"job-503@auspex", "job-504@auspex", "job-502@auspex"}; strings.Join(uids, " ") != strings.Join(want, " ") {
+		t.Fatalf("UIDs = %v, want %v", uids, want)
+	}
+
+	ev := events[1]
+	if want := "Drake Blueprint — ME Research (Installer Charlie Corp)"; ev["SUMMARY"] != want {
+		t.Errorf("SUMMARY = %q, want %q", ev["SUMMARY"], want)
+	}
+	if want := rows[3].JobEndDate.Time.UTC().Format("20060102T150405Z"); ev["DTSTART"] != want {
+		t.Errorf("DTSTART = %q, want %q", ev["DTSTART"], want)
+	}
+	if want := "Jita IV - Moon 4 - Caldari Navy Assembly Plant"; ev["LOCATION"] != want {
+		t.Errorf("LOCATION = %q, want %q", ev["LOCATION"], want)
+	}
+	if want := `Owner: Charlie Corp\nME 0 / TE 0\nLocation: Jita IV - Moon 4 - Caldari Navy Assembly Plant › Corp Hangar 2 (Research)`; ev["DESCRIPTION"] != want {
+		t.Errorf("DESCRIPTION = %q, want %q", ev["DESCRIPTION"], want)
+	}
+	if _, ok := events[0]["LOCATION"]; ok {
+		t.Errorf("unresolved location: LOCATION = %q, want none", events[0]["LOCATION"])
+	}
+}
+
+func TestGetCalendar_UIDsStableAcrossRequests(t *testing.T) {
+	now := time.Now()
+	first := calendarEvents(unfoldCalendar(t, getCalendar(t, calendarRows(now), "", "secret").Body.String()))
+
+	// The job on blueprint 2 finished and was delivered; the others remain.
+	rows := calendarRows(now)
+	rows[1].JobID = sql.NullInt64{}
+	second := calendarEvents(unfoldCalendar(t, getCalendar(t, rows, "", "secret").Body.String()))
+
+	uids := map[string]string{}
+	for _, ev := range first {
+		uids[ev["UID"]] = ev["SUMMARY"]
+	}
+	if len(second) != len(first)-1 {
+		t.Fatalf("got %d events after delivery, want %d", len(second), len(first)-1)
+	}
+	for _, ev := range second {
+		if summary, ok := uids[ev["UID"]]; !ok || summary != ev["SUMMARY"] {
+			t.Errorf("event %s (%q) not in the first response", ev["UID"], ev["SUMMARY"])
+		}
+	}
+}
+
+func TestGetCalendar_FeedFilters(t *testing.T) {
+	rr := getCalendar(t, calendarRows(time.Now()), "owner_type=corporation&activity=copying", "secret")
+	events := calendarEvents(unfoldCalendar(t, rr.Body.String()))
+	if len(events) != 1 || events[0]["UID"] != "job-503@auspex" {
+		t.Errorf("events = %v, want only job-503", events)
+	}
+}
+
+func TestGetCalendar_UnknownToken(t *testing.T) {
+	for _, token := range []string{"", "wrong"} {
+		rr := getCalendar(t, calendarRows(time.Now()), "", token)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("token %q: expected 404, got %d", token, rr.Code)
+		}
+	}
+}
+
+func TestCreateCalendarFeed(t *testing.T) {
+	var got store.CreateCalendarFeedParams
+	mux := NewRouter(&mockQuerier{
+		CreateCalendarFeedFn: func(_ context.Context, arg store.CreateCalendarFeedParams) (store.CalendarFeed, error) {
+			got = arg
+			return store.CalendarFeed{ID: 7, Name: arg.Name, Token: arg.Token, Filters: arg.Filters, CreatedAt: arg.CreatedAt}, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	body := `{"filters":{"owner_id":"100,101","activity":"copying","status":""}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/calendar/feeds", strings.NewReader(body))
+	req.Host = "auspex.example:8080"
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+	}
+	if got.Name != defaultCalendarFeedName {
+		t.Errorf("name = %q, want %q", got.Name, defaultCalendarFeedName)
+	}
+	if got.Filters != "activity=copying&owner_id=100%2C101" {
+		t.Errorf("stored filters = %q", got.Filters)
+	}
+	if len(got.Token) < 20 {
+		t.Errorf("token %q is too short", got.Token)
+	}
+
+	var resp calendarFeedJSON
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if want := "http://auspex.example:8080/calendar.ics?token=" + got.Token; resp.URL != want {
+		t.Errorf("url = %q, want %q", resp.URL, want)
+	}
+	if resp.Filters["owner_id"] != "100,101" || resp.Filters["activity"] != "copying" || len(resp.Filters) != 2 {
+		t.Errorf("filters = %v", resp.Filters)
+	}
+}
+
+func TestCreateCalendarFeed_InvalidFilters(t *testing.T) {
+	mux := NewRouter(&mockQuerier{
+		CreateCalendarFeedFn: func(_ context.Context, _ store.CreateCalendarFeedParams) (store.CalendarFeed, error) {
+			t.Error("feed created despite invalid filters")
+			return store.CalendarFeed{}, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	for _, body := range []string{
+		`{"filters":{"activity":"manufacturing"}}`,
+		`{"filters":{"sort":"type_name"}}`,
+		`{"filters":{"owner_id":"alpha"}}`,
+		`not json`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/calendar/feeds", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestDeleteCalendarFeed(t *testing.T) {
+	mux := NewRouter(&mockQuerier{
+		DeleteCalendarFeedFn: func(_ context.Context, id int64) (int64, error) {
+			switch id {
+			case 1:
+				return 1, nil
+			case 3:
+				return 0, errors.New("db error")
+			}
+			return 0, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	for path, want := range map[string]int{
+		"/api/calendar/feeds/1": http.StatusNoContent,
+		"/api/calendar/feeds/2": http.StatusNotFound,
+		"/api/calendar/feeds/3": http.StatusInternalServerError,
+		"/api/calendar/feeds/x": http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodDelete, path, http.NoBody)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("DELETE %s: expected %d, got %d", path, want, rr.Code)
+		}
+	}
+}
+
+func TestICalWriter_FoldsWithoutSplittingRunes(t *testing.T) {
+	var c icalWriter
+	value := icalText(strings.Repeat("Caldari Navy Assembly Plant › ", 6))
+	c.line("LOCATION", value)
+
+	lines := unfoldCalendar(t, c.buf.String())
+	if len(lines) != 1 || lines[0] != "LOCATION:"+value {
+		t.Errorf("unfolded = %q", lines)
+	}
+	if !strings.Contains(c.buf.String(), "\r\n ") {
+		t.Error("long line was not folded")
+	}
+}
+
+func TestICalText(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain":             "plain",
+		`a\b;c,d`:           `a\\b\;c\,d`,
+		"one\r\ntwo\nthree": `one\ntwo\nthree`,
+		"tab\there\x00":     "tabhere",
+	} {
+		if got := icalText(in); got != want {
+			t.Errorf("icalText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -6,23 +6,54 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/dpleshakov/auspex/internal/auth"
 	"github.com/dpleshakov/auspex/internal/store"
 )
 
+// reauthLoginURL is the login link offered for characters whose refresh token
+// was rejected by EVE SSO. Logging in with the same character replaces the
+// stored tokens and clears the needs_reauth flag. It is also the upgrade link
+// for characters missing scopes: every login requests the full scope set.
+const reauthLoginURL = "/auth/eve/login"
+
 type characterJSON struct {
-	ID              int64     `json:"id"`
-	Name            string    `json:"name"`
-	CorporationID   int64     `json:"corporation_id"`
-	CorporationName string    `json:"corporation_name"`
-	IsDelegate      bool      `json:"is_delegate"`
-	SyncError       *string   `json:"sync_error"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID              int64      `json:"id"`
+	Name            string     `json:"name"`
+	CorporationID   int64      `json:"corporation_id"`
+	CorporationName string     `json:"corporation_name"`
+	AllianceID      *int64     `json:"alliance_id"`
+	AllianceName    *string    `json:"alliance_name"`
+	IsDelegate      bool       `json:"is_delegate"`
+	SyncError       *string    `json:"sync_error"`
+	NeedsReauth     bool       `json:"needs_reauth"`
+	NeedsReauthAt   *time.Time `json:"needs_reauth_at"`
+	ReauthURL       *string    `json:"reauth_url"`
+	MissingScopes   []string   `json:"missing_scopes"`
+	UpgradeURL      *string    `json:"upgrade_url"`
+	Transferred     bool       `json:"transferred"`
+	TransferredAt   *time.Time `json:"transferred_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type affiliationEventJSON struct {
+	ID            int64     `json:"id"`
+	CharacterID   int64     `json:"character_id"`
+	CharacterName string    `json:"character_name"`
+	CorporationID int64     `json:"corporation_id"`
+	Event         string    `json:"event"`
+	OldID         *int64    `json:"old_id"`
+	OldName       *string   `json:"old_name"`
+	NewID         *int64    `json:"new_id"`
+	NewName       *string   `json:"new_name"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 // Handles:
 //
 //	GET    /api/characters
+//	GET    /api/characters/changes  (query param: since, RFC 3339; default: last 24 hours)
 //	DELETE /api/characters/{id}
+//	POST   /api/characters/{id}/confirm-transfer
 func (r *router) handleGetCharacters(w http.ResponseWriter, req *http.Request) {
 	chars, err := r.q.ListCharactersWithMeta(req.Context())
 	if err != nil {
@@ -35,19 +66,93 @@ func (r *router) handleGetCharacters(w http.ResponseWriter, req *http.Request) {
 		if s, ok := c.SyncError.(string); ok && s != "" {
 			syncErr = &s
 		}
+		var reauthAt *time.Time
+		var reauthURL *string
+		if c.NeedsReauth != 0 {
+			if c.NeedsReauthAt.Valid {
+				reauthAt = &c.NeedsReauthAt.Time
+			}
+			u := reauthLoginURL
+			reauthURL = &u
+		}
+		missing := auth.MissingScopes(c.Scopes)
+		var upgradeURL *string
+		if len(missing) > 0 {
+			u := reauthLoginURL
+			upgradeURL = &u
+		} else {
+			missing = []string{}
+		}
+		var transferredAt *time.Time
+		if c.Transferred != 0 && c.TransferredAt.Valid {
+			transferredAt = &c.TransferredAt.Time
+		}
+		var allianceID *int64
+		var allianceName *string
+		if c.AllianceID.Valid {
+			allianceID = &c.AllianceID.Int64
+			allianceName = &c.AllianceName.String
+		}
 		resp[i] = characterJSON{
 			ID:              c.ID,
 			Name:            c.Name,
 			CorporationID:   c.CorporationID,
 			CorporationName: c.CorporationName,
+			AllianceID:      allianceID,
+			AllianceName:    allianceName,
 			IsDelegate:      c.IsDelegate != 0,
 			SyncError:       syncErr,
+			NeedsReauth:     c.NeedsReauth != 0,
+			NeedsReauthAt:   reauthAt,
+			ReauthURL:       reauthURL,
+			MissingScopes:   missing,
+			UpgradeURL:      upgradeURL,
+			Transferred:     c.Transferred != 0,
+			TransferredAt:   transferredAt,
 			CreatedAt:       c.CreatedAt,
 		}
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
+func (r *router) handleGetCharacterChanges(w http.ResponseWriter, req *http.Request) {
+	since := time.Now().Add(-defaultChangesWindow)
+	if v := req.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid since")
+			return
+		}
+		since = t
+	}
+
+	rows, err := r.q.ListAffiliationEventsSince(req.Context(), since.UTC())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list character changes")
+		return
+	}
+
+	resp := make([]affiliationEventJSON, len(rows))
+	for i, row := range rows {
+		e := affiliationEventJSON{
+			ID:            row.ID,
+			CharacterID:   row.CharacterID,
+			CharacterName: row.CharacterName,
+			CorporationID: row.CorporationID,
+			Event:         row.Event,
+			CreatedAt:     row.CreatedAt,
+		}
+		if row.OldID.Valid {
+			e.OldID, e.OldName = &row.OldID.Int64, &row.OldName.String
+		}
+		if row.NewID.Valid {
+			e.NewID, e.NewName = &row.NewID.Int64, &row.NewName.String
+		}
+		resp[i] = e
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
 func (r *router) handleDeleteCharacter(w http.ResponseWriter, req *http.Request) {
 	id, err := parseID(req, "id")
 	if err != nil {
@@ -108,9 +213,9 @@ func (r *router) handleDeleteCharacter(w http.ResponseWriter, req *http.Request)
 					writeError(w, http.StatusInternalServerError, "failed to get corporation")
 					return
 				}
-			} else if corp.DelegateID == id {
+			} else if corp.DelegateID.Valid && corp.DelegateID.Int64 == id {
 				if err := r.q.UpdateCorporationDelegate(ctx, store.UpdateCorporationDelegateParams{
-					DelegateID: others[0].ID,
+					DelegateID: sql.NullInt64{Int64: others[0].ID, Valid: true},
 					ID:         corpID,
 				}); err != nil {
 					writeError(w, http.StatusInternalServerError, "failed to reassign delegate")
@@ -144,3 +249,38 @@ func (r *router) handleDeleteCharacter(w http.ResponseWriter, req *http.Request)
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// handleConfirmTransfer clears the transferred flag set when a character's
+// EVE SSO owner hash changed. The new owner's data is then synced and merged
+// with the blueprints and jobs already stored for the character, so a sync is
+// triggered right away. Deleting the character instead discards that history.
+func (r *router) handleConfirmTransfer(w http.ResponseWriter, req *http.Request) {
+	id, err := parseID(req, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid character id")
+		return
+	}
+	ctx := req.Context()
+
+	char, err := r.q.GetCharacter(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "character not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get character")
+		return
+	}
+	if char.Transferred == 0 {
+		writeError(w, http.StatusConflict, "character is not marked as transferred")
+		return
+	}
+
+	if err := r.q.ClearCharacterTransferred(ctx, id); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to confirm transfer")
+		return
+	}
+	r.worker.ForceRefresh()
+
+	w.WriteHeader(http.StatusNoContent)
+}
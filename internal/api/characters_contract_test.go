@@ -1,10 +1,13 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"testing"
 	"time"
+
+	"github.com/dpleshakov/auspex/internal/store"
 )
 
 func TestContract_GetCharacters_EmptyDB(t *testing.T) {
@@ -61,9 +64,46 @@ func TestContract_GetCharacters_NoCorpNoSyncError(t *testing.T) {
 	assertField[string](t, c, "corporation_name")
 	assertField[bool](t, c, "is_delegate")
 	assertNull(t, c, "sync_error")
+	assertField[bool](t, c, "needs_reauth")
+	assertNull(t, c, "needs_reauth_at")
+	assertNull(t, c, "reauth_url")
+	assertField[[]any](t, c, "missing_scopes")
+	assertNull(t, c, "upgrade_url")
+	assertField[bool](t, c, "transferred")
+	assertNull(t, c, "transferred_at")
 	assertField[string](t, c, "created_at")
 }
 
+func TestContract_GetCharacters_NeedsReauth(t *testing.T) {
+	sqlDB := newContractDB(t)
+	seedCharacter(t, sqlDB, 1003, "Revoked", 0)
+	if err := store.New(sqlDB).MarkCharacterNeedsReauth(context.Background(), 1003); err != nil {
+		t.Fatalf("MarkCharacterNeedsReauth: %v", err)
+	}
+	srv := newContractServer(t, sqlDB)
+
+	resp, err := http.Get(srv.URL + "/api/characters")
+	if err != nil {
+		t.Fatalf("GET /api/characters: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var items []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("expected 1 character, got %d", len(items))
+	}
+	c := items[0]
+
+	if v, ok := c["needs_reauth"].(bool); !ok || !v {
+		t.Errorf("needs_reauth: want true, got %v", c["needs_reauth"])
+	}
+	assertField[string](t, c, "needs_reauth_at")
+	assertField[string](t, c, "reauth_url")
+}
+
 func TestContract_GetCharacters_WithCorpAndSyncError(t *testing.T) {
 	sqlDB := newContractDB(t)
 	const charID int64 = 1002
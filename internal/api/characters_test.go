@@ -2,10 +2,12 @@ package api
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 	"time"
 
@@ -33,7 +35,7 @@ func TestGetCharacters_ReturnsJSON(t *testing.T) {
 			}, nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/characters", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -72,13 +74,96 @@ func TestGetCharacters_ReturnsJSON(t *testing.T) {
 	}
 }
 
+func TestGetCharacters_NeedsReauth(t *testing.T) {
+	flaggedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock := &mockQuerier{
+		ListCharactersWithMetaFn: func(_ context.Context) ([]store.ListCharactersWithMetaRow, error) {
+			return []store.ListCharactersWithMetaRow{
+				{ID: 1, Name: "Revoked", NeedsReauth: 1, NeedsReauthAt: sql.NullTime{Time: flaggedAt, Valid: true}},
+				{ID: 2, Name: "Healthy"},
+			}, nil
+		},
+	}
+	mux := NewRouter(mock, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/characters", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var got []map[string]any
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 characters, got %d", len(got))
+	}
+	if got[0]["needs_reauth"] != true {
+		t.Errorf("expected needs_reauth=true for Revoked, got %v", got[0]["needs_reauth"])
+	}
+	if got[0]["needs_reauth_at"] != "2026-03-01T12:00:00Z" {
+		t.Errorf("expected needs_reauth_at=2026-03-01T12:00:00Z, got %v", got[0]["needs_reauth_at"])
+	}
+	if got[0]["reauth_url"] != "/auth/eve/login" {
+		t.Errorf("expected reauth_url=/auth/eve/login, got %v", got[0]["reauth_url"])
+	}
+	if got[1]["needs_reauth"] != false || got[1]["needs_reauth_at"] != nil || got[1]["reauth_url"] != nil {
+		t.Errorf("expected no re-auth state for Healthy, got %v / %v / %v",
+			got[1]["needs_reauth"], got[1]["needs_reauth_at"], got[1]["reauth_url"])
+	}
+}
+
+func TestGetCharacters_MissingScopes(t *testing.T) {
+	mock := &mockQuerier{
+		ListCharactersWithMetaFn: func(_ context.Context) ([]store.ListCharactersWithMetaRow, error) {
+			return []store.ListCharactersWithMetaRow{
+				{ID: 1, Name: "Partial", Scopes: "esi-characters.read_blueprints.v1"},
+				{ID: 2, Name: "Legacy"},
+			}, nil
+		},
+	}
+	mux := NewRouter(mock, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/characters", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var got []characterJSON
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 characters, got %d", len(got))
+	}
+
+	if len(got[0].MissingScopes) == 0 || slices.Contains(got[0].MissingScopes, "esi-characters.read_blueprints.v1") {
+		t.Errorf("Partial: missing_scopes = %v, want every scope except the granted one", got[0].MissingScopes)
+	}
+	if got[0].UpgradeURL == nil {
+		t.Fatal("Partial: expected upgrade_url")
+	}
+	if *got[0].UpgradeURL != "/auth/eve/login" {
+		t.Errorf("Partial: upgrade_url = %q, want the login URL", *got[0].UpgradeURL)
+	}
+
+	// Scopes not recorded yet: nothing is reported missing.
+	if got[1].MissingScopes == nil || len(got[1].MissingScopes) != 0 || got[1].UpgradeURL != nil {
+		t.Errorf("Legacy: missing_scopes = %v, upgrade_url = %v, want [] and null", got[1].MissingScopes, got[1].UpgradeURL)
+	}
+}
+
 func TestGetCharacters_EmptyList(t *testing.T) {
 	mock := &mockQuerier{
 		ListCharactersWithMetaFn: func(_ context.Context) ([]store.ListCharactersWithMetaRow, error) {
 			return nil, nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/characters", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -103,7 +188,7 @@ func TestGetCharacters_DBError(t *testing.T) {
 			return nil, errors.New("db error")
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/characters", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -122,7 +207,7 @@ func TestDeleteCharacter_OK(t *testing.T) {
 			return nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/characters/42", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -160,7 +245,7 @@ func TestDeleteCharacter_CascadeOrder(t *testing.T) {
 			return nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/characters/7", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -213,7 +298,7 @@ func TestDeleteCharacter_LastInCorp(t *testing.T) {
 			return nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/characters/10", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -249,14 +334,14 @@ func TestDeleteCharacter_DelegateReassignment(t *testing.T) {
 			return []store.Character{{ID: charID}, {ID: otherID}}, nil
 		},
 		GetCorporationFn: func(_ context.Context, _ int64) (store.Corporation, error) {
-			return store.Corporation{ID: corpID, DelegateID: charID}, nil
+			return store.Corporation{ID: corpID, DelegateID: sql.NullInt64{Int64: charID, Valid: true}}, nil
 		},
 		UpdateCorporationDelegateFn: func(_ context.Context, arg store.UpdateCorporationDelegateParams) error {
-			newDelegateID = arg.DelegateID
+			newDelegateID = arg.DelegateID.Int64
 			return nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/characters/10", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -284,14 +369,14 @@ func TestDeleteCharacter_NotDelegate_NoReassignment(t *testing.T) {
 		},
 		GetCorporationFn: func(_ context.Context, _ int64) (store.Corporation, error) {
 			// Character 10 is NOT the delegate.
-			return store.Corporation{ID: corpID, DelegateID: delegateID}, nil
+			return store.Corporation{ID: corpID, DelegateID: sql.NullInt64{Int64: delegateID, Valid: true}}, nil
 		},
 		UpdateCorporationDelegateFn: func(_ context.Context, _ store.UpdateCorporationDelegateParams) error {
 			reassigned = true
 			return nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/characters/10", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -318,7 +403,7 @@ func TestDeleteCharacter_NPCCorp_NoCorpLogic(t *testing.T) {
 			return nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/characters/10", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -333,7 +418,7 @@ func TestDeleteCharacter_NPCCorp_NoCorpLogic(t *testing.T) {
 }
 
 func TestDeleteCharacter_InvalidID(t *testing.T) {
-	mux := NewRouter(&mockQuerier{}, nil, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/characters/notanumber", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -350,7 +435,7 @@ func TestDeleteCharacter_GetCharacterError(t *testing.T) {
 			return store.Character{}, errors.New("db error")
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/characters/42", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -371,7 +456,7 @@ func TestDeleteCharacter_DeleteBlueprintsError(t *testing.T) {
 			return errors.New("db error")
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/characters/42", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -391,7 +476,7 @@ func TestDeleteCharacter_DeleteJobsError(t *testing.T) {
 			return errors.New("db error")
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/characters/42", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -411,7 +496,7 @@ func TestDeleteCharacter_DeleteSyncStateError(t *testing.T) {
 			return errors.New("db error")
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/characters/42", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -431,7 +516,7 @@ func TestDeleteCharacter_DeleteCharacterError(t *testing.T) {
 			return errors.New("db error")
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/characters/42", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -441,3 +526,150 @@ func TestDeleteCharacter_DeleteCharacterError(t *testing.T) {
 		t.Errorf("expected 500, got %d", rr.Code)
 	}
 }
+
+func TestGetCharacters_Transferred(t *testing.T) {
+	at := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	mock := &mockQuerier{
+		ListCharactersWithMetaFn: func(_ context.Context) ([]store.ListCharactersWithMetaRow, error) {
+			return []store.ListCharactersWithMetaRow{
+				{ID: 1, Name: "Sold", Transferred: 1, TransferredAt: sql.NullTime{Time: at, Valid: true}},
+			}, nil
+		},
+	}
+	mux := NewRouter(mock, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/characters", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	var got []characterJSON
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(got) != 1 || !got[0].Transferred || got[0].TransferredAt == nil || !got[0].TransferredAt.Equal(at) {
+		t.Errorf("got %+v, want transferred=true transferred_at=%v", got, at)
+	}
+}
+
+func TestConfirmTransfer_OK(t *testing.T) {
+	var cleared int64
+	mock := &mockQuerier{
+		GetCharacterFn: func(_ context.Context, id int64) (store.Character, error) {
+			return store.Character{ID: id, Transferred: 1}, nil
+		},
+		ClearCharacterTransferredFn: func(_ context.Context, id int64) error {
+			cleared = id
+			return nil
+		},
+	}
+	worker := &mockWorker{}
+	mux := NewRouter(mock, worker, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/characters/42/confirm-transfer", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if cleared != 42 {
+		t.Errorf("ClearCharacterTransferred called with %d, want 42", cleared)
+	}
+	if !worker.forceRefreshCalled {
+		t.Error("expected ForceRefresh after confirming the transfer")
+	}
+}
+
+func TestConfirmTransfer_NotTransferred(t *testing.T) {
+	mock := &mockQuerier{
+		GetCharacterFn: func(_ context.Context, id int64) (store.Character, error) {
+			return store.Character{ID: id}, nil
+		},
+		ClearCharacterTransferredFn: func(_ context.Context, _ int64) error {
+			t.Error("ClearCharacterTransferred must not be called")
+			return nil
+		},
+	}
+	mux := NewRouter(mock, &mockWorker{}, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/characters/42/confirm-transfer", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d", rr.Code)
+	}
+}
+
+func TestConfirmTransfer_NotFound(t *testing.T) {
+	mock := &mockQuerier{
+		GetCharacterFn: func(_ context.Context, _ int64) (store.Character, error) {
+			return store.Character{}, sql.ErrNoRows
+		},
+	}
+	mux := NewRouter(mock, &mockWorker{}, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/characters/42/confirm-transfer", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestGetCharacterChanges_ReturnsJSON(t *testing.T) {
+	at := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+	var gotSince time.Time
+	mock := &mockQuerier{
+		ListAffiliationEventsSinceFn: func(_ context.Context, since time.Time) ([]store.AffiliationEvent, error) {
+			gotSince = since
+			return []store.AffiliationEvent{
+				{
+					ID: 2, CharacterID: 1, CharacterName: "Alpha", CorporationID: 98000001,
+					Event:     "delegate_orphaned",
+					OldID:     sql.NullInt64{Int64: 1, Valid: true},
+					OldName:   sql.NullString{String: "Alpha", Valid: true},
+					CreatedAt: at,
+				},
+			}, nil
+		},
+	}
+	mux := NewRouter(mock, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/characters/changes?since=2026-05-31T00:00:00Z", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if want := time.Date(2026, 5, 31, 0, 0, 0, 0, time.UTC); !gotSince.Equal(want) {
+		t.Errorf("since = %v, want %v", gotSince, want)
+	}
+	var got []map[string]any
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(got))
+	}
+	if got[0]["event"] != "delegate_orphaned" || got[0]["old_name"] != "Alpha" {
+		t.Errorf("unexpected event: %v", got[0])
+	}
+	if got[0]["new_id"] != nil || got[0]["new_name"] != nil {
+		t.Errorf("expected new_id and new_name null, got %v %v", got[0]["new_id"], got[0]["new_name"])
+	}
+}
+
+func TestGetCharacterChanges_InvalidSince(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/characters/changes?since=yesterday", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rr.Code)
+	}
+}
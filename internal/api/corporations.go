@@ -5,17 +5,29 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dpleshakov/auspex/internal/store"
 )
 
 type corporationJSON struct {
-	ID           int64     `json:"id"`
-	Name         string    `json:"name"`
-	DelegateID   int64     `json:"delegate_id"`
-	DelegateName string    `json:"delegate_name"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID                  int64                   `json:"id"`
+	Name                string                  `json:"name"`
+	DelegateID          *int64                  `json:"delegate_id"`
+	DelegateName        *string                 `json:"delegate_name"`
+	ActiveCharacterID   *int64                  `json:"active_character_id"`
+	ActiveCharacterName *string                 `json:"active_character_name"`
+	Members             []corporationMemberJSON `json:"members"`
+	CreatedAt           time.Time               `json:"created_at"`
+}
+
+// corporationMemberJSON is a tracked character in the corporation. Roles is
+// null until the character's corporation roles have been fetched.
+type corporationMemberJSON struct {
+	ID    int64    `json:"id"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
 }
 
 type addCorporationRequest struct {
@@ -35,14 +47,53 @@ func (r *router) handleGetCorporations(w http.ResponseWriter, req *http.Request)
 		writeError(w, http.StatusInternalServerError, "failed to list corporations")
 		return
 	}
+	chars, err := r.q.ListCharacters(req.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list corporations")
+		return
+	}
+	members := make(map[int64][]corporationMemberJSON)
+	for _, ch := range chars {
+		m := corporationMemberJSON{ID: ch.ID, Name: ch.Name}
+		if ch.Roles.Valid {
+			m.Roles = strings.Fields(ch.Roles.String)
+			if m.Roles == nil {
+				m.Roles = []string{}
+			}
+		}
+		members[ch.CorporationID] = append(members[ch.CorporationID], m)
+	}
+
 	resp := make([]corporationJSON, len(corps))
 	for i, c := range corps {
+		// An orphaned corporation (no tracked member left) has no delegate.
+		var delegateID *int64
+		var delegateName *string
+		if c.DelegateID.Valid {
+			delegateID = &c.DelegateID.Int64
+			delegateName = &c.DelegateName.String
+		}
+		// The active character is null until a corporation endpoint succeeded,
+		// and once it has left the corporation.
+		var activeID *int64
+		var activeName *string
+		if c.ActiveCharacterID.Valid {
+			activeID = &c.ActiveCharacterID.Int64
+			activeName = &c.ActiveCharacterName.String
+		}
+		corpMembers := members[c.ID]
+		if corpMembers == nil {
+			corpMembers = []corporationMemberJSON{}
+		}
 		resp[i] = corporationJSON{
-			ID:           c.ID,
-			Name:         c.Name,
-			DelegateID:   c.DelegateID,
-			DelegateName: c.DelegateName,
-			CreatedAt:    c.CreatedAt,
+			ID:                  c.ID,
+			Name:                c.Name,
+			DelegateID:          delegateID,
+			DelegateName:        delegateName,
+			ActiveCharacterID:   activeID,
+			ActiveCharacterName: activeName,
+			Members:             corpMembers,
+			CreatedAt:           c.CreatedAt,
 		}
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -70,7 +121,7 @@ func (r *router) handleAddCorporation(w http.ResponseWriter, req *http.Request)
 	if err := r.q.InsertCorporation(ctx, store.InsertCorporationParams{
 		ID:         body.ID,
 		Name:       body.Name,
-		DelegateID: body.DelegateID,
+		DelegateID: sql.NullInt64{Int64: body.DelegateID, Valid: true},
 	}); err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to insert corporation")
 		return
@@ -114,7 +165,7 @@ func (r *router) handlePatchCorporationDelegate(w http.ResponseWriter, req *http
 		return
 	}
 	if err := r.q.UpdateCorporationDelegate(ctx, store.UpdateCorporationDelegateParams{
-		DelegateID: body.CharacterID,
+		DelegateID: sql.NullInt64{Int64: body.CharacterID, Valid: true},
 		ID:         corpID,
 	}); err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to update delegate")
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 	"time"
 
@@ -19,11 +20,11 @@ func TestGetCorporations_ReturnsJSON(t *testing.T) {
 	mock := &mockQuerier{
 		ListCorporationsFn: func(_ context.Context) ([]store.ListCorporationsRow, error) {
 			return []store.ListCorporationsRow{
-				{ID: 100, Name: "Goonswarm", DelegateID: 1, DelegateName: "Alpha", CreatedAt: createdAt},
+				{ID: 100, Name: "Goonswarm", DelegateID: sql.NullInt64{Int64: 1, Valid: true}, DelegateName: sql.NullString{String: "Alpha", Valid: true}, CreatedAt: createdAt},
 			}, nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/corporations", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -47,13 +48,93 @@ func TestGetCorporations_ReturnsJSON(t *testing.T) {
 	}
 }
 
+func TestGetCorporations_MembersAndActiveCharacter(t *testing.T) {
+	mock := &mockQuerier{
+		ListCorporationsFn: func(_ context.Context) ([]store.ListCorporationsRow, error) {
+			return []store.ListCorporationsRow{{
+				ID: 100, Name: "Goonswarm",
+				DelegateID:          sql.NullInt64{Int64: 1, Valid: true},
+				DelegateName:        sql.NullString{String: "Alpha", Valid: true},
+				ActiveCharacterID:   sql.NullInt64{Int64: 2, Valid: true},
+				ActiveCharacterName: sql.NullString{String: "Beta", Valid: true},
+			}}, nil
+		},
+		ListCharactersFn: func(_ context.Context) ([]store.Character, error) {
+			return []store.Character{
+				{ID: 1, Name: "Alpha", CorporationID: 100, Roles: sql.NullString{String: "", Valid: true}},
+				{ID: 2, Name: "Beta", CorporationID: 100, Roles: sql.NullString{String: "Director Factory_Manager", Valid: true}},
+				{ID: 3, Name: "Gamma", CorporationID: 100},
+				{ID: 4, Name: "Rookie", CorporationID: 1000182},
+			}, nil
+		},
+	}
+	mux := NewRouter(mock, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/corporations", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var got []corporationJSON
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected 1 corporation, got %d", len(got))
+	}
+	c := got[0]
+	if c.ActiveCharacterID == nil || *c.ActiveCharacterID != 2 || c.ActiveCharacterName == nil || *c.ActiveCharacterName != "Beta" {
+		t.Errorf("active character = %v %v, want 2 Beta", c.ActiveCharacterID, c.ActiveCharacterName)
+	}
+	if len(c.Members) != 3 {
+		t.Fatalf("expected 3 members, got %+v", c.Members)
+	}
+	if c.Members[0].Roles == nil || len(c.Members[0].Roles) != 0 {
+		t.Errorf("Alpha roles = %v, want empty list", c.Members[0].Roles)
+	}
+	if !slices.Equal(c.Members[1].Roles, []string{"Director", "Factory_Manager"}) {
+		t.Errorf("Beta roles = %v, want [Director Factory_Manager]", c.Members[1].Roles)
+	}
+	if c.Members[2].Roles != nil {
+		t.Errorf("Gamma roles = %v, want null (not fetched yet)", c.Members[2].Roles)
+	}
+}
+
+func TestGetCorporations_Orphaned(t *testing.T) {
+	mock := &mockQuerier{
+		ListCorporationsFn: func(_ context.Context) ([]store.ListCorporationsRow, error) {
+			return []store.ListCorporationsRow{{ID: 100, Name: "Goonswarm"}}, nil
+		},
+	}
+	mux := NewRouter(mock, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/corporations", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	var got []map[string]any
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected 1 corporation, got %d", len(got))
+	}
+	for _, field := range []string{"delegate_id", "delegate_name"} {
+		if v, ok := got[0][field]; !ok || v != nil {
+			t.Errorf("expected %s=null for an orphaned corporation, got %v", field, v)
+		}
+	}
+}
+
 func TestGetCorporations_DBError(t *testing.T) {
 	mock := &mockQuerier{
 		ListCorporationsFn: func(_ context.Context) ([]store.ListCorporationsRow, error) {
 			return nil, errors.New("db error")
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/corporations", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -72,7 +153,7 @@ func TestAddCorporation_OK(t *testing.T) {
 			return nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	body := `{"id":100,"name":"Goonswarm","delegate_id":1}`
 	req := httptest.NewRequest(http.MethodPost, "/api/corporations", bytes.NewBufferString(body))
@@ -83,7 +164,7 @@ func TestAddCorporation_OK(t *testing.T) {
 	if rr.Code != http.StatusCreated {
 		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
 	}
-	if inserted.ID != 100 || inserted.Name != "Goonswarm" || inserted.DelegateID != 1 {
+	if inserted.ID != 100 || inserted.Name != "Goonswarm" || inserted.DelegateID != (sql.NullInt64{Int64: 1, Valid: true}) {
 		t.Errorf("inserted params = %+v, want {100 Goonswarm 1}", inserted)
 	}
 }
@@ -94,7 +175,7 @@ func TestAddCorporation_InvalidDelegate(t *testing.T) {
 			return store.Character{}, sql.ErrNoRows
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	body := `{"id":100,"name":"Goonswarm","delegate_id":999}`
 	req := httptest.NewRequest(http.MethodPost, "/api/corporations", bytes.NewBufferString(body))
@@ -108,7 +189,7 @@ func TestAddCorporation_InvalidDelegate(t *testing.T) {
 }
 
 func TestAddCorporation_MissingFields(t *testing.T) {
-	mux := NewRouter(&mockQuerier{}, nil, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
 
 	cases := []struct {
 		name string
@@ -132,7 +213,7 @@ func TestAddCorporation_MissingFields(t *testing.T) {
 }
 
 func TestAddCorporation_InvalidBody(t *testing.T) {
-	mux := NewRouter(&mockQuerier{}, nil, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodPost, "/api/corporations", bytes.NewBufferString("not json"))
 	req.Header.Set("Content-Type", "application/json")
@@ -164,7 +245,7 @@ func TestDeleteCorporation_OK(t *testing.T) {
 			return nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/corporations/100", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -185,7 +266,7 @@ func TestDeleteCorporation_OK(t *testing.T) {
 }
 
 func TestDeleteCorporation_InvalidID(t *testing.T) {
-	mux := NewRouter(&mockQuerier{}, nil, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/corporations/notanumber", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -202,7 +283,7 @@ func TestDeleteCorporation_DeleteBlueprintsError(t *testing.T) {
 			return errors.New("db error")
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/corporations/100", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -219,7 +300,7 @@ func TestDeleteCorporation_DeleteJobsError(t *testing.T) {
 			return errors.New("db error")
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/corporations/100", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -236,7 +317,7 @@ func TestDeleteCorporation_DeleteSyncStateError(t *testing.T) {
 			return errors.New("db error")
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/corporations/100", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -253,7 +334,7 @@ func TestDeleteCorporation_DeleteCorporationError(t *testing.T) {
 			return errors.New("db error")
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/corporations/100", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -268,7 +349,7 @@ func TestPatchDelegate_OK(t *testing.T) {
 	var updated store.UpdateCorporationDelegateParams
 	mock := &mockQuerier{
 		GetCorporationFn: func(_ context.Context, id int64) (store.Corporation, error) {
-			return store.Corporation{ID: id, DelegateID: 1}, nil
+			return store.Corporation{ID: id, DelegateID: sql.NullInt64{Int64: 1, Valid: true}}, nil
 		},
 		GetCharacterFn: func(_ context.Context, id int64) (store.Character, error) {
 			return store.Character{ID: id, CorporationID: 100}, nil
@@ -278,7 +359,7 @@ func TestPatchDelegate_OK(t *testing.T) {
 			return nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	body := `{"character_id":42}`
 	req := httptest.NewRequest(http.MethodPatch, "/api/corporations/100/delegate", bytes.NewBufferString(body))
@@ -289,7 +370,7 @@ func TestPatchDelegate_OK(t *testing.T) {
 	if rr.Code != http.StatusNoContent {
 		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
 	}
-	if updated.ID != 100 || updated.DelegateID != 42 {
+	if updated.ID != 100 || updated.DelegateID != (sql.NullInt64{Int64: 42, Valid: true}) {
 		t.Errorf("update params = %+v, want {ID:100 DelegateID:42}", updated)
 	}
 }
@@ -300,7 +381,7 @@ func TestPatchDelegate_CorporationNotFound(t *testing.T) {
 			return store.Corporation{}, sql.ErrNoRows
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	body := `{"character_id":42}`
 	req := httptest.NewRequest(http.MethodPatch, "/api/corporations/100/delegate", bytes.NewBufferString(body))
@@ -323,7 +404,7 @@ func TestPatchDelegate_CharacterNotInCorporation(t *testing.T) {
 			return store.Character{ID: id, CorporationID: 999}, nil
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	body := `{"character_id":42}`
 	req := httptest.NewRequest(http.MethodPatch, "/api/corporations/100/delegate", bytes.NewBufferString(body))
@@ -345,7 +426,7 @@ func TestPatchDelegate_CharacterNotFound(t *testing.T) {
 			return store.Character{}, sql.ErrNoRows
 		},
 	}
-	mux := NewRouter(mock, nil, nil, testFS())
+	mux := NewRouter(mock, nil, nil, nil, testFS())
 
 	body := `{"character_id":42}`
 	req := httptest.NewRequest(http.MethodPatch, "/api/corporations/100/delegate", bytes.NewBufferString(body))
@@ -359,7 +440,7 @@ func TestPatchDelegate_CharacterNotFound(t *testing.T) {
 }
 
 func TestPatchDelegate_MissingCharacterID(t *testing.T) {
-	mux := NewRouter(&mockQuerier{}, nil, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
 
 	body := `{}`
 	req := httptest.NewRequest(http.MethodPatch, "/api/corporations/100/delegate", bytes.NewBufferString(body))
@@ -373,7 +454,7 @@ func TestPatchDelegate_MissingCharacterID(t *testing.T) {
 }
 
 func TestPatchDelegate_InvalidID(t *testing.T) {
-	mux := NewRouter(&mockQuerier{}, nil, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
 
 	body := `{"character_id":42}`
 	req := httptest.NewRequest(http.MethodPatch, "/api/corporations/notanumber/delegate", bytes.NewBufferString(body))
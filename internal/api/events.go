@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+)
+
+const (
+	// eventsHeartbeat is the interval of the comment lines that keep an idle
+	// event stream open through proxies.
+	eventsHeartbeat = 15 * time.Second
+
+	// eventsRetry is the reconnection delay suggested to the browser.
+	eventsRetry = 3 * time.Second
+
+	// eventTypeResync tells the client that it missed events — its
+	// Last-Event-ID is too old or from before a restart — and must reload its
+	// data instead of relying on the stream.
+	eventTypeResync = "resync"
+)
+
+// Handles:
+//
+//	GET /api/events   (Server-Sent Events stream of sync worker events)
+//
+// Each event is sent with its bus ID, its type as the SSE event name, and its
+// data as JSON. A client reconnecting with Last-Event-ID is first sent the
+// events it missed, or a resync event if they are no longer retained.
+func (r *router) handleGetEvents(w http.ResponseWriter, req *http.Request) {
+	if r.events == nil {
+		writeError(w, http.StatusServiceUnavailable, "event stream not available")
+		return
+	}
+
+	var lastID uint64
+	resync := false
+	if v := req.Header.Get("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			resync = true
+		}
+		lastID = id
+	}
+
+	sub, replay, complete := r.events.Subscribe(lastID)
+	defer sub.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // no proxy buffering
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", eventsRetry.Milliseconds()); err != nil {
+		return
+	}
+	if resync || !complete {
+		// An empty id clears the client's Last-Event-ID, so that it does not
+		// resume from the stale ID again if no other event follows.
+		if _, err := io.WriteString(w, "id:\nevent: "+eventTypeResync+"\ndata: {}\n\n"); err != nil {
+			return
+		}
+	}
+	for _, e := range replay {
+		if err := writeEvent(w, e); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := r.heartbeat
+	if heartbeat <= 0 {
+		heartbeat = eventsHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-req.Context().Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind, or the server is shutting down.
+				// The client reconnects and resumes from its Last-Event-ID.
+				return
+			}
+			err = writeEvent(w, e)
+		case <-ticker.C:
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes e in the SSE wire format.
+func writeEvent(w io.Writer, e events.Event) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return fmt.Errorf("encoding %s event %d: %w", e.Type, e.ID, err)
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+)
+
+// openEventStream connects to /api/events on srv with the given Last-Event-ID
+// (none if empty) and returns a reader positioned after the retry preamble.
+func openEventStream(t *testing.T, ctx context.Context, srv *httptest.Server, lastEventID string) *bufio.Reader {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/events", http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/events: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	r := bufio.NewReader(resp.Body)
+	if got := readBlock(t, r); got != "retry: 3000" {
+		t.Errorf("preamble = %q, want retry: 3000", got)
+	}
+	return r
+}
+
+// readBlock reads one SSE block (up to the blank line) and returns its lines
+// joined by "\n".
+func readBlock(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event stream: %v (read so far: %q)", err, lines)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return strings.Join(lines, "\n")
+		}
+		lines = append(lines, line)
+	}
+}
+
+// waitSubscribers waits until bus has n subscribers.
+func waitSubscribers(t *testing.T, bus *events.Bus, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for bus.Subscribers() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("subscribers = %d, want %d", bus.Subscribers(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGetEvents_StreamsPublishedEvents(t *testing.T) {
+	bus := events.NewBus(8)
+	srv := httptest.NewServer(NewRouter(&mockQuerier{}, nil, nil, bus, testFS()))
+	t.Cleanup(srv.Close) // after the stream is closed
+
+	r := openEventStream(t, context.Background(), srv, "")
+	waitSubscribers(t, bus, 1)
+
+	bus.Publish(events.TypeSubjectSynced, events.Subject{OwnerType: "character", OwnerID: 42, Endpoint: "blueprints"})
+
+	want := "id: 1\nevent: subject_synced\ndata: {\"owner_type\":\"character\",\"owner_id\":42,\"endpoint\":\"blueprints\"}"
+	if got := readBlock(t, r); got != want {
+		t.Errorf("event:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGetEvents_ResumesFromLastEventID(t *testing.T) {
+	bus := events.NewBus(8)
+	for range 3 {
+		bus.Publish(events.TypeCycleStarted, events.Cycle{})
+	}
+	srv := httptest.NewServer(NewRouter(&mockQuerier{}, nil, nil, bus, testFS()))
+	t.Cleanup(srv.Close) // after the stream is closed
+
+	r := openEventStream(t, context.Background(), srv, "1")
+
+	for _, id := range []string{"2", "3"} {
+		got := readBlock(t, r)
+		if !strings.HasPrefix(got, "id: "+id+"\nevent: cycle_started\n") {
+			t.Errorf("replayed event = %q, want id %s", got, id)
+		}
+	}
+}
+
+func TestGetEvents_ResyncWhenEventsMissed(t *testing.T) {
+	for _, lastID := range []string{"1", "99", "bogus"} {
+		t.Run(lastID, func(t *testing.T) {
+			bus := events.NewBus(2)
+			for range 4 {
+				bus.Publish(events.TypeCycleStarted, events.Cycle{})
+			}
+			srv := httptest.NewServer(NewRouter(&mockQuerier{}, nil, nil, bus, testFS()))
+			t.Cleanup(srv.Close)
+
+			r := openEventStream(t, context.Background(), srv, lastID)
+			if got := readBlock(t, r); got != "id:\nevent: resync\ndata: {}" {
+				t.Errorf("first event = %q, want resync", got)
+			}
+		})
+	}
+}
+
+func TestGetEvents_Heartbeat(t *testing.T) {
+	bus := events.NewBus(8)
+	rt := &router{events: bus, heartbeat: 10 * time.Millisecond}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/events", rt.handleGetEvents)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	r := openEventStream(t, context.Background(), srv, "")
+	if got := readBlock(t, r); got != ": heartbeat" {
+		t.Errorf("block = %q, want heartbeat comment", got)
+	}
+}
+
+func TestGetEvents_DisconnectUnsubscribes(t *testing.T) {
+	bus := events.NewBus(8)
+	srv := httptest.NewServer(NewRouter(&mockQuerier{}, nil, nil, bus, testFS()))
+	t.Cleanup(srv.Close) // after the stream is closed
+
+	ctx, cancel := context.WithCancel(context.Background())
+	openEventStream(t, ctx, srv, "")
+	waitSubscribers(t, bus, 1)
+
+	cancel()
+	waitSubscribers(t, bus, 0)
+}
+
+func TestGetEvents_BusCloseEndsStream(t *testing.T) {
+	bus := events.NewBus(8)
+	srv := httptest.NewServer(NewRouter(&mockQuerier{}, nil, nil, bus, testFS()))
+	t.Cleanup(srv.Close) // after the stream is closed
+
+	r := openEventStream(t, context.Background(), srv, "")
+	waitSubscribers(t, bus, 1)
+
+	bus.Close()
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Error("stream still open after Bus.Close")
+	}
+}
+
+func TestGetEvents_NoSource_Returns503(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/events", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rr.Code)
+	}
+}
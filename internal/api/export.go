@@ -0,0 +1,274 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/dpleshakov/auspex/internal/export"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// Export datasets, served at /api/export/{dataset} and written by
+// `auspex export`.
+const (
+	ExportBlueprints = "blueprints"
+	ExportJobs       = "jobs"
+)
+
+// exportColumn is one column of an export: its name in the header row (or the
+// JSON key) and its value for a blueprint row.
+type exportColumn struct {
+	name  string
+	value func(row *store.ListBlueprintsRow, now time.Time) any
+}
+
+// blueprintExportColumns are the columns of the blueprints export, one row per
+// blueprint. Names match the fields of GET /api/blueprints; the job columns
+// are empty for an idle blueprint.
+var blueprintExportColumns = []exportColumn{
+	{"id", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.ID }},
+	{"owner_type", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.OwnerType }},
+	{"owner_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.OwnerID }},
+	{"owner_name", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.OwnerName }},
+	{"type_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.TypeID }},
+	{"type_name", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.TypeName }},
+	{"group_name", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.GroupName }},
+	{"category_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.CategoryID }},
+	{"category_name", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.CategoryName }},
+	{"me_level", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.MeLevel }},
+	{"te_level", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.TeLevel }},
+	{"status", func(r *store.ListBlueprintsRow, now time.Time) any { return blueprintStatus(r, now) }},
+	{"location_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.LocationID }},
+	{"location_name", exportLocationName},
+	{"system_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullInt(r.SystemID) }},
+	{"system_name", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullString(r.SystemName) }},
+	{"region_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullInt(r.RegionID) }},
+	{"region_name", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullString(r.RegionName) }},
+	{"security_status", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullFloat(r.SecurityStatus) }},
+	{"job_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullInt(r.JobID) }},
+	{"job_activity", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullString(r.JobActivity) }},
+	{"job_start_date", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullTime(r.JobStartDate) }},
+	{"job_end_date", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullTime(r.JobEndDate) }},
+	{"job_installer_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullInt(r.JobInstallerID) }},
+	{"job_installer_name", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullString(r.JobInstallerName) }},
+}
+
+// jobExportColumns are the columns of the jobs export, one row per job with
+// the blueprint it runs on. status is the derived job status: ready once the
+// end date has passed.
+var jobExportColumns = []exportColumn{
+	{"job_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.JobID.Int64 }},
+	{"activity", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.JobActivity.String }},
+	{"status", func(r *store.ListBlueprintsRow, now time.Time) any { return blueprintStatus(r, now) }},
+	{"start_date", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullTime(r.JobStartDate) }},
+	{"end_date", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullTime(r.JobEndDate) }},
+	{"installer_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullInt(r.JobInstallerID) }},
+	{"installer_name", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullString(r.JobInstallerName) }},
+	{"blueprint_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.ID }},
+	{"type_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.TypeID }},
+	{"type_name", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.TypeName }},
+	{"me_level", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.MeLevel }},
+	{"te_level", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.TeLevel }},
+	{"owner_type", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.OwnerType }},
+	{"owner_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.OwnerID }},
+	{"owner_name", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.OwnerName }},
+	{"location_id", func(r *store.ListBlueprintsRow, _ time.Time) any { return r.LocationID }},
+	{"location_name", exportLocationName},
+	{"system_name", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullString(r.SystemName) }},
+	{"region_name", func(r *store.ListBlueprintsRow, _ time.Time) any { return nullString(r.RegionName) }},
+}
+
+func exportLocationName(r *store.ListBlueprintsRow, _ time.Time) any {
+	if name := blueprintLocationName(r); name != nil {
+		return *name
+	}
+	return nil
+}
+
+func nullString(v sql.NullString) any {
+	if !v.Valid {
+		return nil
+	}
+	return v.String
+}
+
+func nullInt(v sql.NullInt64) any {
+	if !v.Valid {
+		return nil
+	}
+	return v.Int64
+}
+
+func nullFloat(v sql.NullFloat64) any {
+	if !v.Valid {
+		return nil
+	}
+	return v.Float64
+}
+
+func nullTime(v sql.NullTime) any {
+	if !v.Valid {
+		return nil
+	}
+	return v.Time
+}
+
+// errUnknownExport is returned for a dataset other than ExportBlueprints and
+// ExportJobs.
+var errUnknownExport = errors.New("unknown export")
+
+// exportQuery is a validated export request.
+type exportQuery struct {
+	dataset string
+	sheet   string
+	columns []exportColumn
+	bq      *blueprintQuery
+}
+
+// parseExportQuery validates an export of dataset. params take the filters, q
+// and sort of GET /api/blueprints; limit and cursor are ignored, as an export
+// holds every match.
+func parseExportQuery(dataset string, params url.Values) (*exportQuery, error) {
+	eq := &exportQuery{dataset: dataset}
+	switch dataset {
+	case ExportBlueprints:
+		eq.sheet, eq.columns = "Blueprints", blueprintExportColumns
+	case ExportJobs:
+		eq.sheet, eq.columns = "Jobs", jobExportColumns
+	default:
+		return nil, errUnknownExport
+	}
+
+	params = cloneValues(params)
+	params.Del("limit")
+	params.Del("cursor")
+	bq, err := parseBlueprintQuery(params)
+	if err != nil {
+		return nil, err
+	}
+	eq.bq = bq
+	return eq, nil
+}
+
+// rows reads the rows of the export from q: the blueprints matching the
+// query, or those with a job for ExportJobs, in the order of the query.
+func (eq *exportQuery) rows(ctx context.Context, q store.Querier, now time.Time) ([]store.ListBlueprintsRow, error) {
+	p, err := blueprintPager(q)
+	if err != nil {
+		return nil, err
+	}
+	return eq.bq.all(ctx, p, now, eq.dataset == ExportJobs)
+}
+
+// write writes rows, as read by eq.rows, to w in format. now decides whether
+// a job past its end date is ready.
+func (eq *exportQuery) write(w io.Writer, format export.Format, rows []store.ListBlueprintsRow, now time.Time) error {
+	names := make([]string, len(eq.columns))
+	for i, c := range eq.columns {
+		names[i] = c.name
+	}
+	ew, err := export.NewWriter(w, format, eq.sheet, names)
+	if err != nil {
+		return err
+	}
+
+	values := make([]any, len(eq.columns))
+	for i := range rows {
+		for j, c := range eq.columns {
+			values[j] = c.value(&rows[i], now)
+		}
+		if err := ew.WriteRow(values); err != nil {
+			return err
+		}
+	}
+	return ew.Close()
+}
+
+// Export writes dataset (ExportBlueprints or ExportJobs) from q to w in
+// format, filtered and sorted by params exactly as GET /api/export/{dataset}
+// does. It is the offline counterpart of the endpoint, used by
+// `auspex export`.
+func Export(ctx context.Context, q store.Querier, w io.Writer, dataset string, format export.Format, params url.Values) error {
+	eq, err := parseExportQuery(dataset, params)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	rows, err := eq.rows(ctx, q, now)
+	if err != nil {
+		return err
+	}
+	return eq.write(w, format, rows, now)
+}
+
+// ExportFileName returns the suggested file name of an export of dataset in
+// format made at now, e.g. "auspex-blueprints-2026-03-01.csv".
+func ExportFileName(dataset string, format export.Format, now time.Time) string {
+	return fmt.Sprintf("auspex-%s-%s.%s", dataset, now.Format(time.DateOnly), format)
+}
+
+// Handles:
+//
+//	GET /api/export/blueprints  (query params: format, csv (default), xlsx or ndjson;
+//	GET /api/export/jobs         the filters, q and sort of GET /api/blueprints)
+//
+// Streams every matching blueprint, or every job on a matching blueprint, as
+// a file download. Stored OAuth tokens are never part of an export.
+func (r *router) handleGetExport(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	format := export.FormatCSV
+	if v := query.Get("format"); v != "" {
+		f, err := export.ParseFormat(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid format")
+			return
+		}
+		format = f
+	}
+
+	dataset := chi.URLParam(req, "dataset")
+	eq, err := parseExportQuery(dataset, query)
+	if errors.Is(err, errUnknownExport) {
+		writeError(w, http.StatusNotFound, "unknown export")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now()
+	rows, err := eq.rows(req.Context(), r.q, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list blueprints")
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", format.ContentType())
+	h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, ExportFileName(dataset, format, now)))
+	h.Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := eq.write(w, format, rows, now); err != nil {
+		// The status is sent already; the client sees a truncated file.
+		log.Printf("api: writing %s export: %v", dataset, err)
+	}
+}
+
+// cloneValues returns a copy of v that can be modified without affecting v.
+func cloneValues(v url.Values) url.Values {
+	out := make(url.Values, len(v))
+	for k, vs := range v {
+		out[k] = append([]string(nil), vs...)
+	}
+	return out
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// getExport requests path from a router over rows.
+func getExport(t *testing.T, rows []store.ListBlueprintsRow, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	mux, _ := newLibraryRouter(t, rows)
+
+	req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestGetExport_BlueprintsCSV(t *testing.T) {
+	rr := getExport(t, libraryRows(time.Now()), "/api/export/blueprints?owner_type=character&sort=type_name")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="auspex-blueprints-`) || !strings.HasSuffix(cd, `.csv"`) {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	header := records[0]
+	if header[0] != "id" || len(header) != len(blueprintExportColumns) {
+		t.Errorf("header = %v", header)
+	}
+	var ids []string
+	for _, rec := range records[1:] {
+		ids = append(ids, rec[0])
+	}
+	// Filtered and sorted as GET /api/blueprints would.
+	if want := []string{"5", "2", "1"}; !slices.Equal(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+
+	col := func(rec []string, name string) string { return rec[slices.Index(header, name)] }
+	caracal, drake := records[1], records[2]
+	if col(caracal, "location_name") != "Jita IV - Moon 4 - Caldari Navy Assembly Plant › Corp Hangar 2 (Research)" {
+		t.Errorf("location_name = %q", col(caracal, "location_name"))
+	}
+	if col(caracal, "status") != "idle" || col(caracal, "job_id") != "" || col(caracal, "system_id") != "" {
+		t.Errorf("idle blueprint: status %q, job_id %q, system_id %q", col(caracal, "status"), col(caracal, "job_id"), col(caracal, "system_id"))
+	}
+	if col(drake, "status") != "active" || col(drake, "job_activity") != "copying" {
+		t.Errorf("blueprint with job: status %q, job_activity %q", col(drake, "status"), col(drake, "job_activity"))
+	}
+	if _, err := time.Parse(time.RFC3339, col(drake, "job_end_date")); err != nil {
+		t.Errorf("job_end_date %q is not an ISO timestamp", col(drake, "job_end_date"))
+	}
+}
+
+func TestGetExport_JobsNDJSON(t *testing.T) {
+	rr := getExport(t, libraryRows(time.Now()), "/api/export/jobs?format=ndjson&limit=1")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	// Only blueprints with a job, all of them despite limit, default sort.
+	var jobs []map[string]any
+	sc := bufio.NewScanner(rr.Body)
+	for sc.Scan() {
+		var job map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &job); err != nil {
+			t.Fatalf("invalid line %q: %v", sc.Text(), err)
+		}
+		jobs = append(jobs, job)
+	}
+	var got []float64
+	for _, j := range jobs {
+		got = append(got, j["blueprint_id"].(float64))
+	}
+	if want := []float64{3, 4, 2}; !slices.Equal(got, want) {
+		t.Errorf("blueprint_id of jobs = %v, want %v", got, want)
+	}
+	if jobs[0]["status"] != "ready" || jobs[0]["activity"] != "copying" || jobs[0]["start_date"] == nil {
+		t.Errorf("first job = %v", jobs[0])
+	}
+}
+
+func TestGetExport_XLSX(t *testing.T) {
+	rr := getExport(t, libraryRows(time.Now()), "/api/export/jobs?format=xlsx")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.HasPrefix(rr.Body.String(), "PK") {
+		t.Error("body is not a zip archive")
+	}
+}
+
+func TestGetExport_Errors(t *testing.T) {
+	for path, want := range map[string]int{
+		"/api/export/characters":             http.StatusNotFound,
+		"/api/export/blueprints?format=xls":  http.StatusBadRequest,
+		"/api/export/blueprints?status=done": http.StatusBadRequest,
+		"/api/export/jobs?sort=name":         http.StatusBadRequest,
+	} {
+		if rr := getExport(t, nil, path); rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, rr.Code)
+		}
+	}
+
+	mux := NewRouter(&mockQuerier{
+		ListBlueprintsFn: func(context.Context, store.ListBlueprintsParams) ([]store.ListBlueprintsRow, error) {
+			return nil, errors.New("db error")
+		},
+	}, nil, nil, nil, testFS())
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/export/blueprints", http.NoBody))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("store error: expected 500, got %d", rr.Code)
+	}
+}
+
+func TestExport_MatchesEndpoint(t *testing.T) {
+	mux, sqlDB := newLibraryRouter(t, libraryRows(time.Now()))
+	q := store.New(sqlDB)
+	params := map[string][]string{"category_id": {"9"}, "sort": {"-me"}}
+
+	var buf strings.Builder
+	if err := Export(context.Background(), q, &buf, ExportBlueprints, "csv", params); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/export/blueprints?category_id=9&sort=-me", http.NoBody))
+	if buf.String() != rr.Body.String() {
+		t.Errorf("Export output differs from the endpoint:\n%s\nendpoint:\n%s", buf.String(), rr.Body)
+	}
+
+	if err := Export(context.Background(), q, &buf, "characters", "csv", nil); err == nil {
+		t.Error("Export of an unknown dataset: expected error")
+	}
+}
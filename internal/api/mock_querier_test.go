@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"time"
 
 	"github.com/dpleshakov/auspex/internal/store"
 )
@@ -29,6 +30,41 @@ type mockQuerier struct {
 	CountReadyJobsFn         func(ctx context.Context) (int64, error)
 	ListCharacterSlotUsageFn func(ctx context.Context) ([]store.ListCharacterSlotUsageRow, error)
 	ListSyncStatusFn         func(ctx context.Context) ([]store.ListSyncStatusRow, error)
+
+	ListBlueprintEventsSinceFn   func(ctx context.Context, since time.Time) ([]store.ListBlueprintEventsSinceRow, error)
+	ListAffiliationEventsSinceFn func(ctx context.Context, since time.Time) ([]store.AffiliationEvent, error)
+
+	ClearCharacterTransferredFn func(ctx context.Context, id int64) error
+
+	SearchIndexFn func(ctx context.Context, arg store.SearchIndexParams) ([]store.SearchIndexRow, error)
+
+	CreateCalendarFeedFn     func(ctx context.Context, arg store.CreateCalendarFeedParams) (store.CalendarFeed, error)
+	ListCalendarFeedsFn      func(ctx context.Context) ([]store.CalendarFeed, error)
+	GetCalendarFeedByTokenFn func(ctx context.Context, token string) (store.CalendarFeed, error)
+	DeleteCalendarFeedFn     func(ctx context.Context, id int64) (int64, error)
+
+	CreateWebhookFn         func(ctx context.Context, arg store.CreateWebhookParams) (store.Webhook, error)
+	GetWebhookFn            func(ctx context.Context, id int64) (store.Webhook, error)
+	ListWebhooksFn          func(ctx context.Context) ([]store.Webhook, error)
+	UpdateWebhookFn         func(ctx context.Context, arg store.UpdateWebhookParams) (store.Webhook, error)
+	SetWebhookSecretFn      func(ctx context.Context, arg store.SetWebhookSecretParams) (store.Webhook, error)
+	DeleteWebhookFn         func(ctx context.Context, id int64) (int64, error)
+	CreateWebhookDeliveryFn func(ctx context.Context, arg store.CreateWebhookDeliveryParams) (store.WebhookDelivery, error)
+	ListWebhookDeliveriesFn func(ctx context.Context, arg store.ListWebhookDeliveriesParams) ([]store.WebhookDelivery, error)
+
+	GetPushKeysFn            func(ctx context.Context) (store.PushKey, error)
+	InsertPushKeysFn         func(ctx context.Context, arg store.InsertPushKeysParams) error
+	UpsertPushSubscriptionFn func(ctx context.Context, arg store.UpsertPushSubscriptionParams) (store.PushSubscription, error)
+	ListPushSubscriptionsFn  func(ctx context.Context) ([]store.PushSubscription, error)
+	DeletePushSubscriptionFn func(ctx context.Context, id int64) (int64, error)
+
+	CreateAlertRuleFn        func(ctx context.Context, arg store.CreateAlertRuleParams) (store.AlertRule, error)
+	GetAlertRuleFn           func(ctx context.Context, id int64) (store.AlertRule, error)
+	ListAlertRulesFn         func(ctx context.Context) ([]store.AlertRule, error)
+	UpdateAlertRuleFn        func(ctx context.Context, arg store.UpdateAlertRuleParams) (store.AlertRule, error)
+	DeleteAlertRuleFn        func(ctx context.Context, id int64) (int64, error)
+	ListAlertHistoryFn       func(ctx context.Context, limit int64) ([]store.AlertHistory, error)
+	ListAlertHistoryByRuleFn func(ctx context.Context, arg store.ListAlertHistoryByRuleParams) ([]store.AlertHistory, error)
 }
 
 func (m *mockQuerier) ListCharacters(ctx context.Context) ([]store.Character, error) {
@@ -141,6 +177,14 @@ func (m *mockQuerier) GetEveType(_ context.Context, _ int64) (store.EveType, err
 	return store.EveType{}, nil
 }
 
+func (m *mockQuerier) GetEveGroup(_ context.Context, _ int64) (store.EveGroup, error) {
+	return store.EveGroup{}, nil
+}
+
+func (m *mockQuerier) GetEveCategory(_ context.Context, _ int64) (store.EveCategory, error) {
+	return store.EveCategory{}, nil
+}
+
 func (m *mockQuerier) GetLocation(_ context.Context, _ int64) (store.EveLocation, error) {
 	return store.EveLocation{}, nil
 }
@@ -180,6 +224,33 @@ func (m *mockQuerier) ListBlueprints(ctx context.Context, arg store.ListBlueprin
 	return nil, nil
 }
 
+// ListBlueprintPage returns the rows of ListBlueprintsFn as they are, at most
+// arg.Limit of them: filtering and sorting are tested against SQLite.
+func (m *mockQuerier) ListBlueprintPage(ctx context.Context, arg store.BlueprintPageParams) ([]store.ListBlueprintsRow, error) {
+	rows, err := m.ListBlueprints(ctx, store.ListBlueprintsParams{})
+	if arg.Limit > 0 && len(rows) > arg.Limit {
+		rows = rows[:arg.Limit]
+	}
+	return rows, err
+}
+
+func (m *mockQuerier) CountBlueprintMatches(ctx context.Context, _ store.BlueprintFilter) (int64, error) {
+	rows, err := m.ListBlueprints(ctx, store.ListBlueprintsParams{})
+	return int64(len(rows)), err
+}
+
+func (m *mockQuerier) ListBlueprintOwnerCounts(_ context.Context) ([]store.ListBlueprintOwnerCountsRow, error) {
+	return nil, nil
+}
+
+func (m *mockQuerier) ListBlueprintCategoryCounts(_ context.Context) ([]store.ListBlueprintCategoryCountsRow, error) {
+	return nil, nil
+}
+
+func (m *mockQuerier) ListBlueprintRegionCounts(_ context.Context) ([]store.ListBlueprintRegionCountsRow, error) {
+	return nil, nil
+}
+
 func (m *mockQuerier) ListCharacterSlotUsage(ctx context.Context) ([]store.ListCharacterSlotUsageRow, error) {
 	if m.ListCharacterSlotUsageFn != nil {
 		return m.ListCharacterSlotUsageFn(ctx)
@@ -187,7 +258,7 @@ func (m *mockQuerier) ListCharacterSlotUsage(ctx context.Context) ([]store.ListC
 	return nil, nil
 }
 
-func (m *mockQuerier) ListJobIDsByOwner(_ context.Context, _ store.ListJobIDsByOwnerParams) ([]int64, error) {
+func (m *mockQuerier) ListJobsByOwner(_ context.Context, _ store.ListJobsByOwnerParams) ([]store.Job, error) {
 	return nil, nil
 }
 
@@ -198,6 +269,88 @@ func (m *mockQuerier) ListSyncStatus(ctx context.Context) ([]store.ListSyncStatu
 	return nil, nil
 }
 
+func (m *mockQuerier) MarkCharacterNeedsReauth(_ context.Context, _ int64) error {
+	return nil
+}
+
+func (m *mockQuerier) MarkCharacterTransferred(_ context.Context, _ int64) error {
+	return nil
+}
+
+func (m *mockQuerier) ClearCharacterTransferred(ctx context.Context, id int64) error {
+	if m.ClearCharacterTransferredFn != nil {
+		return m.ClearCharacterTransferredFn(ctx, id)
+	}
+	return nil
+}
+
+func (m *mockQuerier) GetTokenEncryption(_ context.Context) (store.TokenEncryption, error) {
+	return store.TokenEncryption{}, nil
+}
+
+func (m *mockQuerier) ListCharacterTokens(_ context.Context) ([]store.ListCharacterTokensRow, error) {
+	return nil, nil
+}
+
+func (m *mockQuerier) SetCharacterTokenColumns(_ context.Context, _ store.SetCharacterTokenColumnsParams) error {
+	return nil
+}
+
+func (m *mockQuerier) UpsertTokenEncryption(_ context.Context, _ store.UpsertTokenEncryptionParams) error {
+	return nil
+}
+
+func (m *mockQuerier) ListAffiliationEventsSince(ctx context.Context, since time.Time) ([]store.AffiliationEvent, error) {
+	if m.ListAffiliationEventsSinceFn != nil {
+		return m.ListAffiliationEventsSinceFn(ctx, since)
+	}
+	return nil, nil
+}
+
+func (m *mockQuerier) InsertAffiliationEvent(_ context.Context, _ store.InsertAffiliationEventParams) error {
+	return nil
+}
+
+func (m *mockQuerier) UpdateCharacterAffiliation(_ context.Context, _ store.UpdateCharacterAffiliationParams) error {
+	return nil
+}
+
+func (m *mockQuerier) AdoptCorporation(_ context.Context, _ store.AdoptCorporationParams) error {
+	return nil
+}
+
+func (m *mockQuerier) OrphanCorporation(_ context.Context, _ int64) error {
+	return nil
+}
+
+func (m *mockQuerier) SetCorporationActiveCharacter(_ context.Context, _ store.SetCorporationActiveCharacterParams) error {
+	return nil
+}
+
+func (m *mockQuerier) UpdateCharacterRoles(_ context.Context, _ store.UpdateCharacterRolesParams) error {
+	return nil
+}
+
+func (m *mockQuerier) GetEveSystem(_ context.Context, _ int64) (store.EveSystem, error) {
+	return store.EveSystem{}, nil
+}
+func (m *mockQuerier) UpsertEveSystem(_ context.Context, _ store.UpsertEveSystemParams) error {
+	return nil
+}
+func (m *mockQuerier) ListStructureAccess(_ context.Context, _ int64) ([]store.StructureAccess, error) {
+	return nil, nil
+}
+func (m *mockQuerier) UpsertStructureAccess(_ context.Context, _ store.UpsertStructureAccessParams) error {
+	return nil
+}
+func (m *mockQuerier) UpdateCharacterTokens(_ context.Context, _ store.UpdateCharacterTokensParams) error {
+	return nil
+}
+
+func (m *mockQuerier) UpdateSyncStateMissingScope(_ context.Context, _ store.UpdateSyncStateMissingScopeParams) error {
+	return nil
+}
+
 func (m *mockQuerier) UpsertBlueprint(_ context.Context, _ store.UpsertBlueprintParams) error {
 	return nil
 }
@@ -223,16 +376,290 @@ func (m *mockQuerier) UpdateSyncStateError(_ context.Context, _ store.UpdateSync
 	return nil
 }
 
-func (m *mockQuerier) DeleteCorpAssetsByOwner(_ context.Context, _ int64) error { return nil }
+func (m *mockQuerier) DeleteAssetsByOwner(_ context.Context, _ store.DeleteAssetsByOwnerParams) error {
+	return nil
+}
+
+func (m *mockQuerier) GetAsset(_ context.Context, _ int64) (store.Asset, error) {
+	return store.Asset{}, nil
+}
+
+func (m *mockQuerier) DeleteCorpDivisions(_ context.Context, _ int64) error { return nil }
 
-func (m *mockQuerier) GetCorpAsset(_ context.Context, _ int64) (store.GetCorpAssetRow, error) {
-	return store.GetCorpAssetRow{}, nil
+func (m *mockQuerier) GetCorpDivisionName(_ context.Context, _ store.GetCorpDivisionNameParams) (string, error) {
+	return "", nil
+}
+
+func (m *mockQuerier) UpsertCorpDivision(_ context.Context, _ store.UpsertCorpDivisionParams) error {
+	return nil
+}
+
+func (m *mockQuerier) DeleteBlueprintLocations(_ context.Context, _ store.DeleteBlueprintLocationsParams) error {
+	return nil
+}
+
+func (m *mockQuerier) UpsertBlueprintLocations(_ context.Context, _ store.UpsertBlueprintLocationsParams) error {
+	return nil
 }
 
 func (m *mockQuerier) ListBlueprintLocationsByOwner(_ context.Context, _ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
 	return nil, nil
 }
 
-func (m *mockQuerier) UpsertCorpAsset(_ context.Context, _ store.UpsertCorpAssetParams) error {
+func (m *mockQuerier) UpsertAsset(_ context.Context, _ store.UpsertAssetParams) error {
+	return nil
+}
+
+func (m *mockQuerier) DeleteBlueprintByID(_ context.Context, _ int64) error { return nil }
+
+func (m *mockQuerier) DeleteJobsByBlueprintID(_ context.Context, _ int64) error { return nil }
+
+func (m *mockQuerier) GetBlueprint(_ context.Context, _ int64) (store.Blueprint, error) {
+	return store.Blueprint{}, nil
+}
+
+func (m *mockQuerier) GetBlueprintTypeName(_ context.Context, _ int64) (string, error) {
+	return "", nil
+}
+
+func (m *mockQuerier) InsertBlueprintEvent(_ context.Context, _ store.InsertBlueprintEventParams) error {
+	return nil
+}
+
+func (m *mockQuerier) InsertBlueprintRemoval(_ context.Context, _ store.InsertBlueprintRemovalParams) error {
 	return nil
 }
+
+func (m *mockQuerier) GetBlueprintRemoval(_ context.Context, _ int64) (store.BlueprintRemoval, error) {
+	return store.BlueprintRemoval{}, nil
+}
+
+func (m *mockQuerier) ListBlueprintRemovals(_ context.Context) ([]store.BlueprintRemoval, error) {
+	return nil, nil
+}
+
+func (m *mockQuerier) DeleteBlueprintRemoval(_ context.Context, _ int64) error {
+	return nil
+}
+
+func (m *mockQuerier) ListBlueprintEventsSince(ctx context.Context, since time.Time) ([]store.ListBlueprintEventsSinceRow, error) {
+	if m.ListBlueprintEventsSinceFn != nil {
+		return m.ListBlueprintEventsSinceFn(ctx, since)
+	}
+	return nil, nil
+}
+
+func (m *mockQuerier) ListBlueprintsByOwner(_ context.Context, _ store.ListBlueprintsByOwnerParams) ([]store.Blueprint, error) {
+	return nil, nil
+}
+
+func (m *mockQuerier) ClearSearchIndex(_ context.Context) error  { return nil }
+func (m *mockQuerier) IndexBlueprints(_ context.Context) error   { return nil }
+func (m *mockQuerier) IndexCharacters(_ context.Context) error   { return nil }
+func (m *mockQuerier) IndexCorporations(_ context.Context) error { return nil }
+func (m *mockQuerier) IndexLocations(_ context.Context) error    { return nil }
+
+func (m *mockQuerier) SearchIndex(ctx context.Context, arg store.SearchIndexParams) ([]store.SearchIndexRow, error) {
+	if m.SearchIndexFn != nil {
+		return m.SearchIndexFn(ctx, arg)
+	}
+	return nil, nil
+}
+
+func (m *mockQuerier) CreateCalendarFeed(ctx context.Context, arg store.CreateCalendarFeedParams) (store.CalendarFeed, error) {
+	if m.CreateCalendarFeedFn != nil {
+		return m.CreateCalendarFeedFn(ctx, arg)
+	}
+	return store.CalendarFeed{}, nil
+}
+
+func (m *mockQuerier) ListCalendarFeeds(ctx context.Context) ([]store.CalendarFeed, error) {
+	if m.ListCalendarFeedsFn != nil {
+		return m.ListCalendarFeedsFn(ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockQuerier) GetCalendarFeedByToken(ctx context.Context, token string) (store.CalendarFeed, error) {
+	if m.GetCalendarFeedByTokenFn != nil {
+		return m.GetCalendarFeedByTokenFn(ctx, token)
+	}
+	return store.CalendarFeed{}, nil
+}
+
+func (m *mockQuerier) DeleteCalendarFeed(ctx context.Context, id int64) (int64, error) {
+	if m.DeleteCalendarFeedFn != nil {
+		return m.DeleteCalendarFeedFn(ctx, id)
+	}
+	return 0, nil
+}
+
+func (m *mockQuerier) GetNotification(_ context.Context, _ store.GetNotificationParams) (store.NotificationLog, error) {
+	return store.NotificationLog{}, nil
+}
+func (m *mockQuerier) RecordNotification(_ context.Context, _ store.RecordNotificationParams) error {
+	return nil
+}
+func (m *mockQuerier) DeleteNotification(_ context.Context, _ store.DeleteNotificationParams) error {
+	return nil
+}
+func (m *mockQuerier) DeleteNotificationsBefore(_ context.Context, _ time.Time) error { return nil }
+func (m *mockQuerier) MarkIdleBlueprints(_ context.Context, _ time.Time) error        { return nil }
+func (m *mockQuerier) ClearBusyBlueprints(_ context.Context) error                    { return nil }
+func (m *mockQuerier) ListIdleBlueprintsSince(_ context.Context, _ time.Time) ([]store.BlueprintIdle, error) {
+	return nil, nil
+}
+
+func (m *mockQuerier) CreateWebhook(ctx context.Context, arg store.CreateWebhookParams) (store.Webhook, error) {
+	if m.CreateWebhookFn != nil {
+		return m.CreateWebhookFn(ctx, arg)
+	}
+	return store.Webhook{}, nil
+}
+
+func (m *mockQuerier) GetWebhook(ctx context.Context, id int64) (store.Webhook, error) {
+	if m.GetWebhookFn != nil {
+		return m.GetWebhookFn(ctx, id)
+	}
+	return store.Webhook{}, nil
+}
+
+func (m *mockQuerier) ListWebhooks(ctx context.Context) ([]store.Webhook, error) {
+	if m.ListWebhooksFn != nil {
+		return m.ListWebhooksFn(ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockQuerier) UpdateWebhook(ctx context.Context, arg store.UpdateWebhookParams) (store.Webhook, error) {
+	if m.UpdateWebhookFn != nil {
+		return m.UpdateWebhookFn(ctx, arg)
+	}
+	return store.Webhook{}, nil
+}
+
+func (m *mockQuerier) SetWebhookSecret(ctx context.Context, arg store.SetWebhookSecretParams) (store.Webhook, error) {
+	if m.SetWebhookSecretFn != nil {
+		return m.SetWebhookSecretFn(ctx, arg)
+	}
+	return store.Webhook{}, nil
+}
+
+func (m *mockQuerier) DeleteWebhook(ctx context.Context, id int64) (int64, error) {
+	if m.DeleteWebhookFn != nil {
+		return m.DeleteWebhookFn(ctx, id)
+	}
+	return 0, nil
+}
+
+func (m *mockQuerier) CreateWebhookDelivery(ctx context.Context, arg store.CreateWebhookDeliveryParams) (store.WebhookDelivery, error) {
+	if m.CreateWebhookDeliveryFn != nil {
+		return m.CreateWebhookDeliveryFn(ctx, arg)
+	}
+	return store.WebhookDelivery{}, nil
+}
+
+func (m *mockQuerier) ListWebhookDeliveries(ctx context.Context, arg store.ListWebhookDeliveriesParams) ([]store.WebhookDelivery, error) {
+	if m.ListWebhookDeliveriesFn != nil {
+		return m.ListWebhookDeliveriesFn(ctx, arg)
+	}
+	return nil, nil
+}
+
+func (m *mockQuerier) DeleteWebhookDeliveriesBefore(_ context.Context, _ time.Time) error { return nil }
+
+func (m *mockQuerier) GetPushKeys(ctx context.Context) (store.PushKey, error) {
+	if m.GetPushKeysFn != nil {
+		return m.GetPushKeysFn(ctx)
+	}
+	return store.PushKey{}, nil
+}
+
+func (m *mockQuerier) InsertPushKeys(ctx context.Context, arg store.InsertPushKeysParams) error {
+	if m.InsertPushKeysFn != nil {
+		return m.InsertPushKeysFn(ctx, arg)
+	}
+	return nil
+}
+
+func (m *mockQuerier) UpsertPushSubscription(ctx context.Context, arg store.UpsertPushSubscriptionParams) (store.PushSubscription, error) {
+	if m.UpsertPushSubscriptionFn != nil {
+		return m.UpsertPushSubscriptionFn(ctx, arg)
+	}
+	return store.PushSubscription{}, nil
+}
+
+func (m *mockQuerier) ListPushSubscriptions(ctx context.Context) ([]store.PushSubscription, error) {
+	if m.ListPushSubscriptionsFn != nil {
+		return m.ListPushSubscriptionsFn(ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockQuerier) DeletePushSubscription(ctx context.Context, id int64) (int64, error) {
+	if m.DeletePushSubscriptionFn != nil {
+		return m.DeletePushSubscriptionFn(ctx, id)
+	}
+	return 0, nil
+}
+
+func (m *mockQuerier) DeletePushSubscriptionByEndpoint(_ context.Context, _ string) error { return nil }
+
+func (m *mockQuerier) CreateAlertRule(ctx context.Context, arg store.CreateAlertRuleParams) (store.AlertRule, error) {
+	if m.CreateAlertRuleFn != nil {
+		return m.CreateAlertRuleFn(ctx, arg)
+	}
+	return store.AlertRule{}, nil
+}
+
+func (m *mockQuerier) GetAlertRule(ctx context.Context, id int64) (store.AlertRule, error) {
+	if m.GetAlertRuleFn != nil {
+		return m.GetAlertRuleFn(ctx, id)
+	}
+	return store.AlertRule{}, nil
+}
+
+func (m *mockQuerier) ListAlertRules(ctx context.Context) ([]store.AlertRule, error) {
+	if m.ListAlertRulesFn != nil {
+		return m.ListAlertRulesFn(ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockQuerier) UpdateAlertRule(ctx context.Context, arg store.UpdateAlertRuleParams) (store.AlertRule, error) {
+	if m.UpdateAlertRuleFn != nil {
+		return m.UpdateAlertRuleFn(ctx, arg)
+	}
+	return store.AlertRule{}, nil
+}
+
+func (m *mockQuerier) DeleteAlertRule(ctx context.Context, id int64) (int64, error) {
+	if m.DeleteAlertRuleFn != nil {
+		return m.DeleteAlertRuleFn(ctx, id)
+	}
+	return 0, nil
+}
+
+func (m *mockQuerier) ListAlertHistory(ctx context.Context, limit int64) ([]store.AlertHistory, error) {
+	if m.ListAlertHistoryFn != nil {
+		return m.ListAlertHistoryFn(ctx, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockQuerier) ListAlertHistoryByRule(ctx context.Context, arg store.ListAlertHistoryByRuleParams) ([]store.AlertHistory, error) {
+	if m.ListAlertHistoryByRuleFn != nil {
+		return m.ListAlertHistoryByRuleFn(ctx, arg)
+	}
+	return nil, nil
+}
+
+func (m *mockQuerier) CreateAlertHistory(_ context.Context, _ store.CreateAlertHistoryParams) error {
+	return nil
+}
+
+func (m *mockQuerier) GetLastAlertFiring(_ context.Context, _ store.GetLastAlertFiringParams) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (m *mockQuerier) DeleteAlertHistoryBefore(_ context.Context, _ time.Time) error { return nil }
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"crypto/subtle"
 	"errors"
 	"net/http"
 
@@ -11,17 +12,39 @@ import (
 //
 //	GET /auth/eve/login
 //	GET /auth/eve/callback?code=...&state=...
+//	GET /auth/eve/callback?error=...      (login canceled or refused by EVE SSO)
+
+// stateCookiePrefix names the cookies that bind OAuth states to the browser
+// that started the login: the callback is only accepted from a browser that
+// presents the cookie of its state. Each state has its own cookie, so that
+// logins started in parallel (in two tabs, or adding a character while
+// another logs in) do not overwrite each other's.
+const stateCookiePrefix = "auspex_oauth_state_"
 
 func (r *router) handleLogin(w http.ResponseWriter, req *http.Request) {
-	url, err := r.auth.GenerateAuthURL()
+	url, state, err := r.auth.GenerateAuthURL()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to generate authorization URL")
 		return
 	}
+	setStateCookie(w, req, state, int(auth.StateTTL.Seconds()))
 	http.Redirect(w, req, url, http.StatusFound)
 }
 
 func (r *router) handleCallback(w http.ResponseWriter, req *http.Request) {
+	// EVE SSO redirects back with ?error=access_denied when the user cancels.
+	if ssoErr := req.URL.Query().Get("error"); ssoErr != "" {
+		if state := req.URL.Query().Get("state"); state != "" {
+			setStateCookie(w, req, state, -1)
+		}
+		reason := "sso_error"
+		if ssoErr == "access_denied" {
+			reason = "canceled"
+		}
+		http.Redirect(w, req, "/?auth_error="+reason, http.StatusFound)
+		return
+	}
+
 	code := req.URL.Query().Get("code")
 	state := req.URL.Query().Get("state")
 
@@ -30,7 +53,14 @@ func (r *router) handleCallback(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	_, err := r.auth.HandleCallback(req.Context(), code, state)
+	cookie, err := req.Cookie(stateCookieName(state))
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		writeError(w, http.StatusBadRequest, "OAuth state was not issued to this browser")
+		return
+	}
+	setStateCookie(w, req, state, -1)
+
+	_, err = r.auth.HandleCallback(req.Context(), code, state)
 	if err != nil {
 		if errors.Is(err, auth.ErrInvalidState) {
 			writeError(w, http.StatusBadRequest, "invalid or expired OAuth state")
@@ -43,3 +73,23 @@ func (r *router) handleCallback(w http.ResponseWriter, req *http.Request) {
 	r.worker.ForceRefresh()
 	http.Redirect(w, req, "/", http.StatusFound)
 }
+
+// stateCookieName returns the name of the cookie of state.
+func stateCookieName(state string) string {
+	return stateCookiePrefix + state
+}
+
+// setStateCookie sets the cookie of state for maxAge seconds; a negative
+// maxAge deletes it. SameSite=Lax lets the cookie through on the top-level
+// redirect back from EVE SSO.
+func setStateCookie(w http.ResponseWriter, req *http.Request, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookieName(state),
+		Value:    state,
+		Path:     "/auth/eve/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
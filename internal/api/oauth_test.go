@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -12,15 +13,15 @@ import (
 
 // mockAuthProvider implements AuthProvider for tests.
 type mockAuthProvider struct {
-	GenerateAuthURLFn func() (string, error)
+	GenerateAuthURLFn func() (string, string, error)
 	HandleCallbackFn  func(ctx context.Context, code, state string) (int64, error)
 }
 
-func (m *mockAuthProvider) GenerateAuthURL() (string, error) {
+func (m *mockAuthProvider) GenerateAuthURL() (string, string, error) {
 	if m.GenerateAuthURLFn != nil {
 		return m.GenerateAuthURLFn()
 	}
-	return "https://login.eveonline.com/auth?state=teststate", nil
+	return "https://login.eveonline.com/auth?state=teststate", "teststate", nil
 }
 
 func (m *mockAuthProvider) HandleCallback(ctx context.Context, code, state string) (int64, error) {
@@ -35,10 +36,10 @@ func (m *mockAuthProvider) HandleCallback(ctx context.Context, code, state strin
 func TestHandleLogin_RedirectsToEVESSO(t *testing.T) {
 	authURL := "https://login.eveonline.com/v2/oauth/authorize?state=abc"
 	mux := NewRouter(&mockQuerier{}, nil, &mockAuthProvider{
-		GenerateAuthURLFn: func() (string, error) {
-			return authURL, nil
+		GenerateAuthURLFn: func() (string, string, error) {
+			return authURL, "abc", nil
 		},
-	}, testFS())
+	}, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/auth/eve/login", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -54,10 +55,10 @@ func TestHandleLogin_RedirectsToEVESSO(t *testing.T) {
 
 func TestHandleLogin_500WhenGenerateFails(t *testing.T) {
 	mux := NewRouter(&mockQuerier{}, nil, &mockAuthProvider{
-		GenerateAuthURLFn: func() (string, error) {
-			return "", errors.New("rng failure")
+		GenerateAuthURLFn: func() (string, string, error) {
+			return "", "", errors.New("rng failure")
 		},
-	}, testFS())
+	}, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/auth/eve/login", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -71,17 +72,48 @@ func TestHandleLogin_500WhenGenerateFails(t *testing.T) {
 	}
 }
 
+func TestHandleLogin_SetsStateCookie(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, &mockAuthProvider{}, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/eve/login", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	c := cookies[0]
+	if c.Name != stateCookiePrefix+"teststate" || c.Value != "teststate" {
+		t.Errorf("cookie = %s=%s, want %steststate=teststate", c.Name, c.Value, stateCookiePrefix)
+	}
+	if !c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+		t.Errorf("cookie HttpOnly=%v SameSite=%v, want HttpOnly and Lax", c.HttpOnly, c.SameSite)
+	}
+	if c.MaxAge <= 0 || c.MaxAge > int(auth.StateTTL.Seconds()) {
+		t.Errorf("cookie MaxAge = %d, want (0, %d]", c.MaxAge, int(auth.StateTTL.Seconds()))
+	}
+}
+
 // --- GET /auth/eve/callback ---
 
+// callbackRequest returns a callback request carrying the state cookie set by
+// the login handler.
+func callbackRequest(url, cookieState string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, url, http.NoBody)
+	req.AddCookie(&http.Cookie{Name: stateCookieName(cookieState), Value: cookieState})
+	return req
+}
+
 func TestHandleCallback_ValidStateRedirectsToRoot(t *testing.T) {
 	worker := &mockWorker{}
 	mux := NewRouter(&mockQuerier{}, worker, &mockAuthProvider{
 		HandleCallbackFn: func(_ context.Context, _, _ string) (int64, error) {
 			return 12345, nil
 		},
-	}, testFS())
+	}, nil, testFS())
 
-	req := httptest.NewRequest(http.MethodGet, "/auth/eve/callback?code=mycode&state=mystate", http.NoBody)
+	req := callbackRequest("/auth/eve/callback?code=mycode&state=mystate", "mystate")
 	rr := httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
 
@@ -98,9 +130,9 @@ func TestHandleCallback_InvalidStateReturns400(t *testing.T) {
 		HandleCallbackFn: func(_ context.Context, _, _ string) (int64, error) {
 			return 0, auth.ErrInvalidState
 		},
-	}, testFS())
+	}, nil, testFS())
 
-	req := httptest.NewRequest(http.MethodGet, "/auth/eve/callback?code=mycode&state=badstate", http.NoBody)
+	req := callbackRequest("/auth/eve/callback?code=mycode&state=badstate", "badstate")
 	rr := httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
 
@@ -110,7 +142,7 @@ func TestHandleCallback_InvalidStateReturns400(t *testing.T) {
 }
 
 func TestHandleCallback_MissingParams_Returns400(t *testing.T) {
-	mux := NewRouter(&mockQuerier{}, nil, &mockAuthProvider{}, testFS())
+	mux := NewRouter(&mockQuerier{}, nil, &mockAuthProvider{}, nil, testFS())
 
 	for _, url := range []string{
 		"/auth/eve/callback",
@@ -132,9 +164,9 @@ func TestHandleCallback_CallbackError_Returns500(t *testing.T) {
 		HandleCallbackFn: func(_ context.Context, _, _ string) (int64, error) {
 			return 0, errors.New("token exchange failed")
 		},
-	}, testFS())
+	}, nil, testFS())
 
-	req := httptest.NewRequest(http.MethodGet, "/auth/eve/callback?code=x&state=y", http.NoBody)
+	req := callbackRequest("/auth/eve/callback?code=x&state=y", "y")
 	rr := httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
 
@@ -149,9 +181,9 @@ func TestHandleCallback_SuccessTriggersForceRefresh(t *testing.T) {
 		HandleCallbackFn: func(_ context.Context, _, _ string) (int64, error) {
 			return 12345, nil
 		},
-	}, testFS())
+	}, nil, testFS())
 
-	req := httptest.NewRequest(http.MethodGet, "/auth/eve/callback?code=mycode&state=mystate", http.NoBody)
+	req := callbackRequest("/auth/eve/callback?code=mycode&state=mystate", "mystate")
 	rr := httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
 
@@ -162,3 +194,88 @@ func TestHandleCallback_SuccessTriggersForceRefresh(t *testing.T) {
 		t.Error("expected ForceRefresh to be called after successful OAuth callback")
 	}
 }
+
+// TestHandleCallback_StateCookieMismatch verifies that a callback is rejected
+// when the browser does not hold the state cookie for that state.
+func TestHandleCallback_StateCookieMismatch(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, &mockWorker{}, &mockAuthProvider{
+		HandleCallbackFn: func(_ context.Context, _, _ string) (int64, error) {
+			t.Error("HandleCallback must not be called without a matching state cookie")
+			return 0, nil
+		},
+	}, nil, testFS())
+
+	for name, req := range map[string]*http.Request{
+		"no cookie":    httptest.NewRequest(http.MethodGet, "/auth/eve/callback?code=x&state=mystate", http.NoBody),
+		"other cookie": callbackRequest("/auth/eve/callback?code=x&state=mystate", "otherstate"),
+	} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rr.Code)
+		}
+	}
+}
+
+// TestHandleCallback_ParallelLogins verifies that starting a second login
+// before the first one returns does not invalidate the first one's state.
+func TestHandleCallback_ParallelLogins(t *testing.T) {
+	n := 0
+	var handled []string
+	mux := NewRouter(&mockQuerier{}, &mockWorker{}, &mockAuthProvider{
+		GenerateAuthURLFn: func() (string, string, error) {
+			n++
+			state := fmt.Sprintf("state%d", n)
+			return "https://login.eveonline.com/auth?state=" + state, state, nil
+		},
+		HandleCallbackFn: func(_ context.Context, _, state string) (int64, error) {
+			handled = append(handled, state)
+			return 12345, nil
+		},
+	}, nil, testFS())
+
+	// Two logins from the same browser, e.g. in two tabs.
+	var cookies []*http.Cookie
+	for range 2 {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/auth/eve/login", http.NoBody))
+		cookies = append(cookies, rr.Result().Cookies()...)
+	}
+
+	for _, state := range []string{"state1", "state2"} {
+		req := httptest.NewRequest(http.MethodGet, "/auth/eve/callback?code=x&state="+state, http.NoBody)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusFound {
+			t.Errorf("callback for %s: expected 302, got %d", state, rr.Code)
+		}
+	}
+	if len(handled) != 2 {
+		t.Errorf("HandleCallback called for %v, want both states", handled)
+	}
+}
+
+// TestHandleCallback_SSOErrorRedirects verifies that EVE SSO errors (the user
+// canceling the login) redirect to the dashboard with a message code.
+func TestHandleCallback_SSOErrorRedirects(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, &mockAuthProvider{}, nil, testFS())
+
+	for query, want := range map[string]string{
+		"error=access_denied&state=mystate": "/?auth_error=canceled",
+		"error=server_error":                "/?auth_error=sso_error",
+	} {
+		req := callbackRequest("/auth/eve/callback?"+query, "mystate")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusFound {
+			t.Fatalf("%s: expected 302, got %d", query, rr.Code)
+		}
+		if got := rr.Header().Get("Location"); got != want {
+			t.Errorf("%s: Location = %q, want %q", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/push"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// Web Push notifications go to the browsers that subscribed through the
+// dashboard's service worker. The browser subscribes at its push service with
+// the server's VAPID public key, then registers the subscription here. See
+// package push for what is sent and when.
+
+// maxUserAgent is the number of bytes of the User-Agent kept with a
+// subscription, to tell subscriptions apart.
+const maxUserAgent = 256
+
+type pushSubscriptionJSON struct {
+	ID        int64     `json:"id"`
+	Endpoint  string    `json:"endpoint"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// createPushSubscriptionRequest is the browser's PushSubscription.toJSON().
+type createPushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// Handles:
+//
+//	GET    /api/push/key
+//	GET    /api/push/subscriptions
+//	POST   /api/push/subscriptions
+//	DELETE /api/push/subscriptions/{id}
+//	POST   /api/push/test
+func (r *router) handleGetPushKey(w http.ResponseWriter, req *http.Request) {
+	keys, err := push.LoadKeys(req.Context(), r.q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get push key")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"public_key": keys.PublicKey()})
+}
+
+func (r *router) handleGetPushSubscriptions(w http.ResponseWriter, req *http.Request) {
+	subs, err := r.q.ListPushSubscriptions(req.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list push subscriptions")
+		return
+	}
+	resp := make([]pushSubscriptionJSON, len(subs))
+	for i, s := range subs {
+		resp[i] = newPushSubscriptionJSON(s)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleCreatePushSubscription registers a browser's subscription. A
+// subscription to an endpoint already registered replaces its keys.
+func (r *router) handleCreatePushSubscription(w http.ResponseWriter, req *http.Request) {
+	var body createPushSubscriptionRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	u, err := url.Parse(body.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, http.StatusBadRequest, "endpoint must be an http or https URL")
+		return
+	}
+	// Some browsers pad the keys.
+	p256dh := strings.TrimRight(body.Keys.P256dh, "=")
+	auth := strings.TrimRight(body.Keys.Auth, "=")
+	if err := push.CheckKeys(p256dh, auth); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userAgent := req.UserAgent()
+	if len(userAgent) > maxUserAgent {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgent], "")
+	}
+
+	sub, err := r.q.UpsertPushSubscription(req.Context(), store.UpsertPushSubscriptionParams{
+		Endpoint:  body.Endpoint,
+		P256dh:    p256dh,
+		Auth:      auth,
+		UserAgent: userAgent,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save push subscription")
+		return
+	}
+	writeJSON(w, http.StatusCreated, newPushSubscriptionJSON(sub))
+}
+
+func (r *router) handleDeletePushSubscription(w http.ResponseWriter, req *http.Request) {
+	id, err := parseID(req, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid push subscription id")
+		return
+	}
+	n, err := r.q.DeletePushSubscription(req.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete push subscription")
+		return
+	}
+	if n == 0 {
+		writeError(w, http.StatusNotFound, "push subscription not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTestPush sends a test notification to every subscription and returns
+// how many it reached. Failed pushes are still a 200.
+func (r *router) handleTestPush(w http.ResponseWriter, req *http.Request) {
+	if r.push == nil {
+		writeError(w, http.StatusServiceUnavailable, "push notifications not available")
+		return
+	}
+	res, err := r.push.Send(req.Context(), push.Notification{
+		Title: "Auspex",
+		Body:  "Notifications are working.",
+		Tag:   "test",
+		URL:   "/",
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to send push notifications")
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func newPushSubscriptionJSON(s store.PushSubscription) pushSubscriptionJSON {
+	return pushSubscriptionJSON{
+		ID:        s.ID,
+		Endpoint:  s.Endpoint,
+		UserAgent: s.UserAgent,
+		CreatedAt: s.CreatedAt,
+	}
+}
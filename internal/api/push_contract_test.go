@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContract_Push_Lifecycle(t *testing.T) {
+	// A local stand-in for the browser's push service.
+	var pushes []http.Header
+	pushService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		pushes = append(pushes, r.Header)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer pushService.Close()
+	srv := newContractServer(t, newContractDB(t))
+
+	keyResp, err := http.Get(srv.URL + "/api/push/key")
+	if err != nil {
+		t.Fatalf("GET /api/push/key: %v", err)
+	}
+	defer func() { _ = keyResp.Body.Close() }()
+	var key map[string]any
+	if err := json.NewDecoder(keyResp.Body).Decode(&key); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	assertField[string](t, key, "public_key")
+	publicKey, _ := key["public_key"].(string)
+	if raw, err := base64.RawURLEncoding.DecodeString(publicKey); err != nil || len(raw) != 65 {
+		t.Errorf("public_key %q is not an unpadded base64url P-256 point", publicKey)
+	}
+
+	p256dh, auth := testPushKeys(t)
+	create := `{"endpoint":"` + pushService.URL + `/send/1","keys":{"p256dh":"` + p256dh + `","auth":"` + auth + `"}}`
+	resp, err := http.Post(srv.URL+"/api/push/subscriptions", "application/json", strings.NewReader(create))
+	if err != nil {
+		t.Fatalf("POST /api/push/subscriptions: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	var sub map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&sub); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	assertField[float64](t, sub, "id")
+	assertField[string](t, sub, "endpoint")
+	assertField[string](t, sub, "user_agent")
+	assertField[string](t, sub, "created_at")
+
+	test, err := http.Post(srv.URL+"/api/push/test", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST /api/push/test: %v", err)
+	}
+	defer func() { _ = test.Body.Close() }()
+	var result map[string]any
+	if err := json.NewDecoder(test.Body).Decode(&result); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	assertField[float64](t, result, "sent")
+	if result["sent"] != 1.0 {
+		t.Errorf("sent = %v, want 1", result["sent"])
+	}
+	assertField[float64](t, result, "failed")
+	assertField[float64](t, result, "expired")
+	if len(pushes) != 1 {
+		t.Fatalf("push service received %d pushes, want 1", len(pushes))
+	}
+	if got := pushes[0].Get("Content-Encoding"); got != "aes128gcm" {
+		t.Errorf("Content-Encoding = %q", got)
+	}
+	if got := pushes[0].Get("Authorization"); !strings.HasSuffix(got, ", k="+publicKey) {
+		t.Errorf("Authorization = %q, want a VAPID token with the public key", got)
+	}
+
+	subURL := srv.URL + "/api/push/subscriptions/" + jsonNumber(sub["id"])
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		req, _ := http.NewRequest(http.MethodDelete, subURL, nil)
+		del, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("DELETE: %v", err)
+		}
+		_ = del.Body.Close()
+		if del.StatusCode != want {
+			t.Errorf("DELETE: expected %d, got %d", want, del.StatusCode)
+		}
+	}
+}
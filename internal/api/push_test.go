@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// testPushKeys returns browser-side subscription keys, padded as some
+// browsers send them.
+func testPushKeys(t *testing.T) (p256dh, auth string) {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	secret := make([]byte, 16)
+	_, _ = rand.Read(secret)
+	return base64.URLEncoding.EncodeToString(key.PublicKey().Bytes()), base64.URLEncoding.EncodeToString(secret)
+}
+
+func TestCreatePushSubscription(t *testing.T) {
+	var got store.UpsertPushSubscriptionParams
+	mux := NewRouter(&mockQuerier{
+		UpsertPushSubscriptionFn: func(_ context.Context, arg store.UpsertPushSubscriptionParams) (store.PushSubscription, error) {
+			got = arg
+			return store.PushSubscription{ID: 4, Endpoint: arg.Endpoint, UserAgent: arg.UserAgent}, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	p256dh, auth := testPushKeys(t)
+	body := `{"endpoint":"https://push.example/send/abc","expirationTime":null,"keys":{"p256dh":"` + p256dh + `","auth":"` + auth + `"}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/push/subscriptions", strings.NewReader(body))
+	req.Header.Set("User-Agent", "Firefox")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+	}
+	if got.Endpoint != "https://push.example/send/abc" || got.UserAgent != "Firefox" {
+		t.Errorf("stored endpoint, user agent = %q, %q", got.Endpoint, got.UserAgent)
+	}
+	if strings.HasSuffix(got.P256dh, "=") || strings.HasSuffix(got.Auth, "=") {
+		t.Errorf("stored keys %q, %q keep their padding", got.P256dh, got.Auth)
+	}
+	if strings.Contains(rr.Body.String(), got.Auth) {
+		t.Errorf("response %s exposes the auth secret", rr.Body)
+	}
+}
+
+func TestCreatePushSubscription_Invalid(t *testing.T) {
+	mux := NewRouter(&mockQuerier{
+		UpsertPushSubscriptionFn: func(_ context.Context, _ store.UpsertPushSubscriptionParams) (store.PushSubscription, error) {
+			t.Error("subscription saved despite an invalid request")
+			return store.PushSubscription{}, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	p256dh, auth := testPushKeys(t)
+	for _, body := range []string{
+		`{"endpoint":"ftp://push.example","keys":{"p256dh":"` + p256dh + `","auth":"` + auth + `"}}`,
+		`{"endpoint":"https://push.example","keys":{"p256dh":"AAAA","auth":"` + auth + `"}}`,
+		`{"endpoint":"https://push.example","keys":{"p256dh":"` + p256dh + `","auth":"c2hvcnQ"}}`,
+		`{"endpoint":"https://push.example"}`,
+		`not json`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/push/subscriptions", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestDeletePushSubscription_NotFound(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/push/subscriptions/9", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestTestPush_NoService(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/push/test", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rr.Code)
+	}
+}
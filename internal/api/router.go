@@ -9,11 +9,15 @@ import (
 	"context"
 	"io/fs"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/push"
 	"github.com/dpleshakov/auspex/internal/store"
+	"github.com/dpleshakov/auspex/internal/webhook"
 )
 
 // WorkerRefresher is the interface the api package uses to communicate with the sync worker.
@@ -23,22 +27,52 @@ type WorkerRefresher interface {
 
 // AuthProvider is the interface the api package uses for EVE SSO OAuth2 operations.
 type AuthProvider interface {
-	GenerateAuthURL() (string, error)
+	GenerateAuthURL() (url, state string, err error)
 	HandleCallback(ctx context.Context, code, state string) (int64, error)
 }
 
+// EventSource is the interface the api package uses to stream sync worker events.
+type EventSource interface {
+	Subscribe(lastEventID uint64) (sub *events.Subscription, replay []events.Event, complete bool)
+}
+
 // router holds shared dependencies for all HTTP handlers.
 type router struct {
 	q      store.Querier
 	worker WorkerRefresher
 	auth   AuthProvider
+	events EventSource
+
+	webhooks *webhook.Dispatcher // sends test events; nil if not configured (see WithWebhooks)
+	push     *push.Service       // sends test notifications; nil if not configured (see WithPush)
+
+	heartbeat time.Duration // /api/events heartbeat interval; eventsHeartbeat if zero
+}
+
+// Option configures the router built by NewRouter.
+type Option func(*router)
+
+// WithWebhooks makes POST /api/webhooks/{id}/test send through d, the
+// dispatcher main runs. Without it, the endpoint responds 503.
+func WithWebhooks(d *webhook.Dispatcher) Option {
+	return func(rt *router) { rt.webhooks = d }
+}
+
+// WithPush makes POST /api/push/test send through s, the service main runs.
+// Without it, the endpoint responds 503.
+func WithPush(s *push.Service) Option {
+	return func(rt *router) { rt.push = s }
 }
 
 // NewRouter assembles and returns the application Chi router.
 // staticFS must be rooted at the frontend dist directory ("index.html" at top level).
 // In production, pass fs.Sub(staticFiles, "web/dist") from main.go.
-func NewRouter(q store.Querier, worker WorkerRefresher, authProv AuthProvider, staticFS fs.FS) *chi.Mux {
-	rt := &router{q: q, worker: worker, auth: authProv}
+// With a nil eventSrc, /api/events responds 503.
+func NewRouter(q store.Querier, worker WorkerRefresher, authProv AuthProvider, eventSrc EventSource, staticFS fs.FS, opts ...Option) *chi.Mux {
+	rt := &router{q: q, worker: worker, auth: authProv, events: eventSrc}
+	for _, opt := range opts {
+		opt(rt)
+	}
 
 	mux := chi.NewRouter()
 	mux.Use(middleware.Logger)
@@ -49,7 +83,9 @@ func NewRouter(q store.Querier, worker WorkerRefresher, authProv AuthProvider, s
 		api.Use(jsonContentType)
 
 		api.Get("/characters", rt.handleGetCharacters)
+		api.Get("/characters/changes", rt.handleGetCharacterChanges)
 		api.Delete("/characters/{id}", rt.handleDeleteCharacter)
+		api.Post("/characters/{id}/confirm-transfer", rt.handleConfirmTransfer)
 
 		api.Get("/corporations", rt.handleGetCorporations)
 		api.Post("/corporations", rt.handleAddCorporation)
@@ -57,16 +93,54 @@ func NewRouter(q store.Querier, worker WorkerRefresher, authProv AuthProvider, s
 		api.Patch("/corporations/{id}/delegate", rt.handlePatchCorporationDelegate)
 
 		api.Get("/blueprints", rt.handleGetBlueprints)
+		api.Get("/blueprints/changes", rt.handleGetBlueprintChanges)
 		api.Get("/jobs/summary", rt.handleGetJobsSummary)
 
+		api.Get("/search", rt.handleGetSearch)
+
+		api.Get("/export/{dataset}", rt.handleGetExport)
+
+		api.Get("/calendar/feeds", rt.handleGetCalendarFeeds)
+		api.Post("/calendar/feeds", rt.handleCreateCalendarFeed)
+		api.Delete("/calendar/feeds/{id}", rt.handleDeleteCalendarFeed)
+
+		api.Get("/webhooks", rt.handleGetWebhooks)
+		api.Post("/webhooks", rt.handleCreateWebhook)
+		api.Get("/webhooks/{id}", rt.handleGetWebhook)
+		api.Put("/webhooks/{id}", rt.handleUpdateWebhook)
+		api.Delete("/webhooks/{id}", rt.handleDeleteWebhook)
+		api.Get("/webhooks/{id}/deliveries", rt.handleGetWebhookDeliveries)
+		api.Post("/webhooks/{id}/secret", rt.handleRotateWebhookSecret)
+		api.Post("/webhooks/{id}/test", rt.handleTestWebhook)
+
+		api.Get("/push/key", rt.handleGetPushKey)
+		api.Get("/push/subscriptions", rt.handleGetPushSubscriptions)
+		api.Post("/push/subscriptions", rt.handleCreatePushSubscription)
+		api.Delete("/push/subscriptions/{id}", rt.handleDeletePushSubscription)
+		api.Post("/push/test", rt.handleTestPush)
+
+		api.Get("/alerts", rt.handleGetAlertRules)
+		api.Post("/alerts", rt.handleCreateAlertRule)
+		api.Get("/alerts/history", rt.handleGetAlertHistory)
+		api.Get("/alerts/{id}", rt.handleGetAlertRule)
+		api.Put("/alerts/{id}", rt.handleUpdateAlertRule)
+		api.Delete("/alerts/{id}", rt.handleDeleteAlertRule)
+		api.Get("/alerts/{id}/matches", rt.handleGetAlertMatches)
+
 		api.Post("/sync", rt.handlePostSync)
 		api.Get("/sync/status", rt.handleGetSyncStatus)
+
+		api.Get("/events", rt.handleGetEvents)
 	})
 
 	// EVE SSO OAuth2 routes — implemented in TASK-17.
 	mux.Get("/auth/eve/login", rt.handleLogin)
 	mux.Get("/auth/eve/callback", rt.handleCallback)
 
+	// iCalendar feed of job completions, outside /api: it is not JSON and is
+	// fetched by calendar apps rather than the frontend.
+	mux.Get("/calendar.ics", rt.handleGetCalendar)
+
 	// All remaining routes serve the embedded React SPA.
 	mux.Handle("/*", newSPAHandler(staticFS))
 
@@ -90,7 +164,7 @@ func jsonContentType(next http.Handler) http.Handler {
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -91,7 +91,7 @@ func TestPanicRecovery(t *testing.T) {
 // --- Assembled router: Content-Type on /api routes ---
 
 func TestRouter_APIContentType(t *testing.T) {
-	mux := NewRouter(&mockQuerier{}, nil, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/characters", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -105,7 +105,7 @@ func TestRouter_APIContentType(t *testing.T) {
 // --- Assembled router: static file serving ---
 
 func TestRouter_StaticKnownFile(t *testing.T) {
-	mux := NewRouter(nil, nil, nil, testFS())
+	mux := NewRouter(nil, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/assets/app.js", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -117,7 +117,7 @@ func TestRouter_StaticKnownFile(t *testing.T) {
 }
 
 func TestRouter_SPAFallback(t *testing.T) {
-	mux := NewRouter(nil, nil, nil, testFS())
+	mux := NewRouter(nil, nil, nil, nil, testFS())
 
 	// /some/spa/route does not exist as a file → must return index.html.
 	req := httptest.NewRequest(http.MethodGet, "/some/spa/route", http.NoBody)
@@ -133,7 +133,7 @@ func TestRouter_SPAFallback(t *testing.T) {
 }
 
 func TestRouter_IndexHTML(t *testing.T) {
-	mux := NewRouter(nil, nil, nil, testFS())
+	mux := NewRouter(nil, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+
+	// searchMarkStart and searchMarkEnd are the markers SearchIndex wraps
+	// matched terms in (char(2) and char(3) in search.sql).
+	searchMarkStart = "\x02"
+	searchMarkEnd   = "\x03"
+)
+
+type searchResultJSON struct {
+	Query  string            `json:"query"`
+	Groups []searchGroupJSON `json:"groups"`
+}
+
+type searchGroupJSON struct {
+	Type  string          `json:"type"`
+	Total int64           `json:"total"`
+	Hits  []searchHitJSON `json:"hits"`
+}
+
+// searchHitJSON holds the indexed text of one entity as HTML: escaped, with
+// the matched terms wrapped in <mark> elements.
+type searchHitJSON struct {
+	ID       int64   `json:"id"`
+	Name     string  `json:"name"`
+	Detail   string  `json:"detail"`
+	Location string  `json:"location"`
+	Owner    string  `json:"owner"`
+	Rank     float64 `json:"rank"`
+}
+
+// Handles:
+//
+//	GET /api/search  (query params: q, required; limit, hits per type, default 10, max 50)
+//
+// Searches blueprints, characters, corporations, and locations in the full-text
+// index the sync worker rebuilds each cycle. Every word of q must match the
+// start of a word in the indexed text; there is no substring or typo-tolerant
+// matching. Hits are grouped by entity type, best group first, and ranked best
+// first within each group.
+func (r *router) handleGetSearch(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "missing q")
+		return
+	}
+	limit := defaultSearchLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	result := searchResultJSON{Query: q, Groups: []searchGroupJSON{}}
+	match := ftsQuery(q)
+	if match == "" {
+		// Nothing but punctuation: no word can match.
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	rows, err := r.q.SearchIndex(req.Context(), store.SearchIndexParams{Query: match, PerType: int64(limit)})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to search")
+		return
+	}
+
+	// Rows come best first, so groups are created in the order of their best hit.
+	groups := make(map[string]int)
+	for _, row := range rows {
+		i, ok := groups[row.EntityType]
+		if !ok {
+			i = len(result.Groups)
+			groups[row.EntityType] = i
+			result.Groups = append(result.Groups, searchGroupJSON{Type: row.EntityType, Total: row.Total})
+		}
+		result.Groups[i].Hits = append(result.Groups[i].Hits, searchHitJSON{
+			ID:       row.EntityID,
+			Name:     highlightHTML(row.NameHighlight),
+			Detail:   highlightHTML(row.DetailHighlight),
+			Location: highlightHTML(row.LocationHighlight),
+			Owner:    highlightHTML(row.OwnerHighlight),
+			Rank:     row.Rank,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// ftsQuery turns free text into an FTS5 query that matches entries containing
+// every word as a prefix. Each word is quoted, so FTS5 operators and column
+// filters in the input are searched for literally rather than interpreted.
+// Words without letters or digits are dropped; the result is empty if none
+// remain.
+func ftsQuery(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		if !strings.ContainsFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+			continue
+		}
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// highlightHTML escapes s for HTML and turns the SearchIndex match markers
+// into <mark> elements.
+func highlightHTML(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, searchMarkStart, "<mark>")
+	return strings.ReplaceAll(s, searchMarkEnd, "</mark>")
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// rebuildSearchIndex repopulates search_index the way the sync worker does at
+// the end of a cycle.
+func rebuildSearchIndex(t *testing.T, sqlDB *sql.DB) {
+	t.Helper()
+	q := store.New(sqlDB)
+	ctx := context.Background()
+	for _, step := range []func(context.Context) error{
+		q.ClearSearchIndex, q.IndexBlueprints, q.IndexCharacters, q.IndexCorporations, q.IndexLocations,
+	} {
+		if err := step(ctx); err != nil {
+			t.Fatalf("rebuilding search index: %v", err)
+		}
+	}
+}
+
+// seedSearchLibrary stores a character and a corporation, each owning a
+// blueprint in a resolved Jita station.
+func seedSearchLibrary(t *testing.T, sqlDB *sql.DB) {
+	t.Helper()
+	seedCharacter(t, sqlDB, 3001, "Drake Lover", 0)
+	seedCorporation(t, sqlDB, 4001, "Caldari Shipwrights", 3001)
+	seedBlueprint(t, sqlDB, BlueprintSeed{ID: 9001, OwnerID: 3001, TypeID: 11, GroupID: 21, CategoryID: 9, LocationID: 60003760})
+	seedBlueprint(t, sqlDB, BlueprintSeed{ID: 9002, OwnerType: "corporation", OwnerID: 4001, TypeID: 12, GroupID: 21, CategoryID: 9, LocationID: 60003760})
+	for _, stmt := range []string{
+		`UPDATE eve_types SET name = 'Drake Blueprint' WHERE id = 11`,
+		`UPDATE eve_types SET name = 'Raven Blueprint' WHERE id = 12`,
+		`UPDATE eve_groups SET name = 'Battlecruiser Blueprint' WHERE id = 21`,
+		`INSERT INTO eve_systems (id, name, security_status, constellation_id, constellation_name, region_id, region_name, resolved_at)
+		 VALUES (30000142, 'Jita', 0.9, 20000020, 'Kimotoro', 10000002, 'The Forge', CURRENT_TIMESTAMP)`,
+		`INSERT INTO eve_locations (id, name, solar_system_id, resolved_at)
+		 VALUES (60003760, 'Jita IV - Moon 4 - Caldari Navy Assembly Plant', 30000142, CURRENT_TIMESTAMP)`,
+		`INSERT INTO blueprint_locations (blueprint_id, root_location_id, path) VALUES
+		 (9001, 60003760, ''), (9002, 60003760, 'Corp Hangar 2 (Research)')`,
+	} {
+		if _, err := sqlDB.Exec(stmt); err != nil {
+			t.Fatalf("seedSearchLibrary: %v", err)
+		}
+	}
+}
+
+// getSearch calls /api/search with text and decodes the result.
+func getSearch(t *testing.T, srvURL, text string) searchResultJSON {
+	t.Helper()
+	resp, err := http.Get(srvURL + "/api/search?q=" + url.QueryEscape(text))
+	if err != nil {
+		t.Fatalf("GET /api/search: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("q=%q: expected 200, got %d", text, resp.StatusCode)
+	}
+	var result searchResultJSON
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	return result
+}
+
+// searchHits returns "type:id" for every hit in result, in order.
+func searchHits(result searchResultJSON) []string {
+	var out []string
+	for _, g := range result.Groups {
+		for _, h := range g.Hits {
+			out = append(out, g.Type+":"+strconv.FormatInt(h.ID, 10))
+		}
+	}
+	return out
+}
+
+func TestContract_Search_RanksAndGroupsEntities(t *testing.T) {
+	sqlDB := newContractDB(t)
+	seedSearchLibrary(t, sqlDB)
+	rebuildSearchIndex(t, sqlDB)
+	srv := newContractServer(t, sqlDB)
+
+	for text, want := range map[string][]string{
+		// want[0] is the best hit. A name match outranks owner and location
+		// matches; among name matches, the entry with less text wins.
+		"drake":          {"character:3001", "blueprint:9001"},
+		"dra":            {"character:3001", "blueprint:9001"},
+		"caldari":        {"corporation:4001", "location:60003760", "blueprint:9002", "blueprint:9001"},
+		"raven research": {"blueprint:9002"},
+		"forge":          {"location:60003760"},
+		"battlecruiser":  {"blueprint:9001", "blueprint:9002"},
+		"drake raven":    nil,
+		// Prefixes only: no substring or typo-tolerant matching.
+		"rake":  nil,
+		"drkae": nil,
+	} {
+		got := getSearch(t, srv.URL, text)
+		hits := searchHits(got)
+		slices.Sort(hits)
+		sorted := slices.Sorted(slices.Values(want))
+		if !slices.Equal(hits, sorted) {
+			t.Errorf("q=%q: hits = %v, want %v", text, searchHits(got), want)
+		}
+		if len(want) > 0 && got.Groups[0].Type+":"+strconv.FormatInt(got.Groups[0].Hits[0].ID, 10) != want[0] {
+			t.Errorf("q=%q: best hit = %v, want %s", text, searchHits(got), want[0])
+		}
+	}
+}
+
+func TestContract_Search_Highlights(t *testing.T) {
+	sqlDB := newContractDB(t)
+	seedSearchLibrary(t, sqlDB)
+	rebuildSearchIndex(t, sqlDB)
+	srv := newContractServer(t, sqlDB)
+
+	got := getSearch(t, srv.URL, "raven hangar")
+	if len(got.Groups) != 1 || len(got.Groups[0].Hits) != 1 {
+		t.Fatalf("groups = %+v, want a single blueprint hit", got.Groups)
+	}
+	hit := got.Groups[0].Hits[0]
+	if hit.Name != "<mark>Raven</mark> Blueprint" {
+		t.Errorf("name = %q", hit.Name)
+	}
+	if want := "Jita IV - Moon 4 - Caldari Navy Assembly Plant › Corp <mark>Hangar</mark> 2 (Research)"; hit.Location != want {
+		t.Errorf("location = %q, want %q", hit.Location, want)
+	}
+	if hit.Owner != "Caldari Shipwrights" || hit.Detail != "Battlecruiser Blueprint Category" {
+		t.Errorf("owner = %q, detail = %q", hit.Owner, hit.Detail)
+	}
+}
+
+func TestContract_Search_SkipsDeletedEntities(t *testing.T) {
+	sqlDB := newContractDB(t)
+	seedSearchLibrary(t, sqlDB)
+	rebuildSearchIndex(t, sqlDB)
+	srv := newContractServer(t, sqlDB)
+
+	// Deleted through the API between two sync cycles.
+	if _, err := sqlDB.Exec(`DELETE FROM blueprints WHERE id = 9001`); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchHits(getSearch(t, srv.URL, "drake")); !slices.Equal(got, []string{"character:3001"}) {
+		t.Errorf("hits = %v, want only the character", got)
+	}
+}
+
+func TestContract_Search_OperatorsSearchedLiterally(t *testing.T) {
+	sqlDB := newContractDB(t)
+	seedSearchLibrary(t, sqlDB)
+	rebuildSearchIndex(t, sqlDB)
+	srv := newContractServer(t, sqlDB)
+
+	for _, text := range []string{`"drake`, "drake OR raven", "name:drake", "drake*", "NEAR(drake raven)", "-", "drake NOT"} {
+		getSearch(t, srv.URL, text) // fails the test unless 200
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+func TestGetSearch_GroupsHitsByType(t *testing.T) {
+	var captured store.SearchIndexParams
+	q := &mockQuerier{
+		SearchIndexFn: func(_ context.Context, arg store.SearchIndexParams) ([]store.SearchIndexRow, error) {
+			captured = arg
+			return []store.SearchIndexRow{
+				{EntityType: "blueprint", EntityID: 9001, NameHighlight: "\x02Drake\x03 Blueprint", Rank: -5, Total: 3},
+				{EntityType: "character", EntityID: 3001, NameHighlight: "\x02Drake\x03 <Pilot>", Rank: -4, Total: 1},
+				{EntityType: "blueprint", EntityID: 9002, NameHighlight: "Navy \x02Drake\x03", OwnerHighlight: "Alpha", Rank: -3, Total: 3},
+			}, nil
+		},
+	}
+	mux := NewRouter(q, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/search?q=drake&limit=2", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if captured.Query != `"drake"*` || captured.PerType != 2 {
+		t.Errorf("SearchIndex params = %+v, want query \"drake\"* per type 2", captured)
+	}
+
+	var got searchResultJSON
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Groups) != 2 || got.Groups[0].Type != "blueprint" || got.Groups[1].Type != "character" {
+		t.Fatalf("groups = %+v, want blueprint then character", got.Groups)
+	}
+	bp := got.Groups[0]
+	if bp.Total != 3 || len(bp.Hits) != 2 || bp.Hits[0].ID != 9001 || bp.Hits[1].ID != 9002 {
+		t.Errorf("blueprint group = %+v, want total 3 and hits 9001, 9002", bp)
+	}
+	if want := "<mark>Drake</mark> &lt;Pilot&gt;"; got.Groups[1].Hits[0].Name != want {
+		t.Errorf("character name = %q, want %q", got.Groups[1].Hits[0].Name, want)
+	}
+}
+
+func TestGetSearch_NoHits(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/search?q=nothing", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if got := rr.Body.String(); got != `{"query":"nothing","groups":[]}`+"\n" {
+		t.Errorf("body = %s, want empty groups", got)
+	}
+}
+
+func TestGetSearch_InvalidQuery(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
+
+	for _, query := range []string{"", "q=", "q=%20%20", "q=x&limit=0", "q=x&limit=51", "q=x&limit=ten"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/search?"+query, http.NoBody)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestGetSearch_StoreError_Returns500(t *testing.T) {
+	q := &mockQuerier{
+		SearchIndexFn: func(context.Context, store.SearchIndexParams) ([]store.SearchIndexRow, error) {
+			return nil, errors.New("db error")
+		},
+	}
+	mux := NewRouter(q, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/search?q=drake", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", rr.Code)
+	}
+}
+
+func TestFTSQuery(t *testing.T) {
+	for text, want := range map[string]string{
+		"drake":             `"drake"*`,
+		"  caldari   navy ": `"caldari"* "navy"*`,
+		`name:x OR "y`:      `"name:x"* "OR"* """y"*`,
+		"jita-4 - iv":       `"jita-4"* "iv"*`,
+		"- * ()":            "",
+		"Ämarr":             `"Ämarr"*`,
+	} {
+		if got := ftsQuery(text); got != want {
+			t.Errorf("ftsQuery(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
@@ -5,13 +5,23 @@ import (
 	"time"
 )
 
+// sync status values reported per subject+endpoint.
+const (
+	syncStatusOK           = "ok"
+	syncStatusError        = "error"
+	syncStatusMissingScope = "missing_scope"
+)
+
 type syncStatusItemJSON struct {
-	OwnerType  string    `json:"owner_type"`
-	OwnerID    int64     `json:"owner_id"`
-	OwnerName  string    `json:"owner_name"`
-	Endpoint   string    `json:"endpoint"`
-	LastSync   time.Time `json:"last_sync"`
-	CacheUntil time.Time `json:"cache_until"`
+	OwnerType    string    `json:"owner_type"`
+	OwnerID      int64     `json:"owner_id"`
+	OwnerName    string    `json:"owner_name"`
+	Endpoint     string    `json:"endpoint"`
+	LastSync     time.Time `json:"last_sync"`
+	CacheUntil   time.Time `json:"cache_until"`
+	Status       string    `json:"status"`
+	LastError    *string   `json:"last_error"`
+	MissingScope *string   `json:"missing_scope"`
 }
 
 // Handles:
@@ -32,14 +42,24 @@ func (r *router) handleGetSyncStatus(w http.ResponseWriter, req *http.Request) {
 
 	items := make([]syncStatusItemJSON, 0, len(rows))
 	for _, row := range rows {
-		items = append(items, syncStatusItemJSON{
+		item := syncStatusItemJSON{
 			OwnerType:  row.OwnerType,
 			OwnerID:    row.OwnerID,
 			OwnerName:  row.OwnerName,
 			Endpoint:   row.Endpoint,
 			LastSync:   row.LastSync,
 			CacheUntil: row.CacheUntil,
-		})
+			Status:     syncStatusOK,
+		}
+		switch {
+		case row.MissingScope.Valid:
+			item.Status = syncStatusMissingScope
+			item.MissingScope = &row.MissingScope.String
+		case row.LastError.Valid:
+			item.Status = syncStatusError
+			item.LastError = &row.LastError.String
+		}
+		items = append(items, item)
 	}
 
 	writeJSON(w, http.StatusOK, items)
@@ -138,6 +138,9 @@ func TestContract_GetSyncStatus_WithData(t *testing.T) {
 	assertField[string](t, item, "endpoint")
 	assertField[string](t, item, "last_sync")
 	assertField[string](t, item, "cache_until")
+	assertField[string](t, item, "status")
+	assertNull(t, item, "last_error")
+	assertNull(t, item, "missing_scope")
 }
 
 func TestContract_PostSync_Returns202(t *testing.T) {
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -25,7 +26,7 @@ func (m *mockWorker) ForceRefresh() {
 
 func TestPostSync_Returns202(t *testing.T) {
 	worker := &mockWorker{}
-	mux := NewRouter(&mockQuerier{}, worker, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, worker, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodPost, "/api/sync", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -38,7 +39,7 @@ func TestPostSync_Returns202(t *testing.T) {
 
 func TestPostSync_CallsForceRefresh(t *testing.T) {
 	worker := &mockWorker{}
-	mux := NewRouter(&mockQuerier{}, worker, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, worker, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodPost, "/api/sync", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -52,7 +53,7 @@ func TestPostSync_CallsForceRefresh(t *testing.T) {
 // --- GET /api/sync/status ---
 
 func TestGetSyncStatus_Empty(t *testing.T) {
-	mux := NewRouter(&mockQuerier{}, nil, nil, testFS())
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/sync/status", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -95,7 +96,7 @@ func TestGetSyncStatus_IncludesOwnerNames(t *testing.T) {
 				},
 			}, nil
 		},
-	}, nil, nil, testFS())
+	}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/sync/status", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -120,12 +121,48 @@ func TestGetSyncStatus_IncludesOwnerNames(t *testing.T) {
 	}
 }
 
+func TestGetSyncStatus_Status(t *testing.T) {
+	mux := NewRouter(&mockQuerier{
+		ListSyncStatusFn: func(_ context.Context) ([]store.ListSyncStatusRow, error) {
+			return []store.ListSyncStatusRow{
+				{OwnerType: "character", OwnerID: 1, Endpoint: "blueprints"},
+				{OwnerType: "character", OwnerID: 1, Endpoint: "jobs", LastError: sql.NullString{String: "esi: 502", Valid: true}},
+				{OwnerType: "corporation", OwnerID: 2, Endpoint: "jobs", MissingScope: sql.NullString{String: "esi-industry.read_corporation_jobs.v1", Valid: true}},
+			}, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sync/status", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var got []syncStatusItemJSON
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(got))
+	}
+	if got[0].Status != "ok" || got[0].LastError != nil || got[0].MissingScope != nil {
+		t.Errorf("item[0] = %+v, want status ok", got[0])
+	}
+	if got[1].Status != "error" || got[1].LastError == nil || *got[1].LastError != "esi: 502" {
+		t.Errorf("item[1] = %+v, want status error with last_error", got[1])
+	}
+	if got[2].Status != "missing_scope" || got[2].MissingScope == nil || *got[2].MissingScope != "esi-industry.read_corporation_jobs.v1" {
+		t.Errorf("item[2] = %+v, want status missing_scope", got[2])
+	}
+}
+
 func TestGetSyncStatus_DBError(t *testing.T) {
 	mux := NewRouter(&mockQuerier{
 		ListSyncStatusFn: func(_ context.Context) ([]store.ListSyncStatusRow, error) {
 			return nil, errors.New("db error")
 		},
-	}, nil, nil, testFS())
+	}, nil, nil, nil, testFS())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/sync/status", http.NoBody)
 	rr := httptest.NewRecorder()
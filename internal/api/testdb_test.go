@@ -1,14 +1,19 @@
 package api
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
+	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/dpleshakov/auspex/internal/db"
+	"github.com/dpleshakov/auspex/internal/push"
 	"github.com/dpleshakov/auspex/internal/store"
+	"github.com/dpleshakov/auspex/internal/webhook"
 )
 
 // --- Stubs ---
@@ -19,7 +24,7 @@ func (noopWorker) ForceRefresh() {}
 
 type noopAuth struct{}
 
-func (noopAuth) GenerateAuthURL() (string, error) { return "", nil }
+func (noopAuth) GenerateAuthURL() (string, string, error) { return "", "", nil }
 func (noopAuth) HandleCallback(_ context.Context, _, _ string) (int64, error) {
 	return 0, nil
 }
@@ -28,7 +33,7 @@ func (noopAuth) HandleCallback(_ context.Context, _, _ string) (int64, error) {
 
 // newContractDB opens an in-memory SQLite database and applies all migrations.
 // Fails the test immediately on any error.
-func newContractDB(t *testing.T) *sql.DB {
+func newContractDB(t testing.TB) *sql.DB {
 	t.Helper()
 	sqlDB, err := db.Open(":memory:")
 	if err != nil {
@@ -44,7 +49,7 @@ func newContractDB(t *testing.T) *sql.DB {
 func newContractServer(t *testing.T, sqlDB *sql.DB) *httptest.Server {
 	t.Helper()
 	q := store.New(sqlDB)
-	mux := NewRouter(q, noopWorker{}, noopAuth{}, testFS())
+	mux := NewRouter(q, noopWorker{}, noopAuth{}, nil, testFS(), WithWebhooks(webhook.New(q, nil)), WithPush(push.New(q, nil)))
 	srv := httptest.NewServer(mux)
 	t.Cleanup(srv.Close)
 	return srv
@@ -197,6 +202,86 @@ func seedJob(t *testing.T, sqlDB *sql.DB, j JobSeed) {
 	}
 }
 
+// seedLibrary inserts rows, as ListBlueprints would return them, with the
+// EVE types, owners, jobs, installers and locations they reference. Rows
+// without a type or group ID get one of their own; job IDs must be distinct.
+func seedLibrary(t testing.TB, sqlDB *sql.DB, rows []store.ListBlueprintsRow) {
+	t.Helper()
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := sqlDB.Exec(query, args...); err != nil {
+			t.Fatalf("seedLibrary: %v\n%s", err, query)
+		}
+	}
+	groups := map[string]int64{}
+	for _, row := range rows {
+		ownerType := cmp.Or(row.OwnerType, "character")
+		groupID := row.GroupID
+		if groupID == 0 {
+			key := fmt.Sprintf("%d/%s", row.CategoryID, row.GroupName)
+			if groups[key] == 0 {
+				groups[key] = int64(1000 + len(groups))
+			}
+			groupID = groups[key]
+		}
+		typeID := cmp.Or(row.TypeID, 100000+row.ID)
+		exec(`INSERT OR IGNORE INTO eve_categories (id, name) VALUES (?, ?)`, row.CategoryID, row.CategoryName)
+		exec(`INSERT OR IGNORE INTO eve_groups (id, category_id, name) VALUES (?, ?, ?)`, groupID, row.CategoryID, row.GroupName)
+		exec(`INSERT OR IGNORE INTO eve_types (id, group_id, name) VALUES (?, ?, ?)`, typeID, groupID, row.TypeName)
+
+		if row.OwnerName != "" {
+			if ownerType == "corporation" {
+				exec(`INSERT OR IGNORE INTO corporations (id, name) VALUES (?, ?)`, row.OwnerID, row.OwnerName)
+			} else {
+				exec(`INSERT OR IGNORE INTO characters (id, name, access_token, refresh_token, token_expiry, corporation_id, corporation_name)
+				      VALUES (?, ?, 'tok', 'rtok', ?, 0, '')`, row.OwnerID, row.OwnerName, time.Now().UTC())
+			}
+		}
+		exec(`INSERT INTO blueprints (id, owner_type, owner_id, type_id, location_id, me_level, te_level, updated_at)
+		      VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			row.ID, ownerType, row.OwnerID, typeID, row.LocationID, row.MeLevel, row.TeLevel, time.Now().UTC())
+
+		if row.JobID.Valid {
+			installerID := cmp.Or(row.JobInstallerID.Int64, 9000+row.ID)
+			if row.JobInstallerName.Valid {
+				exec(`INSERT OR IGNORE INTO characters (id, name, access_token, refresh_token, token_expiry, corporation_id, corporation_name)
+				      VALUES (?, ?, 'tok', 'rtok', ?, 0, '')`, installerID, row.JobInstallerName.String, time.Now().UTC())
+			}
+			end := row.JobEndDate.Time.UTC()
+			start := end.Add(-time.Hour)
+			if row.JobStartDate.Valid {
+				start = row.JobStartDate.Time.UTC()
+			}
+			exec(`INSERT INTO jobs (id, blueprint_id, owner_type, owner_id, installer_id, activity, status, start_date, end_date, updated_at)
+			      VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				row.JobID.Int64, row.ID, ownerType, row.OwnerID, installerID,
+				row.JobActivity.String, row.JobStatus.String, start, end, time.Now().UTC())
+		}
+
+		if row.LocationName.Valid || row.SystemID.Valid {
+			rootID := cmp.Or(row.LocationID, 60000000+row.ID)
+			if row.SystemName.Valid {
+				exec(`INSERT OR IGNORE INTO eve_systems (id, name, security_status, constellation_id, constellation_name, region_id, region_name, resolved_at)
+				      VALUES (?, ?, ?, 0, '', ?, ?, ?)`,
+					row.SystemID.Int64, row.SystemName.String, row.SecurityStatus.Float64, row.RegionID.Int64, row.RegionName.String, time.Now().UTC())
+			}
+			exec(`INSERT OR IGNORE INTO eve_locations (id, name, resolved_at, solar_system_id) VALUES (?, ?, ?, ?)`,
+				rootID, row.LocationName.String, time.Now().UTC(), row.SystemID)
+			exec(`INSERT INTO blueprint_locations (blueprint_id, root_location_id, path) VALUES (?, ?, ?)`,
+				row.ID, rootID, row.LocationPath.String)
+		}
+	}
+}
+
+// newLibraryRouter returns a router over a database holding rows, and the
+// database.
+func newLibraryRouter(t testing.TB, rows []store.ListBlueprintsRow) (http.Handler, *sql.DB) {
+	t.Helper()
+	sqlDB := newContractDB(t)
+	seedLibrary(t, sqlDB, rows)
+	return NewRouter(store.New(sqlDB), nil, nil, nil, testFS()), sqlDB
+}
+
 // --- Assertion helpers ---
 
 // assertField verifies key exists in m and its value has type T.
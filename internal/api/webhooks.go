@@ -0,0 +1,380 @@
+package api
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+	"github.com/dpleshakov/auspex/internal/webhook"
+)
+
+// Outgoing webhooks deliver events from the sync worker to other services:
+// chat bridges, ntfy, home-grown bots. See package webhook for the delivery,
+// the request headers and the signature.
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+type webhookJSON struct {
+	ID        int64             `json:"id"`
+	Name      string            `json:"name"`
+	URL       string            `json:"url"`
+	Events    []string          `json:"events"` // empty means every event type
+	Template  string            `json:"template"`
+	Headers   map[string]string `json:"headers"`
+	Secret    string            `json:"secret"` // masked but for the last 4 characters, except on create and rotate
+	Enabled   bool              `json:"enabled"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+type webhookRequest struct {
+	Name     string            `json:"name"`
+	URL      string            `json:"url"`
+	Events   []string          `json:"events"`
+	Template string            `json:"template"`
+	Headers  map[string]string `json:"headers"`
+	Enabled  *bool             `json:"enabled"` // default true
+}
+
+type webhookDeliveryJSON struct {
+	ID         int64     `json:"id"`
+	WebhookID  int64     `json:"webhook_id"`
+	EventID    int64     `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	Attempts   int64     `json:"attempts"`
+	StatusCode *int64    `json:"status_code"`
+	Error      *string   `json:"error"`
+	Response   string    `json:"response"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Handles:
+//
+//	GET    /api/webhooks
+//	POST   /api/webhooks
+//	GET    /api/webhooks/{id}
+//	PUT    /api/webhooks/{id}
+//	DELETE /api/webhooks/{id}
+//	GET    /api/webhooks/{id}/deliveries  (query param: limit, default 50, max 500)
+//	POST   /api/webhooks/{id}/secret
+//	POST   /api/webhooks/{id}/test
+func (r *router) handleGetWebhooks(w http.ResponseWriter, req *http.Request) {
+	hooks, err := r.q.ListWebhooks(req.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list webhooks")
+		return
+	}
+	resp := make([]webhookJSON, len(hooks))
+	for i, h := range hooks {
+		resp[i] = newWebhookJSON(h)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *router) handleCreateWebhook(w http.ResponseWriter, req *http.Request) {
+	body, ok := decodeWebhookRequest(w, req)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	hook, err := r.q.CreateWebhook(req.Context(), store.CreateWebhookParams{
+		Name:      body.Name,
+		Url:       body.URL,
+		Events:    webhook.FormatEvents(body.Events),
+		Template:  body.Template,
+		Headers:   encodeHeaders(body.Headers),
+		Secret:    rand.Text(),
+		Enabled:   boolInt(*body.Enabled),
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create webhook")
+		return
+	}
+	resp := newWebhookJSON(hook)
+	resp.Secret = hook.Secret // the one time it is shown, with rotation
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (r *router) handleGetWebhook(w http.ResponseWriter, req *http.Request) {
+	hook, ok := r.webhookByID(w, req)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, newWebhookJSON(hook))
+}
+
+// handleUpdateWebhook replaces the settings of a webhook. Its secret is kept.
+func (r *router) handleUpdateWebhook(w http.ResponseWriter, req *http.Request) {
+	id, err := parseID(req, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+	body, ok := decodeWebhookRequest(w, req)
+	if !ok {
+		return
+	}
+	hook, err := r.q.UpdateWebhook(req.Context(), store.UpdateWebhookParams{
+		Name:      body.Name,
+		Url:       body.URL,
+		Events:    webhook.FormatEvents(body.Events),
+		Template:  body.Template,
+		Headers:   encodeHeaders(body.Headers),
+		Enabled:   boolInt(*body.Enabled),
+		UpdatedAt: time.Now().UTC(),
+		ID:        id,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update webhook")
+		return
+	}
+	writeJSON(w, http.StatusOK, newWebhookJSON(hook))
+}
+
+// handleRotateWebhookSecret replaces the signing secret of a webhook with a new
+// random one and returns the webhook with it unmasked.
+func (r *router) handleRotateWebhookSecret(w http.ResponseWriter, req *http.Request) {
+	id, err := parseID(req, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+	hook, err := r.q.SetWebhookSecret(req.Context(), store.SetWebhookSecretParams{
+		Secret:    rand.Text(),
+		UpdatedAt: time.Now().UTC(),
+		ID:        id,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to rotate webhook secret")
+		return
+	}
+	resp := newWebhookJSON(hook)
+	resp.Secret = hook.Secret
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (r *router) handleDeleteWebhook(w http.ResponseWriter, req *http.Request) {
+	id, err := parseID(req, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+	n, err := r.q.DeleteWebhook(req.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete webhook")
+		return
+	}
+	if n == 0 {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetWebhookDeliveries returns the latest deliveries of a webhook,
+// newest first.
+func (r *router) handleGetWebhookDeliveries(w http.ResponseWriter, req *http.Request) {
+	limit := defaultDeliveriesLimit
+	if v := req.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeliveriesLimit {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	hook, ok := r.webhookByID(w, req)
+	if !ok {
+		return
+	}
+	rows, err := r.q.ListWebhookDeliveries(req.Context(), store.ListWebhookDeliveriesParams{
+		WebhookID: hook.ID,
+		Limit:     int64(limit),
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list webhook deliveries")
+		return
+	}
+	resp := make([]webhookDeliveryJSON, len(rows))
+	for i, d := range rows {
+		resp[i] = newWebhookDeliveryJSON(d)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleTestWebhook sends a sample event to a webhook — of the type in the
+// optional body's event_type, else a plain test event — and returns the
+// delivery. A failed delivery is still a 200; its error is in the delivery.
+func (r *router) handleTestWebhook(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		EventType string `json:"event_type"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if body.EventType != "" && body.EventType != webhook.TypeTest && !slices.Contains(events.Types, body.EventType) {
+		writeError(w, http.StatusBadRequest, "unknown event type "+body.EventType)
+		return
+	}
+	hook, ok := r.webhookByID(w, req)
+	if !ok {
+		return
+	}
+	if r.webhooks == nil {
+		writeError(w, http.StatusServiceUnavailable, "webhook delivery not available")
+		return
+	}
+	delivery, err := r.webhooks.Test(req.Context(), hook, body.EventType)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to record webhook delivery")
+		return
+	}
+	writeJSON(w, http.StatusOK, newWebhookDeliveryJSON(delivery))
+}
+
+// webhookByID returns the webhook in the id path parameter, or writes an
+// error response and returns false.
+func (r *router) webhookByID(w http.ResponseWriter, req *http.Request) (store.Webhook, bool) {
+	id, err := parseID(req, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook id")
+		return store.Webhook{}, false
+	}
+	hook, err := r.q.GetWebhook(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return store.Webhook{}, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get webhook")
+		return store.Webhook{}, false
+	}
+	return hook, true
+}
+
+// decodeWebhookRequest decodes and validates the body of a POST or PUT, or
+// writes an error response and returns false. Defaults are filled in: the
+// URL's host as name, and enabled.
+func decodeWebhookRequest(w http.ResponseWriter, req *http.Request) (webhookRequest, bool) {
+	var body webhookRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return body, false
+	}
+	u, err := url.Parse(body.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, http.StatusBadRequest, "url must be an http or https URL")
+		return body, false
+	}
+	if err := webhook.Validate(body.Events, body.Template, body.Headers); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return body, false
+	}
+
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		body.Name = u.Host
+	}
+	// Store event types once each, in the order of events.Types.
+	var types []string
+	for _, t := range events.Types {
+		if slices.Contains(body.Events, t) {
+			types = append(types, t)
+		}
+	}
+	body.Events = types
+	if body.Enabled == nil {
+		enabled := true
+		body.Enabled = &enabled
+	}
+	return body, true
+}
+
+func newWebhookJSON(h store.Webhook) webhookJSON {
+	headers, _ := webhook.ParseHeaders(h.Headers) // written by encodeHeaders
+	return webhookJSON{
+		ID:        h.ID,
+		Name:      h.Name,
+		URL:       h.Url,
+		Events:    webhook.ParseEvents(h.Events),
+		Template:  h.Template,
+		Headers:   headers,
+		Secret:    maskSecret(h.Secret),
+		Enabled:   h.Enabled != 0,
+		CreatedAt: h.CreatedAt,
+		UpdatedAt: h.UpdatedAt,
+	}
+}
+
+// maskSecret returns s with all but its last 4 characters replaced by "*",
+// enough to tell secrets apart without revealing them.
+func maskSecret(s string) string {
+	const shown = 4
+	if len(s) <= shown {
+		return strings.Repeat("*", len(s))
+	}
+	return strings.Repeat("*", len(s)-shown) + s[len(s)-shown:]
+}
+
+func newWebhookDeliveryJSON(d store.WebhookDelivery) webhookDeliveryJSON {
+	var status *int64
+	if d.StatusCode.Valid {
+		status = &d.StatusCode.Int64
+	}
+	var errText *string
+	if d.Error.Valid {
+		errText = &d.Error.String
+	}
+	return webhookDeliveryJSON{
+		ID:         d.ID,
+		WebhookID:  d.WebhookID,
+		EventID:    d.EventID,
+		EventType:  d.EventType,
+		Attempts:   d.Attempts,
+		StatusCode: status,
+		Error:      errText,
+		Response:   d.Response,
+		DurationMS: d.DurationMs,
+		CreatedAt:  d.CreatedAt,
+	}
+}
+
+func encodeHeaders(headers map[string]string) string {
+	if len(headers) == 0 {
+		return "{}"
+	}
+	b, _ := json.Marshal(headers) // a map of strings always encodes
+	return string(b)
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dpleshakov/auspex/internal/webhook"
+)
+
+func TestContract_Webhook_Lifecycle(t *testing.T) {
+	var gotBody string
+	var gotHeader http.Header
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody, gotHeader = string(b), r.Header
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+	srv := newContractServer(t, newContractDB(t))
+
+	create := `{"name":"Bot","url":"` + receiver.URL + `","events":["job_ready"],` +
+		`"template":"{\"job\": {{.Data.JobID}}}","headers":{"X-Token":"abc"}}`
+	resp, err := http.Post(srv.URL+"/api/webhooks", "application/json", strings.NewReader(create))
+	if err != nil {
+		t.Fatalf("POST /api/webhooks: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	var hook map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&hook); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	assertField[float64](t, hook, "id")
+	assertField[string](t, hook, "name")
+	assertField[string](t, hook, "url")
+	assertField[[]any](t, hook, "events")
+	assertField[string](t, hook, "template")
+	assertField[map[string]any](t, hook, "headers")
+	assertField[string](t, hook, "secret")
+	assertField[bool](t, hook, "enabled")
+	assertField[string](t, hook, "created_at")
+	assertField[string](t, hook, "updated_at")
+	base := srv.URL + "/api/webhooks/" + jsonNumber(hook["id"])
+
+	test, err := http.Post(base+"/test", "application/json", strings.NewReader(`{"event_type":"job_ready"}`))
+	if err != nil {
+		t.Fatalf("POST test: %v", err)
+	}
+	defer func() { _ = test.Body.Close() }()
+	if test.StatusCode != http.StatusOK {
+		t.Fatalf("test: expected 200, got %d", test.StatusCode)
+	}
+	var delivery map[string]any
+	if err := json.NewDecoder(test.Body).Decode(&delivery); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if delivery["status_code"] != float64(200) || delivery["error"] != nil || delivery["event_type"] != "job_ready" {
+		t.Errorf("delivery = %v", delivery)
+	}
+	if gotBody != `{"job": 500000001}` || gotHeader.Get("X-Token") != "abc" {
+		t.Errorf("received %q with headers %v", gotBody, gotHeader)
+	}
+	timestamp, _ := strconv.ParseInt(gotHeader.Get(webhook.HeaderTimestamp), 10, 64)
+	if !webhook.Verify(hook["secret"].(string), timestamp, []byte(gotBody), gotHeader.Get(webhook.HeaderSignature)) {
+		t.Error("signature does not verify with the webhook secret")
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, base, strings.NewReader(`{"name":"Bot","url":"`+receiver.URL+`","enabled":false}`))
+	put, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT: %v", err)
+	}
+	defer func() { _ = put.Body.Close() }()
+	var updated map[string]any
+	if err := json.NewDecoder(put.Body).Decode(&updated); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if updated["enabled"] != false || updated["secret"] != maskSecret(hook["secret"].(string)) || len(updated["events"].([]any)) != 0 {
+		t.Errorf("updated = %v", updated)
+	}
+
+	list, err := http.Get(base + "/deliveries")
+	if err != nil {
+		t.Fatalf("GET deliveries: %v", err)
+	}
+	defer func() { _ = list.Body.Close() }()
+	var deliveries []map[string]any
+	if err := json.NewDecoder(list.Body).Decode(&deliveries); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	for _, f := range []string{"id", "webhook_id", "event_id", "attempts", "duration_ms"} {
+		assertField[float64](t, deliveries[0], f)
+	}
+	assertField[string](t, deliveries[0], "response")
+	assertField[string](t, deliveries[0], "created_at")
+
+	req, _ = http.NewRequest(http.MethodDelete, base, http.NoBody)
+	del, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	_ = del.Body.Close()
+	if del.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", del.StatusCode)
+	}
+	gone, err := http.Get(base + "/deliveries")
+	if err != nil {
+		t.Fatalf("GET deliveries: %v", err)
+	}
+	_ = gone.Body.Close()
+	if gone.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted webhook: expected 404, got %d", gone.StatusCode)
+	}
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	var got store.CreateWebhookParams
+	mux := NewRouter(&mockQuerier{
+		CreateWebhookFn: func(_ context.Context, arg store.CreateWebhookParams) (store.Webhook, error) {
+			got = arg
+			return store.Webhook{
+				ID: 3, Name: arg.Name, Url: arg.Url, Events: arg.Events, Template: arg.Template,
+				Headers: arg.Headers, Secret: arg.Secret, Enabled: arg.Enabled,
+			}, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	body := `{"url":"https://ntfy.example/auspex","events":["job_ready","subject_failed","job_ready"],` +
+		`"template":"{{.Type}}","headers":{"Title":"Auspex"}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+	}
+	if got.Name != "ntfy.example" {
+		t.Errorf("name = %q, want the URL's host", got.Name)
+	}
+	if got.Events != "subject_failed,job_ready" {
+		t.Errorf("stored events = %q", got.Events)
+	}
+	if got.Headers != `{"Title":"Auspex"}` || got.Enabled != 1 {
+		t.Errorf("stored headers, enabled = %q, %d", got.Headers, got.Enabled)
+	}
+	if len(got.Secret) < 20 {
+		t.Errorf("secret %q is too short", got.Secret)
+	}
+
+	var resp webhookJSON
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if !resp.Enabled || resp.Secret != got.Secret || len(resp.Events) != 2 || resp.Headers["Title"] != "Auspex" {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestWebhookSecret_ShownOnlyOnCreateAndRotate(t *testing.T) {
+	srv := newContractServer(t, newContractDB(t))
+	call := func(method, path string, body string) webhookJSON {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+			t.Fatalf("%s %s: status %d", method, path, resp.StatusCode)
+		}
+		var hook webhookJSON
+		if strings.HasSuffix(path, "/webhooks") && method == http.MethodGet {
+			var hooks []webhookJSON
+			if err := json.NewDecoder(resp.Body).Decode(&hooks); err != nil || len(hooks) != 1 {
+				t.Fatalf("%s %s: %v, %d webhooks", method, path, err, len(hooks))
+			}
+			return hooks[0]
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&hook); err != nil {
+			t.Fatalf("%s %s: decode: %v", method, path, err)
+		}
+		return hook
+	}
+
+	created := call(http.MethodPost, "/api/webhooks", `{"url":"https://example.com/hook"}`)
+	if len(created.Secret) < 20 || strings.Contains(created.Secret, "*") {
+		t.Fatalf("secret on create = %q, want it in clear", created.Secret)
+	}
+	path := "/api/webhooks/" + strconv.FormatInt(created.ID, 10)
+	masked := maskSecret(created.Secret)
+	for _, got := range []webhookJSON{
+		call(http.MethodGet, "/api/webhooks", ""),
+		call(http.MethodGet, path, ""),
+		call(http.MethodPut, path, `{"url":"https://example.com/other"}`),
+	} {
+		if got.Secret != masked {
+			t.Errorf("secret = %q, want %q", got.Secret, masked)
+		}
+	}
+
+	rotated := call(http.MethodPost, path+"/secret", "")
+	if rotated.Secret == created.Secret || strings.Contains(rotated.Secret, "*") {
+		t.Errorf("secret after rotation = %q, want a new one in clear", rotated.Secret)
+	}
+	if got := call(http.MethodGet, path, ""); got.Secret != maskSecret(rotated.Secret) {
+		t.Errorf("secret after rotation = %q, want %q", got.Secret, maskSecret(rotated.Secret))
+	}
+}
+
+func TestCreateWebhook_Invalid(t *testing.T) {
+	mux := NewRouter(&mockQuerier{
+		CreateWebhookFn: func(_ context.Context, _ store.CreateWebhookParams) (store.Webhook, error) {
+			t.Error("webhook created despite an invalid request")
+			return store.Webhook{}, nil
+		},
+	}, nil, nil, nil, testFS())
+
+	for _, body := range []string{
+		`{"url":"ftp://example.com"}`,
+		`{"url":"not a url"}`,
+		`{"url":"https://example.com","events":["job_done"]}`,
+		`{"url":"https://example.com","template":"{{.Data"}`,
+		`{"url":"https://example.com","events":["subject_failed"],"template":"{{.Data.JobID}}"}`,
+		`{"url":"https://example.com","headers":{"Content-Length":"1"}}`,
+		`not json`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/webhooks", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestWebhook_NotFound(t *testing.T) {
+	mux := NewRouter(&mockQuerier{
+		GetWebhookFn: func(_ context.Context, _ int64) (store.Webhook, error) {
+			return store.Webhook{}, sql.ErrNoRows
+		},
+		UpdateWebhookFn: func(_ context.Context, _ store.UpdateWebhookParams) (store.Webhook, error) {
+			return store.Webhook{}, sql.ErrNoRows
+		},
+		SetWebhookSecretFn: func(_ context.Context, _ store.SetWebhookSecretParams) (store.Webhook, error) {
+			return store.Webhook{}, sql.ErrNoRows
+		},
+		DeleteWebhookFn: func(_ context.Context, _ int64) (int64, error) { return 0, nil },
+	}, nil, nil, nil, testFS())
+
+	for _, tc := range []struct{ method, path, body string }{
+		{http.MethodGet, "/api/webhooks/9", ""},
+		{http.MethodPut, "/api/webhooks/9", `{"url":"https://example.com"}`},
+		{http.MethodDelete, "/api/webhooks/9", ""},
+		{http.MethodGet, "/api/webhooks/9/deliveries", ""},
+		{http.MethodPost, "/api/webhooks/9/secret", ""},
+		{http.MethodPost, "/api/webhooks/9/test", ""},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404, got %d", tc.method, tc.path, rr.Code)
+		}
+	}
+}
+
+func TestGetWebhookDeliveries_InvalidLimit(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
+	for _, limit := range []string{"0", "501", "x"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/webhooks/1/deliveries?limit="+limit, http.NoBody)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("limit=%s: expected 400, got %d", limit, rr.Code)
+		}
+	}
+}
+
+func TestTestWebhook_UnknownEventType(t *testing.T) {
+	mux := NewRouter(&mockQuerier{}, nil, nil, nil, testFS())
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/1/test", strings.NewReader(`{"event_type":"job_done"}`))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestTestWebhook_NoDispatcher(t *testing.T) {
+	mux := NewRouter(&mockQuerier{
+		GetWebhookFn: func(_ context.Context, id int64) (store.Webhook, error) {
+			return store.Webhook{ID: id, Url: "https://example.com"}, nil
+		},
+	}, nil, nil, nil, testFS())
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/1/test", http.NoBody)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rr.Code)
+	}
+}
@@ -6,8 +6,14 @@ package auth
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -19,6 +25,34 @@ import (
 // Compile-time assertion: *Client must satisfy esi.Client.
 var _ esi.Client = (*Client)(nil)
 
+// ErrNeedsReauth is returned when a character's refresh token has been rejected
+// by EVE SSO (app access revoked or account password changed). The character is
+// flagged in the store and no further refresh attempts are made until the user
+// logs in with it again.
+var ErrNeedsReauth = errors.New("character needs re-authorization")
+
+// ErrCharacterTransferred is returned when the EVE SSO owner hash of a
+// character changed, i.e. it now belongs to another EVE account. Its tokens are
+// not used until the transfer is confirmed.
+var ErrCharacterTransferred = errors.New("character transferred to another account")
+
+// oauthErrInvalidGrant is the RFC 6749 error code EVE SSO returns for a refresh
+// token that is expired, revoked, or otherwise no longer valid.
+const oauthErrInvalidGrant = "invalid_grant"
+
+// corporationEndpointRole is the corporation role ESI requires, besides
+// Director, for each corporation endpoint.
+var corporationEndpointRole = map[string]string{
+	esi.ScopeCorporationAssets:     esi.RoleDirector,
+	esi.ScopeCorporationBlueprints: esi.RoleDirector,
+	esi.ScopeCorporationDivisions:  esi.RoleDirector,
+	esi.ScopeCorporationJobs:       esi.RoleFactoryManager,
+}
+
+// tokenExpiryMargin is how long before its expiry an access token stops being
+// reused. It covers clock skew and the duration of the ESI request itself.
+const tokenExpiryMargin = time.Minute
+
 // Client wraps an esi.Client and automatically injects a valid access token
 // into every authenticated ESI request, refreshing via OAuth2 when needed.
 // It implements esi.Client transparently so that the sync worker can treat
@@ -28,6 +62,32 @@ type Client struct {
 	store      store.Querier
 	conf       *oauth2.Config
 	httpClient *http.Client // injected into OAuth2 context for testability
+
+	mu     sync.Mutex
+	tokens map[int64]*cachedToken // keyed by character ID; entries are never removed
+}
+
+// cachedToken is the in-memory access token for one character.
+// mu serializes load-and-refresh for the character: concurrent callers wait
+// for the first one and then reuse the token it obtained, so an expired token
+// is refreshed exactly once and the rotated refresh token is never lost.
+type cachedToken struct {
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+}
+
+// valid reports whether the cached access token can be used at now.
+// Must be called with t.mu held.
+func (t *cachedToken) valid(now time.Time) bool {
+	return t.accessToken != "" && t.expiry.After(now.Add(tokenExpiryMargin))
+}
+
+// TokenRefresher returns a valid access token for a character. Implemented by
+// Client; used by sync to pick the character whose token a request is made with
+// when access differs per character.
+type TokenRefresher interface {
+	FreshToken(ctx context.Context, characterID int64) (string, error)
 }
 
 // NewClient returns an auth.Client that wraps inner.
@@ -43,6 +103,7 @@ func NewClient(inner esi.Client, q store.Querier, conf *oauth2.Config, httpClien
 		store:      q,
 		conf:       conf,
 		httpClient: httpClient,
+		tokens:     make(map[int64]*cachedToken),
 	}
 }
 
@@ -58,14 +119,17 @@ func (c *Client) GetCharacterBlueprints(ctx context.Context, characterID int64,
 }
 
 // GetCorporationBlueprints fetches blueprints for the given corporation,
-// using the delegate character's token (refreshed if needed).
-// The token parameter is ignored.
+// using the token of the delegate or of another member holding the required
+// roles (see withCorporationToken). The token parameter is ignored.
 func (c *Client) GetCorporationBlueprints(ctx context.Context, corporationID int64, _ string) ([]esi.Blueprint, time.Time, error) {
-	token, err := c.tokenForCorporation(ctx, corporationID)
-	if err != nil {
-		return nil, time.Time{}, fmt.Errorf("getting token for corporation %d: %w", corporationID, err)
-	}
-	return c.inner.GetCorporationBlueprints(ctx, corporationID, token)
+	var bps []esi.Blueprint
+	var cacheUntil time.Time
+	err := c.withCorporationToken(ctx, corporationID, esi.ScopeCorporationBlueprints, func(token string) error {
+		var err error
+		bps, cacheUntil, err = c.inner.GetCorporationBlueprints(ctx, corporationID, token)
+		return err
+	})
+	return bps, cacheUntil, err
 }
 
 // GetCharacterJobs fetches active and ready industry jobs for the given character.
@@ -78,26 +142,90 @@ func (c *Client) GetCharacterJobs(ctx context.Context, characterID int64, _ stri
 	return c.inner.GetCharacterJobs(ctx, characterID, token)
 }
 
-// GetCorporationJobs fetches active and ready industry jobs for the given corporation,
-// using the delegate character's token (refreshed if needed).
+// GetCharacterRoles fetches the corporation roles of the given character.
 // The token parameter is ignored.
+func (c *Client) GetCharacterRoles(ctx context.Context, characterID int64, _ string) ([]string, time.Time, error) {
+	token, err := c.tokenForCharacter(ctx, characterID)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("getting token for character %d: %w", characterID, err)
+	}
+	return c.inner.GetCharacterRoles(ctx, characterID, token)
+}
+
+// GetCorporationJobs fetches active and ready industry jobs for the given
+// corporation, using the token of the delegate or of another member holding
+// the required roles (see withCorporationToken). The token parameter is ignored.
 func (c *Client) GetCorporationJobs(ctx context.Context, corporationID int64, _ string) ([]esi.Job, time.Time, error) {
-	token, err := c.tokenForCorporation(ctx, corporationID)
+	var jobs []esi.Job
+	var cacheUntil time.Time
+	err := c.withCorporationToken(ctx, corporationID, esi.ScopeCorporationJobs, func(token string) error {
+		var err error
+		jobs, cacheUntil, err = c.inner.GetCorporationJobs(ctx, corporationID, token)
+		return err
+	})
+	return jobs, cacheUntil, err
+}
+
+// GetCharacterAssets fetches one page of character assets.
+// The token parameter is ignored.
+func (c *Client) GetCharacterAssets(ctx context.Context, characterID int64, _ string, page int) ([]esi.Asset, int, time.Time, error) {
+	token, err := c.tokenForCharacter(ctx, characterID)
 	if err != nil {
-		return nil, time.Time{}, fmt.Errorf("getting token for corporation %d: %w", corporationID, err)
+		return nil, 0, time.Time{}, fmt.Errorf("getting token for character %d: %w", characterID, err)
 	}
-	return c.inner.GetCorporationJobs(ctx, corporationID, token)
+	return c.inner.GetCharacterAssets(ctx, characterID, token, page)
 }
 
-// GetCorporationAssets fetches one page of corporation assets,
-// using the delegate character's token (refreshed if needed).
+// PostCharacterAssetNames returns the names of the given character items.
 // The token parameter is ignored.
-func (c *Client) GetCorporationAssets(ctx context.Context, corpID int64, _ string, page int) ([]esi.CorpAsset, int, time.Time, error) {
-	token, err := c.tokenForCorporation(ctx, corpID)
+func (c *Client) PostCharacterAssetNames(ctx context.Context, characterID int64, _ string, itemIDs []int64) ([]esi.AssetName, error) {
+	token, err := c.tokenForCharacter(ctx, characterID)
 	if err != nil {
-		return nil, 0, time.Time{}, fmt.Errorf("getting token for corporation %d: %w", corpID, err)
+		return nil, fmt.Errorf("getting token for character %d: %w", characterID, err)
 	}
-	return c.inner.GetCorporationAssets(ctx, corpID, token, page)
+	return c.inner.PostCharacterAssetNames(ctx, characterID, token, itemIDs)
+}
+
+// GetCorporationAssets fetches one page of corporation assets, using the token
+// of the delegate or of another member holding the required roles (see
+// withCorporationToken). The token parameter is ignored.
+func (c *Client) GetCorporationAssets(ctx context.Context, corpID int64, _ string, page int) ([]esi.Asset, int, time.Time, error) {
+	var assets []esi.Asset
+	var pages int
+	var cacheUntil time.Time
+	err := c.withCorporationToken(ctx, corpID, esi.ScopeCorporationAssets, func(token string) error {
+		var err error
+		assets, pages, cacheUntil, err = c.inner.GetCorporationAssets(ctx, corpID, token, page)
+		return err
+	})
+	return assets, pages, cacheUntil, err
+}
+
+// PostCorporationAssetNames returns the names of the given corporation items,
+// using the token of the delegate or of another member holding the required
+// roles (see withCorporationToken). The token parameter is ignored.
+func (c *Client) PostCorporationAssetNames(ctx context.Context, corpID int64, _ string, itemIDs []int64) ([]esi.AssetName, error) {
+	var names []esi.AssetName
+	err := c.withCorporationToken(ctx, corpID, esi.ScopeCorporationAssets, func(token string) error {
+		var err error
+		names, err = c.inner.PostCorporationAssetNames(ctx, corpID, token, itemIDs)
+		return err
+	})
+	return names, err
+}
+
+// GetCorporationDivisions fetches the hangar division names of a corporation,
+// using the token of the delegate or of another member holding the required
+// roles (see withCorporationToken). The token parameter is ignored.
+func (c *Client) GetCorporationDivisions(ctx context.Context, corpID int64, _ string) ([]esi.Division, time.Time, error) {
+	var divisions []esi.Division
+	var cacheUntil time.Time
+	err := c.withCorporationToken(ctx, corpID, esi.ScopeCorporationDivisions, func(token string) error {
+		var err error
+		divisions, cacheUntil, err = c.inner.GetCorporationDivisions(ctx, corpID, token)
+		return err
+	})
+	return divisions, cacheUntil, err
 }
 
 // GetUniverseType delegates to the inner ESI client without token injection.
@@ -106,95 +234,294 @@ func (c *Client) GetUniverseType(ctx context.Context, typeID int64) (esi.Univers
 	return c.inner.GetUniverseType(ctx, typeID)
 }
 
+// GetUniverseGroup delegates to the inner ESI client without token injection.
+func (c *Client) GetUniverseGroup(ctx context.Context, groupID int64) (esi.UniverseGroup, error) {
+	return c.inner.GetUniverseGroup(ctx, groupID)
+}
+
+// GetUniverseCategory delegates to the inner ESI client without token injection.
+func (c *Client) GetUniverseCategory(ctx context.Context, categoryID int64) (esi.UniverseCategory, error) {
+	return c.inner.GetUniverseCategory(ctx, categoryID)
+}
+
 // GetUniverseStructure fetches a player-owned structure.
-// The token parameter is ignored; a fresh token is obtained from any available
-// character and refreshed via OAuth2 if needed.
-func (c *Client) GetUniverseStructure(ctx context.Context, structureID int64, _ string) (esi.UniverseStructure, error) {
-	token, err := c.tokenForAnyCharacter(ctx)
-	if err != nil {
-		return esi.UniverseStructure{}, fmt.Errorf("getting token for structure %d: %w", structureID, err)
+// Access depends on the character, so a non-empty token — one obtained from
+// FreshToken for the character the caller chose — is forwarded as is. With an
+// empty token a fresh token is obtained from any available character and
+// refreshed via OAuth2 if needed.
+func (c *Client) GetUniverseStructure(ctx context.Context, structureID int64, token string) (esi.UniverseStructure, error) {
+	if token == "" {
+		var err error
+		token, err = c.tokenForAnyCharacter(ctx)
+		if err != nil {
+			return esi.UniverseStructure{}, fmt.Errorf("getting token for structure %d: %w", structureID, err)
+		}
 	}
 	return c.inner.GetUniverseStructure(ctx, structureID, token)
 }
 
-// GetStation fetches an NPC station name. Public endpoint, no auth required.
-func (c *Client) GetStation(ctx context.Context, stationID int64) (string, error) {
+// GetStation fetches an NPC station. Public endpoint, no auth required.
+func (c *Client) GetStation(ctx context.Context, stationID int64) (esi.UniverseStation, error) {
 	return c.inner.GetStation(ctx, stationID)
 }
 
-// GetUniverseSystem fetches a solar system name. Public endpoint, no auth required.
-func (c *Client) GetUniverseSystem(ctx context.Context, systemID int64) (string, error) {
+// GetUniverseSystem fetches a solar system with its constellation and region.
+// Public endpoints, no auth required.
+func (c *Client) GetUniverseSystem(ctx context.Context, systemID int64) (esi.UniverseSystem, error) {
 	return c.inner.GetUniverseSystem(ctx, systemID)
 }
 
+// PostCharactersAffiliation returns the current corporation and alliance of
+// each character. Public endpoint, no auth required.
+func (c *Client) PostCharactersAffiliation(ctx context.Context, characterIDs []int64) ([]esi.CharacterAffiliation, error) {
+	return c.inner.PostCharactersAffiliation(ctx, characterIDs)
+}
+
 // PostUniverseNames resolves a batch of EVE IDs to names. Public endpoint, no auth required.
 func (c *Client) PostUniverseNames(ctx context.Context, ids []int64) ([]esi.UniverseNamesEntry, error) {
 	return c.inner.PostUniverseNames(ctx, ids)
 }
 
+// FreshToken returns a valid access token for the character, refreshing it
+// via OAuth2 if needed. It implements TokenRefresher.
+func (c *Client) FreshToken(ctx context.Context, characterID int64) (string, error) {
+	return c.tokenForCharacter(ctx, characterID)
+}
+
 // tokenForCharacter returns a valid access token for the character.
-// If the stored token is expired it is refreshed via OAuth2 and the updated
-// credentials are persisted to the store before being returned.
+// A cached token is reused until shortly before it expires; otherwise the
+// character is loaded from the store and, if its stored token is expired too,
+// refreshed via OAuth2. The refreshed credentials — including the rotated
+// refresh token — are persisted before the per-character lock is released.
+// Returns ErrNeedsReauth without contacting EVE SSO if the character is already
+// flagged, and flags the character when the refresh fails with invalid_grant.
+// Returns ErrCharacterTransferred if the character is flagged as transferred,
+// and flags it when the refreshed token carries a different owner hash.
 func (c *Client) tokenForCharacter(ctx context.Context, characterID int64) (string, error) {
+	entry := c.cacheEntry(characterID)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.valid(time.Now()) {
+		return entry.accessToken, nil
+	}
+
 	char, err := c.store.GetCharacter(ctx, characterID)
 	if err != nil {
 		return "", fmt.Errorf("loading character %d from store: %w", characterID, err)
 	}
+	if char.NeedsReauth != 0 {
+		return "", fmt.Errorf("character %d: %w", characterID, ErrNeedsReauth)
+	}
+	if char.Transferred != 0 {
+		return "", fmt.Errorf("character %d: %w", characterID, ErrCharacterTransferred)
+	}
 
-	t := &oauth2.Token{
-		AccessToken:  char.AccessToken,
-		RefreshToken: char.RefreshToken,
-		Expiry:       char.TokenExpiry,
+	// The stored token may still be usable, e.g. right after startup or login.
+	entry.accessToken, entry.expiry = char.AccessToken, char.TokenExpiry
+	if entry.valid(time.Now()) {
+		return entry.accessToken, nil
 	}
 
 	// Inject the HTTP client so oauth2 uses it for the token refresh call.
 	// This makes the refresh path testable without real network calls.
 	ctx = context.WithValue(ctx, oauth2.HTTPClient, c.httpClient)
 
-	// TokenSource returns t unchanged if t.Valid() (not expired).
-	// If expired, it calls the token URL to obtain a new access token.
-	ts := c.conf.TokenSource(ctx, t)
-	newToken, err := ts.Token()
+	// A token without an access token is never Valid(), so TokenSource always
+	// calls the token URL. If EVE SSO does not return a new refresh token,
+	// oauth2 keeps the one passed in.
+	newToken, err := c.conf.TokenSource(ctx, &oauth2.Token{RefreshToken: char.RefreshToken}).Token()
 	if err != nil {
+		entry.accessToken, entry.expiry = "", time.Time{}
+		if isInvalidGrant(err) {
+			if merr := c.store.MarkCharacterNeedsReauth(ctx, characterID); merr != nil {
+				return "", fmt.Errorf("flagging character %d for re-authorization: %w", characterID, merr)
+			}
+			return "", fmt.Errorf("refreshing token for character %d: %w", characterID, ErrNeedsReauth)
+		}
 		return "", fmt.Errorf("refreshing token for character %d: %w", characterID, err)
 	}
 
-	// Persist only when the token actually changed (i.e., a refresh occurred).
-	if newToken.AccessToken != char.AccessToken {
-		if err := c.store.UpsertCharacter(ctx, store.UpsertCharacterParams{
-			ID:           char.ID,
-			Name:         char.Name,
-			AccessToken:  newToken.AccessToken,
-			RefreshToken: newToken.RefreshToken,
-			TokenExpiry:  newToken.Expiry,
-		}); err != nil {
-			return "", fmt.Errorf("saving refreshed token for character %d: %w", characterID, err)
+	// EVE SSO may have rotated the refresh token, invalidating the old one, so
+	// the new credentials must reach the store before anyone else can refresh.
+	// The new token carries the scopes currently granted and the owner hash;
+	// keep the stored values if they cannot be read from it.
+	scopes, ownerHash := char.Scopes, char.OwnerHash
+	if claims, err := parseTokenClaims(newToken.AccessToken); err == nil {
+		scopes = strings.Join(claims.Scopes, " ")
+		if claims.Owner != "" {
+			ownerHash = claims.Owner
 		}
 	}
+	if err := c.store.UpdateCharacterTokens(ctx, store.UpdateCharacterTokensParams{
+		AccessToken:  newToken.AccessToken,
+		RefreshToken: newToken.RefreshToken,
+		TokenExpiry:  newToken.Expiry,
+		Scopes:       scopes,
+		OwnerHash:    ownerHash,
+		ID:           characterID,
+	}); err != nil {
+		entry.accessToken, entry.expiry = "", time.Time{}
+		return "", fmt.Errorf("saving refreshed token for character %d: %w", characterID, err)
+	}
+
+	// UpdateCharacterTokens flagged the character if its owner hash changed.
+	// An empty stored hash predates owner tracking; the new one is simply recorded.
+	if char.OwnerHash != "" && ownerHash != char.OwnerHash {
+		entry.accessToken, entry.expiry = "", time.Time{}
+		return "", fmt.Errorf("refreshing token for character %d: %w", characterID, ErrCharacterTransferred)
+	}
+
+	entry.accessToken, entry.expiry = newToken.AccessToken, newToken.Expiry
+	return entry.accessToken, nil
+}
+
+// forget drops the cached access token of the character, waiting for a
+// refresh in progress. The next request loads the character from the store.
+// It does nothing on a nil Client.
+func (c *Client) forget(characterID int64) {
+	if c == nil {
+		return
+	}
+	entry := c.cacheEntry(characterID)
+	entry.mu.Lock()
+	entry.accessToken, entry.expiry = "", time.Time{}
+	entry.mu.Unlock()
+}
 
-	return newToken.AccessToken, nil
+// cacheEntry returns the token cache entry for the character, creating it on
+// first use.
+func (c *Client) cacheEntry(characterID int64) *cachedToken {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.tokens[characterID]
+	if !ok {
+		entry = &cachedToken{}
+		c.tokens[characterID] = entry
+	}
+	return entry
 }
 
-// tokenForCorporation returns a valid access token for the delegate character
-// of the given corporation, refreshing via OAuth2 if needed.
-func (c *Client) tokenForCorporation(ctx context.Context, corporationID int64) (string, error) {
+// withCorporationToken calls call with the access token of a character able
+// to read the corporation endpoint that requires scope. The corporation's
+// active character (the one that succeeded last) is tried first, then the
+// delegate. When ESI answers the delegate with 403 — it lacks the corporation
+// role the endpoint requires — or the delegate cannot be used at all (it
+// needs re-authorization or was transferred), every other tracked member that
+// holds the role and granted scope is tried in turn. The character that succeeds becomes the
+// corporation's active character. If none does, the delegate's error is
+// returned.
+func (c *Client) withCorporationToken(ctx context.Context, corporationID int64, scope string, call func(token string) error) error {
 	corp, err := c.store.GetCorporation(ctx, corporationID)
 	if err != nil {
-		return "", fmt.Errorf("loading corporation %d from store: %w", corporationID, err)
+		return fmt.Errorf("getting token for corporation %d: loading corporation %d from store: %w", corporationID, corporationID, err)
+	}
+	if !corp.DelegateID.Valid {
+		return fmt.Errorf("getting token for corporation %d: corporation has no delegate: no tracked character is a member", corporationID)
 	}
-	return c.tokenForCharacter(ctx, corp.DelegateID)
+	delegateID := corp.DelegateID.Int64
+
+	var candidates []int64
+	if corp.ActiveCharacterID.Valid && corp.ActiveCharacterID.Int64 != delegateID {
+		candidates = append(candidates, corp.ActiveCharacterID.Int64)
+	}
+	candidates = append(candidates, delegateID)
+
+	var delegateErr error
+	for i := 0; i < len(candidates); i++ {
+		id := candidates[i]
+		err := c.callAsCharacter(ctx, corporationID, id, call)
+		if err == nil {
+			c.setActiveCharacter(ctx, corp, id)
+			return nil
+		}
+		if id != delegateID {
+			continue
+		}
+		delegateErr = err
+		if !esi.IsForbidden(err) && !errors.Is(err, ErrNeedsReauth) && !errors.Is(err, ErrCharacterTransferred) {
+			return err
+		}
+		members, err := c.store.ListCharactersByCorporation(ctx, corporationID)
+		if err != nil {
+			return fmt.Errorf("listing members of corporation %d: %w", corporationID, err)
+		}
+		for _, m := range members {
+			if m.ID != delegateID && !slices.Contains(candidates, m.ID) && canReadCorporation(m, scope) {
+				candidates = append(candidates, m.ID)
+			}
+		}
+	}
+	return delegateErr
+}
+
+// callAsCharacter calls call with a valid access token for the character.
+func (c *Client) callAsCharacter(ctx context.Context, corporationID, characterID int64, call func(token string) error) error {
+	token, err := c.tokenForCharacter(ctx, characterID)
+	if err != nil {
+		return fmt.Errorf("getting token for corporation %d: %w", corporationID, err)
+	}
+	return call(token)
+}
+
+// setActiveCharacter records characterID as the character in use for the
+// corporation's endpoints if it is not already. Errors are logged only: the
+// fetch itself succeeded.
+func (c *Client) setActiveCharacter(ctx context.Context, corp store.Corporation, characterID int64) {
+	if corp.ActiveCharacterID.Valid && corp.ActiveCharacterID.Int64 == characterID {
+		return
+	}
+	if corp.DelegateID.Int64 != characterID {
+		log.Printf("auth: corporation %d: delegate %d cannot read the corporation; using character %d", corp.ID, corp.DelegateID.Int64, characterID)
+	}
+	if err := c.store.SetCorporationActiveCharacter(ctx, store.SetCorporationActiveCharacterParams{
+		ActiveCharacterID: sql.NullInt64{Int64: characterID, Valid: true},
+		ID:                corp.ID,
+	}); err != nil {
+		log.Printf("auth: recording active character of corporation %d: %v", corp.ID, err)
+	}
+}
+
+// canReadCorporation reports whether char may stand in for the delegate on
+// the corporation endpoint that requires scope: its sync is not paused, it
+// granted scope (or its scopes are unknown), and it holds the role the
+// endpoint requires or Director. Characters whose roles were not fetched yet
+// are not used.
+func canReadCorporation(char store.Character, scope string) bool {
+	if char.NeedsReauth != 0 || char.Transferred != 0 || !char.Roles.Valid {
+		return false
+	}
+	if char.Scopes != "" && !slices.Contains(strings.Fields(char.Scopes), scope) {
+		return false
+	}
+	roles := strings.Fields(char.Roles.String)
+	return slices.Contains(roles, esi.RoleDirector) || slices.Contains(roles, corporationEndpointRole[scope])
 }
 
 // tokenForAnyCharacter returns a valid access token for the first registered
-// character, refreshing via OAuth2 if needed. Used for public-structure lookups
-// that require an authenticated token but are not tied to a specific owner.
+// character that neither needs re-authorization nor is flagged as transferred,
+// refreshing via OAuth2 if needed.
+// Used for public-structure lookups that require an authenticated token but are
+// not tied to a specific owner.
 func (c *Client) tokenForAnyCharacter(ctx context.Context) (string, error) {
 	chars, err := c.store.ListCharacters(ctx)
 	if err != nil {
 		return "", fmt.Errorf("listing characters: %w", err)
 	}
-	if len(chars) == 0 {
-		return "", fmt.Errorf("no characters registered")
+	for _, char := range chars {
+		if char.NeedsReauth == 0 && char.Transferred == 0 {
+			return c.tokenForCharacter(ctx, char.ID)
+		}
+	}
+	if len(chars) > 0 {
+		return "", fmt.Errorf("all characters: %w", ErrNeedsReauth)
 	}
-	return c.tokenForCharacter(ctx, chars[0].ID)
+	return "", fmt.Errorf("no characters registered")
+}
+
+// isInvalidGrant reports whether err is an OAuth2 token endpoint error with the
+// invalid_grant code, meaning the refresh token will never work again.
+func isInvalidGrant(err error) bool {
+	var re *oauth2.RetrieveError
+	return errors.As(err, &re) && re.ErrorCode == oauthErrInvalidGrant
 }
@@ -1,11 +1,17 @@
 package auth_test
 
 import (
+	"cmp"
 	"context"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
@@ -21,17 +27,28 @@ import (
 // ---------------------------------------------------------------------------
 
 // mockQuerier implements store.Querier for testing.
-// Only GetCharacter, GetCorporation, and UpsertCharacter are implemented;
+// Only GetCharacter, GetCorporation, UpdateCharacterTokens, ListCharacters,
+// ListCharactersByCorporation, MarkCharacterNeedsReauth,
+// MarkCharacterTransferred, and SetCorporationActiveCharacter are implemented;
 // any other call panics to make unexpected usage obvious.
 type mockQuerier struct {
 	store.Querier // embed to satisfy interface; unimplemented methods panic
 
 	characters   map[int64]store.Character
 	corporations map[int64]store.Corporation
-	upsertCalls  []store.UpsertCharacterParams
+	tokenUpdates []store.UpdateCharacterTokensParams
+	reauthCalls  []int64
+	transferred  []int64
+	activeCalls  []store.SetCorporationActiveCharacterParams
+
+	mu       sync.Mutex // guards getCalls; methods may be called concurrently
+	getCalls int
 }
 
 func (m *mockQuerier) GetCharacter(_ context.Context, id int64) (store.Character, error) {
+	m.mu.Lock()
+	m.getCalls++
+	m.mu.Unlock()
 	c, ok := m.characters[id]
 	if !ok {
 		return store.Character{}, fmt.Errorf("character %d not found", id)
@@ -47,8 +64,18 @@ func (m *mockQuerier) GetCorporation(_ context.Context, id int64) (store.Corpora
 	return c, nil
 }
 
-func (m *mockQuerier) UpsertCharacter(_ context.Context, arg store.UpsertCharacterParams) error {
-	m.upsertCalls = append(m.upsertCalls, arg)
+func (m *mockQuerier) UpdateCharacterTokens(_ context.Context, arg store.UpdateCharacterTokensParams) error {
+	m.tokenUpdates = append(m.tokenUpdates, arg)
+	return nil
+}
+
+func (m *mockQuerier) MarkCharacterNeedsReauth(_ context.Context, id int64) error {
+	m.reauthCalls = append(m.reauthCalls, id)
+	return nil
+}
+
+func (m *mockQuerier) MarkCharacterTransferred(_ context.Context, id int64) error {
+	m.transferred = append(m.transferred, id)
 	return nil
 }
 
@@ -60,16 +87,46 @@ func (m *mockQuerier) ListCharacters(_ context.Context) ([]store.Character, erro
 	return out, nil
 }
 
+// ListCharactersByCorporation returns the members of corporationID ordered by ID.
+func (m *mockQuerier) ListCharactersByCorporation(_ context.Context, corporationID int64) ([]store.Character, error) {
+	var out []store.Character
+	for _, c := range m.characters {
+		if c.CorporationID == corporationID {
+			out = append(out, c)
+		}
+	}
+	slices.SortFunc(out, func(a, b store.Character) int { return cmp.Compare(a.ID, b.ID) })
+	return out, nil
+}
+
+func (m *mockQuerier) SetCorporationActiveCharacter(_ context.Context, arg store.SetCorporationActiveCharacterParams) error {
+	m.activeCalls = append(m.activeCalls, arg)
+	return nil
+}
+
 // ---------------------------------------------------------------------------
 // mock ESI inner client
 // ---------------------------------------------------------------------------
 
 // mockESI records the token passed to each call and returns canned data.
+// Corporation endpoints answer 403 for the tokens in forbidden.
 type mockESI struct {
 	blueprints      []esi.Blueprint
 	cacheUntil      time.Time
 	tokenSeen       string
 	structTokenSeen string
+	forbidden       map[string]bool
+	corpTokens      []string // every token passed to a corporation endpoint
+}
+
+// corpCall records a corporation endpoint call made with token.
+func (m *mockESI) corpCall(token string) error {
+	m.tokenSeen = token
+	m.corpTokens = append(m.corpTokens, token)
+	if m.forbidden[token] {
+		return errors.New(`ESI status 403: {"error":"Character does not have required role(s)"}`)
+	}
+	return nil
 }
 
 func (m *mockESI) GetCharacterBlueprints(_ context.Context, _ int64, token string) ([]esi.Blueprint, time.Time, error) {
@@ -78,7 +135,9 @@ func (m *mockESI) GetCharacterBlueprints(_ context.Context, _ int64, token strin
 }
 
 func (m *mockESI) GetCorporationBlueprints(_ context.Context, _ int64, token string) ([]esi.Blueprint, time.Time, error) {
-	m.tokenSeen = token
+	if err := m.corpCall(token); err != nil {
+		return nil, time.Time{}, err
+	}
 	return m.blueprints, m.cacheUntil, nil
 }
 
@@ -87,34 +146,84 @@ func (m *mockESI) GetCharacterJobs(_ context.Context, _ int64, token string) ([]
 	return nil, m.cacheUntil, nil
 }
 
-func (m *mockESI) GetCorporationJobs(_ context.Context, _ int64, token string) ([]esi.Job, time.Time, error) {
+func (m *mockESI) GetCharacterRoles(_ context.Context, _ int64, token string) ([]string, time.Time, error) {
 	m.tokenSeen = token
 	return nil, m.cacheUntil, nil
 }
 
+func (m *mockESI) GetCorporationJobs(_ context.Context, _ int64, token string) ([]esi.Job, time.Time, error) {
+	if err := m.corpCall(token); err != nil {
+		return nil, time.Time{}, err
+	}
+	return nil, m.cacheUntil, nil
+}
+
 func (m *mockESI) GetUniverseType(_ context.Context, _ int64) (esi.UniverseType, error) {
 	return esi.UniverseType{}, nil
 }
 
+func (m *mockESI) GetUniverseGroup(_ context.Context, _ int64) (esi.UniverseGroup, error) {
+	return esi.UniverseGroup{}, nil
+}
+
+func (m *mockESI) GetUniverseCategory(_ context.Context, _ int64) (esi.UniverseCategory, error) {
+	return esi.UniverseCategory{}, nil
+}
+
 func (m *mockESI) GetUniverseStructure(_ context.Context, _ int64, token string) (esi.UniverseStructure, error) {
 	m.structTokenSeen = token
 	return esi.UniverseStructure{Name: "Test Structure", SolarSystemID: 30000142}, nil
 }
 
-func (m *mockESI) GetUniverseSystem(_ context.Context, _ int64) (string, error) {
-	return "", nil
+func (m *mockESI) GetUniverseSystem(_ context.Context, _ int64) (esi.UniverseSystem, error) {
+	return esi.UniverseSystem{}, nil
+}
+
+func (m *mockESI) PostCharactersAffiliation(_ context.Context, _ []int64) ([]esi.CharacterAffiliation, error) {
+	return nil, nil
 }
 
 func (m *mockESI) PostUniverseNames(_ context.Context, _ []int64) ([]esi.UniverseNamesEntry, error) {
 	return nil, nil
 }
 
-func (m *mockESI) GetCorporationAssets(_ context.Context, _ int64, _ string, _ int) ([]esi.CorpAsset, int, time.Time, error) {
+func (m *mockESI) GetCharacterAssets(_ context.Context, _ int64, _ string, _ int) ([]esi.Asset, int, time.Time, error) {
+	return nil, 0, time.Time{}, nil
+}
+
+func (m *mockESI) GetCorporationAssets(_ context.Context, _ int64, _ string, _ int) ([]esi.Asset, int, time.Time, error) {
 	return nil, 0, time.Time{}, nil
 }
 
-func (m *mockESI) GetStation(_ context.Context, _ int64) (string, error) {
-	return "", nil
+func (m *mockESI) GetCorporationDivisions(_ context.Context, _ int64, _ string) ([]esi.Division, time.Time, error) {
+	return nil, time.Time{}, nil
+}
+
+func (m *mockESI) PostCharacterAssetNames(_ context.Context, _ int64, _ string, _ []int64) ([]esi.AssetName, error) {
+	return nil, nil
+}
+
+func (m *mockESI) PostCorporationAssetNames(_ context.Context, _ int64, _ string, _ []int64) ([]esi.AssetName, error) {
+	return nil, nil
+}
+
+func (m *mockESI) GetStation(_ context.Context, _ int64) (esi.UniverseStation, error) {
+	return esi.UniverseStation{}, nil
+}
+
+// concurrentESI is a mockESI whose GetCharacterJobs is safe for concurrent use
+// and records every token it receives.
+type concurrentESI struct {
+	mockESI
+	mu     sync.Mutex
+	tokens []string
+}
+
+func (m *concurrentESI) GetCharacterJobs(_ context.Context, _ int64, token string) ([]esi.Job, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens = append(m.tokens, token)
+	return nil, time.Time{}, nil
 }
 
 // ---------------------------------------------------------------------------
@@ -141,6 +250,31 @@ func newTokenServer(t *testing.T, newAccess, newRefresh string) *httptest.Server
 	return srv
 }
 
+// newTokenErrorServer starts an httptest.Server that rejects every token request
+// with the given HTTP status and OAuth2 error code, and counts the requests.
+func newTokenErrorServer(t *testing.T, status int, errorCode string, calls *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error":             errorCode,
+			"error_description": "test error",
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// unsignedJWT returns a JWT-shaped access token with the given JSON claims and
+// a dummy signature. auth.Client reads claims from refreshed tokens without
+// verifying them.
+func unsignedJWT(claims string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"RS256"}`)) + "." + enc.EncodeToString([]byte(claims)) + ".sig"
+}
+
 // newConf returns a minimal oauth2.Config pointed at the given token URL.
 func newConf(tokenURL string) *oauth2.Config {
 	return &oauth2.Config{
@@ -186,8 +320,8 @@ func TestClient_FreshToken(t *testing.T) {
 	if inner.tokenSeen != "fresh-token" {
 		t.Errorf("inner ESI received token %q, want %q", inner.tokenSeen, "fresh-token")
 	}
-	if len(q.upsertCalls) != 0 {
-		t.Errorf("UpsertCharacter called %d times, want 0 (token was fresh)", len(q.upsertCalls))
+	if len(q.tokenUpdates) != 0 {
+		t.Errorf("UpdateCharacterTokens called %d times, want 0 (token was fresh)", len(q.tokenUpdates))
 	}
 }
 
@@ -223,10 +357,10 @@ func TestClient_ExpiredTokenRefreshed(t *testing.T) {
 	}
 
 	// New credentials must be persisted exactly once.
-	if len(q.upsertCalls) != 1 {
-		t.Fatalf("UpsertCharacter called %d times, want 1", len(q.upsertCalls))
+	if len(q.tokenUpdates) != 1 {
+		t.Fatalf("UpdateCharacterTokens called %d times, want 1", len(q.tokenUpdates))
 	}
-	saved := q.upsertCalls[0]
+	saved := q.tokenUpdates[0]
 	if saved.ID != 42 {
 		t.Errorf("saved character ID = %d, want 42", saved.ID)
 	}
@@ -266,11 +400,11 @@ func TestClient_RefreshedTokenSavedForJobs(t *testing.T) {
 	if inner.tokenSeen != "jobs-new-token" {
 		t.Errorf("inner ESI received token %q, want %q", inner.tokenSeen, "jobs-new-token")
 	}
-	if len(q.upsertCalls) != 1 {
-		t.Fatalf("UpsertCharacter called %d times, want 1", len(q.upsertCalls))
+	if len(q.tokenUpdates) != 1 {
+		t.Fatalf("UpdateCharacterTokens called %d times, want 1", len(q.tokenUpdates))
 	}
-	if q.upsertCalls[0].AccessToken != "jobs-new-token" {
-		t.Errorf("saved token = %q, want %q", q.upsertCalls[0].AccessToken, "jobs-new-token")
+	if q.tokenUpdates[0].AccessToken != "jobs-new-token" {
+		t.Errorf("saved token = %q, want %q", q.tokenUpdates[0].AccessToken, "jobs-new-token")
 	}
 }
 
@@ -290,7 +424,7 @@ func TestClient_CorporationUsesDelegate(t *testing.T) {
 			},
 		},
 		corporations: map[int64]store.Corporation{
-			77: {ID: 77, Name: "Test Corp", DelegateID: 99},
+			77: {ID: 77, Name: "Test Corp", DelegateID: sql.NullInt64{Int64: 99, Valid: true}},
 		},
 	}
 
@@ -306,8 +440,8 @@ func TestClient_CorporationUsesDelegate(t *testing.T) {
 		t.Errorf("inner ESI received token %q, want %q", inner.tokenSeen, "delegate-token")
 	}
 	// Fresh delegate token: no refresh, no upsert.
-	if len(q.upsertCalls) != 0 {
-		t.Errorf("UpsertCharacter called %d times, want 0", len(q.upsertCalls))
+	if len(q.tokenUpdates) != 0 {
+		t.Errorf("UpdateCharacterTokens called %d times, want 0", len(q.tokenUpdates))
 	}
 }
 
@@ -327,7 +461,7 @@ func TestClient_CorporationExpiredDelegate(t *testing.T) {
 			},
 		},
 		corporations: map[int64]store.Corporation{
-			77: {ID: 77, Name: "Test Corp", DelegateID: 99},
+			77: {ID: 77, Name: "Test Corp", DelegateID: sql.NullInt64{Int64: 99, Valid: true}},
 		},
 	}
 
@@ -342,11 +476,162 @@ func TestClient_CorporationExpiredDelegate(t *testing.T) {
 	if inner.tokenSeen != "new-delegate-token" {
 		t.Errorf("inner ESI received token %q, want %q", inner.tokenSeen, "new-delegate-token")
 	}
-	if len(q.upsertCalls) != 1 {
-		t.Fatalf("UpsertCharacter called %d times, want 1", len(q.upsertCalls))
+	if len(q.tokenUpdates) != 1 {
+		t.Fatalf("UpdateCharacterTokens called %d times, want 1", len(q.tokenUpdates))
 	}
-	if q.upsertCalls[0].ID != 99 {
-		t.Errorf("saved character ID = %d, want 99 (delegate)", q.upsertCalls[0].ID)
+	if q.tokenUpdates[0].ID != 99 {
+		t.Errorf("saved character ID = %d, want 99 (delegate)", q.tokenUpdates[0].ID)
+	}
+}
+
+// corpMember returns a fresh-token member of corporation 77 with the given roles.
+func corpMember(id int64, roles string) store.Character {
+	return store.Character{
+		ID:            id,
+		Name:          fmt.Sprintf("Member %d", id),
+		AccessToken:   fmt.Sprintf("token-%d", id),
+		TokenExpiry:   time.Now().Add(2 * time.Hour),
+		CorporationID: 77,
+		Roles:         sql.NullString{String: roles, Valid: true},
+	}
+}
+
+// TestClient_CorporationForbiddenDelegate_FailsOver verifies that when ESI
+// answers the delegate with 403, the first other member holding the role the
+// endpoint requires is used and recorded as the corporation's active character.
+func TestClient_CorporationForbiddenDelegate_FailsOver(t *testing.T) {
+	srv := newTokenServer(t, "should-not-be-used", "")
+
+	paused := corpMember(101, "Factory_Manager")
+	paused.NeedsReauth = 1
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			99:  corpMember(99, ""),
+			100: corpMember(100, "Accountant"),
+			101: paused,
+			102: corpMember(102, "Station_Manager Factory_Manager"),
+			103: corpMember(103, "Director"),
+		},
+		corporations: map[int64]store.Corporation{
+			77: {ID: 77, Name: "Test Corp", DelegateID: sql.NullInt64{Int64: 99, Valid: true}},
+		},
+	}
+
+	inner := &mockESI{forbidden: map[string]bool{"token-99": true}}
+	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
+
+	if _, _, err := client.GetCorporationJobs(context.Background(), 77, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []string{"token-99", "token-102"}; !slices.Equal(inner.corpTokens, want) {
+		t.Errorf("tokens tried = %v, want %v", inner.corpTokens, want)
+	}
+	want := store.SetCorporationActiveCharacterParams{ActiveCharacterID: sql.NullInt64{Int64: 102, Valid: true}, ID: 77}
+	if len(q.activeCalls) != 1 || q.activeCalls[0] != want {
+		t.Errorf("SetCorporationActiveCharacter calls = %v, want [%v]", q.activeCalls, want)
+	}
+}
+
+// TestClient_CorporationPausedDelegate_FailsOver verifies that a delegate
+// needing re-authorization is passed over like one answered with 403: the
+// first other member able to read the endpoint is used.
+func TestClient_CorporationPausedDelegate_FailsOver(t *testing.T) {
+	srv := newTokenServer(t, "should-not-be-used", "")
+
+	delegate := corpMember(99, "Director")
+	delegate.NeedsReauth = 1
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			99:  delegate,
+			102: corpMember(102, "Factory_Manager"),
+		},
+		corporations: map[int64]store.Corporation{
+			77: {ID: 77, Name: "Test Corp", DelegateID: sql.NullInt64{Int64: 99, Valid: true}},
+		},
+	}
+
+	inner := &mockESI{}
+	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
+
+	if _, _, err := client.GetCorporationJobs(context.Background(), 77, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []string{"token-102"}; !slices.Equal(inner.corpTokens, want) {
+		t.Errorf("tokens tried = %v, want %v", inner.corpTokens, want)
+	}
+	want := store.SetCorporationActiveCharacterParams{ActiveCharacterID: sql.NullInt64{Int64: 102, Valid: true}, ID: 77}
+	if len(q.activeCalls) != 1 || q.activeCalls[0] != want {
+		t.Errorf("SetCorporationActiveCharacter calls = %v, want [%v]", q.activeCalls, want)
+	}
+}
+
+// TestClient_CorporationActiveCharacterTriedFirst verifies that the active
+// character recorded by an earlier failover is used before the delegate.
+func TestClient_CorporationActiveCharacterTriedFirst(t *testing.T) {
+	srv := newTokenServer(t, "should-not-be-used", "")
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			99:  corpMember(99, ""),
+			103: corpMember(103, "Director"),
+		},
+		corporations: map[int64]store.Corporation{
+			77: {
+				ID: 77, Name: "Test Corp",
+				DelegateID:        sql.NullInt64{Int64: 99, Valid: true},
+				ActiveCharacterID: sql.NullInt64{Int64: 103, Valid: true},
+			},
+		},
+	}
+
+	inner := &mockESI{forbidden: map[string]bool{"token-99": true}}
+	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
+
+	if _, _, err := client.GetCorporationBlueprints(context.Background(), 77, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []string{"token-103"}; !slices.Equal(inner.corpTokens, want) {
+		t.Errorf("tokens tried = %v, want %v", inner.corpTokens, want)
+	}
+	if len(q.activeCalls) != 0 {
+		t.Errorf("SetCorporationActiveCharacter called %d times, want 0", len(q.activeCalls))
+	}
+}
+
+// TestClient_CorporationNoMemberWithRoles_ReturnsForbidden verifies that the
+// delegate's 403 is returned when no other member holds the required role.
+// Blueprints require Director, so a Factory Manager is not tried.
+func TestClient_CorporationNoMemberWithRoles_ReturnsForbidden(t *testing.T) {
+	srv := newTokenServer(t, "should-not-be-used", "")
+
+	unknown := corpMember(101, "")
+	unknown.Roles = sql.NullString{}
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			99:  corpMember(99, ""),
+			100: corpMember(100, "Factory_Manager"),
+			101: unknown,
+		},
+		corporations: map[int64]store.Corporation{
+			77: {ID: 77, Name: "Test Corp", DelegateID: sql.NullInt64{Int64: 99, Valid: true}},
+		},
+	}
+
+	inner := &mockESI{forbidden: map[string]bool{"token-99": true}}
+	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
+
+	_, _, err := client.GetCorporationBlueprints(context.Background(), 77, "")
+	if !esi.IsForbidden(err) {
+		t.Fatalf("error = %v, want a 403", err)
+	}
+	if want := []string{"token-99"}; !slices.Equal(inner.corpTokens, want) {
+		t.Errorf("tokens tried = %v, want %v", inner.corpTokens, want)
+	}
+	if len(q.activeCalls) != 0 {
+		t.Errorf("SetCorporationActiveCharacter called %d times, want 0", len(q.activeCalls))
 	}
 }
 
@@ -406,8 +691,8 @@ func TestClient_PostUniverseNames_Passthrough(t *testing.T) {
 }
 
 // TestClient_GetUniverseStructure_RefreshesToken verifies that GetUniverseStructure
-// obtains a fresh token via OAuth2 for any registered character (ignoring any token
-// passed by the caller) and forwards the refreshed token to the inner ESI client.
+// called without a token obtains a fresh token via OAuth2 for any registered
+// character and forwards the refreshed token to the inner ESI client.
 func TestClient_GetUniverseStructure_RefreshesToken(t *testing.T) {
 	srv := newTokenServer(t, "refreshed-struct-token", "new-refresh")
 
@@ -426,20 +711,422 @@ func TestClient_GetUniverseStructure_RefreshesToken(t *testing.T) {
 	inner := &mockESI{}
 	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
 
-	_, err := client.GetUniverseStructure(context.Background(), 1_000_000_000_001, "caller-supplied-token")
+	_, err := client.GetUniverseStructure(context.Background(), 1_000_000_000_001, "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	// The inner client must receive the refreshed token, not the caller-supplied one.
+	// The inner client must receive the refreshed token, not the stale stored one.
 	if inner.structTokenSeen != "refreshed-struct-token" {
 		t.Errorf("inner ESI structure token = %q, want %q", inner.structTokenSeen, "refreshed-struct-token")
 	}
 	// Refreshed token must be persisted.
-	if len(q.upsertCalls) != 1 {
-		t.Fatalf("UpsertCharacter called %d times, want 1", len(q.upsertCalls))
+	if len(q.tokenUpdates) != 1 {
+		t.Fatalf("UpdateCharacterTokens called %d times, want 1", len(q.tokenUpdates))
+	}
+	if q.tokenUpdates[0].AccessToken != "refreshed-struct-token" {
+		t.Errorf("saved AccessToken = %q, want %q", q.tokenUpdates[0].AccessToken, "refreshed-struct-token")
+	}
+}
+
+// TestClient_InvalidGrant_FlagsCharacter verifies that an invalid_grant response
+// from the token endpoint flags the character for re-authorization and surfaces
+// ErrNeedsReauth to the caller without calling ESI.
+func TestClient_InvalidGrant_FlagsCharacter(t *testing.T) {
+	var calls int
+	srv := newTokenErrorServer(t, http.StatusBadRequest, "invalid_grant", &calls)
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			42: {ID: 42, Name: "Revoked Pilot", RefreshToken: "revoked", TokenExpiry: time.Now().Add(-time.Hour)},
+		},
+	}
+
+	inner := &mockESI{}
+	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
+
+	_, _, err := client.GetCharacterBlueprints(context.Background(), 42, "")
+	if !errors.Is(err, auth.ErrNeedsReauth) {
+		t.Fatalf("error = %v, want ErrNeedsReauth", err)
+	}
+	if len(q.reauthCalls) != 1 || q.reauthCalls[0] != 42 {
+		t.Errorf("MarkCharacterNeedsReauth calls = %v, want [42]", q.reauthCalls)
+	}
+	if inner.tokenSeen != "" {
+		t.Errorf("inner ESI was called with token %q, want no call", inner.tokenSeen)
+	}
+}
+
+// TestClient_OtherRefreshError_DoesNotFlag verifies that transient token endpoint
+// failures (e.g. 5xx) are returned as-is and do not flag the character.
+func TestClient_OtherRefreshError_DoesNotFlag(t *testing.T) {
+	var calls int
+	srv := newTokenErrorServer(t, http.StatusServiceUnavailable, "temporarily_unavailable", &calls)
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			42: {ID: 42, Name: "Test Pilot", RefreshToken: "ok", TokenExpiry: time.Now().Add(-time.Hour)},
+		},
+	}
+
+	client := auth.NewClient(&mockESI{}, q, newConf(srv.URL), srv.Client())
+
+	_, _, err := client.GetCharacterJobs(context.Background(), 42, "")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if errors.Is(err, auth.ErrNeedsReauth) {
+		t.Errorf("error = %v, must not be ErrNeedsReauth", err)
+	}
+	if len(q.reauthCalls) != 0 {
+		t.Errorf("MarkCharacterNeedsReauth called %d times, want 0", len(q.reauthCalls))
+	}
+}
+
+// TestClient_AlreadyFlagged_SkipsRefresh verifies that a character already flagged
+// needs_reauth fails fast without another round-trip to the token endpoint.
+func TestClient_AlreadyFlagged_SkipsRefresh(t *testing.T) {
+	var calls int
+	srv := newTokenErrorServer(t, http.StatusBadRequest, "invalid_grant", &calls)
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			42: {ID: 42, Name: "Revoked Pilot", NeedsReauth: 1, TokenExpiry: time.Now().Add(-time.Hour)},
+		},
+		corporations: map[int64]store.Corporation{
+			99: {ID: 99, Name: "Corp", DelegateID: sql.NullInt64{Int64: 42, Valid: true}},
+		},
+	}
+
+	client := auth.NewClient(&mockESI{}, q, newConf(srv.URL), srv.Client())
+
+	_, _, err := client.GetCorporationBlueprints(context.Background(), 99, "")
+	if !errors.Is(err, auth.ErrNeedsReauth) {
+		t.Fatalf("error = %v, want ErrNeedsReauth", err)
+	}
+	if calls != 0 {
+		t.Errorf("token endpoint called %d times, want 0", calls)
+	}
+	if len(q.reauthCalls) != 0 {
+		t.Errorf("MarkCharacterNeedsReauth called %d times, want 0 (already flagged)", len(q.reauthCalls))
+	}
+}
+
+// TestClient_GetUniverseStructure_SkipsFlaggedCharacters verifies that structure
+// lookups use a character that does not need re-authorization.
+func TestClient_GetUniverseStructure_SkipsFlaggedCharacters(t *testing.T) {
+	srv := newTokenServer(t, "should-not-be-used", "")
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			1: {ID: 1, Name: "Revoked", NeedsReauth: 1},
+			2: {ID: 2, Name: "Healthy", AccessToken: "healthy-token", TokenExpiry: time.Now().Add(time.Hour)},
+		},
+	}
+
+	inner := &mockESI{}
+	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
+
+	if _, err := client.GetUniverseStructure(context.Background(), 1_000_000_000_001, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inner.structTokenSeen != "healthy-token" {
+		t.Errorf("inner ESI structure token = %q, want %q", inner.structTokenSeen, "healthy-token")
+	}
+}
+
+// TestClient_GetUniverseStructure_ForwardsCallerToken verifies that a token
+// chosen by the caller via FreshToken is used for the structure lookup instead
+// of the first character's.
+func TestClient_GetUniverseStructure_ForwardsCallerToken(t *testing.T) {
+	srv := newTokenServer(t, "should-not-be-used", "")
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			1: {ID: 1, Name: "First", AccessToken: "first-token", TokenExpiry: time.Now().Add(time.Hour)},
+			2: {ID: 2, Name: "Docked", AccessToken: "docked-token", TokenExpiry: time.Now().Add(time.Hour)},
+		},
+	}
+
+	inner := &mockESI{}
+	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
+
+	token, err := client.FreshToken(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("FreshToken: %v", err)
+	}
+	if _, err := client.GetUniverseStructure(context.Background(), 1_000_000_000_001, token); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inner.structTokenSeen != "docked-token" {
+		t.Errorf("inner ESI structure token = %q, want %q", inner.structTokenSeen, "docked-token")
+	}
+}
+
+// TestClient_TokenCachedAcrossCalls verifies that a valid token is kept in memory:
+// repeated ESI calls for the same character read the store only once.
+func TestClient_TokenCachedAcrossCalls(t *testing.T) {
+	srv := newTokenServer(t, "should-not-be-used", "")
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			42: {ID: 42, Name: "Test Pilot", AccessToken: "fresh-token", TokenExpiry: time.Now().Add(time.Hour)},
+		},
+	}
+
+	inner := &mockESI{}
+	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
+
+	for range 3 {
+		if _, _, err := client.GetCharacterBlueprints(context.Background(), 42, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, _, err := client.GetCharacterJobs(context.Background(), 42, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if q.getCalls != 1 {
+		t.Errorf("GetCharacter called %d times, want 1", q.getCalls)
+	}
+	if inner.tokenSeen != "fresh-token" {
+		t.Errorf("inner ESI received token %q, want %q", inner.tokenSeen, "fresh-token")
+	}
+}
+
+// TestClient_AlmostExpiredTokenRefreshed verifies that a stored token expiring
+// within the reuse margin is refreshed rather than handed to ESI.
+func TestClient_AlmostExpiredTokenRefreshed(t *testing.T) {
+	srv := newTokenServer(t, "new-access-token", "new-refresh-token")
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			42: {ID: 42, AccessToken: "expiring-token", RefreshToken: "old-refresh", TokenExpiry: time.Now().Add(20 * time.Second)},
+		},
+	}
+
+	inner := &mockESI{}
+	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
+
+	if _, _, err := client.GetCharacterBlueprints(context.Background(), 42, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inner.tokenSeen != "new-access-token" {
+		t.Errorf("inner ESI received token %q, want %q", inner.tokenSeen, "new-access-token")
+	}
+}
+
+// TestClient_ConcurrentRefresh_SingleFlight verifies that concurrent callers
+// holding the same expired token trigger exactly one refresh, all receive the
+// new token, and the rotated refresh token is persisted exactly once.
+func TestClient_ConcurrentRefresh_SingleFlight(t *testing.T) {
+	var mu sync.Mutex
+	var refreshes int
+	var refreshTokensSeen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		refreshes++
+		refreshTokensSeen = append(refreshTokensSeen, r.PostForm.Get("refresh_token"))
+		mu.Unlock()
+		// Give concurrent callers time to pile up behind the first refresh.
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "rotated-access",
+			"refresh_token": "rotated-refresh",
+			"token_type":    "Bearer",
+			"expires_in":    1200,
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			42: {ID: 42, AccessToken: "old-access", RefreshToken: "old-refresh", TokenExpiry: time.Now().Add(-time.Hour)},
+		},
+	}
+
+	inner := &concurrentESI{}
+	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := client.GetCharacterJobs(context.Background(), 42, "")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if refreshes != 1 {
+		t.Errorf("token endpoint called %d times, want 1", refreshes)
+	}
+	for i, tok := range inner.tokens {
+		if tok != "rotated-access" {
+			t.Errorf("caller %d: inner ESI received token %q, want %q", i, tok, "rotated-access")
+		}
+	}
+	if len(refreshTokensSeen) > 0 && refreshTokensSeen[0] != "old-refresh" {
+		t.Errorf("refresh used token %q, want %q", refreshTokensSeen[0], "old-refresh")
+	}
+	if len(q.tokenUpdates) != 1 {
+		t.Fatalf("UpdateCharacterTokens called %d times, want 1", len(q.tokenUpdates))
+	}
+	if q.tokenUpdates[0].RefreshToken != "rotated-refresh" {
+		t.Errorf("saved RefreshToken = %q, want %q", q.tokenUpdates[0].RefreshToken, "rotated-refresh")
+	}
+}
+
+// TestClient_PKCERefresh verifies that with a provider in PKCE mode (no client
+// secret) the refresh request identifies the application by client_id in the
+// form body and sends no credentials.
+func TestClient_PKCERefresh(t *testing.T) {
+	var form map[string][]string
+	var authHeader string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		form = r.PostForm
+		authHeader = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "pkce-access",
+			"refresh_token": "pkce-refresh",
+			"token_type":    "Bearer",
+			"expires_in":    1200,
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	provider := auth.NewProvider("pkce-client", "", "http://localhost/callback", "", nil, srv.Client())
+	conf := provider.OAuthConfig()
+	conf.Endpoint.TokenURL = srv.URL
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			42: {ID: 42, AccessToken: "old", RefreshToken: "old-refresh", TokenExpiry: time.Now().Add(-time.Hour)},
+		},
+	}
+	inner := &mockESI{}
+	client := auth.NewClient(inner, q, conf, srv.Client())
+
+	if _, _, err := client.GetCharacterBlueprints(context.Background(), 42, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inner.tokenSeen != "pkce-access" {
+		t.Errorf("inner ESI received token %q, want %q", inner.tokenSeen, "pkce-access")
+	}
+	if authHeader != "" {
+		t.Errorf("Authorization header = %q, want none", authHeader)
+	}
+	if got := form["client_id"]; len(got) != 1 || got[0] != "pkce-client" {
+		t.Errorf("client_id = %v, want [pkce-client]", got)
+	}
+	if _, ok := form["client_secret"]; ok {
+		t.Error("client_secret must not be sent in PKCE mode")
+	}
+	if got := form["refresh_token"]; len(got) != 1 || got[0] != "old-refresh" {
+		t.Errorf("refresh_token = %v, want [old-refresh]", got)
+	}
+}
+
+// TestClient_OwnerHashChanged_FlagsTransferred verifies that a refreshed token
+// with a different owner hash flags the character as transferred and is not
+// handed to the ESI client.
+func TestClient_OwnerHashChanged_FlagsTransferred(t *testing.T) {
+	srv := newTokenServer(t, unsignedJWT(`{"owner":"new-owner"}`), "new-refresh")
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			42: {ID: 42, OwnerHash: "old-owner", RefreshToken: "old-refresh", TokenExpiry: time.Now().Add(-time.Hour)},
+		},
+	}
+	inner := &mockESI{}
+	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
+
+	_, _, err := client.GetCharacterBlueprints(context.Background(), 42, "")
+	if !errors.Is(err, auth.ErrCharacterTransferred) {
+		t.Fatalf("error = %v, want ErrCharacterTransferred", err)
+	}
+	if inner.tokenSeen != "" {
+		t.Errorf("inner ESI was called with token %q, want no call", inner.tokenSeen)
+	}
+	// The rotated refresh token must still be saved, with the new owner hash:
+	// UpdateCharacterTokens flags the character in the same statement, so no
+	// separate MarkCharacterTransferred may be needed.
+	if len(q.transferred) != 0 {
+		t.Errorf("MarkCharacterTransferred called for %v, want the flag set by UpdateCharacterTokens", q.transferred)
+	}
+	if len(q.tokenUpdates) != 1 || q.tokenUpdates[0].OwnerHash != "new-owner" || q.tokenUpdates[0].RefreshToken != "new-refresh" {
+		t.Errorf("token updates = %+v, want one update with the new owner hash and refresh token", q.tokenUpdates)
+	}
+}
+
+// TestClient_OwnerHashRecordedWhenUnknown verifies that a character stored
+// before owner hashes were tracked gets its hash recorded without being flagged.
+func TestClient_OwnerHashRecordedWhenUnknown(t *testing.T) {
+	srv := newTokenServer(t, unsignedJWT(`{"owner":"first-owner"}`), "new-refresh")
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			42: {ID: 42, RefreshToken: "old-refresh", TokenExpiry: time.Now().Add(-time.Hour)},
+		},
+	}
+	client := auth.NewClient(&mockESI{}, q, newConf(srv.URL), srv.Client())
+
+	if _, _, err := client.GetCharacterBlueprints(context.Background(), 42, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(q.transferred) != 0 {
+		t.Errorf("MarkCharacterTransferred called for %v, want no calls", q.transferred)
+	}
+	if len(q.tokenUpdates) != 1 || q.tokenUpdates[0].OwnerHash != "first-owner" {
+		t.Errorf("token updates = %+v, want owner hash %q", q.tokenUpdates, "first-owner")
+	}
+}
+
+// TestClient_Transferred_SkipsRefresh verifies that a character flagged as
+// transferred is not used, even with a valid stored token.
+func TestClient_Transferred_SkipsRefresh(t *testing.T) {
+	var calls int
+	srv := newTokenErrorServer(t, http.StatusBadRequest, "invalid_request", &calls)
+
+	q := &mockQuerier{
+		characters: map[int64]store.Character{
+			1: {ID: 1, Transferred: 1, AccessToken: "sold-token", TokenExpiry: time.Now().Add(time.Hour)},
+			2: {ID: 2, AccessToken: "healthy-token", TokenExpiry: time.Now().Add(time.Hour)},
+		},
+	}
+	inner := &mockESI{}
+	client := auth.NewClient(inner, q, newConf(srv.URL), srv.Client())
+
+	if _, _, err := client.GetCharacterBlueprints(context.Background(), 1, ""); !errors.Is(err, auth.ErrCharacterTransferred) {
+		t.Fatalf("error = %v, want ErrCharacterTransferred", err)
+	}
+	if calls != 0 {
+		t.Errorf("token endpoint called %d times, want 0", calls)
+	}
+
+	// Structure lookups pick a character that is not transferred.
+	if _, err := client.GetUniverseStructure(context.Background(), 1_000_000_000_001, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if q.upsertCalls[0].AccessToken != "refreshed-struct-token" {
-		t.Errorf("saved AccessToken = %q, want %q", q.upsertCalls[0].AccessToken, "refreshed-struct-token")
+	if inner.structTokenSeen != "healthy-token" {
+		t.Errorf("inner ESI structure token = %q, want %q", inner.structTokenSeen, "healthy-token")
 	}
 }
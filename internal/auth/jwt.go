@@ -0,0 +1,312 @@
+package auth
+
+// jwt.go: local validation of EVE SSO access tokens (JWTs) against the signing
+// keys published in the SSO JWKS, replacing the round trip to /verify.
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//nolint:gosec // G101: public EVE SSO endpoint, not a credential
+const eveMetadataURL = "https://login.eveonline.com/.well-known/oauth-authorization-server"
+
+const (
+	// eveAudience is the audience EVE SSO adds to every access token next to
+	// the application's client ID.
+	eveAudience = "EVE Online"
+
+	// jwksCacheTTL is how long fetched signing keys are used before the
+	// metadata and JWKS are fetched again.
+	jwksCacheTTL = 24 * time.Hour
+
+	// jwksMinRefetch limits how often an unknown key ID triggers a refetch,
+	// so a stream of bad tokens cannot hammer EVE SSO.
+	jwksMinRefetch = time.Minute
+
+	// jwtClockSkew is the tolerance applied to the exp claim.
+	jwtClockSkew = 30 * time.Second
+
+	// characterSubjectPrefix prefixes the character ID in the sub claim.
+	characterSubjectPrefix = "CHARACTER:EVE:"
+)
+
+// ErrInvalidToken is returned when an access token fails local validation.
+var ErrInvalidToken = errors.New("invalid access token")
+
+// stringList is a JWT claim that holds either a single string or an array of
+// strings. EVE SSO encodes "scp" and "aud" this way.
+type stringList []string
+
+func (s *stringList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*s = stringList{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("claim is neither a string nor an array of strings: %w", err)
+	}
+	*s = many
+	return nil
+}
+
+// tokenClaims is the subset of EVE SSO access token claims used by Auspex.
+type tokenClaims struct {
+	Subject   string     `json:"sub"`
+	Name      string     `json:"name"`
+	Owner     string     `json:"owner"`
+	Scopes    stringList `json:"scp"`
+	Issuer    string     `json:"iss"`
+	Audience  stringList `json:"aud"`
+	ExpiresAt int64      `json:"exp"`
+}
+
+// tokenHeader is the JOSE header of a JWT.
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// characterIdentity is the character an access token was issued to.
+type characterIdentity struct {
+	CharacterID   int64
+	CharacterName string
+	OwnerHash     string
+	Scopes        []string
+}
+
+// ssoMetadata is the relevant subset of the EVE SSO OAuth 2.0 authorization
+// server metadata document (RFC 8414).
+type ssoMetadata struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// jwkSet is a JSON Web Key Set (RFC 7517).
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// jwk is a single JSON Web Key. Only RSA keys are used: EVE SSO signs access
+// tokens with RS256.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// validateToken checks the access token signature against the cached JWKS and
+// its issuer, audience and expiry claims, and returns the character it was
+// issued to. Failures wrap ErrInvalidToken.
+func (p *Provider) validateToken(ctx context.Context, accessToken string) (characterIdentity, error) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return characterIdentity{}, fmt.Errorf("%w: not a JWT", ErrInvalidToken)
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return characterIdentity{}, fmt.Errorf("%w: header: %w", ErrInvalidToken, err)
+	}
+	if header.Alg != "RS256" {
+		return characterIdentity{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+
+	key, issuer, err := p.signingKey(ctx, header.Kid)
+	if err != nil {
+		return characterIdentity{}, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return characterIdentity{}, fmt.Errorf("%w: decoding signature: %w", ErrInvalidToken, err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return characterIdentity{}, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims tokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return characterIdentity{}, fmt.Errorf("%w: claims: %w", ErrInvalidToken, err)
+	}
+	if !issuerMatches(claims.Issuer, issuer) {
+		return characterIdentity{}, fmt.Errorf("%w: issuer %q, want %q", ErrInvalidToken, claims.Issuer, issuer)
+	}
+	if !slices.Contains(claims.Audience, p.conf.ClientID) || !slices.Contains(claims.Audience, eveAudience) {
+		return characterIdentity{}, fmt.Errorf("%w: audience %v does not include this application", ErrInvalidToken, []string(claims.Audience))
+	}
+	if time.Now().After(time.Unix(claims.ExpiresAt, 0).Add(jwtClockSkew)) {
+		return characterIdentity{}, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+
+	idStr, ok := strings.CutPrefix(claims.Subject, characterSubjectPrefix)
+	if !ok {
+		return characterIdentity{}, fmt.Errorf("%w: subject %q is not a character", ErrInvalidToken, claims.Subject)
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		return characterIdentity{}, fmt.Errorf("%w: invalid character ID in subject %q", ErrInvalidToken, claims.Subject)
+	}
+
+	return characterIdentity{
+		CharacterID:   id,
+		CharacterName: claims.Name,
+		OwnerHash:     claims.Owner,
+		Scopes:        claims.Scopes,
+	}, nil
+}
+
+// parseTokenClaims reads the claims of an EVE SSO access token without
+// checking its signature. It is used on tokens just received from the EVE SSO
+// token endpoint over TLS during refresh; the login callback uses validateToken.
+func parseTokenClaims(accessToken string) (tokenClaims, error) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return tokenClaims{}, fmt.Errorf("access token is not a JWT")
+	}
+	var claims tokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return tokenClaims{}, fmt.Errorf("reading JWT payload: %w", err)
+	}
+	return claims, nil
+}
+
+// signingKey returns the RSA key with the given key ID and the expected token
+// issuer. Keys are cached for jwksCacheTTL; an unknown key ID triggers a
+// refetch (at most once per jwksMinRefetch) to pick up rotated keys. If a
+// refetch fails, a previously cached key is still used.
+func (p *Provider) signingKey(ctx context.Context, kid string) (*rsa.PublicKey, string, error) {
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+
+	now := time.Now()
+	key, known := p.keys[kid]
+	stale := now.Sub(p.keysFetchedAt) > jwksCacheTTL
+	if stale || (!known && now.Sub(p.keysFetchedAt) > jwksMinRefetch) {
+		issuer, keys, err := p.fetchKeys(ctx)
+		switch {
+		case err == nil:
+			p.issuer, p.keys, p.keysFetchedAt = issuer, keys, now
+			key, known = keys[kid]
+		case known:
+			log.Printf("auth: refreshing EVE SSO signing keys failed, using cached keys: %v", err)
+		default:
+			return nil, "", fmt.Errorf("fetching EVE SSO signing keys: %w", err)
+		}
+	}
+	if !known {
+		return nil, "", fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, kid)
+	}
+	return key, p.issuer, nil
+}
+
+// fetchKeys reads the SSO metadata document and the JWKS it points to.
+func (p *Provider) fetchKeys(ctx context.Context) (string, map[string]*rsa.PublicKey, error) {
+	var meta ssoMetadata
+	if err := p.getJSON(ctx, p.metadataURL, &meta); err != nil {
+		return "", nil, fmt.Errorf("SSO metadata: %w", err)
+	}
+	if meta.Issuer == "" || meta.JWKSURI == "" {
+		return "", nil, fmt.Errorf("SSO metadata is missing issuer or jwks_uri")
+	}
+
+	var set jwkSet
+	if err := p.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return "", nil, fmt.Errorf("JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+		pub, err := k.rsaPublicKey()
+		if err != nil {
+			return "", nil, fmt.Errorf("JWKS key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return "", nil, fmt.Errorf("JWKS contains no RS256 keys")
+	}
+	return meta.Issuer, keys, nil
+}
+
+// getJSON fetches url and decodes its JSON body into v.
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("building request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req) //nolint:gosec // URL comes from config or the SSO metadata document
+	if err != nil {
+		return fmt.Errorf("calling %s: %w", url, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // closing response body on read path
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("parsing response: %w", err)
+	}
+	return nil
+}
+
+// rsaPublicKey decodes the modulus and exponent of an RSA JWK.
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("decoding modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("decoding exponent: %w", err)
+	}
+	exp := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid RSA key parameters")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+}
+
+// issuerMatches reports whether the iss claim matches the metadata issuer.
+// EVE SSO has issued tokens both with and without the https:// scheme.
+func issuerMatches(claim, issuer string) bool {
+	return claim == issuer || "https://"+claim == issuer
+}
+
+// decodeSegment base64url-decodes a JWT segment and unmarshals it into v.
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("decoding: %w", err)
+	}
+	return json.Unmarshal(data, v)
+}
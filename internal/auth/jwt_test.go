@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testIssuer = "https://login.eveonline.com"
+
+// testSigningKey is shared by all tests: RSA key generation is slow.
+var testSigningKey = sync.OnceValue(func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+})
+
+// testSSO is a stand-in for EVE SSO: it serves the metadata document and JWKS
+// and signs access tokens.
+type testSSO struct {
+	key       *rsa.PrivateKey
+	kid       string
+	jwksCalls atomic.Int32
+}
+
+func newTestSSO() *testSSO {
+	return &testSSO{key: testSigningKey(), kid: "JWT-Signature-Key"}
+}
+
+// register adds the metadata (/.well-known/oauth-authorization-server) and
+// JWKS (/jwks) handlers to mux.
+func (s *testSSO) register(t *testing.T, mux *http.ServeMux) {
+	t.Helper()
+	mux.HandleFunc("/.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ssoMetadata{
+			Issuer:  testIssuer,
+			JWKSURI: "http://" + r.Host + "/jwks",
+		}); err != nil {
+			t.Errorf("encode metadata: %v", err)
+		}
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		s.jwksCalls.Add(1)
+		pub := s.key.PublicKey
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(jwkSet{Keys: []jwk{
+			// EVE SSO also publishes an ES256 key; it must be ignored.
+			{Kid: "JWT-Signature-Key-ES256", Kty: "EC", Alg: "ES256"},
+			{
+				Kid: s.kid,
+				Kty: "RSA",
+				Alg: "RS256",
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			},
+		}}); err != nil {
+			t.Errorf("encode jwks: %v", err)
+		}
+	})
+}
+
+// sign returns an RS256 JWT with the given claims.
+func (s *testSSO) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": s.kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatalf("marshal header: %v", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed + "." + enc.EncodeToString(sig)
+}
+
+// characterClaims returns valid access token claims for the given character.
+func characterClaims(id int64, name string, scopes ...string) map[string]any {
+	return map[string]any{
+		"sub":   "CHARACTER:EVE:" + strconv.FormatInt(id, 10),
+		"name":  name,
+		"owner": "owner-hash-" + strconv.FormatInt(id, 10),
+		"scp":   scopes,
+		"iss":   "login.eveonline.com",
+		"aud":   []string{"test-client-id", "EVE Online"},
+		"exp":   time.Now().Add(20 * time.Minute).Unix(),
+	}
+}
+
+// newJWTTestProvider returns a Provider whose metadata URL points at a
+// stand-in SSO server.
+func newJWTTestProvider(t *testing.T) (*Provider, *testSSO) {
+	t.Helper()
+	sso := newTestSSO()
+	mux := http.NewServeMux()
+	sso.register(t, mux)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	p := newTestProvider(t, ts.Client(), nil)
+	p.metadataURL = ts.URL + "/.well-known/oauth-authorization-server"
+	return p, sso
+}
+
+func TestValidateToken_ValidClaims(t *testing.T) {
+	p, sso := newJWTTestProvider(t)
+
+	token := sso.sign(t, characterClaims(12345, "TinkerBear", "esi-characters.read_blueprints.v1"))
+	got, err := p.validateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("validateToken: %v", err)
+	}
+	if got.CharacterID != 12345 || got.CharacterName != "TinkerBear" || got.OwnerHash != "owner-hash-12345" {
+		t.Errorf("identity = %+v, want 12345/TinkerBear/owner-hash-12345", got)
+	}
+	if !slices.Equal(got.Scopes, []string{"esi-characters.read_blueprints.v1"}) {
+		t.Errorf("scopes = %v", got.Scopes)
+	}
+}
+
+func TestValidateToken_RejectsBadClaims(t *testing.T) {
+	cases := map[string]func(c map[string]any){
+		"expired":         func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"wrong issuer":    func(c map[string]any) { c["iss"] = "https://evil.example.com" },
+		"wrong audience":  func(c map[string]any) { c["aud"] = []string{"other-client", "EVE Online"} },
+		"no EVE audience": func(c map[string]any) { c["aud"] = "test-client-id" },
+		"not a character": func(c map[string]any) { c["sub"] = "CORPORATION:EVE:1" },
+		"zero character":  func(c map[string]any) { c["sub"] = "CHARACTER:EVE:0" },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			p, sso := newJWTTestProvider(t)
+			claims := characterClaims(1, "X")
+			mutate(claims)
+			_, err := p.validateToken(context.Background(), sso.sign(t, claims))
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestValidateToken_RejectsBadSignature(t *testing.T) {
+	p, sso := newJWTTestProvider(t)
+
+	token := sso.sign(t, characterClaims(1, "X"))
+	// Attach the signature of a token issued to a different character.
+	forged := sso.sign(t, characterClaims(2, "Y"))
+	token = token[:strings.LastIndex(token, ".")] + forged[strings.LastIndex(forged, "."):]
+
+	if _, err := p.validateToken(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestValidateToken_RejectsUnsignedToken(t *testing.T) {
+	p, _ := newJWTTestProvider(t)
+
+	enc := base64.RawURLEncoding
+	token := enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		enc.EncodeToString([]byte(`{"sub":"CHARACTER:EVE:1"}`)) + "."
+	if _, err := p.validateToken(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("err = %v, want ErrInvalidToken", err)
+	}
+}
+
+// TestValidateToken_CachesJWKS verifies that the signing keys are fetched once
+// and reused for later tokens.
+func TestValidateToken_CachesJWKS(t *testing.T) {
+	p, sso := newJWTTestProvider(t)
+
+	for i := range 3 {
+		if _, err := p.validateToken(context.Background(), sso.sign(t, characterClaims(int64(i+1), "X"))); err != nil {
+			t.Fatalf("validateToken #%d: %v", i, err)
+		}
+	}
+	if n := sso.jwksCalls.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+}
+
+// TestValidateToken_UnknownKeyRefetches verifies that a token signed with a
+// key ID not in the cache triggers a refetch (key rotation), but not more
+// often than jwksMinRefetch.
+func TestValidateToken_UnknownKeyRefetches(t *testing.T) {
+	p, sso := newJWTTestProvider(t)
+
+	if _, err := p.validateToken(context.Background(), sso.sign(t, characterClaims(1, "X"))); err != nil {
+		t.Fatalf("validateToken: %v", err)
+	}
+
+	sso.kid = "rotated-key"
+	token := sso.sign(t, characterClaims(1, "X"))
+
+	// Cache was just filled: the unknown key is rejected without a refetch.
+	if _, err := p.validateToken(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("err = %v, want ErrInvalidToken", err)
+	}
+	if n := sso.jwksCalls.Load(); n != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", n)
+	}
+
+	// Once jwksMinRefetch has passed, the rotated key is picked up.
+	p.keysMu.Lock()
+	p.keysFetchedAt = time.Now().Add(-2 * jwksMinRefetch)
+	p.keysMu.Unlock()
+	if _, err := p.validateToken(context.Background(), token); err != nil {
+		t.Fatalf("validateToken after rotation: %v", err)
+	}
+	if n := sso.jwksCalls.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+}
+
+// TestValidateToken_MetadataUnavailable verifies that validation fails when
+// the signing keys cannot be fetched and none are cached.
+func TestValidateToken_MetadataUnavailable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	p := newTestProvider(t, ts.Client(), nil)
+	p.metadataURL = ts.URL + "/.well-known/oauth-authorization-server"
+
+	token := newTestSSO().sign(t, characterClaims(1, "X"))
+	if _, err := p.validateToken(context.Background(), token); err == nil {
+		t.Fatal("expected error when the metadata document is unavailable")
+	}
+}
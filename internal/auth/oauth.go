@@ -1,22 +1,29 @@
 // Package auth implements the EVE SSO OAuth2 flow.
 // Responsibilities: generate authorization URL, exchange code for tokens,
-// refresh tokens on expiry, verify character identity via /verify.
+// refresh tokens on expiry, validate access tokens (JWTs) against the EVE SSO
+// signing keys to establish the character's identity.
 // Uses golang.org/x/oauth2.
 package auth
 
 import (
 	"context"
 	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
 
+	"github.com/dpleshakov/auspex/internal/esi"
 	"github.com/dpleshakov/auspex/internal/store"
 )
 
@@ -24,31 +31,36 @@ import (
 // does not match any pending authorization request.
 var ErrInvalidState = errors.New("invalid or expired OAuth state")
 
-// oauth.go: authorization URL generation, code→token exchange, /verify call.
+// oauth.go: authorization URL generation, code→token exchange.
+
+const (
+	// StateTTL is how long an authorization request can take: a state older
+	// than this is rejected by HandleCallback and removed by SweepStates.
+	StateTTL = 10 * time.Minute
+
+	// stateSweepInterval is how often SweepStates removes expired states.
+	stateSweepInterval = time.Minute
+)
 
 //nolint:gosec // G101: these are public EVE SSO endpoints, not credentials
 const (
-	eveAuthURL   = "https://login.eveonline.com/v2/oauth/authorize"
-	eveTokenURL  = "https://login.eveonline.com/v2/oauth/token"
-	eveVerifyURL = "https://esi.evetech.net/verify"
-	esiBaseURL   = "https://esi.evetech.net/latest"
+	eveAuthURL  = "https://login.eveonline.com/v2/oauth/authorize"
+	eveTokenURL = "https://login.eveonline.com/v2/oauth/token"
+	esiBaseURL  = "https://esi.evetech.net/latest"
 )
 
 // eveScopes are the ESI OAuth2 scopes required for Auspex MVP.
 var eveScopes = []string{
-	"esi-assets.read_corporation_assets.v1",
-	"esi-characters.read_blueprints.v1",
-	"esi-corporations.read_blueprints.v1",
-	"esi-corporations.read_facilities.v1",
-	"esi-industry.read_character_jobs.v1",
-	"esi-industry.read_corporation_jobs.v1",
-	"esi-universe.read_structures.v1",
-}
-
-// verifyResponse is the JSON payload returned by EVE SSO /verify.
-type verifyResponse struct {
-	CharacterID   int64  `json:"CharacterID"`
-	CharacterName string `json:"CharacterName"`
+	esi.ScopeCharacterAssets,
+	esi.ScopeCorporationAssets,
+	esi.ScopeCharacterBlueprints,
+	esi.ScopeCorporationBlueprints,
+	esi.ScopeCorporationDivisions,
+	esi.ScopeCorporationFacilities,
+	esi.ScopeCharacterJobs,
+	esi.ScopeCorporationJobs,
+	esi.ScopeCharacterRoles,
+	esi.ScopeStructures,
 }
 
 // characterInfoResponse is the relevant subset of GET /characters/{id}/.
@@ -61,69 +73,156 @@ type corporationInfoResponse struct {
 	Name string `json:"name"`
 }
 
+// pendingState is an authorization request awaiting its callback.
+type pendingState struct {
+	verifier  string // PKCE code verifier; "" outside PKCE mode
+	createdAt time.Time
+}
+
 // Provider manages the EVE SSO OAuth2 authorization code flow.
+// Without a client secret it runs in PKCE mode (EVE SSO "native application"):
+// each authorization request carries an S256 code challenge and the client ID
+// is sent in the token request body instead of HTTP basic auth.
 // It is safe for concurrent use.
 type Provider struct {
-	conf       *oauth2.Config
-	store      store.Querier
-	httpClient *http.Client
-	verifyURL  string
-	esiBaseURL string
-	states     map[string]struct{}
-	mu         sync.Mutex
+	conf        *oauth2.Config
+	store       store.Querier
+	httpClient  *http.Client
+	metadataURL string
+	esiBaseURL  string
+	states      map[string]pendingState
+	mu          sync.Mutex
+	tokens      *Client // its cached access tokens are dropped on login; may be nil
+
+	// JWKS cache, guarded by keysMu. See signingKey.
+	keysMu        sync.Mutex
+	issuer        string
+	keys          map[string]*rsa.PublicKey
+	keysFetchedAt time.Time
 }
 
 // NewProvider constructs a Provider using the given EVE SSO credentials and store.
-// httpClient is used for the /verify call and injected into OAuth2 token exchange.
+// An empty clientSecret selects PKCE mode.
+// metadataURL is the SSO authorization server metadata document that points
+// to the token signing keys; pass "" to use EVE SSO's.
+// httpClient is used for SSO and ESI calls and injected into OAuth2 token exchange.
 // Pass nil to use http.DefaultClient.
-func NewProvider(clientID, clientSecret, callbackURL string, q store.Querier, httpClient *http.Client) *Provider {
+func NewProvider(clientID, clientSecret, callbackURL, metadataURL string, q store.Querier, httpClient *http.Client) *Provider {
 	if httpClient == nil {
 		httpClient = http.DefaultClient
 	}
+	if metadataURL == "" {
+		metadataURL = eveMetadataURL
+	}
+	endpoint := oauth2.Endpoint{
+		AuthURL:  eveAuthURL,
+		TokenURL: eveTokenURL,
+	}
+	if clientSecret == "" {
+		// Public clients identify themselves with client_id in the form body;
+		// this also applies to refreshes made through OAuthConfig.
+		endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
 	return &Provider{
 		conf: &oauth2.Config{
 			ClientID:     clientID,
 			ClientSecret: clientSecret,
 			RedirectURL:  callbackURL,
 			Scopes:       eveScopes,
-			Endpoint: oauth2.Endpoint{
-				AuthURL:  eveAuthURL,
-				TokenURL: eveTokenURL,
-			},
+			Endpoint:     endpoint,
 		},
-		store:      q,
-		httpClient: httpClient,
-		verifyURL:  eveVerifyURL,
-		esiBaseURL: esiBaseURL,
-		states:     make(map[string]struct{}),
+		store:       q,
+		httpClient:  httpClient,
+		metadataURL: metadataURL,
+		esiBaseURL:  esiBaseURL,
+		states:      make(map[string]pendingState),
 	}
 }
 
 // GenerateAuthURL returns the EVE SSO authorization URL and the random state value.
-// The state is stored internally and consumed exactly once by HandleCallback.
-func (p *Provider) GenerateAuthURL() (string, error) {
+// The state is stored internally and consumed exactly once by HandleCallback,
+// within StateTTL.
+//
+// The URL always requests every scope Auspex requires. It also serves to grant
+// the scopes a character is missing: the tokens of a login carry only the
+// scopes it was granted, and replace the character's stored tokens and scopes,
+// so asking for only the missing ones would drop the others.
+func (p *Provider) GenerateAuthURL() (string, string, error) {
+	return p.authURL()
+}
+
+// SetTokenCache makes HandleCallback drop the access token c has cached for
+// the character that logged in, so that the tokens of the login are used
+// from then on. Call it before serving logins.
+func (p *Provider) SetTokenCache(c *Client) {
+	p.tokens = c
+}
+
+// PKCE reports whether the provider runs in PKCE mode (no client secret).
+func (p *Provider) PKCE() bool {
+	return p.conf.ClientSecret == ""
+}
+
+// authURL registers a new state and builds the authorization URL with opts.
+// In PKCE mode a fresh code verifier is stored with the state and its S256
+// challenge is added to the URL.
+func (p *Provider) authURL(opts ...oauth2.AuthCodeOption) (string, string, error) {
 	state, err := randomState()
 	if err != nil {
-		return "", fmt.Errorf("generating OAuth state: %w", err)
+		return "", "", fmt.Errorf("generating OAuth state: %w", err)
+	}
+	var verifier string
+	if p.PKCE() {
+		verifier = oauth2.GenerateVerifier()
+		opts = append(opts, oauth2.S256ChallengeOption(verifier))
 	}
 	p.mu.Lock()
-	p.states[state] = struct{}{}
+	p.states[state] = pendingState{verifier: verifier, createdAt: time.Now()}
 	p.mu.Unlock()
-	return p.conf.AuthCodeURL(state), nil
+	return p.conf.AuthCodeURL(state, opts...), state, nil
+}
+
+// SweepStates removes expired states every minute until ctx is cancelled,
+// so abandoned logins do not accumulate.
+func (p *Provider) SweepStates(ctx context.Context) {
+	ticker := time.NewTicker(stateSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.removeExpiredStates(time.Now())
+		}
+	}
+}
+
+// removeExpiredStates deletes states created more than StateTTL before now.
+func (p *Provider) removeExpiredStates(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for state, pending := range p.states {
+		if now.Sub(pending.createdAt) > StateTTL {
+			delete(p.states, state)
+		}
+	}
 }
 
 // HandleCallback validates the OAuth2 state, exchanges the authorization code
-// for tokens, verifies the character via ESI /verify, and upserts the character
+// for tokens, validates the access token to identify the character, and upserts the character
 // in the store. Returns the character ID on success.
+// If the character is already stored with a different owner hash, it was
+// transferred to another EVE account: the new tokens are saved but the
+// character is flagged as transferred and is not synced until confirmed.
 func (p *Provider) HandleCallback(ctx context.Context, code, state string) (int64, error) {
 	p.mu.Lock()
-	_, valid := p.states[state]
+	pending, valid := p.states[state]
 	if valid {
 		delete(p.states, state)
 	}
 	p.mu.Unlock()
 
-	if !valid {
+	if !valid || time.Since(pending.createdAt) > StateTTL {
 		return 0, ErrInvalidState
 	}
 
@@ -131,14 +230,18 @@ func (p *Provider) HandleCallback(ctx context.Context, code, state string) (int6
 	// This enables testing without real network calls.
 	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
 
-	token, err := p.conf.Exchange(ctx, code)
+	var opts []oauth2.AuthCodeOption
+	if pending.verifier != "" {
+		opts = append(opts, oauth2.VerifierOption(pending.verifier))
+	}
+	token, err := p.conf.Exchange(ctx, code, opts...)
 	if err != nil {
 		return 0, fmt.Errorf("exchanging authorization code: %w", err)
 	}
 
-	char, err := p.callVerify(ctx, token.AccessToken)
+	char, err := p.validateToken(ctx, token.AccessToken)
 	if err != nil {
-		return 0, fmt.Errorf("verifying character: %w", err)
+		return 0, fmt.Errorf("validating access token: %w", err)
 	}
 
 	charInfo, err := p.callCharacterInfo(ctx, char.CharacterID)
@@ -151,31 +254,79 @@ func (p *Provider) HandleCallback(ctx context.Context, code, state string) (int6
 		return 0, fmt.Errorf("fetching corporation info for %d: %w", charInfo.CorporationID, err)
 	}
 
+	existing, known, err := p.storedCharacter(ctx, char.CharacterID)
+	if err != nil {
+		return 0, err
+	}
+	// An owner hash differing from the stored one means the character was
+	// transferred. Characters stored before owner hashes were recorded (empty
+	// hash) are not considered transferred.
+	transferred := known && existing.OwnerHash != "" && existing.OwnerHash != char.OwnerHash
+
+	// A known character's corporation is only updated by the sync worker's
+	// affiliation refresh, which also hands the old corporation's delegate
+	// over and logs the change. The login triggers a sync right away.
+	corporationID, corporationName := charInfo.CorporationID, corpInfo.Name
+	if known && existing.CorporationID != 0 {
+		corporationID, corporationName = existing.CorporationID, existing.CorporationName
+	}
+
 	if err := p.store.UpsertCharacter(ctx, store.UpsertCharacterParams{
 		ID:              char.CharacterID,
 		Name:            char.CharacterName,
 		AccessToken:     token.AccessToken,
 		RefreshToken:    token.RefreshToken,
 		TokenExpiry:     token.Expiry,
-		CorporationID:   charInfo.CorporationID,
-		CorporationName: corpInfo.Name,
+		CorporationID:   corporationID,
+		CorporationName: corporationName,
+		Scopes:          strings.Join(char.Scopes, " "),
+		OwnerHash:       char.OwnerHash,
 	}); err != nil {
 		return 0, fmt.Errorf("saving character %d: %w", char.CharacterID, err)
 	}
 
-	if !isNPCCorporation(charInfo.CorporationID) {
+	// The cached access token predates this login: it may lack scopes just
+	// granted, or belong to the previous owner.
+	p.tokens.forget(char.CharacterID)
+	if transferred {
+		// UpsertCharacter flagged the character along with the new hash.
+		log.Printf("auth: character %d changed owner; sync paused until the transfer is confirmed", char.CharacterID)
+	}
+
+	if corporationID == charInfo.CorporationID && !esi.IsNPCCorporation(corporationID) {
+		delegate := sql.NullInt64{Int64: char.CharacterID, Valid: true}
 		if err := p.store.InsertOrIgnoreCorporation(ctx, store.InsertOrIgnoreCorporationParams{
-			ID:         charInfo.CorporationID,
-			Name:       corpInfo.Name,
-			DelegateID: char.CharacterID,
+			ID:         corporationID,
+			Name:       corporationName,
+			DelegateID: delegate,
+		}); err != nil {
+			return 0, fmt.Errorf("saving corporation %d: %w", corporationID, err)
+		}
+		// A corporation left without a delegate gets this character.
+		if err := p.store.AdoptCorporation(ctx, store.AdoptCorporationParams{
+			DelegateID: delegate,
+			ID:         corporationID,
 		}); err != nil {
-			return 0, fmt.Errorf("saving corporation %d: %w", charInfo.CorporationID, err)
+			return 0, fmt.Errorf("assigning delegate of corporation %d: %w", corporationID, err)
 		}
 	}
 
 	return char.CharacterID, nil
 }
 
+// storedCharacter loads the character if it is already stored. known is
+// false for a character logging in for the first time.
+func (p *Provider) storedCharacter(ctx context.Context, characterID int64) (c store.Character, known bool, err error) {
+	c, err = p.store.GetCharacter(ctx, characterID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.Character{}, false, nil
+	}
+	if err != nil {
+		return store.Character{}, false, fmt.Errorf("loading character %d: %w", characterID, err)
+	}
+	return c, true, nil
+}
+
 // callCharacterInfo fetches the character's public info (corporation_id) from ESI.
 func (p *Provider) callCharacterInfo(ctx context.Context, characterID int64) (characterInfoResponse, error) {
 	url := fmt.Sprintf("%s/characters/%d/", p.esiBaseURL, characterID)
@@ -240,55 +391,12 @@ func (p *Provider) callCorporationInfo(ctx context.Context, corporationID int64)
 	return v, nil
 }
 
-// callVerify calls EVE SSO /verify with the access token and returns character info.
-func (p *Provider) callVerify(ctx context.Context, accessToken string) (verifyResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.verifyURL, http.NoBody)
-	if err != nil {
-		return verifyResponse{}, fmt.Errorf("building verify request: %w", err)
-	}
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := p.httpClient.Do(req) //nolint:gosec // URL is sourced from validated config, not user input
-	if err != nil {
-		return verifyResponse{}, fmt.Errorf("calling /verify: %w", err)
-	}
-	defer resp.Body.Close() //nolint:errcheck // closing response body on read path, error is not actionable
-
-	if resp.StatusCode != http.StatusOK {
-		return verifyResponse{}, fmt.Errorf("verify returned HTTP %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return verifyResponse{}, fmt.Errorf("reading verify response: %w", err)
-	}
-
-	var v verifyResponse
-	if err := json.Unmarshal(body, &v); err != nil {
-		return verifyResponse{}, fmt.Errorf("parsing verify response: %w", err)
-	}
-
-	if v.CharacterID <= 0 {
-		return verifyResponse{}, fmt.Errorf("verify returned invalid CharacterID %d", v.CharacterID)
-	}
-
-	return v, nil
-}
-
 // OAuthConfig returns the underlying *oauth2.Config so that auth.NewClient
 // can share the same credentials for automatic token refresh.
 func (p *Provider) OAuthConfig() *oauth2.Config {
 	return p.conf
 }
 
-// isNPCCorporation reports whether the given EVE corporation ID belongs to an
-// NPC corporation. NPC corp IDs occupy the range 1000000–2000000 inclusive.
-// Player corps fall outside this range and must be tracked in the corporations table.
-func isNPCCorporation(corpID int64) bool {
-	return corpID >= 1_000_000 && corpID <= 2_000_000
-}
-
 // randomState generates a cryptographically random 32-character hex string.
 func randomState() (string, error) {
 	b := make([]byte, 16)
@@ -2,11 +2,16 @@ package auth
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
 
 	"github.com/dpleshakov/auspex/internal/store"
 )
@@ -15,10 +20,26 @@ import (
 // Embed the interface so unimplemented methods panic clearly if called.
 type mockQuerier struct {
 	store.Querier
+	existing                 map[int64]store.Character
 	upsertCalled             bool
 	upsertParams             store.UpsertCharacterParams
 	insertOrIgnoreCorpCalled bool
 	insertOrIgnoreCorpParams store.InsertOrIgnoreCorporationParams
+	adoptCorpCalls           []store.AdoptCorporationParams
+	transferredCalls         []int64
+}
+
+func (m *mockQuerier) GetCharacter(_ context.Context, id int64) (store.Character, error) {
+	c, ok := m.existing[id]
+	if !ok {
+		return store.Character{}, sql.ErrNoRows
+	}
+	return c, nil
+}
+
+func (m *mockQuerier) MarkCharacterTransferred(_ context.Context, id int64) error {
+	m.transferredCalls = append(m.transferredCalls, id)
+	return nil
 }
 
 // addCharCorpHandlers registers /characters/{id}/ and /corporations/{id}/ handlers
@@ -51,6 +72,11 @@ func (m *mockQuerier) InsertOrIgnoreCorporation(_ context.Context, arg store.Ins
 	return nil
 }
 
+func (m *mockQuerier) AdoptCorporation(_ context.Context, arg store.AdoptCorporationParams) error {
+	m.adoptCorpCalls = append(m.adoptCorpCalls, arg)
+	return nil
+}
+
 // newTestProvider creates a Provider with the given httpClient and querier.
 // Defaults are used when arguments are nil.
 func newTestProvider(t *testing.T, httpClient *http.Client, q store.Querier) *Provider {
@@ -61,7 +87,7 @@ func newTestProvider(t *testing.T, httpClient *http.Client, q store.Querier) *Pr
 	if q == nil {
 		q = &mockQuerier{}
 	}
-	return NewProvider("test-client-id", "test-client-secret", "http://localhost/callback", q, httpClient)
+	return NewProvider("test-client-id", "test-client-secret", "http://localhost/callback", "", q, httpClient)
 }
 
 // TestGenerateAuthURL_ContainsState verifies that the returned URL contains a
@@ -69,7 +95,7 @@ func newTestProvider(t *testing.T, httpClient *http.Client, q store.Querier) *Pr
 func TestGenerateAuthURL_ContainsState(t *testing.T) {
 	p := newTestProvider(t, nil, nil)
 
-	authURL, err := p.GenerateAuthURL()
+	authURL, returned, err := p.GenerateAuthURL()
 	if err != nil {
 		t.Fatalf("GenerateAuthURL error: %v", err)
 	}
@@ -83,6 +109,9 @@ func TestGenerateAuthURL_ContainsState(t *testing.T) {
 	if state == "" {
 		t.Fatal("state parameter missing from auth URL")
 	}
+	if returned != state {
+		t.Errorf("returned state = %q, want the URL's %q", returned, state)
+	}
 
 	p.mu.Lock()
 	_, stored := p.states[state]
@@ -97,14 +126,124 @@ func TestGenerateAuthURL_ContainsState(t *testing.T) {
 func TestGenerateAuthURL_UniqueStates(t *testing.T) {
 	p := newTestProvider(t, nil, nil)
 
-	url1, _ := p.GenerateAuthURL()
-	url2, _ := p.GenerateAuthURL()
+	url1, _, _ := p.GenerateAuthURL()
+	url2, _, _ := p.GenerateAuthURL()
 
 	if url1 == url2 {
 		t.Fatal("two GenerateAuthURL calls returned identical URLs; states must be unique")
 	}
 }
 
+// TestGenerateAuthURL_PKCE verifies that without a client secret the URL
+// carries an S256 code challenge derived from the verifier stored with the state.
+func TestGenerateAuthURL_PKCE(t *testing.T) {
+	p := NewProvider("test-client-id", "", "http://localhost/callback", "", &mockQuerier{}, nil)
+	if !p.PKCE() {
+		t.Fatal("PKCE() = false without a client secret")
+	}
+
+	rawURL, _, err := p.GenerateAuthURL()
+	if err != nil {
+		t.Fatalf("GenerateAuthURL: %v", err)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse URL: %v", err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", q.Get("code_challenge_method"))
+	}
+
+	p.mu.Lock()
+	verifier := p.states[q.Get("state")].verifier
+	p.mu.Unlock()
+	if verifier == "" {
+		t.Fatal("no code verifier stored with the state")
+	}
+	if want := oauth2.S256ChallengeFromVerifier(verifier); q.Get("code_challenge") != want {
+		t.Errorf("code_challenge = %q, want %q", q.Get("code_challenge"), want)
+	}
+}
+
+// TestGenerateAuthURL_NoPKCEWithSecret verifies that a confidential client
+// (client secret set) does not use PKCE.
+func TestGenerateAuthURL_NoPKCEWithSecret(t *testing.T) {
+	p := newTestProvider(t, nil, nil)
+	if p.PKCE() {
+		t.Fatal("PKCE() = true with a client secret")
+	}
+
+	rawURL, _, err := p.GenerateAuthURL()
+	if err != nil {
+		t.Fatalf("GenerateAuthURL: %v", err)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse URL: %v", err)
+	}
+	if u.Query().Has("code_challenge") {
+		t.Error("code_challenge present for a confidential client")
+	}
+}
+
+// TestHandleCallback_PKCE verifies that in PKCE mode the code exchange sends
+// the verifier matching the challenge and client_id instead of a secret.
+func TestHandleCallback_PKCE(t *testing.T) {
+	sso := newTestSSO()
+	mux := http.NewServeMux()
+	var form url.Values
+	var authHeader string
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		form = r.PostForm
+		authHeader = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  sso.sign(t, characterClaims(1, "X")),
+			"token_type":    "Bearer",
+			"refresh_token": "ref",
+			"expires_in":    1200,
+		}); err != nil {
+			t.Errorf("encode: %v", err)
+		}
+	})
+	sso.register(t, mux)
+	addCharCorpHandlers(t, mux, 1000182, "NPC Corp")
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	p := NewProvider("test-client-id", "", "http://localhost/callback", ts.URL+"/.well-known/oauth-authorization-server", &mockQuerier{}, ts.Client())
+	p.conf.Endpoint.TokenURL = ts.URL + "/token"
+	p.esiBaseURL = ts.URL
+
+	rawURL, _, err := p.GenerateAuthURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := u.Query().Get("state")
+	challenge := u.Query().Get("code_challenge")
+
+	if _, err := p.HandleCallback(context.Background(), "code", state); err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+	if got := oauth2.S256ChallengeFromVerifier(form.Get("code_verifier")); got != challenge {
+		t.Errorf("code_verifier does not match the challenge sent in the authorization URL")
+	}
+	if form.Get("client_id") != "test-client-id" {
+		t.Errorf("client_id = %q, want %q", form.Get("client_id"), "test-client-id")
+	}
+	if form.Has("client_secret") || authHeader != "" {
+		t.Errorf("credentials sent in PKCE mode: client_secret=%v, Authorization=%q", form.Has("client_secret"), authHeader)
+	}
+}
+
 // TestHandleCallback_InvalidState verifies that an unrecognized state returns an error.
 func TestHandleCallback_InvalidState(t *testing.T) {
 	p := newTestProvider(t, nil, nil)
@@ -115,14 +254,57 @@ func TestHandleCallback_InvalidState(t *testing.T) {
 	}
 }
 
+// TestHandleCallback_ExpiredState verifies that a state older than StateTTL
+// is rejected (and consumed) even before the sweeper removes it.
+func TestHandleCallback_ExpiredState(t *testing.T) {
+	p := newTestProvider(t, nil, nil)
+
+	_, state, err := p.GenerateAuthURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.states[state] = pendingState{createdAt: time.Now().Add(-StateTTL - time.Second)}
+	p.mu.Unlock()
+
+	if _, err := p.HandleCallback(context.Background(), "anycode", state); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("err = %v, want ErrInvalidState", err)
+	}
+	p.mu.Lock()
+	_, stillPresent := p.states[state]
+	p.mu.Unlock()
+	if stillPresent {
+		t.Error("expired state was not removed")
+	}
+}
+
+// TestRemoveExpiredStates verifies that the sweeper drops only states older
+// than StateTTL.
+func TestRemoveExpiredStates(t *testing.T) {
+	p := newTestProvider(t, nil, nil)
+	now := time.Now()
+	p.states["old"] = pendingState{createdAt: now.Add(-StateTTL - time.Minute)}
+	p.states["fresh"] = pendingState{createdAt: now.Add(-time.Minute)}
+
+	p.removeExpiredStates(now)
+
+	if _, ok := p.states["old"]; ok {
+		t.Error("expired state was not removed")
+	}
+	if _, ok := p.states["fresh"]; !ok {
+		t.Error("fresh state was removed")
+	}
+}
+
 // TestHandleCallback_StateConsumedOnce verifies that a state cannot be reused.
 func TestHandleCallback_StateConsumedOnce(t *testing.T) {
-	// Set up a server that handles token exchange, /verify, and ESI endpoints.
+	// Set up a server that handles token exchange, the SSO signing keys, and ESI endpoints.
+	sso := newTestSSO()
 	mux := http.NewServeMux()
 	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"access_token":  "tok",
+			"access_token":  sso.sign(t, characterClaims(1, "X")),
 			"token_type":    "Bearer",
 			"refresh_token": "ref",
 			"expires_in":    1200,
@@ -131,12 +313,7 @@ func TestHandleCallback_StateConsumedOnce(t *testing.T) {
 			t.Fatalf("encode: %v", err)
 		}
 	})
-	mux.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
-		err := json.NewEncoder(w).Encode(verifyResponse{CharacterID: 1, CharacterName: "X"})
-		if err != nil {
-			t.Fatalf("encode: %v", err)
-		}
-	})
+	sso.register(t, mux)
 	addCharCorpHandlers(t, mux, 500001, "TestCorp")
 	ts := httptest.NewServer(mux)
 	defer ts.Close()
@@ -144,10 +321,10 @@ func TestHandleCallback_StateConsumedOnce(t *testing.T) {
 	mq := &mockQuerier{}
 	p := newTestProvider(t, ts.Client(), mq)
 	p.conf.Endpoint.TokenURL = ts.URL + "/token"
-	p.verifyURL = ts.URL + "/verify"
+	p.metadataURL = ts.URL + "/.well-known/oauth-authorization-server"
 	p.esiBaseURL = ts.URL
 
-	_, err := p.GenerateAuthURL()
+	_, _, err := p.GenerateAuthURL()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -170,89 +347,18 @@ func TestHandleCallback_StateConsumedOnce(t *testing.T) {
 	}
 }
 
-// TestCallVerify_ParsesResponse verifies that callVerify correctly parses the
-// JSON response from the /verify endpoint.
-func TestCallVerify_ParsesResponse(t *testing.T) {
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Verify that the Authorization header was sent.
-		if auth := r.Header.Get("Authorization"); auth != "Bearer test-access-token" {
-			http.Error(w, "missing auth header", http.StatusUnauthorized)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		err := json.NewEncoder(w).Encode(verifyResponse{
-			CharacterID:   12345,
-			CharacterName: "TinkerBear",
-		})
-		if err != nil {
-			t.Fatalf("encode: %v", err)
-		}
-	}))
-	defer ts.Close()
-
-	p := newTestProvider(t, ts.Client(), nil)
-	p.verifyURL = ts.URL
-
-	got, err := p.callVerify(context.Background(), "test-access-token")
-	if err != nil {
-		t.Fatalf("callVerify error: %v", err)
-	}
-	if got.CharacterID != 12345 {
-		t.Errorf("CharacterID = %d, want 12345", got.CharacterID)
-	}
-	if got.CharacterName != "TinkerBear" {
-		t.Errorf("CharacterName = %q, want %q", got.CharacterName, "TinkerBear")
-	}
-}
-
-// TestCallVerify_NonOKStatus verifies that a non-200 response from /verify
-// is returned as an error.
-func TestCallVerify_NonOKStatus(t *testing.T) {
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-	}))
-	defer ts.Close()
-
-	p := newTestProvider(t, ts.Client(), nil)
-	p.verifyURL = ts.URL
-
-	_, err := p.callVerify(context.Background(), "bad-token")
-	if err == nil {
-		t.Fatal("expected error for non-200 verify response, got nil")
-	}
-}
-
-// TestCallVerify_ZeroCharacterID verifies that a response with CharacterID == 0
-// is rejected as invalid.
-func TestCallVerify_ZeroCharacterID(t *testing.T) {
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		err := json.NewEncoder(w).Encode(verifyResponse{CharacterID: 0, CharacterName: ""})
-		if err != nil {
-			t.Fatalf("encode: %v", err)
-		}
-	}))
-	defer ts.Close()
-
-	p := newTestProvider(t, ts.Client(), nil)
-	p.verifyURL = ts.URL
-
-	_, err := p.callVerify(context.Background(), "some-token")
-	if err == nil {
-		t.Fatal("expected error for zero CharacterID, got nil")
-	}
-}
-
 // TestHandleCallback_ValidFlow exercises the full happy path:
-// valid state → token exchange → /verify → character info → corporation info → UpsertCharacter.
+// valid state → token exchange → token validation → character info → corporation info → UpsertCharacter.
 func TestHandleCallback_ValidFlow(t *testing.T) {
 	mq := &mockQuerier{}
+	sso := newTestSSO()
+	accessToken := sso.sign(t, characterClaims(99999, "BearPilot", "esi-characters.read_blueprints.v1", "esi-industry.read_character_jobs.v1"))
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"access_token":  "access-abc",
+			"access_token":  accessToken,
 			"token_type":    "Bearer",
 			"refresh_token": "refresh-xyz",
 			"expires_in":    3600,
@@ -261,27 +367,18 @@ func TestHandleCallback_ValidFlow(t *testing.T) {
 			t.Fatalf("encode: %v", err)
 		}
 	})
-	mux.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		err := json.NewEncoder(w).Encode(verifyResponse{
-			CharacterID:   99999,
-			CharacterName: "BearPilot",
-		})
-		if err != nil {
-			t.Fatalf("encode: %v", err)
-		}
-	})
+	sso.register(t, mux)
 	addCharCorpHandlers(t, mux, 98000001, "Caldari State")
 	ts := httptest.NewServer(mux)
 	defer ts.Close()
 
 	p := newTestProvider(t, ts.Client(), mq)
 	p.conf.Endpoint.TokenURL = ts.URL + "/token"
-	p.verifyURL = ts.URL + "/verify"
+	p.metadataURL = ts.URL + "/.well-known/oauth-authorization-server"
 	p.esiBaseURL = ts.URL
 
 	// Seed a valid state.
-	if _, err := p.GenerateAuthURL(); err != nil {
+	if _, _, err := p.GenerateAuthURL(); err != nil {
 		t.Fatal(err)
 	}
 	p.mu.Lock()
@@ -304,8 +401,11 @@ func TestHandleCallback_ValidFlow(t *testing.T) {
 	if mq.upsertParams.Name != "BearPilot" {
 		t.Errorf("upserted name = %q, want %q", mq.upsertParams.Name, "BearPilot")
 	}
-	if mq.upsertParams.AccessToken != "access-abc" {
-		t.Errorf("upserted access token = %q, want %q", mq.upsertParams.AccessToken, "access-abc")
+	if mq.upsertParams.AccessToken != accessToken {
+		t.Errorf("upserted access token = %q, want %q", mq.upsertParams.AccessToken, accessToken)
+	}
+	if want := "esi-characters.read_blueprints.v1 esi-industry.read_character_jobs.v1"; mq.upsertParams.Scopes != want {
+		t.Errorf("upserted scopes = %q, want %q", mq.upsertParams.Scopes, want)
 	}
 	if mq.upsertParams.RefreshToken != "refresh-xyz" {
 		t.Errorf("upserted refresh token = %q, want %q", mq.upsertParams.RefreshToken, "refresh-xyz")
@@ -332,24 +432,29 @@ func TestHandleCallback_ValidFlow(t *testing.T) {
 	if mq.insertOrIgnoreCorpParams.ID != 98000001 {
 		t.Errorf("corp ID = %d, want 98000001", mq.insertOrIgnoreCorpParams.ID)
 	}
-	if mq.insertOrIgnoreCorpParams.DelegateID != 99999 {
-		t.Errorf("delegate ID = %d, want 99999 (the character ID)", mq.insertOrIgnoreCorpParams.DelegateID)
+	if d := mq.insertOrIgnoreCorpParams.DelegateID; !d.Valid || d.Int64 != 99999 {
+		t.Errorf("delegate ID = %v, want 99999 (the character ID)", d)
 	}
 	if mq.insertOrIgnoreCorpParams.Name != "Caldari State" {
 		t.Errorf("corp name = %q, want %q", mq.insertOrIgnoreCorpParams.Name, "Caldari State")
 	}
+	// An orphaned corporation is adopted by the logged-in member.
+	if len(mq.adoptCorpCalls) != 1 || mq.adoptCorpCalls[0].ID != 98000001 || mq.adoptCorpCalls[0].DelegateID.Int64 != 99999 {
+		t.Errorf("AdoptCorporation calls = %+v, want corporation 98000001 with delegate 99999", mq.adoptCorpCalls)
+	}
 }
 
 // TestHandleCallback_NPCCorporation verifies that NPC corporations (IDs 1000000–2000000)
 // are not inserted into the corporations table.
 func TestHandleCallback_NPCCorporation(t *testing.T) {
 	mq := &mockQuerier{}
+	sso := newTestSSO()
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"access_token":  "access-npc",
+			"access_token":  sso.sign(t, characterClaims(11111, "NPCChar")),
 			"token_type":    "Bearer",
 			"refresh_token": "refresh-npc",
 			"expires_in":    3600,
@@ -358,13 +463,7 @@ func TestHandleCallback_NPCCorporation(t *testing.T) {
 			t.Fatalf("encode: %v", err)
 		}
 	})
-	mux.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		err := json.NewEncoder(w).Encode(verifyResponse{CharacterID: 11111, CharacterName: "NPCChar"})
-		if err != nil {
-			t.Fatalf("encode: %v", err)
-		}
-	})
+	sso.register(t, mux)
 	// NPC corp ID within 1000000–2000000.
 	addCharCorpHandlers(t, mux, 1000182, "Center for Advanced Studies")
 	ts := httptest.NewServer(mux)
@@ -372,10 +471,10 @@ func TestHandleCallback_NPCCorporation(t *testing.T) {
 
 	p := newTestProvider(t, ts.Client(), mq)
 	p.conf.Endpoint.TokenURL = ts.URL + "/token"
-	p.verifyURL = ts.URL + "/verify"
+	p.metadataURL = ts.URL + "/.well-known/oauth-authorization-server"
 	p.esiBaseURL = ts.URL
 
-	if _, err := p.GenerateAuthURL(); err != nil {
+	if _, _, err := p.GenerateAuthURL(); err != nil {
 		t.Fatal(err)
 	}
 	p.mu.Lock()
@@ -393,3 +492,120 @@ func TestHandleCallback_NPCCorporation(t *testing.T) {
 		t.Fatal("InsertOrIgnoreCorporation must not be called for NPC corporation")
 	}
 }
+
+// runOwnerCallback runs a successful callback for character 7 whose access
+// token carries ownerHash, saving into mq. tokens, if not nil, is the token
+// cache of the provider.
+func runOwnerCallback(t *testing.T, mq *mockQuerier, ownerHash string, tokens *Client) {
+	t.Helper()
+	sso := newTestSSO()
+	claims := characterClaims(7, "Traded")
+	claims["owner"] = ownerHash
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  sso.sign(t, claims),
+			"token_type":    "Bearer",
+			"refresh_token": "ref",
+			"expires_in":    1200,
+		}); err != nil {
+			t.Errorf("encode: %v", err)
+		}
+	})
+	sso.register(t, mux)
+	addCharCorpHandlers(t, mux, 1000182, "NPC Corp")
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	p := newTestProvider(t, ts.Client(), mq)
+	p.conf.Endpoint.TokenURL = ts.URL + "/token"
+	p.metadataURL = ts.URL + "/.well-known/oauth-authorization-server"
+	p.esiBaseURL = ts.URL
+	p.SetTokenCache(tokens)
+
+	rawURL, _, err := p.GenerateAuthURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.HandleCallback(context.Background(), "code", u.Query().Get("state")); err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+}
+
+// TestHandleCallback_OwnerChanged_FlagsTransferred verifies that logging in a
+// stored character whose owner hash changed saves the new tokens but flags it.
+func TestHandleCallback_OwnerChanged_FlagsTransferred(t *testing.T) {
+	mq := &mockQuerier{existing: map[int64]store.Character{7: {ID: 7, OwnerHash: "old-owner"}}}
+
+	runOwnerCallback(t, mq, "new-owner", nil)
+
+	if mq.upsertParams.OwnerHash != "new-owner" {
+		t.Errorf("upserted owner hash = %q, want %q", mq.upsertParams.OwnerHash, "new-owner")
+	}
+	// UpsertCharacter flags the character in the same statement.
+	if len(mq.transferredCalls) != 0 {
+		t.Errorf("MarkCharacterTransferred called for %v, want the flag set by UpsertCharacter", mq.transferredCalls)
+	}
+}
+
+// TestHandleCallback_DropsCachedToken verifies that a login drops the access
+// token cached for the character, which belongs to the previous login.
+func TestHandleCallback_DropsCachedToken(t *testing.T) {
+	mq := &mockQuerier{existing: map[int64]store.Character{7: {ID: 7, OwnerHash: "old-owner"}}}
+	tokens := NewClient(nil, mq, &oauth2.Config{}, nil)
+	entry := tokens.cacheEntry(7)
+	entry.accessToken, entry.expiry = "previous-owner-token", time.Now().Add(time.Hour)
+
+	runOwnerCallback(t, mq, "new-owner", tokens)
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.valid(time.Now()) {
+		t.Errorf("cached token %q still valid after the login", entry.accessToken)
+	}
+}
+
+// TestHandleCallback_SameOwner_NotFlagged verifies that re-logging a character
+// with the same owner hash, or one stored before hashes were recorded, does not
+// flag it.
+func TestHandleCallback_SameOwner_NotFlagged(t *testing.T) {
+	for name, stored := range map[string]string{"same owner": "owner-a", "unknown owner": ""} {
+		t.Run(name, func(t *testing.T) {
+			mq := &mockQuerier{existing: map[int64]store.Character{7: {ID: 7, OwnerHash: stored}}}
+
+			runOwnerCallback(t, mq, "owner-a", nil)
+
+			if len(mq.transferredCalls) != 0 {
+				t.Errorf("MarkCharacterTransferred calls = %v, want none", mq.transferredCalls)
+			}
+			if mq.upsertParams.OwnerHash != "owner-a" {
+				t.Errorf("upserted owner hash = %q, want %q", mq.upsertParams.OwnerHash, "owner-a")
+			}
+		})
+	}
+}
+
+// TestHandleCallback_KnownCharacter_KeepsCorporation verifies that logging in
+// a stored character does not move it to another corporation: corporation
+// changes are left to the sync worker's affiliation refresh.
+func TestHandleCallback_KnownCharacter_KeepsCorporation(t *testing.T) {
+	mq := &mockQuerier{existing: map[int64]store.Character{7: {
+		ID: 7, OwnerHash: "owner-a", CorporationID: 98000002, CorporationName: "Old Corp",
+	}}}
+
+	runOwnerCallback(t, mq, "owner-a", nil) // ESI reports NPC corporation 1000182
+
+	if mq.upsertParams.CorporationID != 98000002 || mq.upsertParams.CorporationName != "Old Corp" {
+		t.Errorf("upserted corporation = %d %q, want 98000002 %q",
+			mq.upsertParams.CorporationID, mq.upsertParams.CorporationName, "Old Corp")
+	}
+	if mq.insertOrIgnoreCorpCalled || len(mq.adoptCorpCalls) != 0 {
+		t.Error("corporation tracking must be left to the affiliation refresh")
+	}
+}
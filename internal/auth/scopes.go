@@ -0,0 +1,27 @@
+package auth
+
+// scopes.go: reading the granted scopes from EVE SSO access tokens and
+// comparing them with the scopes Auspex requires.
+
+import (
+	"slices"
+	"strings"
+)
+
+// MissingScopes returns the scopes Auspex requires that are not in granted,
+// a space-separated list as stored in characters.scopes. An empty granted
+// list means the scopes are unknown (character added before they were
+// recorded) and yields no missing scopes.
+func MissingScopes(granted string) []string {
+	have := strings.Fields(granted)
+	if len(have) == 0 {
+		return nil
+	}
+	var missing []string
+	for _, s := range eveScopes {
+		if !slices.Contains(have, s) {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
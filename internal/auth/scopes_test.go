@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dpleshakov/auspex/internal/esi"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// fakeJWT builds an unsigned JWT whose payload is the given JSON.
+func fakeJWT(payload string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"RS256"}`)) + "." +
+		enc.EncodeToString([]byte(payload)) + ".sig"
+}
+
+func TestParseTokenClaims_ScopeArray(t *testing.T) {
+	claims, err := parseTokenClaims(fakeJWT(`{"scp":["a.v1","b.v1"]}`))
+	if err != nil {
+		t.Fatalf("parseTokenClaims: %v", err)
+	}
+	if got := claims.Scopes; !slices.Equal(got, []string{"a.v1", "b.v1"}) {
+		t.Errorf("scopes = %v, want [a.v1 b.v1]", got)
+	}
+}
+
+// TestParseTokenClaims_SingleScope verifies that a token with one scope,
+// which EVE SSO encodes as a plain string, is parsed.
+func TestParseTokenClaims_SingleScope(t *testing.T) {
+	claims, err := parseTokenClaims(fakeJWT(`{"scp":"a.v1"}`))
+	if err != nil {
+		t.Fatalf("parseTokenClaims: %v", err)
+	}
+	if got := claims.Scopes; !slices.Equal(got, []string{"a.v1"}) {
+		t.Errorf("scopes = %v, want [a.v1]", got)
+	}
+}
+
+func TestParseTokenClaims_NotJWT(t *testing.T) {
+	if _, err := parseTokenClaims("opaque-token"); err == nil {
+		t.Fatal("expected error for non-JWT token")
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	all := strings.Join(eveScopes, " ")
+	if got := MissingScopes(all); len(got) != 0 {
+		t.Errorf("all scopes granted: missing = %v, want none", got)
+	}
+
+	partial := strings.Join(slices.DeleteFunc(slices.Clone(eveScopes), func(s string) bool {
+		return s == esi.ScopeCorporationJobs
+	}), " ")
+	if got := MissingScopes(partial); !slices.Equal(got, []string{esi.ScopeCorporationJobs}) {
+		t.Errorf("missing = %v, want [%s]", got, esi.ScopeCorporationJobs)
+	}
+
+	// Unknown scopes (empty) are not reported as missing.
+	if got := MissingScopes(""); got != nil {
+		t.Errorf("unknown scopes: missing = %v, want nil", got)
+	}
+}
+
+// TestGenerateAuthURL_RequestsAllScopes verifies that a login asks for every
+// scope Auspex requires: the login's grant replaces the character's.
+func TestGenerateAuthURL_RequestsAllScopes(t *testing.T) {
+	p := newTestProvider(t, nil, &mockQuerier{})
+
+	rawURL, _, err := p.GenerateAuthURL()
+	if err != nil {
+		t.Fatalf("GenerateAuthURL: %v", err)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse URL: %v", err)
+	}
+	if got, want := u.Query().Get("scope"), strings.Join(eveScopes, " "); got != want {
+		t.Errorf("scope = %q, want %q", got, want)
+	}
+
+	// The state must be registered so the callback accepts it.
+	state := u.Query().Get("state")
+	p.mu.Lock()
+	_, ok := p.states[state]
+	p.mu.Unlock()
+	if !ok {
+		t.Error("state from authorization URL not registered")
+	}
+}
+
+// TestUpgrade_KeepsGrantedScopes logs in a character that has all scopes but
+// one, with an SSO that grants what the authorization URL asked for, and
+// verifies that the character ends up with every scope.
+func TestUpgrade_KeepsGrantedScopes(t *testing.T) {
+	granted := strings.Join(slices.DeleteFunc(slices.Clone(eveScopes), func(s string) bool {
+		return s == esi.ScopeCorporationJobs
+	}), " ")
+	mq := &mockQuerier{existing: map[int64]store.Character{7: {ID: 7, OwnerHash: "owner-hash-7", Scopes: granted}}}
+
+	sso := newTestSSO()
+	var requested []string // the scopes of the authorization URL
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  sso.sign(t, characterClaims(7, "Upgraded", requested...)),
+			"token_type":    "Bearer",
+			"refresh_token": "ref",
+			"expires_in":    1200,
+		}); err != nil {
+			t.Errorf("encode: %v", err)
+		}
+	})
+	sso.register(t, mux)
+	addCharCorpHandlers(t, mux, 1000182, "NPC Corp")
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	p := newTestProvider(t, ts.Client(), mq)
+	p.conf.Endpoint.TokenURL = ts.URL + "/token"
+	p.metadataURL = ts.URL + "/.well-known/oauth-authorization-server"
+	p.esiBaseURL = ts.URL
+
+	rawURL, _, err := p.GenerateAuthURL()
+	if err != nil {
+		t.Fatalf("GenerateAuthURL: %v", err)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse URL: %v", err)
+	}
+	requested = strings.Fields(u.Query().Get("scope"))
+	if _, err := p.HandleCallback(context.Background(), "code", u.Query().Get("state")); err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+
+	if missing := MissingScopes(mq.upsertParams.Scopes); len(missing) != 0 {
+		t.Errorf("after the upgrade the character misses %v (scopes %q)", missing, mq.upsertParams.Scopes)
+	}
+}
@@ -2,8 +2,12 @@ package config
 
 import (
 	"fmt"
+	"net/mail"
 	"net/url"
 	"os"
+	"strings"
+	"time"
+	_ "time/tzdata" // notifications.email.timezone on systems without a time zone database
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,14 +19,99 @@ type Config struct {
 	Port            int       `yaml:"port"`
 	DBPath          string    `yaml:"db_path"`
 	RefreshInterval int       `yaml:"refresh_interval"` // minutes
+	TokenKeyFile    string    `yaml:"token_key_file"`   // token encryption key, unless a passphrase is used
 	ESI             ESIConfig `yaml:"esi"`
+
+	Notifications NotificationsConfig `yaml:"notifications"`
 }
 
 // ESIConfig holds EVE SSO / ESI credentials.
 type ESIConfig struct {
-	ClientID     string `yaml:"client_id"`
+	ClientID string `yaml:"client_id"`
+	// ClientSecret is optional: without it EVE SSO is used in PKCE mode.
 	ClientSecret string `yaml:"client_secret"` //nolint:gosec // G117: false positive, config field read from local yaml file
 	CallbackURL  string `yaml:"callback_url"`
+	// SSOMetadataURL is the EVE SSO authorization server metadata document,
+	// used to find the access token signing keys. Empty means EVE SSO's own.
+	SSOMetadataURL string `yaml:"sso_metadata_url"`
+}
+
+// NotificationsConfig configures the alerts Auspex posts.
+type NotificationsConfig struct {
+	Discord DiscordConfig `yaml:"discord"`
+	Email   EmailConfig   `yaml:"email"`
+}
+
+// DiscordConfig configures alerts posted to Discord webhooks.
+type DiscordConfig struct {
+	Webhooks      []DiscordWebhook `yaml:"webhooks"`
+	IdleAfter     int              `yaml:"idle_after"`     // hours; 0 disables idle blueprint alerts
+	ResearchSlots int              `yaml:"research_slots"` // per character; 0 disables free slot alerts
+	DigestHour    int              `yaml:"digest_hour"`    // UTC
+}
+
+// DiscordWebhook is one Discord webhook and the alerts it receives.
+type DiscordWebhook struct {
+	URL    string   `yaml:"url"`
+	Alerts []string `yaml:"alerts"` // empty means all
+	Digest bool     `yaml:"digest"` // a daily digest instead of individual alerts
+}
+
+// discordAlerts are the alert names accepted in a webhook's alerts list.
+var discordAlerts = map[string]bool{
+	"job_ready":      true,
+	"free_slots":     true,
+	"idle_blueprint": true,
+	"sync_failed":    true,
+	"rule":           true,
+}
+
+// EmailConfig configures the digest email.
+type EmailConfig struct {
+	SMTP          SMTPConfig `yaml:"smtp"`
+	From          string     `yaml:"from"`
+	To            []string   `yaml:"to"`       // no digest is sent without recipients
+	Schedule      string     `yaml:"schedule"` // "daily" or "weekly"
+	Weekday       string     `yaml:"weekday"`  // of the weekly digest, e.g. "monday"
+	Hour          int        `yaml:"hour"`
+	Timezone      string     `yaml:"timezone"`       // IANA name, e.g. "Europe/London"
+	ResearchSlots int        `yaml:"research_slots"` // per character; 0 leaves free slots out
+}
+
+// SMTPConfig is the mail server the digest is sent through.
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Security string `yaml:"security"` // "starttls", "tls" (implicit, usually port 465) or "none"
+	Username string `yaml:"username"` // no authentication if empty
+	Password string `yaml:"password"` //nolint:gosec // G117: false positive, config field read from local yaml file
+}
+
+// smtpSecurity are the accepted values of smtp.security.
+var smtpSecurity = map[string]bool{"starttls": true, "tls": true, "none": true}
+
+// Location returns the time zone of Timezone. It is valid after Load.
+func (e EmailConfig) Location() *time.Location {
+	loc, err := time.LoadLocation(e.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// DigestWeekday returns the day of Weekday. It is valid after Load.
+func (e EmailConfig) DigestWeekday() time.Weekday {
+	d, _ := parseWeekday(e.Weekday)
+	return d
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(s, d.String()) {
+			return d, true
+		}
+	}
+	return 0, false
 }
 
 // Load reads configuration from the file at path and returns a validated Config.
@@ -55,6 +144,22 @@ func defaults() *Config {
 		Port:            8080,
 		DBPath:          "auspex.db",
 		RefreshInterval: 10,
+		TokenKeyFile:    "auspex.key",
+		Notifications: NotificationsConfig{
+			Discord: DiscordConfig{
+				IdleAfter:     24,
+				ResearchSlots: 11,
+				DigestHour:    9,
+			},
+			Email: EmailConfig{
+				SMTP:          SMTPConfig{Port: 587, Security: "starttls"},
+				Schedule:      "daily",
+				Weekday:       "monday",
+				Hour:          8,
+				Timezone:      "UTC",
+				ResearchSlots: 11,
+			},
+		},
 	}
 }
 
@@ -68,14 +173,81 @@ func (c *Config) validate() error {
 	if c.ESI.ClientID == "" {
 		return fmt.Errorf("esi.client_id is required")
 	}
-	if c.ESI.ClientSecret == "" {
-		return fmt.Errorf("esi.client_secret is required")
-	}
 	if c.ESI.CallbackURL == "" {
 		return fmt.Errorf("esi.callback_url is required")
 	}
 	if u, err := url.Parse(c.ESI.CallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
 		return fmt.Errorf("esi.callback_url must be a valid http or https URL, got %q", c.ESI.CallbackURL)
 	}
+	if c.ESI.SSOMetadataURL != "" {
+		if u, err := url.Parse(c.ESI.SSOMetadataURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("esi.sso_metadata_url must be a valid http or https URL, got %q", c.ESI.SSOMetadataURL)
+		}
+	}
+	if err := c.Notifications.Discord.validate(); err != nil {
+		return err
+	}
+	return c.Notifications.Email.validate()
+}
+
+func (d *DiscordConfig) validate() error {
+	for i, wh := range d.Webhooks {
+		if u, err := url.Parse(wh.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("notifications.discord.webhooks[%d].url must be a valid http or https URL", i)
+		}
+		for _, a := range wh.Alerts {
+			if !discordAlerts[a] {
+				return fmt.Errorf("notifications.discord.webhooks[%d].alerts: unknown alert %q", i, a)
+			}
+		}
+	}
+	if d.IdleAfter < 0 {
+		return fmt.Errorf("notifications.discord.idle_after must not be negative, got %d", d.IdleAfter)
+	}
+	if d.ResearchSlots < 0 {
+		return fmt.Errorf("notifications.discord.research_slots must not be negative, got %d", d.ResearchSlots)
+	}
+	if d.DigestHour < 0 || d.DigestHour > 23 {
+		return fmt.Errorf("notifications.discord.digest_hour must be between 0 and 23, got %d", d.DigestHour)
+	}
+	return nil
+}
+
+func (e *EmailConfig) validate() error {
+	if len(e.To) == 0 {
+		return nil
+	}
+	for i, to := range e.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("notifications.email.to[%d] must be an email address, got %q", i, to)
+		}
+	}
+	if _, err := mail.ParseAddress(e.From); err != nil {
+		return fmt.Errorf("notifications.email.from must be an email address, got %q", e.From)
+	}
+	if e.SMTP.Host == "" {
+		return fmt.Errorf("notifications.email.smtp.host is required")
+	}
+	if e.SMTP.Port < 1 || e.SMTP.Port > 65535 {
+		return fmt.Errorf("notifications.email.smtp.port must be between 1 and 65535, got %d", e.SMTP.Port)
+	}
+	if !smtpSecurity[e.SMTP.Security] {
+		return fmt.Errorf("notifications.email.smtp.security must be starttls, tls or none, got %q", e.SMTP.Security)
+	}
+	if e.Schedule != "daily" && e.Schedule != "weekly" {
+		return fmt.Errorf("notifications.email.schedule must be daily or weekly, got %q", e.Schedule)
+	}
+	if _, ok := parseWeekday(e.Weekday); !ok {
+		return fmt.Errorf("notifications.email.weekday must be a day of the week, got %q", e.Weekday)
+	}
+	if e.Hour < 0 || e.Hour > 23 {
+		return fmt.Errorf("notifications.email.hour must be between 0 and 23, got %d", e.Hour)
+	}
+	if _, err := time.LoadLocation(e.Timezone); err != nil {
+		return fmt.Errorf("notifications.email.timezone: unknown time zone %q", e.Timezone)
+	}
+	if e.ResearchSlots < 0 {
+		return fmt.Errorf("notifications.email.research_slots must not be negative, got %d", e.ResearchSlots)
+	}
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoadFromFile_Valid(t *testing.T) {
@@ -54,6 +55,9 @@ esi:
 	if cfg.RefreshInterval != 10 {
 		t.Errorf("refresh_interval: got %d, want 10 (default)", cfg.RefreshInterval)
 	}
+	if cfg.TokenKeyFile != "auspex.key" {
+		t.Errorf("token_key_file: got %q, want %q (default)", cfg.TokenKeyFile, "auspex.key")
+	}
 }
 
 func TestLoadFromFile_MissingClientID(t *testing.T) {
@@ -68,15 +72,20 @@ esi:
 	}
 }
 
+// TestLoadFromFile_MissingClientSecret verifies that client_secret is optional:
+// without it EVE SSO is used in PKCE mode.
 func TestLoadFromFile_MissingClientSecret(t *testing.T) {
 	f := writeTempConfig(t, `
 esi:
   client_id: "myid"
   callback_url: "http://localhost:8080/auth/eve/callback"
 `)
-	_, err := loadFromFile(f)
-	if err == nil {
-		t.Fatal("expected error for missing client_secret, got nil")
+	cfg, err := loadFromFile(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ESI.ClientSecret != "" {
+		t.Errorf("client_secret: got %q, want empty", cfg.ESI.ClientSecret)
 	}
 }
 
@@ -130,6 +139,19 @@ esi:
 	}
 }
 
+func TestLoadFromFile_InvalidSSOMetadataURL(t *testing.T) {
+	f := writeTempConfig(t, `
+esi:
+  client_id: "myid"
+  client_secret: "mysecret"
+  callback_url: "http://localhost:8080/auth/eve/callback"
+  sso_metadata_url: "not-a-url"
+`)
+	if _, err := loadFromFile(f); err == nil {
+		t.Error("expected error for sso_metadata_url \"not-a-url\", got nil")
+	}
+}
+
 func TestLoadFromFile_InvalidRefreshInterval(t *testing.T) {
 	for _, interval := range []int{0, -1, -100} {
 		f := writeTempConfig(t, fmt.Sprintf(`
@@ -146,6 +168,121 @@ esi:
 	}
 }
 
+func TestLoadFromFile_DiscordNotifications(t *testing.T) {
+	f := writeTempConfig(t, `
+esi:
+  client_id: "myid"
+  callback_url: "http://localhost:8080/auth/eve/callback"
+notifications:
+  discord:
+    idle_after: 48
+    webhooks:
+      - url: "https://discord.com/api/webhooks/1/token"
+        alerts: [job_ready, sync_failed]
+      - url: "https://discord.com/api/webhooks/2/token"
+        digest: true
+`)
+	cfg, err := loadFromFile(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d := cfg.Notifications.Discord
+	if len(d.Webhooks) != 2 || len(d.Webhooks[0].Alerts) != 2 || !d.Webhooks[1].Digest {
+		t.Errorf("webhooks: got %+v", d.Webhooks)
+	}
+	if d.IdleAfter != 48 {
+		t.Errorf("idle_after: got %d, want 48", d.IdleAfter)
+	}
+	if d.ResearchSlots != 11 || d.DigestHour != 9 {
+		t.Errorf("research_slots, digest_hour: got %d, %d, want 11, 9 (defaults)", d.ResearchSlots, d.DigestHour)
+	}
+}
+
+func TestLoadFromFile_InvalidDiscordNotifications(t *testing.T) {
+	for _, bad := range []string{
+		`webhooks: [{url: "not-a-url"}]`,
+		`webhooks: [{url: "https://discord.com/api/webhooks/1/token", alerts: [job_done]}]`,
+		`idle_after: -1`,
+		`research_slots: -1`,
+		`digest_hour: 24`,
+	} {
+		f := writeTempConfig(t, fmt.Sprintf(`
+esi:
+  client_id: "myid"
+  callback_url: "http://localhost:8080/auth/eve/callback"
+notifications:
+  discord:
+    %s
+`, bad))
+		if _, err := loadFromFile(f); err == nil {
+			t.Errorf("expected error for %s, got nil", bad)
+		}
+	}
+}
+
+func TestLoadFromFile_EmailNotifications(t *testing.T) {
+	f := writeTempConfig(t, `
+esi:
+  client_id: "myid"
+  callback_url: "http://localhost:8080/auth/eve/callback"
+notifications:
+  email:
+    from: "Auspex <auspex@example.com>"
+    to: ["pilot@example.com"]
+    schedule: weekly
+    weekday: Friday
+    timezone: "Europe/Berlin"
+    smtp:
+      host: "smtp.example.com"
+      username: "auspex"
+      password: "secret"
+`)
+	cfg, err := loadFromFile(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e := cfg.Notifications.Email
+	if e.SMTP.Port != 587 || e.SMTP.Security != "starttls" {
+		t.Errorf("smtp port, security: got %d, %q, want 587, starttls (defaults)", e.SMTP.Port, e.SMTP.Security)
+	}
+	if e.Hour != 8 || e.ResearchSlots != 11 {
+		t.Errorf("hour, research_slots: got %d, %d, want 8, 11 (defaults)", e.Hour, e.ResearchSlots)
+	}
+	if e.DigestWeekday() != time.Friday {
+		t.Errorf("weekday: got %v, want Friday", e.DigestWeekday())
+	}
+	if e.Location().String() != "Europe/Berlin" {
+		t.Errorf("location: got %v", e.Location())
+	}
+}
+
+func TestLoadFromFile_InvalidEmailNotifications(t *testing.T) {
+	const valid = `to: ["pilot@example.com"], from: "auspex@example.com", smtp: {host: "smtp.example.com"}`
+	for _, bad := range []string{
+		`to: ["not an address"], from: "auspex@example.com", smtp: {host: "smtp.example.com"}`,
+		`to: ["pilot@example.com"], smtp: {host: "smtp.example.com"}`,
+		`to: ["pilot@example.com"], from: "auspex@example.com"`,
+		valid + `, schedule: monthly`,
+		valid + `, weekday: someday`,
+		valid + `, hour: 24`,
+		valid + `, timezone: "Mars/Olympus_Mons"`,
+		valid + `, research_slots: -1`,
+		`to: ["pilot@example.com"], from: "auspex@example.com", smtp: {host: "smtp.example.com", port: 0}`,
+		`to: ["pilot@example.com"], from: "auspex@example.com", smtp: {host: "smtp.example.com", security: ssl}`,
+	} {
+		f := writeTempConfig(t, fmt.Sprintf(`
+esi:
+  client_id: "myid"
+  callback_url: "http://localhost:8080/auth/eve/callback"
+notifications:
+  email: {%s}
+`, bad))
+		if _, err := loadFromFile(f); err == nil {
+			t.Errorf("expected error for %s, got nil", bad)
+		}
+	}
+}
+
 func writeTempConfig(t *testing.T, content string) string {
 	t.Helper()
 	f, err := os.CreateTemp(t.TempDir(), "auspex-*.yaml")
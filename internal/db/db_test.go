@@ -9,7 +9,8 @@ var expectedTables = []string{
 	"eve_categories", "eve_groups", "eve_types",
 	"characters", "corporations",
 	"blueprints", "jobs", "sync_state",
-	"eve_locations", "corp_assets",
+	"eve_locations", "assets", "corp_divisions", "blueprint_locations",
+	"affiliation_events",
 }
 
 func TestOpen_TablesCreated(t *testing.T) {
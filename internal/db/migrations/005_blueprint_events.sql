@@ -0,0 +1,14 @@
+-- Blueprint change log (populated by syncBlueprints when the ESI response differs from the stored library)
+CREATE TABLE blueprint_events (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    blueprint_id INTEGER NOT NULL,  -- EVE item_id; no FK because removed blueprints are deleted
+    owner_type   TEXT NOT NULL,     -- owner after the change ('character' | 'corporation')
+    owner_id     INTEGER NOT NULL,
+    type_id      INTEGER NOT NULL,
+    event        TEXT NOT NULL,     -- 'added' | 'removed' | 'me_changed' | 'te_changed' | 'moved' | 'owner_changed'
+    old_value    TEXT,              -- previous value for change events; NULL for 'added' and 'removed'
+    new_value    TEXT,              -- new value for change events; NULL for 'added' and 'removed'
+    created_at   DATETIME NOT NULL
+);
+
+CREATE INDEX idx_blueprint_events_created_at ON blueprint_events (created_at);
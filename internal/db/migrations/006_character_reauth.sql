@@ -0,0 +1,4 @@
+-- Re-authorization state: set when EVE SSO rejects the stored refresh token
+-- (invalid_grant — app revoked or password changed); cleared on the next login.
+ALTER TABLE characters ADD COLUMN needs_reauth    INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE characters ADD COLUMN needs_reauth_at DATETIME;
@@ -0,0 +1,6 @@
+-- SSO scopes granted by each character, space-separated as in the JWT "scp" claim.
+-- Empty for characters added before scopes were recorded (treated as unknown).
+ALTER TABLE characters ADD COLUMN scopes TEXT NOT NULL DEFAULT '';
+
+-- Scope whose absence made the sync worker skip this endpoint; NULL when not skipped.
+ALTER TABLE sync_state ADD COLUMN missing_scope TEXT;
@@ -0,0 +1,6 @@
+-- Character ownership: EVE SSO owner hash from the access token. A changed
+-- hash means the character was transferred to another account; its sync is
+-- paused until the transfer is confirmed.
+ALTER TABLE characters ADD COLUMN owner_hash     TEXT NOT NULL DEFAULT '';
+ALTER TABLE characters ADD COLUMN transferred    INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE characters ADD COLUMN transferred_at DATETIME;
@@ -0,0 +1,12 @@
+-- Token encryption at rest: characters.access_token and refresh_token hold
+-- AES-GCM ciphertext. This single-row table records where the key comes from
+-- (a passphrase or a key file), the PBKDF2 salt for passphrases, and a
+-- known value encrypted with the key so a wrong key is detected on startup.
+-- Existing plaintext tokens are encrypted by the application on first start.
+CREATE TABLE token_encryption (
+    id         INTEGER PRIMARY KEY CHECK (id = 1),
+    key_source TEXT NOT NULL,
+    salt       BLOB,
+    key_check  TEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,37 @@
+-- Character affiliation: the alliance of each character's corporation, kept
+-- current together with corporation_id by the periodic affiliation refresh.
+-- alliance_id is NULL when the corporation is in no alliance; affiliation_at
+-- is NULL until the first refresh, which fills the alliance without logging.
+ALTER TABLE characters ADD COLUMN alliance_id    INTEGER;
+ALTER TABLE characters ADD COLUMN alliance_name  TEXT;
+ALTER TABLE characters ADD COLUMN affiliation_at DATETIME;
+
+-- A corporation whose last tracked member left it keeps its data but has no
+-- delegate (NULL) until a member logs in again. SQLite cannot drop NOT NULL
+-- in place, so the table is rebuilt; no other table references it.
+CREATE TABLE corporations_new (
+    id           INTEGER PRIMARY KEY,  -- EVE corporation_id
+    name         TEXT NOT NULL,
+    delegate_id  INTEGER REFERENCES characters(id),  -- NULL when orphaned
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO corporations_new (id, name, delegate_id, created_at)
+SELECT id, name, delegate_id, created_at FROM corporations;
+DROP TABLE corporations;
+ALTER TABLE corporations_new RENAME TO corporations;
+
+-- Affiliation change log (populated by the sync worker's affiliation refresh)
+CREATE TABLE affiliation_events (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    character_id   INTEGER NOT NULL,  -- no FK: the log outlives deleted characters
+    character_name TEXT NOT NULL,
+    corporation_id INTEGER NOT NULL,  -- corporation the event concerns
+    event          TEXT NOT NULL,     -- 'corporation_changed' | 'alliance_changed' | 'corporation_tracked' | 'delegate_reassigned' | 'delegate_orphaned'
+    old_id         INTEGER,           -- previous corporation, alliance or delegate; NULL if none
+    old_name       TEXT,
+    new_id         INTEGER,           -- new corporation, alliance or delegate; NULL if none
+    new_name       TEXT,
+    created_at     DATETIME NOT NULL
+);
+
+CREATE INDEX idx_affiliation_events_created_at ON affiliation_events (created_at);
@@ -0,0 +1,10 @@
+-- Corporation roles of each character (GET /characters/{id}/roles/), synced
+-- per character. Space-separated; '' when the character holds none, NULL until
+-- first fetched.
+ALTER TABLE characters ADD COLUMN roles TEXT;
+
+-- Character whose token last succeeded on the corporation endpoints: the
+-- delegate, or another member holding the required roles when the delegate got
+-- a 403. NULL until the first success. No FK: a stale value is ignored once the
+-- character leaves the corporation or is deleted.
+ALTER TABLE corporations ADD COLUMN active_character_id INTEGER;
@@ -0,0 +1,11 @@
+-- Outcome of the last structure lookup (GET /universe/structures/{id}/) made
+-- with each character's token. Location resolution tries characters with a
+-- 'granted' row first and skips 'forbidden' ones until a cooldown has passed.
+-- No FK: rows of deleted characters are ignored, as they are never candidates.
+CREATE TABLE structure_access (
+    structure_id  INTEGER NOT NULL,
+    character_id  INTEGER NOT NULL,
+    status        TEXT NOT NULL,      -- 'granted' | 'forbidden'
+    checked_at    DATETIME NOT NULL,
+    PRIMARY KEY (structure_id, character_id)
+);
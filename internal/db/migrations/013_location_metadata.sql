@@ -0,0 +1,22 @@
+-- Solar systems with their constellation and region (GET /universe/systems/{id}/
+-- and the constellation and region it belongs to), populated lazily as
+-- locations in them are resolved and refreshed with them.
+CREATE TABLE eve_systems (
+    id                 INTEGER PRIMARY KEY,  -- EVE solar_system_id
+    name               TEXT NOT NULL,
+    security_status    REAL NOT NULL,        -- true security status, -1.0 to 1.0
+    constellation_id   INTEGER NOT NULL,
+    constellation_name TEXT NOT NULL,
+    region_id          INTEGER NOT NULL,
+    region_name        TEXT NOT NULL,
+    resolved_at        DATETIME NOT NULL
+);
+
+-- Solar system of each resolved station or structure. NULL for the
+-- "Corporation Hangar" sentinel and for rows resolved before this migration,
+-- which are resolved again on the next sync cycle.
+ALTER TABLE eve_locations ADD COLUMN solar_system_id INTEGER;
+
+-- System names were cached in eve_locations under the system ID; eve_systems
+-- replaces them.
+DELETE FROM eve_locations WHERE id >= 30000000 AND id < 33000000;
@@ -0,0 +1,36 @@
+-- Asset tree of characters and corporations: every asset that holds other
+-- assets (corporation offices, containers, ships), so that a blueprint's
+-- location_id can be followed up to the station or structure it is in.
+-- corp_assets only held corporation offices ('OfficeFolder').
+ALTER TABLE corp_assets RENAME TO assets;
+ALTER TABLE assets ADD COLUMN owner_type TEXT NOT NULL DEFAULT 'corporation';
+ALTER TABLE assets ADD COLUMN location_flag TEXT NOT NULL DEFAULT 'OfficeFolder';
+ALTER TABLE assets ADD COLUMN type_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE assets ADD COLUMN name TEXT;  -- player-given name; NULL if never named
+
+-- Hangar division names of each corporation (GET /corporations/{id}/divisions/).
+CREATE TABLE corp_divisions (
+    corporation_id INTEGER NOT NULL,
+    division       INTEGER NOT NULL,  -- 1–7, matching location_flag CorpSAG1–CorpSAG7
+    name           TEXT NOT NULL,     -- '' for an unnamed division
+    PRIMARY KEY (corporation_id, division)
+);
+
+-- Resolved location of each blueprint: the station or structure at the root of
+-- its asset tree (named in eve_locations) and the divisions and containers
+-- between it and the blueprint.
+CREATE TABLE blueprint_locations (
+    blueprint_id     INTEGER PRIMARY KEY REFERENCES blueprints(id) ON DELETE CASCADE,
+    root_location_id INTEGER NOT NULL,
+    path             TEXT NOT NULL    -- e.g. "Corp Hangar 3 (Research BPOs) › Station Container 'T2 BPOs'"; '' directly in a hangar
+);
+
+-- Blueprints directly in a station hangar or a corporation office keep showing
+-- their station until the next sync resolves the full path.
+INSERT INTO blueprint_locations (blueprint_id, root_location_id, path)
+SELECT b.id, COALESCE(a.location_id, b.location_id), ''
+FROM blueprints b
+LEFT JOIN assets a ON a.item_id = b.location_id;
+
+-- The sentinel is no longer specific to corporation hangars.
+UPDATE eve_locations SET name = 'Unknown location' WHERE name = 'Corporation Hangar';
@@ -0,0 +1,15 @@
+-- Full-text index over blueprints, characters, corporations and the locations
+-- that hold blueprints, for GET /api/search. Rebuilt by the sync worker at the
+-- end of every cycle from the tables it mirrors; entity_id refers to
+-- blueprints.id, characters.id, corporations.id or eve_locations.id by
+-- entity_type.
+CREATE VIRTUAL TABLE search_index USING fts5(
+    entity_type UNINDEXED,  -- 'blueprint' | 'character' | 'corporation' | 'location'
+    entity_id   UNINDEXED,
+    name,                   -- type, character, corporation or location name
+    detail,                 -- blueprint: group and category; character: corporation and alliance; location: system and region
+    location,               -- blueprint: station or structure name and container path
+    owner,                  -- blueprint: owner name
+    tokenize = 'unicode61 remove_diacritics 2',
+    prefix = '2 3'
+);
@@ -0,0 +1,10 @@
+-- iCalendar feeds of job completions (GET /calendar.ics?token=). Each feed has
+-- its own secret token, so that a leaked calendar URL can be revoked on its
+-- own, and its own blueprint filters.
+CREATE TABLE calendar_feeds (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    name       TEXT NOT NULL,
+    token      TEXT NOT NULL UNIQUE,
+    filters    TEXT NOT NULL DEFAULT '',  -- GET /api/blueprints filters as a URL query, e.g. 'owner_id=90000001&activity=copying'
+    created_at DATETIME NOT NULL
+);
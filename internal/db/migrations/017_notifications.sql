@@ -0,0 +1,18 @@
+-- Alerts the notifier has sent, so that repeated sync cycles and restarts do
+-- not send them again. An alert is identified by its kind and its subject
+-- (e.g. 'job:<job_id>'); entries older than 30 days are pruned, after which a
+-- condition that still holds is reported again.
+CREATE TABLE notification_log (
+    kind    TEXT NOT NULL,      -- 'job_ready' | 'free_slots' | 'idle_blueprint' | 'sync_failed' | 'digest'
+    subject TEXT NOT NULL,
+    sent_at DATETIME NOT NULL,
+    PRIMARY KEY (kind, subject)
+);
+
+-- When each blueprint without a job was first seen idle. Maintained by the
+-- notifier at the end of every sync cycle; a blueprint is removed once a job
+-- is started on it.
+CREATE TABLE blueprint_idle (
+    blueprint_id INTEGER PRIMARY KEY,  -- EVE item_id; no FK because removed blueprints are deleted
+    idle_since   DATETIME NOT NULL
+);
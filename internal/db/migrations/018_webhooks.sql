@@ -0,0 +1,32 @@
+-- Outgoing webhooks, managed through /api/webhooks. Each matching event on the
+-- events bus is POSTed to url with a body rendered from template, signed with
+-- HMAC-SHA256 under secret.
+CREATE TABLE webhooks (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    name       TEXT NOT NULL,
+    url        TEXT NOT NULL,
+    events     TEXT NOT NULL DEFAULT '',    -- comma-separated event types; '' means all
+    template   TEXT NOT NULL DEFAULT '',    -- Go text/template of the body; '' means the JSON envelope
+    headers    TEXT NOT NULL DEFAULT '{}',  -- JSON object of extra request headers
+    secret     TEXT NOT NULL,
+    enabled    INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+-- One row per delivered event, after its last attempt. Rows older than 30
+-- days are pruned.
+CREATE TABLE webhook_deliveries (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id  INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id    INTEGER NOT NULL,   -- events bus ID; 0 for test events
+    event_type  TEXT NOT NULL,
+    attempts    INTEGER NOT NULL,
+    status_code INTEGER,            -- of the last attempt; NULL if it got no response
+    error       TEXT,               -- NULL if delivered
+    response    TEXT NOT NULL DEFAULT '',  -- start of the last response body
+    duration_ms INTEGER NOT NULL,   -- of all attempts, including backoff
+    created_at  DATETIME NOT NULL
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
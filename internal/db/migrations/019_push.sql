@@ -0,0 +1,19 @@
+-- VAPID key pair that identifies this server to browser push services
+-- (RFC 8292). Generated on first start; there is only ever one row.
+CREATE TABLE push_keys (
+    id          INTEGER PRIMARY KEY CHECK (id = 1),
+    private_key TEXT NOT NULL,  -- base64url P-256 private key
+    public_key  TEXT NOT NULL,  -- base64url uncompressed P-256 point: the browser's applicationServerKey
+    created_at  DATETIME NOT NULL
+);
+
+-- Browser push subscriptions, registered through /api/push/subscriptions.
+-- A subscription the push service reports as gone is deleted.
+CREATE TABLE push_subscriptions (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    endpoint   TEXT NOT NULL UNIQUE,        -- push service URL
+    p256dh     TEXT NOT NULL,               -- base64url browser public key
+    auth       TEXT NOT NULL,               -- base64url authentication secret
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
@@ -0,0 +1,29 @@
+-- User-defined alert rules, managed through /api/alerts. expression is
+-- evaluated after every sync cycle; see package alert for the language.
+CREATE TABLE alert_rules (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        TEXT NOT NULL,
+    expression  TEXT NOT NULL,
+    channels    TEXT NOT NULL DEFAULT '',    -- comma-separated: push, discord, email
+    cooldown    INTEGER NOT NULL,            -- seconds before a subject fires again
+    quiet_start TEXT NOT NULL DEFAULT '',    -- HH:MM in timezone; '' means no quiet hours
+    quiet_end   TEXT NOT NULL DEFAULT '',
+    timezone    TEXT NOT NULL DEFAULT 'UTC', -- IANA name
+    enabled     INTEGER NOT NULL DEFAULT 1,
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL
+);
+
+-- One row per subject (job, blueprint, character, owner) a rule fired for.
+-- Rows older than 90 days are pruned.
+CREATE TABLE alert_history (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    rule_id     INTEGER NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+    subject     TEXT NOT NULL,      -- e.g. job:512345678
+    description TEXT NOT NULL,      -- the subject as shown in the alert
+    channels    TEXT NOT NULL,      -- comma-separated channels it was sent to
+    error       TEXT,               -- NULL if every channel took it
+    fired_at    DATETIME NOT NULL
+);
+
+CREATE INDEX idx_alert_history_rule_subject ON alert_history(rule_id, subject, fired_at);
@@ -0,0 +1,15 @@
+-- Blueprints pruned by syncBlueprints whose removal is not logged yet: they
+-- may have moved to another tracked owner whose blueprints have not been
+-- synced since. A later sync logs each as 'owner_changed' when it shows up
+-- under another owner, or as 'removed' once every other owner has synced or
+-- the grace period has passed. Kept here so a restart does not lose them.
+CREATE TABLE blueprint_removals (
+    blueprint_id INTEGER PRIMARY KEY,  -- EVE item_id
+    owner_type   TEXT NOT NULL,        -- owner it was pruned from
+    owner_id     INTEGER NOT NULL,
+    type_id      INTEGER NOT NULL,
+    location_id  INTEGER NOT NULL,
+    me_level     INTEGER NOT NULL,
+    te_level     INTEGER NOT NULL,
+    removed_at   DATETIME NOT NULL
+);
@@ -0,0 +1,11 @@
+-- sqlc queries for the affiliation_events table.
+
+-- name: InsertAffiliationEvent :exec
+INSERT INTO affiliation_events (character_id, character_name, corporation_id, event, old_id, old_name, new_id, new_name, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: ListAffiliationEventsSince :many
+SELECT id, character_id, character_name, corporation_id, event, old_id, old_name, new_id, new_name, created_at
+FROM affiliation_events
+WHERE created_at >= sqlc.arg('since')
+ORDER BY created_at DESC, id DESC;
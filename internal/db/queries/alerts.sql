@@ -0,0 +1,52 @@
+-- sqlc queries for the alert_rules and alert_history tables.
+
+-- name: CreateAlertRule :one
+INSERT INTO alert_rules (name, expression, channels, cooldown, quiet_start, quiet_end, timezone, enabled, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, name, expression, channels, cooldown, quiet_start, quiet_end, timezone, enabled, created_at, updated_at;
+
+-- name: GetAlertRule :one
+SELECT id, name, expression, channels, cooldown, quiet_start, quiet_end, timezone, enabled, created_at, updated_at
+FROM alert_rules
+WHERE id = ?;
+
+-- name: ListAlertRules :many
+SELECT id, name, expression, channels, cooldown, quiet_start, quiet_end, timezone, enabled, created_at, updated_at
+FROM alert_rules
+ORDER BY id;
+
+-- name: UpdateAlertRule :one
+UPDATE alert_rules
+SET name = ?, expression = ?, channels = ?, cooldown = ?, quiet_start = ?, quiet_end = ?, timezone = ?, enabled = ?, updated_at = ?
+WHERE id = ?
+RETURNING id, name, expression, channels, cooldown, quiet_start, quiet_end, timezone, enabled, created_at, updated_at;
+
+-- name: DeleteAlertRule :execrows
+DELETE FROM alert_rules WHERE id = ?;
+
+-- name: CreateAlertHistory :exec
+INSERT INTO alert_history (rule_id, subject, description, channels, error, fired_at)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: GetLastAlertFiring :one
+SELECT fired_at
+FROM alert_history
+WHERE rule_id = ? AND subject = ?
+ORDER BY fired_at DESC
+LIMIT 1;
+
+-- name: ListAlertHistory :many
+SELECT id, rule_id, subject, description, channels, error, fired_at
+FROM alert_history
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: ListAlertHistoryByRule :many
+SELECT id, rule_id, subject, description, channels, error, fired_at
+FROM alert_history
+WHERE rule_id = ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: DeleteAlertHistoryBefore :exec
+DELETE FROM alert_history WHERE fired_at < ?;
@@ -0,0 +1,13 @@
+-- sqlc queries for the assets table.
+
+-- name: UpsertAsset :exec
+INSERT OR REPLACE INTO assets (item_id, owner_type, owner_id, location_id, location_flag, location_type, type_id, name)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetAsset :one
+SELECT item_id, owner_id, location_id, location_type, owner_type, location_flag, type_id, name
+FROM assets
+WHERE item_id = ?;
+
+-- name: DeleteAssetsByOwner :exec
+DELETE FROM assets WHERE owner_type = ? AND owner_id = ?;
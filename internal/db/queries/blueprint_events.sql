@@ -0,0 +1,51 @@
+-- sqlc queries for the blueprint_events table.
+
+-- name: InsertBlueprintEvent :exec
+INSERT INTO blueprint_events (blueprint_id, owner_type, owner_id, type_id, event, old_value, new_value, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: ListBlueprintEventsSince :many
+SELECT
+    e.id,
+    e.blueprint_id,
+    e.owner_type,
+    e.owner_id,
+    COALESCE(c.name, corp.name, '') AS owner_name,
+    e.type_id,
+    COALESCE(t.name, '') AS type_name,
+    e.event,
+    e.old_value,
+    e.new_value,
+    e.created_at
+FROM blueprint_events e
+LEFT JOIN eve_types t ON t.id = e.type_id
+LEFT JOIN characters c ON e.owner_type = 'character' AND c.id = e.owner_id
+LEFT JOIN corporations corp ON e.owner_type = 'corporation' AND corp.id = e.owner_id
+WHERE e.created_at >= sqlc.arg('since')
+ORDER BY e.created_at DESC, e.id DESC;
+
+-- name: InsertBlueprintRemoval :exec
+INSERT INTO blueprint_removals (blueprint_id, owner_type, owner_id, type_id, location_id, me_level, te_level, removed_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(blueprint_id) DO UPDATE SET
+    owner_type  = excluded.owner_type,
+    owner_id    = excluded.owner_id,
+    type_id     = excluded.type_id,
+    location_id = excluded.location_id,
+    me_level    = excluded.me_level,
+    te_level    = excluded.te_level,
+    removed_at  = excluded.removed_at;
+
+-- name: GetBlueprintRemoval :one
+SELECT blueprint_id, owner_type, owner_id, type_id, location_id, me_level, te_level, removed_at
+FROM blueprint_removals
+WHERE blueprint_id = ?;
+
+-- name: ListBlueprintRemovals :many
+SELECT blueprint_id, owner_type, owner_id, type_id, location_id, me_level, te_level, removed_at
+FROM blueprint_removals
+ORDER BY blueprint_id;
+
+-- name: DeleteBlueprintRemoval :exec
+DELETE FROM blueprint_removals
+WHERE blueprint_id = ?;
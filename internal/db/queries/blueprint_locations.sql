@@ -0,0 +1,20 @@
+-- sqlc queries for the blueprint_locations table.
+
+-- name: UpsertBlueprintLocations :exec
+INSERT INTO blueprint_locations (blueprint_id, root_location_id, path)
+SELECT id, sqlc.arg('root_location_id'), sqlc.arg('path')
+FROM blueprints
+WHERE owner_type = sqlc.arg('owner_type')
+    AND owner_id = sqlc.arg('owner_id')
+    AND location_id = sqlc.arg('location_id')
+    AND location_flag = sqlc.arg('location_flag')
+ON CONFLICT(blueprint_id) DO UPDATE SET
+    root_location_id = excluded.root_location_id,
+    path             = excluded.path;
+
+-- name: DeleteBlueprintLocations :exec
+DELETE FROM blueprint_locations
+WHERE blueprint_id IN (
+    SELECT id FROM blueprints
+    WHERE owner_type = ? AND owner_id = ? AND location_id = ? AND location_flag = ?
+);
@@ -17,6 +17,26 @@ ON CONFLICT(id) DO UPDATE SET
 -- name: DeleteBlueprintsByOwner :exec
 DELETE FROM blueprints WHERE owner_type = ? AND owner_id = ?;
 
+-- name: DeleteBlueprintByID :exec
+DELETE FROM blueprints WHERE id = ?;
+
+-- name: GetBlueprint :one
+SELECT id, owner_type, owner_id, type_id, location_id, me_level, te_level, updated_at, location_flag
+FROM blueprints
+WHERE id = ?;
+
+-- name: GetBlueprintTypeName :one
+-- The type name of a blueprint, for notifications about its jobs.
+SELECT t.name
+FROM blueprints b
+JOIN eve_types t ON t.id = b.type_id
+WHERE b.id = ?;
+
+-- name: ListBlueprintsByOwner :many
+SELECT id, owner_type, owner_id, type_id, location_id, me_level, te_level, updated_at, location_flag
+FROM blueprints
+WHERE owner_type = ? AND owner_id = ?;
+
 -- name: ListBlueprintTypeIDsByOwner :many
 SELECT DISTINCT type_id
 FROM blueprints
@@ -46,6 +66,12 @@ SELECT
     cat.name AS category_name,
     b.location_id,
     loc.name AS location_name,
+    bl.path  AS location_path,
+    loc.solar_system_id AS system_id,
+    sys.name            AS system_name,
+    sys.region_id,
+    sys.region_name,
+    sys.security_status,
     b.me_level,
     b.te_level,
     b.updated_at,
@@ -64,7 +90,9 @@ LEFT JOIN jobs j ON j.blueprint_id = b.id
 LEFT JOIN characters c ON b.owner_type = 'character' AND c.id = b.owner_id
 LEFT JOIN corporations corp ON b.owner_type = 'corporation' AND corp.id = b.owner_id
 LEFT JOIN characters ic ON ic.id = j.installer_id
-LEFT JOIN eve_locations loc ON loc.id = b.location_id
+LEFT JOIN blueprint_locations bl ON bl.blueprint_id = b.id
+LEFT JOIN eve_locations loc ON loc.id = bl.root_location_id
+LEFT JOIN eve_systems sys ON sys.id = loc.solar_system_id
 WHERE
     (sqlc.narg('owner_type') IS NULL OR b.owner_type = sqlc.narg('owner_type'))
     AND (sqlc.narg('owner_id') IS NULL OR b.owner_id = sqlc.narg('owner_id'))
@@ -75,4 +103,55 @@ WHERE
         OR (sqlc.narg('status') = 'active' AND j.status = 'active')
         OR (sqlc.narg('status') = 'ready' AND j.status = 'ready')
     )
+    AND (sqlc.narg('region_id') IS NULL OR sys.region_id = sqlc.narg('region_id'))
+    AND (sqlc.narg('system_id') IS NULL OR loc.solar_system_id = sqlc.narg('system_id'))
+    AND (
+        sqlc.narg('security') IS NULL
+        OR (sqlc.narg('security') = 'high' AND sys.security_status >= 0.45)
+        OR (sqlc.narg('security') = 'low' AND sys.security_status > 0.0 AND sys.security_status < 0.45)
+        OR (sqlc.narg('security') = 'null' AND sys.security_status <= 0.0)
+    )
 ORDER BY b.id;
+
+-- name: ListBlueprintOwnerCounts :many
+-- The number of blueprints of each owner, for the owner facet of GET /api/blueprints.
+SELECT
+    b.owner_type,
+    b.owner_id,
+    COALESCE(c.name, corp.name, '') AS owner_name,
+    COUNT(*) AS count
+FROM blueprints b
+JOIN eve_types t ON t.id = b.type_id
+JOIN eve_groups g ON g.id = t.group_id
+JOIN eve_categories cat ON cat.id = g.category_id
+LEFT JOIN characters c ON b.owner_type = 'character' AND c.id = b.owner_id
+LEFT JOIN corporations corp ON b.owner_type = 'corporation' AND corp.id = b.owner_id
+GROUP BY b.owner_type, b.owner_id;
+
+-- name: ListBlueprintCategoryCounts :many
+-- The number of blueprints in each category, for the category facet of GET /api/blueprints.
+SELECT
+    g.category_id,
+    cat.name AS category_name,
+    COUNT(*) AS count
+FROM blueprints b
+JOIN eve_types t ON t.id = b.type_id
+JOIN eve_groups g ON g.id = t.group_id
+JOIN eve_categories cat ON cat.id = g.category_id
+GROUP BY g.category_id;
+
+-- name: ListBlueprintRegionCounts :many
+-- The number of blueprints in each region, for the region facet of GET /api/blueprints.
+-- Blueprints in locations not resolved yet are in none.
+SELECT
+    sys.region_id,
+    sys.region_name,
+    COUNT(*) AS count
+FROM blueprints b
+JOIN eve_types t ON t.id = b.type_id
+JOIN eve_groups g ON g.id = t.group_id
+JOIN eve_categories cat ON cat.id = g.category_id
+JOIN blueprint_locations bl ON bl.blueprint_id = b.id
+JOIN eve_locations loc ON loc.id = bl.root_location_id
+JOIN eve_systems sys ON sys.id = loc.solar_system_id
+GROUP BY sys.region_id;
@@ -0,0 +1,19 @@
+-- sqlc queries for the calendar_feeds table.
+
+-- name: CreateCalendarFeed :one
+INSERT INTO calendar_feeds (name, token, filters, created_at)
+VALUES (?, ?, ?, ?)
+RETURNING id, name, token, filters, created_at;
+
+-- name: ListCalendarFeeds :many
+SELECT id, name, token, filters, created_at
+FROM calendar_feeds
+ORDER BY id;
+
+-- name: GetCalendarFeedByToken :one
+SELECT id, name, token, filters, created_at
+FROM calendar_feeds
+WHERE token = ?;
+
+-- name: DeleteCalendarFeed :execrows
+DELETE FROM calendar_feeds WHERE id = ?;
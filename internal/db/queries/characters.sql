@@ -2,31 +2,42 @@
 -- See https://docs.sqlc.dev for query annotation syntax.
 
 -- name: GetCharacter :one
-SELECT id, name, access_token, refresh_token, token_expiry, created_at, corporation_id, corporation_name
+SELECT id, name, access_token, refresh_token, token_expiry, created_at, corporation_id, corporation_name, needs_reauth, needs_reauth_at, scopes, owner_hash, transferred, transferred_at, alliance_id, alliance_name, affiliation_at, roles
 FROM characters
 WHERE id = ?;
 
 -- name: ListCharacters :many
-SELECT id, name, access_token, refresh_token, token_expiry, created_at, corporation_id, corporation_name
+SELECT id, name, access_token, refresh_token, token_expiry, created_at, corporation_id, corporation_name, needs_reauth, needs_reauth_at, scopes, owner_hash, transferred, transferred_at, alliance_id, alliance_name, affiliation_at, roles
 FROM characters
 ORDER BY name;
 
 -- name: ListCharactersByCorporation :many
-SELECT id, name, access_token, refresh_token, token_expiry, created_at, corporation_id, corporation_name
+SELECT id, name, access_token, refresh_token, token_expiry, created_at, corporation_id, corporation_name, needs_reauth, needs_reauth_at, scopes, owner_hash, transferred, transferred_at, alliance_id, alliance_name, affiliation_at, roles
 FROM characters
 WHERE corporation_id = ?
 ORDER BY name;
 
 -- name: UpsertCharacter :exec
-INSERT INTO characters (id, name, access_token, refresh_token, token_expiry, corporation_id, corporation_name)
-VALUES (?, ?, ?, ?, ?, ?, ?)
+-- A changed owner hash flags the character as transferred in the same
+-- statement; one stored before owner hashes were recorded (empty) does not.
+INSERT INTO characters (id, name, access_token, refresh_token, token_expiry, corporation_id, corporation_name, scopes, owner_hash)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(id) DO UPDATE SET
     name             = excluded.name,
     access_token     = excluded.access_token,
     refresh_token    = excluded.refresh_token,
     token_expiry     = excluded.token_expiry,
     corporation_id   = excluded.corporation_id,
-    corporation_name = excluded.corporation_name;
+    corporation_name = excluded.corporation_name,
+    scopes           = excluded.scopes,
+    owner_hash       = excluded.owner_hash,
+    transferred      = CASE WHEN characters.owner_hash NOT IN ('', excluded.owner_hash)
+                            THEN 1 ELSE characters.transferred END,
+    transferred_at   = CASE WHEN characters.owner_hash NOT IN ('', excluded.owner_hash)
+                            THEN COALESCE(characters.transferred_at, CURRENT_TIMESTAMP)
+                            ELSE characters.transferred_at END,
+    needs_reauth     = 0,
+    needs_reauth_at  = NULL;
 
 -- name: ListCharactersWithMeta :many
 SELECT
@@ -34,7 +45,14 @@ SELECT
   ch.name,
   ch.corporation_id,
   ch.corporation_name,
+  ch.alliance_id,
+  ch.alliance_name,
   ch.created_at,
+  ch.needs_reauth,
+  ch.needs_reauth_at,
+  ch.scopes,
+  ch.transferred,
+  ch.transferred_at,
   CASE WHEN corp.id IS NOT NULL THEN 1 ELSE 0 END AS is_delegate,
   CASE WHEN corp.id IS NOT NULL THEN (
     SELECT last_error FROM sync_state
@@ -45,5 +63,50 @@ FROM characters ch
 LEFT JOIN corporations corp ON corp.delegate_id = ch.id
 ORDER BY ch.name;
 
+-- name: UpdateCharacterTokens :exec
+-- A changed owner hash flags the character as transferred in the same
+-- statement; one stored before owner hashes were recorded (empty) does not.
+UPDATE characters
+SET access_token   = sqlc.arg('access_token'),
+    refresh_token  = sqlc.arg('refresh_token'),
+    token_expiry   = sqlc.arg('token_expiry'),
+    scopes         = sqlc.arg('scopes'),
+    owner_hash     = sqlc.arg('owner_hash'),
+    transferred    = CASE WHEN owner_hash NOT IN ('', sqlc.arg('owner_hash'))
+                          THEN 1 ELSE transferred END,
+    transferred_at = CASE WHEN owner_hash NOT IN ('', sqlc.arg('owner_hash'))
+                          THEN COALESCE(transferred_at, CURRENT_TIMESTAMP) ELSE transferred_at END
+WHERE id = sqlc.arg('id');
+
+-- name: UpdateCharacterAffiliation :exec
+UPDATE characters
+SET corporation_id   = ?,
+    corporation_name = ?,
+    alliance_id      = ?,
+    alliance_name    = ?,
+    affiliation_at   = ?
+WHERE id = ?;
+
+-- name: UpdateCharacterRoles :exec
+UPDATE characters SET roles = ? WHERE id = ?;
+
+-- name: MarkCharacterNeedsReauth :exec
+UPDATE characters
+SET needs_reauth    = 1,
+    needs_reauth_at = COALESCE(needs_reauth_at, CURRENT_TIMESTAMP)
+WHERE id = ?;
+
+-- name: MarkCharacterTransferred :exec
+UPDATE characters
+SET transferred    = 1,
+    transferred_at = COALESCE(transferred_at, CURRENT_TIMESTAMP)
+WHERE id = ?;
+
+-- name: ClearCharacterTransferred :exec
+UPDATE characters
+SET transferred    = 0,
+    transferred_at = NULL
+WHERE id = ?;
+
 -- name: DeleteCharacter :exec
 DELETE FROM characters WHERE id = ?;
@@ -0,0 +1,11 @@
+-- sqlc queries for the corp_divisions table.
+
+-- name: UpsertCorpDivision :exec
+INSERT OR REPLACE INTO corp_divisions (corporation_id, division, name)
+VALUES (?, ?, ?);
+
+-- name: GetCorpDivisionName :one
+SELECT name FROM corp_divisions WHERE corporation_id = ? AND division = ?;
+
+-- name: DeleteCorpDivisions :exec
+DELETE FROM corp_divisions WHERE corporation_id = ?;
@@ -2,14 +2,16 @@
 -- See https://docs.sqlc.dev for query annotation syntax.
 
 -- name: GetCorporation :one
-SELECT id, name, delegate_id, created_at
+SELECT id, name, delegate_id, created_at, active_character_id
 FROM corporations
 WHERE id = ?;
 
 -- name: ListCorporations :many
-SELECT c.id, c.name, c.delegate_id, ch.name AS delegate_name, c.created_at
+SELECT c.id, c.name, c.delegate_id, ch.name AS delegate_name, c.created_at,
+       ac.id AS active_character_id, ac.name AS active_character_name
 FROM corporations c
-JOIN characters ch ON ch.id = c.delegate_id
+LEFT JOIN characters ch ON ch.id = c.delegate_id
+LEFT JOIN characters ac ON ac.id = c.active_character_id AND ac.corporation_id = c.id
 ORDER BY c.name;
 
 -- name: InsertCorporation :exec
@@ -23,5 +25,14 @@ VALUES (?, ?, ?);
 -- name: UpdateCorporationDelegate :exec
 UPDATE corporations SET delegate_id = ? WHERE id = ?;
 
+-- name: AdoptCorporation :exec
+UPDATE corporations SET delegate_id = ? WHERE id = ? AND delegate_id IS NULL;
+
+-- name: OrphanCorporation :exec
+UPDATE corporations SET delegate_id = NULL WHERE id = ?;
+
+-- name: SetCorporationActiveCharacter :exec
+UPDATE corporations SET active_character_id = ? WHERE id = ?;
+
 -- name: DeleteCorporation :exec
 DELETE FROM corporations WHERE id = ?;
@@ -21,8 +21,13 @@ DELETE FROM jobs WHERE owner_type = ? AND owner_id = ?;
 -- name: DeleteJobByID :exec
 DELETE FROM jobs WHERE id = ?;
 
--- name: ListJobIDsByOwner :many
-SELECT id FROM jobs WHERE owner_type = ? AND owner_id = ?;
+-- name: DeleteJobsByBlueprintID :exec
+DELETE FROM jobs WHERE blueprint_id = ?;
+
+-- name: ListJobsByOwner :many
+SELECT id, blueprint_id, owner_type, owner_id, installer_id, activity, status, start_date, end_date, updated_at
+FROM jobs
+WHERE owner_type = ? AND owner_id = ?;
 
 -- name: CountIdleBlueprints :one
 SELECT COUNT(*) FROM blueprints b
@@ -0,0 +1,35 @@
+-- sqlc queries for the notification_log and blueprint_idle tables.
+
+-- name: GetNotification :one
+SELECT kind, subject, sent_at
+FROM notification_log
+WHERE kind = ? AND subject = ?;
+
+-- name: RecordNotification :exec
+INSERT INTO notification_log (kind, subject, sent_at)
+VALUES (?, ?, ?)
+ON CONFLICT (kind, subject) DO UPDATE SET
+    sent_at = excluded.sent_at;
+
+-- name: DeleteNotification :exec
+DELETE FROM notification_log WHERE kind = ? AND subject = ?;
+
+-- name: DeleteNotificationsBefore :exec
+DELETE FROM notification_log WHERE sent_at < ?;
+
+-- name: MarkIdleBlueprints :exec
+INSERT OR IGNORE INTO blueprint_idle (blueprint_id, idle_since)
+SELECT b.id, ?
+FROM blueprints b
+WHERE NOT EXISTS (SELECT 1 FROM jobs j WHERE j.blueprint_id = b.id);
+
+-- name: ClearBusyBlueprints :exec
+DELETE FROM blueprint_idle
+WHERE blueprint_id IN (SELECT blueprint_id FROM jobs)
+   OR blueprint_id NOT IN (SELECT id FROM blueprints);
+
+-- name: ListIdleBlueprintsSince :many
+SELECT blueprint_id, idle_since
+FROM blueprint_idle
+WHERE idle_since <= ?
+ORDER BY idle_since, blueprint_id;
@@ -0,0 +1,30 @@
+-- sqlc queries for the push_keys and push_subscriptions tables.
+
+-- name: GetPushKeys :one
+SELECT id, private_key, public_key, created_at
+FROM push_keys
+WHERE id = 1;
+
+-- name: InsertPushKeys :exec
+INSERT OR IGNORE INTO push_keys (id, private_key, public_key, created_at)
+VALUES (1, ?, ?, ?);
+
+-- name: UpsertPushSubscription :one
+INSERT INTO push_subscriptions (endpoint, p256dh, auth, user_agent, created_at)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(endpoint) DO UPDATE SET
+    p256dh     = excluded.p256dh,
+    auth       = excluded.auth,
+    user_agent = excluded.user_agent
+RETURNING id, endpoint, p256dh, auth, user_agent, created_at;
+
+-- name: ListPushSubscriptions :many
+SELECT id, endpoint, p256dh, auth, user_agent, created_at
+FROM push_subscriptions
+ORDER BY id;
+
+-- name: DeletePushSubscription :execrows
+DELETE FROM push_subscriptions WHERE id = ?;
+
+-- name: DeletePushSubscriptionByEndpoint :exec
+DELETE FROM push_subscriptions WHERE endpoint = ?;
@@ -0,0 +1,81 @@
+-- sqlc queries for the search_index full-text table.
+
+-- name: ClearSearchIndex :exec
+DELETE FROM search_index;
+
+-- name: IndexBlueprints :exec
+INSERT INTO search_index (entity_type, entity_id, name, detail, location, owner)
+SELECT
+    'blueprint',
+    b.id,
+    t.name,
+    g.name || ' ' || cat.name,
+    COALESCE(loc.name || COALESCE(' › ' || NULLIF(bl.path, ''), ''), ''),
+    COALESCE(c.name, corp.name, '')
+FROM blueprints b
+JOIN eve_types t ON t.id = b.type_id
+JOIN eve_groups g ON g.id = t.group_id
+JOIN eve_categories cat ON cat.id = g.category_id
+LEFT JOIN characters c ON b.owner_type = 'character' AND c.id = b.owner_id
+LEFT JOIN corporations corp ON b.owner_type = 'corporation' AND corp.id = b.owner_id
+LEFT JOIN blueprint_locations bl ON bl.blueprint_id = b.id
+LEFT JOIN eve_locations loc ON loc.id = bl.root_location_id;
+
+-- name: IndexCharacters :exec
+INSERT INTO search_index (entity_type, entity_id, name, detail, location, owner)
+SELECT 'character', id, name, TRIM(corporation_name || ' ' || COALESCE(alliance_name, '')), '', ''
+FROM characters;
+
+-- name: IndexCorporations :exec
+INSERT INTO search_index (entity_type, entity_id, name, detail, location, owner)
+SELECT 'corporation', id, name, '', '', ''
+FROM corporations;
+
+-- name: IndexLocations :exec
+INSERT INTO search_index (entity_type, entity_id, name, detail, location, owner)
+SELECT 'location', loc.id, loc.name, COALESCE(sys.name || ' ' || sys.region_name, ''), '', ''
+FROM eve_locations loc
+LEFT JOIN eve_systems sys ON sys.id = loc.solar_system_id
+WHERE EXISTS (SELECT 1 FROM blueprint_locations bl WHERE bl.root_location_id = loc.id);
+
+-- name: SearchIndex :many
+-- Ranked hits for an FTS5 query, at most per_type of each entity type, best
+-- first. Matches are wrapped in char(2) … char(3). Entries of entities deleted
+-- since the last rebuild are skipped.
+WITH hits AS (
+    SELECT
+        s.entity_type,
+        s.entity_id,
+        highlight(search_index, 2, char(2), char(3)) AS name_highlight,
+        highlight(search_index, 3, char(2), char(3)) AS detail_highlight,
+        highlight(search_index, 4, char(2), char(3)) AS location_highlight,
+        highlight(search_index, 5, char(2), char(3)) AS owner_highlight,
+        bm25(search_index, 0.0, 0.0, 10.0, 2.0, 3.0, 2.0) AS rank
+    FROM search_index s
+    WHERE search_index MATCH sqlc.arg('query')
+        AND CASE s.entity_type
+            WHEN 'blueprint'   THEN EXISTS (SELECT 1 FROM blueprints b WHERE b.id = s.entity_id)
+            WHEN 'character'   THEN EXISTS (SELECT 1 FROM characters c WHERE c.id = s.entity_id)
+            WHEN 'corporation' THEN EXISTS (SELECT 1 FROM corporations c WHERE c.id = s.entity_id)
+            ELSE 1
+        END
+),
+ranked AS (
+    SELECT
+        hits.*,
+        ROW_NUMBER() OVER (PARTITION BY entity_type ORDER BY rank, entity_id) AS position,
+        COUNT(*) OVER (PARTITION BY entity_type) AS total
+    FROM hits
+)
+SELECT
+    entity_type,
+    CAST(entity_id AS INTEGER) AS entity_id,
+    name_highlight,
+    detail_highlight,
+    location_highlight,
+    owner_highlight,
+    CAST(rank AS REAL) AS rank,
+    total
+FROM ranked
+WHERE position <= sqlc.arg('per_type')
+ORDER BY rank, entity_type, entity_id;
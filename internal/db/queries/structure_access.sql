@@ -0,0 +1,14 @@
+-- sqlc queries for the structure_access table.
+
+-- name: ListStructureAccess :many
+SELECT structure_id, character_id, status, checked_at
+FROM structure_access
+WHERE structure_id = ?
+ORDER BY character_id;
+
+-- name: UpsertStructureAccess :exec
+INSERT INTO structure_access (structure_id, character_id, status, checked_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (structure_id, character_id) DO UPDATE SET
+    status     = excluded.status,
+    checked_at = excluded.checked_at;
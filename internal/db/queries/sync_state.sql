@@ -2,7 +2,7 @@
 -- See https://docs.sqlc.dev for query annotation syntax.
 
 -- name: GetSyncState :one
-SELECT owner_type, owner_id, endpoint, last_sync, cache_until, last_error
+SELECT owner_type, owner_id, endpoint, last_sync, cache_until, last_error, missing_scope
 FROM sync_state
 WHERE owner_type = ? AND owner_id = ? AND endpoint = ?;
 
@@ -11,12 +11,20 @@ INSERT INTO sync_state (owner_type, owner_id, endpoint, last_sync, cache_until,
 VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?)
 ON CONFLICT(owner_type, owner_id, endpoint) DO UPDATE SET last_error = excluded.last_error;
 
+-- name: UpdateSyncStateMissingScope :exec
+INSERT INTO sync_state (owner_type, owner_id, endpoint, last_sync, cache_until, missing_scope)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?)
+ON CONFLICT(owner_type, owner_id, endpoint) DO UPDATE SET
+    missing_scope = excluded.missing_scope,
+    last_error    = NULL;
+
 -- name: UpsertSyncState :exec
 INSERT INTO sync_state (owner_type, owner_id, endpoint, last_sync, cache_until)
 VALUES (?, ?, ?, ?, ?)
 ON CONFLICT(owner_type, owner_id, endpoint) DO UPDATE SET
-    last_sync   = excluded.last_sync,
-    cache_until = excluded.cache_until;
+    last_sync     = excluded.last_sync,
+    cache_until   = excluded.cache_until,
+    missing_scope = NULL;
 
 -- name: ListSyncStatus :many
 SELECT
@@ -25,7 +33,9 @@ SELECT
     COALESCE(c.name, corp.name, '') AS owner_name,
     ss.endpoint,
     ss.last_sync,
-    ss.cache_until
+    ss.cache_until,
+    ss.last_error,
+    ss.missing_scope
 FROM sync_state ss
 LEFT JOIN characters c ON ss.owner_type = 'character' AND c.id = ss.owner_id
 LEFT JOIN corporations corp ON ss.owner_type = 'corporation' AND corp.id = ss.owner_id
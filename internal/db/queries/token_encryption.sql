@@ -0,0 +1,27 @@
+-- sqlc queries for token encryption: the key settings row and the raw token
+-- columns of the characters table, used to encrypt and re-key stored tokens.
+
+-- name: GetTokenEncryption :one
+SELECT id, key_source, salt, key_check, updated_at
+FROM token_encryption
+WHERE id = 1;
+
+-- name: UpsertTokenEncryption :exec
+INSERT INTO token_encryption (id, key_source, salt, key_check)
+VALUES (1, ?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET
+    key_source = excluded.key_source,
+    salt       = excluded.salt,
+    key_check  = excluded.key_check,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: ListCharacterTokens :many
+SELECT id, access_token, refresh_token
+FROM characters
+ORDER BY id;
+
+-- name: SetCharacterTokenColumns :exec
+UPDATE characters
+SET access_token  = ?,
+    refresh_token = ?
+WHERE id = ?;
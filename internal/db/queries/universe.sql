@@ -11,10 +11,24 @@ INSERT OR IGNORE INTO eve_groups (id, category_id, name) VALUES (?, ?, ?);
 INSERT OR IGNORE INTO eve_types (id, group_id, name) VALUES (?, ?, ?);
 
 -- name: GetLocation :one
-SELECT id, name, resolved_at FROM eve_locations WHERE id = ?;
+SELECT id, name, resolved_at, solar_system_id FROM eve_locations WHERE id = ?;
 
 -- name: InsertLocation :exec
-INSERT OR REPLACE INTO eve_locations (id, name, resolved_at) VALUES (?, ?, ?);
+INSERT OR REPLACE INTO eve_locations (id, name, resolved_at, solar_system_id) VALUES (?, ?, ?, ?);
+
+-- name: GetEveSystem :one
+SELECT id, name, security_status, constellation_id, constellation_name, region_id, region_name, resolved_at
+FROM eve_systems WHERE id = ?;
+
+-- name: UpsertEveSystem :exec
+INSERT OR REPLACE INTO eve_systems (id, name, security_status, constellation_id, constellation_name, region_id, region_name, resolved_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?);
 
 -- name: GetEveType :one
 SELECT id, group_id, name FROM eve_types WHERE id = ?;
+
+-- name: GetEveGroup :one
+SELECT id, category_id, name FROM eve_groups WHERE id = ?;
+
+-- name: GetEveCategory :one
+SELECT id, name FROM eve_categories WHERE id = ?;
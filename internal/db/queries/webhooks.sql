@@ -0,0 +1,47 @@
+-- sqlc queries for the webhooks and webhook_deliveries tables.
+
+-- name: CreateWebhook :one
+INSERT INTO webhooks (name, url, events, template, headers, secret, enabled, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, name, url, events, template, headers, secret, enabled, created_at, updated_at;
+
+-- name: GetWebhook :one
+SELECT id, name, url, events, template, headers, secret, enabled, created_at, updated_at
+FROM webhooks
+WHERE id = ?;
+
+-- name: ListWebhooks :many
+SELECT id, name, url, events, template, headers, secret, enabled, created_at, updated_at
+FROM webhooks
+ORDER BY id;
+
+-- name: UpdateWebhook :one
+UPDATE webhooks
+SET name = ?, url = ?, events = ?, template = ?, headers = ?, enabled = ?, updated_at = ?
+WHERE id = ?
+RETURNING id, name, url, events, template, headers, secret, enabled, created_at, updated_at;
+
+-- name: SetWebhookSecret :one
+UPDATE webhooks
+SET secret = ?, updated_at = ?
+WHERE id = ?
+RETURNING id, name, url, events, template, headers, secret, enabled, created_at, updated_at;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = ?;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries
+    (webhook_id, event_id, event_type, attempts, status_code, error, response, duration_ms, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, webhook_id, event_id, event_type, attempts, status_code, error, response, duration_ms, created_at;
+
+-- name: ListWebhookDeliveries :many
+SELECT id, webhook_id, event_id, event_type, attempts, status_code, error, response, duration_ms, created_at
+FROM webhook_deliveries
+WHERE webhook_id = ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: DeleteWebhookDeliveriesBefore :exec
+DELETE FROM webhook_deliveries WHERE created_at < ?;
@@ -7,12 +7,51 @@ import (
 	"time"
 )
 
-// CorpAsset represents one entry from GET /corporations/{id}/assets/.
-type CorpAsset struct {
+// assetNamesBatchSize is the maximum number of item IDs ESI accepts in a
+// single POST /characters/{id}/assets/names/ or
+// POST /corporations/{id}/assets/names/ request.
+const assetNamesBatchSize = 1000
+
+// Asset represents one entry from GET /characters/{id}/assets/ or
+// GET /corporations/{id}/assets/. LocationID is a station, structure, or
+// solar system, or — when LocationType is "item" — the item containing it.
+type Asset struct {
 	ItemID       int64  `json:"item_id"`
+	TypeID       int64  `json:"type_id"`
 	LocationID   int64  `json:"location_id"`
 	LocationFlag string `json:"location_flag"`
 	LocationType string `json:"location_type"`
+	IsSingleton  bool   `json:"is_singleton"`
+}
+
+// AssetName is one entry returned by the asset names endpoints.
+// ESI returns "None" for an item that was never named.
+type AssetName struct {
+	ItemID int64  `json:"item_id"`
+	Name   string `json:"name"`
+}
+
+// Division is one corporation hangar division returned by
+// GET /corporations/{id}/divisions/. Name is empty for an unnamed division.
+type Division struct {
+	Division int64  `json:"division"`
+	Name     string `json:"name"`
+}
+
+// divisionsResponse is the relevant subset of GET /corporations/{id}/divisions/.
+// Wallet divisions are ignored.
+type divisionsResponse struct {
+	Hangar []Division `json:"hangar"`
+}
+
+// GetCharacterAssets fetches one page of character assets.
+// Returns the raw asset records, the total page count (from X-Pages header),
+// the ESI cache expiry, and any error.
+// Caller is responsible for iterating all pages.
+// Requires esi-assets.read_assets.v1 scope.
+func (c *httpClient) GetCharacterAssets(ctx context.Context, characterID int64, token string, page int) ([]Asset, int, time.Time, error) {
+	url := fmt.Sprintf("%s/characters/%d/assets/?page=%d", c.baseURL, characterID, page)
+	return c.getAssetsPage(ctx, url, token)
 }
 
 // GetCorporationAssets fetches one page of corporation assets.
@@ -20,16 +59,80 @@ type CorpAsset struct {
 // the ESI cache expiry, and any error.
 // Caller is responsible for iterating all pages and filtering by LocationFlag.
 // Requires esi-assets.read_corporation_assets.v1 scope.
-func (c *httpClient) GetCorporationAssets(ctx context.Context, corpID int64, token string, page int) ([]CorpAsset, int, time.Time, error) {
+func (c *httpClient) GetCorporationAssets(ctx context.Context, corpID int64, token string, page int) ([]Asset, int, time.Time, error) {
 	url := fmt.Sprintf("%s/corporations/%d/assets/?page=%d", c.baseURL, corpID, page)
+	return c.getAssetsPage(ctx, url, token)
+}
+
+// getAssetsPage fetches and parses one page of an assets endpoint.
+func (c *httpClient) getAssetsPage(ctx context.Context, url, token string) ([]Asset, int, time.Time, error) {
 	body, headers, cacheUntil, err := c.doWithHeader(ctx, url, token)
 	if err != nil {
 		return nil, 0, time.Time{}, err
 	}
 	totalPages := parseXPages(headers.Get("X-Pages"))
-	var assets []CorpAsset
+	var assets []Asset
 	if err := json.Unmarshal(body, &assets); err != nil {
 		return nil, 0, time.Time{}, fmt.Errorf("parsing assets response: %w", err)
 	}
 	return assets, totalPages, cacheUntil, nil
 }
+
+// PostCharacterAssetNames returns the names of the given character items via
+// POST /characters/{id}/assets/names/. IDs are sent in batches of
+// assetNamesBatchSize. Only singleton items such as containers and ships can
+// be named. Requires esi-assets.read_assets.v1 scope.
+func (c *httpClient) PostCharacterAssetNames(ctx context.Context, characterID int64, token string, itemIDs []int64) ([]AssetName, error) {
+	url := fmt.Sprintf("%s/characters/%d/assets/names/", c.baseURL, characterID)
+	return c.postAssetNames(ctx, url, token, itemIDs)
+}
+
+// PostCorporationAssetNames returns the names of the given corporation items
+// via POST /corporations/{id}/assets/names/. IDs are sent in batches of
+// assetNamesBatchSize. Requires esi-assets.read_corporation_assets.v1 scope.
+func (c *httpClient) PostCorporationAssetNames(ctx context.Context, corpID int64, token string, itemIDs []int64) ([]AssetName, error) {
+	url := fmt.Sprintf("%s/corporations/%d/assets/names/", c.baseURL, corpID)
+	return c.postAssetNames(ctx, url, token, itemIDs)
+}
+
+// postAssetNames posts itemIDs to an asset names endpoint in batches.
+// Returns an empty slice for an empty input.
+func (c *httpClient) postAssetNames(ctx context.Context, url, token string, itemIDs []int64) ([]AssetName, error) {
+	var result []AssetName
+	for start := 0; start < len(itemIDs); start += assetNamesBatchSize {
+		batch := itemIDs[start:min(start+assetNamesBatchSize, len(itemIDs))]
+
+		reqBody, err := json.Marshal(batch)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling item ids: %w", err)
+		}
+		body, err := c.doPost(ctx, url, token, reqBody)
+		if err != nil {
+			return nil, fmt.Errorf("posting asset names: %w", err)
+		}
+
+		var entries []AssetName
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return nil, fmt.Errorf("parsing asset names response: %w", err)
+		}
+		result = append(result, entries...)
+	}
+	return result, nil
+}
+
+// GetCorporationDivisions fetches the hangar division names of a corporation
+// via GET /corporations/{id}/divisions/. Requires the
+// esi-corporations.read_divisions.v1 scope and the Director role.
+func (c *httpClient) GetCorporationDivisions(ctx context.Context, corpID int64, token string) ([]Division, time.Time, error) {
+	url := fmt.Sprintf("%s/corporations/%d/divisions/", c.baseURL, corpID)
+	body, cacheUntil, err := c.do(ctx, url, token)
+	if err != nil {
+		return nil, cacheUntil, fmt.Errorf("fetching divisions for corporation %d: %w", corpID, err)
+	}
+
+	var resp divisionsResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, time.Time{}, fmt.Errorf("parsing divisions for corporation %d: %w", corpID, err)
+	}
+	return resp.Hangar, cacheUntil, nil
+}
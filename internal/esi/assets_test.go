@@ -2,8 +2,10 @@ package esi
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -208,6 +210,130 @@ func TestGetCorporationAssets_MultiPage_SecondPage(t *testing.T) {
 	}
 }
 
+func TestGetCharacterAssets_ParsesResponse(t *testing.T) {
+	payload := `[
+		{"item_id":1000000000101,"type_id":17366,"location_flag":"Hangar","location_id":60003760,"location_type":"station","is_singleton":true},
+		{"item_id":1000000000102,"type_id":2047,"location_flag":"Unlocked","location_id":1000000000101,"location_type":"item","is_singleton":true}
+	]`
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("X-Pages", "2")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer srv.Close()
+
+	c := newTestClient(srv)
+	assets, totalPages, _, err := c.GetCharacterAssets(context.Background(), 12345678, "tok", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/characters/12345678/assets/" {
+		t.Errorf("unexpected URL path: %q", gotPath)
+	}
+	if totalPages != 2 {
+		t.Errorf("totalPages: got %d, want 2", totalPages)
+	}
+	if len(assets) != 2 {
+		t.Fatalf("expected 2 assets, got %d", len(assets))
+	}
+	want := Asset{ItemID: 1000000000101, TypeID: 17366, LocationID: 60003760, LocationFlag: "Hangar", LocationType: "station", IsSingleton: true}
+	if assets[0] != want {
+		t.Errorf("asset 0: got %+v, want %+v", assets[0], want)
+	}
+}
+
+// --- Asset names ---
+
+func TestPostCorporationAssetNames_BatchesWithToken(t *testing.T) {
+	var batches [][]int64
+	var gotAuth, gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotPath = r.URL.Path
+		var ids []int64
+		if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+		batches = append(batches, ids)
+		names := make([]AssetName, len(ids))
+		for i, id := range ids {
+			names[i] = AssetName{ItemID: id, Name: "None"}
+		}
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(names)
+	}))
+	defer srv.Close()
+
+	ids := make([]int64, assetNamesBatchSize+1)
+	for i := range ids {
+		ids[i] = int64(1000000000000 + i)
+	}
+
+	c := newTestClient(srv)
+	names, err := c.PostCorporationAssetNames(context.Background(), 99000001, "mytoken", ids)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/corporations/99000001/assets/names/" {
+		t.Errorf("unexpected URL path: %q", gotPath)
+	}
+	if gotAuth != "Bearer mytoken" {
+		t.Errorf("Authorization: got %q, want %q", gotAuth, "Bearer mytoken")
+	}
+	if len(batches) != 2 || len(batches[0]) != assetNamesBatchSize || len(batches[1]) != 1 {
+		t.Errorf("expected batches of %d and 1 IDs, got %d batches", assetNamesBatchSize, len(batches))
+	}
+	if len(names) != len(ids) {
+		t.Errorf("expected %d names, got %d", len(ids), len(names))
+	}
+}
+
+func TestPostCharacterAssetNames_EmptyInput_NoRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no request expected for empty input")
+	}))
+	defer srv.Close()
+
+	c := newTestClient(srv)
+	names, err := c.PostCharacterAssetNames(context.Background(), 12345678, "tok", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(names) != 0 {
+		t.Errorf("expected no names, got %v", names)
+	}
+}
+
+// --- GetCorporationDivisions ---
+
+func TestGetCorporationDivisions_ReturnsHangarDivisions(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{
+			"hangar":[{"division":1},{"division":3,"name":"Research BPOs"}],
+			"wallet":[{"division":1,"name":"Master Wallet"}]
+		}`))
+	}))
+	defer srv.Close()
+
+	c := newTestClient(srv)
+	divisions, _, err := c.GetCorporationDivisions(context.Background(), 99000001, "tok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/corporations/99000001/divisions/" {
+		t.Errorf("unexpected URL path: %q", gotPath)
+	}
+	want := []Division{{Division: 1}, {Division: 3, Name: "Research BPOs"}}
+	if !slices.Equal(divisions, want) {
+		t.Errorf("divisions: got %+v, want %+v", divisions, want)
+	}
+}
+
 // --- GetStation ---
 
 func TestGetStation_ReturnsName(t *testing.T) {
@@ -217,17 +343,20 @@ func TestGetStation_ReturnsName(t *testing.T) {
 			return
 		}
 		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(`{"station_id":60015146,"name":"Ibura IX - Moon 11 - Spacelane Patrol Testing Facilities"}`))
+		_, _ = w.Write([]byte(`{"station_id":60015146,"name":"Ibura IX - Moon 11 - Spacelane Patrol Testing Facilities","system_id":30002507}`))
 	}))
 	defer srv.Close()
 
 	c := newTestClient(srv)
-	name, err := c.GetStation(context.Background(), 60015146)
+	station, err := c.GetStation(context.Background(), 60015146)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if name != "Ibura IX - Moon 11 - Spacelane Patrol Testing Facilities" {
-		t.Errorf("name: got %q", name)
+	if station.Name != "Ibura IX - Moon 11 - Spacelane Patrol Testing Facilities" {
+		t.Errorf("name: got %q", station.Name)
+	}
+	if station.SystemID != 30002507 {
+		t.Errorf("system_id: got %d, want 30002507", station.SystemID)
 	}
 }
 
@@ -284,7 +413,7 @@ func TestGetStation_HTTPError(t *testing.T) {
 }
 
 func TestGetStation_CacheUntilNotReturned(t *testing.T) {
-	// GetStation only returns (string, error). Verify we get the name and no error
+	// GetStation returns no cache time. Verify we get the station and no error
 	// even when an Expires header is present — the cache time is intentionally discarded.
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Expires", time.Now().Add(10*time.Minute).UTC().Format(http.TimeFormat))
@@ -294,11 +423,11 @@ func TestGetStation_CacheUntilNotReturned(t *testing.T) {
 	defer srv.Close()
 
 	c := newTestClient(srv)
-	name, err := c.GetStation(context.Background(), 60003760)
+	station, err := c.GetStation(context.Background(), 60003760)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if name != "Jita Station" {
-		t.Errorf("name: got %q, want Jita Station", name)
+	if station.Name != "Jita Station" {
+		t.Errorf("name: got %q, want Jita Station", station.Name)
 	}
 }
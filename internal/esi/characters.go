@@ -0,0 +1,87 @@
+package esi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// affiliationBatchSize is the maximum number of character IDs ESI accepts in
+// a single POST /characters/affiliation/ request.
+const affiliationBatchSize = 1000
+
+// Corporation roles that grant access to the corporation endpoints used by
+// Auspex. A Director holds every role implicitly.
+const (
+	RoleDirector       = "Director"
+	RoleFactoryManager = "Factory_Manager"
+)
+
+// characterRolesResponse is the relevant subset of GET /characters/{id}/roles/.
+// Roles granted only at a headquarters, base, or other location do not open
+// the corporation endpoints and are ignored.
+type characterRolesResponse struct {
+	Roles []string `json:"roles"`
+}
+
+// CharacterAffiliation is one item returned by POST /characters/affiliation/.
+// AllianceID and FactionID are 0 when the character's corporation has none.
+type CharacterAffiliation struct {
+	CharacterID   int64 `json:"character_id"`
+	CorporationID int64 `json:"corporation_id"`
+	AllianceID    int64 `json:"alliance_id"`
+	FactionID     int64 `json:"faction_id"`
+}
+
+// PostCharactersAffiliation returns the current corporation and alliance of
+// each character via POST /characters/affiliation/. IDs are sent in batches of
+// affiliationBatchSize. This is a public endpoint (no auth required). Returns
+// an empty slice for an empty input.
+func (c *httpClient) PostCharactersAffiliation(ctx context.Context, characterIDs []int64) ([]CharacterAffiliation, error) {
+	var result []CharacterAffiliation
+	url := fmt.Sprintf("%s/characters/affiliation/", c.baseURL)
+	for start := 0; start < len(characterIDs); start += affiliationBatchSize {
+		batch := characterIDs[start:min(start+affiliationBatchSize, len(characterIDs))]
+
+		reqBody, err := json.Marshal(batch)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling character ids: %w", err)
+		}
+		body, err := c.doPost(ctx, url, "", reqBody)
+		if err != nil {
+			return nil, fmt.Errorf("posting characters/affiliation: %w", err)
+		}
+
+		var entries []CharacterAffiliation
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return nil, fmt.Errorf("parsing characters/affiliation response: %w", err)
+		}
+		result = append(result, entries...)
+	}
+	return result, nil
+}
+
+// GetCharacterRoles fetches the corporation roles the character holds in its
+// corporation via GET /characters/{id}/roles/. Requires the
+// esi-characters.read_corporation_roles.v1 scope.
+func (c *httpClient) GetCharacterRoles(ctx context.Context, characterID int64, token string) ([]string, time.Time, error) {
+	url := fmt.Sprintf("%s/characters/%d/roles/", c.baseURL, characterID)
+	body, cacheUntil, err := c.do(ctx, url, token)
+	if err != nil {
+		return nil, cacheUntil, fmt.Errorf("fetching roles for character %d: %w", characterID, err)
+	}
+
+	var resp characterRolesResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, time.Time{}, fmt.Errorf("parsing roles for character %d: %w", characterID, err)
+	}
+	return resp.Roles, cacheUntil, nil
+}
+
+// IsNPCCorporation reports whether the given EVE corporation ID belongs to an
+// NPC corporation. NPC corp IDs occupy the range 1000000–2000000 inclusive.
+// Player corps fall outside this range and must be tracked in the corporations table.
+func IsNPCCorporation(corpID int64) bool {
+	return corpID >= 1_000_000 && corpID <= 2_000_000
+}
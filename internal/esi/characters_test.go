@@ -0,0 +1,141 @@
+package esi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// --- PostCharactersAffiliation ---
+
+func TestPostCharactersAffiliation_ParsesResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/characters/affiliation/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`[
+			{"character_id":90000001,"corporation_id":98000001,"alliance_id":99000001},
+			{"character_id":90000002,"corporation_id":1000182,"faction_id":500001}
+		]`))
+	}))
+	defer srv.Close()
+
+	c := newTestClient(srv)
+	got, err := c.PostCharactersAffiliation(context.Background(), []int64{90000001, 90000002})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []CharacterAffiliation{
+		{CharacterID: 90000001, CorporationID: 98000001, AllianceID: 99000001},
+		{CharacterID: 90000002, CorporationID: 1000182, FactionID: 500001},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestPostCharactersAffiliation_Batches verifies that more IDs than ESI accepts
+// in one request are split across requests and the results concatenated.
+func TestPostCharactersAffiliation_Batches(t *testing.T) {
+	var batchSizes []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ids []int64
+		if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batchSizes = append(batchSizes, len(ids))
+		entries := make([]string, len(ids))
+		for i, id := range ids {
+			entries[i] = fmt.Sprintf(`{"character_id":%d,"corporation_id":98000001}`, id)
+		}
+		_, _ = w.Write([]byte("[" + strings.Join(entries, ",") + "]"))
+	}))
+	defer srv.Close()
+
+	ids := make([]int64, affiliationBatchSize+1)
+	for i := range ids {
+		ids[i] = int64(90000000 + i)
+	}
+
+	c := newTestClient(srv)
+	got, err := c.PostCharactersAffiliation(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(batchSizes) != 2 || batchSizes[0] != affiliationBatchSize || batchSizes[1] != 1 {
+		t.Errorf("batch sizes = %v, want [%d 1]", batchSizes, affiliationBatchSize)
+	}
+	if len(got) != len(ids) {
+		t.Errorf("got %d entries, want %d", len(got), len(ids))
+	}
+}
+
+func TestPostCharactersAffiliation_EmptyInput_NoRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected HTTP call for empty input")
+	}))
+	defer srv.Close()
+
+	got, err := newTestClient(srv).PostCharactersAffiliation(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no entries, got %v", got)
+	}
+}
+
+// --- GetCharacterRoles ---
+
+func TestGetCharacterRoles_ReturnsGlobalRoles(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/characters/42/roles/" || r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Expires", "Sat, 01 Jan 2050 00:00:00 GMT")
+		_, _ = w.Write([]byte(`{
+			"roles": ["Director", "Factory_Manager"],
+			"roles_at_hq": ["Hangar_Take_1"],
+			"roles_at_base": [],
+			"roles_at_other": []
+		}`))
+	}))
+	defer srv.Close()
+
+	roles, cacheUntil, err := newTestClient(srv).GetCharacterRoles(context.Background(), 42, "tok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(roles) != 2 || roles[0] != "Director" || roles[1] != "Factory_Manager" {
+		t.Errorf("roles = %v, want [Director Factory_Manager]", roles)
+	}
+	if cacheUntil.Year() != 2050 {
+		t.Errorf("cacheUntil = %v, want the Expires header", cacheUntil)
+	}
+}
+
+func TestGetCharacterRoles_Forbidden(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error":"token not valid for scope"}`))
+	}))
+	defer srv.Close()
+
+	_, _, err := newTestClient(srv).GetCharacterRoles(context.Background(), 42, "tok")
+	if !IsForbidden(err) {
+		t.Errorf("error = %v, want IsForbidden", err)
+	}
+}
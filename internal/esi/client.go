@@ -39,12 +39,20 @@ type Client interface {
 	GetCharacterBlueprints(ctx context.Context, characterID int64, token string) ([]Blueprint, time.Time, error)
 	GetCorporationBlueprints(ctx context.Context, corporationID int64, token string) ([]Blueprint, time.Time, error)
 	GetCharacterJobs(ctx context.Context, characterID int64, token string) ([]Job, time.Time, error)
+	GetCharacterRoles(ctx context.Context, characterID int64, token string) ([]string, time.Time, error)
 	GetCorporationJobs(ctx context.Context, corporationID int64, token string) ([]Job, time.Time, error)
-	GetCorporationAssets(ctx context.Context, corpID int64, token string, page int) ([]CorpAsset, int, time.Time, error)
-	GetStation(ctx context.Context, stationID int64) (string, error)
+	GetCharacterAssets(ctx context.Context, characterID int64, token string, page int) ([]Asset, int, time.Time, error)
+	GetCorporationAssets(ctx context.Context, corpID int64, token string, page int) ([]Asset, int, time.Time, error)
+	GetCorporationDivisions(ctx context.Context, corpID int64, token string) ([]Division, time.Time, error)
+	GetStation(ctx context.Context, stationID int64) (UniverseStation, error)
+	GetUniverseCategory(ctx context.Context, categoryID int64) (UniverseCategory, error)
+	GetUniverseGroup(ctx context.Context, groupID int64) (UniverseGroup, error)
 	GetUniverseStructure(ctx context.Context, structureID int64, token string) (UniverseStructure, error)
-	GetUniverseSystem(ctx context.Context, systemID int64) (string, error)
+	GetUniverseSystem(ctx context.Context, systemID int64) (UniverseSystem, error)
 	GetUniverseType(ctx context.Context, typeID int64) (UniverseType, error)
+	PostCharacterAssetNames(ctx context.Context, characterID int64, token string, itemIDs []int64) ([]AssetName, error)
+	PostCharactersAffiliation(ctx context.Context, characterIDs []int64) ([]CharacterAffiliation, error)
+	PostCorporationAssetNames(ctx context.Context, corpID int64, token string, itemIDs []int64) ([]AssetName, error)
 	PostUniverseNames(ctx context.Context, ids []int64) ([]UniverseNamesEntry, error)
 }
 
@@ -148,10 +156,10 @@ func parseExpires(s string) time.Time {
 	return t
 }
 
-// doPost executes a POST request to url with a JSON body (no auth token).
-// It applies the same retry logic as do(): retries on 429 and 5xx.
-// Returns the raw response body.
-func (c *httpClient) doPost(ctx context.Context, url string, body []byte) ([]byte, error) {
+// doPost executes a POST request to url with a JSON body and, if token is
+// non-empty, a Bearer token. It applies the same retry logic as do(): retries
+// on 429 and 5xx. Returns the raw response body.
+func (c *httpClient) doPost(ctx context.Context, url, token string, body []byte) ([]byte, error) {
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 		if err != nil {
@@ -159,6 +167,9 @@ func (c *httpClient) doPost(ctx context.Context, url string, body []byte) ([]byt
 		}
 		req.Header.Set("Accept", "application/json")
 		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
 
 		resp, err := c.http.Do(req) //nolint:gosec // url is always constructed from a hardcoded base URL within this package
 		if err != nil {
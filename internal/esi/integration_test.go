@@ -104,10 +104,10 @@ func TestIntegration_GetUniverseType(t *testing.T) {
 	if err != nil {
 		t.Fatalf("GetUniverseType: %v", err)
 	}
-	if ut.TypeName == "" {
-		t.Error("GetUniverseType: TypeName is empty")
+	if ut.Name == "" {
+		t.Error("GetUniverseType: Name is empty")
 	}
-	if ut.CategoryID == 0 {
-		t.Error("GetUniverseType: CategoryID is 0")
+	if ut.GroupID == 0 {
+		t.Error("GetUniverseType: GroupID is 0")
 	}
 }
@@ -0,0 +1,18 @@
+package esi
+
+// scopes.go: EVE SSO scopes required by the authenticated ESI endpoints.
+
+// OAuth2 scopes granted through EVE SSO. Each authenticated endpoint used by
+// Auspex requires exactly one of them.
+const (
+	ScopeCharacterAssets       = "esi-assets.read_assets.v1"
+	ScopeCharacterBlueprints   = "esi-characters.read_blueprints.v1"
+	ScopeCharacterJobs         = "esi-industry.read_character_jobs.v1"
+	ScopeCharacterRoles        = "esi-characters.read_corporation_roles.v1"
+	ScopeCorporationAssets     = "esi-assets.read_corporation_assets.v1"
+	ScopeCorporationBlueprints = "esi-corporations.read_blueprints.v1"
+	ScopeCorporationDivisions  = "esi-corporations.read_divisions.v1"
+	ScopeCorporationFacilities = "esi-corporations.read_facilities.v1"
+	ScopeCorporationJobs       = "esi-industry.read_corporation_jobs.v1"
+	ScopeStructures            = "esi-universe.read_structures.v1"
+)
@@ -15,89 +15,84 @@ var _ Client = (*httpClient)(nil)
 // character does not have access to the structure (ESI 403).
 var ErrForbidden = errors.New("ESI: 403 Forbidden")
 
+// IsForbidden reports whether err is an ESI 403 response: ErrForbidden, or the
+// status error of any other authenticated endpoint, e.g. a corporation endpoint
+// called with the token of a character lacking the required role.
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrForbidden) || (err != nil && strings.Contains(err.Error(), "ESI status 403"))
+}
+
 // ErrNotFound is returned by GetUniverseStructure when ESI returns 404,
 // indicating the ID is not a player structure (e.g. a corp office item ID).
 var ErrNotFound = errors.New("ESI: 404 Not Found")
 
-// UniverseType holds fully resolved EVE type data including group and category.
-// It is returned by GetUniverseType, which internally chains three ESI calls:
-//
-//	GET /universe/types/{type_id}       → TypeName, GroupID
-//	GET /universe/groups/{group_id}     → GroupName, CategoryID
-//	GET /universe/categories/{cat_id}  → CategoryName
-type UniverseType struct {
-	TypeID       int64
-	TypeName     string
-	GroupID      int64
-	GroupName    string
-	CategoryID   int64
-	CategoryName string
-}
+// universeNamesBatchSize is the maximum number of IDs ESI accepts in a single
+// POST /universe/names/ request.
+const universeNamesBatchSize = 1000
 
-// esiTypeResponse is the raw JSON from GET /universe/types/{type_id}.
-type esiTypeResponse struct {
+// UniverseType holds the fields we need from GET /universe/types/{type_id}.
+type UniverseType struct {
 	TypeID  int64  `json:"type_id"`
 	Name    string `json:"name"`
 	GroupID int64  `json:"group_id"`
 }
 
-// esiGroupResponse is the raw JSON from GET /universe/groups/{group_id}.
-type esiGroupResponse struct {
-	GroupID    int64  `json:"group_id"`
-	Name       string `json:"name"`
-	CategoryID int64  `json:"category_id"`
+// UniverseGroup holds the fields we need from GET /universe/groups/{group_id}.
+// TypeIDs lists every published type in the group.
+type UniverseGroup struct {
+	GroupID    int64   `json:"group_id"`
+	Name       string  `json:"name"`
+	CategoryID int64   `json:"category_id"`
+	TypeIDs    []int64 `json:"types"`
 }
 
-// esiCategoryResponse is the raw JSON from GET /universe/categories/{category_id}.
-type esiCategoryResponse struct {
+// UniverseCategory holds the fields we need from GET /universe/categories/{category_id}.
+type UniverseCategory struct {
 	CategoryID int64  `json:"category_id"`
 	Name       string `json:"name"`
 }
 
-// GetUniverseType resolves a type_id into a fully populated UniverseType by
-// chaining three ESI calls. Universe endpoints are public — no token is sent.
+// GetUniverseType fetches a type's name and group. Public endpoint, no token required.
 func (c *httpClient) GetUniverseType(ctx context.Context, typeID int64) (UniverseType, error) {
-	// Step 1: fetch type.
-	typeURL := fmt.Sprintf("%s/universe/types/%d", c.baseURL, typeID)
-	body, _, err := c.do(ctx, typeURL, "")
+	url := fmt.Sprintf("%s/universe/types/%d", c.baseURL, typeID)
+	body, _, err := c.do(ctx, url, "")
 	if err != nil {
 		return UniverseType{}, fmt.Errorf("fetching type %d: %w", typeID, err)
 	}
-	var typeResp esiTypeResponse
-	if err := json.Unmarshal(body, &typeResp); err != nil {
+	var t UniverseType
+	if err := json.Unmarshal(body, &t); err != nil {
 		return UniverseType{}, fmt.Errorf("parsing type %d response: %w", typeID, err)
 	}
+	return t, nil
+}
 
-	// Step 2: fetch group.
-	groupURL := fmt.Sprintf("%s/universe/groups/%d", c.baseURL, typeResp.GroupID)
-	body, _, err = c.do(ctx, groupURL, "")
+// GetUniverseGroup fetches a group's name, category, and types. Public endpoint,
+// no token required.
+func (c *httpClient) GetUniverseGroup(ctx context.Context, groupID int64) (UniverseGroup, error) {
+	url := fmt.Sprintf("%s/universe/groups/%d", c.baseURL, groupID)
+	body, _, err := c.do(ctx, url, "")
 	if err != nil {
-		return UniverseType{}, fmt.Errorf("fetching group %d: %w", typeResp.GroupID, err)
+		return UniverseGroup{}, fmt.Errorf("fetching group %d: %w", groupID, err)
 	}
-	var groupResp esiGroupResponse
-	if err := json.Unmarshal(body, &groupResp); err != nil {
-		return UniverseType{}, fmt.Errorf("parsing group %d response: %w", typeResp.GroupID, err)
+	var g UniverseGroup
+	if err := json.Unmarshal(body, &g); err != nil {
+		return UniverseGroup{}, fmt.Errorf("parsing group %d response: %w", groupID, err)
 	}
+	return g, nil
+}
 
-	// Step 3: fetch category.
-	catURL := fmt.Sprintf("%s/universe/categories/%d", c.baseURL, groupResp.CategoryID)
-	body, _, err = c.do(ctx, catURL, "")
+// GetUniverseCategory fetches a category's name. Public endpoint, no token required.
+func (c *httpClient) GetUniverseCategory(ctx context.Context, categoryID int64) (UniverseCategory, error) {
+	url := fmt.Sprintf("%s/universe/categories/%d", c.baseURL, categoryID)
+	body, _, err := c.do(ctx, url, "")
 	if err != nil {
-		return UniverseType{}, fmt.Errorf("fetching category %d: %w", groupResp.CategoryID, err)
+		return UniverseCategory{}, fmt.Errorf("fetching category %d: %w", categoryID, err)
 	}
-	var catResp esiCategoryResponse
-	if err := json.Unmarshal(body, &catResp); err != nil {
-		return UniverseType{}, fmt.Errorf("parsing category %d response: %w", groupResp.CategoryID, err)
+	var cat UniverseCategory
+	if err := json.Unmarshal(body, &cat); err != nil {
+		return UniverseCategory{}, fmt.Errorf("parsing category %d response: %w", categoryID, err)
 	}
-
-	return UniverseType{
-		TypeID:       typeID,
-		TypeName:     typeResp.Name,
-		GroupID:      typeResp.GroupID,
-		GroupName:    groupResp.Name,
-		CategoryID:   groupResp.CategoryID,
-		CategoryName: catResp.Name,
-	}, nil
+	return cat, nil
 }
 
 // UniverseNamesEntry is one item returned by POST /universe/names/.
@@ -107,20 +102,49 @@ type UniverseNamesEntry struct {
 	Category string `json:"category"`
 }
 
-// PostUniverseNames resolves a batch of EVE IDs to names via POST /universe/names/.
-// This is a public endpoint (no auth required). Returns an empty slice for an empty input.
+// PostUniverseNames resolves EVE IDs to names via POST /universe/names/, in
+// batches of universeNamesBatchSize. This is a public endpoint (no auth
+// required). Returns an empty slice for an empty input.
+//
+// ESI rejects a whole batch with 404 if any ID in it is invalid. Such a batch
+// is split in halves until the invalid IDs are isolated; they are left out of
+// the result, so one bad ID does not cost the names of the others.
 func (c *httpClient) PostUniverseNames(ctx context.Context, ids []int64) ([]UniverseNamesEntry, error) {
-	if len(ids) == 0 {
-		return nil, nil
+	var result []UniverseNamesEntry
+	for start := 0; start < len(ids); start += universeNamesBatchSize {
+		entries, err := c.postUniverseNamesBatch(ctx, ids[start:min(start+universeNamesBatchSize, len(ids))])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entries...)
 	}
+	return result, nil
+}
 
+// postUniverseNamesBatch posts one batch of IDs, bisecting it on a 404.
+func (c *httpClient) postUniverseNamesBatch(ctx context.Context, ids []int64) ([]UniverseNamesEntry, error) {
 	reqBody, err := json.Marshal(ids)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling ids: %w", err)
 	}
 
 	url := fmt.Sprintf("%s/universe/names/", c.baseURL)
-	body, err := c.doPost(ctx, url, reqBody)
+	body, err := c.doPost(ctx, url, "", reqBody)
+	if err != nil && strings.Contains(err.Error(), "ESI status 404") {
+		if len(ids) == 1 {
+			return nil, nil // invalid ID: left out
+		}
+		half := len(ids) / 2
+		first, err := c.postUniverseNamesBatch(ctx, ids[:half])
+		if err != nil {
+			return nil, err
+		}
+		second, err := c.postUniverseNamesBatch(ctx, ids[half:])
+		if err != nil {
+			return nil, err
+		}
+		return append(first, second...), nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("posting universe/names: %w", err)
 	}
@@ -160,42 +184,104 @@ func (c *httpClient) GetUniverseStructure(ctx context.Context, structureID int64
 	return s, nil
 }
 
+// UniverseSystem holds a solar system with its constellation and region.
+// It is returned by GetUniverseSystem, which internally chains three ESI calls:
+//
+//	GET /universe/systems/{system_id}/               → Name, SecurityStatus, ConstellationID
+//	GET /universe/constellations/{constellation_id}/ → ConstellationName, RegionID
+//	GET /universe/regions/{region_id}/               → RegionName
+type UniverseSystem struct {
+	SystemID          int64
+	Name              string
+	SecurityStatus    float64
+	ConstellationID   int64
+	ConstellationName string
+	RegionID          int64
+	RegionName        string
+}
+
 // esiSystemResponse is the minimal subset of GET /universe/systems/{id}/ we need.
 type esiSystemResponse struct {
+	Name            string  `json:"name"`
+	SecurityStatus  float64 `json:"security_status"`
+	ConstellationID int64   `json:"constellation_id"`
+}
+
+// esiConstellationResponse is the minimal subset of GET /universe/constellations/{id}/ we need.
+type esiConstellationResponse struct {
+	Name     string `json:"name"`
+	RegionID int64  `json:"region_id"`
+}
+
+// esiRegionResponse is the minimal subset of GET /universe/regions/{id}/ we need.
+type esiRegionResponse struct {
 	Name string `json:"name"`
 }
 
-// GetUniverseSystem fetches the name of a solar system. Public endpoint, no token required.
-func (c *httpClient) GetUniverseSystem(ctx context.Context, systemID int64) (string, error) {
+// GetUniverseSystem resolves a solar system with its constellation and region
+// by chaining three ESI calls. Public endpoints, no token required.
+func (c *httpClient) GetUniverseSystem(ctx context.Context, systemID int64) (UniverseSystem, error) {
+	// Step 1: fetch system.
 	url := fmt.Sprintf("%s/universe/systems/%d/", c.baseURL, systemID)
 	body, _, err := c.do(ctx, url, "")
 	if err != nil {
-		return "", fmt.Errorf("fetching system %d: %w", systemID, err)
+		return UniverseSystem{}, fmt.Errorf("fetching system %d: %w", systemID, err)
+	}
+	var sys esiSystemResponse
+	if err := json.Unmarshal(body, &sys); err != nil {
+		return UniverseSystem{}, fmt.Errorf("parsing system %d response: %w", systemID, err)
 	}
 
-	var s esiSystemResponse
-	if err := json.Unmarshal(body, &s); err != nil {
-		return "", fmt.Errorf("parsing system %d response: %w", systemID, err)
+	// Step 2: fetch constellation.
+	url = fmt.Sprintf("%s/universe/constellations/%d/", c.baseURL, sys.ConstellationID)
+	body, _, err = c.do(ctx, url, "")
+	if err != nil {
+		return UniverseSystem{}, fmt.Errorf("fetching constellation %d: %w", sys.ConstellationID, err)
 	}
-	return s.Name, nil
+	var con esiConstellationResponse
+	if err := json.Unmarshal(body, &con); err != nil {
+		return UniverseSystem{}, fmt.Errorf("parsing constellation %d response: %w", sys.ConstellationID, err)
+	}
+
+	// Step 3: fetch region.
+	url = fmt.Sprintf("%s/universe/regions/%d/", c.baseURL, con.RegionID)
+	body, _, err = c.do(ctx, url, "")
+	if err != nil {
+		return UniverseSystem{}, fmt.Errorf("fetching region %d: %w", con.RegionID, err)
+	}
+	var reg esiRegionResponse
+	if err := json.Unmarshal(body, &reg); err != nil {
+		return UniverseSystem{}, fmt.Errorf("parsing region %d response: %w", con.RegionID, err)
+	}
+
+	return UniverseSystem{
+		SystemID:          systemID,
+		Name:              sys.Name,
+		SecurityStatus:    sys.SecurityStatus,
+		ConstellationID:   sys.ConstellationID,
+		ConstellationName: con.Name,
+		RegionID:          con.RegionID,
+		RegionName:        reg.Name,
+	}, nil
 }
 
-// esiStationResponse is the minimal subset of GET /universe/stations/{id}/ we need.
-type esiStationResponse struct {
-	Name string `json:"name"`
+// UniverseStation holds the fields we need from GET /universe/stations/{id}/.
+type UniverseStation struct {
+	Name     string `json:"name"`
+	SystemID int64  `json:"system_id"`
 }
 
-// GetStation fetches the name of an NPC station. Public endpoint, no token required.
-func (c *httpClient) GetStation(ctx context.Context, stationID int64) (string, error) {
+// GetStation fetches an NPC station and the solar system it is in. Public endpoint, no token required.
+func (c *httpClient) GetStation(ctx context.Context, stationID int64) (UniverseStation, error) {
 	url := fmt.Sprintf("%s/universe/stations/%d/", c.baseURL, stationID)
 	body, _, err := c.do(ctx, url, "")
 	if err != nil {
-		return "", fmt.Errorf("fetching station %d: %w", stationID, err)
+		return UniverseStation{}, fmt.Errorf("fetching station %d: %w", stationID, err)
 	}
 
-	var s esiStationResponse
+	var s UniverseStation
 	if err := json.Unmarshal(body, &s); err != nil {
-		return "", fmt.Errorf("parsing station %d response: %w", stationID, err)
+		return UniverseStation{}, fmt.Errorf("parsing station %d response: %w", stationID, err)
 	}
-	return s.Name, nil
+	return s, nil
 }
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
 	"testing"
 )
 
@@ -22,7 +23,7 @@ func serveUniverse(t *testing.T) http.Handler {
 			_, _ = w.Write([]byte(`{"type_id":34,"name":"Tritanium","group_id":18}`))
 		case "/universe/groups/18":
 			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte(`{"group_id":18,"name":"Mineral","category_id":4}`))
+			_, _ = w.Write([]byte(`{"group_id":18,"name":"Mineral","category_id":4,"types":[34,35,36]}`))
 		case "/universe/categories/4":
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte(`{"category_id":4,"name":"Material"}`))
@@ -33,7 +34,7 @@ func serveUniverse(t *testing.T) http.Handler {
 	})
 }
 
-func TestGetUniverseType_ParsesChainedCalls(t *testing.T) {
+func TestGetUniverseType_ParsesResponse(t *testing.T) {
 	srv := httptest.NewServer(serveUniverse(t))
 	defer srv.Close()
 
@@ -45,107 +46,77 @@ func TestGetUniverseType_ParsesChainedCalls(t *testing.T) {
 	if got.TypeID != 34 {
 		t.Errorf("TypeID: got %d, want 34", got.TypeID)
 	}
-	if got.TypeName != "Tritanium" {
-		t.Errorf("TypeName: got %q, want Tritanium", got.TypeName)
+	if got.Name != "Tritanium" {
+		t.Errorf("Name: got %q, want Tritanium", got.Name)
 	}
 	if got.GroupID != 18 {
 		t.Errorf("GroupID: got %d, want 18", got.GroupID)
 	}
-	if got.GroupName != "Mineral" {
-		t.Errorf("GroupName: got %q, want Mineral", got.GroupName)
-	}
-	if got.CategoryID != 4 {
-		t.Errorf("CategoryID: got %d, want 4", got.CategoryID)
-	}
-	if got.CategoryName != "Material" {
-		t.Errorf("CategoryName: got %q, want Material", got.CategoryName)
-	}
 }
 
-func TestGetUniverseType_NoTokenSent(t *testing.T) {
-	var gotAuth string
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		gotAuth = r.Header.Get("Authorization")
-		switch r.URL.Path {
-		case "/universe/types/34":
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte(`{"type_id":34,"name":"Tritanium","group_id":18}`))
-		case "/universe/groups/18":
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte(`{"group_id":18,"name":"Mineral","category_id":4}`))
-		case "/universe/categories/4":
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte(`{"category_id":4,"name":"Material"}`))
-		}
-	}))
+func TestGetUniverseGroup_ParsesResponse(t *testing.T) {
+	srv := httptest.NewServer(serveUniverse(t))
 	defer srv.Close()
 
 	c := newTestClient(srv)
-	_, err := c.GetUniverseType(context.Background(), 34)
+	got, err := c.GetUniverseGroup(context.Background(), 18)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if gotAuth != "" {
-		t.Errorf("expected no Authorization header for public endpoint, got %q", gotAuth)
+	if got.GroupID != 18 || got.Name != "Mineral" || got.CategoryID != 4 {
+		t.Errorf("got %+v, want group 18 Mineral in category 4", got)
+	}
+	if !slices.Equal(got.TypeIDs, []int64{34, 35, 36}) {
+		t.Errorf("TypeIDs: got %v, want [34 35 36]", got.TypeIDs)
 	}
 }
 
-func TestGetUniverseType_TypeFetchError(t *testing.T) {
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNotFound)
-		_, _ = w.Write([]byte(`{"error":"type not found"}`))
-	}))
+func TestGetUniverseCategory_ParsesResponse(t *testing.T) {
+	srv := httptest.NewServer(serveUniverse(t))
 	defer srv.Close()
 
 	c := newTestClient(srv)
-	_, err := c.GetUniverseType(context.Background(), 9999)
-	if err == nil {
-		t.Fatal("expected error when type fetch returns 404, got nil")
+	got, err := c.GetUniverseCategory(context.Background(), 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.CategoryID != 4 || got.Name != "Material" {
+		t.Errorf("got %+v, want category 4 Material", got)
 	}
 }
 
-func TestGetUniverseType_GroupFetchError(t *testing.T) {
+func TestGetUniverseType_NoTokenSent(t *testing.T) {
+	var gotAuth string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.URL.Path {
-		case "/universe/types/34":
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte(`{"type_id":34,"name":"Tritanium","group_id":18}`))
-		default:
-			// group and category endpoints fail
-			w.WriteHeader(http.StatusNotFound)
-			_, _ = w.Write([]byte(`{"error":"not found"}`))
-		}
+		gotAuth = r.Header.Get("Authorization")
+		serveUniverse(t).ServeHTTP(w, r)
 	}))
 	defer srv.Close()
 
 	c := newTestClient(srv)
 	_, err := c.GetUniverseType(context.Background(), 34)
-	if err == nil {
-		t.Fatal("expected error when group fetch fails, got nil")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotAuth != "" {
+		t.Errorf("expected no Authorization header for public endpoint, got %q", gotAuth)
 	}
 }
 
-func TestGetUniverseType_CategoryFetchError(t *testing.T) {
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.URL.Path {
-		case "/universe/types/34":
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte(`{"type_id":34,"name":"Tritanium","group_id":18}`))
-		case "/universe/groups/18":
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte(`{"group_id":18,"name":"Mineral","category_id":4}`))
-		default:
-			// category endpoint fails
-			w.WriteHeader(http.StatusNotFound)
-			_, _ = w.Write([]byte(`{"error":"not found"}`))
-		}
-	}))
+func TestGetUniverseType_FetchErrors(t *testing.T) {
+	srv := httptest.NewServer(serveUniverse(t))
 	defer srv.Close()
 
 	c := newTestClient(srv)
-	_, err := c.GetUniverseType(context.Background(), 34)
-	if err == nil {
-		t.Fatal("expected error when category fetch fails, got nil")
+	ctx := context.Background()
+	if _, err := c.GetUniverseType(ctx, 9999); err == nil {
+		t.Error("GetUniverseType: expected error for 404, got nil")
+	}
+	if _, err := c.GetUniverseGroup(ctx, 9999); err == nil {
+		t.Error("GetUniverseGroup: expected error for 404, got nil")
+	}
+	if _, err := c.GetUniverseCategory(ctx, 9999); err == nil {
+		t.Error("GetUniverseCategory: expected error for 404, got nil")
 	}
 }
 
@@ -183,27 +154,27 @@ func TestGetUniverseType_FullESIResponse(t *testing.T) {
 	defer srv.Close()
 
 	c := newTestClient(srv)
-	got, err := c.GetUniverseType(context.Background(), 34)
+	ctx := context.Background()
+	typ, err := c.GetUniverseType(ctx, 34)
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-	if got.TypeID != 34 {
-		t.Errorf("TypeID: got %d, want 34", got.TypeID)
+		t.Fatalf("GetUniverseType: %v", err)
 	}
-	if got.TypeName != "Tritanium" {
-		t.Errorf("TypeName: got %q, want Tritanium", got.TypeName)
+	if typ.TypeID != 34 || typ.Name != "Tritanium" || typ.GroupID != 18 {
+		t.Errorf("type: got %+v, want 34 Tritanium in group 18", typ)
 	}
-	if got.GroupID != 18 {
-		t.Errorf("GroupID: got %d, want 18", got.GroupID)
+	group, err := c.GetUniverseGroup(ctx, typ.GroupID)
+	if err != nil {
+		t.Fatalf("GetUniverseGroup: %v", err)
 	}
-	if got.GroupName != "Mineral" {
-		t.Errorf("GroupName: got %q, want Mineral", got.GroupName)
+	if group.Name != "Mineral" || group.CategoryID != 4 || !slices.Contains(group.TypeIDs, 34) {
+		t.Errorf("group: got %+v, want Mineral in category 4 containing type 34", group)
 	}
-	if got.CategoryID != 4 {
-		t.Errorf("CategoryID: got %d, want 4", got.CategoryID)
+	category, err := c.GetUniverseCategory(ctx, group.CategoryID)
+	if err != nil {
+		t.Fatalf("GetUniverseCategory: %v", err)
 	}
-	if got.CategoryName != "Material" {
-		t.Errorf("CategoryName: got %q, want Material", got.CategoryName)
+	if category.Name != "Material" {
+		t.Errorf("category name: got %q, want Material", category.Name)
 	}
 }
 
@@ -278,6 +249,70 @@ func TestPostUniverseNames_EmptyInput_ReturnsNil(t *testing.T) {
 	}
 }
 
+func TestPostUniverseNames_BatchesOf1000(t *testing.T) {
+	var batches [][]int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ids []int64
+		_ = json.NewDecoder(r.Body).Decode(&ids)
+		batches = append(batches, ids)
+		entries := make([]UniverseNamesEntry, len(ids))
+		for i, id := range ids {
+			entries[i] = UniverseNamesEntry{ID: id, Name: "x", Category: "inventory_type"}
+		}
+		_ = json.NewEncoder(w).Encode(entries)
+	}))
+	defer srv.Close()
+
+	ids := make([]int64, 2500)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	c := newTestClient(srv)
+	entries, err := c.PostUniverseNames(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2500 {
+		t.Errorf("expected 2500 entries, got %d", len(entries))
+	}
+	if len(batches) != 3 || len(batches[0]) != 1000 || len(batches[1]) != 1000 || len(batches[2]) != 500 {
+		t.Errorf("unexpected batch sizes: %d batches", len(batches))
+	}
+}
+
+func TestPostUniverseNames_InvalidIDIsolated(t *testing.T) {
+	const invalidID = 3
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ids []int64
+		_ = json.NewDecoder(r.Body).Decode(&ids)
+		if slices.Contains(ids, invalidID) {
+			// ESI rejects the whole batch.
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"Ensure all IDs are valid before resolving."}`))
+			return
+		}
+		entries := make([]UniverseNamesEntry, len(ids))
+		for i, id := range ids {
+			entries[i] = UniverseNamesEntry{ID: id, Name: "x"}
+		}
+		_ = json.NewEncoder(w).Encode(entries)
+	}))
+	defer srv.Close()
+
+	c := newTestClient(srv)
+	entries, err := c.PostUniverseNames(context.Background(), []int64{1, 2, 3, 4, 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []int64
+	for _, e := range entries {
+		got = append(got, e.ID)
+	}
+	if !slices.Equal(got, []int64{1, 2, 4, 5}) {
+		t.Errorf("resolved IDs: got %v, want [1 2 4 5]", got)
+	}
+}
+
 func TestPostUniverseNames_HTTPError(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
@@ -384,33 +419,68 @@ func TestGetUniverseStructure_SendsAuthToken(t *testing.T) {
 
 // --- GetUniverseSystem ---
 
-func TestGetUniverseSystem_ReturnsName(t *testing.T) {
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/universe/systems/30000142/" {
+// serveSystem serves Jita with its constellation and region.
+func serveSystem() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/universe/systems/30000142/":
+			_, _ = w.Write([]byte(`{"name":"Jita","system_id":30000142,"security_status":0.9459,"constellation_id":20000020}`))
+		case "/universe/constellations/20000020/":
+			_, _ = w.Write([]byte(`{"name":"Kimotoro","constellation_id":20000020,"region_id":10000002}`))
+		case "/universe/regions/10000002/":
+			_, _ = w.Write([]byte(`{"name":"The Forge","region_id":10000002}`))
+		default:
 			w.WriteHeader(http.StatusNotFound)
-			return
 		}
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(`{"name":"Jita","system_id":30000142}`))
-	}))
+	}
+}
+
+func TestGetUniverseSystem_ReturnsSystemConstellationAndRegion(t *testing.T) {
+	srv := httptest.NewServer(serveSystem())
 	defer srv.Close()
 
 	c := newTestClient(srv)
-	name, err := c.GetUniverseSystem(context.Background(), 30000142)
+	sys, err := c.GetUniverseSystem(context.Background(), 30000142)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if name != "Jita" {
-		t.Errorf("name: got %q, want Jita", name)
+	want := UniverseSystem{
+		SystemID:          30000142,
+		Name:              "Jita",
+		SecurityStatus:    0.9459,
+		ConstellationID:   20000020,
+		ConstellationName: "Kimotoro",
+		RegionID:          10000002,
+		RegionName:        "The Forge",
+	}
+	if sys != want {
+		t.Errorf("system: got %+v, want %+v", sys, want)
+	}
+}
+
+func TestGetUniverseSystem_RegionError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/universe/regions/10000002/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		serveSystem()(w, r)
+	}))
+	defer srv.Close()
+
+	c := newTestClient(srv)
+	if _, err := c.GetUniverseSystem(context.Background(), 30000142); err == nil {
+		t.Fatal("expected error when the region cannot be fetched, got nil")
 	}
 }
 
 func TestGetUniverseSystem_NoTokenSent(t *testing.T) {
 	var gotAuth string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		gotAuth = r.Header.Get("Authorization")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(`{"name":"Jita"}`))
+		if v := r.Header.Get("Authorization"); v != "" {
+			gotAuth = v
+		}
+		serveSystem()(w, r)
 	}))
 	defer srv.Close()
 
@@ -0,0 +1,307 @@
+// Package events is the in-process event bus between the sync worker and its
+// listeners (the /api/events stream, notifications).
+//
+// Publishers call Bus.Publish with one of the event types below and its data
+// struct. Every event gets an increasing ID; the last events are kept in a
+// small ring buffer so that a subscriber that reconnects with the ID of the
+// last event it saw can be sent the ones it missed. In-process listeners use
+// Follow, which does that for them.
+package events
+
+import (
+	"context"
+	stdsync "sync"
+	"time"
+)
+
+// Event types published by the sync worker, and by the alert engine for
+// alert_fired.
+const (
+	TypeCycleStarted     = "cycle_started"     // data: Cycle
+	TypeCycleFinished    = "cycle_finished"    // data: Cycle
+	TypeSubjectSynced    = "subject_synced"    // data: Subject
+	TypeSubjectFailed    = "subject_failed"    // data: Subject
+	TypeJobReady         = "job_ready"         // data: Job
+	TypeBlueprintAdded   = "blueprint_added"   // data: Blueprint
+	TypeBlueprintRemoved = "blueprint_removed" // data: Blueprint
+	TypeAlertFired       = "alert_fired"       // data: Alert
+)
+
+// Types lists every event type.
+var Types = []string{
+	TypeCycleStarted, TypeSubjectSynced, TypeSubjectFailed, TypeJobReady,
+	TypeBlueprintAdded, TypeBlueprintRemoved, TypeCycleFinished, TypeAlertFired,
+}
+
+// DefaultHistorySize is the number of past events a Bus keeps for replay.
+const DefaultHistorySize = 256
+
+// subscriberBuffer is the number of events a subscriber may fall behind by
+// before it is dropped.
+const subscriberBuffer = 64
+
+// Event is one published event.
+type Event struct {
+	ID   uint64
+	Type string
+	Time time.Time
+	Data any
+}
+
+// Cycle is the data of cycle_started and cycle_finished. Duration is set on
+// cycle_finished only.
+type Cycle struct {
+	Forced     bool  `json:"forced"`
+	DurationMS int64 `json:"duration_ms,omitempty"`
+}
+
+// Subject is the data of subject_synced and subject_failed.
+type Subject struct {
+	OwnerType string `json:"owner_type"`
+	OwnerID   int64  `json:"owner_id"`
+	Endpoint  string `json:"endpoint"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Job is the data of job_ready.
+type Job struct {
+	JobID       int64     `json:"job_id"`
+	BlueprintID int64     `json:"blueprint_id"`
+	OwnerType   string    `json:"owner_type"`
+	OwnerID     int64     `json:"owner_id"`
+	InstallerID int64     `json:"installer_id"`
+	Activity    string    `json:"activity"`
+	EndDate     time.Time `json:"end_date"`
+}
+
+// activityLabels are the names of job activities as shown in the dashboard.
+var activityLabels = map[string]string{
+	"me_research": "ME Research",
+	"te_research": "TE Research",
+	"copying":     "Copying",
+}
+
+// ActivityLabel returns the name of a job activity ("me_research",
+// "te_research" or "copying", as in Job) as shown in the dashboard, or
+// activity itself if it is none of them.
+func ActivityLabel(activity string) string {
+	if label, ok := activityLabels[activity]; ok {
+		return label
+	}
+	return activity
+}
+
+// Blueprint is the data of blueprint_added and blueprint_removed.
+type Blueprint struct {
+	BlueprintID int64  `json:"blueprint_id"`
+	TypeID      int64  `json:"type_id"`
+	OwnerType   string `json:"owner_type"`
+	OwnerID     int64  `json:"owner_id"`
+}
+
+// Alert is the data of alert_fired: a rule matched subjects it had not
+// fired for within its cooldown.
+type Alert struct {
+	RuleID  int64        `json:"rule_id"`
+	Rule    string       `json:"rule"`
+	Matches []AlertMatch `json:"matches"`
+}
+
+// AlertMatch is a subject an alert rule matched.
+type AlertMatch struct {
+	Subject     string `json:"subject"` // e.g. job:512345678
+	Description string `json:"description"`
+}
+
+// Bus fans published events out to subscribers. The zero value is not
+// usable; create one with NewBus. A nil *Bus discards everything published
+// to it, so publishers need not check whether a bus is wired up.
+type Bus struct {
+	mu      stdsync.Mutex
+	now     func() time.Time
+	lastID  uint64
+	history []Event // ring buffer of the last len(history) events
+	next    int     // index in history the next event is written to
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+// NewBus creates a Bus that keeps the last historySize events for replay.
+func NewBus(historySize int) *Bus {
+	if historySize < 1 {
+		historySize = 1
+	}
+	return &Bus{
+		now:     time.Now,
+		history: make([]Event, historySize),
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events published after it was created.
+type Subscription struct {
+	bus *Bus
+	ch  chan Event
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// subscription is closed, when the bus is closed, or when the subscriber fell
+// more than a buffer's worth of events behind; in the last case it should
+// subscribe again from the last event it received.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.drop(s)
+}
+
+// Publish assigns the next ID to an event of eventType with data, records it
+// in the history, and delivers it to every subscriber without blocking. A
+// subscriber whose buffer is full is dropped.
+func (b *Bus) Publish(eventType string, data any) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	b.lastID++
+	e := Event{ID: b.lastID, Type: eventType, Time: b.now(), Data: data}
+	b.history[b.next] = e
+	b.next = (b.next + 1) % len(b.history)
+
+	for s := range b.subs {
+		select {
+		case s.ch <- e:
+		default:
+			b.drop(s)
+		}
+	}
+}
+
+// Subscribe registers a new subscriber. With lastEventID 0 it receives only
+// events published from now on. Otherwise replay holds the retained events
+// after lastEventID, oldest first, and complete reports whether they are all
+// of them: it is false when some were already dropped from the history, or
+// when lastEventID is unknown to this bus (e.g. from before a restart). The
+// caller should then treat its state as stale and reload it.
+func (b *Bus) Subscribe(lastEventID uint64) (sub *Subscription, replay []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{bus: b, ch: make(chan Event, subscriberBuffer)}
+	if b.closed {
+		close(sub.ch)
+		return sub, nil, false
+	}
+	b.subs[sub] = struct{}{}
+
+	if lastEventID == 0 {
+		return sub, nil, true
+	}
+	if lastEventID > b.lastID {
+		return sub, nil, false
+	}
+	retained := b.retained()
+	complete = len(retained) == 0 || retained[0].ID <= lastEventID+1
+	for _, e := range retained {
+		if e.ID > lastEventID {
+			replay = append(replay, e)
+		}
+	}
+	return sub, replay, complete
+}
+
+// Follow calls handle with every event published on bus, in order, until ctx
+// is canceled or the bus is closed. When the bus drops it for falling behind,
+// it subscribes again from the last event handled and handles the ones it
+// missed; if some of them are no longer retained, it calls missed first (if
+// not nil), so that the caller can reload its state or report the gap.
+func Follow(ctx context.Context, bus *Bus, handle func(Event), missed func()) {
+	sub, _, _ := bus.Subscribe(0)
+	defer func() { sub.Close() }()
+
+	var lastID uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.Events():
+			if ok {
+				lastID = e.ID
+				handle(e)
+				continue
+			}
+			if ctx.Err() != nil || bus.isClosed() {
+				return
+			}
+			var replay []Event
+			var complete bool
+			sub, replay, complete = bus.Subscribe(lastID)
+			if !complete && missed != nil && !bus.isClosed() {
+				missed()
+			}
+			for _, e := range replay {
+				lastID = e.ID
+				handle(e)
+			}
+		}
+	}
+}
+
+// Subscribers returns the number of active subscriptions.
+func (b *Bus) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Close closes every subscription and discards events published afterwards.
+// It is meant for server shutdown, so that open event streams end.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		b.drop(s)
+	}
+}
+
+// isClosed reports whether Close was called.
+func (b *Bus) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// retained returns the events in the history, oldest first.
+// The caller must hold b.mu.
+func (b *Bus) retained() []Event {
+	n := len(b.history)
+	if b.lastID < uint64(n) {
+		n = int(b.lastID)
+	}
+	out := make([]Event, 0, n)
+	start := (b.next - n + len(b.history)) % len(b.history)
+	for i := range n {
+		out = append(out, b.history[(start+i)%len(b.history)])
+	}
+	return out
+}
+
+// drop removes s and closes its channel if it is still subscribed.
+// The caller must hold b.mu.
+func (b *Bus) drop(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.ch)
+}
@@ -0,0 +1,276 @@
+package events
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// ids returns the IDs of events, for compact comparisons.
+func ids(events []Event) []uint64 {
+	out := make([]uint64, 0, len(events))
+	for _, e := range events {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestBus_PublishDeliversToSubscribers(t *testing.T) {
+	b := NewBus(8)
+	s1, _, _ := b.Subscribe(0)
+	s2, _, _ := b.Subscribe(0)
+
+	b.Publish(TypeSubjectSynced, Subject{OwnerType: "character", OwnerID: 1, Endpoint: "blueprints"})
+
+	for i, s := range []*Subscription{s1, s2} {
+		e := <-s.Events()
+		if e.ID != 1 || e.Type != TypeSubjectSynced {
+			t.Errorf("subscriber %d: got event %d %q, want 1 %q", i, e.ID, e.Type, TypeSubjectSynced)
+		}
+		if d, ok := e.Data.(Subject); !ok || d.OwnerID != 1 {
+			t.Errorf("subscriber %d: data = %#v", i, e.Data)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("subscriber %d: event time not set", i)
+		}
+	}
+}
+
+func TestBus_IDsIncrease(t *testing.T) {
+	b := NewBus(8)
+	s, _, _ := b.Subscribe(0)
+	for range 3 {
+		b.Publish(TypeCycleStarted, Cycle{})
+	}
+	var got []uint64
+	for range 3 {
+		got = append(got, (<-s.Events()).ID)
+	}
+	if !slices.Equal(got, []uint64{1, 2, 3}) {
+		t.Errorf("IDs = %v, want [1 2 3]", got)
+	}
+}
+
+func TestBus_SubscribeReplaysAfterLastEventID(t *testing.T) {
+	b := NewBus(8)
+	for range 5 {
+		b.Publish(TypeCycleStarted, Cycle{})
+	}
+
+	_, replay, complete := b.Subscribe(3)
+	if !complete {
+		t.Error("complete = false, want true")
+	}
+	if got := ids(replay); !slices.Equal(got, []uint64{4, 5}) {
+		t.Errorf("replay = %v, want [4 5]", got)
+	}
+
+	_, replay, complete = b.Subscribe(5)
+	if !complete || len(replay) != 0 {
+		t.Errorf("up to date: replay = %v, complete = %v; want none, true", ids(replay), complete)
+	}
+}
+
+func TestBus_SubscribeAfterHistoryWrapped(t *testing.T) {
+	b := NewBus(3)
+	for range 7 {
+		b.Publish(TypeCycleStarted, Cycle{})
+	}
+
+	// Events 5, 6, 7 are retained: resuming from 4 misses nothing.
+	_, replay, complete := b.Subscribe(4)
+	if !complete {
+		t.Error("resume from 4: complete = false, want true")
+	}
+	if got := ids(replay); !slices.Equal(got, []uint64{5, 6, 7}) {
+		t.Errorf("resume from 4: replay = %v, want [5 6 7]", got)
+	}
+
+	// Event 3 was dropped from the history.
+	_, replay, complete = b.Subscribe(2)
+	if complete {
+		t.Error("resume from 2: complete = true, want false")
+	}
+	if got := ids(replay); !slices.Equal(got, []uint64{5, 6, 7}) {
+		t.Errorf("resume from 2: replay = %v, want [5 6 7]", got)
+	}
+}
+
+func TestBus_SubscribeUnknownLastEventID(t *testing.T) {
+	b := NewBus(8)
+	b.Publish(TypeCycleStarted, Cycle{})
+
+	// An ID from before a restart, ahead of this bus.
+	_, replay, complete := b.Subscribe(40)
+	if complete || len(replay) != 0 {
+		t.Errorf("replay = %v, complete = %v; want none, false", ids(replay), complete)
+	}
+}
+
+func TestBus_CloseUnsubscribes(t *testing.T) {
+	b := NewBus(8)
+	s, _, _ := b.Subscribe(0)
+	if n := b.Subscribers(); n != 1 {
+		t.Fatalf("Subscribers() = %d, want 1", n)
+	}
+
+	s.Close()
+	s.Close() // idempotent
+
+	if n := b.Subscribers(); n != 0 {
+		t.Errorf("Subscribers() after Close = %d, want 0", n)
+	}
+	if _, ok := <-s.Events(); ok {
+		t.Error("Events() channel still open after Close")
+	}
+	b.Publish(TypeCycleStarted, Cycle{}) // must not panic on the closed channel
+}
+
+func TestBus_SlowSubscriberDropped(t *testing.T) {
+	b := NewBus(8)
+	slow, _, _ := b.Subscribe(0)
+	fast, _, _ := b.Subscribe(0)
+
+	for range subscriberBuffer {
+		b.Publish(TypeCycleStarted, Cycle{})
+	}
+	for range subscriberBuffer {
+		<-fast.Events()
+	}
+	// slow's buffer is full; fast has room.
+	b.Publish(TypeCycleStarted, Cycle{})
+
+	n := 0
+	for range slow.Events() {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("slow subscriber got %d events before being dropped, want %d", n, subscriberBuffer)
+	}
+	if got := b.Subscribers(); got != 1 {
+		t.Errorf("Subscribers() = %d, want 1 (only the fast one)", got)
+	}
+	if e := <-fast.Events(); e.ID != subscriberBuffer+1 {
+		t.Errorf("fast subscriber got event %d, want %d", e.ID, subscriberBuffer+1)
+	}
+}
+
+func TestBus_CloseEndsAllSubscriptions(t *testing.T) {
+	b := NewBus(8)
+	s, _, _ := b.Subscribe(0)
+
+	b.Close()
+
+	if _, ok := <-s.Events(); ok {
+		t.Error("Events() channel still open after Bus.Close")
+	}
+	b.Publish(TypeCycleStarted, Cycle{})
+
+	late, _, complete := b.Subscribe(0)
+	if _, ok := <-late.Events(); ok || complete {
+		t.Errorf("subscription after Bus.Close: open = %v, complete = %v; want closed, false", ok, complete)
+	}
+}
+
+func TestBus_NilPublishIsNoop(t *testing.T) {
+	var b *Bus
+	b.Publish(TypeCycleStarted, Cycle{})
+}
+
+// followDropped runs Follow on b and makes it fall behind by more than a
+// buffer's worth of events while it handles the first one. It returns the IDs
+// handled, once b is closed, and whether missed was called.
+func followDropped(t *testing.T, b *Bus, published int) (handled []uint64, missed bool) {
+	t.Helper()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Follow(context.Background(), b, func(e Event) {
+			if e.ID == 1 {
+				close(started)
+				<-release
+			}
+			handled = append(handled, e.ID)
+		}, func() { missed = true })
+	}()
+	for b.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	b.Publish(TypeCycleStarted, Cycle{})
+	<-started
+	for range published - 1 {
+		b.Publish(TypeSubjectSynced, Subject{})
+	}
+	close(release)
+	for b.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	b.Close()
+	<-done
+	return handled, missed
+}
+
+func TestFollow_ResubscribesWhenDropped(t *testing.T) {
+	const published = subscriberBuffer + 3
+	handled, missed := followDropped(t, NewBus(DefaultHistorySize), published)
+
+	var want []uint64
+	for id := range uint64(published) {
+		want = append(want, id+1)
+	}
+	if !slices.Equal(handled, want) {
+		t.Errorf("handled %v, want 1 to %d in order", handled, published)
+	}
+	if missed {
+		t.Error("missed called, though every event was retained")
+	}
+}
+
+func TestFollow_ReportsEventsNoLongerRetained(t *testing.T) {
+	const published = subscriberBuffer + 20
+	handled, missed := followDropped(t, NewBus(8), published)
+
+	if !missed {
+		t.Error("missed not called, though events were dropped from the history")
+	}
+	if len(handled) == 0 || handled[len(handled)-1] != published {
+		t.Errorf("handled %v, want it to end with %d", handled, published)
+	}
+}
+
+func TestFollow_StopsWhenContextCanceled(t *testing.T) {
+	b := NewBus(8)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Follow(ctx, b, func(Event) {}, nil)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Follow did not return after ctx was canceled")
+	}
+	if got := b.Subscribers(); got != 0 {
+		t.Errorf("Subscribers() = %d after Follow returned, want 0", got)
+	}
+}
+
+func TestActivityLabel(t *testing.T) {
+	for activity, want := range map[string]string{
+		"me_research":   "ME Research",
+		"te_research":   "TE Research",
+		"copying":       "Copying",
+		"manufacturing": "manufacturing",
+	} {
+		if got := ActivityLabel(activity); got != want {
+			t.Errorf("ActivityLabel(%q) = %q, want %q", activity, got, want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvWriter writes RFC 4180 CSV: CRLF line endings, fields quoted as needed.
+type csvWriter struct {
+	w       *csv.Writer
+	columns []string
+	started bool
+	record  []string
+}
+
+func newCSVWriter(w io.Writer, columns []string) *csvWriter {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	return &csvWriter{w: cw, columns: columns, record: make([]string, len(columns))}
+}
+
+func (c *csvWriter) start() error {
+	if c.started {
+		return nil
+	}
+	c.started = true
+	return c.w.Write(c.columns)
+}
+
+func (c *csvWriter) WriteRow(values []any) error {
+	if len(values) != len(c.columns) {
+		return fmt.Errorf("row has %d values, want %d", len(values), len(c.columns))
+	}
+	if err := c.start(); err != nil {
+		return err
+	}
+	for i, v := range values {
+		c.record[i] = csvField(v)
+	}
+	if err := c.w.Write(c.record); err != nil {
+		return err
+	}
+	// Flush row by row so that a large export is streamed.
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.start(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func csvField(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return timestamp(v)
+	}
+	return fmt.Sprint(v)
+}
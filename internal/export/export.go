@@ -0,0 +1,72 @@
+// Package export writes tabular data as CSV, XLSX or JSON lines.
+//
+// A Writer is created with the column names and then fed one row at a time,
+// so large exports are streamed rather than built in memory. Cell values are
+// nil (empty), string, int64, float64, bool or time.Time; times are written as
+// ISO 8601 timestamps in UTC in every format.
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Format is an export file format.
+type Format string
+
+// Supported formats.
+const (
+	FormatCSV    Format = "csv"
+	FormatXLSX   Format = "xlsx"
+	FormatNDJSON Format = "ndjson"
+)
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCSV, FormatXLSX, FormatNDJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown export format %q (want csv, xlsx or ndjson)", s)
+}
+
+// ContentType returns the MIME type of f.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// Writer writes the rows of one export.
+type Writer interface {
+	// WriteRow writes one row; values are in column order.
+	WriteRow(values []any) error
+	// Close finishes the output. It does not close the underlying io.Writer.
+	Close() error
+}
+
+// NewWriter returns a Writer of format f to w. The CSV and XLSX header row is
+// written by the first WriteRow or Close; JSON lines use the column names as
+// keys. sheet names the XLSX worksheet and is ignored by the other formats.
+func NewWriter(w io.Writer, f Format, sheet string, columns []string) (Writer, error) {
+	switch f {
+	case FormatCSV:
+		return newCSVWriter(w, columns), nil
+	case FormatXLSX:
+		return newXLSXWriter(w, sheet, columns), nil
+	case FormatNDJSON:
+		return newNDJSONWriter(w, columns), nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", f)
+}
+
+// timestamp formats t as written in every format.
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
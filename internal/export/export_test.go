@@ -0,0 +1,179 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testColumns = []string{"id", "name", "security", "ready", "end_date", "note"}
+	testEnd     = time.Date(2026, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+	testRows    = [][]any{
+		{int64(1035000000001), "Drake Blueprint", 0.946, true, testEnd, nil},
+		{int64(2), `Station Container "T2, BPOs"`, -0.1, false, nil, "line one\nline two <&>"},
+	}
+)
+
+func writeAll(t *testing.T, f Format) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, f, "Blueprints", testColumns)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for _, row := range testRows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"csv", "xlsx", "ndjson"} {
+		if f, err := ParseFormat(s); err != nil || string(f) != s {
+			t.Errorf("ParseFormat(%q) = %q, %v", s, f, err)
+		}
+	}
+	for _, s := range []string{"", "CSV", "json", "xls"} {
+		if _, err := ParseFormat(s); err == nil {
+			t.Errorf("ParseFormat(%q): expected error", s)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	got := string(writeAll(t, FormatCSV))
+	want := "id,name,security,ready,end_date,note\r\n" +
+		"1035000000001,Drake Blueprint,0.946,true,2026-03-01T11:30:00Z,\r\n" +
+		"2,\"Station Container \"\"T2, BPOs\"\"\",-0.1,false,,\"line one\r\nline two <&>\"\r\n"
+	if got != want {
+		t.Errorf("CSV:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestCSV_HeaderOnlyWhenEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, FormatCSV, "", []string{"a", "b"})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "a,b\r\n" {
+		t.Errorf("empty CSV = %q, want header row", got)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	got := string(writeAll(t, FormatNDJSON))
+	want := `{"id":1035000000001,"name":"Drake Blueprint","security":0.946,"ready":true,"end_date":"2026-03-01T11:30:00Z","note":null}` + "\n" +
+		`{"id":2,"name":"Station Container \"T2, BPOs\"","security":-0.1,"ready":false,"end_date":null,"note":"line one\nline two <&>"}` + "\n"
+	if got != want {
+		t.Errorf("NDJSON:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteRow_WrongLength(t *testing.T) {
+	for _, f := range []Format{FormatCSV, FormatXLSX, FormatNDJSON} {
+		w, _ := NewWriter(io.Discard, f, "", []string{"a", "b"})
+		if err := w.WriteRow([]any{"x"}); err == nil {
+			t.Errorf("%s: expected error for a short row", f)
+		}
+	}
+}
+
+// xlsxSheet is the part of a worksheet the test reads back.
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Type   string `xml:"t,attr"`
+			Style  string `xml:"s,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestXLSX(t *testing.T) {
+	data := writeAll(t, FormatXLSX)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("not a zip archive: %v", err)
+	}
+	parts := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name], _ = io.ReadAll(rc)
+		_ = rc.Close()
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		p, ok := parts[name]
+		if !ok {
+			t.Fatalf("missing part %s", name)
+		}
+		if err := xml.Unmarshal(p, new(struct{})); err != nil {
+			t.Errorf("%s is not well-formed XML: %v", name, err)
+		}
+	}
+	if !strings.Contains(string(parts["xl/workbook.xml"]), `name="Blueprints"`) {
+		t.Errorf("workbook does not name the sheet: %s", parts["xl/workbook.xml"])
+	}
+
+	var sheet xlsxSheet
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Rows) != 3 {
+		t.Fatalf("rows = %d, want header + 2", len(sheet.Rows))
+	}
+	var header []string
+	for _, c := range sheet.Rows[0].Cells {
+		header = append(header, c.Inline)
+	}
+	if strings.Join(header, ",") != strings.Join(testColumns, ",") {
+		t.Errorf("header = %v, want %v", header, testColumns)
+	}
+	first := sheet.Rows[1].Cells
+	if first[0].Value != "1035000000001" || first[0].Style != "2" {
+		t.Errorf("id cell = %+v, want integer 1035000000001", first[0])
+	}
+	if first[1].Type != "inlineStr" || first[1].Inline != "Drake Blueprint" {
+		t.Errorf("name cell = %+v", first[1])
+	}
+	if first[2].Value != "0.946" || first[3].Type != "b" || first[3].Value != "1" {
+		t.Errorf("number and bool cells = %+v, %+v", first[2], first[3])
+	}
+	if first[4].Inline != "2026-03-01T11:30:00Z" {
+		t.Errorf("time cell = %+v, want ISO timestamp", first[4])
+	}
+	second := sheet.Rows[2].Cells
+	if second[1].Inline != `Station Container "T2, BPOs"` || second[5].Inline != "line one\nline two <&>" {
+		t.Errorf("escaped strings = %q, %q", second[1].Inline, second[5].Inline)
+	}
+	if second[4].Value != "" || second[4].Inline != "" {
+		t.Errorf("nil cell = %+v, want empty", second[4])
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	for in, want := range map[string]string{
+		"Blueprints":                            "Blueprints",
+		"":                                      "Sheet1",
+		"a/b:c":                                 "a_b_c",
+		"Blueprints of a very long corporation": "Blueprints of a very long corpo",
+	} {
+		if got := xlsxSheetName(in); got != want {
+			t.Errorf("xlsxSheetName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ndjsonWriter writes one JSON object per line, keyed by column name in
+// column order. Empty values are null. HTML characters are not escaped.
+type ndjsonWriter struct {
+	w    io.Writer
+	keys [][]byte // column names, JSON-encoded
+	buf  bytes.Buffer
+	val  bytes.Buffer // one encoded value
+	enc  *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer, columns []string) *ndjsonWriter {
+	keys := make([][]byte, len(columns))
+	for i, c := range columns {
+		keys[i], _ = json.Marshal(c) // a string always encodes
+	}
+	n := &ndjsonWriter{w: w, keys: keys}
+	n.enc = json.NewEncoder(&n.val)
+	n.enc.SetEscapeHTML(false)
+	return n
+}
+
+func (n *ndjsonWriter) WriteRow(values []any) error {
+	if len(values) != len(n.keys) {
+		return fmt.Errorf("row has %d values, want %d", len(values), len(n.keys))
+	}
+	n.buf.Reset()
+	n.buf.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			n.buf.WriteByte(',')
+		}
+		n.buf.Write(n.keys[i])
+		n.buf.WriteByte(':')
+		if t, ok := v.(time.Time); ok {
+			v = timestamp(t)
+		}
+		n.val.Reset()
+		if err := n.enc.Encode(v); err != nil {
+			return fmt.Errorf("encoding %s: %w", n.keys[i], err)
+		}
+		n.buf.Write(bytes.TrimSuffix(n.val.Bytes(), []byte("\n")))
+	}
+	n.buf.WriteString("}\n")
+	_, err := n.w.Write(n.buf.Bytes())
+	return err
+}
+
+func (n *ndjsonWriter) Close() error { return nil }
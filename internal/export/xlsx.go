@@ -0,0 +1,205 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xlsxWriter writes an Office Open XML workbook with a single worksheet. The
+// worksheet is streamed row by row with inline strings, so no shared string
+// table has to be collected first.
+type xlsxWriter struct {
+	zw      *zip.Writer
+	sheet   string
+	columns []string
+	started bool
+	out     *bufio.Writer // the worksheet part, once started
+	err     error         // first error writing to out
+}
+
+// Cell styles, indexes into cellXfs in xlsxStyles.
+const (
+	xlsxStyleHeader  = 1 // bold
+	xlsxStyleInteger = 2 // "0": IDs are shown in full rather than as 1.03E+12
+)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="1" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`</styleSheet>`
+
+func newXLSXWriter(w io.Writer, sheet string, columns []string) *xlsxWriter {
+	return &xlsxWriter{zw: zip.NewWriter(w), sheet: xlsxSheetName(sheet), columns: columns}
+}
+
+// start writes the fixed workbook parts, opens the worksheet and writes the
+// header row.
+func (x *xlsxWriter) start() error {
+	if x.started {
+		return nil
+	}
+	x.started = true
+
+	workbook := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + xmlEscape(x.sheet) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	for _, part := range []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", workbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	} {
+		f, err := x.zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+
+	f, err := x.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	x.out = bufio.NewWriter(f)
+	x.write(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<sheetData>`)
+	x.write("<row>")
+	for _, c := range x.columns {
+		x.writeString(c, xlsxStyleHeader)
+	}
+	x.write("</row>")
+	return x.err
+}
+
+func (x *xlsxWriter) WriteRow(values []any) error {
+	if len(values) != len(x.columns) {
+		return fmt.Errorf("row has %d values, want %d", len(values), len(x.columns))
+	}
+	if err := x.start(); err != nil {
+		return err
+	}
+	x.write("<row>")
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			x.write("<c/>")
+		case string:
+			x.writeString(v, 0)
+		case int64:
+			x.write(`<c s="` + strconv.Itoa(xlsxStyleInteger) + `"><v>` + strconv.FormatInt(v, 10) + "</v></c>")
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				x.write("<c/>")
+				continue
+			}
+			x.write("<c><v>" + strconv.FormatFloat(v, 'g', -1, 64) + "</v></c>")
+		case bool:
+			b := "0"
+			if v {
+				b = "1"
+			}
+			x.write(`<c t="b"><v>` + b + "</v></c>")
+		case time.Time:
+			x.writeString(timestamp(v), 0)
+		default:
+			x.writeString(fmt.Sprint(v), 0)
+		}
+	}
+	x.write("</row>")
+	return x.err
+}
+
+// write writes s to the worksheet unless an earlier write failed.
+func (x *xlsxWriter) write(s string) {
+	if x.err == nil {
+		_, x.err = x.out.WriteString(s)
+	}
+}
+
+func (x *xlsxWriter) writeString(s string, style int) {
+	x.write(`<c t="inlineStr"`)
+	if style != 0 {
+		x.write(` s="` + strconv.Itoa(style) + `"`)
+	}
+	x.write(`><is><t xml:space="preserve">` + xmlEscape(s) + "</t></is></c>")
+}
+
+func (x *xlsxWriter) Close() error {
+	if err := x.start(); err != nil {
+		return err
+	}
+	x.write("</sheetData></worksheet>")
+	if x.err != nil {
+		return x.err
+	}
+	if err := x.out.Flush(); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// xmlEscape escapes s for XML text and attribute values. Characters XML does
+// not allow are replaced with U+FFFD.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s)) // a strings.Builder never fails
+	return b.String()
+}
+
+// xlsxSheetName makes name a valid worksheet name: at most 31 characters, none
+// of []:*?/\.
+func xlsxSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if name == "" {
+		name = "Sheet1"
+	}
+	return name
+}
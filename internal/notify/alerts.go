@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// jobAlert returns the alert for job j becoming ready. row is the blueprint
+// the job runs on, or nil if it is no longer stored; the alert then shows IDs
+// instead of names.
+func jobAlert(j events.Job, row *store.ListBlueprintsRow) Alert {
+	typeName := "Blueprint " + strconv.FormatInt(j.BlueprintID, 10)
+	owner := ownerKey(j.OwnerType, j.OwnerID)
+	installer := "character " + strconv.FormatInt(j.InstallerID, 10)
+	location := ""
+	if row != nil {
+		typeName, owner = row.TypeName, row.OwnerName
+		if row.JobID.Int64 == j.JobID && row.JobInstallerName.Valid {
+			installer = row.JobInstallerName.String
+		}
+		location = locationName(row)
+	}
+	activity := events.ActivityLabel(j.Activity)
+
+	a := Alert{
+		Kind:    KindJobReady,
+		Subject: "job:" + strconv.FormatInt(j.JobID, 10),
+		Title:   "Job ready: " + typeName,
+		Summary: fmt.Sprintf("%s — %s (%s)", typeName, activity, installer),
+		Fields: []Field{
+			{"Activity", activity},
+			{"Installer", installer},
+			{"Owner", owner},
+		},
+		Time: j.EndDate,
+	}
+	if location != "" {
+		a.Fields = append(a.Fields, Field{"Location", location})
+	}
+	return a
+}
+
+// idleAlert returns the alert for the blueprint on row, idle since since.
+// The subject includes since, so that each idle period is reported once.
+func idleAlert(row *store.ListBlueprintsRow, since, now time.Time) Alert {
+	idle := formatDuration(now.Sub(since))
+	a := Alert{
+		Kind:    KindIdleBlueprint,
+		Subject: fmt.Sprintf("blueprint:%d:%d", row.ID, since.Unix()),
+		Title:   "Idle blueprint: " + row.TypeName,
+		Summary: fmt.Sprintf("%s (%s), idle for %s", row.TypeName, row.OwnerName, idle),
+		Fields: []Field{
+			{"Owner", row.OwnerName},
+			{"ME / TE", fmt.Sprintf("%d / %d", row.MeLevel, row.TeLevel)},
+			{"Idle for", idle},
+		},
+		Time: since,
+	}
+	if location := locationName(row); location != "" {
+		a.Fields = append(a.Fields, Field{"Location", location})
+	}
+	return a
+}
+
+// freeSlotsAlert returns the alert for a character using used of slots
+// research slots.
+func freeSlotsAlert(id int64, name string, used, slots int64, now time.Time) Alert {
+	return Alert{
+		Kind:    KindFreeSlots,
+		Subject: characterSubject(id),
+		Title:   "Free research slots: " + name,
+		Summary: fmt.Sprintf("%s: %d of %d free", name, slots-used, slots),
+		Fields: []Field{
+			{"Free", fmt.Sprintf("%d of %d", slots-used, slots)},
+		},
+		Time: now,
+	}
+}
+
+// syncFailedAlert returns the alert for endpoint failing to sync for an
+// owner. name is empty if the owner is unknown.
+func syncFailedAlert(ownerType string, ownerID int64, name, endpoint, errText string, now time.Time) Alert {
+	if name == "" {
+		name = ownerKey(ownerType, ownerID)
+	}
+	return Alert{
+		Kind:    KindSyncFailed,
+		Subject: syncSubject(ownerType, ownerID, endpoint),
+		Title:   fmt.Sprintf("Sync failed: %s (%s)", name, endpoint),
+		Summary: fmt.Sprintf("%s (%s): %s", name, endpoint, errText),
+		Text:    errText,
+		Fields: []Field{
+			{"Owner", fmt.Sprintf("%s (%s)", name, ownerType)},
+			{"Endpoint", endpoint},
+		},
+		Time: now,
+	}
+}
+
+// locationName returns the station or structure name of row followed by the
+// path inside it, as on the dashboard, or "" while it is not resolved.
+func locationName(row *store.ListBlueprintsRow) string {
+	if !row.LocationName.Valid {
+		return ""
+	}
+	if row.LocationPath.String != "" {
+		return row.LocationName.String + " › " + row.LocationPath.String
+	}
+	return row.LocationName.String
+}
+
+// formatDuration formats d in days and hours, e.g. "3d 4h".
+func formatDuration(d time.Duration) string {
+	hours := int64(d / time.Hour)
+	switch {
+	case hours < 1:
+		return "less than an hour"
+	case hours < 24:
+		return fmt.Sprintf("%dh", hours)
+	case hours%24 == 0:
+		return fmt.Sprintf("%dd", hours/24)
+	}
+	return fmt.Sprintf("%dd %dh", hours/24, hours%24)
+}
@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// The daily digest is a snapshot of everything that needs attention when it
+// is sent — ready jobs, failing syncs, idle blueprints, free slots — rather
+// than a replay of the day's alerts, so it holds no state beyond the webhooks
+// it was sent to each day.
+
+// untilDigest returns the time left until the next digest is due.
+func (n *Notifier) untilDigest() time.Duration {
+	now := n.now().UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), n.opts.DigestHour, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.Sub(now)
+}
+
+// sendDigest posts today's digest to the digest webhooks that have not taken
+// it yet, unless it is not due. A digest is recorded per webhook once posted,
+// so a webhook that failed is sent it on the next try and the others are not
+// sent it twice. It reports whether no digest is left to post today.
+func (n *Notifier) sendDigest(ctx context.Context) bool {
+	now := n.now().UTC()
+	if now.Hour() < n.opts.DigestHour {
+		return true
+	}
+	date := now.Format(time.DateOnly)
+	var hooks []Webhook
+	for _, wh := range n.opts.Webhooks {
+		if !wh.Digest {
+			continue
+		}
+		sent, err := n.sent(ctx, kindDigest, digestSubject(date, wh))
+		if err != nil {
+			log.Printf("notify: %v", err)
+			return false
+		}
+		if !sent {
+			hooks = append(hooks, wh)
+		}
+	}
+	if len(hooks) == 0 {
+		return true
+	}
+
+	alerts, err := n.snapshot(ctx, now)
+	if err != nil {
+		log.Printf("notify: digest: %v", err)
+		return false
+	}
+	done := true
+	for _, wh := range hooks {
+		var selected []Alert
+		for _, a := range alerts {
+			if wh.wants(a.Kind) {
+				selected = append(selected, a)
+			}
+		}
+		if err := n.postDigest(ctx, wh, digestMessages(date, selected)); err != nil {
+			log.Printf("notify: posting digest to Discord webhook %s: %v", webhookName(wh.URL), err)
+			done = false
+			continue
+		}
+		if err := n.q.RecordNotification(ctx, store.RecordNotificationParams{
+			Kind: kindDigest, Subject: digestSubject(date, wh), SentAt: now,
+		}); err != nil {
+			log.Printf("notify: recording digest: %v", err)
+		}
+	}
+	return done
+}
+
+// postDigest posts the messages of a digest to wh. A digest split across
+// several messages is posted whole again when one of them fails.
+func (n *Notifier) postDigest(ctx context.Context, wh Webhook, msgs []discordMessage) error {
+	for _, msg := range msgs {
+		if err := n.discord.post(ctx, wh.URL, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// digestSubject returns the notification_log subject of the digest of date
+// posted to wh. The webhook is identified by a hash of its URL, which holds
+// its secret token.
+func digestSubject(date string, wh Webhook) string {
+	sum := sha256.Sum256([]byte(wh.URL))
+	return date + ":" + hex.EncodeToString(sum[:8])
+}
+
+// snapshot returns an alert for everything that needs attention at now.
+func (n *Notifier) snapshot(ctx context.Context, now time.Time) ([]Alert, error) {
+	rows, err := n.q.ListBlueprints(ctx, store.ListBlueprintsParams{})
+	if err != nil {
+		return nil, fmt.Errorf("listing blueprints: %w", err)
+	}
+	byID := make(map[int64]*store.ListBlueprintsRow, len(rows))
+	var alerts []Alert
+	for i := range rows {
+		row := &rows[i]
+		byID[row.ID] = row
+		if row.JobID.Valid && (row.JobStatus.String == "ready" || !row.JobEndDate.Time.After(now)) {
+			alerts = append(alerts, jobAlert(events.Job{
+				JobID:       row.JobID.Int64,
+				BlueprintID: row.ID,
+				OwnerType:   row.OwnerType,
+				OwnerID:     row.OwnerID,
+				InstallerID: row.JobInstallerID.Int64,
+				Activity:    row.JobActivity.String,
+				EndDate:     row.JobEndDate.Time,
+			}, row))
+		}
+	}
+
+	status, err := n.q.ListSyncStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing sync status: %w", err)
+	}
+	for _, s := range status {
+		if s.LastError.Valid && s.LastError.String != "" {
+			alerts = append(alerts, syncFailedAlert(s.OwnerType, s.OwnerID, s.OwnerName, s.Endpoint, s.LastError.String, now))
+		}
+	}
+
+	idle, err := n.idleBlueprints(ctx, byID, now)
+	if err != nil {
+		return nil, err
+	}
+	alerts = append(alerts, idle...)
+
+	free, _, err := n.freeSlots(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	return append(alerts, free...), nil
+}
+
+// digestMessages returns the digest of date listing alerts: one embed per
+// kind with alerts.
+func digestMessages(date string, alerts []Alert) []discordMessage {
+	byKind := make(map[string][]Alert)
+	for _, a := range alerts {
+		byKind[a.Kind] = append(byKind[a.Kind], a)
+	}
+	var embeds []discordEmbed
+	for _, kind := range Kinds {
+		if group := byKind[kind]; len(group) > 0 {
+			embeds = append(embeds, listEmbed(kind, fmt.Sprintf("%s (%d)", kindTitles[kind], len(group)), group))
+		}
+	}
+	if len(embeds) == 0 {
+		embeds = append(embeds, discordEmbed{Description: "Nothing needs attention."})
+	}
+	msgs := packMessages(embeds)
+	msgs[0].Content = "**Auspex daily digest — " + date + "**"
+	return msgs
+}
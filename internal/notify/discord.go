@@ -0,0 +1,328 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	stdsync "sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Discord message limits, see https://discord.com/developers/docs/resources/message#embed-object-embed-limits.
+const (
+	discordMaxEmbeds      = 10 // per message
+	discordMaxEmbedChars  = 6000
+	discordMaxTitle       = 256
+	discordMaxDescription = 4096
+	discordMaxFieldName   = 256
+	discordMaxFieldValue  = 1024
+)
+
+const (
+	// maxAlertsPerKind is the number of alerts of one kind posted as separate
+	// embeds; more are posted as one embed listing them.
+	maxAlertsPerKind = 10
+	// maxListLines is the number of alerts listed in one embed.
+	maxListLines = 20
+
+	// discordMaxAttempts bounds the attempts at posting one message.
+	discordMaxAttempts = 3
+	// discordMaxRetryAfter is the longest rate limit waited out before giving
+	// up on a message.
+	discordMaxRetryAfter = time.Minute
+)
+
+// Embed colors by alert kind.
+var kindColors = map[string]int{
+	KindJobReady:      0xd9534f, // red, as ready rows on the dashboard
+	KindSyncFailed:    0x992d22,
+	KindIdleBlueprint: 0xf0ad4e, // yellow
+	KindFreeSlots:     0x5cb85c, // green
+	KindRule:          0x5bc0de, // blue
+}
+
+// kindTitles title the embeds listing several alerts of a kind.
+var kindTitles = map[string]string{
+	KindJobReady:      "Jobs ready",
+	KindSyncFailed:    "Sync failures",
+	KindIdleBlueprint: "Idle blueprints",
+	KindFreeSlots:     "Characters with free research slots",
+	KindRule:          "Alert rules",
+}
+
+type discordMessage struct {
+	Username        string                 `json:"username,omitempty"`
+	Content         string                 `json:"content,omitempty"`
+	Embeds          []discordEmbed         `json:"embeds"`
+	AllowedMentions discordAllowedMentions `json:"allowed_mentions"`
+}
+
+// discordAllowedMentions with an empty Parse keeps text such as "@everyone"
+// in names or error messages from pinging anyone.
+type discordAllowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color,omitempty"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// size returns the characters of e counted against discordMaxEmbedChars.
+func (e discordEmbed) size() int {
+	n := utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description)
+	for _, f := range e.Fields {
+		n += utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+	}
+	return n
+}
+
+func newMessage(embeds []discordEmbed) discordMessage {
+	return discordMessage{Username: "Auspex", Embeds: embeds, AllowedMentions: discordAllowedMentions{Parse: []string{}}}
+}
+
+// alertMessages returns the messages posting alerts: one embed per alert,
+// except that a kind with more than maxAlertsPerKind alerts gets one embed
+// listing them. Kinds are posted in the order of Kinds.
+func alertMessages(alerts []Alert) []discordMessage {
+	byKind := make(map[string][]Alert)
+	for _, a := range alerts {
+		byKind[a.Kind] = append(byKind[a.Kind], a)
+	}
+	var embeds []discordEmbed
+	for _, kind := range Kinds {
+		group := byKind[kind]
+		if len(group) > maxAlertsPerKind {
+			embeds = append(embeds, listEmbed(kind, fmt.Sprintf("%s (%d)", kindTitles[kind], len(group)), group))
+			continue
+		}
+		for _, a := range group {
+			embeds = append(embeds, alertEmbed(a))
+		}
+	}
+	return packMessages(embeds)
+}
+
+// packMessages splits embeds into messages within Discord's limits.
+func packMessages(embeds []discordEmbed) []discordMessage {
+	var msgs []discordMessage
+	var cur []discordEmbed
+	size := 0
+	for _, e := range embeds {
+		if len(cur) == discordMaxEmbeds || (len(cur) > 0 && size+e.size() > discordMaxEmbedChars) {
+			msgs = append(msgs, newMessage(cur))
+			cur, size = nil, 0
+		}
+		cur = append(cur, e)
+		size += e.size()
+	}
+	if len(cur) > 0 {
+		msgs = append(msgs, newMessage(cur))
+	}
+	return msgs
+}
+
+func alertEmbed(a Alert) discordEmbed {
+	e := discordEmbed{
+		Title:       truncate(a.Title, discordMaxTitle),
+		Description: truncate(a.Text, discordMaxDescription),
+		Color:       kindColors[a.Kind],
+	}
+	if !a.Time.IsZero() {
+		e.Timestamp = a.Time.UTC().Format(time.RFC3339)
+	}
+	for _, f := range a.Fields {
+		e.Fields = append(e.Fields, discordField{
+			Name:   truncate(f.Name, discordMaxFieldName),
+			Value:  truncate(f.Value, discordMaxFieldValue),
+			Inline: true,
+		})
+	}
+	return e
+}
+
+// listEmbed returns an embed titled title listing the summaries of alerts,
+// at most maxListLines of them.
+func listEmbed(kind, title string, alerts []Alert) discordEmbed {
+	lines := make([]string, 0, min(len(alerts), maxListLines)+1)
+	for i, a := range alerts {
+		if i == maxListLines {
+			lines = append(lines, fmt.Sprintf("…and %d more", len(alerts)-maxListLines))
+			break
+		}
+		lines = append(lines, "• "+truncate(a.Summary, 180))
+	}
+	return discordEmbed{
+		Title:       truncate(title, discordMaxTitle),
+		Description: truncate(strings.Join(lines, "\n"), discordMaxDescription),
+		Color:       kindColors[kind],
+	}
+}
+
+// truncate shortens s to at most n characters, ending with "…" if cut.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
+
+// discordClient posts messages to Discord webhooks within their rate limits.
+type discordClient struct {
+	http       *http.Client
+	retryDelay time.Duration // before retrying a server error
+
+	mu    stdsync.Mutex
+	until map[string]time.Time // webhook URL → end of its exhausted rate limit bucket
+}
+
+func newDiscordClient(httpClient *http.Client) *discordClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &discordClient{http: httpClient, retryDelay: time.Second, until: make(map[string]time.Time)}
+}
+
+// post sends msg to the webhook at webhookURL. It waits while the webhook's
+// rate limit is exhausted, and retries after a 429 response or a server
+// error.
+func (c *discordClient) post(ctx context.Context, webhookURL string, msg discordMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encoding message: %w", err)
+	}
+	for attempt := 1; ; attempt++ {
+		if err := sleep(ctx, c.wait(webhookURL)); err != nil {
+			return err
+		}
+		status, header, respBody, err := c.send(ctx, webhookURL, body)
+		if err != nil {
+			return err
+		}
+		c.track(webhookURL, header)
+
+		var delay time.Duration
+		switch {
+		case status == http.StatusTooManyRequests:
+			delay = retryAfter(header, respBody)
+			if delay > discordMaxRetryAfter {
+				return fmt.Errorf("rate limited for %s", delay)
+			}
+		case status >= 500:
+			delay = c.retryDelay
+		case status >= 300:
+			return fmt.Errorf("discord responded %d: %s", status, truncate(strings.TrimSpace(string(respBody)), 200))
+		default:
+			return nil
+		}
+		if attempt == discordMaxAttempts {
+			return fmt.Errorf("discord responded %d after %d attempts", status, attempt)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *discordClient) send(ctx context.Context, webhookURL string, body []byte) (int, http.Header, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("reading response: %w", err)
+	}
+	return resp.StatusCode, resp.Header, respBody, nil
+}
+
+// wait returns how long to wait before the next request to webhookURL.
+func (c *discordClient) wait(webhookURL string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Until(c.until[webhookURL])
+}
+
+// track records the rate limit state Discord reports in header. Once a
+// bucket has no requests left, the next request waits for it to reset.
+func (c *discordClient) track(webhookURL string, header http.Header) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		delete(c.until, webhookURL)
+		return
+	}
+	if reset, err := strconv.ParseFloat(header.Get("X-RateLimit-Reset-After"), 64); err == nil {
+		c.until[webhookURL] = time.Now().Add(seconds(reset))
+	}
+}
+
+// retryAfter returns the wait Discord asks for in a 429 response: retry_after
+// in the body, else the Retry-After header, else one second.
+func retryAfter(header http.Header, body []byte) time.Duration {
+	var payload struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.RetryAfter > 0 {
+		return seconds(payload.RetryAfter)
+	}
+	if s, err := strconv.ParseFloat(header.Get("Retry-After"), 64); err == nil && s >= 0 {
+		return seconds(s)
+	}
+	return time.Second
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// webhookName returns webhookURL without its secret token, for logs.
+func webhookName(webhookURL string) string {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	path := u.Path
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		path = path[:i] + "/…"
+	}
+	return u.Host + path
+}
@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiscordClient_RetriesAfter429(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"message":"You are being rate limited.","retry_after":0.05,"global":false}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := newDiscordClient(nil)
+	start := time.Now()
+	if err := c.post(context.Background(), srv.URL, newMessage(nil)); err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("got %d requests, want 2", calls.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("retried after %v, want at least retry_after", elapsed)
+	}
+}
+
+func TestDiscordClient_WaitsForExhaustedBucket(t *testing.T) {
+	var last atomic.Int64
+	var tooSoon atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().UnixNano()
+		if prev := last.Swap(now); prev != 0 && time.Duration(now-prev) < 50*time.Millisecond {
+			tooSoon.Store(true)
+		}
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset-After", "0.06")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := newDiscordClient(nil)
+	for range 3 {
+		if err := c.post(context.Background(), srv.URL, newMessage(nil)); err != nil {
+			t.Fatalf("post: %v", err)
+		}
+	}
+	if tooSoon.Load() {
+		t.Error("posted before the rate limit bucket reset")
+	}
+}
+
+func TestDiscordClient_GivesUp(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c := newDiscordClient(nil)
+	c.retryDelay = 0
+	if err := c.post(context.Background(), srv.URL, newMessage(nil)); err == nil {
+		t.Fatal("post succeeded, want an error")
+	}
+	if calls.Load() != discordMaxAttempts {
+		t.Errorf("got %d requests, want %d", calls.Load(), discordMaxAttempts)
+	}
+}
+
+func TestDiscordClient_ClientErrorNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, `{"message": "Unknown Webhook", "code": 10015}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	err := newDiscordClient(nil).post(context.Background(), srv.URL, newMessage(nil))
+	if err == nil || !strings.Contains(err.Error(), "Unknown Webhook") {
+		t.Errorf("err = %v, want Discord's message", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("got %d requests, want 1", calls.Load())
+	}
+}
+
+func TestPackMessages(t *testing.T) {
+	var embeds []discordEmbed
+	for range 25 {
+		embeds = append(embeds, discordEmbed{Title: "t"})
+	}
+	msgs := packMessages(embeds)
+	if len(msgs) != 3 || len(msgs[0].Embeds) != 10 || len(msgs[2].Embeds) != 5 {
+		t.Errorf("25 embeds packed as %d messages", len(msgs))
+	}
+
+	big := discordEmbed{Description: strings.Repeat("x", 4000)}
+	msgs = packMessages([]discordEmbed{big, big})
+	if len(msgs) != 2 {
+		t.Errorf("embeds over %d characters packed as %d messages, want 2", discordMaxEmbedChars, len(msgs))
+	}
+	if msgs[0].AllowedMentions.Parse == nil {
+		t.Error("allowed_mentions.parse is nil; it must be an empty list to suppress mentions")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("héllo", 5); got != "héllo" {
+		t.Errorf("truncate = %q", got)
+	}
+	if got := truncate("héllo world", 5); got != "héll…" {
+		t.Errorf("truncate = %q", got)
+	}
+}
+
+func TestWebhookName(t *testing.T) {
+	got := webhookName("https://discord.com/api/webhooks/123/s3cr3t-token")
+	if got != "discord.com/api/webhooks/123/…" {
+		t.Errorf("webhookName = %q", got)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Minute, "less than an hour"},
+		{5 * time.Hour, "5h"},
+		{48 * time.Hour, "2d"},
+		{76 * time.Hour, "3d 4h"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package notify
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/mail"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// The email digest is a report on the whole operation rather than a list of
+// alerts: it is built from the queries behind the dashboard — the blueprint
+// table, the slot usage of the summary bar, the sync status and the
+// blueprint change log — at the time it is sent.
+
+// kindEmailDigest is the notification_log kind of email digests; the subject
+// is the local date.
+const kindEmailDigest = "email_digest"
+
+// finishingWithin is how far ahead the digest lists jobs about to finish.
+const finishingWithin = 24 * time.Hour
+
+// EmailOptions configures a Mailer.
+type EmailOptions struct {
+	SMTP SMTPServer
+	From *mail.Address
+	To   []*mail.Address
+	// Weekly sends the digest on Weekday only, covering the past 7 days
+	// instead of the past day.
+	Weekly  bool
+	Weekday time.Weekday
+	// Hour is the hour of the day, in Location, the digest is sent at.
+	Hour     int
+	Location *time.Location
+	// ResearchSlots is the number of research jobs a character can run. Zero
+	// leaves free slots out of the digest.
+	ResearchSlots int64
+}
+
+// Mailer emails the digest. Create one with NewMailer and start it with Run.
+type Mailer struct {
+	q    store.Querier
+	smtp *smtpClient
+	opts EmailOptions
+	now  func() time.Time
+}
+
+// NewMailer creates a Mailer that reads from q.
+func NewMailer(q store.Querier, opts EmailOptions) *Mailer {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	return &Mailer{
+		q:    q,
+		smtp: &smtpClient{server: opts.SMTP},
+		opts: opts,
+		now:  time.Now,
+	}
+}
+
+// Run sends the digest whenever it is due until ctx is canceled. A digest
+// missed while Auspex was not running is sent at startup if it is still the
+// day it was due.
+func (m *Mailer) Run(ctx context.Context) {
+	m.sendDigest(ctx)
+	timer := time.NewTimer(m.untilDigest())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			m.sendDigest(ctx)
+			timer.Reset(m.untilDigest())
+		}
+	}
+}
+
+// untilDigest returns the time left until the next digest is due.
+func (m *Mailer) untilDigest() time.Duration {
+	now := m.now().In(m.opts.Location)
+	next := time.Date(now.Year(), now.Month(), now.Day(), m.opts.Hour, 0, 0, 0, m.opts.Location)
+	for !next.After(now) || !m.sendsOn(next) {
+		next = time.Date(next.Year(), next.Month(), next.Day()+1, m.opts.Hour, 0, 0, 0, m.opts.Location)
+	}
+	return next.Sub(now)
+}
+
+func (m *Mailer) sendsOn(day time.Time) bool {
+	return !m.opts.Weekly || day.Weekday() == m.opts.Weekday
+}
+
+// sendDigest emails today's digest, unless it is not due yet or was sent
+// already.
+func (m *Mailer) sendDigest(ctx context.Context) {
+	now := m.now().In(m.opts.Location)
+	if !m.sendsOn(now) || now.Hour() < m.opts.Hour {
+		return
+	}
+	date := now.Format(time.DateOnly)
+	sent, err := notificationSent(ctx, m.q, kindEmailDigest, date)
+	if err != nil {
+		log.Printf("notify: %v", err)
+		return
+	}
+	if sent {
+		return
+	}
+
+	report, err := m.report(ctx, now)
+	if err != nil {
+		log.Printf("notify: email digest: %v", err)
+		return
+	}
+	msg, err := m.message(report, now)
+	if err != nil {
+		log.Printf("notify: email digest: %v", err)
+		return
+	}
+	// Recorded before it is sent, like the alerts, so that a mail server
+	// that is down does not get the digest retried every few minutes.
+	if err := m.q.RecordNotification(ctx, store.RecordNotificationParams{
+		Kind: kindEmailDigest, Subject: date, SentAt: now.UTC(),
+	}); err != nil {
+		log.Printf("notify: recording email digest: %v", err)
+		return
+	}
+	to := make([]string, len(m.opts.To))
+	for i, a := range m.opts.To {
+		to[i] = a.Address
+	}
+	if err := m.smtp.send(ctx, m.opts.From.Address, to, msg); err != nil {
+		log.Printf("notify: sending email digest via %s: %v", m.opts.SMTP.Host, err)
+		return
+	}
+	log.Printf("notify: emailed the %s digest to %d recipient(s)", date, len(to))
+}
+
+// report is the content of a digest.
+type report struct {
+	Title   string
+	Period  string // covered by Added and Removed, e.g. "the past day"
+	Summary []reportCount
+
+	Ready      []reportJob
+	Finishing  []reportJob // within finishingWithin
+	Idle       []reportOwner
+	Slots      []reportSlots // characters with slots free
+	SyncErrors []reportSyncError
+	Added      []reportChange
+	Removed    []reportChange
+}
+
+type reportCount struct {
+	Label string
+	Count int
+}
+
+type reportJob struct {
+	Blueprint, Activity, Installer, Owner, Location, End string
+
+	end time.Time
+}
+
+type reportOwner struct {
+	Name  string
+	Count int
+}
+
+type reportSlots struct {
+	Name              string
+	Used, Free, Slots int64
+}
+
+type reportSyncError struct {
+	Owner, Endpoint, Error string
+}
+
+type reportChange struct {
+	Blueprint, Owner, Time string
+}
+
+// report gathers the digest sent at now.
+func (m *Mailer) report(ctx context.Context, now time.Time) (report, error) {
+	r := report{Title: "Auspex daily digest — " + now.Format("Monday 2 January 2006"), Period: "the past day"}
+	since := now.Add(-24 * time.Hour)
+	if m.opts.Weekly {
+		r.Title = "Auspex weekly digest — " + now.Format("Monday 2 January 2006")
+		r.Period = "the past week"
+		since = now.AddDate(0, 0, -7)
+	}
+
+	rows, err := m.q.ListBlueprints(ctx, store.ListBlueprintsParams{})
+	if err != nil {
+		return r, fmt.Errorf("listing blueprints: %w", err)
+	}
+	idle := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		if !row.JobID.Valid {
+			idle[row.OwnerName]++
+			continue
+		}
+		end := row.JobEndDate.Time
+		switch {
+		case row.JobStatus.String == "ready" || !end.After(now):
+			r.Ready = append(r.Ready, m.reportJob(row))
+		case end.Sub(now) <= finishingWithin:
+			r.Finishing = append(r.Finishing, m.reportJob(row))
+		}
+	}
+	byEnd := func(a, b reportJob) int { return a.end.Compare(b.end) }
+	slices.SortStableFunc(r.Ready, byEnd)
+	slices.SortStableFunc(r.Finishing, byEnd)
+	idleCount := 0
+	for name, n := range idle {
+		r.Idle = append(r.Idle, reportOwner{Name: name, Count: n})
+		idleCount += n
+	}
+	slices.SortFunc(r.Idle, func(a, b reportOwner) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Name, b.Name))
+	})
+
+	freeCount := int64(0)
+	if m.opts.ResearchSlots > 0 {
+		usage, err := m.q.ListCharacterSlotUsage(ctx)
+		if err != nil {
+			return r, fmt.Errorf("listing slot usage: %w", err)
+		}
+		for _, u := range usage {
+			if free := m.opts.ResearchSlots - u.UsedSlots; free > 0 {
+				r.Slots = append(r.Slots, reportSlots{Name: u.Name, Used: u.UsedSlots, Free: free, Slots: m.opts.ResearchSlots})
+				freeCount += free
+			}
+		}
+	}
+
+	status, err := m.q.ListSyncStatus(ctx)
+	if err != nil {
+		return r, fmt.Errorf("listing sync status: %w", err)
+	}
+	for _, s := range status {
+		if s.LastError.Valid && s.LastError.String != "" {
+			r.SyncErrors = append(r.SyncErrors, reportSyncError{Owner: s.OwnerName, Endpoint: s.Endpoint, Error: s.LastError.String})
+		}
+	}
+
+	changes, err := m.q.ListBlueprintEventsSince(ctx, since.UTC())
+	if err != nil {
+		return r, fmt.Errorf("listing blueprint changes: %w", err)
+	}
+	for _, c := range changes {
+		change := reportChange{Blueprint: c.TypeName, Owner: c.OwnerName, Time: m.formatTime(c.CreatedAt)}
+		switch c.Event {
+		case "added":
+			r.Added = append(r.Added, change)
+		case "removed":
+			r.Removed = append(r.Removed, change)
+		}
+	}
+
+	r.Summary = []reportCount{
+		{"Jobs ready", len(r.Ready)},
+		{"Finishing within 24 hours", len(r.Finishing)},
+		{"Idle blueprints", idleCount},
+	}
+	if m.opts.ResearchSlots > 0 {
+		r.Summary = append(r.Summary, reportCount{"Free research slots", int(freeCount)})
+	}
+	r.Summary = append(r.Summary,
+		reportCount{"Sync errors", len(r.SyncErrors)},
+		reportCount{"Blueprints added", len(r.Added)},
+		reportCount{"Blueprints removed", len(r.Removed)},
+	)
+	return r, nil
+}
+
+func (m *Mailer) reportJob(row *store.ListBlueprintsRow) reportJob {
+	return reportJob{
+		Blueprint: row.TypeName,
+		Activity:  events.ActivityLabel(row.JobActivity.String),
+		Installer: row.JobInstallerName.String,
+		Owner:     row.OwnerName,
+		Location:  locationName(row),
+		End:       m.formatTime(row.JobEndDate.Time),
+		end:       row.JobEndDate.Time,
+	}
+}
+
+// formatTime formats t in the digest's time zone.
+func (m *Mailer) formatTime(t time.Time) string {
+	return t.In(m.opts.Location).Format("2006-01-02 15:04 MST")
+}
+
+// message renders r as an email.
+func (m *Mailer) message(r report, now time.Time) ([]byte, error) {
+	var text, html strings.Builder
+	if err := textDigest.Execute(&text, r); err != nil {
+		return nil, fmt.Errorf("rendering text: %w", err)
+	}
+	if err := htmlDigest.Execute(&html, r); err != nil {
+		return nil, fmt.Errorf("rendering HTML: %w", err)
+	}
+	return email{
+		From:    m.opts.From,
+		To:      m.opts.To,
+		Subject: r.Title,
+		Date:    now,
+		Text:    text.String(),
+		HTML:    html.String(),
+	}.bytes()
+}
+
+// SendAlert emails a plain alert, one line per line of text, to the digest
+// recipients. It is for alerts raised outside the Mailer, such as those of
+// alert rules.
+func (m *Mailer) SendAlert(ctx context.Context, subject, text string) error {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		lines[i] = htmltemplate.HTMLEscapeString(l)
+	}
+	msg, err := email{
+		From:    m.opts.From,
+		To:      m.opts.To,
+		Subject: subject,
+		Date:    m.now(),
+		Text:    text + "\n",
+		HTML:    "<p>" + strings.Join(lines, "<br>\n") + "</p>\n",
+	}.bytes()
+	if err != nil {
+		return err
+	}
+	to := make([]string, len(m.opts.To))
+	for i, a := range m.opts.To {
+		to[i] = a.Address
+	}
+	if err := m.smtp.send(ctx, m.opts.From.Address, to, msg); err != nil {
+		return fmt.Errorf("sending via %s: %w", m.opts.SMTP.Host, err)
+	}
+	return nil
+}
+
+var textDigest = template.Must(template.New("text").Funcs(template.FuncMap{"upper": strings.ToUpper}).Parse(`{{.Title}}
+{{range .Summary}}
+{{printf "%-28s" .Label}} {{.Count}}{{end}}
+{{with .Ready}}
+JOBS READY
+{{range .}}
+- {{.Blueprint}} — {{.Activity}} ({{.Installer}}), ready since {{.End}}{{with .Location}}
+  {{.}}{{end}}{{end}}
+{{end}}{{with .Finishing}}
+FINISHING WITHIN 24 HOURS
+{{range .}}
+- {{.End}}: {{.Blueprint}} — {{.Activity}} ({{.Installer}}){{end}}
+{{end}}{{with .Idle}}
+IDLE BLUEPRINTS
+{{range .}}
+- {{.Name}}: {{.Count}}{{end}}
+{{end}}{{with .Slots}}
+FREE RESEARCH SLOTS
+{{range .}}
+- {{.Name}}: {{.Free}} of {{.Slots}} free{{end}}
+{{end}}{{with .SyncErrors}}
+SYNC ERRORS
+{{range .}}
+- {{.Owner}} ({{.Endpoint}}): {{.Error}}{{end}}
+{{end}}{{with .Added}}
+BLUEPRINTS ADDED IN {{upper $.Period}}
+{{range .}}
+- {{.Blueprint}} ({{.Owner}}), {{.Time}}{{end}}
+{{end}}{{with .Removed}}
+BLUEPRINTS REMOVED IN {{upper $.Period}}
+{{range .}}
+- {{.Blueprint}} ({{.Owner}}), {{.Time}}{{end}}
+{{end}}`))
+
+var htmlDigest = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="font-family: sans-serif; font-size: 14px; color: #222;">
+<h1 style="font-size: 20px;">{{.Title}}</h1>
+<table cellpadding="4" style="border-collapse: collapse;">
+{{range .Summary}}<tr><td>{{.Label}}</td><td style="text-align: right;"><b>{{.Count}}</b></td></tr>
+{{end}}</table>
+{{with .Ready}}<h2 style="font-size: 16px;">Jobs ready</h2>
+<table cellpadding="4" style="border-collapse: collapse;">
+<tr style="text-align: left;"><th>Blueprint</th><th>Activity</th><th>Installer</th><th>Location</th><th>Ready since</th></tr>
+{{range .}}<tr><td>{{.Blueprint}}</td><td>{{.Activity}}</td><td>{{.Installer}}</td><td>{{.Location}}</td><td>{{.End}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Finishing}}<h2 style="font-size: 16px;">Finishing within 24 hours</h2>
+<table cellpadding="4" style="border-collapse: collapse;">
+<tr style="text-align: left;"><th>Ends</th><th>Blueprint</th><th>Activity</th><th>Installer</th><th>Location</th></tr>
+{{range .}}<tr><td>{{.End}}</td><td>{{.Blueprint}}</td><td>{{.Activity}}</td><td>{{.Installer}}</td><td>{{.Location}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Idle}}<h2 style="font-size: 16px;">Idle blueprints</h2>
+<table cellpadding="4" style="border-collapse: collapse;">
+{{range .}}<tr><td>{{.Name}}</td><td style="text-align: right;">{{.Count}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Slots}}<h2 style="font-size: 16px;">Free research slots</h2>
+<table cellpadding="4" style="border-collapse: collapse;">
+{{range .}}<tr><td>{{.Name}}</td><td>{{.Free}} of {{.Slots}} free</td></tr>
+{{end}}</table>
+{{end}}{{with .SyncErrors}}<h2 style="font-size: 16px;">Sync errors</h2>
+<table cellpadding="4" style="border-collapse: collapse;">
+{{range .}}<tr><td>{{.Owner}}</td><td>{{.Endpoint}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Added}}<h2 style="font-size: 16px;">Blueprints added in {{$.Period}}</h2>
+<table cellpadding="4" style="border-collapse: collapse;">
+{{range .}}<tr><td>{{.Blueprint}}</td><td>{{.Owner}}</td><td>{{.Time}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Removed}}<h2 style="font-size: 16px;">Blueprints removed in {{$.Period}}</h2>
+<table cellpadding="4" style="border-collapse: collapse;">
+{{range .}}<tr><td>{{.Blueprint}}</td><td>{{.Owner}}</td><td>{{.Time}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,359 @@
+package notify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	stdsync "sync"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// smtpStub is a local stand-in for a mail server. It speaks enough SMTP for
+// net/smtp, including STARTTLS and AUTH PLAIN, and records the messages sent.
+type smtpStub struct {
+	ln       net.Listener
+	tls      *tls.Config
+	roots    *x509.CertPool
+	startTLS bool // advertise STARTTLS
+
+	mu       stdsync.Mutex
+	messages []smtpMessage
+}
+
+type smtpMessage struct {
+	from string
+	to   []string
+	auth string // decoded AUTH PLAIN response
+	tls  bool
+	data string
+}
+
+func newSMTPStub(t *testing.T, startTLS bool) *smtpStub {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpStub{
+		ln:       ln,
+		tls:      &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}, MinVersion: tls.VersionTLS12},
+		roots:    roots,
+		startTLS: startTLS,
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+// client returns an smtpClient for the stub that trusts its certificate.
+func (s *smtpStub) client(username, password string) *smtpClient {
+	return &smtpClient{
+		server: SMTPServer{
+			Host:     "127.0.0.1",
+			Port:     s.ln.Addr().(*net.TCPAddr).Port,
+			Security: SMTPStartTLS,
+			Username: username,
+			Password: password,
+		},
+		tls: &tls.Config{RootCAs: s.roots, ServerName: "127.0.0.1", MinVersion: tls.VersionTLS12},
+	}
+}
+
+func (s *smtpStub) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	tp := textproto.NewConn(conn)
+	var msg smtpMessage
+	reply := func(format string, args ...any) bool { return tp.PrintfLine(format, args...) == nil }
+	if !reply("220 127.0.0.1 ESMTP stub") {
+		return
+	}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO", "HELO":
+			if s.startTLS && !msg.tls {
+				reply("250-127.0.0.1\r\n250-STARTTLS\r\n250 AUTH PLAIN")
+			} else {
+				reply("250-127.0.0.1\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			reply("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tls)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, msg.tls = tlsConn, true
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			b, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			msg.auth = string(b)
+			reply("235 Authenticated")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			reply("250 OK")
+		case "RCPT":
+			msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 Queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Unknown command")
+		}
+	}
+}
+
+func (s *smtpStub) sent() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+func TestSMTPClient_StartTLSAndAuth(t *testing.T) {
+	stub := newSMTPStub(t, true)
+	msg := []byte("Subject: test\r\n\r\nHello\r\n")
+	err := stub.client("auspex", "s3cret").send(context.Background(), "auspex@example.com", []string{"a@example.com", "b@example.com"}, msg)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	sent := stub.sent()
+	if len(sent) != 1 {
+		t.Fatalf("got %d messages, want 1", len(sent))
+	}
+	got := sent[0]
+	if !got.tls {
+		t.Error("message sent without STARTTLS")
+	}
+	if got.auth != "\x00auspex\x00s3cret" {
+		t.Errorf("auth = %q", got.auth)
+	}
+	if got.from != "auspex@example.com" || strings.Join(got.to, ",") != "a@example.com,b@example.com" {
+		t.Errorf("envelope = %s → %v", got.from, got.to)
+	}
+	if !strings.Contains(got.data, "Hello") {
+		t.Errorf("data = %q", got.data)
+	}
+}
+
+func TestSMTPClient_StartTLSRequired(t *testing.T) {
+	stub := newSMTPStub(t, false)
+	err := stub.client("auspex", "s3cret").send(context.Background(), "auspex@example.com", []string{"a@example.com"}, []byte("x\r\n"))
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("err = %v, want STARTTLS refused", err)
+	}
+	if len(stub.sent()) != 0 {
+		t.Error("message sent over an unencrypted connection")
+	}
+}
+
+func newTestMailer(t *testing.T, q store.Querier, stub *smtpStub, clock *testClock, opts EmailOptions) *Mailer {
+	t.Helper()
+	opts.From = &mail.Address{Name: "Auspex", Address: "auspex@example.com"}
+	opts.To = []*mail.Address{{Address: "pilot@example.com"}}
+	m := NewMailer(q, opts)
+	m.smtp = stub.client("", "")
+	m.now = clock.now
+	return m
+}
+
+// readDigest parses an email digest and returns its subject and the text and
+// HTML parts.
+func readDigest(t *testing.T, data string) (subject, text, html string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decoding subject: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q (%v)", msg.Header.Get("Content-Type"), err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading part: %v", err)
+		}
+		body, err := io.ReadAll(p) // quoted-printable, decoded by the reader
+		if err != nil {
+			t.Fatalf("decoding part: %v", err)
+		}
+		switch p.Header.Get("Content-Type") {
+		case "text/plain; charset=utf-8":
+			text = string(body)
+		case "text/html; charset=utf-8":
+			html = string(body)
+		}
+	}
+	return subject, text, html
+}
+
+func TestMailer_Digest(t *testing.T) {
+	sqlDB := newTestDB(t)
+	seedCharacter(t, sqlDB, 1001, "Builder")
+	seedCharacter(t, sqlDB, 1002, "Copier <Alt>")
+	now := time.Date(2026, 10, 19, 6, 30, 0, 0, time.UTC) // 08:30 in Berlin
+	seedBlueprint(t, sqlDB, 5001, 1001)
+	seedJob(t, sqlDB, 7001, 5001, 1001, "active", now.Add(-2*time.Hour))
+	seedBlueprint(t, sqlDB, 5002, 1001)
+	seedJob(t, sqlDB, 7002, 5002, 1001, "active", now.Add(6*time.Hour))
+	seedBlueprint(t, sqlDB, 5003, 1001)
+	seedJob(t, sqlDB, 7003, 5003, 1001, "active", now.Add(72*time.Hour))
+	seedBlueprint(t, sqlDB, 5004, 1002)
+	seedBlueprint(t, sqlDB, 5005, 1002)
+	mustExec(t, sqlDB,
+		`INSERT INTO sync_state (owner_type, owner_id, endpoint, last_sync, cache_until, last_error)
+		 VALUES ('character', 1002, 'jobs', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'ESI responded 502')`)
+	mustExec(t, sqlDB,
+		`INSERT INTO blueprint_events (blueprint_id, owner_type, owner_id, type_id, event, created_at)
+		 VALUES (5005, 'character', 1002, 1, 'added', ?), (5099, 'character', 1002, 1, 'removed', ?), (5098, 'character', 1002, 1, 'removed', ?)`,
+		now.Add(-time.Hour), now.Add(-3*time.Hour), now.Add(-48*time.Hour))
+
+	stub := newSMTPStub(t, true)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("loading time zone: %v", err)
+	}
+	clock := &testClock{now.Add(-time.Hour)}
+	m := newTestMailer(t, store.New(sqlDB), stub, clock, EmailOptions{Hour: 8, Location: berlin, ResearchSlots: 3})
+	ctx := context.Background()
+
+	m.sendDigest(ctx)
+	if len(stub.sent()) != 0 {
+		t.Fatal("digest sent before its hour")
+	}
+	clock.advance(time.Hour)
+	m.sendDigest(ctx)
+	m.sendDigest(ctx)
+	sent := stub.sent()
+	if len(sent) != 1 {
+		t.Fatalf("got %d digests, want 1", len(sent))
+	}
+
+	subject, text, html := readDigest(t, sent[0].data)
+	if subject != "Auspex daily digest — Monday 19 October 2026" {
+		t.Errorf("subject = %q", subject)
+	}
+	for _, want := range []string{
+		"Jobs ready                   1",
+		"Finishing within 24 hours    1",
+		"Idle blueprints              2",
+		"Free research slots          3", // Builder runs 3 jobs of 3, Copier none
+		"Sync errors                  1",
+		"Blueprints added             1",
+		"Blueprints removed           1", // the other is older than a day
+		"- Rifter Blueprint — ME Research (Builder), ready since 2026-10-19 06:30 CEST",
+		"- 2026-10-19 14:30 CEST: Rifter Blueprint — ME Research (Builder)",
+		"- Copier <Alt>: 2",
+		"- Copier <Alt>: 3 of 3 free",
+		"- Copier <Alt> (jobs): ESI responded 502",
+		"BLUEPRINTS REMOVED IN THE PAST DAY",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text part lacks %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "Builder: 0") {
+		t.Error("text part lists Builder, who has no slot free")
+	}
+	if !strings.Contains(html, "<h2 style=\"font-size: 16px;\">Sync errors</h2>") || !strings.Contains(html, "Copier &lt;Alt&gt;") {
+		t.Errorf("HTML part:\n%s", html)
+	}
+
+	clock.advance(24 * time.Hour)
+	m.sendDigest(ctx)
+	if len(stub.sent()) != 2 {
+		t.Errorf("got %d digests on the second day, want 2 in all", len(stub.sent()))
+	}
+}
+
+func TestMailer_UntilDigest_Weekly(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("loading time zone: %v", err)
+	}
+	// Monday 19 October 2026, 10:00 in Berlin.
+	clock := &testClock{time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)}
+	m := NewMailer(nil, EmailOptions{Weekly: true, Weekday: time.Monday, Hour: 8, Location: berlin})
+	m.now = clock.now
+
+	// Next Monday 08:00 CET: the clocks go back on 25 October.
+	if d := m.untilDigest(); d != 7*24*time.Hour-time.Hour {
+		t.Errorf("untilDigest = %v, want 6d23h", d)
+	}
+	m.opts.Weekday = time.Tuesday
+	if d := m.untilDigest(); d != 22*time.Hour {
+		t.Errorf("untilDigest = %v, want 22h", d)
+	}
+	m.sendDigest(context.Background()) // not the day: must not query anything
+}
@@ -0,0 +1,533 @@
+// Package notify posts alerts about the blueprint library to Discord, and
+// emails a digest of it.
+//
+// A Notifier listens on the events bus. During a sync cycle it collects the
+// jobs that became ready and the subjects that failed to sync; when the cycle
+// finishes it also looks for blueprints idle beyond a threshold and characters
+// with free research slots. Alerts already sent are recorded in
+// notification_log once posted and dropped, so that repeated cycles and
+// restarts do not repeat them; an alert a webhook failed to take is retried
+// next cycle. Webhooks can take a daily digest instead of individual alerts.
+//
+// A Mailer emails a daily or weekly digest over SMTP, independently of the
+// Notifier.
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	stdsync "sync"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// Alert kinds, as named in the alerts list of a webhook.
+const (
+	KindJobReady      = "job_ready"      // a job became ready to deliver
+	KindFreeSlots     = "free_slots"     // a character has research slots free
+	KindIdleBlueprint = "idle_blueprint" // a blueprint has been idle longer than the threshold
+	KindSyncFailed    = "sync_failed"    // an ESI endpoint failed to sync for an owner
+	KindRule          = "rule"           // an alert rule matched; posted by package alert through Post
+)
+
+// Kinds lists every alert kind, in the order alerts are posted.
+var Kinds = []string{KindJobReady, KindSyncFailed, KindIdleBlueprint, KindFreeSlots, KindRule}
+
+// kindDigest is the notification_log kind of daily digests; the subject is
+// the date and the webhook it was posted to (see digestSubject).
+const kindDigest = "digest"
+
+// digestRetry is how long after a failed digest post it is tried again.
+const digestRetry = 10 * time.Minute
+
+// logRetention is how long sent alerts are remembered. A condition that still
+// holds after that is reported again.
+const logRetention = 30 * 24 * time.Hour
+
+// retryFor is how long an alert a webhook failed to take is retried, once a
+// cycle, before it is given up on.
+const retryFor = 24 * time.Hour
+
+// Alert is one notification.
+type Alert struct {
+	Kind    string
+	Subject string // identifies the alert within its kind, e.g. "job:123"
+	Title   string
+	Summary string // one-line form, used in digests and grouped alerts
+	Text    string // optional longer text
+	Fields  []Field
+	Time    time.Time // when the condition arose
+}
+
+// Field is a named value shown with an alert.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Webhook is a Discord webhook alerts are posted to.
+type Webhook struct {
+	URL    string
+	Kinds  []string // alert kinds to post; every kind if empty
+	Digest bool     // post a daily digest instead of individual alerts
+}
+
+func (wh Webhook) wants(kind string) bool {
+	if len(wh.Kinds) == 0 {
+		return true
+	}
+	for _, k := range wh.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configures a Notifier.
+type Options struct {
+	Webhooks []Webhook
+	// IdleAfter is how long a blueprint must have been idle to be reported.
+	// Zero disables idle blueprint alerts.
+	IdleAfter time.Duration
+	// ResearchSlots is the number of research jobs a character can run. Zero
+	// disables free slot alerts.
+	ResearchSlots int64
+	// DigestHour is the hour of the day (UTC) digests are posted at.
+	DigestHour int
+}
+
+// Notifier turns sync events into alerts. Create one with New and start it
+// with Run.
+type Notifier struct {
+	q       store.Querier
+	discord *discordClient
+	opts    Options
+	now     func() time.Time
+
+	// Collected during the current sync cycle.
+	ready    []events.Job
+	failures map[string]events.Subject // keyed by syncSubject
+
+	// owed holds, by webhook URL, the alerts the webhook failed to take,
+	// keyed by alertKey. They are retried by the next cycles.
+	owed map[string]map[string]owedAlert
+}
+
+// owedAlert is an alert a webhook failed to take since since.
+type owedAlert struct {
+	alert Alert
+	since time.Time
+}
+
+// New creates a Notifier that reads from q and posts with httpClient.
+func New(q store.Querier, httpClient *http.Client, opts Options) *Notifier {
+	return &Notifier{
+		q:        q,
+		discord:  newDiscordClient(httpClient),
+		opts:     opts,
+		now:      time.Now,
+		failures: make(map[string]events.Subject),
+		owed:     make(map[string]map[string]owedAlert),
+	}
+}
+
+// Run handles the events published on bus until ctx is canceled or the bus
+// is closed, and posts the daily digest when it is due.
+func (n *Notifier) Run(ctx context.Context, bus *events.Bus) {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg stdsync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		n.runDigest(ctx)
+	}()
+
+	events.Follow(ctx, bus, func(e events.Event) { n.handle(ctx, e) }, func() {
+		log.Printf("notify: missed sync events; alerts of this cycle may be incomplete")
+	})
+}
+
+// runDigest posts the daily digest when it is due, until ctx is canceled. A
+// digest a webhook did not take is posted to it again after digestRetry.
+func (n *Notifier) runDigest(ctx context.Context) {
+	// Catch up on today's digest if it was missed.
+	wait := n.untilDigest()
+	if !n.sendDigest(ctx) {
+		wait = digestRetry
+	}
+	digest := time.NewTimer(wait)
+	defer digest.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-digest.C:
+			if n.sendDigest(ctx) {
+				digest.Reset(n.untilDigest())
+			} else {
+				digest.Reset(digestRetry)
+			}
+		}
+	}
+}
+
+// handle records e and sends the alerts of a cycle when it finishes.
+func (n *Notifier) handle(ctx context.Context, e events.Event) {
+	switch e.Type {
+	case events.TypeJobReady:
+		if j, ok := e.Data.(events.Job); ok {
+			n.ready = append(n.ready, j)
+		}
+	case events.TypeSubjectFailed:
+		if s, ok := e.Data.(events.Subject); ok {
+			n.failures[syncSubject(s.OwnerType, s.OwnerID, s.Endpoint)] = s
+		}
+	case events.TypeSubjectSynced:
+		if s, ok := e.Data.(events.Subject); ok {
+			key := syncSubject(s.OwnerType, s.OwnerID, s.Endpoint)
+			delete(n.failures, key)
+			// Recovered: the next failure is reported again.
+			n.forget(ctx, KindSyncFailed, key)
+		}
+	case events.TypeCycleFinished:
+		n.flush(ctx)
+	}
+}
+
+// flush sends the alerts of the cycle that just finished.
+func (n *Notifier) flush(ctx context.Context) {
+	now := n.now()
+	alerts, err := n.collect(ctx, now)
+	n.ready = nil
+	n.failures = make(map[string]events.Subject)
+	if err != nil {
+		log.Printf("notify: %v", err)
+		return
+	}
+
+	if err := n.deliver(ctx, n.unsent(ctx, alerts), now); err != nil {
+		log.Printf("notify: %v", err)
+	}
+
+	if err := n.q.DeleteNotificationsBefore(ctx, now.Add(-logRetention)); err != nil {
+		log.Printf("notify: pruning notification log: %v", err)
+	}
+}
+
+// collect returns every alert that holds at the end of a cycle, sent before
+// or not.
+func (n *Notifier) collect(ctx context.Context, now time.Time) ([]Alert, error) {
+	rows, err := n.q.ListBlueprints(ctx, store.ListBlueprintsParams{})
+	if err != nil {
+		return nil, fmt.Errorf("listing blueprints: %w", err)
+	}
+	byID := make(map[int64]*store.ListBlueprintsRow, len(rows))
+	for i := range rows {
+		byID[rows[i].ID] = &rows[i]
+	}
+
+	var alerts []Alert
+	for _, j := range n.ready {
+		alerts = append(alerts, jobAlert(j, byID[j.BlueprintID]))
+	}
+
+	if len(n.failures) > 0 {
+		names, err := n.ownerNames(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range n.failures {
+			name := names[ownerKey(s.OwnerType, s.OwnerID)]
+			alerts = append(alerts, syncFailedAlert(s.OwnerType, s.OwnerID, name, s.Endpoint, s.Error, now))
+		}
+	}
+
+	idle, err := n.idleBlueprints(ctx, byID, now)
+	if err != nil {
+		return nil, err
+	}
+	alerts = append(alerts, idle...)
+
+	free, full, err := n.freeSlots(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	alerts = append(alerts, free...)
+	for _, id := range full {
+		// No slot free any more: report the next time one is.
+		n.forget(ctx, KindFreeSlots, characterSubject(id))
+	}
+	return alerts, nil
+}
+
+// idleBlueprints updates blueprint_idle and returns an alert per blueprint
+// idle for longer than IdleAfter.
+func (n *Notifier) idleBlueprints(ctx context.Context, byID map[int64]*store.ListBlueprintsRow, now time.Time) ([]Alert, error) {
+	if n.opts.IdleAfter <= 0 {
+		return nil, nil
+	}
+	if err := n.q.MarkIdleBlueprints(ctx, now.UTC()); err != nil {
+		return nil, fmt.Errorf("marking idle blueprints: %w", err)
+	}
+	if err := n.q.ClearBusyBlueprints(ctx); err != nil {
+		return nil, fmt.Errorf("clearing busy blueprints: %w", err)
+	}
+	idle, err := n.q.ListIdleBlueprintsSince(ctx, now.Add(-n.opts.IdleAfter).UTC())
+	if err != nil {
+		return nil, fmt.Errorf("listing idle blueprints: %w", err)
+	}
+	var alerts []Alert
+	for _, b := range idle {
+		if row := byID[b.BlueprintID]; row != nil {
+			alerts = append(alerts, idleAlert(row, b.IdleSince, now))
+		}
+	}
+	return alerts, nil
+}
+
+// freeSlots returns an alert per character with research slots free, and the
+// IDs of the characters with none free.
+func (n *Notifier) freeSlots(ctx context.Context, now time.Time) (alerts []Alert, full []int64, err error) {
+	if n.opts.ResearchSlots <= 0 {
+		return nil, nil, nil
+	}
+	usage, err := n.q.ListCharacterSlotUsage(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing slot usage: %w", err)
+	}
+	for _, u := range usage {
+		if u.UsedSlots < n.opts.ResearchSlots {
+			alerts = append(alerts, freeSlotsAlert(u.ID, u.Name, u.UsedSlots, n.opts.ResearchSlots, now))
+		} else {
+			full = append(full, u.ID)
+		}
+	}
+	return alerts, full, nil
+}
+
+// ownerNames returns character and corporation names keyed by ownerKey.
+func (n *Notifier) ownerNames(ctx context.Context) (map[string]string, error) {
+	chars, err := n.q.ListCharacters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing characters: %w", err)
+	}
+	corps, err := n.q.ListCorporations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing corporations: %w", err)
+	}
+	names := make(map[string]string, len(chars)+len(corps))
+	for _, c := range chars {
+		names[ownerKey("character", c.ID)] = c.Name
+	}
+	for _, c := range corps {
+		names[ownerKey("corporation", c.ID)] = c.Name
+	}
+	return names, nil
+}
+
+// unsent returns the alerts not sent before, leaving out those a webhook
+// still owes: they are retried by deliver.
+func (n *Notifier) unsent(ctx context.Context, alerts []Alert) []Alert {
+	var out []Alert
+	for _, a := range alerts {
+		if n.isOwed(alertKey(a)) {
+			continue
+		}
+		sent, err := n.sent(ctx, a.Kind, a.Subject)
+		if err != nil {
+			log.Printf("notify: %v", err)
+			continue
+		}
+		if !sent {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// deliver posts alerts to every webhook that takes them individually, along
+// with the alerts each webhook failed to take in earlier cycles. An alert is
+// recorded in notification_log once no webhook owes it any more. A webhook
+// that fails owes all the alerts it was sent, and is sent them again next
+// cycle, for up to retryFor.
+func (n *Notifier) deliver(ctx context.Context, alerts []Alert, now time.Time) error {
+	due := make(map[string]Alert, len(alerts))
+	for _, a := range alerts {
+		due[alertKey(a)] = a
+	}
+	for _, owed := range n.owed {
+		for key, o := range owed {
+			due[key] = o.alert
+		}
+	}
+
+	var errs []error
+	for _, wh := range n.opts.Webhooks {
+		if wh.Digest {
+			continue
+		}
+		owed := n.owed[wh.URL]
+		var selected []Alert
+		for _, key := range slices.Sorted(maps.Keys(owed)) {
+			if now.Sub(owed[key].since) > retryFor {
+				log.Printf("notify: giving up on %s alert %s for Discord webhook %s", owed[key].alert.Kind, owed[key].alert.Subject, webhookName(wh.URL))
+				delete(owed, key)
+				continue
+			}
+			selected = append(selected, owed[key].alert)
+		}
+		for _, a := range alerts {
+			if wh.wants(a.Kind) {
+				selected = append(selected, a)
+			}
+		}
+		if err := n.postTo(ctx, wh, selected); err != nil {
+			errs = append(errs, err)
+			if owed == nil {
+				owed = make(map[string]owedAlert)
+				n.owed[wh.URL] = owed
+			}
+			for _, a := range selected {
+				if _, ok := owed[alertKey(a)]; !ok {
+					owed[alertKey(a)] = owedAlert{alert: a, since: now}
+				}
+			}
+			continue
+		}
+		delete(n.owed, wh.URL)
+	}
+
+	// Record even if ctx was canceled while posting.
+	ctx = context.WithoutCancel(ctx)
+	for key, a := range due {
+		if n.isOwed(key) {
+			continue
+		}
+		if err := n.q.RecordNotification(ctx, store.RecordNotificationParams{
+			Kind: a.Kind, Subject: a.Subject, SentAt: now.UTC(),
+		}); err != nil {
+			log.Printf("notify: recording %s alert %s: %v", a.Kind, a.Subject, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isOwed reports whether a webhook owes the alert with key.
+func (n *Notifier) isOwed(key string) bool {
+	for _, owed := range n.owed {
+		if _, ok := owed[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// alertKey identifies an alert by its kind and subject.
+func alertKey(a Alert) string {
+	return a.Kind + " " + a.Subject
+}
+
+// sent reports whether the alert kind/subject is in the notification log.
+func (n *Notifier) sent(ctx context.Context, kind, subject string) (bool, error) {
+	return notificationSent(ctx, n.q, kind, subject)
+}
+
+// notificationSent reports whether kind/subject is in the notification log.
+func notificationSent(ctx context.Context, q store.Querier, kind, subject string) (bool, error) {
+	_, err := q.GetNotification(ctx, store.GetNotificationParams{Kind: kind, Subject: subject})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("looking up %s alert %s: %w", kind, subject, err)
+	}
+	return true, nil
+}
+
+// forget removes kind/subject from the notification log, and from the alerts
+// webhooks owe: the condition no longer holds.
+func (n *Notifier) forget(ctx context.Context, kind, subject string) {
+	for _, owed := range n.owed {
+		delete(owed, alertKey(Alert{Kind: kind, Subject: subject}))
+	}
+	if err := n.q.DeleteNotification(ctx, store.DeleteNotificationParams{Kind: kind, Subject: subject}); err != nil {
+		log.Printf("notify: clearing %s alert %s: %v", kind, subject, err)
+	}
+}
+
+// Post sends alerts to every webhook that takes them individually. Unlike the
+// alerts of sync cycles, they are not recorded in notification_log: the
+// caller decides what is repeated. It returns an error if no webhook takes
+// them, or if any failed.
+func (n *Notifier) Post(ctx context.Context, alerts []Alert) error {
+	taken := false
+	for _, wh := range n.opts.Webhooks {
+		for _, a := range alerts {
+			taken = taken || (!wh.Digest && wh.wants(a.Kind))
+		}
+	}
+	if !taken {
+		return errors.New("no Discord webhook takes these alerts")
+	}
+	return n.post(ctx, alerts)
+}
+
+// post sends alerts to every webhook that takes them individually. A webhook
+// that fails is not sent the rest of the alerts; the others are.
+func (n *Notifier) post(ctx context.Context, alerts []Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, wh := range n.opts.Webhooks {
+		if wh.Digest {
+			continue
+		}
+		var selected []Alert
+		for _, a := range alerts {
+			if wh.wants(a.Kind) {
+				selected = append(selected, a)
+			}
+		}
+		if err := n.postTo(ctx, wh, selected); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// postTo sends alerts to wh, stopping at the first message that fails.
+func (n *Notifier) postTo(ctx context.Context, wh Webhook, alerts []Alert) error {
+	for _, msg := range alertMessages(alerts) {
+		if err := n.discord.post(ctx, wh.URL, msg); err != nil {
+			return fmt.Errorf("posting to Discord webhook %s: %w", webhookName(wh.URL), err)
+		}
+	}
+	return nil
+}
+
+func syncSubject(ownerType string, ownerID int64, endpoint string) string {
+	return ownerKey(ownerType, ownerID) + ":" + endpoint
+}
+
+func characterSubject(id int64) string {
+	return ownerKey("character", id)
+}
+
+func ownerKey(ownerType string, ownerID int64) string {
+	return ownerType + ":" + strconv.FormatInt(ownerID, 10)
+}
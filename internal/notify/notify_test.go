@@ -0,0 +1,529 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	stdsync "sync"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/db"
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// discordStub is a local stand-in for Discord webhooks that records the
+// messages posted to it.
+type discordStub struct {
+	*httptest.Server
+
+	mu       stdsync.Mutex
+	messages map[string][]discordMessage // by request path
+	down     map[string]bool             // request paths answered with 500
+}
+
+func newDiscordStub(t *testing.T) *discordStub {
+	t.Helper()
+	s := &discordStub{messages: make(map[string][]discordMessage), down: make(map[string]bool)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg discordMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.down[r.URL.Path] {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		s.messages[r.URL.Path] = append(s.messages[r.URL.Path], msg)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// hook returns the URL of a webhook named name.
+func (s *discordStub) hook(name string) string {
+	return s.URL + "/api/webhooks/1/" + name
+}
+
+// embeds returns the embeds posted to the webhook named name.
+func (s *discordStub) embeds(name string) []discordEmbed {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []discordEmbed
+	for _, m := range s.messages["/api/webhooks/1/"+name] {
+		out = append(out, m.Embeds...)
+	}
+	return out
+}
+
+// setDown makes the webhook named name fail, or work again.
+func (s *discordStub) setDown(name string, down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down["/api/webhooks/1/"+name] = down
+}
+
+func (s *discordStub) posts(name string) []discordMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.messages["/api/webhooks/1/"+name]
+}
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	sqlDB, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return sqlDB
+}
+
+func mustExec(t *testing.T, sqlDB *sql.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := sqlDB.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func seedCharacter(t *testing.T, sqlDB *sql.DB, id int64, name string) {
+	t.Helper()
+	mustExec(t, sqlDB,
+		`INSERT INTO characters (id, name, access_token, refresh_token, token_expiry, corporation_id, corporation_name)
+		 VALUES (?, ?, 'tok', 'rtok', ?, 0, '')`, id, name, time.Now().Add(time.Hour))
+}
+
+func seedBlueprint(t *testing.T, sqlDB *sql.DB, id, ownerID int64) {
+	t.Helper()
+	mustExec(t, sqlDB, `INSERT OR IGNORE INTO eve_categories (id, name) VALUES (1, 'Category')`)
+	mustExec(t, sqlDB, `INSERT OR IGNORE INTO eve_groups (id, category_id, name) VALUES (1, 1, 'Group')`)
+	mustExec(t, sqlDB, `INSERT OR IGNORE INTO eve_types (id, group_id, name) VALUES (1, 1, 'Rifter Blueprint')`)
+	mustExec(t, sqlDB,
+		`INSERT INTO blueprints (id, owner_type, owner_id, type_id, location_id, me_level, te_level, updated_at)
+		 VALUES (?, 'character', ?, 1, 0, 10, 20, CURRENT_TIMESTAMP)`, id, ownerID)
+}
+
+func seedJob(t *testing.T, sqlDB *sql.DB, id, blueprintID, ownerID int64, status string, end time.Time) {
+	t.Helper()
+	mustExec(t, sqlDB,
+		`INSERT INTO jobs (id, blueprint_id, owner_type, owner_id, installer_id, activity, status, start_date, end_date, updated_at)
+		 VALUES (?, ?, 'character', ?, ?, 'me_research', ?, ?, ?, CURRENT_TIMESTAMP)`,
+		id, blueprintID, ownerID, ownerID, status, end.Add(-time.Hour), end)
+}
+
+// testClock is a settable clock for Notifier.now.
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time          { return c.t }
+func (c *testClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestNotifier(sqlDB *sql.DB, clock *testClock, opts Options) *Notifier {
+	n := New(store.New(sqlDB), nil, opts)
+	n.now = clock.now
+	n.discord.retryDelay = 0
+	return n
+}
+
+func event(eventType string, data any) events.Event {
+	return events.Event{Type: eventType, Data: data}
+}
+
+func titles(embeds []discordEmbed) []string {
+	out := make([]string, len(embeds))
+	for i, e := range embeds {
+		out[i] = e.Title
+	}
+	return out
+}
+
+func TestNotifier_JobReady_SentOnce(t *testing.T) {
+	sqlDB := newTestDB(t)
+	seedCharacter(t, sqlDB, 1001, "Builder")
+	seedBlueprint(t, sqlDB, 5001, 1001)
+	end := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	seedJob(t, sqlDB, 7001, 5001, 1001, "ready", end)
+
+	discord := newDiscordStub(t)
+	clock := &testClock{end.Add(time.Minute)}
+	n := newTestNotifier(sqlDB, clock, Options{Webhooks: []Webhook{{URL: discord.hook("all")}}})
+	ctx := context.Background()
+	job := events.Job{JobID: 7001, BlueprintID: 5001, OwnerType: "character", OwnerID: 1001, InstallerID: 1001, Activity: "me_research", EndDate: end}
+
+	for range 2 {
+		n.handle(ctx, event(events.TypeJobReady, job))
+		n.handle(ctx, event(events.TypeCycleFinished, events.Cycle{}))
+	}
+
+	got := discord.embeds("all")
+	if len(got) != 1 {
+		t.Fatalf("got %d embeds, want the job reported once: %v", len(got), titles(got))
+	}
+	if got[0].Title != "Job ready: Rifter Blueprint" {
+		t.Errorf("title = %q", got[0].Title)
+	}
+	if got[0].Timestamp != "2026-10-19T12:00:00Z" {
+		t.Errorf("timestamp = %q", got[0].Timestamp)
+	}
+	fields := map[string]string{}
+	for _, f := range got[0].Fields {
+		fields[f.Name] = f.Value
+	}
+	if fields["Activity"] != "ME Research" || fields["Installer"] != "Builder" || fields["Owner"] != "Builder" {
+		t.Errorf("fields = %v", fields)
+	}
+}
+
+// TestNotifier_FailedPost_Retried verifies that an alert a webhook failed to
+// take is not recorded as sent: the next cycle posts it again to that webhook
+// only, and records it once it is taken.
+func TestNotifier_FailedPost_Retried(t *testing.T) {
+	sqlDB := newTestDB(t)
+	seedCharacter(t, sqlDB, 1001, "Builder")
+	seedBlueprint(t, sqlDB, 5001, 1001)
+	end := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	seedJob(t, sqlDB, 7001, 5001, 1001, "ready", end)
+
+	discord := newDiscordStub(t)
+	discord.setDown("flaky", true)
+	clock := &testClock{end.Add(time.Minute)}
+	n := newTestNotifier(sqlDB, clock, Options{Webhooks: []Webhook{
+		{URL: discord.hook("healthy")},
+		{URL: discord.hook("flaky")},
+	}})
+	ctx := context.Background()
+	job := events.Job{JobID: 7001, BlueprintID: 5001, OwnerType: "character", OwnerID: 1001, InstallerID: 1001, Activity: "me_research", EndDate: end}
+
+	n.handle(ctx, event(events.TypeJobReady, job))
+	n.handle(ctx, event(events.TypeCycleFinished, events.Cycle{}))
+	if sent, err := n.sent(ctx, KindJobReady, "job:7001"); err != nil || sent {
+		t.Fatalf("sent = %v, %v after a failed post; want false", sent, err)
+	}
+
+	discord.setDown("flaky", false)
+	for range 2 {
+		n.handle(ctx, event(events.TypeCycleFinished, events.Cycle{}))
+	}
+
+	if got := discord.embeds("healthy"); len(got) != 1 {
+		t.Errorf("healthy webhook got %d embeds, want 1: %v", len(got), titles(got))
+	}
+	if got := discord.embeds("flaky"); len(got) != 1 {
+		t.Errorf("flaky webhook got %d embeds, want 1 once it recovered: %v", len(got), titles(got))
+	}
+	if sent, err := n.sent(ctx, KindJobReady, "job:7001"); err != nil || !sent {
+		t.Errorf("sent = %v, %v after the retry; want true", sent, err)
+	}
+}
+
+func TestNotifier_SyncFailed_ReportedAgainAfterRecovery(t *testing.T) {
+	sqlDB := newTestDB(t)
+	seedCharacter(t, sqlDB, 1001, "Builder")
+	discord := newDiscordStub(t)
+	clock := &testClock{time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	n := newTestNotifier(sqlDB, clock, Options{Webhooks: []Webhook{{URL: discord.hook("all")}}})
+	ctx := context.Background()
+	failed := events.Subject{OwnerType: "character", OwnerID: 1001, Endpoint: "blueprints", Error: "ESI responded 502"}
+	synced := events.Subject{OwnerType: "character", OwnerID: 1001, Endpoint: "blueprints"}
+
+	cycle := func(e events.Event) {
+		n.handle(ctx, e)
+		n.handle(ctx, event(events.TypeCycleFinished, events.Cycle{}))
+	}
+	cycle(event(events.TypeSubjectFailed, failed))
+	cycle(event(events.TypeSubjectFailed, failed)) // still failing: not repeated
+	cycle(event(events.TypeSubjectSynced, synced))
+	cycle(event(events.TypeSubjectFailed, failed)) // failing again: reported
+
+	got := discord.embeds("all")
+	if len(got) != 2 {
+		t.Fatalf("got %d embeds, want 2: %v", len(got), titles(got))
+	}
+	if got[0].Title != "Sync failed: Builder (blueprints)" || got[0].Description != "ESI responded 502" {
+		t.Errorf("embed = %+v", got[0])
+	}
+}
+
+func TestNotifier_IdleBlueprint_AfterThreshold(t *testing.T) {
+	sqlDB := newTestDB(t)
+	seedCharacter(t, sqlDB, 1001, "Builder")
+	seedBlueprint(t, sqlDB, 5001, 1001)
+	seedBlueprint(t, sqlDB, 5002, 1001)
+	seedJob(t, sqlDB, 7002, 5002, 1001, "active", time.Now().Add(time.Hour))
+
+	discord := newDiscordStub(t)
+	clock := &testClock{time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	n := newTestNotifier(sqlDB, clock, Options{
+		Webhooks:  []Webhook{{URL: discord.hook("all")}},
+		IdleAfter: 24 * time.Hour,
+	})
+	ctx := context.Background()
+	finish := func() { n.handle(ctx, event(events.TypeCycleFinished, events.Cycle{})) }
+
+	finish() // 5001 first seen idle
+	clock.advance(23 * time.Hour)
+	finish()
+	if got := discord.embeds("all"); len(got) != 0 {
+		t.Fatalf("reported before the threshold: %v", titles(got))
+	}
+
+	clock.advance(2 * time.Hour)
+	finish()
+	finish()
+	got := discord.embeds("all")
+	if len(got) != 1 || got[0].Title != "Idle blueprint: Rifter Blueprint" {
+		t.Fatalf("got %v, want one idle alert", titles(got))
+	}
+
+	// A job started and delivered: the blueprint is idle again from then on.
+	seedJob(t, sqlDB, 7001, 5001, 1001, "active", clock.t)
+	finish()
+	mustExec(t, sqlDB, `DELETE FROM jobs WHERE id = 7001`)
+	finish()
+	clock.advance(25 * time.Hour)
+	finish()
+	if got := discord.embeds("all"); len(got) != 2 {
+		t.Errorf("got %v, want the new idle period reported", titles(got))
+	}
+}
+
+func TestNotifier_FreeSlots(t *testing.T) {
+	sqlDB := newTestDB(t)
+	seedCharacter(t, sqlDB, 1001, "Builder")
+	seedBlueprint(t, sqlDB, 5001, 1001)
+	discord := newDiscordStub(t)
+	clock := &testClock{time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	n := newTestNotifier(sqlDB, clock, Options{
+		Webhooks:      []Webhook{{URL: discord.hook("all")}},
+		ResearchSlots: 1,
+	})
+	ctx := context.Background()
+	finish := func() { n.handle(ctx, event(events.TypeCycleFinished, events.Cycle{})) }
+
+	finish()
+	finish()
+	got := discord.embeds("all")
+	if len(got) != 1 || got[0].Title != "Free research slots: Builder" {
+		t.Fatalf("got %v, want one free slot alert", titles(got))
+	}
+
+	seedJob(t, sqlDB, 7001, 5001, 1001, "active", clock.t.Add(time.Hour))
+	finish()
+	mustExec(t, sqlDB, `DELETE FROM jobs WHERE id = 7001`)
+	finish()
+	if got := discord.embeds("all"); len(got) != 2 {
+		t.Errorf("got %v, want the freed slot reported again", titles(got))
+	}
+}
+
+func TestNotifier_WebhookKinds(t *testing.T) {
+	sqlDB := newTestDB(t)
+	seedCharacter(t, sqlDB, 1001, "Builder")
+	discord := newDiscordStub(t)
+	clock := &testClock{time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	n := newTestNotifier(sqlDB, clock, Options{
+		Webhooks: []Webhook{
+			{URL: discord.hook("jobs"), Kinds: []string{KindJobReady}},
+			{URL: discord.hook("sync"), Kinds: []string{KindSyncFailed}},
+		},
+		ResearchSlots: 11,
+	})
+	ctx := context.Background()
+	n.handle(ctx, event(events.TypeJobReady, events.Job{JobID: 7001, BlueprintID: 5001, OwnerType: "character", OwnerID: 1001}))
+	n.handle(ctx, event(events.TypeCycleFinished, events.Cycle{}))
+
+	if got := discord.embeds("jobs"); len(got) != 1 || got[0].Title != "Job ready: Blueprint 5001" {
+		t.Errorf("jobs webhook got %v", titles(got))
+	}
+	if got := discord.posts("sync"); len(got) != 0 {
+		t.Errorf("sync webhook got %d messages, want none", len(got))
+	}
+}
+
+func TestNotifier_Post(t *testing.T) {
+	discord := newDiscordStub(t)
+	clock := &testClock{time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	a := Alert{Kind: KindRule, Subject: "rule:1", Title: "Copies ending soon", Text: "Rifter Blueprint (Main Corp)", Time: clock.t}
+	ctx := context.Background()
+
+	n := newTestNotifier(newTestDB(t), clock, Options{
+		Webhooks: []Webhook{{URL: discord.hook("jobs"), Kinds: []string{KindJobReady}}},
+	})
+	if err := n.Post(ctx, []Alert{a}); err == nil {
+		t.Error("Post with no webhook taking rule alerts: want error")
+	}
+
+	n = newTestNotifier(newTestDB(t), clock, Options{
+		Webhooks: []Webhook{
+			{URL: discord.hook("jobs"), Kinds: []string{KindJobReady}},
+			{URL: discord.hook("rules"), Kinds: []string{KindRule}},
+			{URL: discord.hook("digest"), Digest: true},
+		},
+	})
+	if err := n.Post(ctx, []Alert{a}); err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	if got := discord.embeds("rules"); len(got) != 1 || got[0].Title != "Copies ending soon" {
+		t.Errorf("rules webhook got %v", titles(got))
+	}
+	if got := len(discord.posts("jobs")) + len(discord.posts("digest")); got != 0 {
+		t.Errorf("other webhooks got %d messages, want none", got)
+	}
+}
+
+func TestNotifier_ManyAlertsListed(t *testing.T) {
+	sqlDB := newTestDB(t)
+	discord := newDiscordStub(t)
+	clock := &testClock{time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	n := newTestNotifier(sqlDB, clock, Options{Webhooks: []Webhook{{URL: discord.hook("all")}}})
+	ctx := context.Background()
+	for id := int64(1); id <= 25; id++ {
+		n.handle(ctx, event(events.TypeJobReady, events.Job{JobID: id, BlueprintID: id, OwnerType: "character", OwnerID: 1001}))
+	}
+	n.handle(ctx, event(events.TypeCycleFinished, events.Cycle{}))
+
+	got := discord.embeds("all")
+	if len(got) != 1 || got[0].Title != "Jobs ready (25)" {
+		t.Fatalf("got %v, want one list embed", titles(got))
+	}
+	if !strings.HasSuffix(got[0].Description, "…and 5 more") {
+		t.Errorf("description = %q", got[0].Description)
+	}
+}
+
+func TestNotifier_Digest_OncePerDay(t *testing.T) {
+	sqlDB := newTestDB(t)
+	seedCharacter(t, sqlDB, 1001, "Builder")
+	seedBlueprint(t, sqlDB, 5001, 1001)
+	seedJob(t, sqlDB, 7001, 5001, 1001, "active", time.Date(2026, 10, 19, 6, 0, 0, 0, time.UTC))
+	mustExec(t, sqlDB,
+		`INSERT INTO sync_state (owner_type, owner_id, endpoint, last_sync, cache_until, last_error)
+		 VALUES ('character', 1001, 'jobs', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'ESI responded 502')`)
+
+	discord := newDiscordStub(t)
+	clock := &testClock{time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)}
+	n := newTestNotifier(sqlDB, clock, Options{
+		Webhooks: []Webhook{
+			{URL: discord.hook("instant")},
+			{URL: discord.hook("digest"), Digest: true},
+		},
+		DigestHour: 9,
+	})
+	ctx := context.Background()
+
+	if d := n.untilDigest(); d != 30*time.Minute {
+		t.Errorf("untilDigest = %v, want 30m", d)
+	}
+	n.sendDigest(ctx)
+	if got := discord.posts("digest"); len(got) != 0 {
+		t.Fatalf("digest posted before its hour")
+	}
+
+	clock.advance(time.Hour)
+	n.sendDigest(ctx)
+	n.sendDigest(ctx)
+	posts := discord.posts("digest")
+	if len(posts) != 1 {
+		t.Fatalf("got %d digests, want 1", len(posts))
+	}
+	if posts[0].Content != "**Auspex daily digest — 2026-10-19**" {
+		t.Errorf("content = %q", posts[0].Content)
+	}
+	want := []string{"Jobs ready (1)", "Sync failures (1)"}
+	if got := titles(posts[0].Embeds); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("sections = %v, want %v", got, want)
+	}
+	if d := n.untilDigest(); d != 23*time.Hour+30*time.Minute {
+		t.Errorf("untilDigest = %v, want 23h30m", d)
+	}
+
+	// Digest webhooks get no individual alerts.
+	n.handle(ctx, event(events.TypeJobReady, events.Job{JobID: 7001, BlueprintID: 5001, OwnerType: "character", OwnerID: 1001}))
+	n.handle(ctx, event(events.TypeCycleFinished, events.Cycle{}))
+	if len(discord.posts("digest")) != 1 || len(discord.posts("instant")) != 1 {
+		t.Errorf("digest webhook got %d messages, instant webhook %d", len(discord.posts("digest")), len(discord.posts("instant")))
+	}
+
+	clock.advance(24 * time.Hour)
+	mustExec(t, sqlDB, `DELETE FROM jobs`)
+	mustExec(t, sqlDB, `UPDATE sync_state SET last_error = NULL`)
+	n.sendDigest(ctx)
+	posts = discord.posts("digest")
+	if len(posts) != 2 || posts[1].Embeds[0].Description != "Nothing needs attention." {
+		t.Errorf("second digest = %+v", posts[len(posts)-1])
+	}
+}
+
+// TestNotifier_Digest_FailedPostRetried verifies that a digest a webhook did
+// not take is not recorded for it: the next try posts it to that webhook
+// only.
+func TestNotifier_Digest_FailedPostRetried(t *testing.T) {
+	sqlDB := newTestDB(t)
+	discord := newDiscordStub(t)
+	discord.setDown("flaky", true)
+	clock := &testClock{time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)}
+	n := newTestNotifier(sqlDB, clock, Options{
+		Webhooks: []Webhook{
+			{URL: discord.hook("healthy"), Digest: true},
+			{URL: discord.hook("flaky"), Digest: true},
+		},
+		DigestHour: 9,
+	})
+	ctx := context.Background()
+
+	if n.sendDigest(ctx) {
+		t.Errorf("sendDigest = true with a webhook down, want false")
+	}
+	if got := discord.posts("flaky"); len(got) != 0 {
+		t.Fatalf("flaky webhook got %d digests while down", len(got))
+	}
+
+	discord.setDown("flaky", false)
+	clock.advance(digestRetry)
+	if !n.sendDigest(ctx) {
+		t.Errorf("sendDigest = false once the webhook recovered, want true")
+	}
+	n.sendDigest(ctx)
+
+	if got := discord.posts("healthy"); len(got) != 1 {
+		t.Errorf("healthy webhook got %d digests, want 1", len(got))
+	}
+	if got := discord.posts("flaky"); len(got) != 1 {
+		t.Errorf("flaky webhook got %d digests, want 1 once it recovered", len(got))
+	}
+}
+
+func TestNotifier_Run(t *testing.T) {
+	sqlDB := newTestDB(t)
+	discord := newDiscordStub(t)
+	n := New(store.New(sqlDB), nil, Options{Webhooks: []Webhook{{URL: discord.hook("all")}}})
+	bus := events.NewBus(events.DefaultHistorySize)
+
+	done := make(chan struct{})
+	go func() {
+		n.Run(context.Background(), bus)
+		close(done)
+	}()
+	for bus.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	bus.Publish(events.TypeJobReady, events.Job{JobID: 7001, BlueprintID: 5001, OwnerType: "character", OwnerID: 1001})
+	bus.Publish(events.TypeCycleFinished, events.Cycle{})
+	bus.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the bus was closed")
+	}
+	if got := discord.embeds("all"); len(got) != 1 {
+		t.Errorf("got %v, want the job alert", titles(got))
+	}
+}
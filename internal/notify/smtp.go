@@ -0,0 +1,186 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpTimeout bounds one conversation with the mail server.
+const smtpTimeout = time.Minute
+
+// SMTP connection security.
+const (
+	SMTPStartTLS = "starttls" // plain connection upgraded with STARTTLS, which is required
+	SMTPTLS      = "tls"      // implicit TLS, usually on port 465
+	SMTPNone     = "none"     // no encryption; for a relay on the same host
+)
+
+// SMTPServer is the mail server email is sent through.
+type SMTPServer struct {
+	Host     string
+	Port     int
+	Security string // SMTPStartTLS, SMTPTLS or SMTPNone
+	Username string // no authentication if empty
+	Password string
+}
+
+// smtpClient sends email through an SMTPServer.
+type smtpClient struct {
+	server SMTPServer
+	tls    *tls.Config // nil means the default, verified against server.Host
+}
+
+// send delivers msg from from to every address in to.
+func (c *smtpClient) send(ctx context.Context, from string, to []string, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	tlsConfig := c.tls
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: c.server.Host, MinVersion: tls.VersionTLS12}
+	}
+	addr := net.JoinHostPort(c.server.Host, strconv.Itoa(c.server.Port))
+	var conn net.Conn
+	var err error
+	if c.server.Security == SMTPTLS {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, c.server.Host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("greeting from %s: %w", addr, err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if c.server.Security == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS: %w", err)
+		}
+	}
+	if c.server.Username != "" {
+		// PlainAuth refuses to send the password over an unencrypted
+		// connection, except to localhost.
+		if err := client.Auth(smtp.PlainAuth("", c.server.Username, c.server.Password, c.server.Host)); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("MAIL FROM: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("RCPT TO %s: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+	return client.Quit()
+}
+
+// email is a message with a plain text and an HTML version of its body.
+type email struct {
+	From    *mail.Address
+	To      []*mail.Address
+	Subject string
+	Date    time.Time
+	Text    string
+	HTML    string
+}
+
+// bytes returns e as a MIME multipart/alternative message with CRLF line
+// endings, ready for SMTP DATA.
+func (e email) bytes() ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, p := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", e.Text}, // least preferred first
+		{"text/html; charset=utf-8", e.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(crlf(p.content))); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	to := make([]string, len(e.To))
+	for i, a := range e.To {
+		to[i] = a.String()
+	}
+	var msg bytes.Buffer
+	for _, h := range [][2]string{
+		{"From", e.From.String()},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", e.Subject)},
+		{"Date", e.Date.Format(time.RFC1123Z)},
+		{"Message-ID", messageID(e.From.Address)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + parts.Boundary()},
+	} {
+		msg.WriteString(h[0] + ": " + h[1] + "\r\n")
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// messageID returns a new Message-ID in the domain of the sender address.
+func messageID(from string) string {
+	domain := "auspex"
+	if i := strings.LastIndexByte(from, '@'); i >= 0 {
+		domain = from[i+1:]
+	}
+	return "<" + strings.ToLower(rand.Text()) + "@" + domain + ">"
+}
+
+// crlf returns s with CRLF line endings.
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
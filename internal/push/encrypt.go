@@ -0,0 +1,128 @@
+package push
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// recordSize is the record size in the aes128gcm header. Payloads are sent
+// as a single record, so it only has to exceed the payload.
+const recordSize = 4096
+
+// maxPayload is the largest payload that fits in a record: the record size
+// less the padding delimiter and the AEAD tag.
+const maxPayload = recordSize - 1 - 16
+
+// CheckKeys reports whether p256dh and auth, as they come from the browser,
+// are keys a notification can be encrypted for: an uncompressed P-256 point
+// and a 16-byte secret, in unpadded base64url.
+func CheckKeys(p256dh, auth string) error {
+	pub, err := b64.DecodeString(p256dh)
+	if err != nil {
+		return errors.New("p256dh is not base64url")
+	}
+	if _, err := ecdh.P256().NewPublicKey(pub); err != nil {
+		return errors.New("p256dh is not a P-256 public key")
+	}
+	secret, err := b64.DecodeString(auth)
+	if err != nil {
+		return errors.New("auth is not base64url")
+	}
+	if len(secret) != 16 {
+		return errors.New("auth must be 16 bytes")
+	}
+	return nil
+}
+
+// encrypt encrypts plaintext for a subscription with the client keys p256dh
+// and auth, as they come from the browser (RFC 8291), and returns the
+// aes128gcm-encoded body of the push request (RFC 8188).
+func encrypt(plaintext []byte, p256dh, auth string) ([]byte, error) {
+	uaPublic, err := b64.DecodeString(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("decoding p256dh: %w", err)
+	}
+	secret, err := b64.DecodeString(auth)
+	if err != nil {
+		return nil, fmt.Errorf("decoding auth: %w", err)
+	}
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encryptWith(plaintext, uaPublic, secret, asPrivate, salt)
+}
+
+// encryptWith is encrypt with a given application server key pair and salt,
+// which are random for every message.
+func encryptWith(plaintext, uaPublic, auth []byte, asPrivate *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	if len(plaintext) > maxPayload {
+		return nil, fmt.Errorf("payload of %d bytes exceeds %d", len(plaintext), maxPayload)
+	}
+	if len(auth) != 16 {
+		return nil, errors.New("auth secret must be 16 bytes")
+	}
+	uaKey, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("parsing p256dh: %w", err)
+	}
+	shared, err := asPrivate.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+
+	// RFC 8291 section 3.4.
+	prkKey, err := hkdf.Extract(sha256.New, shared, auth)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(uaPublic) + string(asPublic)
+	ikm, err := hkdf.Expand(sha256.New, prkKey, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt, record size, key ID length and key ID — the application
+	// server's public key (RFC 8188 section 2.1).
+	body := make([]byte, 0, 16+4+1+len(asPublic)+len(plaintext)+1+gcm.Overhead())
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, recordSize)
+	body = append(body, byte(len(asPublic)))
+	body = append(body, asPublic...)
+	// A single, last record: the plaintext with the delimiter 0x02.
+	record := append(append(make([]byte, 0, len(plaintext)+1), plaintext...), 2)
+	return gcm.Seal(body, nonce, record, nil), nil
+}
@@ -0,0 +1,37 @@
+package push
+
+import (
+	"crypto/ecdh"
+	"testing"
+)
+
+// TestEncryptWith_RFC8291 checks encryption against the example in RFC 8291
+// Appendix A.
+func TestEncryptWith_RFC8291(t *testing.T) {
+	decode := func(s string) []byte {
+		t.Helper()
+		b, err := b64.DecodeString(s)
+		if err != nil {
+			t.Fatalf("decoding %q: %v", s, err)
+		}
+		return b
+	}
+	asPrivate, err := ecdh.P256().NewPrivateKey(decode("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	body, err := encryptWith(
+		[]byte("When I grow up, I want to be a watermelon"),
+		decode("BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"),
+		decode("BTBZMqHH6r4Tts7J_aSIgg"),
+		asPrivate,
+		decode("DGv6ra1nlYgDCS1FRnbzlw"),
+	)
+	if err != nil {
+		t.Fatalf("encryptWith: %v", err)
+	}
+	const want = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	if got := b64.EncodeToString(body); got != want {
+		t.Errorf("body =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// Keys is the VAPID key pair the server identifies itself with to push
+// services (RFC 8292). Browsers tie a subscription to the public key, so it
+// must not change once subscriptions exist.
+type Keys struct {
+	private *ecdsa.PrivateKey
+	public  []byte // uncompressed P-256 point
+}
+
+// PublicKey returns the public key in the unpadded base64url form the
+// browser's PushManager.subscribe takes as applicationServerKey.
+func (k *Keys) PublicKey() string {
+	return b64.EncodeToString(k.public)
+}
+
+// b64 is the encoding of keys in the Web Push protocols and the store.
+var b64 = base64.RawURLEncoding
+
+// LoadKeys returns the VAPID keys in q, generating and storing them on first
+// use.
+func LoadKeys(ctx context.Context, q store.Querier) (*Keys, error) {
+	row, err := q.GetPushKeys(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		k, genErr := generateKeys()
+		if genErr != nil {
+			return nil, genErr
+		}
+		priv, _ := k.private.Bytes() // a generated key is valid
+		// Another caller may have stored keys first; keep theirs.
+		if err := q.InsertPushKeys(ctx, store.InsertPushKeysParams{
+			PrivateKey: b64.EncodeToString(priv),
+			PublicKey:  k.PublicKey(),
+			CreatedAt:  time.Now().UTC(),
+		}); err != nil {
+			return nil, fmt.Errorf("storing VAPID keys: %w", err)
+		}
+		row, err = q.GetPushKeys(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting VAPID keys: %w", err)
+	}
+	return parseKeys(row.PrivateKey)
+}
+
+func generateKeys() (*Keys, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating VAPID keys: %w", err)
+	}
+	return newKeys(priv)
+}
+
+// parseKeys returns the keys for a private key in base64url form.
+func parseKeys(private string) (*Keys, error) {
+	raw, err := b64.DecodeString(private)
+	if err != nil {
+		return nil, fmt.Errorf("decoding VAPID private key: %w", err)
+	}
+	priv, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing VAPID private key: %w", err)
+	}
+	return newKeys(priv)
+}
+
+func newKeys(priv *ecdsa.PrivateKey) (*Keys, error) {
+	pub, err := priv.PublicKey.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("encoding VAPID public key: %w", err)
+	}
+	return &Keys{private: priv, public: pub}, nil
+}
@@ -0,0 +1,283 @@
+// Package push sends Web Push notifications to the browsers subscribed
+// through the dashboard.
+//
+// A Service listens on the events bus. During a sync cycle it collects the
+// jobs that became ready; when the cycle finishes it also compares the
+// research jobs of each character with the previous cycle, to notice slots
+// that freed up. Notifications are encrypted for each subscription (RFC 8291)
+// and posted to its push service with a VAPID token (RFC 8292) signed with
+// the keys from LoadKeys. Subscriptions the push service reports gone are
+// deleted.
+package push
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	stdsync "sync"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+const (
+	// subject is the contact in VAPID tokens, for push service operators.
+	subject = "https://github.com/dpleshakov/auspex"
+
+	// ttl is how long a push service keeps a notification for a browser
+	// that is offline. Alerts older than a day are stale.
+	ttl = 24 * time.Hour
+
+	// maxJobNotifications is the number of ready jobs notified individually
+	// per cycle; more are summed up in one notification.
+	maxJobNotifications = 3
+
+	// requestTimeout bounds one push when New is given no HTTP client.
+	requestTimeout = 10 * time.Second
+)
+
+// Notification is the payload of a push, shown by the service worker.
+type Notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Tag   string `json:"tag,omitempty"` // replaces a shown notification with the same tag
+	URL   string `json:"url"`           // opened when the notification is clicked
+}
+
+// Result counts the subscriptions a notification was sent to.
+type Result struct {
+	Sent    int `json:"sent"`
+	Failed  int `json:"failed"`
+	Expired int `json:"expired"` // gone at the push service, and deleted
+}
+
+// Service sends notifications to the push subscriptions in the store. Create
+// one with New; Run sends them for sync events.
+type Service struct {
+	q    store.Querier
+	http *http.Client
+	now  func() time.Time
+
+	mu   stdsync.Mutex
+	keys *Keys // loaded on first send
+
+	// Used by Run only.
+	ready []events.Job
+	used  map[int64]int64 // research jobs by character at the end of the last cycle; nil before the first
+}
+
+// New creates a Service that reads subscriptions from q and pushes with
+// httpClient. A nil httpClient means a client with a 10 second timeout.
+func New(q store.Querier, httpClient *http.Client) *Service {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: requestTimeout}
+	}
+	return &Service{q: q, http: httpClient, now: time.Now}
+}
+
+// Run handles the events published on bus until ctx is canceled or the bus
+// is closed.
+func (s *Service) Run(ctx context.Context, bus *events.Bus) {
+	events.Follow(ctx, bus, func(e events.Event) { s.handle(ctx, e) }, func() {
+		log.Printf("push: missed sync events; notifications of this cycle may be incomplete")
+	})
+}
+
+// handle records e and sends the notifications of a cycle when it finishes.
+func (s *Service) handle(ctx context.Context, e events.Event) {
+	switch e.Type {
+	case events.TypeJobReady:
+		if j, ok := e.Data.(events.Job); ok {
+			s.ready = append(s.ready, j)
+		}
+	case events.TypeCycleFinished:
+		notes, err := s.collect(ctx)
+		s.ready = nil
+		if err != nil {
+			log.Printf("push: %v", err)
+			return
+		}
+		for _, n := range notes {
+			if _, err := s.Send(ctx, n); err != nil {
+				log.Printf("push: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// collect returns the notifications of the cycle that just finished.
+func (s *Service) collect(ctx context.Context) ([]Notification, error) {
+	var notes []Notification
+	if len(s.ready) > 0 {
+		// Only the first maxJobNotifications jobs are named.
+		names := make(map[int64]string)
+		for _, j := range s.ready[:min(len(s.ready), maxJobNotifications)] {
+			name, err := s.q.GetBlueprintTypeName(ctx, j.BlueprintID)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue // deleted since, or its type not resolved yet
+			}
+			if err != nil {
+				return nil, fmt.Errorf("getting name of blueprint %d: %w", j.BlueprintID, err)
+			}
+			names[j.BlueprintID] = name
+		}
+		notes = append(notes, jobNotifications(s.ready, names)...)
+	}
+
+	usage, err := s.q.ListCharacterSlotUsage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing slot usage: %w", err)
+	}
+	used := make(map[int64]int64, len(usage))
+	for _, u := range usage {
+		used[u.ID] = u.UsedSlots
+		if prev, ok := s.used[u.ID]; ok && u.UsedSlots < prev {
+			notes = append(notes, slotNotification(u.ID, u.Name, prev-u.UsedSlots))
+		}
+	}
+	s.used = used
+	return notes, nil
+}
+
+// jobNotifications returns a notification per ready job, or one for all of
+// them if there are more than maxJobNotifications. names are blueprint type
+// names by blueprint ID.
+func jobNotifications(jobs []events.Job, names map[int64]string) []Notification {
+	name := func(j events.Job) string {
+		if n, ok := names[j.BlueprintID]; ok {
+			return n
+		}
+		return "Blueprint " + strconv.FormatInt(j.BlueprintID, 10)
+	}
+	if len(jobs) > maxJobNotifications {
+		list := make([]string, 0, maxJobNotifications+1)
+		for _, j := range jobs[:maxJobNotifications] {
+			list = append(list, name(j))
+		}
+		list = append(list, fmt.Sprintf("and %d more", len(jobs)-maxJobNotifications))
+		return []Notification{{
+			Title: fmt.Sprintf("%d jobs ready", len(jobs)),
+			Body:  strings.Join(list, ", "),
+			Tag:   "jobs",
+			URL:   "/",
+		}}
+	}
+	notes := make([]Notification, len(jobs))
+	for i, j := range jobs {
+		notes[i] = Notification{
+			Title: "Job ready: " + name(j),
+			Body:  events.ActivityLabel(j.Activity) + " job is ready to deliver",
+			Tag:   "job:" + strconv.FormatInt(j.JobID, 10),
+			URL:   "/",
+		}
+	}
+	return notes
+}
+
+// slotNotification returns the notification for freed research slots of a
+// character.
+func slotNotification(id int64, name string, freed int64) Notification {
+	body := "A research slot is free"
+	if freed > 1 {
+		body = fmt.Sprintf("%d research slots are free", freed)
+	}
+	return Notification{
+		Title: "Slot free: " + name,
+		Body:  body,
+		Tag:   "slots:" + strconv.FormatInt(id, 10),
+		URL:   "/",
+	}
+}
+
+// Send pushes n to every subscription. Subscriptions the push service
+// reports gone are deleted; other failures are logged and counted. The
+// returned error is about reading the keys or subscriptions. It may be
+// called while Run is running.
+func (s *Service) Send(ctx context.Context, n Notification) (Result, error) {
+	var res Result
+	keys, err := s.loadKeys(ctx)
+	if err != nil {
+		return res, err
+	}
+	subs, err := s.q.ListPushSubscriptions(ctx)
+	if err != nil {
+		return res, fmt.Errorf("listing subscriptions: %w", err)
+	}
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return res, err
+	}
+	for _, sub := range subs {
+		status, err := s.push(ctx, keys, sub, payload)
+		switch {
+		case err == nil:
+			res.Sent++
+		case status == http.StatusNotFound || status == http.StatusGone:
+			res.Expired++
+			if err := s.q.DeletePushSubscriptionByEndpoint(ctx, sub.Endpoint); err != nil {
+				log.Printf("push: deleting subscription %d: %v", sub.ID, err)
+			}
+		default:
+			res.Failed++
+			log.Printf("push: subscription %d: %v", sub.ID, err)
+		}
+	}
+	return res, nil
+}
+
+// loadKeys returns the VAPID keys, loading them on first use.
+func (s *Service) loadKeys(ctx context.Context) (*Keys, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys == nil {
+		keys, err := LoadKeys(ctx, s.q)
+		if err != nil {
+			return nil, err
+		}
+		s.keys = keys
+	}
+	return s.keys, nil
+}
+
+// push posts payload to one subscription, and returns the response status
+// of the push service.
+func (s *Service) push(ctx context.Context, keys *Keys, sub store.PushSubscription, payload []byte) (int, error) {
+	body, err := encrypt(payload, sub.P256dh, sub.Auth)
+	if err != nil {
+		return 0, err
+	}
+	auth, err := keys.authorization(sub.Endpoint, subject, s.now())
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+	req.Header.Set("Urgency", "normal")
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("push service responded %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,346 @@
+package push
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	stdsync "sync"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/db"
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// pushStub is a local stand-in for a push service and the browser behind it.
+// It checks the VAPID token of each push, decrypts the payload with the
+// browser's keys, and records it.
+type pushStub struct {
+	*httptest.Server
+	t      *testing.T
+	key    *ecdh.PrivateKey // the browser's p256dh key pair
+	auth   []byte
+	status int // response status; 201 if zero
+
+	mu       stdsync.Mutex
+	received []Notification
+}
+
+func newPushStub(t *testing.T, status int) *pushStub {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	s := &pushStub{t: t, key: key, auth: make([]byte, 16), status: status}
+	_, _ = rand.Read(s.auth)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *pushStub) serve(w http.ResponseWriter, r *http.Request) {
+	if err := s.check(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *pushStub) check(r *http.Request) error {
+	if got := r.Header.Get("Content-Encoding"); got != "aes128gcm" {
+		return fmt.Errorf("Content-Encoding = %q", got)
+	}
+	if r.Header.Get("TTL") == "" {
+		return errors.New("no TTL")
+	}
+	if err := verifyVAPID(r.Header.Get("Authorization"), "http://"+r.Host); err != nil {
+		return err
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	plaintext, err := decrypt(body, s.key, s.auth)
+	if err != nil {
+		return fmt.Errorf("decrypting: %w", err)
+	}
+	var n Notification
+	if err := json.Unmarshal(plaintext, &n); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.received = append(s.received, n)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *pushStub) notifications() []Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Notification(nil), s.received...)
+}
+
+// subscribe stores a subscription to the stub at path.
+func (s *pushStub) subscribe(q store.Querier, path string) store.PushSubscription {
+	s.t.Helper()
+	sub, err := q.UpsertPushSubscription(context.Background(), store.UpsertPushSubscriptionParams{
+		Endpoint:  s.URL + path,
+		P256dh:    b64.EncodeToString(s.key.PublicKey().Bytes()),
+		Auth:      b64.EncodeToString(s.auth),
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		s.t.Fatalf("UpsertPushSubscription: %v", err)
+	}
+	return sub
+}
+
+// verifyVAPID checks a "vapid t=..., k=..." Authorization header as a push
+// service does.
+func verifyVAPID(header, audience string) error {
+	token, key, ok := strings.Cut(strings.TrimPrefix(header, "vapid t="), ", k=")
+	if !ok {
+		return fmt.Errorf("Authorization = %q", header)
+	}
+	raw, err := b64.DecodeString(key)
+	if err != nil {
+		return err
+	}
+	pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), raw)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return errors.New("malformed signature")
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(pub, hash[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return errors.New("bad signature")
+	}
+	claims, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	var c struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(claims, &c); err != nil {
+		return err
+	}
+	if c.Aud != audience || c.Sub == "" || c.Exp <= time.Now().Unix() {
+		return fmt.Errorf("claims = %+v", c)
+	}
+	return nil
+}
+
+// decrypt reverses encrypt, as the browser does.
+func decrypt(body []byte, uaPrivate *ecdh.PrivateKey, auth []byte) ([]byte, error) {
+	if len(body) < 21 || len(body) < 21+int(body[20]) {
+		return nil, errors.New("short body")
+	}
+	salt := body[:16]
+	if rs := binary.BigEndian.Uint32(body[16:20]); rs != recordSize {
+		return nil, fmt.Errorf("record size %d", rs)
+	}
+	asPublic := body[21 : 21+int(body[20])]
+	ciphertext := body[21+int(body[20]):]
+
+	asKey, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := uaPrivate.ECDH(asKey)
+	if err != nil {
+		return nil, err
+	}
+	prkKey, _ := hkdf.Extract(sha256.New, shared, auth)
+	ikm, _ := hkdf.Expand(sha256.New, prkKey, "WebPush: info\x00"+string(uaPrivate.PublicKey().Bytes())+string(asPublic), 32)
+	prk, _ := hkdf.Extract(sha256.New, ikm, salt)
+	cek, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	record, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+	i := strings.LastIndexByte(string(record), 2)
+	if i < 0 {
+		return nil, errors.New("no delimiter")
+	}
+	return record[:i], nil
+}
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	sqlDB, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return sqlDB
+}
+
+func mustExec(t *testing.T, sqlDB *sql.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := sqlDB.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func TestLoadKeys_GeneratedOnce(t *testing.T) {
+	q := store.New(newTestDB(t))
+	ctx := context.Background()
+
+	first, err := LoadKeys(ctx, q)
+	if err != nil {
+		t.Fatalf("LoadKeys: %v", err)
+	}
+	second, err := LoadKeys(ctx, q)
+	if err != nil {
+		t.Fatalf("LoadKeys: %v", err)
+	}
+	if first.PublicKey() != second.PublicKey() {
+		t.Errorf("public key changed from %s to %s", first.PublicKey(), second.PublicKey())
+	}
+	raw, err := b64.DecodeString(first.PublicKey())
+	if err != nil || len(raw) != 65 || raw[0] != 4 {
+		t.Errorf("public key %q is not an uncompressed P-256 point", first.PublicKey())
+	}
+}
+
+func TestSend_EncryptedAndSigned(t *testing.T) {
+	q := store.New(newTestDB(t))
+	stub := newPushStub(t, 0)
+	stub.subscribe(q, "/push/a")
+
+	want := Notification{Title: "Job ready: Rifter Blueprint", Body: "ME Research job is ready to deliver", Tag: "job:1", URL: "/"}
+	res, err := New(q, nil).Send(context.Background(), want)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if res != (Result{Sent: 1}) {
+		t.Errorf("result = %+v, want 1 sent", res)
+	}
+	got := stub.notifications()
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("received %+v, want [%+v]", got, want)
+	}
+}
+
+func TestSend_GoneSubscriptionDeleted(t *testing.T) {
+	q := store.New(newTestDB(t))
+	gone := newPushStub(t, http.StatusGone)
+	failing := newPushStub(t, http.StatusInternalServerError)
+	gone.subscribe(q, "/push/gone")
+	kept := failing.subscribe(q, "/push/failing")
+
+	res, err := New(q, nil).Send(context.Background(), Notification{Title: "test"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if res != (Result{Failed: 1, Expired: 1}) {
+		t.Errorf("result = %+v, want 1 failed and 1 expired", res)
+	}
+	subs, err := q.ListPushSubscriptions(context.Background())
+	if err != nil {
+		t.Fatalf("ListPushSubscriptions: %v", err)
+	}
+	if len(subs) != 1 || subs[0].ID != kept.ID {
+		t.Errorf("subscriptions = %+v, want only %d", subs, kept.ID)
+	}
+}
+
+func TestService_JobReadyAndSlotFreed(t *testing.T) {
+	sqlDB := newTestDB(t)
+	q := store.New(sqlDB)
+	stub := newPushStub(t, 0)
+	stub.subscribe(q, "/push/a")
+
+	mustExec(t, sqlDB,
+		`INSERT INTO characters (id, name, access_token, refresh_token, token_expiry, corporation_id, corporation_name)
+		 VALUES (1, 'Alice', 'tok', 'rtok', ?, 0, '')`, time.Now().Add(time.Hour))
+	mustExec(t, sqlDB, `INSERT INTO eve_categories (id, name) VALUES (1, 'Category')`)
+	mustExec(t, sqlDB, `INSERT INTO eve_groups (id, category_id, name) VALUES (1, 1, 'Group')`)
+	mustExec(t, sqlDB, `INSERT INTO eve_types (id, group_id, name) VALUES (1, 1, 'Rifter Blueprint')`)
+	mustExec(t, sqlDB,
+		`INSERT INTO blueprints (id, owner_type, owner_id, type_id, location_id, me_level, te_level, updated_at)
+		 VALUES (10, 'character', 1, 1, 0, 10, 20, CURRENT_TIMESTAMP)`)
+	mustExec(t, sqlDB,
+		`INSERT INTO jobs (id, blueprint_id, owner_type, owner_id, installer_id, activity, status, start_date, end_date, updated_at)
+		 VALUES (100, 10, 'character', 1, 1, 'me_research', 'active', ?, ?, CURRENT_TIMESTAMP)`,
+		time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+
+	s := New(q, nil)
+	ctx := context.Background()
+	// The first cycle only takes note of the slots in use.
+	s.handle(ctx, events.Event{Type: events.TypeJobReady, Data: events.Job{JobID: 100, BlueprintID: 10, Activity: "me_research"}})
+	s.handle(ctx, events.Event{Type: events.TypeCycleFinished})
+
+	// The job is delivered.
+	mustExec(t, sqlDB, `DELETE FROM jobs WHERE id = 100`)
+	s.handle(ctx, events.Event{Type: events.TypeCycleFinished})
+	s.handle(ctx, events.Event{Type: events.TypeCycleFinished})
+
+	got := stub.notifications()
+	var titles []string
+	for _, n := range got {
+		titles = append(titles, n.Title)
+	}
+	want := []string{"Job ready: Rifter Blueprint", "Slot free: Alice"}
+	if strings.Join(titles, "|") != strings.Join(want, "|") {
+		t.Errorf("titles = %q, want %q", titles, want)
+	}
+}
+
+func TestJobNotifications_Summarized(t *testing.T) {
+	jobs := make([]events.Job, maxJobNotifications+2)
+	for i := range jobs {
+		jobs[i] = events.Job{JobID: int64(i), BlueprintID: int64(i)}
+	}
+	notes := jobNotifications(jobs, map[int64]string{0: "Rifter Blueprint"})
+	if len(notes) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(notes))
+	}
+	if want := fmt.Sprintf("%d jobs ready", len(jobs)); notes[0].Title != want {
+		t.Errorf("title = %q, want %q", notes[0].Title, want)
+	}
+	if want := "Rifter Blueprint, Blueprint 1, Blueprint 2, and 2 more"; notes[0].Body != want {
+		t.Errorf("body = %q, want %q", notes[0].Body, want)
+	}
+}
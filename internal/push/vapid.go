@@ -0,0 +1,52 @@
+package push
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// vapidExpiry is the lifetime of a VAPID token. Push services reject tokens
+// valid for more than 24 hours.
+const vapidExpiry = 12 * time.Hour
+
+// authorization returns the Authorization header of a push to endpoint:
+// a VAPID token (RFC 8292) signed with k, naming subject as the contact of
+// the sender.
+func (k *Keys) authorization(endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("parsing endpoint: %w", err)
+	}
+	header := `{"typ":"JWT","alg":"ES256"}`
+	claims, err := json.Marshal(struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}{
+		Aud: u.Scheme + "://" + u.Host,
+		Exp: now.Add(vapidExpiry).Unix(),
+		Sub: subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := b64.EncodeToString([]byte(header)) + "." + b64.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("signing VAPID token: %w", err)
+	}
+	// JWS wants the two integers concatenated at fixed width (RFC 7518 3.4),
+	// not the ASN.1 form.
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	return "vapid t=" + unsigned + "." + b64.EncodeToString(sig) + ", k=" + k.PublicKey(), nil
+}
@@ -0,0 +1,314 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/db"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// openTestDB opens an in-memory SQLite database with all migrations applied.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	sqlDB, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("openTestDB: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return sqlDB
+}
+
+func TestUpsertAndGetAsset(t *testing.T) {
+	sqlDB := openTestDB(t)
+	q := store.New(sqlDB)
+	ctx := context.Background()
+
+	err := q.UpsertAsset(ctx, store.UpsertAssetParams{
+		ItemID:       1052718829566,
+		OwnerType:    "corporation",
+		OwnerID:      99000001,
+		LocationID:   60015146,
+		LocationFlag: "OfficeFolder",
+		LocationType: "station",
+		TypeID:       27,
+	})
+	if err != nil {
+		t.Fatalf("UpsertAsset: %v", err)
+	}
+
+	row, err := q.GetAsset(ctx, 1052718829566)
+	if err != nil {
+		t.Fatalf("GetAsset: %v", err)
+	}
+	if row.LocationID != 60015146 {
+		t.Errorf("LocationID: got %d, want 60015146", row.LocationID)
+	}
+	if row.LocationType != "station" {
+		t.Errorf("LocationType: got %q, want %q", row.LocationType, "station")
+	}
+	if row.OwnerType != "corporation" || row.LocationFlag != "OfficeFolder" || row.TypeID != 27 {
+		t.Errorf("got %+v", row)
+	}
+	if row.Name.Valid {
+		t.Errorf("Name: got %q, want NULL", row.Name.String)
+	}
+}
+
+func TestUpsertAsset_Replaces(t *testing.T) {
+	sqlDB := openTestDB(t)
+	q := store.New(sqlDB)
+	ctx := context.Background()
+
+	q.UpsertAsset(ctx, store.UpsertAssetParams{ //nolint:errcheck
+		ItemID: 100, OwnerType: "character", OwnerID: 1, LocationID: 60000001, LocationFlag: "Hangar", LocationType: "station",
+	})
+	// Replace with updated location and a name.
+	err := q.UpsertAsset(ctx, store.UpsertAssetParams{
+		ItemID: 100, OwnerType: "character", OwnerID: 1, LocationID: 60000002, LocationFlag: "Hangar", LocationType: "station",
+		Name: sql.NullString{String: "T2 BPOs", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("UpsertAsset replace: %v", err)
+	}
+
+	row, err := q.GetAsset(ctx, 100)
+	if err != nil {
+		t.Fatalf("GetAsset: %v", err)
+	}
+	if row.LocationID != 60000002 {
+		t.Errorf("LocationID after replace: got %d, want 60000002", row.LocationID)
+	}
+	if row.Name.String != "T2 BPOs" {
+		t.Errorf("Name after replace: got %q, want %q", row.Name.String, "T2 BPOs")
+	}
+}
+
+func TestDeleteAssetsByOwner(t *testing.T) {
+	sqlDB := openTestDB(t)
+	q := store.New(sqlDB)
+	ctx := context.Background()
+
+	for _, item := range []store.UpsertAssetParams{
+		{ItemID: 1, OwnerType: "corporation", OwnerID: 10, LocationID: 60000001, LocationType: "station"},
+		{ItemID: 2, OwnerType: "corporation", OwnerID: 10, LocationID: 60000002, LocationType: "station"},
+		{ItemID: 3, OwnerType: "corporation", OwnerID: 20, LocationID: 60000003, LocationType: "station"},
+		{ItemID: 4, OwnerType: "character", OwnerID: 10, LocationID: 60000004, LocationType: "station"},
+	} {
+		if err := q.UpsertAsset(ctx, item); err != nil {
+			t.Fatalf("UpsertAsset: %v", err)
+		}
+	}
+
+	if err := q.DeleteAssetsByOwner(ctx, store.DeleteAssetsByOwnerParams{OwnerType: "corporation", OwnerID: 10}); err != nil {
+		t.Fatalf("DeleteAssetsByOwner: %v", err)
+	}
+
+	// corporation 10 assets should be gone
+	if _, err := q.GetAsset(ctx, 1); err == nil {
+		t.Error("item_id=1 (corporation 10) should be deleted")
+	}
+	if _, err := q.GetAsset(ctx, 2); err == nil {
+		t.Error("item_id=2 (corporation 10) should be deleted")
+	}
+	// corporation 20 and character 10 assets should remain
+	if _, err := q.GetAsset(ctx, 3); err != nil {
+		t.Errorf("item_id=3 (corporation 20) should still exist: %v", err)
+	}
+	if _, err := q.GetAsset(ctx, 4); err != nil {
+		t.Errorf("item_id=4 (character 10) should still exist: %v", err)
+	}
+}
+
+func TestCorpDivisions(t *testing.T) {
+	sqlDB := openTestDB(t)
+	q := store.New(sqlDB)
+	ctx := context.Background()
+
+	for _, d := range []store.UpsertCorpDivisionParams{
+		{CorporationID: 10, Division: 3, Name: "Research BPOs"},
+		{CorporationID: 20, Division: 3, Name: "Other Corp"},
+	} {
+		if err := q.UpsertCorpDivision(ctx, d); err != nil {
+			t.Fatalf("UpsertCorpDivision: %v", err)
+		}
+	}
+
+	name, err := q.GetCorpDivisionName(ctx, store.GetCorpDivisionNameParams{CorporationID: 10, Division: 3})
+	if err != nil {
+		t.Fatalf("GetCorpDivisionName: %v", err)
+	}
+	if name != "Research BPOs" {
+		t.Errorf("name: got %q, want %q", name, "Research BPOs")
+	}
+
+	if err := q.DeleteCorpDivisions(ctx, 10); err != nil {
+		t.Fatalf("DeleteCorpDivisions: %v", err)
+	}
+	if _, err := q.GetCorpDivisionName(ctx, store.GetCorpDivisionNameParams{CorporationID: 10, Division: 3}); err == nil {
+		t.Error("division of corporation 10 should be deleted")
+	}
+	if _, err := q.GetCorpDivisionName(ctx, store.GetCorpDivisionNameParams{CorporationID: 20, Division: 3}); err != nil {
+		t.Errorf("division of corporation 20 should still exist: %v", err)
+	}
+}
+
+// seedBlueprintPrereqs inserts the minimal eve_types/groups/categories rows
+// required to satisfy blueprint FK constraints.
+func seedBlueprintPrereqs(t *testing.T, sqlDB *sql.DB, typeID int64) {
+	t.Helper()
+	_, err := sqlDB.Exec(
+		`INSERT OR IGNORE INTO eve_categories (id, name) VALUES (1, 'TestCat')`,
+	)
+	if err != nil {
+		t.Fatalf("insert eve_category: %v", err)
+	}
+	_, err = sqlDB.Exec(
+		`INSERT OR IGNORE INTO eve_groups (id, category_id, name) VALUES (1, 1, 'TestGroup')`,
+	)
+	if err != nil {
+		t.Fatalf("insert eve_group: %v", err)
+	}
+	_, err = sqlDB.Exec(
+		`INSERT OR IGNORE INTO eve_types (id, group_id, name) VALUES (?, 1, 'TestType')`, typeID,
+	)
+	if err != nil {
+		t.Fatalf("insert eve_type %d: %v", typeID, err)
+	}
+}
+
+func TestUpsertBlueprint_LocationFlagStored(t *testing.T) {
+	sqlDB := openTestDB(t)
+	q := store.New(sqlDB)
+	ctx := context.Background()
+
+	const typeID = int64(5000)
+	seedBlueprintPrereqs(t, sqlDB, typeID)
+
+	err := q.UpsertBlueprint(ctx, store.UpsertBlueprintParams{
+		ID:           1052548174037,
+		OwnerType:    "corporation",
+		OwnerID:      99000001,
+		TypeID:       typeID,
+		LocationID:   1052718829566,
+		LocationFlag: "CorpSAG3",
+		MeLevel:      10,
+		TeLevel:      20,
+		UpdatedAt:    time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("UpsertBlueprint: %v", err)
+	}
+
+	rows, err := q.ListBlueprintLocationsByOwner(ctx, store.ListBlueprintLocationsByOwnerParams{
+		OwnerType: "corporation",
+		OwnerID:   99000001,
+	})
+	if err != nil {
+		t.Fatalf("ListBlueprintLocationsByOwner: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows))
+	}
+	if rows[0].LocationID != 1052718829566 {
+		t.Errorf("LocationID: got %d, want 1052718829566", rows[0].LocationID)
+	}
+	if rows[0].LocationFlag != "CorpSAG3" {
+		t.Errorf("LocationFlag: got %q, want %q", rows[0].LocationFlag, "CorpSAG3")
+	}
+}
+
+func TestListBlueprintLocationsByOwner_DeduplicatesLocations(t *testing.T) {
+	sqlDB := openTestDB(t)
+	q := store.New(sqlDB)
+	ctx := context.Background()
+
+	seedBlueprintPrereqs(t, sqlDB, 5000)
+	seedBlueprintPrereqs(t, sqlDB, 5001)
+
+	for _, bp := range []store.UpsertBlueprintParams{
+		{ID: 1, OwnerType: "corporation", OwnerID: 1, TypeID: 5000, LocationID: 60000001, LocationFlag: "CorpSAG1", UpdatedAt: time.Now()},
+		{ID: 2, OwnerType: "corporation", OwnerID: 1, TypeID: 5001, LocationID: 60000001, LocationFlag: "CorpSAG1", UpdatedAt: time.Now()},
+	} {
+		if err := q.UpsertBlueprint(ctx, bp); err != nil {
+			t.Fatalf("UpsertBlueprint %d: %v", bp.ID, err)
+		}
+	}
+
+	rows, err := q.ListBlueprintLocationsByOwner(ctx, store.ListBlueprintLocationsByOwnerParams{
+		OwnerType: "corporation", OwnerID: 1,
+	})
+	if err != nil {
+		t.Fatalf("ListBlueprintLocationsByOwner: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Errorf("got %d rows, want 1 (DISTINCT)", len(rows))
+	}
+}
+
+func TestUpsertBlueprintLocations_ListBlueprintsJoinsPath(t *testing.T) {
+	sqlDB := openTestDB(t)
+	q := store.New(sqlDB)
+	ctx := context.Background()
+
+	seedBlueprintPrereqs(t, sqlDB, 5000)
+	const containerID = int64(1000000000101)
+	for _, bp := range []store.UpsertBlueprintParams{
+		{ID: 1, OwnerType: "corporation", OwnerID: 1, TypeID: 5000, LocationID: containerID, LocationFlag: "Unlocked", UpdatedAt: time.Now()},
+		{ID: 2, OwnerType: "corporation", OwnerID: 1, TypeID: 5000, LocationID: containerID, LocationFlag: "Unlocked", UpdatedAt: time.Now()},
+		{ID: 3, OwnerType: "corporation", OwnerID: 1, TypeID: 5000, LocationID: 60003760, LocationFlag: "Hangar", UpdatedAt: time.Now()},
+	} {
+		if err := q.UpsertBlueprint(ctx, bp); err != nil {
+			t.Fatalf("UpsertBlueprint %d: %v", bp.ID, err)
+		}
+	}
+	if err := q.InsertLocation(ctx, store.InsertLocationParams{ID: 60003760, Name: "Jita IV - Moon 4", ResolvedAt: time.Now()}); err != nil {
+		t.Fatalf("InsertLocation: %v", err)
+	}
+	if err := q.UpsertBlueprintLocations(ctx, store.UpsertBlueprintLocationsParams{
+		RootLocationID: 60003760,
+		Path:           "Corp Hangar 3 › Station Container 'T2 BPOs'",
+		OwnerType:      "corporation",
+		OwnerID:        1,
+		LocationID:     containerID,
+		LocationFlag:   "Unlocked",
+	}); err != nil {
+		t.Fatalf("UpsertBlueprintLocations: %v", err)
+	}
+
+	rows, err := q.ListBlueprints(ctx, store.ListBlueprintsParams{})
+	if err != nil {
+		t.Fatalf("ListBlueprints: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	for _, row := range rows[:2] {
+		if row.LocationName.String != "Jita IV - Moon 4" || row.LocationPath.String != "Corp Hangar 3 › Station Container 'T2 BPOs'" {
+			t.Errorf("blueprint %d: got location %v, path %v", row.ID, row.LocationName, row.LocationPath)
+		}
+	}
+	// Blueprint 3 has no resolved location yet.
+	if rows[2].LocationName.Valid || rows[2].LocationPath.Valid {
+		t.Errorf("blueprint 3: got location %v, path %v, want NULL", rows[2].LocationName, rows[2].LocationPath)
+	}
+
+	// Deleting a blueprint removes its location.
+	if err := q.DeleteBlueprintByID(ctx, 1); err != nil {
+		t.Fatalf("DeleteBlueprintByID: %v", err)
+	}
+	if err := q.DeleteBlueprintLocations(ctx, store.DeleteBlueprintLocationsParams{
+		OwnerType: "corporation", OwnerID: 1, LocationID: containerID, LocationFlag: "Unlocked",
+	}); err != nil {
+		t.Fatalf("DeleteBlueprintLocations: %v", err)
+	}
+	var n int
+	if err := sqlDB.QueryRow(`SELECT COUNT(*) FROM blueprint_locations`).Scan(&n); err != nil {
+		t.Fatalf("counting blueprint_locations: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("blueprint_locations rows: got %d, want 0", n)
+	}
+}
@@ -0,0 +1,384 @@
+package store
+
+// blueprint_page.go: filtered, sorted and paged reads of the blueprint
+// library. Not generated by sqlc: the filters, the ORDER BY and the keyset
+// condition vary per request.
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BlueprintPager is implemented by the Queriers that can read the blueprint
+// library a page at a time: Queries and EncryptedQueries.
+type BlueprintPager interface {
+	// ListBlueprintPage returns the blueprints matching arg.Filter that sort
+	// after arg.After, in arg.Sort order and then by ID: at most arg.Limit of
+	// them, or all with a zero Limit.
+	ListBlueprintPage(ctx context.Context, arg BlueprintPageParams) ([]ListBlueprintsRow, error)
+	// CountBlueprintMatches returns the number of blueprints matching f.
+	CountBlueprintMatches(ctx context.Context, f BlueprintFilter) (int64, error)
+}
+
+var (
+	_ BlueprintPager = (*Queries)(nil)
+	_ BlueprintPager = (*EncryptedQueries)(nil)
+)
+
+// ValueFilter matches a column that equals one of Values, or with Negate one
+// that equals none of them. Without Values it matches every row. A NULL
+// column, such as the region of a location not resolved yet, matches only a
+// negated filter.
+type ValueFilter[T any] struct {
+	Values []T
+	Negate bool
+}
+
+// BlueprintFilter selects blueprints. Its fields all have to match.
+type BlueprintFilter struct {
+	OwnerType  ValueFilter[string]
+	OwnerID    ValueFilter[int64]
+	CategoryID ValueFilter[int64]
+	Status     ValueFilter[string] // "ready", "active" or "idle", see BlueprintKey.Status
+	Activity   ValueFilter[string] // of the job; NULL for idle blueprints
+	RegionID   ValueFilter[int64]
+	SystemID   ValueFilter[int64]
+	Security   ValueFilter[string] // "high" (0.45 and above), "low" (above 0.0) or "null"
+
+	// Terms must each occur in the type, group, owner or location name,
+	// ignoring the case of ASCII letters. Pass them lower-cased.
+	Terms []string
+
+	HasJob bool      // only blueprints with a job
+	Now    time.Time // a job past its end date at Now counts as ready
+}
+
+// BlueprintSort is one key of the order of ListBlueprintPage. Field is one of
+// "status", "end_date", "type_name", "me", "te" and "owner".
+type BlueprintSort struct {
+	Field string
+	Desc  bool
+}
+
+// BlueprintKey holds the values a blueprint is sorted by. TypeName and Owner
+// are lower-cased as SQLite's lower() does: ASCII letters only.
+type BlueprintKey struct {
+	Status   int64      // 0 ready, 1 active, 2 idle
+	EndDate  *time.Time // job end date; nil for idle blueprints, which sort last either way
+	TypeName string
+	Me       int64
+	Te       int64
+	Owner    string
+	ID       int64
+}
+
+type BlueprintPageParams struct {
+	Filter BlueprintFilter
+	Sort   []BlueprintSort
+	After  *BlueprintKey // nil for the first page
+	Limit  int
+}
+
+// blueprintLibrary is the blueprint library as returned by ListBlueprints,
+// with the derived columns the page queries filter and sort on. Its one
+// parameter is the time jobs past their end date count as ready at.
+const blueprintLibrary = `WITH library AS (
+SELECT
+    b.id,
+    b.owner_type,
+    b.owner_id,
+    COALESCE(c.name, corp.name, '') AS owner_name,
+    b.type_id,
+    t.name AS type_name,
+    t.group_id,
+    g.name AS group_name,
+    g.category_id,
+    cat.name AS category_name,
+    b.location_id,
+    loc.name AS location_name,
+    bl.path  AS location_path,
+    loc.solar_system_id AS system_id,
+    sys.name            AS system_name,
+    sys.region_id,
+    sys.region_name,
+    sys.security_status,
+    b.me_level,
+    b.te_level,
+    b.updated_at,
+    j.id           AS job_id,
+    j.activity     AS job_activity,
+    j.status       AS job_status,
+    j.start_date   AS job_start_date,
+    j.end_date     AS job_end_date,
+    j.installer_id AS job_installer_id,
+    ic.name        AS job_installer_name,
+    CASE
+        WHEN j.id IS NULL THEN 2
+        WHEN j.status = 'ready' OR j.end_date <= ? THEN 0
+        ELSE 1
+    END AS status_rank,
+    CASE
+        WHEN sys.security_status IS NULL THEN NULL
+        WHEN sys.security_status >= 0.45 THEN 'high'
+        WHEN sys.security_status > 0.0 THEN 'low'
+        ELSE 'null'
+    END AS security_class,
+    CASE
+        WHEN loc.name IS NULL THEN NULL
+        WHEN COALESCE(bl.path, '') = '' THEN loc.name
+        ELSE loc.name || ' › ' || bl.path
+    END AS location_label
+FROM blueprints b
+JOIN eve_types t ON t.id = b.type_id
+JOIN eve_groups g ON g.id = t.group_id
+JOIN eve_categories cat ON cat.id = g.category_id
+LEFT JOIN jobs j ON j.blueprint_id = b.id
+LEFT JOIN characters c ON b.owner_type = 'character' AND c.id = b.owner_id
+LEFT JOIN corporations corp ON b.owner_type = 'corporation' AND corp.id = b.owner_id
+LEFT JOIN characters ic ON ic.id = j.installer_id
+LEFT JOIN blueprint_locations bl ON bl.blueprint_id = b.id
+LEFT JOIN eve_locations loc ON loc.id = bl.root_location_id
+LEFT JOIN eve_systems sys ON sys.id = loc.solar_system_id
+)
+`
+
+const listBlueprintPageColumns = `SELECT
+    id, owner_type, owner_id, owner_name, type_id, type_name, group_id, group_name,
+    category_id, category_name, location_id, location_name, location_path,
+    system_id, system_name, region_id, region_name, security_status,
+    me_level, te_level, updated_at,
+    job_id, job_activity, job_status, job_start_date, job_end_date, job_installer_id, job_installer_name
+FROM library
+`
+
+// blueprintStatusRanks maps BlueprintFilter.Status values to status_rank.
+var blueprintStatusRanks = map[string]int64{"ready": 0, "active": 1, "idle": 2}
+
+func (q *Queries) ListBlueprintPage(ctx context.Context, arg BlueprintPageParams) ([]ListBlueprintsRow, error) {
+	where, args, err := blueprintConditions(arg.Filter)
+	if err != nil {
+		return nil, err
+	}
+	order := make([]string, 0, len(arg.Sort)+2)
+	var keys []sortKey
+	for _, s := range arg.Sort {
+		k, err := blueprintSortKeys(s, arg.After)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range k {
+			order = append(order, k.expr+direction(k.desc))
+		}
+		keys = append(keys, k...)
+	}
+	order = append(order, "id")
+	if arg.After != nil {
+		keys = append(keys, sortKey{expr: "id", value: arg.After.ID})
+		cond, condArgs := keysetCondition(keys)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	query := blueprintLibrary + listBlueprintPageColumns + whereClause(where) + "ORDER BY " + strings.Join(order, ", ")
+	if arg.Limit > 0 {
+		query += "\nLIMIT ?"
+		args = append(args, arg.Limit)
+	}
+	rows, err := q.db.QueryContext(ctx, query, append([]any{arg.Filter.Now.UTC()}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBlueprintsRow
+	for rows.Next() {
+		var i ListBlueprintsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerType,
+			&i.OwnerID,
+			&i.OwnerName,
+			&i.TypeID,
+			&i.TypeName,
+			&i.GroupID,
+			&i.GroupName,
+			&i.CategoryID,
+			&i.CategoryName,
+			&i.LocationID,
+			&i.LocationName,
+			&i.LocationPath,
+			&i.SystemID,
+			&i.SystemName,
+			&i.RegionID,
+			&i.RegionName,
+			&i.SecurityStatus,
+			&i.MeLevel,
+			&i.TeLevel,
+			&i.UpdatedAt,
+			&i.JobID,
+			&i.JobActivity,
+			&i.JobStatus,
+			&i.JobStartDate,
+			&i.JobEndDate,
+			&i.JobInstallerID,
+			&i.JobInstallerName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (q *Queries) CountBlueprintMatches(ctx context.Context, f BlueprintFilter) (int64, error) {
+	where, args, err := blueprintConditions(f)
+	if err != nil {
+		return 0, err
+	}
+	query := blueprintLibrary + "SELECT COUNT(*) FROM library\n" + whereClause(where)
+	var count int64
+	err = q.db.QueryRowContext(ctx, query, append([]any{f.Now.UTC()}, args...)...).Scan(&count)
+	return count, err
+}
+
+// blueprintConditions returns the conditions on library rows of f, and their
+// arguments.
+func blueprintConditions(f BlueprintFilter) ([]string, []any, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, condArgs []any) {
+		if cond != "" {
+			where = append(where, cond)
+			args = append(args, condArgs...)
+		}
+	}
+	add(valueCondition("owner_type", f.OwnerType))
+	add(valueCondition("owner_id", f.OwnerID))
+	add(valueCondition("category_id", f.CategoryID))
+	add(valueCondition("job_activity", f.Activity))
+	add(valueCondition("region_id", f.RegionID))
+	add(valueCondition("system_id", f.SystemID))
+	add(valueCondition("security_class", f.Security))
+
+	ranks := ValueFilter[int64]{Negate: f.Status.Negate}
+	for _, s := range f.Status.Values {
+		rank, ok := blueprintStatusRanks[s]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown blueprint status %q", s)
+		}
+		ranks.Values = append(ranks.Values, rank)
+	}
+	add(valueCondition("status_rank", ranks))
+
+	for _, term := range f.Terms {
+		where = append(where, "(instr(lower(type_name), ?) > 0 OR instr(lower(group_name), ?) > 0"+
+			" OR instr(lower(owner_name), ?) > 0 OR instr(lower(location_label), ?) > 0)")
+		args = append(args, term, term, term, term)
+	}
+	if f.HasJob {
+		where = append(where, "job_id IS NOT NULL")
+	}
+	return where, args, nil
+}
+
+// valueCondition returns the condition of f on column, or "" if f matches
+// every row.
+func valueCondition[T any](column string, f ValueFilter[T]) (string, []any) {
+	if len(f.Values) == 0 {
+		return "", nil
+	}
+	args := make([]any, len(f.Values))
+	for i, v := range f.Values {
+		args[i] = v
+	}
+	list := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ") + ")"
+	if f.Negate {
+		return "(" + column + " IS NULL OR " + column + " NOT IN " + list + ")", args
+	}
+	return column + " IN " + list, args
+}
+
+// sortKey is an expression the page is ordered by, with its value in the
+// key of the blueprint the page starts after.
+type sortKey struct {
+	expr  string
+	desc  bool
+	value any
+}
+
+// blueprintSortKeys returns the ORDER BY keys of s, with their values in
+// after if it is not nil.
+func blueprintSortKeys(s BlueprintSort, after *BlueprintKey) ([]sortKey, error) {
+	if after == nil {
+		after = &BlueprintKey{}
+	}
+	switch s.Field {
+	case "status":
+		return []sortKey{{"status_rank", s.Desc, after.Status}}, nil
+	case "end_date":
+		// Idle blueprints have no end date and sort last in either direction.
+		var noJob, end any = int64(1), nil
+		if after.EndDate != nil {
+			noJob, end = int64(0), after.EndDate.UTC()
+		}
+		return []sortKey{{"(job_id IS NULL)", false, noJob}, {"job_end_date", s.Desc, end}}, nil
+	case "type_name":
+		return []sortKey{{"lower(type_name)", s.Desc, after.TypeName}}, nil
+	case "me":
+		return []sortKey{{"me_level", s.Desc, after.Me}}, nil
+	case "te":
+		return []sortKey{{"te_level", s.Desc, after.Te}}, nil
+	case "owner":
+		return []sortKey{{"lower(owner_name)", s.Desc, after.Owner}}, nil
+	default:
+		return nil, fmt.Errorf("unknown blueprint sort field %q", s.Field)
+	}
+}
+
+// keysetCondition returns the condition selecting the rows that sort after
+// the values of keys: greater in the first key that differs, or less if that
+// key is descending. A NULL value equals NULL and compares with nothing else.
+func keysetCondition(keys []sortKey) (string, []any) {
+	var (
+		alternatives []string
+		args         []any
+		equal        []string
+		equalArgs    []any
+	)
+	for _, k := range keys {
+		op := " > ?"
+		if k.desc {
+			op = " < ?"
+		}
+		alternatives = append(alternatives, "("+strings.Join(append(slices.Clone(equal), k.expr+op), " AND ")+")")
+		args = append(args, equalArgs...)
+		args = append(args, k.value)
+		equal = append(equal, k.expr+" IS ?")
+		equalArgs = append(equalArgs, k.value)
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+func direction(desc bool) string {
+	if desc {
+		return " DESC"
+	}
+	return ""
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conds, "\n    AND ") + "\n"
+}
@@ -0,0 +1,164 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// seedPageLibrary inserts 12 blueprints of two characters with many equal sort
+// values, half of them with a job, some of those ending at the same time.
+// Blueprints 4, 6 and 12 are ready.
+func seedPageLibrary(t *testing.T, sqlDB *sql.DB, now time.Time) {
+	t.Helper()
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := sqlDB.Exec(query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	seedBlueprintPrereqs(t, sqlDB, 1)
+	exec(`INSERT INTO eve_types (id, group_id, name) VALUES (2, 1, 'drake Blueprint'), (3, 1, 'Caracal Blueprint')`)
+	for _, c := range []struct {
+		id   int64
+		name string
+	}{{100, "alpha"}, {101, "Bravo"}} {
+		exec(`INSERT INTO characters (id, name, access_token, refresh_token, token_expiry, corporation_id, corporation_name) VALUES (?, ?, '', '', ?, 0, '')`, c.id, c.name, now)
+	}
+	for i := int64(1); i <= 12; i++ {
+		exec(`INSERT INTO blueprints (id, owner_type, owner_id, type_id, location_id, me_level, te_level, updated_at)
+		      VALUES (?, 'character', ?, ?, 60003760, ?, ?, ?)`,
+			i, 100+i%2, 1+i%3, i%4, 2*(i%4), now)
+		if i%2 == 0 {
+			status := "active"
+			if i == 4 {
+				status = "ready"
+			}
+			end := now.Add(time.Duration(i%6-2)*time.Hour + 30*time.Minute).UTC()
+			exec(`INSERT INTO jobs (id, blueprint_id, owner_type, owner_id, installer_id, activity, status, start_date, end_date, updated_at)
+			      VALUES (?, ?, 'character', ?, 100, 'copying', ?, ?, ?, ?)`,
+				500+i, i, 100+i%2, status, end.Add(-time.Hour), end, now)
+		}
+	}
+}
+
+// pageKey returns the BlueprintKey of row at now.
+func pageKey(row store.ListBlueprintsRow, now time.Time) store.BlueprintKey {
+	k := store.BlueprintKey{
+		Status:   2,
+		TypeName: strings.ToLower(row.TypeName),
+		Me:       row.MeLevel,
+		Te:       row.TeLevel,
+		Owner:    strings.ToLower(row.OwnerName),
+		ID:       row.ID,
+	}
+	if row.JobID.Valid {
+		k.Status = 1
+		if row.JobStatus.String == "ready" || !row.JobEndDate.Time.After(now) {
+			k.Status = 0
+		}
+		end := row.JobEndDate.Time.UTC()
+		k.EndDate = &end
+	}
+	return k
+}
+
+func rowIDs(rows []store.ListBlueprintsRow) []int64 {
+	ids := make([]int64, len(rows))
+	for i, r := range rows {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestListBlueprintPage_PagesFollowOrder(t *testing.T) {
+	sqlDB := openTestDB(t)
+	q := store.New(sqlDB)
+	ctx := context.Background()
+	now := time.Now()
+	seedPageLibrary(t, sqlDB, now)
+
+	for _, sort := range [][]store.BlueprintSort{
+		nil,
+		{{Field: "status"}, {Field: "end_date"}},
+		{{Field: "end_date", Desc: true}},
+		{{Field: "type_name"}, {Field: "owner", Desc: true}},
+		{{Field: "me", Desc: true}, {Field: "te"}},
+		{{Field: "owner"}, {Field: "status", Desc: true}},
+	} {
+		filter := store.BlueprintFilter{Now: now}
+		all, err := q.ListBlueprintPage(ctx, store.BlueprintPageParams{Filter: filter, Sort: sort})
+		if err != nil {
+			t.Fatalf("%v: ListBlueprintPage: %v", sort, err)
+		}
+		if len(all) != 12 {
+			t.Fatalf("%v: got %d blueprints, want 12", sort, len(all))
+		}
+
+		// Two at a time, each page starting after the last of the previous.
+		var paged []store.ListBlueprintsRow
+		params := store.BlueprintPageParams{Filter: filter, Sort: sort, Limit: 2}
+		for len(paged) <= len(all) {
+			page, err := q.ListBlueprintPage(ctx, params)
+			if err != nil {
+				t.Fatalf("%v: ListBlueprintPage: %v", sort, err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			paged = append(paged, page...)
+			after := pageKey(page[len(page)-1], now)
+			params.After = &after
+		}
+		if got, want := rowIDs(paged), rowIDs(all); !slices.Equal(got, want) {
+			t.Errorf("%v: paged order %v, want %v", sort, got, want)
+		}
+	}
+}
+
+func TestBlueprintPager_Filters(t *testing.T) {
+	sqlDB := openTestDB(t)
+	q := store.New(sqlDB)
+	ctx := context.Background()
+	now := time.Now()
+	seedPageLibrary(t, sqlDB, now)
+
+	for _, tc := range []struct {
+		filter store.BlueprintFilter
+		want   []int64
+	}{
+		{store.BlueprintFilter{OwnerID: store.ValueFilter[int64]{Values: []int64{101}}}, []int64{1, 3, 5, 7, 9, 11}},
+		{store.BlueprintFilter{Status: store.ValueFilter[string]{Values: []string{"idle"}, Negate: true}}, []int64{2, 4, 6, 8, 10, 12}},
+		{store.BlueprintFilter{Status: store.ValueFilter[string]{Values: []string{"ready"}}}, []int64{4, 6, 12}},
+		{store.BlueprintFilter{Activity: store.ValueFilter[string]{Values: []string{"copying"}, Negate: true}}, []int64{1, 3, 5, 7, 9, 11}},
+		{store.BlueprintFilter{Terms: []string{"drake", "bravo"}}, []int64{1, 7}},
+		{store.BlueprintFilter{Terms: []string{"caracal drake"}}, nil}, // one term, not two
+		{store.BlueprintFilter{HasJob: true, Terms: []string{"caracal"}}, []int64{2, 8}},
+	} {
+		tc.filter.Now = now
+		rows, err := q.ListBlueprintPage(ctx, store.BlueprintPageParams{Filter: tc.filter})
+		if err != nil {
+			t.Fatalf("%+v: ListBlueprintPage: %v", tc.filter, err)
+		}
+		if got := rowIDs(rows); !slices.Equal(got, tc.want) {
+			t.Errorf("%+v: blueprints %v, want %v", tc.filter, got, tc.want)
+		}
+		n, err := q.CountBlueprintMatches(ctx, tc.filter)
+		if err != nil {
+			t.Fatalf("%+v: CountBlueprintMatches: %v", tc.filter, err)
+		}
+		if n != int64(len(tc.want)) {
+			t.Errorf("%+v: count %d, want %d", tc.filter, n, len(tc.want))
+		}
+	}
+
+	_, err := q.ListBlueprintPage(ctx, store.BlueprintPageParams{Sort: []store.BlueprintSort{{Field: "name"}}})
+	if err == nil {
+		t.Error("unknown sort field: expected error")
+	}
+}
@@ -11,6 +11,15 @@ import (
 	"time"
 )
 
+const deleteBlueprintByID = `-- name: DeleteBlueprintByID :exec
+DELETE FROM blueprints WHERE id = ?
+`
+
+func (q *Queries) DeleteBlueprintByID(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteBlueprintByID, id)
+	return err
+}
+
 const deleteBlueprintsByOwner = `-- name: DeleteBlueprintsByOwner :exec
 DELETE FROM blueprints WHERE owner_type = ? AND owner_id = ?
 `
@@ -25,6 +34,92 @@ func (q *Queries) DeleteBlueprintsByOwner(ctx context.Context, arg DeleteBluepri
 	return err
 }
 
+const getBlueprint = `-- name: GetBlueprint :one
+SELECT id, owner_type, owner_id, type_id, location_id, me_level, te_level, updated_at, location_flag
+FROM blueprints
+WHERE id = ?
+`
+
+func (q *Queries) GetBlueprint(ctx context.Context, id int64) (Blueprint, error) {
+	row := q.db.QueryRowContext(ctx, getBlueprint, id)
+	var i Blueprint
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerType,
+		&i.OwnerID,
+		&i.TypeID,
+		&i.LocationID,
+		&i.MeLevel,
+		&i.TeLevel,
+		&i.UpdatedAt,
+		&i.LocationFlag,
+	)
+	return i, err
+}
+
+const getBlueprintTypeName = `-- name: GetBlueprintTypeName :one
+
+SELECT t.name
+FROM blueprints b
+JOIN eve_types t ON t.id = b.type_id
+WHERE b.id = ?
+`
+
+// The type name of a blueprint, for notifications about its jobs.
+func (q *Queries) GetBlueprintTypeName(ctx context.Context, id int64) (string, error) {
+	row := q.db.QueryRowContext(ctx, getBlueprintTypeName, id)
+	var name string
+	err := row.Scan(&name)
+	return name, err
+}
+
+const listBlueprintCategoryCounts = `-- name: ListBlueprintCategoryCounts :many
+
+SELECT
+    g.category_id,
+    cat.name AS category_name,
+    COUNT(*) AS count
+FROM blueprints b
+JOIN eve_types t ON t.id = b.type_id
+JOIN eve_groups g ON g.id = t.group_id
+JOIN eve_categories cat ON cat.id = g.category_id
+GROUP BY g.category_id
+`
+
+type ListBlueprintCategoryCountsRow struct {
+	CategoryID   int64
+	CategoryName string
+	Count        int64
+}
+
+// The number of blueprints in each category, for the category facet of GET /api/blueprints.
+func (q *Queries) ListBlueprintCategoryCounts(ctx context.Context) ([]ListBlueprintCategoryCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listBlueprintCategoryCounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBlueprintCategoryCountsRow
+	for rows.Next() {
+		var i ListBlueprintCategoryCountsRow
+		if err := rows.Scan(
+			&i.CategoryID,
+			&i.CategoryName,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listBlueprintLocationIDsByOwner = `-- name: ListBlueprintLocationIDsByOwner :many
 SELECT DISTINCT location_id
 FROM blueprints
@@ -98,6 +193,109 @@ func (q *Queries) ListBlueprintLocationsByOwner(ctx context.Context, arg ListBlu
 	return items, nil
 }
 
+const listBlueprintOwnerCounts = `-- name: ListBlueprintOwnerCounts :many
+
+SELECT
+    b.owner_type,
+    b.owner_id,
+    COALESCE(c.name, corp.name, '') AS owner_name,
+    COUNT(*) AS count
+FROM blueprints b
+JOIN eve_types t ON t.id = b.type_id
+JOIN eve_groups g ON g.id = t.group_id
+JOIN eve_categories cat ON cat.id = g.category_id
+LEFT JOIN characters c ON b.owner_type = 'character' AND c.id = b.owner_id
+LEFT JOIN corporations corp ON b.owner_type = 'corporation' AND corp.id = b.owner_id
+GROUP BY b.owner_type, b.owner_id
+`
+
+type ListBlueprintOwnerCountsRow struct {
+	OwnerType string
+	OwnerID   int64
+	OwnerName string
+	Count     int64
+}
+
+// The number of blueprints of each owner, for the owner facet of GET /api/blueprints.
+func (q *Queries) ListBlueprintOwnerCounts(ctx context.Context) ([]ListBlueprintOwnerCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listBlueprintOwnerCounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBlueprintOwnerCountsRow
+	for rows.Next() {
+		var i ListBlueprintOwnerCountsRow
+		if err := rows.Scan(
+			&i.OwnerType,
+			&i.OwnerID,
+			&i.OwnerName,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBlueprintRegionCounts = `-- name: ListBlueprintRegionCounts :many
+
+SELECT
+    sys.region_id,
+    sys.region_name,
+    COUNT(*) AS count
+FROM blueprints b
+JOIN eve_types t ON t.id = b.type_id
+JOIN eve_groups g ON g.id = t.group_id
+JOIN eve_categories cat ON cat.id = g.category_id
+JOIN blueprint_locations bl ON bl.blueprint_id = b.id
+JOIN eve_locations loc ON loc.id = bl.root_location_id
+JOIN eve_systems sys ON sys.id = loc.solar_system_id
+GROUP BY sys.region_id
+`
+
+type ListBlueprintRegionCountsRow struct {
+	RegionID   int64
+	RegionName string
+	Count      int64
+}
+
+// The number of blueprints in each region, for the region facet of GET /api/blueprints.
+// Blueprints in locations not resolved yet are in none.
+func (q *Queries) ListBlueprintRegionCounts(ctx context.Context) ([]ListBlueprintRegionCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listBlueprintRegionCounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBlueprintRegionCountsRow
+	for rows.Next() {
+		var i ListBlueprintRegionCountsRow
+		if err := rows.Scan(
+			&i.RegionID,
+			&i.RegionName,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listBlueprintTypeIDsByOwner = `-- name: ListBlueprintTypeIDsByOwner :many
 SELECT DISTINCT type_id
 FROM blueprints
@@ -146,6 +344,12 @@ SELECT
     cat.name AS category_name,
     b.location_id,
     loc.name AS location_name,
+    bl.path  AS location_path,
+    loc.solar_system_id AS system_id,
+    sys.name            AS system_name,
+    sys.region_id,
+    sys.region_name,
+    sys.security_status,
     b.me_level,
     b.te_level,
     b.updated_at,
@@ -164,7 +368,9 @@ LEFT JOIN jobs j ON j.blueprint_id = b.id
 LEFT JOIN characters c ON b.owner_type = 'character' AND c.id = b.owner_id
 LEFT JOIN corporations corp ON b.owner_type = 'corporation' AND corp.id = b.owner_id
 LEFT JOIN characters ic ON ic.id = j.installer_id
-LEFT JOIN eve_locations loc ON loc.id = b.location_id
+LEFT JOIN blueprint_locations bl ON bl.blueprint_id = b.id
+LEFT JOIN eve_locations loc ON loc.id = bl.root_location_id
+LEFT JOIN eve_systems sys ON sys.id = loc.solar_system_id
 WHERE
     (?1 IS NULL OR b.owner_type = ?1)
     AND (?2 IS NULL OR b.owner_id = ?2)
@@ -175,6 +381,14 @@ WHERE
         OR (?4 = 'active' AND j.status = 'active')
         OR (?4 = 'ready' AND j.status = 'ready')
     )
+    AND (?5 IS NULL OR sys.region_id = ?5)
+    AND (?6 IS NULL OR loc.solar_system_id = ?6)
+    AND (
+        ?7 IS NULL
+        OR (?7 = 'high' AND sys.security_status >= 0.45)
+        OR (?7 = 'low' AND sys.security_status > 0.0 AND sys.security_status < 0.45)
+        OR (?7 = 'null' AND sys.security_status <= 0.0)
+    )
 ORDER BY b.id
 `
 
@@ -183,6 +397,9 @@ type ListBlueprintsParams struct {
 	OwnerID    interface{}
 	CategoryID interface{}
 	Status     interface{}
+	RegionID   interface{}
+	SystemID   interface{}
+	Security   interface{}
 }
 
 type ListBlueprintsRow struct {
@@ -198,6 +415,12 @@ type ListBlueprintsRow struct {
 	CategoryName     string
 	LocationID       int64
 	LocationName     sql.NullString
+	LocationPath     sql.NullString
+	SystemID         sql.NullInt64
+	SystemName       sql.NullString
+	RegionID         sql.NullInt64
+	RegionName       sql.NullString
+	SecurityStatus   sql.NullFloat64
 	MeLevel          int64
 	TeLevel          int64
 	UpdatedAt        time.Time
@@ -216,6 +439,9 @@ func (q *Queries) ListBlueprints(ctx context.Context, arg ListBlueprintsParams)
 		arg.OwnerID,
 		arg.CategoryID,
 		arg.Status,
+		arg.RegionID,
+		arg.SystemID,
+		arg.Security,
 	)
 	if err != nil {
 		return nil, err
@@ -237,6 +463,12 @@ func (q *Queries) ListBlueprints(ctx context.Context, arg ListBlueprintsParams)
 			&i.CategoryName,
 			&i.LocationID,
 			&i.LocationName,
+			&i.LocationPath,
+			&i.SystemID,
+			&i.SystemName,
+			&i.RegionID,
+			&i.RegionName,
+			&i.SecurityStatus,
 			&i.MeLevel,
 			&i.TeLevel,
 			&i.UpdatedAt,
@@ -261,6 +493,50 @@ func (q *Queries) ListBlueprints(ctx context.Context, arg ListBlueprintsParams)
 	return items, nil
 }
 
+const listBlueprintsByOwner = `-- name: ListBlueprintsByOwner :many
+SELECT id, owner_type, owner_id, type_id, location_id, me_level, te_level, updated_at, location_flag
+FROM blueprints
+WHERE owner_type = ? AND owner_id = ?
+`
+
+type ListBlueprintsByOwnerParams struct {
+	OwnerType string
+	OwnerID   int64
+}
+
+func (q *Queries) ListBlueprintsByOwner(ctx context.Context, arg ListBlueprintsByOwnerParams) ([]Blueprint, error) {
+	rows, err := q.db.QueryContext(ctx, listBlueprintsByOwner, arg.OwnerType, arg.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Blueprint
+	for rows.Next() {
+		var i Blueprint
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerType,
+			&i.OwnerID,
+			&i.TypeID,
+			&i.LocationID,
+			&i.MeLevel,
+			&i.TeLevel,
+			&i.UpdatedAt,
+			&i.LocationFlag,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const upsertBlueprint = `-- name: UpsertBlueprint :exec
 
 INSERT INTO blueprints (id, owner_type, owner_id, type_id, location_id, location_flag, me_level, te_level, updated_at)
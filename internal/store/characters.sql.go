@@ -7,9 +7,22 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
+const clearCharacterTransferred = `-- name: ClearCharacterTransferred :exec
+UPDATE characters
+SET transferred    = 0,
+    transferred_at = NULL
+WHERE id = ?
+`
+
+func (q *Queries) ClearCharacterTransferred(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, clearCharacterTransferred, id)
+	return err
+}
+
 const deleteCharacter = `-- name: DeleteCharacter :exec
 DELETE FROM characters WHERE id = ?
 `
@@ -21,7 +34,7 @@ func (q *Queries) DeleteCharacter(ctx context.Context, id int64) error {
 
 const getCharacter = `-- name: GetCharacter :one
 
-SELECT id, name, access_token, refresh_token, token_expiry, created_at, corporation_id, corporation_name
+SELECT id, name, access_token, refresh_token, token_expiry, created_at, corporation_id, corporation_name, needs_reauth, needs_reauth_at, scopes, owner_hash, transferred, transferred_at, alliance_id, alliance_name, affiliation_at, roles
 FROM characters
 WHERE id = ?
 `
@@ -40,12 +53,22 @@ func (q *Queries) GetCharacter(ctx context.Context, id int64) (Character, error)
 		&i.CreatedAt,
 		&i.CorporationID,
 		&i.CorporationName,
+		&i.NeedsReauth,
+		&i.NeedsReauthAt,
+		&i.Scopes,
+		&i.OwnerHash,
+		&i.Transferred,
+		&i.TransferredAt,
+		&i.AllianceID,
+		&i.AllianceName,
+		&i.AffiliationAt,
+		&i.Roles,
 	)
 	return i, err
 }
 
 const listCharacters = `-- name: ListCharacters :many
-SELECT id, name, access_token, refresh_token, token_expiry, created_at, corporation_id, corporation_name
+SELECT id, name, access_token, refresh_token, token_expiry, created_at, corporation_id, corporation_name, needs_reauth, needs_reauth_at, scopes, owner_hash, transferred, transferred_at, alliance_id, alliance_name, affiliation_at, roles
 FROM characters
 ORDER BY name
 `
@@ -68,6 +91,16 @@ func (q *Queries) ListCharacters(ctx context.Context) ([]Character, error) {
 			&i.CreatedAt,
 			&i.CorporationID,
 			&i.CorporationName,
+			&i.NeedsReauth,
+			&i.NeedsReauthAt,
+			&i.Scopes,
+			&i.OwnerHash,
+			&i.Transferred,
+			&i.TransferredAt,
+			&i.AllianceID,
+			&i.AllianceName,
+			&i.AffiliationAt,
+			&i.Roles,
 		); err != nil {
 			return nil, err
 		}
@@ -83,7 +116,7 @@ func (q *Queries) ListCharacters(ctx context.Context) ([]Character, error) {
 }
 
 const listCharactersByCorporation = `-- name: ListCharactersByCorporation :many
-SELECT id, name, access_token, refresh_token, token_expiry, created_at, corporation_id, corporation_name
+SELECT id, name, access_token, refresh_token, token_expiry, created_at, corporation_id, corporation_name, needs_reauth, needs_reauth_at, scopes, owner_hash, transferred, transferred_at, alliance_id, alliance_name, affiliation_at, roles
 FROM characters
 WHERE corporation_id = ?
 ORDER BY name
@@ -107,6 +140,16 @@ func (q *Queries) ListCharactersByCorporation(ctx context.Context, corporationID
 			&i.CreatedAt,
 			&i.CorporationID,
 			&i.CorporationName,
+			&i.NeedsReauth,
+			&i.NeedsReauthAt,
+			&i.Scopes,
+			&i.OwnerHash,
+			&i.Transferred,
+			&i.TransferredAt,
+			&i.AllianceID,
+			&i.AllianceName,
+			&i.AffiliationAt,
+			&i.Roles,
 		); err != nil {
 			return nil, err
 		}
@@ -127,7 +170,14 @@ SELECT
   ch.name,
   ch.corporation_id,
   ch.corporation_name,
+  ch.alliance_id,
+  ch.alliance_name,
   ch.created_at,
+  ch.needs_reauth,
+  ch.needs_reauth_at,
+  ch.scopes,
+  ch.transferred,
+  ch.transferred_at,
   CASE WHEN corp.id IS NOT NULL THEN 1 ELSE 0 END AS is_delegate,
   CASE WHEN corp.id IS NOT NULL THEN (
     SELECT last_error FROM sync_state
@@ -144,7 +194,14 @@ type ListCharactersWithMetaRow struct {
 	Name            string
 	CorporationID   int64
 	CorporationName string
+	AllianceID      sql.NullInt64
+	AllianceName    sql.NullString
 	CreatedAt       time.Time
+	NeedsReauth     int64
+	NeedsReauthAt   sql.NullTime
+	Scopes          string
+	Transferred     int64
+	TransferredAt   sql.NullTime
 	IsDelegate      int64
 	SyncError       interface{}
 }
@@ -163,7 +220,14 @@ func (q *Queries) ListCharactersWithMeta(ctx context.Context) ([]ListCharactersW
 			&i.Name,
 			&i.CorporationID,
 			&i.CorporationName,
+			&i.AllianceID,
+			&i.AllianceName,
 			&i.CreatedAt,
+			&i.NeedsReauth,
+			&i.NeedsReauthAt,
+			&i.Scopes,
+			&i.Transferred,
+			&i.TransferredAt,
 			&i.IsDelegate,
 			&i.SyncError,
 		); err != nil {
@@ -180,16 +244,133 @@ func (q *Queries) ListCharactersWithMeta(ctx context.Context) ([]ListCharactersW
 	return items, nil
 }
 
+const markCharacterNeedsReauth = `-- name: MarkCharacterNeedsReauth :exec
+UPDATE characters
+SET needs_reauth    = 1,
+    needs_reauth_at = COALESCE(needs_reauth_at, CURRENT_TIMESTAMP)
+WHERE id = ?
+`
+
+func (q *Queries) MarkCharacterNeedsReauth(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, markCharacterNeedsReauth, id)
+	return err
+}
+
+const markCharacterTransferred = `-- name: MarkCharacterTransferred :exec
+UPDATE characters
+SET transferred    = 1,
+    transferred_at = COALESCE(transferred_at, CURRENT_TIMESTAMP)
+WHERE id = ?
+`
+
+func (q *Queries) MarkCharacterTransferred(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, markCharacterTransferred, id)
+	return err
+}
+
+const updateCharacterAffiliation = `-- name: UpdateCharacterAffiliation :exec
+UPDATE characters
+SET corporation_id   = ?,
+    corporation_name = ?,
+    alliance_id      = ?,
+    alliance_name    = ?,
+    affiliation_at   = ?
+WHERE id = ?
+`
+
+type UpdateCharacterAffiliationParams struct {
+	CorporationID   int64
+	CorporationName string
+	AllianceID      sql.NullInt64
+	AllianceName    sql.NullString
+	AffiliationAt   sql.NullTime
+	ID              int64
+}
+
+func (q *Queries) UpdateCharacterAffiliation(ctx context.Context, arg UpdateCharacterAffiliationParams) error {
+	_, err := q.db.ExecContext(ctx, updateCharacterAffiliation,
+		arg.CorporationID,
+		arg.CorporationName,
+		arg.AllianceID,
+		arg.AllianceName,
+		arg.AffiliationAt,
+		arg.ID,
+	)
+	return err
+}
+
+const updateCharacterRoles = `-- name: UpdateCharacterRoles :exec
+UPDATE characters SET roles = ? WHERE id = ?
+`
+
+type UpdateCharacterRolesParams struct {
+	Roles sql.NullString
+	ID    int64
+}
+
+func (q *Queries) UpdateCharacterRoles(ctx context.Context, arg UpdateCharacterRolesParams) error {
+	_, err := q.db.ExecContext(ctx, updateCharacterRoles, arg.Roles, arg.ID)
+	return err
+}
+
+const updateCharacterTokens = `-- name: UpdateCharacterTokens :exec
+
+UPDATE characters
+SET access_token   = ?1,
+    refresh_token  = ?2,
+    token_expiry   = ?3,
+    scopes         = ?4,
+    owner_hash     = ?5,
+    transferred    = CASE WHEN owner_hash NOT IN ('', ?5)
+                          THEN 1 ELSE transferred END,
+    transferred_at = CASE WHEN owner_hash NOT IN ('', ?5)
+                          THEN COALESCE(transferred_at, CURRENT_TIMESTAMP) ELSE transferred_at END
+WHERE id = ?6
+`
+
+type UpdateCharacterTokensParams struct {
+	AccessToken  string
+	RefreshToken string
+	TokenExpiry  time.Time
+	Scopes       string
+	OwnerHash    string
+	ID           int64
+}
+
+// A changed owner hash flags the character as transferred in the same
+// statement; one stored before owner hashes were recorded (empty) does not.
+func (q *Queries) UpdateCharacterTokens(ctx context.Context, arg UpdateCharacterTokensParams) error {
+	_, err := q.db.ExecContext(ctx, updateCharacterTokens,
+		arg.AccessToken,
+		arg.RefreshToken,
+		arg.TokenExpiry,
+		arg.Scopes,
+		arg.OwnerHash,
+		arg.ID,
+	)
+	return err
+}
+
 const upsertCharacter = `-- name: UpsertCharacter :exec
-INSERT INTO characters (id, name, access_token, refresh_token, token_expiry, corporation_id, corporation_name)
-VALUES (?, ?, ?, ?, ?, ?, ?)
+
+INSERT INTO characters (id, name, access_token, refresh_token, token_expiry, corporation_id, corporation_name, scopes, owner_hash)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(id) DO UPDATE SET
     name             = excluded.name,
     access_token     = excluded.access_token,
     refresh_token    = excluded.refresh_token,
     token_expiry     = excluded.token_expiry,
     corporation_id   = excluded.corporation_id,
-    corporation_name = excluded.corporation_name
+    corporation_name = excluded.corporation_name,
+    scopes           = excluded.scopes,
+    owner_hash       = excluded.owner_hash,
+    transferred      = CASE WHEN characters.owner_hash NOT IN ('', excluded.owner_hash)
+                            THEN 1 ELSE characters.transferred END,
+    transferred_at   = CASE WHEN characters.owner_hash NOT IN ('', excluded.owner_hash)
+                            THEN COALESCE(characters.transferred_at, CURRENT_TIMESTAMP)
+                            ELSE characters.transferred_at END,
+    needs_reauth     = 0,
+    needs_reauth_at  = NULL
 `
 
 type UpsertCharacterParams struct {
@@ -200,8 +381,12 @@ type UpsertCharacterParams struct {
 	TokenExpiry     time.Time
 	CorporationID   int64
 	CorporationName string
+	Scopes          string
+	OwnerHash       string
 }
 
+// A changed owner hash flags the character as transferred in the same
+// statement; one stored before owner hashes were recorded (empty) does not.
 func (q *Queries) UpsertCharacter(ctx context.Context, arg UpsertCharacterParams) error {
 	_, err := q.db.ExecContext(ctx, upsertCharacter,
 		arg.ID,
@@ -211,6 +396,8 @@ func (q *Queries) UpsertCharacter(ctx context.Context, arg UpsertCharacterParams
 		arg.TokenExpiry,
 		arg.CorporationID,
 		arg.CorporationName,
+		arg.Scopes,
+		arg.OwnerHash,
 	)
 	return err
 }
@@ -0,0 +1,192 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+func TestUpdateCharacterTokens_KeepsCorporation(t *testing.T) {
+	sqlDB := openTestDB(t)
+	q := store.New(sqlDB)
+	ctx := context.Background()
+
+	if err := q.UpsertCharacter(ctx, store.UpsertCharacterParams{
+		ID:              90000001,
+		Name:            "Pilot",
+		AccessToken:     "old-access",
+		RefreshToken:    "old-refresh",
+		TokenExpiry:     time.Now().Add(-time.Hour),
+		CorporationID:   98000001,
+		CorporationName: "Test Corp",
+	}); err != nil {
+		t.Fatalf("UpsertCharacter: %v", err)
+	}
+
+	expiry := time.Now().Add(20 * time.Minute).UTC().Truncate(time.Second)
+	if err := q.UpdateCharacterTokens(ctx, store.UpdateCharacterTokensParams{
+		AccessToken:  "new-access",
+		RefreshToken: "new-refresh",
+		TokenExpiry:  expiry,
+		ID:           90000001,
+	}); err != nil {
+		t.Fatalf("UpdateCharacterTokens: %v", err)
+	}
+
+	c, err := q.GetCharacter(ctx, 90000001)
+	if err != nil {
+		t.Fatalf("GetCharacter: %v", err)
+	}
+	if c.AccessToken != "new-access" || c.RefreshToken != "new-refresh" {
+		t.Errorf("tokens: got %q/%q, want new-access/new-refresh", c.AccessToken, c.RefreshToken)
+	}
+	if !c.TokenExpiry.Equal(expiry) {
+		t.Errorf("TokenExpiry: got %v, want %v", c.TokenExpiry, expiry)
+	}
+	if c.CorporationID != 98000001 || c.CorporationName != "Test Corp" {
+		t.Errorf("corporation: got %d %q, want 98000001 %q", c.CorporationID, c.CorporationName, "Test Corp")
+	}
+}
+
+func TestUpsertCharacter_ClearsNeedsReauth(t *testing.T) {
+	sqlDB := openTestDB(t)
+	q := store.New(sqlDB)
+	ctx := context.Background()
+
+	params := store.UpsertCharacterParams{
+		ID:           90000001,
+		Name:         "Pilot",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		TokenExpiry:  time.Now().Add(time.Hour),
+	}
+	if err := q.UpsertCharacter(ctx, params); err != nil {
+		t.Fatalf("UpsertCharacter: %v", err)
+	}
+	if err := q.MarkCharacterNeedsReauth(ctx, 90000001); err != nil {
+		t.Fatalf("MarkCharacterNeedsReauth: %v", err)
+	}
+
+	c, err := q.GetCharacter(ctx, 90000001)
+	if err != nil {
+		t.Fatalf("GetCharacter: %v", err)
+	}
+	if c.NeedsReauth != 1 || !c.NeedsReauthAt.Valid {
+		t.Fatalf("after mark: got NeedsReauth=%d NeedsReauthAt.Valid=%v, want 1/true", c.NeedsReauth, c.NeedsReauthAt.Valid)
+	}
+
+	// Logging in again stores fresh tokens via UpsertCharacter.
+	if err := q.UpsertCharacter(ctx, params); err != nil {
+		t.Fatalf("UpsertCharacter (re-login): %v", err)
+	}
+	c, err = q.GetCharacter(ctx, 90000001)
+	if err != nil {
+		t.Fatalf("GetCharacter: %v", err)
+	}
+	if c.NeedsReauth != 0 || c.NeedsReauthAt.Valid {
+		t.Errorf("after re-login: got NeedsReauth=%d NeedsReauthAt.Valid=%v, want 0/false", c.NeedsReauth, c.NeedsReauthAt.Valid)
+	}
+}
+
+// TestCharacterTransferred_SurvivesLogin verifies that the transferred flag is
+// kept when the new owner logs in (UpsertCharacter) and only cleared by
+// ClearCharacterTransferred.
+func TestCharacterTransferred_SurvivesLogin(t *testing.T) {
+	sqlDB := openTestDB(t)
+	q := store.New(sqlDB)
+	ctx := context.Background()
+
+	params := store.UpsertCharacterParams{
+		ID:           90000001,
+		Name:         "Pilot",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		TokenExpiry:  time.Now().Add(time.Hour),
+		OwnerHash:    "old-owner",
+	}
+	if err := q.UpsertCharacter(ctx, params); err != nil {
+		t.Fatalf("UpsertCharacter: %v", err)
+	}
+	if err := q.MarkCharacterTransferred(ctx, 90000001); err != nil {
+		t.Fatalf("MarkCharacterTransferred: %v", err)
+	}
+
+	params.OwnerHash = "new-owner"
+	if err := q.UpsertCharacter(ctx, params); err != nil {
+		t.Fatalf("UpsertCharacter (new owner): %v", err)
+	}
+	c, err := q.GetCharacter(ctx, 90000001)
+	if err != nil {
+		t.Fatalf("GetCharacter: %v", err)
+	}
+	if c.Transferred != 1 || !c.TransferredAt.Valid {
+		t.Errorf("after login: got Transferred=%d TransferredAt.Valid=%v, want 1/true", c.Transferred, c.TransferredAt.Valid)
+	}
+	if c.OwnerHash != "new-owner" {
+		t.Errorf("OwnerHash: got %q, want %q", c.OwnerHash, "new-owner")
+	}
+
+	if err := q.ClearCharacterTransferred(ctx, 90000001); err != nil {
+		t.Fatalf("ClearCharacterTransferred: %v", err)
+	}
+	c, err = q.GetCharacter(ctx, 90000001)
+	if err != nil {
+		t.Fatalf("GetCharacter: %v", err)
+	}
+	if c.Transferred != 0 || c.TransferredAt.Valid {
+		t.Errorf("after confirm: got Transferred=%d TransferredAt.Valid=%v, want 0/false", c.Transferred, c.TransferredAt.Valid)
+	}
+}
+
+// TestOwnerHashChange_FlagsTransferred verifies that UpsertCharacter and
+// UpdateCharacterTokens flag a character as transferred when they store a
+// different owner hash, and not when the stored hash is empty or the same.
+func TestOwnerHashChange_FlagsTransferred(t *testing.T) {
+	ctx := context.Background()
+	login := func(q *store.Queries, hash string) error {
+		return q.UpsertCharacter(ctx, store.UpsertCharacterParams{
+			ID: 90000001, Name: "Pilot", AccessToken: "access", RefreshToken: "refresh",
+			TokenExpiry: time.Now().Add(time.Hour), OwnerHash: hash,
+		})
+	}
+	refresh := func(q *store.Queries, hash string) error {
+		return q.UpdateCharacterTokens(ctx, store.UpdateCharacterTokensParams{
+			AccessToken: "access", RefreshToken: "refresh",
+			TokenExpiry: time.Now().Add(time.Hour), OwnerHash: hash, ID: 90000001,
+		})
+	}
+
+	for name, write := range map[string]func(*store.Queries, string) error{"UpsertCharacter": login, "UpdateCharacterTokens": refresh} {
+		t.Run(name, func(t *testing.T) {
+			q := store.New(openTestDB(t))
+			transferred := func() bool {
+				t.Helper()
+				c, err := q.GetCharacter(ctx, 90000001)
+				if err != nil {
+					t.Fatalf("GetCharacter: %v", err)
+				}
+				return c.Transferred != 0 && c.TransferredAt.Valid
+			}
+
+			if err := login(q, ""); err != nil {
+				t.Fatalf("UpsertCharacter: %v", err)
+			}
+			for _, hash := range []string{"owner-a", "owner-a"} { // recorded, then unchanged
+				if err := write(q, hash); err != nil {
+					t.Fatalf("%s(%q): %v", name, hash, err)
+				}
+				if transferred() {
+					t.Fatalf("flagged as transferred after storing %q", hash)
+				}
+			}
+			if err := write(q, "owner-b"); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if !transferred() {
+				t.Error("not flagged as transferred after the owner hash changed")
+			}
+		})
+	}
+}
@@ -7,9 +7,24 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
+const adoptCorporation = `-- name: AdoptCorporation :exec
+UPDATE corporations SET delegate_id = ? WHERE id = ? AND delegate_id IS NULL
+`
+
+type AdoptCorporationParams struct {
+	DelegateID sql.NullInt64
+	ID         int64
+}
+
+func (q *Queries) AdoptCorporation(ctx context.Context, arg AdoptCorporationParams) error {
+	_, err := q.db.ExecContext(ctx, adoptCorporation, arg.DelegateID, arg.ID)
+	return err
+}
+
 const deleteCorporation = `-- name: DeleteCorporation :exec
 DELETE FROM corporations WHERE id = ?
 `
@@ -21,7 +36,7 @@ func (q *Queries) DeleteCorporation(ctx context.Context, id int64) error {
 
 const getCorporation = `-- name: GetCorporation :one
 
-SELECT id, name, delegate_id, created_at
+SELECT id, name, delegate_id, created_at, active_character_id
 FROM corporations
 WHERE id = ?
 `
@@ -36,6 +51,7 @@ func (q *Queries) GetCorporation(ctx context.Context, id int64) (Corporation, er
 		&i.Name,
 		&i.DelegateID,
 		&i.CreatedAt,
+		&i.ActiveCharacterID,
 	)
 	return i, err
 }
@@ -48,7 +64,7 @@ VALUES (?, ?, ?)
 type InsertCorporationParams struct {
 	ID         int64
 	Name       string
-	DelegateID int64
+	DelegateID sql.NullInt64
 }
 
 func (q *Queries) InsertCorporation(ctx context.Context, arg InsertCorporationParams) error {
@@ -64,7 +80,7 @@ VALUES (?, ?, ?)
 type InsertOrIgnoreCorporationParams struct {
 	ID         int64
 	Name       string
-	DelegateID int64
+	DelegateID sql.NullInt64
 }
 
 func (q *Queries) InsertOrIgnoreCorporation(ctx context.Context, arg InsertOrIgnoreCorporationParams) error {
@@ -73,18 +89,22 @@ func (q *Queries) InsertOrIgnoreCorporation(ctx context.Context, arg InsertOrIgn
 }
 
 const listCorporations = `-- name: ListCorporations :many
-SELECT c.id, c.name, c.delegate_id, ch.name AS delegate_name, c.created_at
+SELECT c.id, c.name, c.delegate_id, ch.name AS delegate_name, c.created_at,
+       ac.id AS active_character_id, ac.name AS active_character_name
 FROM corporations c
-JOIN characters ch ON ch.id = c.delegate_id
+LEFT JOIN characters ch ON ch.id = c.delegate_id
+LEFT JOIN characters ac ON ac.id = c.active_character_id AND ac.corporation_id = c.id
 ORDER BY c.name
 `
 
 type ListCorporationsRow struct {
-	ID           int64
-	Name         string
-	DelegateID   int64
-	DelegateName string
-	CreatedAt    time.Time
+	ID                  int64
+	Name                string
+	DelegateID          sql.NullInt64
+	DelegateName        sql.NullString
+	CreatedAt           time.Time
+	ActiveCharacterID   sql.NullInt64
+	ActiveCharacterName sql.NullString
 }
 
 func (q *Queries) ListCorporations(ctx context.Context) ([]ListCorporationsRow, error) {
@@ -102,6 +122,8 @@ func (q *Queries) ListCorporations(ctx context.Context) ([]ListCorporationsRow,
 			&i.DelegateID,
 			&i.DelegateName,
 			&i.CreatedAt,
+			&i.ActiveCharacterID,
+			&i.ActiveCharacterName,
 		); err != nil {
 			return nil, err
 		}
@@ -116,12 +138,35 @@ func (q *Queries) ListCorporations(ctx context.Context) ([]ListCorporationsRow,
 	return items, nil
 }
 
+const orphanCorporation = `-- name: OrphanCorporation :exec
+UPDATE corporations SET delegate_id = NULL WHERE id = ?
+`
+
+func (q *Queries) OrphanCorporation(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, orphanCorporation, id)
+	return err
+}
+
+const setCorporationActiveCharacter = `-- name: SetCorporationActiveCharacter :exec
+UPDATE corporations SET active_character_id = ? WHERE id = ?
+`
+
+type SetCorporationActiveCharacterParams struct {
+	ActiveCharacterID sql.NullInt64
+	ID                int64
+}
+
+func (q *Queries) SetCorporationActiveCharacter(ctx context.Context, arg SetCorporationActiveCharacterParams) error {
+	_, err := q.db.ExecContext(ctx, setCorporationActiveCharacter, arg.ActiveCharacterID, arg.ID)
+	return err
+}
+
 const updateCorporationDelegate = `-- name: UpdateCorporationDelegate :exec
 UPDATE corporations SET delegate_id = ? WHERE id = ?
 `
 
 type UpdateCorporationDelegateParams struct {
-	DelegateID int64
+	DelegateID sql.NullInt64
 	ID         int64
 }
 
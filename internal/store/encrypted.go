@@ -0,0 +1,86 @@
+package store
+
+// encrypted.go: a Querier that keeps the characters table's OAuth tokens
+// encrypted at rest. Not generated by sqlc.
+
+import (
+	"context"
+	"database/sql"
+)
+
+// EncryptedQueries wraps Queries: the access and refresh tokens written by
+// UpsertCharacter and UpdateCharacterTokens are encrypted with the
+// TokenCipher, and the characters returned by GetCharacter, ListCharacters
+// and ListCharactersByCorporation carry decrypted tokens. All other queries,
+// including ListCharacterTokens and SetCharacterTokenColumns, pass through
+// unchanged.
+type EncryptedQueries struct {
+	*Queries
+	cipher *TokenCipher
+}
+
+var _ Querier = (*EncryptedQueries)(nil)
+
+// NewEncrypted returns an EncryptedQueries for db using c.
+func NewEncrypted(db DBTX, c *TokenCipher) *EncryptedQueries {
+	return &EncryptedQueries{Queries: New(db), cipher: c}
+}
+
+// WithTx returns an EncryptedQueries that runs in tx.
+func (q *EncryptedQueries) WithTx(tx *sql.Tx) *EncryptedQueries {
+	return &EncryptedQueries{Queries: q.Queries.WithTx(tx), cipher: q.cipher}
+}
+
+func (q *EncryptedQueries) GetCharacter(ctx context.Context, id int64) (Character, error) {
+	c, err := q.Queries.GetCharacter(ctx, id)
+	if err != nil {
+		return c, err
+	}
+	return q.decryptCharacter(c)
+}
+
+func (q *EncryptedQueries) ListCharacters(ctx context.Context) ([]Character, error) {
+	chars, err := q.Queries.ListCharacters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return q.decryptCharacters(chars)
+}
+
+func (q *EncryptedQueries) ListCharactersByCorporation(ctx context.Context, corporationID int64) ([]Character, error) {
+	chars, err := q.Queries.ListCharactersByCorporation(ctx, corporationID)
+	if err != nil {
+		return nil, err
+	}
+	return q.decryptCharacters(chars)
+}
+
+func (q *EncryptedQueries) UpsertCharacter(ctx context.Context, arg UpsertCharacterParams) error {
+	arg.AccessToken, arg.RefreshToken = q.cipher.encryptTokens(arg.ID, arg.AccessToken, arg.RefreshToken)
+	return q.Queries.UpsertCharacter(ctx, arg)
+}
+
+func (q *EncryptedQueries) UpdateCharacterTokens(ctx context.Context, arg UpdateCharacterTokensParams) error {
+	arg.AccessToken, arg.RefreshToken = q.cipher.encryptTokens(arg.ID, arg.AccessToken, arg.RefreshToken)
+	return q.Queries.UpdateCharacterTokens(ctx, arg)
+}
+
+func (q *EncryptedQueries) decryptCharacter(c Character) (Character, error) {
+	access, refresh, err := q.cipher.decryptTokens(c.ID, c.AccessToken, c.RefreshToken)
+	if err != nil {
+		return Character{}, err
+	}
+	c.AccessToken, c.RefreshToken = access, refresh
+	return c, nil
+}
+
+func (q *EncryptedQueries) decryptCharacters(chars []Character) ([]Character, error) {
+	for i := range chars {
+		c, err := q.decryptCharacter(chars[i])
+		if err != nil {
+			return nil, err
+		}
+		chars[i] = c
+	}
+	return chars, nil
+}
@@ -46,6 +46,15 @@ func (q *Queries) DeleteJobByID(ctx context.Context, id int64) error {
 	return err
 }
 
+const deleteJobsByBlueprintID = `-- name: DeleteJobsByBlueprintID :exec
+DELETE FROM jobs WHERE blueprint_id = ?
+`
+
+func (q *Queries) DeleteJobsByBlueprintID(ctx context.Context, blueprintID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteJobsByBlueprintID, blueprintID)
+	return err
+}
+
 const deleteJobsByOwner = `-- name: DeleteJobsByOwner :exec
 DELETE FROM jobs WHERE owner_type = ? AND owner_id = ?
 `
@@ -100,28 +109,41 @@ func (q *Queries) ListCharacterSlotUsage(ctx context.Context) ([]ListCharacterSl
 	return items, nil
 }
 
-const listJobIDsByOwner = `-- name: ListJobIDsByOwner :many
-SELECT id FROM jobs WHERE owner_type = ? AND owner_id = ?
+const listJobsByOwner = `-- name: ListJobsByOwner :many
+SELECT id, blueprint_id, owner_type, owner_id, installer_id, activity, status, start_date, end_date, updated_at
+FROM jobs
+WHERE owner_type = ? AND owner_id = ?
 `
 
-type ListJobIDsByOwnerParams struct {
+type ListJobsByOwnerParams struct {
 	OwnerType string
 	OwnerID   int64
 }
 
-func (q *Queries) ListJobIDsByOwner(ctx context.Context, arg ListJobIDsByOwnerParams) ([]int64, error) {
-	rows, err := q.db.QueryContext(ctx, listJobIDsByOwner, arg.OwnerType, arg.OwnerID)
+func (q *Queries) ListJobsByOwner(ctx context.Context, arg ListJobsByOwnerParams) ([]Job, error) {
+	rows, err := q.db.QueryContext(ctx, listJobsByOwner, arg.OwnerType, arg.OwnerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []int64
+	var items []Job
 	for rows.Next() {
-		var id int64
-		if err := rows.Scan(&id); err != nil {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.BlueprintID,
+			&i.OwnerType,
+			&i.OwnerID,
+			&i.InstallerID,
+			&i.Activity,
+			&i.Status,
+			&i.StartDate,
+			&i.EndDate,
+			&i.UpdatedAt,
+		); err != nil {
 			return nil, err
 		}
-		items = append(items, id)
+		items = append(items, i)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
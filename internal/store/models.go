@@ -9,6 +9,54 @@ import (
 	"time"
 )
 
+type AlertHistory struct {
+	ID          int64
+	RuleID      int64
+	Subject     string
+	Description string
+	Channels    string
+	Error       sql.NullString
+	FiredAt     time.Time
+}
+
+type AlertRule struct {
+	ID         int64
+	Name       string
+	Expression string
+	Channels   string
+	Cooldown   int64
+	QuietStart string
+	QuietEnd   string
+	Timezone   string
+	Enabled    int64
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type AffiliationEvent struct {
+	ID            int64
+	CharacterID   int64
+	CharacterName string
+	CorporationID int64
+	Event         string
+	OldID         sql.NullInt64
+	OldName       sql.NullString
+	NewID         sql.NullInt64
+	NewName       sql.NullString
+	CreatedAt     time.Time
+}
+
+type Asset struct {
+	ItemID       int64
+	OwnerID      int64
+	LocationID   int64
+	LocationType string
+	OwnerType    string
+	LocationFlag string
+	TypeID       int64
+	Name         sql.NullString
+}
+
 type Blueprint struct {
 	ID           int64
 	OwnerType    string
@@ -21,6 +69,48 @@ type Blueprint struct {
 	LocationFlag string
 }
 
+type BlueprintEvent struct {
+	ID          int64
+	BlueprintID int64
+	OwnerType   string
+	OwnerID     int64
+	TypeID      int64
+	Event       string
+	OldValue    sql.NullString
+	NewValue    sql.NullString
+	CreatedAt   time.Time
+}
+
+type BlueprintRemoval struct {
+	BlueprintID int64
+	OwnerType   string
+	OwnerID     int64
+	TypeID      int64
+	LocationID  int64
+	MeLevel     int64
+	TeLevel     int64
+	RemovedAt   time.Time
+}
+
+type BlueprintIdle struct {
+	BlueprintID int64
+	IdleSince   time.Time
+}
+
+type BlueprintLocation struct {
+	BlueprintID    int64
+	RootLocationID int64
+	Path           string
+}
+
+type CalendarFeed struct {
+	ID        int64
+	Name      string
+	Token     string
+	Filters   string
+	CreatedAt time.Time
+}
+
 type Character struct {
 	ID              int64
 	Name            string
@@ -30,20 +120,30 @@ type Character struct {
 	CreatedAt       time.Time
 	CorporationID   int64
 	CorporationName string
+	NeedsReauth     int64
+	NeedsReauthAt   sql.NullTime
+	Scopes          string
+	OwnerHash       string
+	Transferred     int64
+	TransferredAt   sql.NullTime
+	AllianceID      sql.NullInt64
+	AllianceName    sql.NullString
+	AffiliationAt   sql.NullTime
+	Roles           sql.NullString
 }
 
-type CorpAsset struct {
-	ItemID       int64
-	OwnerID      int64
-	LocationID   int64
-	LocationType string
+type CorpDivision struct {
+	CorporationID int64
+	Division      int64
+	Name          string
 }
 
 type Corporation struct {
-	ID         int64
-	Name       string
-	DelegateID int64
-	CreatedAt  time.Time
+	ID                int64
+	Name              string
+	DelegateID        sql.NullInt64
+	CreatedAt         time.Time
+	ActiveCharacterID sql.NullInt64
 }
 
 type EveCategory struct {
@@ -58,9 +158,21 @@ type EveGroup struct {
 }
 
 type EveLocation struct {
-	ID         int64
-	Name       string
-	ResolvedAt time.Time
+	ID            int64
+	Name          string
+	ResolvedAt    time.Time
+	SolarSystemID sql.NullInt64
+}
+
+type EveSystem struct {
+	ID                int64
+	Name              string
+	SecurityStatus    float64
+	ConstellationID   int64
+	ConstellationName string
+	RegionID          int64
+	RegionName        string
+	ResolvedAt        time.Time
 }
 
 type EveType struct {
@@ -82,11 +194,75 @@ type Job struct {
 	UpdatedAt   time.Time
 }
 
+type NotificationLog struct {
+	Kind    string
+	Subject string
+	SentAt  time.Time
+}
+
+type PushKey struct {
+	ID         int64
+	PrivateKey string
+	PublicKey  string
+	CreatedAt  time.Time
+}
+
+type PushSubscription struct {
+	ID        int64
+	Endpoint  string
+	P256dh    string
+	Auth      string
+	UserAgent string
+	CreatedAt time.Time
+}
+
+type StructureAccess struct {
+	StructureID int64
+	CharacterID int64
+	Status      string
+	CheckedAt   time.Time
+}
+
 type SyncState struct {
-	OwnerType  string
-	OwnerID    int64
-	Endpoint   string
-	LastSync   time.Time
-	CacheUntil time.Time
-	LastError  sql.NullString
+	OwnerType    string
+	OwnerID      int64
+	Endpoint     string
+	LastSync     time.Time
+	CacheUntil   time.Time
+	LastError    sql.NullString
+	MissingScope sql.NullString
+}
+
+type TokenEncryption struct {
+	ID        int64
+	KeySource string
+	Salt      []byte
+	KeyCheck  string
+	UpdatedAt time.Time
+}
+
+type Webhook struct {
+	ID        int64
+	Name      string
+	Url       string
+	Events    string
+	Template  string
+	Headers   string
+	Secret    string
+	Enabled   int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type WebhookDelivery struct {
+	ID         int64
+	WebhookID  int64
+	EventID    int64
+	EventType  string
+	Attempts   int64
+	StatusCode sql.NullInt64
+	Error      sql.NullString
+	Response   string
+	DurationMs int64
+	CreatedAt  time.Time
 }
@@ -6,30 +6,86 @@ package store
 
 import (
 	"context"
+	"time"
 )
 
 type Querier interface {
+	AdoptCorporation(ctx context.Context, arg AdoptCorporationParams) error
+	ClearBusyBlueprints(ctx context.Context) error
+	ClearCharacterTransferred(ctx context.Context, id int64) error
+	// sqlc queries for the search_index full-text table.
+	ClearSearchIndex(ctx context.Context) error
 	CountIdleBlueprints(ctx context.Context) (int64, error)
 	CountReadyJobs(ctx context.Context) (int64, error)
+	CreateAlertHistory(ctx context.Context, arg CreateAlertHistoryParams) error
+	// sqlc queries for the alert_rules and alert_history tables.
+	CreateAlertRule(ctx context.Context, arg CreateAlertRuleParams) (AlertRule, error)
+	// sqlc queries for the calendar_feeds table.
+	CreateCalendarFeed(ctx context.Context, arg CreateCalendarFeedParams) (CalendarFeed, error)
+	// sqlc queries for the webhooks and webhook_deliveries tables.
+	CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error)
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error)
+	DeleteAlertHistoryBefore(ctx context.Context, firedAt time.Time) error
+	DeleteAlertRule(ctx context.Context, id int64) (int64, error)
+	DeleteAssetsByOwner(ctx context.Context, arg DeleteAssetsByOwnerParams) error
+	DeleteBlueprintByID(ctx context.Context, id int64) error
+	DeleteBlueprintRemoval(ctx context.Context, blueprintID int64) error
+	DeleteBlueprintLocations(ctx context.Context, arg DeleteBlueprintLocationsParams) error
 	DeleteBlueprintsByOwner(ctx context.Context, arg DeleteBlueprintsByOwnerParams) error
+	DeleteCalendarFeed(ctx context.Context, id int64) (int64, error)
 	DeleteCharacter(ctx context.Context, id int64) error
-	DeleteCorpAssetsByOwner(ctx context.Context, ownerID int64) error
+	DeleteCorpDivisions(ctx context.Context, corporationID int64) error
 	DeleteCorporation(ctx context.Context, id int64) error
 	DeleteJobByID(ctx context.Context, id int64) error
+	DeleteJobsByBlueprintID(ctx context.Context, blueprintID int64) error
 	DeleteJobsByOwner(ctx context.Context, arg DeleteJobsByOwnerParams) error
+	DeleteNotification(ctx context.Context, arg DeleteNotificationParams) error
+	DeleteNotificationsBefore(ctx context.Context, sentAt time.Time) error
+	DeletePushSubscription(ctx context.Context, id int64) (int64, error)
+	DeletePushSubscriptionByEndpoint(ctx context.Context, endpoint string) error
 	DeleteSyncStateByOwner(ctx context.Context, arg DeleteSyncStateByOwnerParams) error
+	DeleteWebhook(ctx context.Context, id int64) (int64, error)
+	DeleteWebhookDeliveriesBefore(ctx context.Context, createdAt time.Time) error
+	GetAlertRule(ctx context.Context, id int64) (AlertRule, error)
+	GetAsset(ctx context.Context, itemID int64) (Asset, error)
+	GetBlueprint(ctx context.Context, id int64) (Blueprint, error)
+	GetBlueprintRemoval(ctx context.Context, blueprintID int64) (BlueprintRemoval, error)
+	// The type name of a blueprint, for notifications about its jobs.
+	GetBlueprintTypeName(ctx context.Context, id int64) (string, error)
 	// sqlc queries for the characters table.
 	// See https://docs.sqlc.dev for query annotation syntax.
+	GetCalendarFeedByToken(ctx context.Context, token string) (CalendarFeed, error)
 	GetCharacter(ctx context.Context, id int64) (Character, error)
-	GetCorpAsset(ctx context.Context, itemID int64) (GetCorpAssetRow, error)
+	GetCorpDivisionName(ctx context.Context, arg GetCorpDivisionNameParams) (string, error)
 	// sqlc queries for the corporations table.
 	// See https://docs.sqlc.dev for query annotation syntax.
 	GetCorporation(ctx context.Context, id int64) (Corporation, error)
+	GetEveCategory(ctx context.Context, id int64) (EveCategory, error)
+	GetEveGroup(ctx context.Context, id int64) (EveGroup, error)
+	GetEveSystem(ctx context.Context, id int64) (EveSystem, error)
 	GetEveType(ctx context.Context, id int64) (EveType, error)
+	GetLastAlertFiring(ctx context.Context, arg GetLastAlertFiringParams) (time.Time, error)
 	GetLocation(ctx context.Context, id int64) (EveLocation, error)
+	// sqlc queries for the notification_log and blueprint_idle tables.
+	GetNotification(ctx context.Context, arg GetNotificationParams) (NotificationLog, error)
+	// sqlc queries for the push_keys and push_subscriptions tables.
+	GetPushKeys(ctx context.Context) (PushKey, error)
 	// sqlc queries for the sync_state table.
 	// See https://docs.sqlc.dev for query annotation syntax.
 	GetSyncState(ctx context.Context, arg GetSyncStateParams) (SyncState, error)
+	// sqlc queries for token encryption: the key settings row and the raw token
+	// columns of the characters table, used to encrypt and re-key stored tokens.
+	GetTokenEncryption(ctx context.Context) (TokenEncryption, error)
+	GetWebhook(ctx context.Context, id int64) (Webhook, error)
+	IndexBlueprints(ctx context.Context) error
+	IndexCharacters(ctx context.Context) error
+	IndexCorporations(ctx context.Context) error
+	IndexLocations(ctx context.Context) error
+	// sqlc queries for the affiliation_events table.
+	InsertAffiliationEvent(ctx context.Context, arg InsertAffiliationEventParams) error
+	// sqlc queries for the blueprint_events table.
+	InsertBlueprintEvent(ctx context.Context, arg InsertBlueprintEventParams) error
+	InsertBlueprintRemoval(ctx context.Context, arg InsertBlueprintRemovalParams) error
 	InsertCorporation(ctx context.Context, arg InsertCorporationParams) error
 	// sqlc queries for eve_types, eve_groups, eve_categories tables.
 	// See https://docs.sqlc.dev for query annotation syntax.
@@ -38,29 +94,82 @@ type Querier interface {
 	InsertEveType(ctx context.Context, arg InsertEveTypeParams) error
 	InsertLocation(ctx context.Context, arg InsertLocationParams) error
 	InsertOrIgnoreCorporation(ctx context.Context, arg InsertOrIgnoreCorporationParams) error
+	InsertPushKeys(ctx context.Context, arg InsertPushKeysParams) error
+	ListAffiliationEventsSince(ctx context.Context, since time.Time) ([]AffiliationEvent, error)
+	ListAlertHistory(ctx context.Context, limit int64) ([]AlertHistory, error)
+	ListAlertHistoryByRule(ctx context.Context, arg ListAlertHistoryByRuleParams) ([]AlertHistory, error)
+	ListAlertRules(ctx context.Context) ([]AlertRule, error)
+	ListBlueprintEventsSince(ctx context.Context, since time.Time) ([]ListBlueprintEventsSinceRow, error)
+	// The number of blueprints in each category, for the category facet of GET /api/blueprints.
+	ListBlueprintCategoryCounts(ctx context.Context) ([]ListBlueprintCategoryCountsRow, error)
 	ListBlueprintLocationIDsByOwner(ctx context.Context, arg ListBlueprintLocationIDsByOwnerParams) ([]int64, error)
 	ListBlueprintLocationsByOwner(ctx context.Context, arg ListBlueprintLocationsByOwnerParams) ([]ListBlueprintLocationsByOwnerRow, error)
+	// The number of blueprints of each owner, for the owner facet of GET /api/blueprints.
+	ListBlueprintOwnerCounts(ctx context.Context) ([]ListBlueprintOwnerCountsRow, error)
+	// The number of blueprints in each region, for the region facet of GET /api/blueprints.
+	// Blueprints in locations not resolved yet are in none.
+	ListBlueprintRegionCounts(ctx context.Context) ([]ListBlueprintRegionCountsRow, error)
+	ListBlueprintRemovals(ctx context.Context) ([]BlueprintRemoval, error)
 	ListBlueprintTypeIDsByOwner(ctx context.Context, arg ListBlueprintTypeIDsByOwnerParams) ([]int64, error)
 	ListBlueprints(ctx context.Context, arg ListBlueprintsParams) ([]ListBlueprintsRow, error)
+	ListBlueprintsByOwner(ctx context.Context, arg ListBlueprintsByOwnerParams) ([]Blueprint, error)
+	ListCalendarFeeds(ctx context.Context) ([]CalendarFeed, error)
 	ListCharacterSlotUsage(ctx context.Context) ([]ListCharacterSlotUsageRow, error)
+	ListCharacterTokens(ctx context.Context) ([]ListCharacterTokensRow, error)
 	ListCharacters(ctx context.Context) ([]Character, error)
 	ListCharactersByCorporation(ctx context.Context, corporationID int64) ([]Character, error)
 	ListCharactersWithMeta(ctx context.Context) ([]ListCharactersWithMetaRow, error)
 	ListCorporations(ctx context.Context) ([]ListCorporationsRow, error)
-	ListJobIDsByOwner(ctx context.Context, arg ListJobIDsByOwnerParams) ([]int64, error)
+	ListIdleBlueprintsSince(ctx context.Context, idleSince time.Time) ([]BlueprintIdle, error)
+	ListJobsByOwner(ctx context.Context, arg ListJobsByOwnerParams) ([]Job, error)
+	ListPushSubscriptions(ctx context.Context) ([]PushSubscription, error)
+	// sqlc queries for the structure_access table.
+	ListStructureAccess(ctx context.Context, structureID int64) ([]StructureAccess, error)
 	ListSyncStatus(ctx context.Context) ([]ListSyncStatusRow, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	MarkCharacterNeedsReauth(ctx context.Context, id int64) error
+	MarkCharacterTransferred(ctx context.Context, id int64) error
+	MarkIdleBlueprints(ctx context.Context, idleSince time.Time) error
+	OrphanCorporation(ctx context.Context, id int64) error
+	RecordNotification(ctx context.Context, arg RecordNotificationParams) error
+	// Ranked hits for an FTS5 query, at most per_type of each entity type, best
+	// first. Matches are wrapped in char(2) … char(3). Entries of entities deleted
+	// since the last rebuild are skipped.
+	SearchIndex(ctx context.Context, arg SearchIndexParams) ([]SearchIndexRow, error)
+	SetCharacterTokenColumns(ctx context.Context, arg SetCharacterTokenColumnsParams) error
+	SetCorporationActiveCharacter(ctx context.Context, arg SetCorporationActiveCharacterParams) error
+	SetWebhookSecret(ctx context.Context, arg SetWebhookSecretParams) (Webhook, error)
+	UpdateAlertRule(ctx context.Context, arg UpdateAlertRuleParams) (AlertRule, error)
+	UpdateCharacterAffiliation(ctx context.Context, arg UpdateCharacterAffiliationParams) error
+	UpdateCharacterRoles(ctx context.Context, arg UpdateCharacterRolesParams) error
+	// A changed owner hash flags the character as transferred in the same
+	// statement; one stored before owner hashes were recorded (empty) does not.
+	UpdateCharacterTokens(ctx context.Context, arg UpdateCharacterTokensParams) error
 	UpdateCorporationDelegate(ctx context.Context, arg UpdateCorporationDelegateParams) error
 	UpdateSyncStateError(ctx context.Context, arg UpdateSyncStateErrorParams) error
+	UpdateSyncStateMissingScope(ctx context.Context, arg UpdateSyncStateMissingScopeParams) error
+	UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error)
+	// sqlc queries for the assets table.
+	UpsertAsset(ctx context.Context, arg UpsertAssetParams) error
 	// sqlc queries for the blueprints table.
 	// See https://docs.sqlc.dev for query annotation syntax.
 	UpsertBlueprint(ctx context.Context, arg UpsertBlueprintParams) error
+	// sqlc queries for the blueprint_locations table.
+	UpsertBlueprintLocations(ctx context.Context, arg UpsertBlueprintLocationsParams) error
+	// A changed owner hash flags the character as transferred in the same
+	// statement; one stored before owner hashes were recorded (empty) does not.
 	UpsertCharacter(ctx context.Context, arg UpsertCharacterParams) error
-	// sqlc queries for the corp_assets table.
-	UpsertCorpAsset(ctx context.Context, arg UpsertCorpAssetParams) error
+	// sqlc queries for the corp_divisions table.
+	UpsertCorpDivision(ctx context.Context, arg UpsertCorpDivisionParams) error
+	UpsertEveSystem(ctx context.Context, arg UpsertEveSystemParams) error
 	// sqlc queries for the jobs table.
 	// See https://docs.sqlc.dev for query annotation syntax.
 	UpsertJob(ctx context.Context, arg UpsertJobParams) error
+	UpsertPushSubscription(ctx context.Context, arg UpsertPushSubscriptionParams) (PushSubscription, error)
+	UpsertStructureAccess(ctx context.Context, arg UpsertStructureAccessParams) error
 	UpsertSyncState(ctx context.Context, arg UpsertSyncStateParams) error
+	UpsertTokenEncryption(ctx context.Context, arg UpsertTokenEncryptionParams) error
 }
 
 var _ Querier = (*Queries)(nil)
@@ -27,7 +27,7 @@ func (q *Queries) DeleteSyncStateByOwner(ctx context.Context, arg DeleteSyncStat
 
 const getSyncState = `-- name: GetSyncState :one
 
-SELECT owner_type, owner_id, endpoint, last_sync, cache_until, last_error
+SELECT owner_type, owner_id, endpoint, last_sync, cache_until, last_error, missing_scope
 FROM sync_state
 WHERE owner_type = ? AND owner_id = ? AND endpoint = ?
 `
@@ -50,6 +50,7 @@ func (q *Queries) GetSyncState(ctx context.Context, arg GetSyncStateParams) (Syn
 		&i.LastSync,
 		&i.CacheUntil,
 		&i.LastError,
+		&i.MissingScope,
 	)
 	return i, err
 }
@@ -61,7 +62,9 @@ SELECT
     COALESCE(c.name, corp.name, '') AS owner_name,
     ss.endpoint,
     ss.last_sync,
-    ss.cache_until
+    ss.cache_until,
+    ss.last_error,
+    ss.missing_scope
 FROM sync_state ss
 LEFT JOIN characters c ON ss.owner_type = 'character' AND c.id = ss.owner_id
 LEFT JOIN corporations corp ON ss.owner_type = 'corporation' AND corp.id = ss.owner_id
@@ -69,12 +72,14 @@ ORDER BY ss.owner_type, ss.owner_id, ss.endpoint
 `
 
 type ListSyncStatusRow struct {
-	OwnerType  string
-	OwnerID    int64
-	OwnerName  string
-	Endpoint   string
-	LastSync   time.Time
-	CacheUntil time.Time
+	OwnerType    string
+	OwnerID      int64
+	OwnerName    string
+	Endpoint     string
+	LastSync     time.Time
+	CacheUntil   time.Time
+	LastError    sql.NullString
+	MissingScope sql.NullString
 }
 
 func (q *Queries) ListSyncStatus(ctx context.Context) ([]ListSyncStatusRow, error) {
@@ -93,6 +98,8 @@ func (q *Queries) ListSyncStatus(ctx context.Context) ([]ListSyncStatusRow, erro
 			&i.Endpoint,
 			&i.LastSync,
 			&i.CacheUntil,
+			&i.LastError,
+			&i.MissingScope,
 		); err != nil {
 			return nil, err
 		}
@@ -130,12 +137,38 @@ func (q *Queries) UpdateSyncStateError(ctx context.Context, arg UpdateSyncStateE
 	return err
 }
 
+const updateSyncStateMissingScope = `-- name: UpdateSyncStateMissingScope :exec
+INSERT INTO sync_state (owner_type, owner_id, endpoint, last_sync, cache_until, missing_scope)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?)
+ON CONFLICT(owner_type, owner_id, endpoint) DO UPDATE SET
+    missing_scope = excluded.missing_scope,
+    last_error    = NULL
+`
+
+type UpdateSyncStateMissingScopeParams struct {
+	OwnerType    string
+	OwnerID      int64
+	Endpoint     string
+	MissingScope sql.NullString
+}
+
+func (q *Queries) UpdateSyncStateMissingScope(ctx context.Context, arg UpdateSyncStateMissingScopeParams) error {
+	_, err := q.db.ExecContext(ctx, updateSyncStateMissingScope,
+		arg.OwnerType,
+		arg.OwnerID,
+		arg.Endpoint,
+		arg.MissingScope,
+	)
+	return err
+}
+
 const upsertSyncState = `-- name: UpsertSyncState :exec
 INSERT INTO sync_state (owner_type, owner_id, endpoint, last_sync, cache_until)
 VALUES (?, ?, ?, ?, ?)
 ON CONFLICT(owner_type, owner_id, endpoint) DO UPDATE SET
-    last_sync   = excluded.last_sync,
-    cache_until = excluded.cache_until
+    last_sync     = excluded.last_sync,
+    cache_until   = excluded.cache_until,
+    missing_scope = NULL
 `
 
 type UpsertSyncStateParams struct {
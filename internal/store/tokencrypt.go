@@ -0,0 +1,313 @@
+package store
+
+// tokencrypt.go: encryption at rest for the OAuth tokens in the characters
+// table. Not generated by sqlc.
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Key sources recorded in token_encryption.key_source.
+const (
+	KeySourcePassphrase = "passphrase"
+	KeySourceKeyFile    = "key_file"
+)
+
+// TokenKeySize is the size in bytes of a raw token encryption key (AES-256).
+const TokenKeySize = 32
+
+const (
+	// encryptedTokenPrefix marks a token column value as ciphertext and
+	// versions its format: base64(nonce || AES-GCM ciphertext).
+	encryptedTokenPrefix = "enc:v1:"
+
+	// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256.
+	pbkdf2Iterations = 600_000
+	saltSize         = 16
+
+	// keyCheckValue is encrypted with the key and stored in token_encryption
+	// so a wrong passphrase or key file is detected before any token is read.
+	keyCheckValue = "auspex token key check"
+)
+
+// ErrWrongTokenKey is returned when the supplied passphrase or key file does
+// not match the key the stored tokens were encrypted with.
+var ErrWrongTokenKey = errors.New("token encryption key does not match the database")
+
+// TokenKey is the secret token encryption is keyed with: either a passphrase
+// (stretched with PBKDF2) or a raw TokenKeySize-byte key read from a key file.
+type TokenKey struct {
+	Passphrase string
+	Key        []byte
+}
+
+func (k TokenKey) source() string {
+	if k.Passphrase != "" {
+		return KeySourcePassphrase
+	}
+	return KeySourceKeyFile
+}
+
+// cipher returns the TokenCipher for k. salt is only used for passphrases.
+func (k TokenKey) cipher(salt []byte) (*TokenCipher, error) {
+	if k.Passphrase == "" {
+		return NewTokenCipher(k.Key)
+	}
+	key, err := pbkdf2.Key(sha256.New, k.Passphrase, salt, pbkdf2Iterations, TokenKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("deriving key from passphrase: %w", err)
+	}
+	return NewTokenCipher(key)
+}
+
+// TokenCipher encrypts and decrypts token column values with AES-256-GCM.
+// Each value is bound to its character and column, so ciphertext copied to
+// another row or column does not decrypt.
+type TokenCipher struct {
+	aead cipher.AEAD
+}
+
+// NewTokenCipher returns a TokenCipher for a TokenKeySize-byte key.
+func NewTokenCipher(key []byte) (*TokenCipher, error) {
+	if len(key) != TokenKeySize {
+		return nil, fmt.Errorf("token key must be %d bytes, got %d", TokenKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenCipher{aead: aead}, nil
+}
+
+// NewTokenKey returns a random TokenKeySize-byte key for a key file.
+func NewTokenKey() []byte {
+	return randomBytes(TokenKeySize)
+}
+
+func (c *TokenCipher) encrypt(plaintext, aad string) string {
+	nonce := randomBytes(c.aead.NonceSize())
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+	return encryptedTokenPrefix + base64.StdEncoding.EncodeToString(sealed)
+}
+
+func (c *TokenCipher) decrypt(value, aad string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, encryptedTokenPrefix)
+	if !ok {
+		return "", fmt.Errorf("value is not encrypted")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decoding ciphertext: %w", err)
+	}
+	n := c.aead.NonceSize()
+	if len(sealed) < n {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:n], sealed[n:], []byte(aad))
+	if err != nil {
+		return "", ErrWrongTokenKey
+	}
+	return string(plaintext), nil
+}
+
+// encryptTokens returns the stored form of a character's token pair.
+func (c *TokenCipher) encryptTokens(id int64, accessToken, refreshToken string) (string, string) {
+	return c.encrypt(accessToken, tokenAAD(id, "access_token")), c.encrypt(refreshToken, tokenAAD(id, "refresh_token"))
+}
+
+// decryptTokens reverses encryptTokens.
+func (c *TokenCipher) decryptTokens(id int64, accessToken, refreshToken string) (string, string, error) {
+	access, err := c.decrypt(accessToken, tokenAAD(id, "access_token"))
+	if err != nil {
+		return "", "", fmt.Errorf("decrypting access token of character %d: %w", id, err)
+	}
+	refresh, err := c.decrypt(refreshToken, tokenAAD(id, "refresh_token"))
+	if err != nil {
+		return "", "", fmt.Errorf("decrypting refresh token of character %d: %w", id, err)
+	}
+	return access, refresh, nil
+}
+
+func tokenAAD(id int64, column string) string {
+	return strconv.FormatInt(id, 10) + "/" + column
+}
+
+// TokenKeySource returns the key source the stored tokens are encrypted with
+// (KeySourcePassphrase or KeySourceKeyFile), or "" if encryption has not been
+// set up yet.
+func TokenKeySource(ctx context.Context, db *sql.DB) (string, error) {
+	row, err := New(db).GetTokenEncryption(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading token encryption settings: %w", err)
+	}
+	return row.KeySource, nil
+}
+
+// OpenTokenCipher returns the cipher for key after checking it against the
+// database. On first use the key is recorded; afterwards a key of another
+// source or a different key fails with an error wrapping ErrWrongTokenKey.
+func OpenTokenCipher(ctx context.Context, db *sql.DB, key TokenKey) (*TokenCipher, error) {
+	q := New(db)
+	row, err := q.GetTokenEncryption(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		c, params, err := newTokenEncryption(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := q.UpsertTokenEncryption(ctx, params); err != nil {
+			return nil, fmt.Errorf("saving token encryption settings: %w", err)
+		}
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading token encryption settings: %w", err)
+	}
+
+	if row.KeySource != key.source() {
+		return nil, fmt.Errorf("%w: tokens are encrypted with a %s, got a %s", ErrWrongTokenKey, row.KeySource, key.source())
+	}
+	c, err := key.cipher(row.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if check, err := c.decrypt(row.KeyCheck, "key_check"); err != nil || check != keyCheckValue {
+		return nil, ErrWrongTokenKey
+	}
+	return c, nil
+}
+
+// newTokenEncryption returns the cipher and settings row for a new key.
+func newTokenEncryption(key TokenKey) (*TokenCipher, UpsertTokenEncryptionParams, error) {
+	var salt []byte
+	if key.source() == KeySourcePassphrase {
+		salt = randomBytes(saltSize)
+	}
+	c, err := key.cipher(salt)
+	if err != nil {
+		return nil, UpsertTokenEncryptionParams{}, err
+	}
+	return c, UpsertTokenEncryptionParams{
+		KeySource: key.source(),
+		Salt:      salt,
+		KeyCheck:  c.encrypt(keyCheckValue, "key_check"),
+	}, nil
+}
+
+// EncryptPlaintextTokens encrypts every character whose tokens are still
+// stored in plaintext (written before encryption was introduced) and returns
+// how many were encrypted. It is safe to run on every start.
+func EncryptPlaintextTokens(ctx context.Context, db *sql.DB, c *TokenCipher) (int, error) {
+	return rewriteTokens(ctx, db, func(row ListCharacterTokensRow) (SetCharacterTokenColumnsParams, bool, error) {
+		if strings.HasPrefix(row.AccessToken, encryptedTokenPrefix) && strings.HasPrefix(row.RefreshToken, encryptedTokenPrefix) {
+			return SetCharacterTokenColumnsParams{}, false, nil
+		}
+		access, refresh := c.encryptTokens(row.ID, row.AccessToken, row.RefreshToken)
+		return SetCharacterTokenColumnsParams{AccessToken: access, RefreshToken: refresh, ID: row.ID}, true, nil
+	}, nil)
+}
+
+// Rekey re-encrypts all stored tokens from old to a new key in a single
+// transaction and returns the cipher for the new key.
+func Rekey(ctx context.Context, db *sql.DB, old *TokenCipher, key TokenKey) (*TokenCipher, error) {
+	c, params, err := newTokenEncryption(key)
+	if err != nil {
+		return nil, err
+	}
+	_, err = rewriteTokens(ctx, db, func(row ListCharacterTokensRow) (SetCharacterTokenColumnsParams, bool, error) {
+		access, refresh, err := old.decryptTokens(row.ID, row.AccessToken, row.RefreshToken)
+		if err != nil {
+			return SetCharacterTokenColumnsParams{}, false, err
+		}
+		access, refresh = c.encryptTokens(row.ID, access, refresh)
+		return SetCharacterTokenColumnsParams{AccessToken: access, RefreshToken: refresh, ID: row.ID}, true, nil
+	}, &params)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// rewriteTokens applies fn to every character's stored tokens in a
+// transaction, writing the rows fn reports as changed. If settings is non-nil
+// the token_encryption row is replaced in the same transaction.
+func rewriteTokens(
+	ctx context.Context,
+	db *sql.DB,
+	fn func(ListCharacterTokensRow) (SetCharacterTokenColumnsParams, bool, error),
+	settings *UpsertTokenEncryptionParams,
+) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after Commit
+
+	q := New(tx)
+	rows, err := q.ListCharacterTokens(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("listing character tokens: %w", err)
+	}
+	changed := 0
+	for _, row := range rows {
+		params, ok, err := fn(row)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if err := q.SetCharacterTokenColumns(ctx, params); err != nil {
+			return 0, fmt.Errorf("writing tokens of character %d: %w", row.ID, err)
+		}
+		changed++
+	}
+	if settings != nil {
+		if err := q.UpsertTokenEncryption(ctx, *settings); err != nil {
+			return 0, fmt.Errorf("saving token encryption settings: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing: %w", err)
+	}
+	return changed, nil
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	_, _ = rand.Read(b) // crypto/rand.Read never returns an error
+	return b
+}
+
+// Format redacts the tokens when a Character is printed, so they cannot leak
+// into logs through %v or %+v.
+func (c Character) Format(f fmt.State, _ rune) {
+	fmt.Fprintf(f, "{ID:%d Name:%q AccessToken:[redacted] RefreshToken:[redacted]}", c.ID, c.Name) //nolint:errcheck // formatting
+}
+
+// Format redacts the tokens when the parameters are printed.
+func (p UpsertCharacterParams) Format(f fmt.State, _ rune) {
+	fmt.Fprintf(f, "{ID:%d Name:%q AccessToken:[redacted] RefreshToken:[redacted]}", p.ID, p.Name) //nolint:errcheck // formatting
+}
+
+// Format redacts the tokens when the parameters are printed.
+func (p UpdateCharacterTokensParams) Format(f fmt.State, _ rune) {
+	fmt.Fprintf(f, "{ID:%d AccessToken:[redacted] RefreshToken:[redacted]}", p.ID) //nolint:errcheck // formatting
+}
@@ -0,0 +1,221 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+func seedTokens(t *testing.T, q store.Querier, id int64, access, refresh string) {
+	t.Helper()
+	if err := q.UpsertCharacter(context.Background(), store.UpsertCharacterParams{
+		ID:           id,
+		Name:         fmt.Sprintf("Pilot %d", id),
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenExpiry:  time.Now().Add(20 * time.Minute),
+	}); err != nil {
+		t.Fatalf("UpsertCharacter: %v", err)
+	}
+}
+
+func rawTokens(t *testing.T, sqlDB *sql.DB) []store.ListCharacterTokensRow {
+	t.Helper()
+	rows, err := store.New(sqlDB).ListCharacterTokens(context.Background())
+	if err != nil {
+		t.Fatalf("ListCharacterTokens: %v", err)
+	}
+	return rows
+}
+
+func TestEncryptedQueries_TokensEncryptedAtRest(t *testing.T) {
+	sqlDB := openTestDB(t)
+	ctx := context.Background()
+
+	c, err := store.OpenTokenCipher(ctx, sqlDB, store.TokenKey{Key: store.NewTokenKey()})
+	if err != nil {
+		t.Fatalf("OpenTokenCipher: %v", err)
+	}
+	q := store.NewEncrypted(sqlDB, c)
+	seedTokens(t, q, 90000001, "access-1", "refresh-1")
+
+	raw := rawTokens(t, sqlDB)
+	if len(raw) != 1 {
+		t.Fatalf("got %d rows, want 1", len(raw))
+	}
+	for _, v := range []string{raw[0].AccessToken, raw[0].RefreshToken} {
+		if !strings.HasPrefix(v, "enc:v1:") || strings.Contains(v, "access-1") || strings.Contains(v, "refresh-1") {
+			t.Errorf("stored token %q is not encrypted", v)
+		}
+	}
+
+	if err := q.UpdateCharacterTokens(ctx, store.UpdateCharacterTokensParams{
+		AccessToken:  "access-2",
+		RefreshToken: "refresh-2",
+		TokenExpiry:  time.Now().Add(20 * time.Minute),
+		ID:           90000001,
+	}); err != nil {
+		t.Fatalf("UpdateCharacterTokens: %v", err)
+	}
+
+	got, err := q.GetCharacter(ctx, 90000001)
+	if err != nil {
+		t.Fatalf("GetCharacter: %v", err)
+	}
+	if got.AccessToken != "access-2" || got.RefreshToken != "refresh-2" {
+		t.Errorf("tokens: got %q/%q, want access-2/refresh-2", got.AccessToken, got.RefreshToken)
+	}
+	list, err := q.ListCharacters(ctx)
+	if err != nil {
+		t.Fatalf("ListCharacters: %v", err)
+	}
+	if len(list) != 1 || list[0].RefreshToken != "refresh-2" {
+		t.Errorf("ListCharacters = %v, want one character with decrypted tokens", list)
+	}
+}
+
+// TestEncryptedQueries_SwappedCiphertextRejected verifies that ciphertext is
+// bound to its row: copying one character's tokens onto another fails.
+func TestEncryptedQueries_SwappedCiphertextRejected(t *testing.T) {
+	sqlDB := openTestDB(t)
+	ctx := context.Background()
+
+	c, err := store.OpenTokenCipher(ctx, sqlDB, store.TokenKey{Key: store.NewTokenKey()})
+	if err != nil {
+		t.Fatalf("OpenTokenCipher: %v", err)
+	}
+	q := store.NewEncrypted(sqlDB, c)
+	seedTokens(t, q, 1, "a1", "r1")
+	seedTokens(t, q, 2, "a2", "r2")
+
+	raw := rawTokens(t, sqlDB)
+	if err := store.New(sqlDB).SetCharacterTokenColumns(ctx, store.SetCharacterTokenColumnsParams{
+		AccessToken:  raw[0].AccessToken,
+		RefreshToken: raw[0].RefreshToken,
+		ID:           2,
+	}); err != nil {
+		t.Fatalf("SetCharacterTokenColumns: %v", err)
+	}
+	if _, err := q.GetCharacter(ctx, 2); err == nil {
+		t.Error("expected an error decrypting tokens copied from another character")
+	}
+}
+
+func TestOpenTokenCipher_WrongKey(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("key file", func(t *testing.T) {
+		sqlDB := openTestDB(t)
+		if _, err := store.OpenTokenCipher(ctx, sqlDB, store.TokenKey{Key: store.NewTokenKey()}); err != nil {
+			t.Fatalf("OpenTokenCipher: %v", err)
+		}
+		_, err := store.OpenTokenCipher(ctx, sqlDB, store.TokenKey{Key: store.NewTokenKey()})
+		if !errors.Is(err, store.ErrWrongTokenKey) {
+			t.Errorf("err = %v, want ErrWrongTokenKey", err)
+		}
+	})
+
+	t.Run("passphrase", func(t *testing.T) {
+		sqlDB := openTestDB(t)
+		if _, err := store.OpenTokenCipher(ctx, sqlDB, store.TokenKey{Passphrase: "correct horse"}); err != nil {
+			t.Fatalf("OpenTokenCipher: %v", err)
+		}
+		if _, err := store.OpenTokenCipher(ctx, sqlDB, store.TokenKey{Passphrase: "correct horse"}); err != nil {
+			t.Errorf("same passphrase: %v", err)
+		}
+		_, err := store.OpenTokenCipher(ctx, sqlDB, store.TokenKey{Passphrase: "battery staple"})
+		if !errors.Is(err, store.ErrWrongTokenKey) {
+			t.Errorf("err = %v, want ErrWrongTokenKey", err)
+		}
+		_, err = store.OpenTokenCipher(ctx, sqlDB, store.TokenKey{Key: store.NewTokenKey()})
+		if !errors.Is(err, store.ErrWrongTokenKey) {
+			t.Errorf("key file instead of passphrase: err = %v, want ErrWrongTokenKey", err)
+		}
+	})
+}
+
+// TestEncryptPlaintextTokens verifies that tokens written before encryption
+// was introduced are encrypted once and remain readable.
+func TestEncryptPlaintextTokens(t *testing.T) {
+	sqlDB := openTestDB(t)
+	ctx := context.Background()
+	seedTokens(t, store.New(sqlDB), 90000001, "plain-access", "plain-refresh")
+
+	c, err := store.OpenTokenCipher(ctx, sqlDB, store.TokenKey{Key: store.NewTokenKey()})
+	if err != nil {
+		t.Fatalf("OpenTokenCipher: %v", err)
+	}
+	n, err := store.EncryptPlaintextTokens(ctx, sqlDB, c)
+	if err != nil || n != 1 {
+		t.Fatalf("EncryptPlaintextTokens = %d, %v; want 1, nil", n, err)
+	}
+	if n, err := store.EncryptPlaintextTokens(ctx, sqlDB, c); err != nil || n != 0 {
+		t.Errorf("second run = %d, %v; want 0, nil", n, err)
+	}
+
+	if raw := rawTokens(t, sqlDB); strings.Contains(raw[0].AccessToken, "plain-access") {
+		t.Errorf("access token still stored in plaintext: %q", raw[0].AccessToken)
+	}
+	got, err := store.NewEncrypted(sqlDB, c).GetCharacter(ctx, 90000001)
+	if err != nil {
+		t.Fatalf("GetCharacter: %v", err)
+	}
+	if got.AccessToken != "plain-access" || got.RefreshToken != "plain-refresh" {
+		t.Errorf("tokens: got %q/%q, want plain-access/plain-refresh", got.AccessToken, got.RefreshToken)
+	}
+}
+
+func TestRekey(t *testing.T) {
+	sqlDB := openTestDB(t)
+	ctx := context.Background()
+	oldKey := store.TokenKey{Key: store.NewTokenKey()}
+
+	old, err := store.OpenTokenCipher(ctx, sqlDB, oldKey)
+	if err != nil {
+		t.Fatalf("OpenTokenCipher: %v", err)
+	}
+	seedTokens(t, store.NewEncrypted(sqlDB, old), 90000001, "access", "refresh")
+	before := rawTokens(t, sqlDB)
+
+	newKey := store.TokenKey{Passphrase: "new passphrase"}
+	if _, err := store.Rekey(ctx, sqlDB, old, newKey); err != nil {
+		t.Fatalf("Rekey: %v", err)
+	}
+	if after := rawTokens(t, sqlDB); after[0].AccessToken == before[0].AccessToken {
+		t.Error("access token ciphertext unchanged after rekey")
+	}
+
+	if _, err := store.OpenTokenCipher(ctx, sqlDB, oldKey); !errors.Is(err, store.ErrWrongTokenKey) {
+		t.Errorf("old key after rekey: err = %v, want ErrWrongTokenKey", err)
+	}
+	c, err := store.OpenTokenCipher(ctx, sqlDB, newKey)
+	if err != nil {
+		t.Fatalf("OpenTokenCipher with new key: %v", err)
+	}
+	got, err := store.NewEncrypted(sqlDB, c).GetCharacter(ctx, 90000001)
+	if err != nil {
+		t.Fatalf("GetCharacter: %v", err)
+	}
+	if got.AccessToken != "access" || got.RefreshToken != "refresh" {
+		t.Errorf("tokens: got %q/%q, want access/refresh", got.AccessToken, got.RefreshToken)
+	}
+}
+
+func TestCharacterFormat_RedactsTokens(t *testing.T) {
+	c := store.Character{ID: 1, Name: "Pilot", AccessToken: "secret-access", RefreshToken: "secret-refresh"}
+	var buf bytes.Buffer
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		fmt.Fprintf(&buf, format+"\n", c)
+	}
+	fmt.Fprintf(&buf, "%+v\n", store.UpdateCharacterTokensParams{AccessToken: "secret-access", RefreshToken: "secret-refresh"})
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("tokens printed:\n%s", buf.String())
+	}
+}
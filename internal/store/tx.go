@@ -0,0 +1,55 @@
+package store
+
+// tx.go: running several queries in one transaction. Not generated by sqlc.
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Transactor is implemented by the Queriers that can run queries in a
+// database transaction: Queries and EncryptedQueries.
+type Transactor interface {
+	// InTx calls fn with a Querier whose queries all run in one transaction,
+	// committed if fn returns nil and rolled back otherwise. Inside fn, use
+	// only that Querier: the database has a single connection, which the
+	// transaction holds until it ends.
+	InTx(ctx context.Context, fn func(Querier) error) error
+}
+
+var (
+	_ Transactor = (*Queries)(nil)
+	_ Transactor = (*EncryptedQueries)(nil)
+)
+
+func (q *Queries) InTx(ctx context.Context, fn func(Querier) error) error {
+	return inTx(ctx, q.db, func(tx *sql.Tx) error { return fn(q.WithTx(tx)) })
+}
+
+func (q *EncryptedQueries) InTx(ctx context.Context, fn func(Querier) error) error {
+	return inTx(ctx, q.Queries.db, func(tx *sql.Tx) error { return fn(q.WithTx(tx)) })
+}
+
+// inTx runs fn in a new transaction on db, or in the one db already is.
+func inTx(ctx context.Context, db DBTX, fn func(*sql.Tx) error) error {
+	switch db := db.(type) {
+	case *sql.Tx:
+		return fn(db)
+	case *sql.DB:
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("beginning transaction: %w", err)
+		}
+		defer tx.Rollback() //nolint:errcheck // no-op after Commit
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("committing transaction: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%T cannot begin a transaction", db)
+	}
+}
@@ -7,9 +7,53 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
+const getEveCategory = `-- name: GetEveCategory :one
+SELECT id, name FROM eve_categories WHERE id = ?
+`
+
+func (q *Queries) GetEveCategory(ctx context.Context, id int64) (EveCategory, error) {
+	row := q.db.QueryRowContext(ctx, getEveCategory, id)
+	var i EveCategory
+	err := row.Scan(&i.ID, &i.Name)
+	return i, err
+}
+
+const getEveGroup = `-- name: GetEveGroup :one
+SELECT id, category_id, name FROM eve_groups WHERE id = ?
+`
+
+func (q *Queries) GetEveGroup(ctx context.Context, id int64) (EveGroup, error) {
+	row := q.db.QueryRowContext(ctx, getEveGroup, id)
+	var i EveGroup
+	err := row.Scan(&i.ID, &i.CategoryID, &i.Name)
+	return i, err
+}
+
+const getEveSystem = `-- name: GetEveSystem :one
+SELECT id, name, security_status, constellation_id, constellation_name, region_id, region_name, resolved_at
+FROM eve_systems WHERE id = ?
+`
+
+func (q *Queries) GetEveSystem(ctx context.Context, id int64) (EveSystem, error) {
+	row := q.db.QueryRowContext(ctx, getEveSystem, id)
+	var i EveSystem
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.SecurityStatus,
+		&i.ConstellationID,
+		&i.ConstellationName,
+		&i.RegionID,
+		&i.RegionName,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
 const getEveType = `-- name: GetEveType :one
 SELECT id, group_id, name FROM eve_types WHERE id = ?
 `
@@ -22,13 +66,18 @@ func (q *Queries) GetEveType(ctx context.Context, id int64) (EveType, error) {
 }
 
 const getLocation = `-- name: GetLocation :one
-SELECT id, name, resolved_at FROM eve_locations WHERE id = ?
+SELECT id, name, resolved_at, solar_system_id FROM eve_locations WHERE id = ?
 `
 
 func (q *Queries) GetLocation(ctx context.Context, id int64) (EveLocation, error) {
 	row := q.db.QueryRowContext(ctx, getLocation, id)
 	var i EveLocation
-	err := row.Scan(&i.ID, &i.Name, &i.ResolvedAt)
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.ResolvedAt,
+		&i.SolarSystemID,
+	)
 	return i, err
 }
 
@@ -80,16 +129,52 @@ func (q *Queries) InsertEveType(ctx context.Context, arg InsertEveTypeParams) er
 }
 
 const insertLocation = `-- name: InsertLocation :exec
-INSERT OR REPLACE INTO eve_locations (id, name, resolved_at) VALUES (?, ?, ?)
+INSERT OR REPLACE INTO eve_locations (id, name, resolved_at, solar_system_id) VALUES (?, ?, ?, ?)
 `
 
 type InsertLocationParams struct {
-	ID         int64
-	Name       string
-	ResolvedAt time.Time
+	ID            int64
+	Name          string
+	ResolvedAt    time.Time
+	SolarSystemID sql.NullInt64
 }
 
 func (q *Queries) InsertLocation(ctx context.Context, arg InsertLocationParams) error {
-	_, err := q.db.ExecContext(ctx, insertLocation, arg.ID, arg.Name, arg.ResolvedAt)
+	_, err := q.db.ExecContext(ctx, insertLocation,
+		arg.ID,
+		arg.Name,
+		arg.ResolvedAt,
+		arg.SolarSystemID,
+	)
+	return err
+}
+
+const upsertEveSystem = `-- name: UpsertEveSystem :exec
+INSERT OR REPLACE INTO eve_systems (id, name, security_status, constellation_id, constellation_name, region_id, region_name, resolved_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type UpsertEveSystemParams struct {
+	ID                int64
+	Name              string
+	SecurityStatus    float64
+	ConstellationID   int64
+	ConstellationName string
+	RegionID          int64
+	RegionName        string
+	ResolvedAt        time.Time
+}
+
+func (q *Queries) UpsertEveSystem(ctx context.Context, arg UpsertEveSystemParams) error {
+	_, err := q.db.ExecContext(ctx, upsertEveSystem,
+		arg.ID,
+		arg.Name,
+		arg.SecurityStatus,
+		arg.ConstellationID,
+		arg.ConstellationName,
+		arg.RegionID,
+		arg.RegionName,
+		arg.ResolvedAt,
+	)
 	return err
 }
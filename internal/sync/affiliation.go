@@ -0,0 +1,266 @@
+package sync
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/esi"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// affiliationInterval is how often character affiliations are refreshed
+// outside a forced sync. ESI caches POST /characters/affiliation/ for an hour.
+const affiliationInterval = time.Hour
+
+// affiliation_events.event values.
+const (
+	affiliationEventCorporationChanged = "corporation_changed"
+	affiliationEventAllianceChanged    = "alliance_changed"
+	affiliationEventCorporationTracked = "corporation_tracked"
+	affiliationEventDelegateReassigned = "delegate_reassigned"
+	affiliationEventDelegateOrphaned   = "delegate_orphaned"
+)
+
+// affiliationChange is a character whose stored affiliation differs from ESI.
+type affiliationChange struct {
+	char store.Character
+	aff  esi.CharacterAffiliation
+}
+
+func (c affiliationChange) corporationChanged() bool {
+	return c.aff.CorporationID != c.char.CorporationID
+}
+
+func (c affiliationChange) allianceChanged() bool {
+	return c.aff.AllianceID != c.char.AllianceID.Int64
+}
+
+// refreshAffiliations fetches the current corporation and alliance of every
+// tracked character in bulk and applies the differences. When a character
+// has left a corporation it was the delegate of, the delegation passes to
+// another tracked member, or the corporation is orphaned (kept, but not
+// synced) if none is left. A new player corporation is tracked with the
+// character as its delegate, and an orphaned one is adopted by it. Every
+// change is recorded in affiliation_events.
+//
+// The first refresh of a character (affiliation_at NULL) fills its alliance
+// without logging it; a corporation change is always handled.
+func (w *Worker) refreshAffiliations(ctx context.Context) error {
+	chars, err := w.store.ListCharacters(ctx)
+	if err != nil {
+		return fmt.Errorf("listing characters: %w", err)
+	}
+	if len(chars) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(chars))
+	for i, c := range chars {
+		ids[i] = c.ID
+	}
+	affs, err := w.esi.PostCharactersAffiliation(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("fetching affiliations: %w", err)
+	}
+	current := make(map[int64]esi.CharacterAffiliation, len(affs))
+	for _, a := range affs {
+		current[a.CharacterID] = a
+	}
+
+	var changes []affiliationChange
+	for _, c := range chars {
+		a, ok := current[c.ID]
+		if !ok {
+			continue // deleted character or ESI error for this ID
+		}
+		change := affiliationChange{char: c, aff: a}
+		if change.corporationChanged() || change.allianceChanged() || !c.AffiliationAt.Valid {
+			changes = append(changes, change)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	names, err := w.affiliationNames(ctx, changes)
+	if err != nil {
+		return err
+	}
+
+	// All characters are moved before any delegation changes hands, so a
+	// delegate is never reassigned to a member who is leaving in the same batch.
+	now := w.now()
+	for _, c := range changes {
+		corpName := c.char.CorporationName
+		if c.corporationChanged() {
+			corpName = names[c.aff.CorporationID]
+		}
+		if err := w.store.UpdateCharacterAffiliation(ctx, store.UpdateCharacterAffiliationParams{
+			CorporationID:   c.aff.CorporationID,
+			CorporationName: corpName,
+			AllianceID:      sql.NullInt64{Int64: c.aff.AllianceID, Valid: c.aff.AllianceID != 0},
+			AllianceName:    sql.NullString{String: names[c.aff.AllianceID], Valid: c.aff.AllianceID != 0},
+			AffiliationAt:   sql.NullTime{Time: now.UTC(), Valid: true},
+			ID:              c.char.ID,
+		}); err != nil {
+			return fmt.Errorf("updating affiliation of character %d: %w", c.char.ID, err)
+		}
+
+		if c.allianceChanged() && c.char.AffiliationAt.Valid {
+			w.recordAffiliationEvent(ctx, c.char, c.aff.CorporationID, affiliationEventAllianceChanged,
+				c.char.AllianceID.Int64, c.char.AllianceName.String, c.aff.AllianceID, names[c.aff.AllianceID], now)
+		}
+		if c.corporationChanged() {
+			log.Printf("sync: character %d moved from corporation %d to %d", c.char.ID, c.char.CorporationID, c.aff.CorporationID)
+			w.recordAffiliationEvent(ctx, c.char, c.aff.CorporationID, affiliationEventCorporationChanged,
+				c.char.CorporationID, c.char.CorporationName, c.aff.CorporationID, corpName, now)
+		}
+	}
+
+	for _, c := range changes {
+		if !c.corporationChanged() {
+			continue
+		}
+		if err := w.handOverDelegate(ctx, c.char, now); err != nil {
+			return err
+		}
+		if !esi.IsNPCCorporation(c.aff.CorporationID) {
+			if err := w.trackCorporation(ctx, c.char, c.aff.CorporationID, names[c.aff.CorporationID], now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// affiliationNames resolves the new corporations and alliances in changes to
+// names with a single POST /universe/names/ call.
+func (w *Worker) affiliationNames(ctx context.Context, changes []affiliationChange) (map[int64]string, error) {
+	seen := make(map[int64]bool)
+	var ids []int64
+	add := func(id int64) {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, c := range changes {
+		if c.corporationChanged() {
+			add(c.aff.CorporationID)
+		}
+		add(c.aff.AllianceID)
+	}
+
+	entries, err := w.esi.PostUniverseNames(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("resolving corporation and alliance names: %w", err)
+	}
+	names := make(map[int64]string, len(entries))
+	for _, e := range entries {
+		names[e.ID] = e.Name
+	}
+	return names, nil
+}
+
+// handOverDelegate passes the delegation of the corporation char has left to
+// another tracked member, preferring one whose sync is not paused, or orphans
+// the corporation if no member is left. Nothing happens if char was not its
+// delegate.
+func (w *Worker) handOverDelegate(ctx context.Context, char store.Character, now time.Time) error {
+	corp, err := w.store.GetCorporation(ctx, char.CorporationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("loading corporation %d: %w", char.CorporationID, err)
+	}
+	if !corp.DelegateID.Valid || corp.DelegateID.Int64 != char.ID {
+		return nil
+	}
+
+	members, err := w.store.ListCharactersByCorporation(ctx, corp.ID)
+	if err != nil {
+		return fmt.Errorf("listing members of corporation %d: %w", corp.ID, err)
+	}
+	var next *store.Character
+	for i := range members {
+		m := &members[i]
+		if m.NeedsReauth == 0 && m.Transferred == 0 {
+			next = m
+			break
+		}
+		if next == nil {
+			next = m
+		}
+	}
+
+	if next == nil {
+		log.Printf("sync: corporation %d has no tracked member left; delegate cleared", corp.ID)
+		if err := w.store.OrphanCorporation(ctx, corp.ID); err != nil {
+			return fmt.Errorf("orphaning corporation %d: %w", corp.ID, err)
+		}
+		w.recordAffiliationEvent(ctx, char, corp.ID, affiliationEventDelegateOrphaned, char.ID, char.Name, 0, "", now)
+		return nil
+	}
+
+	if err := w.store.UpdateCorporationDelegate(ctx, store.UpdateCorporationDelegateParams{
+		DelegateID: sql.NullInt64{Int64: next.ID, Valid: true},
+		ID:         corp.ID,
+	}); err != nil {
+		return fmt.Errorf("reassigning delegate of corporation %d: %w", corp.ID, err)
+	}
+	w.recordAffiliationEvent(ctx, char, corp.ID, affiliationEventDelegateReassigned, char.ID, char.Name, next.ID, next.Name, now)
+	return nil
+}
+
+// trackCorporation makes char the delegate of the player corporation it has
+// joined: the corporation is added if it is not tracked yet, or adopted if it
+// was orphaned. A corporation that already has a delegate is left alone.
+func (w *Worker) trackCorporation(ctx context.Context, char store.Character, corpID int64, corpName string, now time.Time) error {
+	delegate := sql.NullInt64{Int64: char.ID, Valid: true}
+	corp, err := w.store.GetCorporation(ctx, corpID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if err := w.store.InsertCorporation(ctx, store.InsertCorporationParams{
+			ID:         corpID,
+			Name:       corpName,
+			DelegateID: delegate,
+		}); err != nil {
+			return fmt.Errorf("tracking corporation %d: %w", corpID, err)
+		}
+		w.recordAffiliationEvent(ctx, char, corpID, affiliationEventCorporationTracked, 0, "", char.ID, char.Name, now)
+	case err != nil:
+		return fmt.Errorf("loading corporation %d: %w", corpID, err)
+	case !corp.DelegateID.Valid:
+		if err := w.store.AdoptCorporation(ctx, store.AdoptCorporationParams{
+			DelegateID: delegate,
+			ID:         corpID,
+		}); err != nil {
+			return fmt.Errorf("assigning delegate of corporation %d: %w", corpID, err)
+		}
+		w.recordAffiliationEvent(ctx, char, corpID, affiliationEventDelegateReassigned, 0, "", char.ID, char.Name, now)
+	}
+	return nil
+}
+
+// recordAffiliationEvent appends one row to affiliation_events. A zero oldID
+// or newID is stored as NULL together with its name. Errors are logged and
+// otherwise ignored, as for blueprint_events.
+func (w *Worker) recordAffiliationEvent(ctx context.Context, char store.Character, corpID int64, event string, oldID int64, oldName string, newID int64, newName string, now time.Time) {
+	if err := w.store.InsertAffiliationEvent(ctx, store.InsertAffiliationEventParams{
+		CharacterID:   char.ID,
+		CharacterName: char.Name,
+		CorporationID: corpID,
+		Event:         event,
+		OldID:         sql.NullInt64{Int64: oldID, Valid: oldID != 0},
+		OldName:       sql.NullString{String: oldName, Valid: oldID != 0},
+		NewID:         sql.NullInt64{Int64: newID, Valid: newID != 0},
+		NewName:       sql.NullString{String: newName, Valid: newID != 0},
+		CreatedAt:     now.UTC(),
+	}); err != nil {
+		log.Printf("sync: recording %s event for character %d: %v", event, char.ID, err)
+	}
+}
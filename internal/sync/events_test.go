@@ -0,0 +1,232 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/esi"
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// drain returns the events already delivered to sub.
+func drain(sub *events.Subscription) []events.Event {
+	var out []events.Event
+	for {
+		select {
+		case e := <-sub.Events():
+			out = append(out, e)
+		default:
+			return out
+		}
+	}
+}
+
+// eventTypes returns the types of evs in order.
+func eventTypes(evs []events.Event) []string {
+	out := make([]string, 0, len(evs))
+	for _, e := range evs {
+		out = append(out, e.Type)
+	}
+	return out
+}
+
+// --- TestRunCycle_PublishesCycleEvents ---
+// Verifies that a cycle is bracketed by cycle_started and cycle_finished,
+// both carrying the force flag.
+func TestRunCycle_PublishesCycleEvents(t *testing.T) {
+	q := &mockQuerier{
+		listCharsFunc: oneChar(1),
+		listCorpsFunc: noCorps(),
+	}
+	bus := events.NewBus(16)
+	sub, _, _ := bus.Subscribe(0)
+
+	w := New(q, nil, nil, time.Minute)
+	w.SetEventBus(bus)
+	w.affiliationFn = noAffiliations
+	w.syncFn = func(context.Context, string, int64, string) {}
+
+	w.runCycle(context.Background(), true)
+
+	got := drain(sub)
+	if len(got) != 2 || got[0].Type != events.TypeCycleStarted || got[1].Type != events.TypeCycleFinished {
+		t.Fatalf("events = %v, want [cycle_started cycle_finished]", eventTypes(got))
+	}
+	for _, e := range got {
+		if c, ok := e.Data.(events.Cycle); !ok || !c.Forced {
+			t.Errorf("%s data = %#v, want Cycle{Forced: true}", e.Type, e.Data)
+		}
+	}
+}
+
+// --- TestSyncSubject_PublishesOutcome ---
+// Verifies that syncSubject publishes subject_synced on success and
+// subject_failed with the error on failure.
+func TestSyncSubject_PublishesOutcome(t *testing.T) {
+	fail := false
+	q := &mockQuerier{
+		listJobsByOwnerFunc: func(store.ListJobsByOwnerParams) ([]store.Job, error) { return nil, nil },
+		upsertSyncStateFunc: func(store.UpsertSyncStateParams) error { return nil },
+	}
+	esiMock := &mockESIClient{
+		charJobsFunc: func(context.Context, int64, string) ([]esi.Job, time.Time, error) {
+			if fail {
+				return nil, time.Time{}, errors.New("ESI 503: service unavailable")
+			}
+			return nil, time.Now().Add(time.Minute), nil
+		},
+	}
+	bus := events.NewBus(16)
+	sub, _, _ := bus.Subscribe(0)
+
+	w := New(q, esiMock, nil, time.Minute)
+	w.SetEventBus(bus)
+	w.syncSubject(context.Background(), ownerTypeCharacter, 7, endpointJobs)
+	fail = true
+	w.syncSubject(context.Background(), ownerTypeCharacter, 7, endpointJobs)
+
+	got := drain(sub)
+	if len(got) != 2 {
+		t.Fatalf("events = %v, want [subject_synced subject_failed]", eventTypes(got))
+	}
+	want := events.Subject{OwnerType: ownerTypeCharacter, OwnerID: 7, Endpoint: endpointJobs}
+	if got[0].Type != events.TypeSubjectSynced || got[0].Data != want {
+		t.Errorf("first event = %s %#v, want subject_synced %#v", got[0].Type, got[0].Data, want)
+	}
+	want.Error = "fetching jobs: ESI 503: service unavailable"
+	if got[1].Type != events.TypeSubjectFailed || got[1].Data != want {
+		t.Errorf("second event = %s %#v, want subject_failed %#v", got[1].Type, got[1].Data, want)
+	}
+}
+
+// --- TestSyncJobs_PublishesJobReady ---
+// Verifies that job_ready is published once for a job that became ready since
+// the last sync — by status or by passing its end date — and not for jobs that
+// were ready already or are still running.
+func TestSyncJobs_PublishesJobReady(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	lastSync := now.Add(-20 * time.Minute)
+
+	stored := []store.Job{
+		// Ended between the syncs; ESI still says active.
+		{ID: 1, Status: "active", EndDate: now.Add(-5 * time.Minute), UpdatedAt: lastSync},
+		// ESI flipped it to ready.
+		{ID: 2, Status: "active", EndDate: now.Add(-time.Minute), UpdatedAt: lastSync},
+		// Was ready at the last sync already.
+		{ID: 3, Status: "active", EndDate: now.Add(-time.Hour), UpdatedAt: lastSync},
+		// Still running.
+		{ID: 4, Status: "active", EndDate: now.Add(time.Hour), UpdatedAt: lastSync},
+	}
+	incoming := []esi.Job{
+		{JobID: 1, BlueprintID: 101, InstallerID: 9, Activity: "me_research", Status: "active", EndDate: stored[0].EndDate},
+		{JobID: 2, BlueprintID: 102, InstallerID: 9, Activity: "copying", Status: "ready", EndDate: stored[1].EndDate},
+		{JobID: 3, BlueprintID: 103, InstallerID: 9, Activity: "te_research", Status: "ready", EndDate: stored[2].EndDate},
+		{JobID: 4, BlueprintID: 104, InstallerID: 9, Activity: "copying", Status: "active", EndDate: stored[3].EndDate},
+		// New job, installed and finished between the syncs.
+		{JobID: 5, BlueprintID: 105, InstallerID: 9, Activity: "copying", Status: "active", EndDate: now.Add(-2 * time.Minute)},
+	}
+
+	q := &mockQuerier{
+		listJobsByOwnerFunc: func(store.ListJobsByOwnerParams) ([]store.Job, error) { return stored, nil },
+		upsertJobFunc:       func(store.UpsertJobParams) error { return nil },
+	}
+	esiMock := &mockESIClient{
+		charJobsFunc: func(context.Context, int64, string) ([]esi.Job, time.Time, error) {
+			return incoming, now.Add(time.Minute), nil
+		},
+	}
+	bus := events.NewBus(16)
+	sub, _, _ := bus.Subscribe(0)
+
+	w := New(q, esiMock, nil, time.Minute)
+	w.now = func() time.Time { return now }
+	w.SetEventBus(bus)
+	if _, err := w.syncJobs(context.Background(), ownerTypeCharacter, 9); err != nil {
+		t.Fatalf("syncJobs: %v", err)
+	}
+
+	var ready []int64
+	for _, e := range drain(sub) {
+		if e.Type != events.TypeJobReady {
+			t.Errorf("unexpected event %s", e.Type)
+			continue
+		}
+		ready = append(ready, e.Data.(events.Job).JobID)
+	}
+	if len(ready) != 3 || ready[0] != 1 || ready[1] != 2 || ready[2] != 5 {
+		t.Errorf("job_ready for jobs %v, want [1 2 5]", ready)
+	}
+}
+
+// --- TestSyncJobs_InitialImportNotAnnounced ---
+// Verifies that ready jobs found on an owner's first job sync are not
+// published as job_ready.
+func TestSyncJobs_InitialImportNotAnnounced(t *testing.T) {
+	q := &mockQuerier{
+		listJobsByOwnerFunc: func(store.ListJobsByOwnerParams) ([]store.Job, error) { return nil, nil },
+		upsertJobFunc:       func(store.UpsertJobParams) error { return nil },
+	}
+	esiMock := &mockESIClient{
+		charJobsFunc: func(context.Context, int64, string) ([]esi.Job, time.Time, error) {
+			return []esi.Job{{JobID: 1, BlueprintID: 101, Activity: "copying", Status: "ready", EndDate: time.Now().Add(-time.Hour)}}, time.Now(), nil
+		},
+	}
+	bus := events.NewBus(16)
+	sub, _, _ := bus.Subscribe(0)
+
+	w := New(q, esiMock, nil, time.Minute)
+	w.SetEventBus(bus)
+	if _, err := w.syncJobs(context.Background(), ownerTypeCharacter, 9); err != nil {
+		t.Fatalf("syncJobs: %v", err)
+	}
+
+	if got := drain(sub); len(got) != 0 {
+		t.Errorf("events = %v, want none on initial import", eventTypes(got))
+	}
+}
+
+// --- TestSyncBlueprints_PublishesAddedAndRemoved ---
+// Verifies that blueprint_added and blueprint_removed are published alongside
+// the corresponding blueprint_events rows.
+func TestSyncBlueprints_PublishesAddedAndRemoved(t *testing.T) {
+	q := &mockQuerier{
+		getEveTypeFunc: func(id int64) (store.EveType, error) { return store.EveType{ID: id}, nil },
+		listBlueprintsByOwnerFunc: func(store.ListBlueprintsByOwnerParams) ([]store.Blueprint, error) {
+			return []store.Blueprint{{ID: 1001, OwnerType: ownerTypeCharacter, OwnerID: 42, TypeID: 500}}, nil
+		},
+		getBlueprintFunc:          func(int64) (store.Blueprint, error) { return store.Blueprint{}, errors.New("not found") },
+		upsertBlueprintFunc:       func(store.UpsertBlueprintParams) error { return nil },
+		deleteJobsByBlueprintFunc: func(int64) error { return nil },
+		deleteBlueprintByIDFunc:   func(int64) error { return nil },
+		insertBlueprintEventFunc:  func(store.InsertBlueprintEventParams) error { return nil },
+	}
+	esiMock := &mockESIClient{
+		charBlueprintsFunc: func(context.Context, int64, string) ([]esi.Blueprint, time.Time, error) {
+			return []esi.Blueprint{{ItemID: 1002, TypeID: 501, LocationID: 60003760, LocationFlag: "Hangar"}}, time.Now(), nil
+		},
+	}
+	bus := events.NewBus(16)
+	sub, _, _ := bus.Subscribe(0)
+
+	w := New(q, esiMock, nil, time.Minute)
+	w.SetEventBus(bus)
+	if _, err := w.syncBlueprints(context.Background(), ownerTypeCharacter, 42); err != nil {
+		t.Fatalf("syncBlueprints: %v", err)
+	}
+
+	got := drain(sub)
+	if len(got) != 2 {
+		t.Fatalf("events = %v, want [blueprint_added blueprint_removed]", eventTypes(got))
+	}
+	added := events.Blueprint{BlueprintID: 1002, TypeID: 501, OwnerType: ownerTypeCharacter, OwnerID: 42}
+	if got[0].Type != events.TypeBlueprintAdded || got[0].Data != added {
+		t.Errorf("first event = %s %#v, want blueprint_added %#v", got[0].Type, got[0].Data, added)
+	}
+	removed := events.Blueprint{BlueprintID: 1001, TypeID: 500, OwnerType: ownerTypeCharacter, OwnerID: 42}
+	if got[1].Type != events.TypeBlueprintRemoved || got[1].Data != removed {
+		t.Errorf("second event = %s %#v, want blueprint_removed %#v", got[1].Type, got[1].Data, removed)
+	}
+}
@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// affiliationRoutes returns the ESI route map for an affiliation refresh.
+func affiliationRoutes() map[string]string {
+	return map[string]string{
+		"/latest/characters/affiliation/": "characters_affiliation.json",
+		"/latest/universe/names/":         "universe_names_affiliation.json",
+	}
+}
+
+// TestSyncIntegration_Affiliations_CorporationChanges seeds three characters
+// and applies the fixture's affiliations:
+//   - 90000001, delegate of 98000001, moves to untracked 98000002 and a new
+//     alliance: 98000002 is tracked with it as delegate, and the delegation of
+//     98000001 passes to the remaining member 90000002.
+//   - 90000002 is unchanged.
+//   - 90000003, sole member and delegate of 98000003, moves to an NPC
+//     corporation: 98000003 is orphaned and no corporation is tracked for it.
+//     Its first refresh fills the alliance without logging a change.
+func TestSyncIntegration_Affiliations_CorporationChanges(t *testing.T) {
+	sqlDB := newIntegrationDB(t)
+	seedIntegrationCharacter(t, sqlDB, 90000001, 98000001)
+	seedIntegrationCharacter(t, sqlDB, 90000002, 98000001)
+	seedIntegrationCharacter(t, sqlDB, 90000003, 98000003)
+	seedIntegrationCorporation(t, sqlDB, 98000001, 90000001)
+	seedIntegrationCorporation(t, sqlDB, 98000003, 90000003)
+	if _, err := sqlDB.Exec(
+		`UPDATE characters SET alliance_id = 99000001, alliance_name = 'Old Alliance', affiliation_at = ?
+		 WHERE id IN (90000001, 90000002)`, time.Now().Add(-2*time.Hour).UTC(),
+	); err != nil {
+		t.Fatalf("seeding affiliations: %v", err)
+	}
+
+	srv := newESIServer(t, affiliationRoutes())
+	w := newIntegrationWorker(t, sqlDB, srv.URL)
+	ctx := context.Background()
+
+	if err := w.refreshAffiliations(ctx); err != nil {
+		t.Fatalf("refreshAffiliations: %v", err)
+	}
+
+	q := store.New(sqlDB)
+	moved, err := q.GetCharacter(ctx, 90000001)
+	if err != nil {
+		t.Fatalf("GetCharacter: %v", err)
+	}
+	if moved.CorporationID != 98000002 || moved.CorporationName != "New Corp" {
+		t.Errorf("character 90000001 corporation = %d %q, want 98000002 New Corp", moved.CorporationID, moved.CorporationName)
+	}
+	if moved.AllianceID.Int64 != 99000002 || moved.AllianceName.String != "New Alliance" {
+		t.Errorf("character 90000001 alliance = %v %v, want 99000002 New Alliance", moved.AllianceID, moved.AllianceName)
+	}
+
+	delegates := map[int64]sql.NullInt64{
+		98000001: {Int64: 90000002, Valid: true},
+		98000002: {Int64: 90000001, Valid: true},
+		98000003: {},
+	}
+	for corpID, want := range delegates {
+		corp, err := q.GetCorporation(ctx, corpID)
+		if err != nil {
+			t.Errorf("GetCorporation %d: %v", corpID, err)
+			continue
+		}
+		if corp.DelegateID != want {
+			t.Errorf("corporation %d delegate = %v, want %v", corpID, corp.DelegateID, want)
+		}
+	}
+	if _, err := q.GetCorporation(ctx, 1000182); err == nil {
+		t.Error("NPC corporation 1000182 must not be tracked")
+	}
+
+	events, err := q.ListAffiliationEventsSince(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("ListAffiliationEventsSince: %v", err)
+	}
+	got := make(map[string]int)
+	for _, e := range events {
+		got[e.Event]++
+	}
+	want := map[string]int{
+		affiliationEventCorporationChanged: 2,
+		affiliationEventAllianceChanged:    1,
+		affiliationEventCorporationTracked: 1,
+		affiliationEventDelegateReassigned: 1,
+		affiliationEventDelegateOrphaned:   1,
+	}
+	if len(got) != len(want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	for event, n := range want {
+		if got[event] != n {
+			t.Errorf("%s events = %d, want %d", event, got[event], n)
+		}
+	}
+
+	// A second refresh with the same affiliations changes nothing.
+	if err := w.refreshAffiliations(ctx); err != nil {
+		t.Fatalf("second refreshAffiliations: %v", err)
+	}
+	again, err := q.ListAffiliationEventsSince(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("ListAffiliationEventsSince: %v", err)
+	}
+	if len(again) != len(events) {
+		t.Errorf("second refresh logged %d new events, want 0", len(again)-len(events))
+	}
+}
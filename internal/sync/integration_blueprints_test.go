@@ -2,22 +2,33 @@ package sync
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
 )
 
 // charBlueprintRoutes returns the ESI route map for a full character blueprint sync,
 // including universe type/group/category resolution and NPC station name lookup.
 func charBlueprintRoutes() map[string]string {
 	return map[string]string{
-		"/latest/characters/90000001/blueprints": "character_blueprints.json",
-		"/latest/universe/types/5000":            "universe_type_5000.json",
-		"/latest/universe/types/5001":            "universe_type_5001.json",
-		"/latest/universe/groups/260":            "universe_group_260.json",
-		"/latest/universe/categories/26":         "universe_category_26.json",
-		"/latest/universe/stations/60003760/":    "universe_station_60003760.json",
+		"/latest/characters/90000001/blueprints":    "character_blueprints.json",
+		"/latest/universe/types/5000":               "universe_type_5000.json",
+		"/latest/universe/types/5001":               "universe_type_5001.json",
+		"/latest/universe/groups/260":               "universe_group_260.json",
+		"/latest/universe/categories/26":            "universe_category_26.json",
+		"/latest/universe/stations/60003760/":       "universe_station_60003760.json",
+		"/latest/universe/systems/30000142/":        "universe_system_30000142.json",
+		"/latest/universe/constellations/20000020/": "universe_constellation_20000020.json",
+		"/latest/universe/regions/10000002/":        "universe_region_10000002.json",
 	}
 }
 
@@ -33,6 +44,7 @@ func TestSyncIntegration_CharacterBlueprints_RowsMatchFixture(t *testing.T) {
 	ctx := context.Background()
 
 	w.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+	w.resolvePendingLocations(ctx)
 
 	// Row count.
 	var count int
@@ -99,6 +111,57 @@ func TestSyncIntegration_CharacterBlueprints_RowsMatchFixture(t *testing.T) {
 	}
 }
 
+// TestSyncIntegration_CharacterBlueprints_LocationFilters verifies that the
+// blueprints listed after a character blueprint sync carry the Jita station's
+// system, region and security status and can be filtered by them.
+func TestSyncIntegration_CharacterBlueprints_LocationFilters(t *testing.T) {
+	sqlDB := newIntegrationDB(t)
+	seedIntegrationCharacter(t, sqlDB, 90000001, 0)
+	srv := newESIServer(t, charBlueprintRoutes())
+	w := newIntegrationWorker(t, sqlDB, srv.URL)
+	ctx := context.Background()
+
+	w.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+	w.resolvePendingLocations(ctx)
+
+	q := store.New(sqlDB)
+	all, err := q.ListBlueprints(ctx, store.ListBlueprintsParams{})
+	if err != nil {
+		t.Fatalf("ListBlueprints: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("want 2 blueprints, got %d", len(all))
+	}
+	for _, bp := range all {
+		if bp.SystemName.String != "Jita" || bp.RegionName.String != "The Forge" || bp.SecurityStatus.Float64 != 0.9459 {
+			t.Errorf("blueprint %d location: got %v %v %v, want Jita The Forge 0.9459",
+				bp.ID, bp.SystemName, bp.RegionName, bp.SecurityStatus)
+		}
+	}
+
+	filters := []struct {
+		name   string
+		params store.ListBlueprintsParams
+		want   int
+	}{
+		{"region", store.ListBlueprintsParams{RegionID: int64(10000002)}, 2},
+		{"other region", store.ListBlueprintsParams{RegionID: int64(10000043)}, 0},
+		{"system", store.ListBlueprintsParams{SystemID: int64(30000142)}, 2},
+		{"high-sec", store.ListBlueprintsParams{Security: "high"}, 2},
+		{"low-sec", store.ListBlueprintsParams{Security: "low"}, 0},
+		{"null-sec", store.ListBlueprintsParams{Security: "null"}, 0},
+	}
+	for _, f := range filters {
+		rows, err := q.ListBlueprints(ctx, f.params)
+		if err != nil {
+			t.Fatalf("ListBlueprints %s: %v", f.name, err)
+		}
+		if len(rows) != f.want {
+			t.Errorf("%s filter: got %d blueprints, want %d", f.name, len(rows), f.want)
+		}
+	}
+}
+
 // TestSyncIntegration_CharacterBlueprints_SecondSync_Upserts verifies that
 // running blueprint sync a second time with updated fixture data upserts the
 // existing row rather than inserting a duplicate.
@@ -111,6 +174,7 @@ func TestSyncIntegration_CharacterBlueprints_SecondSync_Upserts(t *testing.T) {
 	srv1 := newESIServer(t, charBlueprintRoutes())
 	w1 := newIntegrationWorker(t, sqlDB, srv1.URL)
 	w1.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+	w1.resolvePendingLocations(ctx)
 
 	// Second sync: same item_id, me_level changed to 5.
 	// Types and locations are already cached — only the blueprint route is needed.
@@ -133,6 +197,7 @@ func TestSyncIntegration_CharacterBlueprints_SecondSync_Upserts(t *testing.T) {
 
 	w2 := newIntegrationWorker(t, sqlDB, srv2.URL)
 	w2.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+	w2.resolvePendingLocations(ctx)
 
 	var meLevel int64
 	if err := sqlDB.QueryRow(
@@ -145,27 +210,321 @@ func TestSyncIntegration_CharacterBlueprints_SecondSync_Upserts(t *testing.T) {
 	}
 }
 
+// TestSyncIntegration_CharacterBlueprints_SecondSync_PrunesAndLogs verifies that
+// a blueprint missing from the second ESI response is deleted together with its
+// job, and that the removal and the ME change are both written to blueprint_events.
+func TestSyncIntegration_CharacterBlueprints_SecondSync_PrunesAndLogs(t *testing.T) {
+	sqlDB := newIntegrationDB(t)
+	seedIntegrationCharacter(t, sqlDB, 90000001, 0)
+	ctx := context.Background()
+
+	srv1 := newESIServer(t, charBlueprintRoutes())
+	w1 := newIntegrationWorker(t, sqlDB, srv1.URL)
+	w1.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+	w1.resolvePendingLocations(ctx)
+
+	// A job on the blueprint that is about to disappear must not block its deletion.
+	if _, err := sqlDB.Exec(
+		`INSERT INTO jobs (id, blueprint_id, owner_type, owner_id, installer_id, activity, status, start_date, end_date, updated_at)
+		 VALUES (1, 1052548712662, 'character', 90000001, 90000001, 'copying', 'active', ?, ?, ?)`,
+		time.Now(), time.Now().Add(time.Hour), time.Now(),
+	); err != nil {
+		t.Fatalf("seeding job: %v", err)
+	}
+
+	// Second sync: item 1052548712662 is gone, item 1052548709012 has me_level 10 → 5.
+	updatedJSON := []byte(`[{"item_id":1052548709012,"location_flag":"Hangar","location_id":60003760,` +
+		`"material_efficiency":5,"quantity":-1,"runs":-1,"time_efficiency":20,"type_id":5000}]`)
+	srv2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Expires", time.Now().Add(10*time.Minute).UTC().Format(http.TimeFormat))
+		_, _ = w.Write(updatedJSON)
+	}))
+	t.Cleanup(srv2.Close)
+	w2 := newIntegrationWorker(t, sqlDB, srv2.URL)
+	w2.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+	w2.resolvePendingLocations(ctx)
+
+	var bpCount, jobCount int
+	if err := sqlDB.QueryRow(`SELECT COUNT(*) FROM blueprints`).Scan(&bpCount); err != nil {
+		t.Fatalf("counting blueprints: %v", err)
+	}
+	if err := sqlDB.QueryRow(`SELECT COUNT(*) FROM jobs`).Scan(&jobCount); err != nil {
+		t.Fatalf("counting jobs: %v", err)
+	}
+	if bpCount != 1 || jobCount != 0 {
+		t.Errorf("after prune: got %d blueprints and %d jobs, want 1 and 0", bpCount, jobCount)
+	}
+
+	// The initial import logs nothing; the second sync logs the ME change and the removal.
+	rows, err := store.New(sqlDB).ListBlueprintEventsSince(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("listing blueprint events: %v", err)
+	}
+	got := map[string]int64{}
+	for _, r := range rows {
+		got[r.Event] = r.BlueprintID
+	}
+	if len(rows) != 2 || got[blueprintEventMEChanged] != 1052548709012 || got[blueprintEventRemoved] != 1052548712662 {
+		t.Errorf("unexpected blueprint events: %+v", rows)
+	}
+}
+
+// blueprintServer serves the blueprints in libraries as the ESI blueprints of
+// each character, and the universe fixtures of charBlueprintRoutes. Tests
+// change libraries between syncs.
+func blueprintServer(t *testing.T, libraries map[int64]string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	for pattern, fixture := range charBlueprintRoutes() {
+		if strings.HasPrefix(pattern, "/latest/universe/") {
+			fixturePath := filepath.Join("testdata", fixture)
+			mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+				http.ServeFile(w, r, fixturePath)
+			})
+		}
+	}
+	mux.HandleFunc("/latest/characters/{id}/blueprints", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Expires", time.Now().Add(10*time.Minute).UTC().Format(http.TimeFormat))
+		_, _ = w.Write([]byte("[" + libraries[id] + "]"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// esiBlueprint returns the ESI JSON of a blueprint of type 5000.
+func esiBlueprint(itemID int64) string {
+	return fmt.Sprintf(`{"item_id":%d,"location_flag":"Hangar","location_id":60003760,`+
+		`"material_efficiency":10,"quantity":-1,"runs":-1,"time_efficiency":20,"type_id":5000}`, itemID)
+}
+
+// TestSyncIntegration_Blueprints_MovedBetweenOwners verifies that a blueprint
+// moved to another tracked owner is logged as an owner change, not removed and
+// added, even when the old owner is synced first; and that a blueprint that
+// left every tracked owner is reported removed once the others have synced.
+func TestSyncIntegration_Blueprints_MovedBetweenOwners(t *testing.T) {
+	sqlDB := newIntegrationDB(t)
+	seedIntegrationCharacter(t, sqlDB, 90000001, 0)
+	seedIntegrationCharacter(t, sqlDB, 90000002, 0)
+	ctx := context.Background()
+
+	libraries := map[int64]string{
+		90000001: esiBlueprint(1),
+		90000002: esiBlueprint(2),
+	}
+	w := newIntegrationWorker(t, sqlDB, blueprintServer(t, libraries).URL)
+	clock := time.Now()
+	w.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	bus := events.NewBus(16)
+	sub, _, _ := bus.Subscribe(0)
+	w.SetEventBus(bus)
+
+	// syncBoth syncs both characters in one cycle, in the given order.
+	syncBoth := func(first, second int64) {
+		t.Helper()
+		for _, id := range []int64{first, second} {
+			w.syncSubject(ctx, ownerTypeCharacter, id, endpointBlueprints)
+		}
+		w.settleRemovedBlueprints(ctx)
+	}
+	syncBoth(90000001, 90000002) // initial imports
+
+	// Blueprint 1 moves to the second character; the first is synced first.
+	libraries[90000001] = ""
+	libraries[90000002] = esiBlueprint(2) + "," + esiBlueprint(1)
+	syncBoth(90000001, 90000002)
+
+	// Blueprint 2 is sold; the first character is synced after the second.
+	libraries[90000002] = esiBlueprint(1)
+	syncBoth(90000002, 90000001)
+
+	var got []string
+	for _, e := range drain(sub) {
+		if b, ok := e.Data.(events.Blueprint); ok {
+			got = append(got, fmt.Sprintf("%s %d", e.Type, b.BlueprintID))
+		}
+	}
+	if want := []string{events.TypeBlueprintRemoved + " 2"}; !slices.Equal(got, want) {
+		t.Errorf("blueprint events published = %v, want %v", got, want)
+	}
+
+	rows, err := store.New(sqlDB).ListBlueprintEventsSince(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("listing blueprint events: %v", err)
+	}
+	got = nil
+	for _, r := range rows {
+		got = append(got, fmt.Sprintf("%s %d", r.Event, r.BlueprintID))
+	}
+	slices.Sort(got)
+	if want := []string{blueprintEventOwnerChanged + " 1", blueprintEventRemoved + " 2"}; !slices.Equal(got, want) {
+		t.Errorf("blueprint_events = %v, want %v", got, want)
+	}
+}
+
+// TestSyncIntegration_Blueprints_RemovalSurvivesRestart verifies that a
+// blueprint pruned before a restart is still settled after it: one moved to
+// another tracked owner is logged as an owner change, and one that left every
+// tracked owner is reported removed.
+func TestSyncIntegration_Blueprints_RemovalSurvivesRestart(t *testing.T) {
+	sqlDB := newIntegrationDB(t)
+	seedIntegrationCharacter(t, sqlDB, 90000001, 0)
+	seedIntegrationCharacter(t, sqlDB, 90000002, 0)
+	ctx := context.Background()
+
+	libraries := map[int64]string{
+		90000001: esiBlueprint(1) + "," + esiBlueprint(3),
+		90000002: esiBlueprint(2),
+	}
+	srv := blueprintServer(t, libraries)
+	clock := time.Now()
+	newWorker := func() *Worker {
+		w := newIntegrationWorker(t, sqlDB, srv.URL)
+		w.now = func() time.Time {
+			clock = clock.Add(time.Second)
+			return clock
+		}
+		return w
+	}
+
+	w := newWorker()
+	for _, id := range []int64{90000001, 90000002} { // initial imports
+		w.syncSubject(ctx, ownerTypeCharacter, id, endpointBlueprints)
+	}
+
+	// Blueprint 1 moves to the second character and blueprint 3 is sold. The
+	// first character is synced, then the server restarts.
+	libraries[90000001] = ""
+	libraries[90000002] = esiBlueprint(2) + "," + esiBlueprint(1)
+	w.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+
+	w = newWorker()
+	bus := events.NewBus(16)
+	sub, _, _ := bus.Subscribe(0)
+	w.SetEventBus(bus)
+	w.syncSubject(ctx, ownerTypeCharacter, 90000002, endpointBlueprints)
+	w.settleRemovedBlueprints(ctx) // as runCycle does at the end of the cycle
+
+	var got []string
+	for _, e := range drain(sub) {
+		if b, ok := e.Data.(events.Blueprint); ok {
+			got = append(got, fmt.Sprintf("%s %d", e.Type, b.BlueprintID))
+		}
+	}
+	if want := []string{events.TypeBlueprintRemoved + " 3"}; !slices.Equal(got, want) {
+		t.Errorf("blueprint events published = %v, want %v", got, want)
+	}
+
+	rows, err := store.New(sqlDB).ListBlueprintEventsSince(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("listing blueprint events: %v", err)
+	}
+	got = nil
+	for _, r := range rows {
+		got = append(got, fmt.Sprintf("%s %d", r.Event, r.BlueprintID))
+	}
+	slices.Sort(got)
+	if want := []string{blueprintEventOwnerChanged + " 1", blueprintEventRemoved + " 3"}; !slices.Equal(got, want) {
+		t.Errorf("blueprint_events = %v, want %v", got, want)
+	}
+
+	removals, err := store.New(sqlDB).ListBlueprintRemovals(ctx)
+	if err != nil {
+		t.Fatalf("listing blueprint removals: %v", err)
+	}
+	if len(removals) != 0 {
+		t.Errorf("blueprint_removals = %+v, want none left", removals)
+	}
+}
+
+// TestSyncIntegration_Blueprints_FailedPruneRollsBack verifies that a blueprint
+// sync failing midway leaves the owner's stored blueprints and change log as
+// they were, and reports nothing.
+func TestSyncIntegration_Blueprints_FailedPruneRollsBack(t *testing.T) {
+	sqlDB := newIntegrationDB(t)
+	seedIntegrationCharacter(t, sqlDB, 90000001, 0)
+	ctx := context.Background()
+
+	libraries := map[int64]string{90000001: esiBlueprint(1) + "," + esiBlueprint(2)}
+	w := newIntegrationWorker(t, sqlDB, blueprintServer(t, libraries).URL)
+	bus := events.NewBus(16)
+	sub, _, _ := bus.Subscribe(0)
+	w.SetEventBus(bus)
+	w.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+
+	if _, err := sqlDB.Exec(`CREATE TRIGGER fail_prune BEFORE DELETE ON blueprints
+		WHEN OLD.id = 2 BEGIN SELECT RAISE(ABORT, 'disk full'); END`); err != nil {
+		t.Fatalf("creating trigger: %v", err)
+	}
+	// Blueprint 3 is new, blueprint 1 was researched and blueprint 2 is gone.
+	libraries[90000001] = strings.Replace(esiBlueprint(1), `"material_efficiency":10`, `"material_efficiency":5`, 1) +
+		"," + esiBlueprint(3)
+	w.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+
+	var ids []int64
+	rows, err := sqlDB.Query(`SELECT id FROM blueprints WHERE me_level = 10 ORDER BY id`)
+	if err != nil {
+		t.Fatalf("listing blueprints: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("scanning blueprint: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if !slices.Equal(ids, []int64{1, 2}) {
+		t.Errorf("blueprints after the failed sync = %v, want 1 and 2 unchanged", ids)
+	}
+	var logged int
+	if err := sqlDB.QueryRow(`SELECT COUNT(*) FROM blueprint_events`).Scan(&logged); err != nil {
+		t.Fatalf("counting blueprint events: %v", err)
+	}
+	if logged != 0 {
+		t.Errorf("blueprint_events has %d rows, want none", logged)
+	}
+	for _, e := range drain(sub) {
+		if e.Type == events.TypeBlueprintAdded || e.Type == events.TypeBlueprintRemoved {
+			t.Errorf("published %s %+v for a sync that was rolled back", e.Type, e.Data)
+		}
+	}
+}
+
 // TestSyncIntegration_CorporationBlueprints_RowsMatchFixture verifies that after
-// a corporation blueprint sync followed by a corp assets sync: the blueprint row
-// exists, and eve_locations has the exact station name resolved via corp_assets
-// (CorpSAG3 location_id → OfficeFolder item → real station name).
+// a corp assets sync followed by a corporation blueprint sync: the blueprint row
+// exists, eve_locations has the exact station name resolved via the asset tree
+// (CorpSAG3 location_id → OfficeFolder item → real station name), and
+// blueprint_locations holds the hangar division below it.
 func TestSyncIntegration_CorporationBlueprints_RowsMatchFixture(t *testing.T) {
 	sqlDB := newIntegrationDB(t)
 	seedIntegrationCharacter(t, sqlDB, 90000001, 0)
 	seedIntegrationCorporation(t, sqlDB, 99000001, 90000001)
 	srv := newESIServer(t, map[string]string{
-		"/latest/corporations/99000001/blueprints": "corporation_blueprints.json",
-		"/latest/universe/types/5000":              "universe_type_5000.json",
-		"/latest/universe/groups/260":              "universe_group_260.json",
-		"/latest/universe/categories/26":           "universe_category_26.json",
-		"/latest/corporations/99000001/assets/":    "corporation_assets_officefolders.json",
-		"/latest/universe/stations/60015146/":      "universe_station_60015146.json",
+		"/latest/corporations/99000001/divisions/":  "corporation_divisions.json",
+		"/latest/corporations/99000001/blueprints":  "corporation_blueprints.json",
+		"/latest/universe/types/5000":               "universe_type_5000.json",
+		"/latest/universe/groups/260":               "universe_group_260.json",
+		"/latest/universe/categories/26":            "universe_category_26.json",
+		"/latest/corporations/99000001/assets/":     "corporation_assets_officefolders.json",
+		"/latest/universe/stations/60015146/":       "universe_station_60015146.json",
+		"/latest/universe/systems/30002507/":        "universe_system_30002507.json",
+		"/latest/universe/constellations/20000367/": "universe_constellation_20000367.json",
+		"/latest/universe/regions/10000043/":        "universe_region_10000043.json",
 	})
 	w := newIntegrationWorker(t, sqlDB, srv.URL)
 	ctx := context.Background()
 
+	w.syncSubject(ctx, ownerTypeCorporation, 99000001, endpointDivisions)
 	w.syncSubject(ctx, ownerTypeCorporation, 99000001, endpointCorpAssets)
 	w.syncSubject(ctx, ownerTypeCorporation, 99000001, endpointBlueprints)
+	w.resolvePendingLocations(ctx)
 
 	// Blueprint row exists.
 	var count int
@@ -178,22 +537,48 @@ func TestSyncIntegration_CorporationBlueprints_RowsMatchFixture(t *testing.T) {
 		t.Errorf("want 1 corp blueprint, got %d", count)
 	}
 
-	// eve_locations has the exact resolved station name via corp_assets lookup.
+	// eve_locations has the exact resolved station name of the office.
 	const wantName = "Ibura IX - Moon 11 - Spacelane Patrol Testing Facilities"
 	var locName string
 	if err := sqlDB.QueryRow(
-		`SELECT name FROM eve_locations WHERE id=1052718829566`,
+		`SELECT name FROM eve_locations WHERE id=60015146`,
 	).Scan(&locName); err != nil {
-		t.Fatalf("querying eve_locations for corp hangar location ID: %v", err)
+		t.Fatalf("querying eve_locations for office station: %v", err)
 	}
 	if locName != wantName {
 		t.Errorf("eve_locations name: got %q, want %q", locName, wantName)
 	}
+
+	// The blueprint is in the named division 3 of that station's office.
+	var rootID int64
+	var path string
+	if err := sqlDB.QueryRow(
+		`SELECT root_location_id, path FROM blueprint_locations WHERE blueprint_id=1052548174037`,
+	).Scan(&rootID, &path); err != nil {
+		t.Fatalf("querying blueprint_locations: %v", err)
+	}
+	if rootID != 60015146 || path != "Corp Hangar 3 (Research BPOs)" {
+		t.Errorf("blueprint location: got %d %q, want 60015146 %q", rootID, path, "Corp Hangar 3 (Research BPOs)")
+	}
+
+	// The station's solar system is stored with its region and security status.
+	var systemName, regionName string
+	var security float64
+	if err := sqlDB.QueryRow(
+		`SELECT s.name, s.region_name, s.security_status
+		 FROM eve_locations l JOIN eve_systems s ON s.id = l.solar_system_id
+		 WHERE l.id=60015146`,
+	).Scan(&systemName, &regionName, &security); err != nil {
+		t.Fatalf("querying eve_systems for office station: %v", err)
+	}
+	if systemName != "Ibura" || regionName != "Domain" || security != 0.3152 {
+		t.Errorf("system: got %q %q %v, want Ibura Domain 0.3152", systemName, regionName, security)
+	}
 }
 
 // TestSyncIntegration_CorpAssetsSync_StoresOfficeFolders verifies that after a
-// corp_assets sync, the corp_assets table contains the OfficeFolder entry from
-// the fixture and sync_state.cache_until is in the future.
+// corp_assets sync, the assets table contains the OfficeFolder entry from the
+// fixture and sync_state.cache_until is in the future.
 func TestSyncIntegration_CorpAssetsSync_StoresOfficeFolders(t *testing.T) {
 	sqlDB := newIntegrationDB(t)
 	seedIntegrationCharacter(t, sqlDB, 90000001, 0)
@@ -206,12 +591,17 @@ func TestSyncIntegration_CorpAssetsSync_StoresOfficeFolders(t *testing.T) {
 
 	w.syncSubject(ctx, ownerTypeCorporation, 99000001, endpointCorpAssets)
 
-	// corp_assets row must exist with correct item_id and location_id.
+	// assets row must exist with correct item_id, location_id and flag.
 	var itemID, locationID int64
+	var flag string
 	if err := sqlDB.QueryRow(
-		`SELECT item_id, location_id FROM corp_assets WHERE owner_id=99000001`,
-	).Scan(&itemID, &locationID); err != nil {
-		t.Fatalf("querying corp_assets: %v", err)
+		`SELECT item_id, location_id, location_flag FROM assets
+		 WHERE owner_type='corporation' AND owner_id=99000001`,
+	).Scan(&itemID, &locationID, &flag); err != nil {
+		t.Fatalf("querying assets: %v", err)
+	}
+	if flag != "OfficeFolder" {
+		t.Errorf("location_flag: got %q, want OfficeFolder", flag)
 	}
 	if itemID != 1052718829566 {
 		t.Errorf("item_id: got %d, want 1052718829566", itemID)
@@ -244,6 +634,7 @@ func TestSyncIntegration_TypeResolution_FKIntegrity(t *testing.T) {
 	ctx := context.Background()
 
 	w.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+	w.resolvePendingLocations(ctx)
 
 	// A JOIN across blueprints → eve_types → eve_groups → eve_categories
 	// returns the same count as the blueprints table — every FK is satisfied.
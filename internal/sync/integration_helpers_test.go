@@ -36,7 +36,7 @@ func newIntegrationWorker(t *testing.T, sqlDB *sql.DB, esiServerURL string) *Wor
 	q := store.New(sqlDB)
 	transport := &hostOverrideTransport{target: esiServerURL}
 	esiClient := esi.NewClient(&http.Client{Transport: transport})
-	return New(q, esiClient, time.Minute)
+	return New(q, esiClient, nil, time.Minute)
 }
 
 // newESIServer starts a test HTTP server that serves fixture files from testdata/.
@@ -35,6 +35,7 @@ func TestSyncIntegration_CharacterJobs_OnlyActiveAndReadyStored(t *testing.T) {
 
 	// Blueprints must exist before jobs (FK constraint).
 	w.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+	w.resolvePendingLocations(ctx)
 	// Fixture: 1 active + 1 ready + 1 delivered job.
 	w.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointJobs)
 
@@ -59,6 +60,7 @@ func TestSyncIntegration_CharacterJobs_SyncStateUpdated(t *testing.T) {
 	ctx := context.Background()
 
 	w.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+	w.resolvePendingLocations(ctx)
 	w.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointJobs)
 
 	var cacheUntil time.Time
@@ -84,6 +86,7 @@ func TestSyncIntegration_StaleJobsDeleted(t *testing.T) {
 	bpSrv := newESIServer(t, charBlueprintRoutes())
 	w := newIntegrationWorker(t, sqlDB, bpSrv.URL)
 	w.syncSubject(ctx, ownerTypeCharacter, 90000001, endpointBlueprints)
+	w.resolvePendingLocations(ctx)
 
 	// Insert two job rows directly into the DB.
 	now := time.Now().UTC()
@@ -178,6 +181,7 @@ func TestSyncIntegration_CorporationJobs_Stored(t *testing.T) {
 
 	// Seed corp blueprint row (FK dependency for corp jobs).
 	w.syncSubject(ctx, ownerTypeCorporation, 99000001, endpointBlueprints)
+	w.resolvePendingLocations(ctx)
 	// Sync corp jobs.
 	w.syncSubject(ctx, ownerTypeCorporation, 99000001, endpointJobs)
 
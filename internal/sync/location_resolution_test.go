@@ -2,7 +2,9 @@ package sync
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"slices"
 	"testing"
 	"time"
 
@@ -27,6 +29,8 @@ func TestResolveLocationIDs_NPCStation_Hangar_ResolvesViaGetStation(t *testing.T
 		getLocationFunc: func(id int64) (store.EveLocation, error) {
 			return store.EveLocation{}, errors.New("not found")
 		},
+		getEveSystemFunc:    func(int64) (store.EveSystem, error) { return store.EveSystem{}, errors.New("not found") },
+		upsertEveSystemFunc: func(store.UpsertEveSystemParams) error { return nil },
 		insertLocationFunc: func(arg store.InsertLocationParams) error {
 			insertedLocations = append(insertedLocations, arg)
 			return nil
@@ -34,17 +38,24 @@ func TestResolveLocationIDs_NPCStation_Hangar_ResolvesViaGetStation(t *testing.T
 	}
 
 	esiMock := &mockESIClient{
-		getStationFunc: func(_ context.Context, id int64) (string, error) {
+		getStationFunc: func(_ context.Context, id int64) (esi.UniverseStation, error) {
 			stationCalled = true
 			if id != stationID {
 				t.Errorf("GetStation: unexpected id %d", id)
 			}
-			return "Jita IV - Moon 4 - Caldari Navy Assembly Plant", nil
+			return esi.UniverseStation{Name: "Jita IV - Moon 4 - Caldari Navy Assembly Plant", SystemID: 30000142}, nil
+		},
+		getUniverseSystemFunc: func(_ context.Context, id int64) (esi.UniverseSystem, error) {
+			if id != 30000142 {
+				t.Errorf("GetUniverseSystem: unexpected id %d", id)
+			}
+			return esi.UniverseSystem{SystemID: id, Name: "Jita", SecurityStatus: 0.9459, RegionID: 10000002, RegionName: "The Forge"}, nil
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 1)
+	w.resolvePendingLocations(context.Background())
 
 	if !stationCalled {
 		t.Error("GetStation must be called for NPC station with Hangar flag")
@@ -58,6 +69,9 @@ func TestResolveLocationIDs_NPCStation_Hangar_ResolvesViaGetStation(t *testing.T
 	if insertedLocations[0].Name != "Jita IV - Moon 4 - Caldari Navy Assembly Plant" {
 		t.Errorf("InsertLocation Name: got %q", insertedLocations[0].Name)
 	}
+	if insertedLocations[0].SolarSystemID != (sql.NullInt64{Int64: 30000142, Valid: true}) {
+		t.Errorf("InsertLocation SolarSystemID: got %v, want 30000142", insertedLocations[0].SolarSystemID)
+	}
 }
 
 // --- TestResolveLocationIDs_SkipsAlreadyCached ---
@@ -73,19 +87,20 @@ func TestResolveLocationIDs_SkipsAlreadyCached(t *testing.T) {
 		},
 		getLocationFunc: func(id int64) (store.EveLocation, error) {
 			// Already cached.
-			return store.EveLocation{ID: id, Name: "Jita IV", ResolvedAt: time.Now()}, nil
+			return store.EveLocation{ID: id, Name: "Jita IV", ResolvedAt: time.Now(), SolarSystemID: sql.NullInt64{Int64: 30000142, Valid: true}}, nil
 		},
 	}
 
 	esiMock := &mockESIClient{
-		getStationFunc: func(_ context.Context, _ int64) (string, error) {
+		getStationFunc: func(_ context.Context, _ int64) (esi.UniverseStation, error) {
 			stationCalled = true
-			return "", nil
+			return esi.UniverseStation{}, nil
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 1)
+	w.resolvePendingLocations(context.Background())
 
 	if stationCalled {
 		t.Error("GetStation must not be called when location is already cached")
@@ -104,24 +119,191 @@ func TestResolveLocationIDs_NoIDs_NoESICalls(t *testing.T) {
 	// ESI mock with panicking methods — any call would fail the test.
 	esiMock := &mockESIClient{}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	// Should complete without panicking.
 	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 1)
+	w.resolvePendingLocations(context.Background())
+}
+
+// --- TestResolvePendingLocations_SharedStationResolvedOnce ---
+// Verifies that a station holding blueprints of several owners is fetched once
+// per cycle and that all station names are requested in one
+// POST /universe/names/ call.
+func TestResolvePendingLocations_SharedStationResolvedOnce(t *testing.T) {
+	locations := map[int64][]store.ListBlueprintLocationsByOwnerRow{
+		1: {{LocationID: 60003760, LocationFlag: "Hangar"}},
+		2: {{LocationID: 60003760, LocationFlag: "Hangar"}, {LocationID: 60008494, LocationFlag: "Hangar"}},
+	}
+
+	var inserted []int64
+	q := &mockQuerier{
+		listBlueprintLocationsByOwnerFunc: func(arg store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
+			return locations[arg.OwnerID], nil
+		},
+		getLocationFunc: func(int64) (store.EveLocation, error) {
+			return store.EveLocation{}, sql.ErrNoRows
+		},
+		getEveSystemFunc: func(id int64) (store.EveSystem, error) {
+			return store.EveSystem{ID: id, Name: "Jita", ResolvedAt: time.Now()}, nil
+		},
+		insertLocationFunc: func(arg store.InsertLocationParams) error {
+			inserted = append(inserted, arg.ID)
+			return nil
+		},
+	}
+
+	var namesCalls [][]int64
+	stationCalls := make(map[int64]int)
+	esiMock := &mockESIClient{
+		postUniverseNamesFunc: func(_ context.Context, ids []int64) ([]esi.UniverseNamesEntry, error) {
+			namesCalls = append(namesCalls, ids)
+			return nil, nil
+		},
+		getStationFunc: func(_ context.Context, id int64) (esi.UniverseStation, error) {
+			stationCalls[id]++
+			return esi.UniverseStation{Name: "Station", SystemID: 30000142}, nil
+		},
+	}
+
+	w := New(q, esiMock, nil, time.Minute)
+	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 1)
+	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 2)
+	w.resolvePendingLocations(context.Background())
+
+	if len(namesCalls) != 1 || !slices.Equal(namesCalls[0], []int64{60003760, 60008494}) {
+		t.Errorf("PostUniverseNames calls: got %v, want one call with [60003760 60008494]", namesCalls)
+	}
+	if stationCalls[60003760] != 1 || stationCalls[60008494] != 1 {
+		t.Errorf("GetStation calls: got %v, want one per station", stationCalls)
+	}
+	if !slices.Equal(inserted, []int64{60003760, 60008494}) {
+		t.Errorf("inserted locations: got %v, want [60003760 60008494]", inserted)
+	}
+}
+
+// --- TestResolvePendingLocations_CachedStationRenamedFromBulkNames ---
+// Verifies that a stale station that is cached with its solar system takes its
+// name from POST /universe/names/ without GetStation, while a station seen for
+// the first time is still fetched for its system.
+func TestResolvePendingLocations_CachedStationRenamedFromBulkNames(t *testing.T) {
+	const cachedID, newID int64 = 60003760, 60008494
+
+	var inserted []store.InsertLocationParams
+	q := &mockQuerier{
+		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
+			return []store.ListBlueprintLocationsByOwnerRow{
+				{LocationID: cachedID, LocationFlag: "Hangar"},
+				{LocationID: newID, LocationFlag: "Hangar"},
+			}, nil
+		},
+		getLocationFunc: func(id int64) (store.EveLocation, error) {
+			if id != cachedID {
+				return store.EveLocation{}, sql.ErrNoRows
+			}
+			return store.EveLocation{
+				ID: id, Name: "Old name", ResolvedAt: time.Now().Add(-2 * locationTTL),
+				SolarSystemID: sql.NullInt64{Int64: 30000142, Valid: true},
+			}, nil
+		},
+		getEveSystemFunc: func(id int64) (store.EveSystem, error) {
+			return store.EveSystem{ID: id, Name: "System", ResolvedAt: time.Now()}, nil
+		},
+		insertLocationFunc: func(arg store.InsertLocationParams) error {
+			inserted = append(inserted, arg)
+			return nil
+		},
+	}
+	var stationCalls []int64
+	esiMock := &mockESIClient{
+		postUniverseNamesFunc: func(_ context.Context, _ []int64) ([]esi.UniverseNamesEntry, error) {
+			return []esi.UniverseNamesEntry{
+				{ID: cachedID, Name: "Jita IV - Moon 4 - Caldari Navy Assembly Plant", Category: "station"},
+				{ID: newID, Name: "Amarr VIII (Oris) - Emperor Family Academy", Category: "station"},
+			}, nil
+		},
+		getStationFunc: func(_ context.Context, id int64) (esi.UniverseStation, error) {
+			stationCalls = append(stationCalls, id)
+			return esi.UniverseStation{Name: "Amarr VIII (Oris) - Emperor Family Academy", SystemID: 30002187}, nil
+		},
+	}
+
+	w := New(q, esiMock, nil, time.Minute)
+	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 1)
+	w.resolvePendingLocations(context.Background())
+
+	if !slices.Equal(stationCalls, []int64{newID}) {
+		t.Errorf("GetStation calls: got %v, want only the uncached station %d", stationCalls, newID)
+	}
+	if len(inserted) != 2 {
+		t.Fatalf("expected 2 InsertLocation calls, got %d", len(inserted))
+	}
+	if inserted[0].ID != cachedID || inserted[0].Name != "Jita IV - Moon 4 - Caldari Navy Assembly Plant" ||
+		inserted[0].SolarSystemID != (sql.NullInt64{Int64: 30000142, Valid: true}) {
+		t.Errorf("cached station: got %+v, want the bulk name and the cached system", inserted[0])
+	}
+	if inserted[1].ID != newID || inserted[1].SolarSystemID != (sql.NullInt64{Int64: 30002187, Valid: true}) {
+		t.Errorf("new station: got %+v, want its system from GetStation", inserted[1])
+	}
+}
+
+// --- TestResolvePendingLocations_BulkNameFallback ---
+// Verifies that a station whose per-ID lookup fails is still stored, without a
+// solar system, under its name from POST /universe/names/ when it is not
+// cached yet.
+func TestResolvePendingLocations_BulkNameFallback(t *testing.T) {
+	const stationID int64 = 60003760
+
+	var inserted []store.InsertLocationParams
+	q := &mockQuerier{
+		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
+			return []store.ListBlueprintLocationsByOwnerRow{{LocationID: stationID, LocationFlag: "Hangar"}}, nil
+		},
+		getLocationFunc: func(int64) (store.EveLocation, error) {
+			return store.EveLocation{}, sql.ErrNoRows
+		},
+		insertLocationFunc: func(arg store.InsertLocationParams) error {
+			inserted = append(inserted, arg)
+			return nil
+		},
+	}
+	esiMock := &mockESIClient{
+		postUniverseNamesFunc: func(_ context.Context, _ []int64) ([]esi.UniverseNamesEntry, error) {
+			return []esi.UniverseNamesEntry{{ID: stationID, Name: "Jita IV - Moon 4 - Caldari Navy Assembly Plant", Category: "station"}}, nil
+		},
+		getStationFunc: func(_ context.Context, _ int64) (esi.UniverseStation, error) {
+			return esi.UniverseStation{}, errors.New("ESI 502: bad gateway")
+		},
+	}
+
+	w := New(q, esiMock, nil, time.Minute)
+	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 1)
+	w.resolvePendingLocations(context.Background())
+
+	if len(inserted) != 1 {
+		t.Fatalf("expected 1 InsertLocation call, got %d", len(inserted))
+	}
+	if inserted[0].Name != "Jita IV - Moon 4 - Caldari Navy Assembly Plant" || inserted[0].SolarSystemID.Valid {
+		t.Errorf("InsertLocation: got %+v, want bulk name without solar system", inserted[0])
+	}
 }
 
 // --- TestResolveLocationIDs_Structure_ResolvedWithSystemName ---
 // Verifies that structure IDs (>= 1T) with "Hangar" flag are resolved via
-// GetUniverseStructure + GetUniverseSystem, stored as "SystemName — StructureName".
+// GetUniverseStructure + GetUniverseSystem, stored as "SystemName — StructureName"
+// with the system cached in eve_systems.
 func TestResolveLocationIDs_Structure_ResolvedWithSystemName(t *testing.T) {
 	const structureID int64 = 1_000_000_000_001
 	const systemID int64 = 30000142
 
 	var insertedLocations []store.InsertLocationParams
+	var upsertedSystems []store.UpsertEveSystemParams
 
 	q := &mockQuerier{
 		listCharsFunc: func() ([]store.Character, error) {
 			return []store.Character{{ID: 1, Name: "TestChar", AccessToken: "tok123"}}, nil
 		},
+		listStructureAccessFunc:   func(int64) ([]store.StructureAccess, error) { return nil, nil },
+		upsertStructureAccessFunc: func(store.UpsertStructureAccessParams) error { return nil },
 		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
 			return []store.ListBlueprintLocationsByOwnerRow{
 				{LocationID: structureID, LocationFlag: "Hangar"},
@@ -130,6 +312,11 @@ func TestResolveLocationIDs_Structure_ResolvedWithSystemName(t *testing.T) {
 		getLocationFunc: func(id int64) (store.EveLocation, error) {
 			return store.EveLocation{}, errors.New("not found")
 		},
+		getEveSystemFunc: func(int64) (store.EveSystem, error) { return store.EveSystem{}, errors.New("not found") },
+		upsertEveSystemFunc: func(arg store.UpsertEveSystemParams) error {
+			upsertedSystems = append(upsertedSystems, arg)
+			return nil
+		},
 		insertLocationFunc: func(arg store.InsertLocationParams) error {
 			insertedLocations = append(insertedLocations, arg)
 			return nil
@@ -146,37 +333,47 @@ func TestResolveLocationIDs_Structure_ResolvedWithSystemName(t *testing.T) {
 			}
 			return esi.UniverseStructure{Name: "Tranquility Trading Tower", SolarSystemID: systemID}, nil
 		},
-		getUniverseSystemFunc: func(_ context.Context, id int64) (string, error) {
+		getUniverseSystemFunc: func(_ context.Context, id int64) (esi.UniverseSystem, error) {
 			if id != systemID {
 				t.Errorf("GetUniverseSystem: unexpected id %d", id)
 			}
-			return "Perimeter", nil
+			return esi.UniverseSystem{
+				SystemID:          systemID,
+				Name:              "Perimeter",
+				SecurityStatus:    0.9,
+				ConstellationID:   20000020,
+				ConstellationName: "Kimotoro",
+				RegionID:          10000002,
+				RegionName:        "The Forge",
+			}, nil
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, mockTokens{1: "tok123"}, time.Minute)
 	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 1)
+	w.resolvePendingLocations(context.Background())
 
-	// Expect two inserts: one for the system name, one for the structure.
-	if len(insertedLocations) != 2 {
-		t.Fatalf("expected 2 InsertLocation calls (system + structure), got %d", len(insertedLocations))
-	}
-
-	// System name cached first.
-	if insertedLocations[0].ID != systemID {
-		t.Errorf("first insert: expected system ID %d, got %d", systemID, insertedLocations[0].ID)
+	// System cached in eve_systems with its constellation and region.
+	if len(upsertedSystems) != 1 {
+		t.Fatalf("expected 1 UpsertEveSystem call, got %d", len(upsertedSystems))
 	}
-	if insertedLocations[0].Name != "Perimeter" {
-		t.Errorf("system name: got %q, want Perimeter", insertedLocations[0].Name)
+	if sys := upsertedSystems[0]; sys.ID != systemID || sys.Name != "Perimeter" || sys.RegionName != "The Forge" || sys.SecurityStatus != 0.9 {
+		t.Errorf("system: got %+v", sys)
 	}
 
-	// Structure stored as "System — Structure".
-	if insertedLocations[1].ID != structureID {
-		t.Errorf("second insert: expected structure ID %d, got %d", structureID, insertedLocations[1].ID)
+	// Structure stored as "System — Structure" with its solar system.
+	if len(insertedLocations) != 1 {
+		t.Fatalf("expected 1 InsertLocation call, got %d", len(insertedLocations))
+	}
+	if insertedLocations[0].ID != structureID {
+		t.Errorf("insert: expected structure ID %d, got %d", structureID, insertedLocations[0].ID)
 	}
 	wantName := "Perimeter \u2014 Tranquility Trading Tower"
-	if insertedLocations[1].Name != wantName {
-		t.Errorf("structure name: got %q, want %q", insertedLocations[1].Name, wantName)
+	if insertedLocations[0].Name != wantName {
+		t.Errorf("structure name: got %q, want %q", insertedLocations[0].Name, wantName)
+	}
+	if insertedLocations[0].SolarSystemID != (sql.NullInt64{Int64: systemID, Valid: true}) {
+		t.Errorf("structure solar system: got %v, want %d", insertedLocations[0].SolarSystemID, systemID)
 	}
 }
 
@@ -191,6 +388,8 @@ func TestResolveLocationIDs_Structure_403_Skipped(t *testing.T) {
 		listCharsFunc: func() ([]store.Character, error) {
 			return []store.Character{{ID: 1, Name: "TestChar", AccessToken: "tok"}}, nil
 		},
+		listStructureAccessFunc:   func(int64) ([]store.StructureAccess, error) { return nil, nil },
+		upsertStructureAccessFunc: func(store.UpsertStructureAccessParams) error { return nil },
 		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
 			return []store.ListBlueprintLocationsByOwnerRow{
 				{LocationID: structureID, LocationFlag: "Hangar"},
@@ -199,6 +398,8 @@ func TestResolveLocationIDs_Structure_403_Skipped(t *testing.T) {
 		getLocationFunc: func(id int64) (store.EveLocation, error) {
 			return store.EveLocation{}, errors.New("not found")
 		},
+		getEveSystemFunc:    func(int64) (store.EveSystem, error) { return store.EveSystem{}, errors.New("not found") },
+		upsertEveSystemFunc: func(store.UpsertEveSystemParams) error { return nil },
 		insertLocationFunc: func(_ store.InsertLocationParams) error {
 			insertCalled = true
 			return nil
@@ -211,8 +412,9 @@ func TestResolveLocationIDs_Structure_403_Skipped(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, mockTokens{1: "tok"}, time.Minute)
 	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 1)
+	w.resolvePendingLocations(context.Background())
 
 	if insertCalled {
 		t.Error("InsertLocation must not be called for a 403 structure response")
@@ -230,6 +432,8 @@ func TestResolveLocationIDs_Structure_404_StoresSentinel(t *testing.T) {
 		listCharsFunc: func() ([]store.Character, error) {
 			return []store.Character{{ID: 1, Name: "TestChar", AccessToken: "tok"}}, nil
 		},
+		listStructureAccessFunc:   func(int64) ([]store.StructureAccess, error) { return nil, nil },
+		upsertStructureAccessFunc: func(store.UpsertStructureAccessParams) error { return nil },
 		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
 			return []store.ListBlueprintLocationsByOwnerRow{
 				{LocationID: structureID, LocationFlag: "Hangar"},
@@ -238,6 +442,8 @@ func TestResolveLocationIDs_Structure_404_StoresSentinel(t *testing.T) {
 		getLocationFunc: func(id int64) (store.EveLocation, error) {
 			return store.EveLocation{}, errors.New("not found")
 		},
+		getEveSystemFunc:    func(int64) (store.EveSystem, error) { return store.EveSystem{}, errors.New("not found") },
+		upsertEveSystemFunc: func(store.UpsertEveSystemParams) error { return nil },
 		insertLocationFunc: func(arg store.InsertLocationParams) error {
 			insertedLocations = append(insertedLocations, arg)
 			return nil
@@ -250,8 +456,9 @@ func TestResolveLocationIDs_Structure_404_StoresSentinel(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, mockTokens{1: "tok"}, time.Minute)
 	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 1)
+	w.resolvePendingLocations(context.Background())
 
 	if len(insertedLocations) != 1 {
 		t.Fatalf("expected 1 InsertLocation call for 404 structure, got %d", len(insertedLocations))
@@ -259,13 +466,13 @@ func TestResolveLocationIDs_Structure_404_StoresSentinel(t *testing.T) {
 	if insertedLocations[0].ID != structureID {
 		t.Errorf("InsertLocation ID: got %d, want %d", insertedLocations[0].ID, structureID)
 	}
-	if insertedLocations[0].Name != corpHangarSentinel {
-		t.Errorf("InsertLocation Name: got %q, want %q", insertedLocations[0].Name, corpHangarSentinel)
+	if insertedLocations[0].Name != unknownLocationSentinel {
+		t.Errorf("InsertLocation Name: got %q, want %q", insertedLocations[0].Name, unknownLocationSentinel)
 	}
 }
 
 // --- TestResolveLocationIDs_Structure_CachedSystemName ---
-// Verifies that the system name is fetched from eve_locations cache rather than ESI
+// Verifies that the system is taken from the eve_systems cache rather than ESI
 // when it was already resolved for a prior structure in the same system.
 func TestResolveLocationIDs_Structure_CachedSystemName(t *testing.T) {
 	const structureID int64 = 1_000_000_000_004
@@ -278,19 +485,21 @@ func TestResolveLocationIDs_Structure_CachedSystemName(t *testing.T) {
 		listCharsFunc: func() ([]store.Character, error) {
 			return []store.Character{{ID: 1, Name: "TestChar", AccessToken: "tok"}}, nil
 		},
+		listStructureAccessFunc:   func(int64) ([]store.StructureAccess, error) { return nil, nil },
+		upsertStructureAccessFunc: func(store.UpsertStructureAccessParams) error { return nil },
 		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
 			return []store.ListBlueprintLocationsByOwnerRow{
 				{LocationID: structureID, LocationFlag: "Hangar"},
 			}, nil
 		},
 		getLocationFunc: func(id int64) (store.EveLocation, error) {
-			if id == systemID {
-				// System name already cached.
-				return store.EveLocation{ID: id, Name: "Jita", ResolvedAt: time.Now()}, nil
-			}
 			// Structure not yet cached.
 			return store.EveLocation{}, errors.New("not found")
 		},
+		getEveSystemFunc: func(id int64) (store.EveSystem, error) {
+			// System already cached.
+			return store.EveSystem{ID: id, Name: "Jita", ResolvedAt: time.Now()}, nil
+		},
 		insertLocationFunc: func(arg store.InsertLocationParams) error {
 			insertedIDs = append(insertedIDs, arg.ID)
 			return nil
@@ -301,33 +510,36 @@ func TestResolveLocationIDs_Structure_CachedSystemName(t *testing.T) {
 		getUniverseStructFunc: func(_ context.Context, _ int64, _ string) (esi.UniverseStructure, error) {
 			return esi.UniverseStructure{Name: "My Fortizar", SolarSystemID: systemID}, nil
 		},
-		getUniverseSystemFunc: func(_ context.Context, _ int64) (string, error) {
+		getUniverseSystemFunc: func(_ context.Context, _ int64) (esi.UniverseSystem, error) {
 			systemESICallCount++
-			return "Jita", nil
+			return esi.UniverseSystem{SystemID: 30000142, Name: "Jita"}, nil
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, mockTokens{1: "tok"}, time.Minute)
 	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 1)
+	w.resolvePendingLocations(context.Background())
 
 	if systemESICallCount != 0 {
 		t.Errorf("GetUniverseSystem must not be called when system name is already cached, got %d calls", systemESICallCount)
 	}
 
-	// Only the structure should be inserted (system was cached).
+	// Only the structure should be inserted.
 	if len(insertedIDs) != 1 || insertedIDs[0] != structureID {
 		t.Errorf("expected only structure %d to be inserted, got %v", structureID, insertedIDs)
 	}
 }
 
 // --- TestResolveLocationIDs_CorpHangar_ResolvesViaCorpAssets_NPCStation ---
-// Verifies that a corp blueprint with CorpSAG flag resolves to station name via corp_assets + GetStation.
+// Verifies that a corp blueprint with CorpSAG flag resolves via the office in
+// the asset tree to the station name (GetStation) and the named division.
 func TestResolveLocationIDs_CorpHangar_ResolvesViaCorpAssets_NPCStation(t *testing.T) {
 	const officeItemID int64 = 1_052_718_829_566
 	const stationID int64 = 60015146
 	const stationName = "Ibura IX - Moon 11 - Spacelane Patrol Testing Facilities"
 
 	var insertedLocations []store.InsertLocationParams
+	var paths []store.UpsertBlueprintLocationsParams
 
 	q := &mockQuerier{
 		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
@@ -338,12 +550,24 @@ func TestResolveLocationIDs_CorpHangar_ResolvesViaCorpAssets_NPCStation(t *testi
 		getLocationFunc: func(id int64) (store.EveLocation, error) {
 			return store.EveLocation{}, errors.New("not found")
 		},
-		getCorpAssetFunc: func(itemID int64) (store.GetCorpAssetRow, error) {
+		getAssetFunc: func(itemID int64) (store.Asset, error) {
 			if itemID != officeItemID {
-				t.Errorf("GetCorpAsset: unexpected itemID %d", itemID)
+				t.Errorf("GetAsset: unexpected itemID %d", itemID)
+			}
+			return officeAsset(itemID, stationID), nil
+		},
+		getCorpDivisionNameFunc: func(arg store.GetCorpDivisionNameParams) (string, error) {
+			if arg.CorporationID != 99000001 || arg.Division != 3 {
+				t.Errorf("GetCorpDivisionName: unexpected %+v", arg)
 			}
-			return store.GetCorpAssetRow{LocationID: stationID, LocationType: "station"}, nil
+			return "Research BPOs", nil
+		},
+		upsertBlueprintLocationsFunc: func(arg store.UpsertBlueprintLocationsParams) error {
+			paths = append(paths, arg)
+			return nil
 		},
+		getEveSystemFunc:    func(int64) (store.EveSystem, error) { return store.EveSystem{}, errors.New("not found") },
+		upsertEveSystemFunc: func(store.UpsertEveSystemParams) error { return nil },
 		insertLocationFunc: func(arg store.InsertLocationParams) error {
 			insertedLocations = append(insertedLocations, arg)
 			return nil
@@ -351,26 +575,39 @@ func TestResolveLocationIDs_CorpHangar_ResolvesViaCorpAssets_NPCStation(t *testi
 	}
 
 	esiMock := &mockESIClient{
-		getStationFunc: func(_ context.Context, id int64) (string, error) {
+		getStationFunc: func(_ context.Context, id int64) (esi.UniverseStation, error) {
 			if id != stationID {
 				t.Errorf("GetStation: unexpected id %d", id)
 			}
-			return stationName, nil
+			return esi.UniverseStation{Name: stationName, SystemID: 30002507}, nil
+		},
+		getUniverseSystemFunc: func(_ context.Context, id int64) (esi.UniverseSystem, error) {
+			return esi.UniverseSystem{SystemID: id, Name: "Ibura"}, nil
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.resolveLocationIDs(context.Background(), ownerTypeCorporation, 99000001)
+	w.resolvePendingLocations(context.Background())
 
 	if len(insertedLocations) != 1 {
 		t.Fatalf("expected 1 InsertLocation call, got %d", len(insertedLocations))
 	}
-	if insertedLocations[0].ID != officeItemID {
-		t.Errorf("InsertLocation ID: got %d, want %d", insertedLocations[0].ID, officeItemID)
+	if insertedLocations[0].ID != stationID {
+		t.Errorf("InsertLocation ID: got %d, want %d", insertedLocations[0].ID, stationID)
 	}
 	if insertedLocations[0].Name != stationName {
 		t.Errorf("InsertLocation Name: got %q, want %q", insertedLocations[0].Name, stationName)
 	}
+	if len(paths) != 1 {
+		t.Fatalf("expected 1 UpsertBlueprintLocations call, got %d", len(paths))
+	}
+	if paths[0].RootLocationID != stationID || paths[0].Path != "Corp Hangar 3 (Research BPOs)" {
+		t.Errorf("blueprint location: got root %d path %q, want %d %q", paths[0].RootLocationID, paths[0].Path, stationID, "Corp Hangar 3 (Research BPOs)")
+	}
+	if paths[0].LocationID != officeItemID || paths[0].LocationFlag != "CorpSAG3" {
+		t.Errorf("blueprint location key: got %d %q", paths[0].LocationID, paths[0].LocationFlag)
+	}
 }
 
 // --- TestResolveLocationIDs_CorpHangar_AssetNotFound_NoInsert ---
@@ -380,6 +617,7 @@ func TestResolveLocationIDs_CorpHangar_AssetNotFound_NoInsert(t *testing.T) {
 	const officeItemID int64 = 1_052_718_829_567
 
 	insertCalled := false
+	cleared := false
 
 	q := &mockQuerier{
 		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
@@ -390,9 +628,15 @@ func TestResolveLocationIDs_CorpHangar_AssetNotFound_NoInsert(t *testing.T) {
 		getLocationFunc: func(id int64) (store.EveLocation, error) {
 			return store.EveLocation{}, errors.New("not found")
 		},
-		getCorpAssetFunc: func(_ int64) (store.GetCorpAssetRow, error) {
-			return store.GetCorpAssetRow{}, errors.New("not found")
+		getAssetFunc: func(itemID int64) (store.Asset, error) {
+			return store.Asset{}, sql.ErrNoRows
 		},
+		deleteBlueprintLocationsFunc: func(arg store.DeleteBlueprintLocationsParams) error {
+			cleared = arg.LocationID == officeItemID && arg.LocationFlag == "CorpSAG1"
+			return nil
+		},
+		getEveSystemFunc:    func(int64) (store.EveSystem, error) { return store.EveSystem{}, errors.New("not found") },
+		upsertEveSystemFunc: func(store.UpsertEveSystemParams) error { return nil },
 		insertLocationFunc: func(_ store.InsertLocationParams) error {
 			insertCalled = true
 			return nil
@@ -401,20 +645,26 @@ func TestResolveLocationIDs_CorpHangar_AssetNotFound_NoInsert(t *testing.T) {
 
 	esiMock := &mockESIClient{}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.resolveLocationIDs(context.Background(), ownerTypeCorporation, 99000001)
+	w.resolvePendingLocations(context.Background())
 
 	if insertCalled {
 		t.Error("InsertLocation must not be called when corp_assets not yet synced — location must stay unresolved so the next cycle can retry")
 	}
+	if !cleared {
+		t.Error("DeleteBlueprintLocations must clear the outdated location of the blueprints")
+	}
 }
 
 // --- TestResolveLocationIDs_CorpHangar_AlreadyCached_NoESICalls ---
-// Verifies that a corp hangar office item ID already in eve_locations is not re-resolved.
+// Verifies that the station of a corp hangar office already in eve_locations is
+// not re-resolved, while the blueprint's path is still stored.
 func TestResolveLocationIDs_CorpHangar_AlreadyCached_NoESICalls(t *testing.T) {
 	const officeItemID int64 = 1_052_718_829_568
+	const stationID int64 = 60003760
 
-	getCorpAssetCalled := false
+	var paths []string
 
 	q := &mockQuerier{
 		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
@@ -422,23 +672,32 @@ func TestResolveLocationIDs_CorpHangar_AlreadyCached_NoESICalls(t *testing.T) {
 				{LocationID: officeItemID, LocationFlag: "CorpSAG2"},
 			}, nil
 		},
+		getAssetFunc: func(itemID int64) (store.Asset, error) {
+			return officeAsset(itemID, stationID), nil
+		},
+		upsertBlueprintLocationsFunc: func(arg store.UpsertBlueprintLocationsParams) error {
+			paths = append(paths, arg.Path)
+			return nil
+		},
 		getLocationFunc: func(id int64) (store.EveLocation, error) {
+			if id != stationID {
+				t.Errorf("GetLocation: got %d, want station %d", id, stationID)
+			}
 			// Already cached.
-			return store.EveLocation{ID: id, Name: "Some Station", ResolvedAt: time.Now()}, nil
-		},
-		getCorpAssetFunc: func(_ int64) (store.GetCorpAssetRow, error) {
-			getCorpAssetCalled = true
-			return store.GetCorpAssetRow{}, nil
+			return store.EveLocation{ID: id, Name: "Some Station", ResolvedAt: time.Now(), SolarSystemID: sql.NullInt64{Int64: 30000142, Valid: true}}, nil
 		},
 	}
 
+	// ESI mock with panicking methods — any call would fail the test.
 	esiMock := &mockESIClient{}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.resolveLocationIDs(context.Background(), ownerTypeCorporation, 99000001)
+	w.resolvePendingLocations(context.Background())
 
-	if getCorpAssetCalled {
-		t.Error("GetCorpAsset must not be called when corp hangar location is already cached")
+	// Division 2 has no known name.
+	if !slices.Equal(paths, []string{"Corp Hangar 2"}) {
+		t.Errorf("paths: got %q, want [Corp Hangar 2]", paths)
 	}
 }
 
@@ -456,22 +715,24 @@ func TestResolveLocationIDs_CorpHangar_ResolvesViaCorpAssets_Structure(t *testin
 		listCharsFunc: func() ([]store.Character, error) {
 			return []store.Character{{ID: 1, Name: "TestChar", AccessToken: "tok"}}, nil
 		},
+		listStructureAccessFunc:   func(int64) ([]store.StructureAccess, error) { return nil, nil },
+		upsertStructureAccessFunc: func(store.UpsertStructureAccessParams) error { return nil },
 		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
 			return []store.ListBlueprintLocationsByOwnerRow{
 				{LocationID: officeItemID, LocationFlag: "CorpSAG4"},
 			}, nil
 		},
 		getLocationFunc: func(id int64) (store.EveLocation, error) {
-			if id == systemID {
-				return store.EveLocation{ID: id, Name: "Jita"}, nil
-			}
 			return store.EveLocation{}, errors.New("not found")
 		},
-		getCorpAssetFunc: func(itemID int64) (store.GetCorpAssetRow, error) {
+		getAssetFunc: func(itemID int64) (store.Asset, error) {
 			if itemID != officeItemID {
-				t.Errorf("GetCorpAsset: unexpected itemID %d", itemID)
+				t.Errorf("GetAsset: unexpected itemID %d", itemID)
 			}
-			return store.GetCorpAssetRow{LocationID: structureID, LocationType: "structure"}, nil
+			return officeAsset(itemID, structureID), nil
+		},
+		getEveSystemFunc: func(id int64) (store.EveSystem, error) {
+			return store.EveSystem{ID: id, Name: "Jita", ResolvedAt: time.Now()}, nil
 		},
 		insertLocationFunc: func(arg store.InsertLocationParams) error {
 			insertedLocations = append(insertedLocations, arg)
@@ -488,14 +749,15 @@ func TestResolveLocationIDs_CorpHangar_ResolvesViaCorpAssets_Structure(t *testin
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, mockTokens{1: "tok"}, time.Minute)
 	w.resolveLocationIDs(context.Background(), ownerTypeCorporation, 99000001)
+	w.resolvePendingLocations(context.Background())
 
 	if len(insertedLocations) != 1 {
 		t.Fatalf("expected 1 InsertLocation call, got %d", len(insertedLocations))
 	}
-	if insertedLocations[0].ID != officeItemID {
-		t.Errorf("InsertLocation ID: got %d, want %d", insertedLocations[0].ID, officeItemID)
+	if insertedLocations[0].ID != structureID {
+		t.Errorf("InsertLocation ID: got %d, want %d", insertedLocations[0].ID, structureID)
 	}
 	wantName := "Jita \u2014 Keepstar"
 	if insertedLocations[0].Name != wantName {
@@ -515,6 +777,8 @@ func TestResolveLocationIDs_CorpHangar_Structure_403_Skipped(t *testing.T) {
 		listCharsFunc: func() ([]store.Character, error) {
 			return []store.Character{{ID: 1, Name: "TestChar", AccessToken: "tok"}}, nil
 		},
+		listStructureAccessFunc:   func(int64) ([]store.StructureAccess, error) { return nil, nil },
+		upsertStructureAccessFunc: func(store.UpsertStructureAccessParams) error { return nil },
 		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
 			return []store.ListBlueprintLocationsByOwnerRow{
 				{LocationID: officeItemID, LocationFlag: "CorpDeliveries"},
@@ -523,9 +787,11 @@ func TestResolveLocationIDs_CorpHangar_Structure_403_Skipped(t *testing.T) {
 		getLocationFunc: func(id int64) (store.EveLocation, error) {
 			return store.EveLocation{}, errors.New("not found")
 		},
-		getCorpAssetFunc: func(_ int64) (store.GetCorpAssetRow, error) {
-			return store.GetCorpAssetRow{LocationID: structureID, LocationType: "structure"}, nil
+		getAssetFunc: func(itemID int64) (store.Asset, error) {
+			return officeAsset(itemID, structureID), nil
 		},
+		getEveSystemFunc:    func(int64) (store.EveSystem, error) { return store.EveSystem{}, errors.New("not found") },
+		upsertEveSystemFunc: func(store.UpsertEveSystemParams) error { return nil },
 		insertLocationFunc: func(_ store.InsertLocationParams) error {
 			insertCalled = true
 			return nil
@@ -538,8 +804,9 @@ func TestResolveLocationIDs_CorpHangar_Structure_403_Skipped(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, mockTokens{1: "tok"}, time.Minute)
 	w.resolveLocationIDs(context.Background(), ownerTypeCorporation, 99000001)
+	w.resolvePendingLocations(context.Background())
 
 	if insertCalled {
 		t.Error("InsertLocation must not be called for a 403 structure in corp hangar resolution")
@@ -558,6 +825,8 @@ func TestResolveLocationIDs_CorpHangar_Structure_404_StoresSentinel(t *testing.T
 		listCharsFunc: func() ([]store.Character, error) {
 			return []store.Character{{ID: 1, Name: "TestChar", AccessToken: "tok"}}, nil
 		},
+		listStructureAccessFunc:   func(int64) ([]store.StructureAccess, error) { return nil, nil },
+		upsertStructureAccessFunc: func(store.UpsertStructureAccessParams) error { return nil },
 		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
 			return []store.ListBlueprintLocationsByOwnerRow{
 				{LocationID: officeItemID, LocationFlag: "CorpSAG5"},
@@ -566,9 +835,11 @@ func TestResolveLocationIDs_CorpHangar_Structure_404_StoresSentinel(t *testing.T
 		getLocationFunc: func(id int64) (store.EveLocation, error) {
 			return store.EveLocation{}, errors.New("not found")
 		},
-		getCorpAssetFunc: func(_ int64) (store.GetCorpAssetRow, error) {
-			return store.GetCorpAssetRow{LocationID: structureID, LocationType: "structure"}, nil
+		getAssetFunc: func(itemID int64) (store.Asset, error) {
+			return officeAsset(itemID, structureID), nil
 		},
+		getEveSystemFunc:    func(int64) (store.EveSystem, error) { return store.EveSystem{}, errors.New("not found") },
+		upsertEveSystemFunc: func(store.UpsertEveSystemParams) error { return nil },
 		insertLocationFunc: func(arg store.InsertLocationParams) error {
 			insertedLocations = append(insertedLocations, arg)
 			return nil
@@ -581,16 +852,445 @@ func TestResolveLocationIDs_CorpHangar_Structure_404_StoresSentinel(t *testing.T
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, mockTokens{1: "tok"}, time.Minute)
 	w.resolveLocationIDs(context.Background(), ownerTypeCorporation, 99000001)
+	w.resolvePendingLocations(context.Background())
 
 	if len(insertedLocations) != 1 {
 		t.Fatalf("expected 1 InsertLocation call for sentinel, got %d", len(insertedLocations))
 	}
-	if insertedLocations[0].ID != officeItemID {
-		t.Errorf("InsertLocation ID: got %d, want %d", insertedLocations[0].ID, officeItemID)
+	if insertedLocations[0].ID != structureID {
+		t.Errorf("InsertLocation ID: got %d, want %d", insertedLocations[0].ID, structureID)
+	}
+	if insertedLocations[0].Name != unknownLocationSentinel {
+		t.Errorf("InsertLocation Name (404): got %q, want %q", insertedLocations[0].Name, unknownLocationSentinel)
+	}
+}
+
+// officeAsset returns the asset row of corporation 99000001's office itemID in
+// station or structure locationID.
+func officeAsset(itemID, locationID int64) store.Asset {
+	return store.Asset{
+		ItemID:       itemID,
+		OwnerType:    ownerTypeCorporation,
+		OwnerID:      99000001,
+		LocationID:   locationID,
+		LocationFlag: officeFolderFlag,
+		LocationType: "station",
+		TypeID:       27,
+	}
+}
+
+// mockTokens implements auth.TokenRefresher with a fixed token per character.
+type mockTokens map[int64]string
+
+func (m mockTokens) FreshToken(_ context.Context, characterID int64) (string, error) {
+	token, ok := m[characterID]
+	if !ok {
+		return "", errors.New("no token")
+	}
+	return token, nil
+}
+
+// --- TestResolveLocationIDs_Structure_TriesEachCharacter ---
+// Verifies that a structure refused to the blueprint's owner is looked up with
+// the other characters' tokens, owner first, and that every outcome is recorded.
+func TestResolveLocationIDs_Structure_TriesEachCharacter(t *testing.T) {
+	const structureID int64 = 1_000_000_000_008
+
+	var recorded []store.UpsertStructureAccessParams
+	var insertedIDs []int64
+
+	q := &mockQuerier{
+		listCharsFunc: func() ([]store.Character, error) {
+			return []store.Character{{ID: 1}, {ID: 2}, {ID: 3}}, nil
+		},
+		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
+			return []store.ListBlueprintLocationsByOwnerRow{
+				{LocationID: structureID, LocationFlag: "Hangar"},
+			}, nil
+		},
+		getLocationFunc: func(id int64) (store.EveLocation, error) {
+			return store.EveLocation{}, errors.New("not found")
+		},
+		getEveSystemFunc:    func(int64) (store.EveSystem, error) { return store.EveSystem{}, errors.New("not found") },
+		upsertEveSystemFunc: func(store.UpsertEveSystemParams) error { return nil },
+		insertLocationFunc: func(arg store.InsertLocationParams) error {
+			insertedIDs = append(insertedIDs, arg.ID)
+			return nil
+		},
+		listStructureAccessFunc: func(int64) ([]store.StructureAccess, error) { return nil, nil },
+		upsertStructureAccessFunc: func(arg store.UpsertStructureAccessParams) error {
+			recorded = append(recorded, arg)
+			return nil
+		},
+	}
+
+	var tried []string
+	esiMock := &mockESIClient{
+		getUniverseStructFunc: func(_ context.Context, _ int64, token string) (esi.UniverseStructure, error) {
+			tried = append(tried, token)
+			if token != "tok2" {
+				return esi.UniverseStructure{}, esi.ErrForbidden
+			}
+			return esi.UniverseStructure{Name: "Docking Fortizar", SolarSystemID: 30000142}, nil
+		},
+		getUniverseSystemFunc: func(_ context.Context, _ int64) (esi.UniverseSystem, error) {
+			return esi.UniverseSystem{SystemID: 30000142, Name: "Jita"}, nil
+		},
+	}
+
+	w := New(q, esiMock, mockTokens{1: "tok1", 2: "tok2", 3: "tok3"}, time.Minute)
+	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 3)
+	w.resolvePendingLocations(context.Background())
+
+	if want := []string{"tok3", "tok1", "tok2"}; !slices.Equal(tried, want) {
+		t.Errorf("tokens tried = %v, want %v", tried, want)
+	}
+	want := []struct {
+		characterID int64
+		status      string
+	}{
+		{3, structureAccessForbidden},
+		{1, structureAccessForbidden},
+		{2, structureAccessGranted},
+	}
+	if len(recorded) != len(want) {
+		t.Fatalf("recorded %d access outcomes, want %d", len(recorded), len(want))
+	}
+	for i, rec := range recorded {
+		if rec.StructureID != structureID || rec.CharacterID != want[i].characterID || rec.Status != want[i].status {
+			t.Errorf("access[%d] = %d/%d %q, want %d/%d %q", i,
+				rec.StructureID, rec.CharacterID, rec.Status, structureID, want[i].characterID, want[i].status)
+		}
+	}
+	if !slices.Contains(insertedIDs, structureID) {
+		t.Errorf("structure %d not inserted, got %v", structureID, insertedIDs)
+	}
+}
+
+// --- TestResolveLocationIDs_Structure_ForbiddenCooldown ---
+// Verifies that a character with recorded access is tried first and that a
+// character refused access is retried only once the cooldown has passed.
+func TestResolveLocationIDs_Structure_ForbiddenCooldown(t *testing.T) {
+	const structureID int64 = 1_000_000_000_009
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	q := &mockQuerier{
+		listCharsFunc: func() ([]store.Character, error) {
+			return []store.Character{{ID: 1}, {ID: 2}, {ID: 3}}, nil
+		},
+		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
+			return []store.ListBlueprintLocationsByOwnerRow{
+				{LocationID: structureID, LocationFlag: "Hangar"},
+			}, nil
+		},
+		getLocationFunc: func(id int64) (store.EveLocation, error) {
+			return store.EveLocation{}, errors.New("not found")
+		},
+		getEveSystemFunc:    func(int64) (store.EveSystem, error) { return store.EveSystem{}, errors.New("not found") },
+		upsertEveSystemFunc: func(store.UpsertEveSystemParams) error { return nil },
+		insertLocationFunc:  func(_ store.InsertLocationParams) error { return nil },
+		listStructureAccessFunc: func(int64) ([]store.StructureAccess, error) {
+			return []store.StructureAccess{
+				{StructureID: structureID, CharacterID: 1, Status: structureAccessForbidden, CheckedAt: now.Add(-time.Hour)},
+				{StructureID: structureID, CharacterID: 2, Status: structureAccessForbidden, CheckedAt: now.Add(-structureForbiddenCooldown - time.Hour)},
+				{StructureID: structureID, CharacterID: 3, Status: structureAccessGranted, CheckedAt: now.Add(-time.Hour)},
+			}, nil
+		},
+		upsertStructureAccessFunc: func(store.UpsertStructureAccessParams) error { return nil },
+	}
+
+	var tried []string
+	esiMock := &mockESIClient{
+		getUniverseStructFunc: func(_ context.Context, _ int64, token string) (esi.UniverseStructure, error) {
+			tried = append(tried, token)
+			if token == "tok3" { // docking rights revoked since the last lookup
+				return esi.UniverseStructure{}, esi.ErrForbidden
+			}
+			return esi.UniverseStructure{Name: "Raitaru", SolarSystemID: 30000142}, nil
+		},
+		getUniverseSystemFunc: func(_ context.Context, _ int64) (esi.UniverseSystem, error) {
+			return esi.UniverseSystem{SystemID: 30000142, Name: "Jita"}, nil
+		},
+	}
+
+	w := New(q, esiMock, mockTokens{1: "tok1", 2: "tok2", 3: "tok3"}, time.Minute)
+	w.now = func() time.Time { return now }
+	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 1)
+	w.resolvePendingLocations(context.Background())
+
+	if want := []string{"tok3", "tok2"}; !slices.Equal(tried, want) {
+		t.Errorf("tokens tried = %v, want %v (character 1 is still in cooldown)", tried, want)
+	}
+}
+
+// --- TestStructureCandidates_OwnersFirst ---
+// Verifies that the owning character or the owning corporation's members come
+// first and that flagged characters are left out.
+func TestStructureCandidates_OwnersFirst(t *testing.T) {
+	q := &mockQuerier{
+		listCharsFunc: func() ([]store.Character, error) {
+			return []store.Character{
+				{ID: 1, CorporationID: 10},
+				{ID: 2, CorporationID: 20},
+				{ID: 3, CorporationID: 20, NeedsReauth: 1},
+				{ID: 4, CorporationID: 20},
+				{ID: 5, CorporationID: 10, Transferred: 1},
+			}, nil
+		},
+	}
+	w := New(q, nil, nil, time.Minute)
+
+	if got, want := w.structureCandidates(context.Background(), ownerTypeCorporation, 20), []int64{2, 4, 1}; !slices.Equal(got, want) {
+		t.Errorf("corporation candidates = %v, want %v", got, want)
+	}
+	if got, want := w.structureCandidates(context.Background(), ownerTypeCharacter, 4), []int64{4, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("character candidates = %v, want %v", got, want)
+	}
+}
+
+// --- TestResolveLocationIDs_StaleStructure_Renamed ---
+// Verifies that a cached structure older than locationTTL is resolved again,
+// picking up its new name.
+func TestResolveLocationIDs_StaleStructure_Renamed(t *testing.T) {
+	const structureID int64 = 1_000_000_000_010
+	const systemID int64 = 30000142
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	var insertedLocations []store.InsertLocationParams
+
+	q := &mockQuerier{
+		listCharsFunc: func() ([]store.Character, error) {
+			return []store.Character{{ID: 1}}, nil
+		},
+		listStructureAccessFunc:   func(int64) ([]store.StructureAccess, error) { return nil, nil },
+		upsertStructureAccessFunc: func(store.UpsertStructureAccessParams) error { return nil },
+		listBlueprintLocationsByOwnerFunc: func(_ store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
+			return []store.ListBlueprintLocationsByOwnerRow{
+				{LocationID: structureID, LocationFlag: "Hangar"},
+			}, nil
+		},
+		getLocationFunc: func(id int64) (store.EveLocation, error) {
+			return store.EveLocation{
+				ID:            id,
+				Name:          "Jita — Old Name",
+				ResolvedAt:    now.Add(-locationTTL - time.Hour),
+				SolarSystemID: sql.NullInt64{Int64: systemID, Valid: true},
+			}, nil
+		},
+		getEveSystemFunc: func(id int64) (store.EveSystem, error) {
+			return store.EveSystem{ID: id, Name: "Jita", ResolvedAt: now}, nil
+		},
+		insertLocationFunc: func(arg store.InsertLocationParams) error {
+			insertedLocations = append(insertedLocations, arg)
+			return nil
+		},
+	}
+
+	esiMock := &mockESIClient{
+		getUniverseStructFunc: func(_ context.Context, _ int64, _ string) (esi.UniverseStructure, error) {
+			return esi.UniverseStructure{Name: "New Name", SolarSystemID: systemID}, nil
+		},
+	}
+
+	w := New(q, esiMock, mockTokens{1: "tok"}, time.Minute)
+	w.now = func() time.Time { return now }
+	w.resolveLocationIDs(context.Background(), ownerTypeCharacter, 1)
+	w.resolvePendingLocations(context.Background())
+
+	if len(insertedLocations) != 1 {
+		t.Fatalf("expected 1 InsertLocation call, got %d", len(insertedLocations))
+	}
+	if want := "Jita — New Name"; insertedLocations[0].Name != want {
+		t.Errorf("InsertLocation Name: got %q, want %q", insertedLocations[0].Name, want)
+	}
+	if !insertedLocations[0].ResolvedAt.Equal(now) {
+		t.Errorf("InsertLocation ResolvedAt: got %v, want %v", insertedLocations[0].ResolvedAt, now)
+	}
+}
+
+// --- TestLocationFresh ---
+// Verifies the refresh periods of cached locations.
+func TestLocationFresh(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	system := sql.NullInt64{Int64: 30000142, Valid: true}
+
+	tests := []struct {
+		name string
+		loc  store.EveLocation
+		want bool
+	}{
+		{"recent", store.EveLocation{Name: "Jita IV", ResolvedAt: now.Add(-time.Hour), SolarSystemID: system}, true},
+		{"older than TTL", store.EveLocation{Name: "Jita IV", ResolvedAt: now.Add(-locationTTL), SolarSystemID: system}, false},
+		{"no solar system", store.EveLocation{Name: "Jita IV", ResolvedAt: now.Add(-time.Hour)}, false},
+		{"recent sentinel", store.EveLocation{Name: unknownLocationSentinel, ResolvedAt: now.Add(-time.Hour)}, true},
+		{"sentinel older than sentinel TTL", store.EveLocation{Name: unknownLocationSentinel, ResolvedAt: now.Add(-sentinelTTL)}, false},
+	}
+	for _, tt := range tests {
+		if got := locationFresh(tt.loc, now); got != tt.want {
+			t.Errorf("%s: locationFresh = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// --- TestLocationPath_ContainerInDivision ---
+// Verifies that a blueprint in a named container in a corp hangar division gets
+// the division and the container in its path, rooted at the office's station.
+func TestLocationPath_ContainerInDivision(t *testing.T) {
+	const officeItemID int64 = 1_052_718_829_566
+	const containerID int64 = 1_052_718_900_001
+	const stationID int64 = 60003760
+
+	q := &mockQuerier{
+		getAssetFunc: func(itemID int64) (store.Asset, error) {
+			switch itemID {
+			case containerID:
+				return store.Asset{
+					ItemID: containerID, OwnerType: ownerTypeCorporation, OwnerID: 99000001,
+					LocationID: officeItemID, LocationFlag: "CorpSAG3", LocationType: "item",
+					TypeID: 17366, Name: sql.NullString{String: "T2 BPOs", Valid: true},
+				}, nil
+			case officeItemID:
+				return officeAsset(officeItemID, stationID), nil
+			}
+			return store.Asset{}, sql.ErrNoRows
+		},
+		getEveTypeFunc: func(id int64) (store.EveType, error) {
+			return store.EveType{ID: id, Name: "Station Container"}, nil
+		},
+		getCorpDivisionNameFunc: func(store.GetCorpDivisionNameParams) (string, error) {
+			return "Research BPOs", nil
+		},
+	}
+
+	w := New(q, &mockESIClient{}, nil, time.Minute)
+	rootID, path, ok := w.locationPath(context.Background(), containerID, "Unlocked")
+
+	const wantPath = "Corp Hangar 3 (Research BPOs) › Station Container 'T2 BPOs'"
+	if !ok || rootID != stationID || path != wantPath {
+		t.Errorf("locationPath = %d, %q, %v; want %d, %q, true", rootID, path, ok, stationID, wantPath)
+	}
+}
+
+// --- TestLocationPath_CharacterHangar ---
+// Verifies that a character blueprint directly in a station hangar has an empty
+// path, and one in an unnamed container only the container's type.
+func TestLocationPath_CharacterHangar(t *testing.T) {
+	const containerID int64 = 1_052_718_900_002
+	const stationID int64 = 60003760
+
+	q := &mockQuerier{
+		getAssetFunc: func(itemID int64) (store.Asset, error) {
+			if itemID == containerID {
+				return store.Asset{
+					ItemID: containerID, OwnerType: ownerTypeCharacter, OwnerID: 1,
+					LocationID: stationID, LocationFlag: "Hangar", LocationType: "station", TypeID: 17365,
+				}, nil
+			}
+			return store.Asset{}, sql.ErrNoRows
+		},
+		// Type not resolved yet.
+		getEveTypeFunc: func(int64) (store.EveType, error) { return store.EveType{}, sql.ErrNoRows },
+	}
+
+	w := New(q, &mockESIClient{}, nil, time.Minute)
+	ctx := context.Background()
+
+	if rootID, path, ok := w.locationPath(ctx, stationID, "Hangar"); !ok || rootID != stationID || path != "" {
+		t.Errorf("hangar: locationPath = %d, %q, %v; want %d, \"\", true", rootID, path, ok, stationID)
+	}
+	if rootID, path, ok := w.locationPath(ctx, containerID, "Unlocked"); !ok || rootID != stationID || path != "Container" {
+		t.Errorf("container: locationPath = %d, %q, %v; want %d, \"Container\", true", rootID, path, ok, stationID)
+	}
+}
+
+// --- TestSyncAssets_StoresTreeWithNames ---
+// Verifies that only assets holding other assets and offices are stored, that
+// names are fetched for singleton items only, and that "None" means unnamed.
+func TestSyncAssets_StoresTreeWithNames(t *testing.T) {
+	const stationID int64 = 60003760
+
+	var stored []store.UpsertAssetParams
+	var resolvedTypes []int64
+	q := &mockQuerier{
+		upsertAssetFunc: func(arg store.UpsertAssetParams) error {
+			stored = append(stored, arg)
+			return nil
+		},
+		getEveTypeFunc: func(id int64) (store.EveType, error) {
+			resolvedTypes = append(resolvedTypes, id)
+			return store.EveType{ID: id}, nil
+		},
+	}
+	var askedNames []int64
+	esiMock := &mockESIClient{
+		charAssetsFunc: func(_ context.Context, _ int64, _ string, _ int) ([]esi.Asset, int, time.Time, error) {
+			return []esi.Asset{
+				{ItemID: 10, TypeID: 17366, LocationID: stationID, LocationFlag: "Hangar", LocationType: "station", IsSingleton: true},
+				{ItemID: 11, TypeID: 17365, LocationID: stationID, LocationFlag: "Hangar", LocationType: "station", IsSingleton: true},
+				{ItemID: 12, TypeID: 34, LocationID: stationID, LocationFlag: "Hangar", LocationType: "station"},
+				{ItemID: 20, TypeID: 5000, LocationID: 10, LocationFlag: "Unlocked", LocationType: "item", IsSingleton: true},
+				{ItemID: 21, TypeID: 5000, LocationID: 11, LocationFlag: "Unlocked", LocationType: "item", IsSingleton: true},
+			}, 1, time.Now().Add(time.Hour), nil
+		},
+		assetNamesFunc: func(_ context.Context, _ int64, _ string, itemIDs []int64) ([]esi.AssetName, error) {
+			askedNames = itemIDs
+			return []esi.AssetName{{ItemID: 10, Name: "T2 BPOs"}, {ItemID: 11, Name: "None"}}, nil
+		},
+	}
+
+	w := New(q, esiMock, nil, time.Minute)
+	if _, err := w.syncAssets(context.Background(), ownerTypeCharacter, 1); err != nil {
+		t.Fatalf("syncAssets: %v", err)
+	}
+
+	if !slices.Equal(askedNames, []int64{10, 11}) {
+		t.Errorf("asset names asked for %v, want [10 11]", askedNames)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("expected 2 stored assets, got %d: %+v", len(stored), stored)
+	}
+	if stored[0].ItemID != 10 || stored[0].Name != (sql.NullString{String: "T2 BPOs", Valid: true}) {
+		t.Errorf("first asset: got %d %+v, want 10 named T2 BPOs", stored[0].ItemID, stored[0].Name)
+	}
+	if stored[1].ItemID != 11 || stored[1].Name.Valid {
+		t.Errorf("second asset: got %d %+v, want 11 unnamed", stored[1].ItemID, stored[1].Name)
+	}
+	if stored[0].OwnerType != ownerTypeCharacter || stored[0].OwnerID != 1 {
+		t.Errorf("owner: got %s %d, want character 1", stored[0].OwnerType, stored[0].OwnerID)
+	}
+	if !slices.Equal(resolvedTypes, []int64{17366, 17365}) {
+		t.Errorf("resolved types: got %v, want [17366 17365]", resolvedTypes)
+	}
+}
+
+// --- TestSyncDivisions_StoresHangarNames ---
+// Verifies that the corporation's hangar division names are stored.
+func TestSyncDivisions_StoresHangarNames(t *testing.T) {
+	var stored []store.UpsertCorpDivisionParams
+	q := &mockQuerier{
+		upsertCorpDivisionFunc: func(arg store.UpsertCorpDivisionParams) error {
+			stored = append(stored, arg)
+			return nil
+		},
+	}
+	esiMock := &mockESIClient{
+		corpDivisionsFunc: func(_ context.Context, _ int64, _ string) ([]esi.Division, time.Time, error) {
+			return []esi.Division{{Division: 1, Name: ""}, {Division: 3, Name: "Research BPOs"}}, time.Now().Add(time.Hour), nil
+		},
+	}
+
+	w := New(q, esiMock, nil, time.Minute)
+	if _, err := w.syncDivisions(context.Background(), 99000001); err != nil {
+		t.Fatalf("syncDivisions: %v", err)
+	}
+
+	want := []store.UpsertCorpDivisionParams{
+		{CorporationID: 99000001, Division: 1, Name: ""},
+		{CorporationID: 99000001, Division: 3, Name: "Research BPOs"},
 	}
-	if insertedLocations[0].Name != corpHangarSentinel {
-		t.Errorf("InsertLocation Name (404): got %q, want %q", insertedLocations[0].Name, corpHangarSentinel)
+	if !slices.Equal(stored, want) {
+		t.Errorf("stored divisions: got %+v, want %+v", stored, want)
 	}
 }
@@ -17,11 +17,18 @@ type mockESIClient struct {
 	corpBlueprintsFunc    func(context.Context, int64, string) ([]esi.Blueprint, time.Time, error)
 	charJobsFunc          func(context.Context, int64, string) ([]esi.Job, time.Time, error)
 	corpJobsFunc          func(context.Context, int64, string) ([]esi.Job, time.Time, error)
+	charRolesFunc         func(context.Context, int64, string) ([]string, time.Time, error)
 	getUniverseTypeFunc   func(context.Context, int64) (esi.UniverseType, error)
+	getUniverseGroupFunc  func(context.Context, int64) (esi.UniverseGroup, error)
+	getUniverseCatFunc    func(context.Context, int64) (esi.UniverseCategory, error)
 	postUniverseNamesFunc func(context.Context, []int64) ([]esi.UniverseNamesEntry, error)
 	getUniverseStructFunc func(context.Context, int64, string) (esi.UniverseStructure, error)
-	getUniverseSystemFunc func(context.Context, int64) (string, error)
-	getStationFunc        func(context.Context, int64) (string, error)
+	getUniverseSystemFunc func(context.Context, int64) (esi.UniverseSystem, error)
+	getStationFunc        func(context.Context, int64) (esi.UniverseStation, error)
+	charAssetsFunc        func(context.Context, int64, string, int) ([]esi.Asset, int, time.Time, error)
+	corpAssetsFunc        func(context.Context, int64, string, int) ([]esi.Asset, int, time.Time, error)
+	assetNamesFunc        func(context.Context, int64, string, []int64) ([]esi.AssetName, error)
+	corpDivisionsFunc     func(context.Context, int64, string) ([]esi.Division, time.Time, error)
 }
 
 func (m *mockESIClient) GetCharacterBlueprints(ctx context.Context, id int64, token string) ([]esi.Blueprint, time.Time, error) {
@@ -45,6 +52,13 @@ func (m *mockESIClient) GetCharacterJobs(ctx context.Context, id int64, token st
 	panic("unexpected call to GetCharacterJobs")
 }
 
+func (m *mockESIClient) GetCharacterRoles(ctx context.Context, id int64, token string) ([]string, time.Time, error) {
+	if m.charRolesFunc != nil {
+		return m.charRolesFunc(ctx, id, token)
+	}
+	panic("unexpected call to GetCharacterRoles")
+}
+
 func (m *mockESIClient) GetCorporationJobs(ctx context.Context, id int64, token string) ([]esi.Job, time.Time, error) {
 	if m.corpJobsFunc != nil {
 		return m.corpJobsFunc(ctx, id, token)
@@ -59,11 +73,31 @@ func (m *mockESIClient) GetUniverseType(ctx context.Context, typeID int64) (esi.
 	panic("unexpected call to GetUniverseType")
 }
 
+func (m *mockESIClient) GetUniverseGroup(ctx context.Context, groupID int64) (esi.UniverseGroup, error) {
+	if m.getUniverseGroupFunc != nil {
+		return m.getUniverseGroupFunc(ctx, groupID)
+	}
+	panic("unexpected call to GetUniverseGroup")
+}
+
+func (m *mockESIClient) GetUniverseCategory(ctx context.Context, categoryID int64) (esi.UniverseCategory, error) {
+	if m.getUniverseCatFunc != nil {
+		return m.getUniverseCatFunc(ctx, categoryID)
+	}
+	panic("unexpected call to GetUniverseCategory")
+}
+
+func (m *mockESIClient) PostCharactersAffiliation(_ context.Context, _ []int64) ([]esi.CharacterAffiliation, error) {
+	panic("unexpected call to PostCharactersAffiliation")
+}
+
 func (m *mockESIClient) PostUniverseNames(ctx context.Context, ids []int64) ([]esi.UniverseNamesEntry, error) {
 	if m.postUniverseNamesFunc != nil {
 		return m.postUniverseNamesFunc(ctx, ids)
 	}
-	panic("unexpected call to PostUniverseNames")
+	// Default: no names known — type and location resolution fall back to
+	// the per-ID endpoints.
+	return nil, nil
 }
 
 func (m *mockESIClient) GetUniverseStructure(ctx context.Context, id int64, token string) (esi.UniverseStructure, error) {
@@ -73,18 +107,50 @@ func (m *mockESIClient) GetUniverseStructure(ctx context.Context, id int64, toke
 	panic("unexpected call to GetUniverseStructure")
 }
 
-func (m *mockESIClient) GetUniverseSystem(ctx context.Context, id int64) (string, error) {
+func (m *mockESIClient) GetUniverseSystem(ctx context.Context, id int64) (esi.UniverseSystem, error) {
 	if m.getUniverseSystemFunc != nil {
 		return m.getUniverseSystemFunc(ctx, id)
 	}
 	panic("unexpected call to GetUniverseSystem")
 }
 
-func (m *mockESIClient) GetCorporationAssets(_ context.Context, _ int64, _ string, _ int) ([]esi.CorpAsset, int, time.Time, error) {
+func (m *mockESIClient) GetCharacterAssets(ctx context.Context, id int64, token string, page int) ([]esi.Asset, int, time.Time, error) {
+	if m.charAssetsFunc != nil {
+		return m.charAssetsFunc(ctx, id, token, page)
+	}
+	panic("unexpected call to GetCharacterAssets")
+}
+
+func (m *mockESIClient) GetCorporationAssets(ctx context.Context, id int64, token string, page int) ([]esi.Asset, int, time.Time, error) {
+	if m.corpAssetsFunc != nil {
+		return m.corpAssetsFunc(ctx, id, token, page)
+	}
 	panic("unexpected call to GetCorporationAssets")
 }
 
-func (m *mockESIClient) GetStation(ctx context.Context, id int64) (string, error) {
+func (m *mockESIClient) GetCorporationDivisions(ctx context.Context, id int64, token string) ([]esi.Division, time.Time, error) {
+	if m.corpDivisionsFunc != nil {
+		return m.corpDivisionsFunc(ctx, id, token)
+	}
+	panic("unexpected call to GetCorporationDivisions")
+}
+
+// PostCharacterAssetNames and PostCorporationAssetNames share assetNamesFunc.
+func (m *mockESIClient) PostCharacterAssetNames(ctx context.Context, id int64, token string, itemIDs []int64) ([]esi.AssetName, error) {
+	if m.assetNamesFunc != nil {
+		return m.assetNamesFunc(ctx, id, token, itemIDs)
+	}
+	panic("unexpected call to PostCharacterAssetNames")
+}
+
+func (m *mockESIClient) PostCorporationAssetNames(ctx context.Context, id int64, token string, itemIDs []int64) ([]esi.AssetName, error) {
+	if m.assetNamesFunc != nil {
+		return m.assetNamesFunc(ctx, id, token, itemIDs)
+	}
+	panic("unexpected call to PostCorporationAssetNames")
+}
+
+func (m *mockESIClient) GetStation(ctx context.Context, id int64) (esi.UniverseStation, error) {
 	if m.getStationFunc != nil {
 		return m.getStationFunc(ctx, id)
 	}
@@ -133,7 +199,7 @@ func TestSyncBlueprints_UpsertsAll(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.syncSubject(context.Background(), ownerTypeCharacter, charID, endpointBlueprints)
 
 	if len(upsertedBPs) != len(bps) {
@@ -215,7 +281,7 @@ func TestSyncBlueprints_UpsertError_ContinuesOtherBlueprints(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.syncSubject(context.Background(), ownerTypeCharacter, charID, endpointBlueprints)
 
 	if len(upsertedIDs) != 1 || upsertedIDs[0] != 1002 {
@@ -226,6 +292,155 @@ func TestSyncBlueprints_UpsertError_ContinuesOtherBlueprints(t *testing.T) {
 	}
 }
 
+// --- TestSyncBlueprints_PrunesStaleAndRecordsEvents ---
+// Verifies that blueprints stored for the owner but absent from the ESI response
+// are deleted (jobs first, then the blueprint), and that every difference
+// against the stored library is recorded in blueprint_events.
+func TestSyncBlueprints_PrunesStaleAndRecordsEvents(t *testing.T) {
+	const charID int64 = 42
+
+	existing := []store.Blueprint{
+		{ID: 1001, OwnerType: ownerTypeCharacter, OwnerID: charID, TypeID: 500, LocationID: 60000004, MeLevel: 5, TeLevel: 10},
+		{ID: 1003, OwnerType: ownerTypeCharacter, OwnerID: charID, TypeID: 502, LocationID: 60000004},
+	}
+	incoming := []esi.Blueprint{
+		// 1001: ME researched 5 → 7 and moved to another station.
+		{ItemID: 1001, TypeID: 500, LocationID: 60003760, MELevel: 7, TELevel: 10},
+		// 1002: new blueprint.
+		{ItemID: 1002, TypeID: 501, LocationID: 60000004},
+		// 1003 is absent → stale.
+	}
+
+	var deletedJobsFor, deletedBPs []int64
+	var events []store.InsertBlueprintEventParams
+
+	q := &mockQuerier{
+		getEveTypeFunc: func(id int64) (store.EveType, error) { return store.EveType{ID: id}, nil },
+		listBlueprintsByOwnerFunc: func(arg store.ListBlueprintsByOwnerParams) ([]store.Blueprint, error) {
+			if arg.OwnerType != ownerTypeCharacter || arg.OwnerID != charID {
+				t.Errorf("ListBlueprintsByOwner: unexpected owner %s %d", arg.OwnerType, arg.OwnerID)
+			}
+			return existing, nil
+		},
+		upsertBlueprintFunc: func(_ store.UpsertBlueprintParams) error { return nil },
+		upsertSyncStateFunc: func(_ store.UpsertSyncStateParams) error { return nil },
+		deleteJobsByBlueprintFunc: func(id int64) error {
+			deletedJobsFor = append(deletedJobsFor, id)
+			return nil
+		},
+		deleteBlueprintByIDFunc: func(id int64) error {
+			if len(deletedJobsFor) == 0 || deletedJobsFor[len(deletedJobsFor)-1] != id {
+				t.Errorf("blueprint %d deleted before its jobs", id)
+			}
+			deletedBPs = append(deletedBPs, id)
+			return nil
+		},
+		insertBlueprintEventFunc: func(arg store.InsertBlueprintEventParams) error {
+			events = append(events, arg)
+			return nil
+		},
+	}
+	esiMock := &mockESIClient{
+		charBlueprintsFunc: func(_ context.Context, _ int64, _ string) ([]esi.Blueprint, time.Time, error) {
+			return incoming, time.Now().Add(time.Minute), nil
+		},
+	}
+
+	w := New(q, esiMock, nil, time.Minute)
+	if _, err := w.syncBlueprints(context.Background(), ownerTypeCharacter, charID); err != nil {
+		t.Fatalf("syncBlueprints: %v", err)
+	}
+
+	if len(deletedBPs) != 1 || deletedBPs[0] != 1003 {
+		t.Errorf("deleted blueprints: got %v, want [1003]", deletedBPs)
+	}
+
+	type ev struct {
+		id              int64
+		event, old, new string
+	}
+	want := []ev{
+		{1001, blueprintEventMEChanged, "5", "7"},
+		{1001, blueprintEventMoved, "60000004", "60003760"},
+		{1002, blueprintEventAdded, "", ""},
+		{1003, blueprintEventRemoved, "", ""},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(events), events)
+	}
+	for i, e := range events {
+		got := ev{e.BlueprintID, e.Event, e.OldValue.String, e.NewValue.String}
+		if got != want[i] {
+			t.Errorf("event[%d]: got %+v, want %+v", i, got, want[i])
+		}
+		if e.OwnerType != ownerTypeCharacter || e.OwnerID != charID {
+			t.Errorf("event[%d]: owner got %s %d, want %s %d", i, e.OwnerType, e.OwnerID, ownerTypeCharacter, charID)
+		}
+	}
+}
+
+// --- TestSyncBlueprints_InitialImport_NoAddedEvents ---
+// Verifies that the first sync of an owner with no stored blueprints does not
+// record an "added" event per blueprint (insertBlueprintEventFunc is unset, so
+// any call would panic).
+func TestSyncBlueprints_InitialImport_NoAddedEvents(t *testing.T) {
+	q := &mockQuerier{
+		getEveTypeFunc:      func(id int64) (store.EveType, error) { return store.EveType{ID: id}, nil },
+		upsertBlueprintFunc: func(_ store.UpsertBlueprintParams) error { return nil },
+	}
+	esiMock := &mockESIClient{
+		charBlueprintsFunc: func(_ context.Context, _ int64, _ string) ([]esi.Blueprint, time.Time, error) {
+			return []esi.Blueprint{{ItemID: 1001, TypeID: 500}, {ItemID: 1002, TypeID: 501}}, time.Now(), nil
+		},
+	}
+
+	w := New(q, esiMock, nil, time.Minute)
+	if _, err := w.syncBlueprints(context.Background(), ownerTypeCharacter, 42); err != nil {
+		t.Fatalf("syncBlueprints: %v", err)
+	}
+}
+
+// --- TestSyncBlueprints_OwnerChanged_RecordsEvent ---
+// Verifies that a blueprint previously stored under another tracked owner is
+// recorded as an owner change rather than as a new blueprint.
+func TestSyncBlueprints_OwnerChanged_RecordsEvent(t *testing.T) {
+	const corpID int64 = 99
+
+	var events []store.InsertBlueprintEventParams
+	q := &mockQuerier{
+		getEveTypeFunc: func(id int64) (store.EveType, error) { return store.EveType{ID: id}, nil },
+		getBlueprintFunc: func(id int64) (store.Blueprint, error) {
+			return store.Blueprint{ID: id, OwnerType: ownerTypeCharacter, OwnerID: 42, TypeID: 500, LocationID: 60000004}, nil
+		},
+		upsertBlueprintFunc: func(_ store.UpsertBlueprintParams) error { return nil },
+		insertBlueprintEventFunc: func(arg store.InsertBlueprintEventParams) error {
+			events = append(events, arg)
+			return nil
+		},
+	}
+	esiMock := &mockESIClient{
+		corpBlueprintsFunc: func(_ context.Context, _ int64, _ string) ([]esi.Blueprint, time.Time, error) {
+			return []esi.Blueprint{{ItemID: 1001, TypeID: 500, LocationID: 60000004}}, time.Now(), nil
+		},
+	}
+
+	w := New(q, esiMock, nil, time.Minute)
+	if _, err := w.syncBlueprints(context.Background(), ownerTypeCorporation, corpID); err != nil {
+		t.Fatalf("syncBlueprints: %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d: %+v", len(events), events)
+	}
+	e := events[0]
+	if e.Event != blueprintEventOwnerChanged {
+		t.Errorf("event: got %q, want %q", e.Event, blueprintEventOwnerChanged)
+	}
+	if e.OldValue.String != "character:42" || e.NewValue.String != "corporation:99" {
+		t.Errorf("values: got %q → %q, want %q → %q", e.OldValue.String, e.NewValue.String, "character:42", "corporation:99")
+	}
+}
+
 // --- TestSyncJobs_UpsertAndPruneStale ---
 // Verifies that syncSubject upserts incoming jobs, deletes stale jobs (present
 // in store but absent from ESI response), and updates sync_state.
@@ -244,15 +459,18 @@ func TestSyncJobs_UpsertAndPruneStale(t *testing.T) {
 		},
 	}
 	// Job 202 is in the store but not in the ESI response → stale, must be deleted.
-	existingIDs := []int64{201, 202}
+	existing := []store.Job{
+		{ID: 201, BlueprintID: 1001, Activity: "me_research", Status: "active", EndDate: end},
+		{ID: 202, BlueprintID: 1001, Activity: "te_research", Status: "active", EndDate: end},
+	}
 
 	var upsertedJobs []store.UpsertJobParams
 	var deletedIDs []int64
 	var syncStateArg store.UpsertSyncStateParams
 
 	q := &mockQuerier{
-		listJobIDsByOwnerFunc: func(_ store.ListJobIDsByOwnerParams) ([]int64, error) {
-			return existingIDs, nil
+		listJobsByOwnerFunc: func(_ store.ListJobsByOwnerParams) ([]store.Job, error) {
+			return existing, nil
 		},
 		upsertJobFunc: func(arg store.UpsertJobParams) error {
 			upsertedJobs = append(upsertedJobs, arg)
@@ -274,7 +492,7 @@ func TestSyncJobs_UpsertAndPruneStale(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.syncSubject(context.Background(), ownerTypeCharacter, charID, endpointJobs)
 
 	// Verify upsert.
@@ -337,7 +555,7 @@ func TestSyncSubject_ESIError_SkipsSyncState(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.syncSubject(context.Background(), ownerTypeCharacter, 1, endpointBlueprints)
 
 	if syncStateUpdated {
@@ -364,7 +582,7 @@ func TestSyncBlueprints_CorporationOwner(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.syncSubject(context.Background(), ownerTypeCorporation, corpID, endpointBlueprints)
 
 	if !corpCalled {
@@ -379,8 +597,8 @@ func TestSyncJobs_CorporationOwner(t *testing.T) {
 
 	corpCalled := false
 	q := &mockQuerier{
-		listJobIDsByOwnerFunc: func(_ store.ListJobIDsByOwnerParams) ([]int64, error) { return nil, nil },
-		upsertSyncStateFunc:   func(_ store.UpsertSyncStateParams) error { return nil },
+		listJobsByOwnerFunc: func(_ store.ListJobsByOwnerParams) ([]store.Job, error) { return nil, nil },
+		upsertSyncStateFunc: func(_ store.UpsertSyncStateParams) error { return nil },
 	}
 	esiMock := &mockESIClient{
 		corpJobsFunc: func(_ context.Context, id int64, _ string) ([]esi.Job, time.Time, error) {
@@ -392,7 +610,7 @@ func TestSyncJobs_CorporationOwner(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.syncSubject(context.Background(), ownerTypeCorporation, corpID, endpointJobs)
 
 	if !corpCalled {
@@ -400,14 +618,58 @@ func TestSyncJobs_CorporationOwner(t *testing.T) {
 	}
 }
 
+// --- TestSyncRoles_StoresRoles ---
+// Verifies that the roles endpoint stores the character's roles space-separated
+// and records the ESI cache expiry, and that an empty role list is stored as an empty string.
+func TestSyncRoles_StoresRoles(t *testing.T) {
+	const charID int64 = 42
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	for _, tc := range []struct {
+		roles []string
+		want  string
+	}{
+		{roles: []string{"Director", "Factory_Manager"}, want: "Director Factory_Manager"},
+		{roles: nil, want: ""},
+	} {
+		var stored store.UpdateCharacterRolesParams
+		var syncStateArg store.UpsertSyncStateParams
+		q := &mockQuerier{
+			updateCharacterRolesFunc: func(arg store.UpdateCharacterRolesParams) error {
+				stored = arg
+				return nil
+			},
+			upsertSyncStateFunc: func(arg store.UpsertSyncStateParams) error {
+				syncStateArg = arg
+				return nil
+			},
+		}
+		esiMock := &mockESIClient{
+			charRolesFunc: func(_ context.Context, _ int64, _ string) ([]string, time.Time, error) {
+				return tc.roles, expiry, nil
+			},
+		}
+
+		w := New(q, esiMock, nil, time.Minute)
+		w.syncSubject(context.Background(), ownerTypeCharacter, charID, endpointRoles)
+
+		if stored.ID != charID || !stored.Roles.Valid || stored.Roles.String != tc.want {
+			t.Errorf("roles %v: stored %+v, want %q for character %d", tc.roles, stored, tc.want, charID)
+		}
+		if syncStateArg.Endpoint != endpointRoles || !syncStateArg.CacheUntil.Equal(expiry) {
+			t.Errorf("roles %v: sync_state = %+v, want endpoint %q cache_until %v", tc.roles, syncStateArg, endpointRoles, expiry)
+		}
+	}
+}
+
 // --- TestSyncJobs_NoStaleJobs ---
 // Verifies that no deletion calls occur when all stored job IDs are still in the ESI response.
 func TestSyncJobs_NoStaleJobs(t *testing.T) {
 	deleteCallCount := 0
 
 	q := &mockQuerier{
-		listJobIDsByOwnerFunc: func(_ store.ListJobIDsByOwnerParams) ([]int64, error) {
-			return []int64{301}, nil // one existing job, same as incoming
+		listJobsByOwnerFunc: func(_ store.ListJobsByOwnerParams) ([]store.Job, error) {
+			return []store.Job{{ID: 301}}, nil // one existing job, same as incoming
 		},
 		upsertJobFunc: func(_ store.UpsertJobParams) error { return nil },
 		deleteJobByIDFunc: func(_ int64) error {
@@ -426,7 +688,7 @@ func TestSyncJobs_NoStaleJobs(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.syncSubject(context.Background(), ownerTypeCharacter, 1, endpointJobs)
 
 	if deleteCallCount != 0 {
@@ -452,7 +714,7 @@ func TestSyncSubject_ESIError_RecordsError(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.syncSubject(context.Background(), ownerTypeCharacter, 1, endpointBlueprints)
 
 	if !recordedError.LastError.Valid {
@@ -494,7 +756,7 @@ func TestSyncSubject_Success_ClearsError(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.syncSubject(context.Background(), ownerTypeCharacter, 1, endpointBlueprints)
 
 	if clearedError.LastError.Valid {
@@ -523,7 +785,7 @@ func TestSyncJobs_UpsertError_ContinuesOtherJobs(t *testing.T) {
 	syncStateUpdated := false
 
 	q := &mockQuerier{
-		listJobIDsByOwnerFunc: func(_ store.ListJobIDsByOwnerParams) ([]int64, error) {
+		listJobsByOwnerFunc: func(_ store.ListJobsByOwnerParams) ([]store.Job, error) {
 			return nil, nil
 		},
 		upsertJobFunc: func(arg store.UpsertJobParams) error {
@@ -545,7 +807,7 @@ func TestSyncJobs_UpsertError_ContinuesOtherJobs(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.syncSubject(context.Background(), ownerTypeCharacter, charID, endpointJobs)
 
 	if len(upsertedJobIDs) != 1 {
@@ -2,7 +2,9 @@ package sync
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"slices"
 	"testing"
 	"time"
 
@@ -11,22 +13,18 @@ import (
 )
 
 // --- TestResolveTypeIDs_NewTypeTriggersESIAndInserts ---
-// Verifies that a type_id absent from eve_types triggers an ESI fetch and
-// all three inserts (category → group → type) with correct field values.
+// Verifies that a type_id absent from eve_types triggers ESI fetches of the
+// type, its group, and its category, and all three inserts
+// (category → group → type) with correct field values.
 func TestResolveTypeIDs_NewTypeTriggersESIAndInserts(t *testing.T) {
 	const (
 		ownerID int64 = 42
 		typeID  int64 = 500
 	)
 
-	ut := esi.UniverseType{
-		TypeID:       typeID,
-		TypeName:     "Rifter",
-		GroupID:      25,
-		GroupName:    "Frigate",
-		CategoryID:   6,
-		CategoryName: "Ship",
-	}
+	ut := esi.UniverseType{TypeID: typeID, Name: "Rifter", GroupID: 25}
+	ug := esi.UniverseGroup{GroupID: 25, Name: "Frigate", CategoryID: 6, TypeIDs: []int64{typeID}}
+	uc := esi.UniverseCategory{CategoryID: 6, Name: "Ship"}
 
 	var insertedCategory store.InsertEveCategoryParams
 	var insertedGroup store.InsertEveGroupParams
@@ -60,39 +58,137 @@ func TestResolveTypeIDs_NewTypeTriggersESIAndInserts(t *testing.T) {
 			}
 			return ut, nil
 		},
+		getUniverseGroupFunc: func(_ context.Context, id int64) (esi.UniverseGroup, error) {
+			if id != ut.GroupID {
+				t.Errorf("GetUniverseGroup: unexpected groupID %d", id)
+			}
+			return ug, nil
+		},
+		getUniverseCatFunc: func(_ context.Context, id int64) (esi.UniverseCategory, error) {
+			if id != ug.CategoryID {
+				t.Errorf("GetUniverseCategory: unexpected categoryID %d", id)
+			}
+			return uc, nil
+		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.resolveTypeIDs(context.Background(), ownerTypeCharacter, ownerID)
 
-	// Verify category insert.
-	if insertedCategory.ID != ut.CategoryID {
-		t.Errorf("InsertEveCategory ID: got %d, want %d", insertedCategory.ID, ut.CategoryID)
+	if insertedCategory != (store.InsertEveCategoryParams{ID: 6, Name: "Ship"}) {
+		t.Errorf("InsertEveCategory: got %+v", insertedCategory)
+	}
+	if insertedGroup != (store.InsertEveGroupParams{ID: 25, CategoryID: 6, Name: "Frigate"}) {
+		t.Errorf("InsertEveGroup: got %+v", insertedGroup)
 	}
-	if insertedCategory.Name != ut.CategoryName {
-		t.Errorf("InsertEveCategory Name: got %q, want %q", insertedCategory.Name, ut.CategoryName)
+	if insertedType != (store.InsertEveTypeParams{ID: typeID, GroupID: 25, Name: "Rifter"}) {
+		t.Errorf("InsertEveType: got %+v", insertedType)
 	}
+}
+
+// --- TestResolveTypeIDs_BulkNamesAndGroupMembers ---
+// Verifies that the names of unknown types come from one POST /universe/names/
+// call and that types listed in an already fetched group need no
+// GET /universe/types/ call; the group and category are fetched once.
+func TestResolveTypeIDs_BulkNamesAndGroupMembers(t *testing.T) {
+	typeIDs := []int64{500, 501, 502}
 
-	// Verify group insert.
-	if insertedGroup.ID != ut.GroupID {
-		t.Errorf("InsertEveGroup ID: got %d, want %d", insertedGroup.ID, ut.GroupID)
+	var inserted []store.InsertEveTypeParams
+	q := &mockQuerier{
+		getEveTypeFunc: func(_ int64) (store.EveType, error) {
+			return store.EveType{}, sql.ErrNoRows
+		},
+		insertEveCategoryFunc: func(_ store.InsertEveCategoryParams) error { return nil },
+		insertEveGroupFunc:    func(_ store.InsertEveGroupParams) error { return nil },
+		insertEveTypeFunc: func(arg store.InsertEveTypeParams) error {
+			inserted = append(inserted, arg)
+			return nil
+		},
 	}
-	if insertedGroup.CategoryID != ut.CategoryID {
-		t.Errorf("InsertEveGroup CategoryID: got %d, want %d", insertedGroup.CategoryID, ut.CategoryID)
+
+	var namesCalls, typeCalls, groupCalls, categoryCalls int
+	groupStored := false
+	q.getEveGroupFunc = func(id int64) (store.EveGroup, error) {
+		if groupStored {
+			return store.EveGroup{ID: id}, nil
+		}
+		return store.EveGroup{}, sql.ErrNoRows
 	}
-	if insertedGroup.Name != ut.GroupName {
-		t.Errorf("InsertEveGroup Name: got %q, want %q", insertedGroup.Name, ut.GroupName)
+	q.insertEveGroupFunc = func(_ store.InsertEveGroupParams) error {
+		groupStored = true
+		return nil
 	}
+	esiMock := &mockESIClient{
+		postUniverseNamesFunc: func(_ context.Context, ids []int64) ([]esi.UniverseNamesEntry, error) {
+			namesCalls++
+			if !slices.Equal(ids, typeIDs) {
+				t.Errorf("PostUniverseNames: got %v, want %v", ids, typeIDs)
+			}
+			return []esi.UniverseNamesEntry{
+				{ID: 500, Name: "Rifter Blueprint", Category: "inventory_type"},
+				{ID: 501, Name: "Slasher Blueprint", Category: "inventory_type"},
+				{ID: 502, Name: "Breacher Blueprint", Category: "inventory_type"},
+			}, nil
+		},
+		getUniverseTypeFunc: func(_ context.Context, id int64) (esi.UniverseType, error) {
+			typeCalls++
+			return esi.UniverseType{TypeID: id, Name: "Rifter Blueprint", GroupID: 105}, nil
+		},
+		getUniverseGroupFunc: func(_ context.Context, id int64) (esi.UniverseGroup, error) {
+			groupCalls++
+			return esi.UniverseGroup{GroupID: id, Name: "Frigate Blueprint", CategoryID: 9, TypeIDs: []int64{500, 501, 502, 503}}, nil
+		},
+		getUniverseCatFunc: func(_ context.Context, id int64) (esi.UniverseCategory, error) {
+			categoryCalls++
+			return esi.UniverseCategory{CategoryID: id, Name: "Blueprint"}, nil
+		},
+	}
+
+	w := New(q, esiMock, nil, time.Minute)
+	w.resolveTypeIDsList(context.Background(), typeIDs)
 
-	// Verify type insert.
-	if insertedType.ID != typeID {
-		t.Errorf("InsertEveType ID: got %d, want %d", insertedType.ID, typeID)
+	if namesCalls != 1 || typeCalls != 1 || groupCalls != 1 || categoryCalls != 1 {
+		t.Errorf("ESI calls: names %d, types %d, groups %d, categories %d; want 1 each", namesCalls, typeCalls, groupCalls, categoryCalls)
 	}
-	if insertedType.GroupID != ut.GroupID {
-		t.Errorf("InsertEveType GroupID: got %d, want %d", insertedType.GroupID, ut.GroupID)
+	want := []store.InsertEveTypeParams{
+		{ID: 500, GroupID: 105, Name: "Rifter Blueprint"},
+		{ID: 501, GroupID: 105, Name: "Slasher Blueprint"},
+		{ID: 502, GroupID: 105, Name: "Breacher Blueprint"},
 	}
-	if insertedType.Name != ut.TypeName {
-		t.Errorf("InsertEveType Name: got %q, want %q", insertedType.Name, ut.TypeName)
+	if !slices.Equal(inserted, want) {
+		t.Errorf("inserted types: got %+v, want %+v", inserted, want)
+	}
+}
+
+// --- TestResolveTypeIDs_StoredGroupAndCategoryNotFetched ---
+// Verifies that a new type in a group already in eve_groups costs a single
+// GET /universe/types/ call.
+func TestResolveTypeIDs_StoredGroupAndCategoryNotFetched(t *testing.T) {
+	var inserted []store.InsertEveTypeParams
+	q := &mockQuerier{
+		getEveTypeFunc: func(_ int64) (store.EveType, error) {
+			return store.EveType{}, sql.ErrNoRows
+		},
+		getEveGroupFunc: func(id int64) (store.EveGroup, error) {
+			return store.EveGroup{ID: id, CategoryID: 9, Name: "Frigate Blueprint"}, nil
+		},
+		insertEveTypeFunc: func(arg store.InsertEveTypeParams) error {
+			inserted = append(inserted, arg)
+			return nil
+		},
+	}
+	// GetUniverseGroup and GetUniverseCategory panic if called.
+	esiMock := &mockESIClient{
+		getUniverseTypeFunc: func(_ context.Context, id int64) (esi.UniverseType, error) {
+			return esi.UniverseType{TypeID: id, Name: "Rifter Blueprint", GroupID: 105}, nil
+		},
+	}
+
+	w := New(q, esiMock, nil, time.Minute)
+	w.resolveTypeIDsList(context.Background(), []int64{500})
+
+	if len(inserted) != 1 || inserted[0] != (store.InsertEveTypeParams{ID: 500, GroupID: 105, Name: "Rifter Blueprint"}) {
+		t.Errorf("inserted types: got %+v", inserted)
 	}
 }
 
@@ -121,7 +217,7 @@ func TestResolveTypeIDs_KnownTypeSkipped(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.resolveTypeIDs(context.Background(), ownerTypeCharacter, 1)
 
 	if esiCalled {
@@ -158,11 +254,17 @@ func TestResolveTypeIDs_MultipleTypes_MixedKnownAndNew(t *testing.T) {
 	esiMock := &mockESIClient{
 		getUniverseTypeFunc: func(_ context.Context, id int64) (esi.UniverseType, error) {
 			esiCallIDs = append(esiCallIDs, id)
-			return esi.UniverseType{TypeID: id, TypeName: "New Ship", GroupID: 1, GroupName: "G", CategoryID: 1, CategoryName: "C"}, nil
+			return esi.UniverseType{TypeID: id, Name: "New Ship", GroupID: 1}, nil
+		},
+		getUniverseGroupFunc: func(_ context.Context, id int64) (esi.UniverseGroup, error) {
+			return esi.UniverseGroup{GroupID: id, Name: "G", CategoryID: 1}, nil
+		},
+		getUniverseCatFunc: func(_ context.Context, id int64) (esi.UniverseCategory, error) {
+			return esi.UniverseCategory{CategoryID: id, Name: "C"}, nil
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.resolveTypeIDs(context.Background(), ownerTypeCharacter, 1)
 
 	if len(esiCallIDs) != 1 {
@@ -200,7 +302,7 @@ func TestResolveTypeIDs_CalledAfterBlueprintSync(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.syncSubject(context.Background(), ownerTypeCharacter, charID, endpointBlueprints)
 
 	if !typeIDsResolved {
@@ -226,7 +328,7 @@ func TestResolveTypeIDs_NotCalledAfterBlueprintError(t *testing.T) {
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.syncSubject(context.Background(), ownerTypeCharacter, 1, endpointBlueprints)
 
 	if typeIDsResolved {
@@ -265,11 +367,17 @@ func TestResolveTypeIDs_ESIErrorSkipsType(t *testing.T) {
 			if id == badTypeID {
 				return esi.UniverseType{}, errors.New("ESI 404: type not found")
 			}
-			return esi.UniverseType{TypeID: id, TypeName: "Good Ship", GroupID: 1, GroupName: "G", CategoryID: 1, CategoryName: "C"}, nil
+			return esi.UniverseType{TypeID: id, Name: "Good Ship", GroupID: 1}, nil
+		},
+		getUniverseGroupFunc: func(_ context.Context, id int64) (esi.UniverseGroup, error) {
+			return esi.UniverseGroup{GroupID: id, Name: "G", CategoryID: 1}, nil
+		},
+		getUniverseCatFunc: func(_ context.Context, id int64) (esi.UniverseCategory, error) {
+			return esi.UniverseCategory{CategoryID: id, Name: "C"}, nil
 		},
 	}
 
-	w := New(q, esiMock, time.Minute)
+	w := New(q, esiMock, nil, time.Minute)
 	w.resolveTypeIDs(context.Background(), ownerTypeCharacter, 1)
 
 	if len(resolvedTypes) != 1 {
@@ -7,6 +7,7 @@
 //
 // Accepts a force-refresh signal via a channel from the api package.
 // After a successful sync, triggers lazy resolution of any new type_ids via esi.
+// Progress and the changes found are published on an events.Bus, if one is set.
 //
 // Note: this package is named "sync" matching the architecture. If stdlib sync is needed
 // inside this package, import it as: import stdsync "sync"
@@ -18,49 +19,120 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/dpleshakov/auspex/internal/auth"
 	"github.com/dpleshakov/auspex/internal/esi"
+	"github.com/dpleshakov/auspex/internal/events"
 	"github.com/dpleshakov/auspex/internal/store"
 )
 
 const (
+	endpointAssets       = "assets"
 	endpointCorpAssets   = "corp_assets"
+	endpointDivisions    = "divisions"
 	endpointBlueprints   = "blueprints"
 	endpointJobs         = "jobs"
+	endpointRoles        = "roles"
 	ownerTypeCharacter   = "character"
 	ownerTypeCorporation = "corporation"
 )
 
+// removalGrace is how long a pruned blueprint may wait for the other owners'
+// blueprints to be synced before its removal is reported. ESI caches
+// blueprints for an hour, so a blueprint moved to a tracked owner shows up
+// there within it.
+const removalGrace = 2 * time.Hour
+
+// blueprint_events.event values.
+const (
+	blueprintEventAdded        = "added"
+	blueprintEventRemoved      = "removed"
+	blueprintEventMEChanged    = "me_changed"
+	blueprintEventTEChanged    = "te_changed"
+	blueprintEventMoved        = "moved"
+	blueprintEventOwnerChanged = "owner_changed"
+)
+
 // Worker is the background sync worker.
 // It runs a ticker loop, checks ESI cache freshness per subject+endpoint,
 // and calls syncFn for subjects whose cache has expired.
 type Worker struct {
 	store           store.Querier
 	esi             esi.Client
+	tokens          auth.TokenRefresher // per-character tokens for structure lookups; may be nil
 	refreshInterval time.Duration
 	now             func() time.Time // injectable for testing; defaults to time.Now
 	force           chan struct{}    // signals an immediate full sync, ignoring cache_until
+	bus             *events.Bus      // receives cycle, subject, job, and blueprint events; may be nil
 
 	// syncFn is called when a subject needs syncing.
 	// Defaults to w.syncSubject (a no-op placeholder until TASK-10).
 	// Replace in tests to observe sync calls without executing real ESI fetches.
 	syncFn func(ctx context.Context, ownerType string, ownerID int64, endpoint string)
+
+	// affiliationFn refreshes character affiliations at the start of a cycle
+	// once affiliationsDue has passed. Defaults to w.refreshAffiliations.
+	// Replace in tests that exercise the scheduling loop only.
+	affiliationFn   func(ctx context.Context) error
+	affiliationsDue time.Time
+
+	// pendingLocations holds the station, structure, and solar system IDs
+	// queued by resolveLocationIDs during a cycle, with the first owner whose
+	// blueprints are there. resolvePendingLocations resolves them all at the
+	// end of the cycle. Only the cycle's goroutine touches it.
+	pendingLocations map[int64]locationOwner
+
+	// searchStale is set when data the search index is built from may have
+	// changed since it was last rebuilt: affiliations were refreshed, or an
+	// endpoint in searchEndpoints was synced. It starts out true, so that the
+	// first cycle indexes what changed while the server was stopped. Only the
+	// cycle's goroutine touches it.
+	searchStale bool
+}
+
+// searchEndpoints are the endpoints whose data appears in the search index:
+// blueprints, and the asset tree and hangar divisions that name their
+// locations.
+var searchEndpoints = []string{endpointBlueprints, endpointAssets, endpointCorpAssets, endpointDivisions}
+
+// locationOwner is an owner of blueprints in a pending location; its members
+// are tried first for player structure lookups.
+type locationOwner struct {
+	ownerType string
+	ownerID   int64
 }
 
 // New creates a Worker. interval is the ticker period (typically from config.RefreshInterval).
-func New(q store.Querier, esiClient esi.Client, interval time.Duration) *Worker {
+// tokens supplies the token of each character tried for player structure
+// lookups; with nil, structures are not resolved.
+func New(q store.Querier, esiClient esi.Client, tokens auth.TokenRefresher, interval time.Duration) *Worker {
 	w := &Worker{
 		store:           q,
 		esi:             esiClient,
+		tokens:          tokens,
 		refreshInterval: interval,
 		now:             time.Now,
 		force:           make(chan struct{}, 1),
+
+		pendingLocations: make(map[int64]locationOwner),
+		searchStale:      true,
 	}
 	w.syncFn = w.syncSubject
+	w.affiliationFn = w.refreshAffiliations
 	return w
 }
 
+// SetEventBus makes the worker publish its progress and the changes it finds
+// on bus. Call it before Run.
+func (w *Worker) SetEventBus(bus *events.Bus) {
+	w.bus = bus
+}
+
 // Run starts the background sync loop and blocks until ctx is canceled.
 // Intended to be called in a goroutine:
 //
@@ -98,9 +170,44 @@ func (w *Worker) ForceRefresh() {
 }
 
 // runCycle iterates all characters and corporations.
+// Character affiliations are refreshed first (at most every
+// affiliationInterval unless force is true), so corporation changes take
+// effect in the same cycle.
 // For each subject+endpoint pair it checks freshness (unless force is true)
 // and calls w.syncFn for subjects that need syncing.
+// Characters flagged needs_reauth or transferred are skipped: their refresh
+// token is known to be rejected by EVE SSO, or now belongs to another EVE
+// account. A corporation is synced while any of its members is not flagged,
+// the delegate or one that can stand in for it; corporations with no such
+// member, and orphaned ones (no delegate), are skipped.
+// Endpoints whose scope the character (or, for a corporation, every member not
+// flagged) has not granted are not fetched; they are recorded as missing_scope
+// in sync_state instead.
+// Corporation roles are synced only for members of tracked corporations:
+// they decide which member can stand in for a delegate that lacks them.
+// Removals of blueprints that may have moved between owners are settled at
+// the end, the locations of all synced blueprints are resolved together, and
+// the search index is then rebuilt from the stored data if it may be stale.
+// cycle_started and cycle_finished events bracket the cycle.
 func (w *Worker) runCycle(ctx context.Context, force bool) {
+	start := w.now()
+	w.bus.Publish(events.TypeCycleStarted, events.Cycle{Forced: force})
+	defer func() {
+		w.bus.Publish(events.TypeCycleFinished, events.Cycle{
+			Forced:     force,
+			DurationMS: w.now().Sub(start).Milliseconds(),
+		})
+	}()
+
+	if force || !w.now().Before(w.affiliationsDue) {
+		if err := w.affiliationFn(ctx); err != nil {
+			log.Printf("sync: refreshing affiliations: %v", err)
+		} else {
+			w.affiliationsDue = w.now().Add(affiliationInterval)
+			w.searchStale = true
+		}
+	}
+
 	chars, err := w.store.ListCharacters(ctx)
 	if err != nil {
 		log.Printf("sync: listing characters: %v", err)
@@ -113,11 +220,34 @@ func (w *Worker) runCycle(ctx context.Context, force bool) {
 		return
 	}
 
+	tracked := make(map[int64]bool, len(corps))
+	for _, corp := range corps {
+		tracked[corp.ID] = true
+	}
+
+	paused := make(map[int64]bool)
+	for _, char := range chars {
+		if char.NeedsReauth != 0 || char.Transferred != 0 {
+			paused[char.ID] = true
+		}
+	}
+
 	for _, char := range chars {
-		for _, endpoint := range []string{endpointBlueprints, endpointJobs} {
+		if paused[char.ID] {
+			continue
+		}
+		endpoints := []string{endpointAssets, endpointBlueprints, endpointJobs}
+		if tracked[char.CorporationID] {
+			endpoints = append(endpoints, endpointRoles)
+		}
+		for _, endpoint := range endpoints {
 			if ctx.Err() != nil {
 				return
 			}
+			if scope := requiredScope(ownerTypeCharacter, endpoint); !hasScope(char.Scopes, scope) {
+				w.recordMissingScope(ctx, ownerTypeCharacter, char.ID, endpoint, scope)
+				continue
+			}
 			if !force && w.isFresh(ctx, ownerTypeCharacter, char.ID, endpoint) {
 				continue
 			}
@@ -126,16 +256,108 @@ func (w *Worker) runCycle(ctx context.Context, force bool) {
 	}
 
 	for _, corp := range corps {
-		for _, endpoint := range []string{endpointCorpAssets, endpointBlueprints, endpointJobs} {
+		if !corp.DelegateID.Valid {
+			continue
+		}
+		members := activeMembers(corp.ID, corp.DelegateID.Int64, chars, paused)
+		if len(members) == 0 {
+			continue
+		}
+		for _, endpoint := range []string{endpointDivisions, endpointCorpAssets, endpointBlueprints, endpointJobs} {
 			if ctx.Err() != nil {
 				return
 			}
+			scope := requiredScope(ownerTypeCorporation, endpoint)
+			if !slices.ContainsFunc(members, func(m store.Character) bool { return hasScope(m.Scopes, scope) }) {
+				w.recordMissingScope(ctx, ownerTypeCorporation, corp.ID, endpoint, scope)
+				continue
+			}
 			if !force && w.isFresh(ctx, ownerTypeCorporation, corp.ID, endpoint) {
 				continue
 			}
 			w.syncFn(ctx, ownerTypeCorporation, corp.ID, endpoint)
 		}
 	}
+
+	w.settleRemovedBlueprints(ctx)
+	w.resolvePendingLocations(ctx)
+	w.rebuildSearchIndex(ctx)
+}
+
+// activeMembers returns the members of corporation corpID that are not
+// paused, its delegate first: the characters whose tokens can be used for its
+// endpoints (the auth client fails over from the delegate to the others).
+func activeMembers(corpID, delegateID int64, chars []store.Character, paused map[int64]bool) []store.Character {
+	// A delegate missing from chars is not known to be paused: it is
+	// attempted, with its scopes unknown.
+	delegate := store.Character{ID: delegateID, CorporationID: corpID}
+	var others []store.Character
+	for _, char := range chars {
+		switch {
+		case char.ID == delegateID:
+			delegate = char
+		case char.CorporationID == corpID:
+			others = append(others, char)
+		}
+	}
+	out := make([]store.Character, 0, len(others)+1)
+	for _, char := range append([]store.Character{delegate}, others...) {
+		if !paused[char.ID] {
+			out = append(out, char)
+		}
+	}
+	return out
+}
+
+// requiredScope returns the SSO scope needed to fetch endpoint for ownerType.
+func requiredScope(ownerType, endpoint string) string {
+	if ownerType == ownerTypeCorporation {
+		switch endpoint {
+		case endpointDivisions:
+			return esi.ScopeCorporationDivisions
+		case endpointCorpAssets:
+			return esi.ScopeCorporationAssets
+		case endpointBlueprints:
+			return esi.ScopeCorporationBlueprints
+		case endpointJobs:
+			return esi.ScopeCorporationJobs
+		}
+		return ""
+	}
+	switch endpoint {
+	case endpointAssets:
+		return esi.ScopeCharacterAssets
+	case endpointBlueprints:
+		return esi.ScopeCharacterBlueprints
+	case endpointJobs:
+		return esi.ScopeCharacterJobs
+	case endpointRoles:
+		return esi.ScopeCharacterRoles
+	}
+	return ""
+}
+
+// hasScope reports whether scope is in granted, a space-separated scope list
+// as stored in characters.scopes. An empty list means the scopes are unknown
+// (character added before they were recorded); the endpoint is then attempted.
+func hasScope(granted, scope string) bool {
+	if granted == "" || scope == "" {
+		return true
+	}
+	return slices.Contains(strings.Fields(granted), scope)
+}
+
+// recordMissingScope marks (ownerType, ownerID, endpoint) as skipped because
+// scope has not been granted. The next successful sync clears the mark.
+func (w *Worker) recordMissingScope(ctx context.Context, ownerType string, ownerID int64, endpoint, scope string) {
+	if err := w.store.UpdateSyncStateMissingScope(ctx, store.UpdateSyncStateMissingScopeParams{
+		OwnerType:    ownerType,
+		OwnerID:      ownerID,
+		Endpoint:     endpoint,
+		MissingScope: sql.NullString{String: scope, Valid: true},
+	}); err != nil {
+		log.Printf("sync: recording missing scope for %s %d %s: %v", ownerType, ownerID, endpoint, err)
+	}
 }
 
 // isFresh returns true when the ESI cache for (ownerType, ownerID, endpoint)
@@ -156,18 +378,31 @@ func (w *Worker) isFresh(ctx context.Context, ownerType string, ownerID int64, e
 
 // syncSubject fetches and stores ESI data for one (ownerType, ownerID, endpoint) tuple.
 // On ESI or store error the error is logged and sync_state is NOT updated,
-// so the next tick will retry the subject.
+// so the next tick will retry the subject. The outcome is published as a
+// subject_synced or subject_failed event.
 func (w *Worker) syncSubject(ctx context.Context, ownerType string, ownerID int64, endpoint string) {
 	var cacheUntil time.Time
 	var err error
 
 	switch endpoint {
+	case endpointAssets:
+		if ownerType != ownerTypeCharacter {
+			log.Printf("sync: assets endpoint requires character owner, got %s %d", ownerType, ownerID)
+			return
+		}
+		cacheUntil, err = w.syncAssets(ctx, ownerType, ownerID)
 	case endpointCorpAssets:
 		if ownerType != ownerTypeCorporation {
 			log.Printf("sync: corp_assets endpoint requires corporation owner, got %s %d", ownerType, ownerID)
 			return
 		}
-		cacheUntil, err = w.syncCorpAssets(ctx, ownerID)
+		cacheUntil, err = w.syncAssets(ctx, ownerType, ownerID)
+	case endpointDivisions:
+		if ownerType != ownerTypeCorporation {
+			log.Printf("sync: divisions endpoint requires corporation owner, got %s %d", ownerType, ownerID)
+			return
+		}
+		cacheUntil, err = w.syncDivisions(ctx, ownerID)
 	case endpointBlueprints:
 		cacheUntil, err = w.syncBlueprints(ctx, ownerType, ownerID)
 		if err == nil {
@@ -176,13 +411,22 @@ func (w *Worker) syncSubject(ctx context.Context, ownerType string, ownerID int6
 		}
 	case endpointJobs:
 		cacheUntil, err = w.syncJobs(ctx, ownerType, ownerID)
+	case endpointRoles:
+		if ownerType != ownerTypeCharacter {
+			log.Printf("sync: roles endpoint requires character owner, got %s %d", ownerType, ownerID)
+			return
+		}
+		cacheUntil, err = w.syncRoles(ctx, ownerID)
 	default:
 		log.Printf("sync: unknown endpoint %q for %s %d", endpoint, ownerType, ownerID)
 		return
 	}
 
+	subject := events.Subject{OwnerType: ownerType, OwnerID: ownerID, Endpoint: endpoint}
 	if err != nil {
 		log.Printf("sync: %s %s %d: %v", endpoint, ownerType, ownerID, err)
+		subject.Error = err.Error()
+		w.bus.Publish(events.TypeSubjectFailed, subject)
 		if uerr := w.store.UpdateSyncStateError(ctx, store.UpdateSyncStateErrorParams{
 			LastError: sql.NullString{String: err.Error(), Valid: true},
 			OwnerType: ownerType,
@@ -211,9 +455,20 @@ func (w *Worker) syncSubject(ctx context.Context, ownerType string, ownerID int6
 	}); err != nil {
 		log.Printf("sync: clearing error for %s %d %s: %v", ownerType, ownerID, endpoint, err)
 	}
+	if slices.Contains(searchEndpoints, endpoint) {
+		w.searchStale = true
+	}
+	w.bus.Publish(events.TypeSubjectSynced, subject)
 }
 
-// syncBlueprints fetches blueprints from ESI and upserts them into the store.
+// syncBlueprints fetches blueprints from ESI, upserts them into the store, and
+// deletes any blueprints that were previously stored for the owner but are no
+// longer in the ESI response (sold, destroyed, or moved to another owner).
+// Every difference against the stored library is recorded in blueprint_events.
+// The diff and the prune run in one transaction; blueprint_added is published
+// once it is committed. A pruned blueprint is kept in blueprint_removals, and
+// only reported removed once settleRemovedBlueprints has ruled out a move to
+// another tracked owner.
 // Returns the ESI cache expiry time on success.
 func (w *Worker) syncBlueprints(ctx context.Context, ownerType string, ownerID int64) (time.Time, error) {
 	var bps []esi.Blueprint
@@ -246,127 +501,503 @@ func (w *Worker) syncBlueprints(ctx context.Context, ownerType string, ownerID i
 	w.resolveTypeIDsList(ctx, typeIDs)
 
 	now := w.now()
-	for _, bp := range bps {
-		if err := w.store.UpsertBlueprint(ctx, store.UpsertBlueprintParams{
-			ID:           bp.ItemID,
-			OwnerType:    ownerType,
-			OwnerID:      ownerID,
-			TypeID:       bp.TypeID,
-			LocationID:   bp.LocationID,
-			LocationFlag: bp.LocationFlag,
-			MeLevel:      bp.MELevel,
-			TeLevel:      bp.TELevel,
-			UpdatedAt:    now,
-		}); err != nil {
-			// A blueprint whose type_id is missing from eve_types (e.g. because
-			// type resolution failed due to a transient ESI error) fails the FK
-			// constraint. Log and skip so the rest of the blueprints are stored
-			// and sync_state is still updated. The next tick will retry resolution.
-			log.Printf("sync: blueprints %s %d: upserting blueprint %d: %v", ownerType, ownerID, bp.ItemID, err)
-			continue
+	var added []events.Blueprint
+	err = w.inTx(ctx, func(q store.Querier) error {
+		added = nil
+
+		// Get blueprints currently in the store for this owner so we can diff and prune.
+		existing, err := q.ListBlueprintsByOwner(ctx, store.ListBlueprintsByOwnerParams{
+			OwnerType: ownerType,
+			OwnerID:   ownerID,
+		})
+		if err != nil {
+			return fmt.Errorf("listing existing blueprints: %w", err)
+		}
+		stored := make(map[int64]store.Blueprint, len(existing))
+		for _, b := range existing {
+			stored[b.ID] = b
 		}
+
+		// An owner with nothing stored yet is being imported for the first time.
+		// Logging its whole library as "added" would bury the real changes, so
+		// "added" events are suppressed for the initial import.
+		initialImport := len(existing) == 0
+
+		incoming := make(map[int64]bool, len(bps))
+		for _, bp := range bps {
+			incoming[bp.ItemID] = true
+
+			prev, known := stored[bp.ItemID]
+			if !known {
+				// Not stored for this owner — either a new blueprint or one that
+				// moved here from another tracked owner, which may already have
+				// pruned it.
+				if b, err := q.GetBlueprint(ctx, bp.ItemID); err == nil {
+					prev, known = b, true
+				} else if r, err := q.GetBlueprintRemoval(ctx, bp.ItemID); err == nil {
+					if err := q.DeleteBlueprintRemoval(ctx, bp.ItemID); err != nil {
+						return fmt.Errorf("settling removal of blueprint %d: %w", bp.ItemID, err)
+					}
+					prev, known = store.Blueprint{
+						ID:         r.BlueprintID,
+						OwnerType:  r.OwnerType,
+						OwnerID:    r.OwnerID,
+						TypeID:     r.TypeID,
+						LocationID: r.LocationID,
+						MeLevel:    r.MeLevel,
+						TeLevel:    r.TeLevel,
+					}, true
+				}
+			}
+
+			if err := q.UpsertBlueprint(ctx, store.UpsertBlueprintParams{
+				ID:           bp.ItemID,
+				OwnerType:    ownerType,
+				OwnerID:      ownerID,
+				TypeID:       bp.TypeID,
+				LocationID:   bp.LocationID,
+				LocationFlag: bp.LocationFlag,
+				MeLevel:      bp.MELevel,
+				TeLevel:      bp.TELevel,
+				UpdatedAt:    now,
+			}); err != nil {
+				// A blueprint whose type_id is missing from eve_types (e.g. because
+				// type resolution failed due to a transient ESI error) fails the FK
+				// constraint. Log and skip so the rest of the blueprints are stored
+				// and sync_state is still updated. The next tick will retry resolution.
+				log.Printf("sync: blueprints %s %d: upserting blueprint %d: %v", ownerType, ownerID, bp.ItemID, err)
+				continue
+			}
+
+			switch {
+			case known:
+				recordBlueprintChanges(ctx, q, ownerType, ownerID, prev, bp, now)
+			case !initialImport:
+				recordBlueprintEvent(ctx, q, bp.ItemID, ownerType, ownerID, bp.TypeID, blueprintEventAdded, "", "", now)
+				added = append(added, events.Blueprint{
+					BlueprintID: bp.ItemID, TypeID: bp.TypeID, OwnerType: ownerType, OwnerID: ownerID,
+				})
+			}
+		}
+
+		// Delete stale blueprints (in store but no longer in ESI response).
+		// Jobs reference blueprints via FK, so a stale blueprint's jobs go first.
+		for _, b := range existing {
+			if incoming[b.ID] {
+				continue
+			}
+			if err := q.DeleteJobsByBlueprintID(ctx, b.ID); err != nil {
+				return fmt.Errorf("deleting jobs of stale blueprint %d: %w", b.ID, err)
+			}
+			if err := q.DeleteBlueprintByID(ctx, b.ID); err != nil {
+				return fmt.Errorf("deleting stale blueprint %d: %w", b.ID, err)
+			}
+			if err := q.InsertBlueprintRemoval(ctx, store.InsertBlueprintRemovalParams{
+				BlueprintID: b.ID,
+				OwnerType:   b.OwnerType,
+				OwnerID:     b.OwnerID,
+				TypeID:      b.TypeID,
+				LocationID:  b.LocationID,
+				MeLevel:     b.MeLevel,
+				TeLevel:     b.TeLevel,
+				RemovedAt:   now.UTC(),
+			}); err != nil {
+				return fmt.Errorf("recording removal of blueprint %d: %w", b.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return cacheUntil, err
 	}
 
+	for _, b := range added {
+		w.bus.Publish(events.TypeBlueprintAdded, b)
+	}
+	w.settleRemovedBlueprints(ctx)
 	return cacheUntil, nil
 }
 
-// syncCorpAssets fetches all pages of corporation assets from ESI, retains only
-// OfficeFolder entries, and stores them in corp_assets after pruning stale rows.
-// Returns the ESI cache expiry from page 1.
-func (w *Worker) syncCorpAssets(ctx context.Context, corpID int64) (time.Time, error) {
-	assets, totalPages, cacheUntil, err := w.esi.GetCorporationAssets(ctx, corpID, "", 1)
+// settleRemovedBlueprints records and publishes the removal of the blueprints
+// in blueprint_removals once they cannot have moved to another tracked owner:
+// every other owner's blueprints have been synced since the removal, or
+// removalGrace has passed. A blueprint found stored again under another owner
+// moved there, and is not reported removed. The removals are kept in the
+// database so that a restart within removalGrace does not lose them.
+func (w *Worker) settleRemovedBlueprints(ctx context.Context) {
+	removals, err := w.store.ListBlueprintRemovals(ctx)
+	if err != nil {
+		log.Printf("sync: settling removed blueprints: %v", err)
+		return
+	}
+	if len(removals) == 0 {
+		return
+	}
+	owners, err := w.blueprintOwners(ctx)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("fetching corp assets page 1: %w", err)
+		log.Printf("sync: settling removed blueprints: %v", err)
+		return
 	}
 
-	var officeFolders []esi.CorpAsset
-	for _, a := range assets {
-		if a.LocationFlag == "OfficeFolder" {
-			officeFolders = append(officeFolders, a)
+	now := w.now()
+	for _, r := range removals {
+		if _, err := w.store.GetBlueprint(ctx, r.BlueprintID); err == nil {
+			if err := w.store.DeleteBlueprintRemoval(ctx, r.BlueprintID); err != nil {
+				log.Printf("sync: settling removal of blueprint %d: %v", r.BlueprintID, err)
+			}
+			continue
+		}
+		if now.Sub(r.RemovedAt) < removalGrace && !w.syncedSince(ctx, owners, r) {
+			continue
+		}
+		err := w.inTx(ctx, func(q store.Querier) error {
+			recordBlueprintEvent(ctx, q, r.BlueprintID, r.OwnerType, r.OwnerID, r.TypeID, blueprintEventRemoved, "", "", r.RemovedAt)
+			return q.DeleteBlueprintRemoval(ctx, r.BlueprintID)
+		})
+		if err != nil {
+			log.Printf("sync: settling removal of blueprint %d: %v", r.BlueprintID, err)
+			continue
+		}
+		w.bus.Publish(events.TypeBlueprintRemoved, events.Blueprint{
+			BlueprintID: r.BlueprintID, TypeID: r.TypeID, OwnerType: r.OwnerType, OwnerID: r.OwnerID,
+		})
+	}
+}
+
+// blueprintOwners returns every tracked character and corporation.
+func (w *Worker) blueprintOwners(ctx context.Context) ([]locationOwner, error) {
+	chars, err := w.store.ListCharacters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing characters: %w", err)
+	}
+	corps, err := w.store.ListCorporations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing corporations: %w", err)
+	}
+	owners := make([]locationOwner, 0, len(chars)+len(corps))
+	for _, c := range chars {
+		owners = append(owners, locationOwner{ownerType: ownerTypeCharacter, ownerID: c.ID})
+	}
+	for _, c := range corps {
+		owners = append(owners, locationOwner{ownerType: ownerTypeCorporation, ownerID: c.ID})
+	}
+	return owners, nil
+}
+
+// syncedSince reports whether the blueprints of every owner other than the
+// one r was removed from have been synced since its removal.
+func (w *Worker) syncedSince(ctx context.Context, owners []locationOwner, r store.BlueprintRemoval) bool {
+	for _, o := range owners {
+		if o.ownerType == r.OwnerType && o.ownerID == r.OwnerID {
+			continue
 		}
+		state, err := w.store.GetSyncState(ctx, store.GetSyncStateParams{
+			OwnerType: o.ownerType,
+			OwnerID:   o.ownerID,
+			Endpoint:  endpointBlueprints,
+		})
+		if err != nil || state.LastSync.Before(r.RemovedAt) {
+			return false
+		}
+	}
+	return true
+}
+
+// inTx runs fn in a transaction when the store supports them (see
+// store.Transactor), and on w.store directly otherwise.
+func (w *Worker) inTx(ctx context.Context, fn func(store.Querier) error) error {
+	if t, ok := w.store.(store.Transactor); ok {
+		return t.InTx(ctx, fn)
+	}
+	return fn(w.store)
+}
+
+// recordBlueprintChanges compares a stored blueprint with its fresh ESI state and
+// records one blueprint_events row per changed attribute (owner, ME, TE, location).
+func recordBlueprintChanges(ctx context.Context, q store.Querier, ownerType string, ownerID int64, prev store.Blueprint, bp esi.Blueprint, now time.Time) {
+	if prev.OwnerType != ownerType || prev.OwnerID != ownerID {
+		recordBlueprintEvent(ctx, q, bp.ItemID, ownerType, ownerID, bp.TypeID, blueprintEventOwnerChanged,
+			fmt.Sprintf("%s:%d", prev.OwnerType, prev.OwnerID),
+			fmt.Sprintf("%s:%d", ownerType, ownerID), now)
+	}
+	if prev.MeLevel != bp.MELevel {
+		recordBlueprintEvent(ctx, q, bp.ItemID, ownerType, ownerID, bp.TypeID, blueprintEventMEChanged,
+			strconv.FormatInt(prev.MeLevel, 10), strconv.FormatInt(bp.MELevel, 10), now)
+	}
+	if prev.TeLevel != bp.TELevel {
+		recordBlueprintEvent(ctx, q, bp.ItemID, ownerType, ownerID, bp.TypeID, blueprintEventTEChanged,
+			strconv.FormatInt(prev.TeLevel, 10), strconv.FormatInt(bp.TELevel, 10), now)
 	}
+	if prev.LocationID != bp.LocationID {
+		recordBlueprintEvent(ctx, q, bp.ItemID, ownerType, ownerID, bp.TypeID, blueprintEventMoved,
+			strconv.FormatInt(prev.LocationID, 10), strconv.FormatInt(bp.LocationID, 10), now)
+	}
+}
+
+// recordBlueprintEvent appends one row to blueprint_events. Empty oldValue or
+// newValue are stored as NULL. Errors are logged and otherwise ignored: the
+// change log is an audit aid and must never fail the blueprint sync itself.
+func recordBlueprintEvent(ctx context.Context, q store.Querier, blueprintID int64, ownerType string, ownerID, typeID int64, event, oldValue, newValue string, now time.Time) {
+	if err := q.InsertBlueprintEvent(ctx, store.InsertBlueprintEventParams{
+		BlueprintID: blueprintID,
+		OwnerType:   ownerType,
+		OwnerID:     ownerID,
+		TypeID:      typeID,
+		Event:       event,
+		OldValue:    sql.NullString{String: oldValue, Valid: oldValue != ""},
+		NewValue:    sql.NullString{String: newValue, Valid: newValue != ""},
+		CreatedAt:   now.UTC(),
+	}); err != nil {
+		log.Printf("sync: recording %s event for blueprint %d: %v", event, blueprintID, err)
+	}
+}
 
+// syncAssets fetches all pages of a character's or corporation's assets from
+// ESI and stores its asset tree — the assets holding other assets: corporation
+// offices, containers, and ships — in assets after pruning stale rows.
+// Player-given names of containers and ships are stored along with them, and
+// their types are resolved so that location paths can name them.
+// Returns the ESI cache expiry from page 1.
+func (w *Worker) syncAssets(ctx context.Context, ownerType string, ownerID int64) (time.Time, error) {
+	getPage := w.esi.GetCorporationAssets
+	postNames := w.esi.PostCorporationAssetNames
+	if ownerType == ownerTypeCharacter {
+		getPage = w.esi.GetCharacterAssets
+		postNames = w.esi.PostCharacterAssetNames
+	}
+
+	assets, totalPages, cacheUntil, err := getPage(ctx, ownerID, "", 1)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fetching assets page 1: %w", err)
+	}
 	for page := 2; page <= totalPages; page++ {
 		if ctx.Err() != nil {
 			return cacheUntil, ctx.Err()
 		}
-		pageAssets, _, _, err := w.esi.GetCorporationAssets(ctx, corpID, "", page)
+		pageAssets, _, _, err := getPage(ctx, ownerID, "", page)
+		if err != nil {
+			return cacheUntil, fmt.Errorf("fetching assets page %d: %w", page, err)
+		}
+		assets = append(assets, pageAssets...)
+	}
+
+	tree := assetTree(assets)
+
+	var named []int64
+	for _, a := range tree {
+		if a.IsSingleton && a.LocationFlag != officeFolderFlag {
+			named = append(named, a.ItemID)
+		}
+	}
+	names := make(map[int64]sql.NullString, len(named))
+	if len(named) > 0 {
+		entries, err := postNames(ctx, ownerID, "", named)
 		if err != nil {
-			return cacheUntil, fmt.Errorf("fetching corp assets page %d: %w", page, err)
+			// Names are cosmetic: store the tree unnamed rather than fail the sync.
+			log.Printf("sync: fetching asset names for %s %d: %v", ownerType, ownerID, err)
 		}
-		for _, a := range pageAssets {
-			if a.LocationFlag == "OfficeFolder" {
-				officeFolders = append(officeFolders, a)
+		for _, e := range entries {
+			// ESI reports an item that was never named as "None".
+			if e.Name != "" && e.Name != "None" {
+				names[e.ItemID] = sql.NullString{String: e.Name, Valid: true}
 			}
 		}
 	}
 
-	if err := w.store.DeleteCorpAssetsByOwner(ctx, corpID); err != nil {
-		return cacheUntil, fmt.Errorf("deleting stale corp assets: %w", err)
+	if err := w.store.DeleteAssetsByOwner(ctx, store.DeleteAssetsByOwnerParams{
+		OwnerType: ownerType,
+		OwnerID:   ownerID,
+	}); err != nil {
+		return cacheUntil, fmt.Errorf("deleting stale assets: %w", err)
 	}
-	for _, a := range officeFolders {
-		if err := w.store.UpsertCorpAsset(ctx, store.UpsertCorpAssetParams{
+	var typeIDs []int64
+	for _, a := range tree {
+		if err := w.store.UpsertAsset(ctx, store.UpsertAssetParams{
 			ItemID:       a.ItemID,
-			OwnerID:      corpID,
+			OwnerType:    ownerType,
+			OwnerID:      ownerID,
 			LocationID:   a.LocationID,
+			LocationFlag: a.LocationFlag,
 			LocationType: a.LocationType,
+			TypeID:       a.TypeID,
+			Name:         names[a.ItemID],
 		}); err != nil {
-			log.Printf("sync: corp %d: upserting corp asset %d: %v", corpID, a.ItemID, err)
+			log.Printf("sync: %s %d: upserting asset %d: %v", ownerType, ownerID, a.ItemID, err)
+		}
+		if a.LocationFlag != officeFolderFlag && !slices.Contains(typeIDs, a.TypeID) {
+			typeIDs = append(typeIDs, a.TypeID)
 		}
 	}
+	w.resolveTypeIDsList(ctx, typeIDs)
 
 	return cacheUntil, nil
 }
 
+// assetTree returns the assets that hold at least one other asset, and every
+// corporation office whether or not anything is in it. Other assets, including
+// the blueprints themselves, are not needed to follow a location up the tree.
+func assetTree(assets []esi.Asset) []esi.Asset {
+	parents := make(map[int64]bool)
+	for _, a := range assets {
+		if a.LocationType == "item" {
+			parents[a.LocationID] = true
+		}
+	}
+	var tree []esi.Asset
+	for _, a := range assets {
+		if parents[a.ItemID] || a.LocationFlag == officeFolderFlag {
+			tree = append(tree, a)
+		}
+	}
+	return tree
+}
+
+// syncDivisions fetches the hangar division names of a corporation and
+// replaces its rows in corp_divisions. Returns the ESI cache expiry.
+func (w *Worker) syncDivisions(ctx context.Context, corpID int64) (time.Time, error) {
+	divisions, cacheUntil, err := w.esi.GetCorporationDivisions(ctx, corpID, "")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fetching divisions: %w", err)
+	}
+	if err := w.store.DeleteCorpDivisions(ctx, corpID); err != nil {
+		return cacheUntil, fmt.Errorf("deleting stale divisions: %w", err)
+	}
+	for _, d := range divisions {
+		if err := w.store.UpsertCorpDivision(ctx, store.UpsertCorpDivisionParams{
+			CorporationID: corpID,
+			Division:      d.Division,
+			Name:          d.Name,
+		}); err != nil {
+			log.Printf("sync: corp %d: upserting division %d: %v", corpID, d.Division, err)
+		}
+	}
+	return cacheUntil, nil
+}
+
 // resolveTypeIDsList ensures that every type_id in the provided slice has a
 // corresponding row in eve_types, eve_groups, and eve_categories.
-// Unknown type_ids are fetched from ESI and inserted in FK order:
-// category → group → type. Known type_ids are skipped.
+//
+// The names of all unknown type_ids are resolved at once via
+// POST /universe/names/. The group of a type is taken from the type list of a
+// group fetched earlier in the same call, or else from GET /universe/types/{id};
+// groups and categories are fetched only if they are not stored yet. Rows are
+// inserted in FK order: category → group → type.
 // Errors per type_id are logged and skipped; they are non-fatal so that a
 // single bad type_id does not block resolution of the rest.
 func (w *Worker) resolveTypeIDsList(ctx context.Context, typeIDs []int64) {
+	seen := make(map[int64]bool, len(typeIDs))
+	var unknown []int64
 	for _, typeID := range typeIDs {
+		if seen[typeID] {
+			continue
+		}
+		seen[typeID] = true
+		// Skip if already in eve_types — no ESI call needed.
+		if _, err := w.store.GetEveType(ctx, typeID); err != nil {
+			unknown = append(unknown, typeID)
+		}
+	}
+
+	names := w.universeNames(ctx, unknown, universeNameCategoryType)
+	groupOf := make(map[int64]int64) // type_id → group_id, from the groups fetched below
+	for _, typeID := range unknown {
 		if ctx.Err() != nil {
 			return
 		}
 
-		// Skip if already in eve_types — no ESI call needed.
-		if _, err := w.store.GetEveType(ctx, typeID); err == nil {
-			continue
+		groupID, inGroup := groupOf[typeID]
+		name, named := names[typeID]
+		if !inGroup || !named {
+			ut, err := w.esi.GetUniverseType(ctx, typeID)
+			if err != nil {
+				log.Printf("sync: fetching universe type %d: %v", typeID, err)
+				continue
+			}
+			groupID, name = ut.GroupID, ut.Name
 		}
 
-		ut, err := w.esi.GetUniverseType(ctx, typeID)
+		members, err := w.ensureGroup(ctx, groupID)
 		if err != nil {
-			log.Printf("sync: fetching universe type %d: %v", typeID, err)
+			log.Printf("sync: resolving group %d of type %d: %v", groupID, typeID, err)
 			continue
 		}
-
-		// Insert in FK order: category → group → type.
-		if err := w.store.InsertEveCategory(ctx, store.InsertEveCategoryParams{
-			ID:   ut.CategoryID,
-			Name: ut.CategoryName,
-		}); err != nil {
-			log.Printf("sync: inserting eve_category %d: %v", ut.CategoryID, err)
-			continue
-		}
-		if err := w.store.InsertEveGroup(ctx, store.InsertEveGroupParams{
-			ID:         ut.GroupID,
-			CategoryID: ut.CategoryID,
-			Name:       ut.GroupName,
-		}); err != nil {
-			log.Printf("sync: inserting eve_group %d: %v", ut.GroupID, err)
-			continue
+		for _, id := range members {
+			groupOf[id] = groupID
 		}
+
 		if err := w.store.InsertEveType(ctx, store.InsertEveTypeParams{
 			ID:      typeID,
-			GroupID: ut.GroupID,
-			Name:    ut.TypeName,
+			GroupID: groupID,
+			Name:    name,
 		}); err != nil {
 			log.Printf("sync: inserting eve_type %d: %v", typeID, err)
 		}
 	}
 }
 
+// ensureGroup makes sure groupID and its category are stored in eve_groups and
+// eve_categories, fetching from ESI only what is missing. Returns the type_ids
+// of the group if it was fetched, nil if it was already stored.
+func (w *Worker) ensureGroup(ctx context.Context, groupID int64) ([]int64, error) {
+	if _, err := w.store.GetEveGroup(ctx, groupID); err == nil {
+		return nil, nil
+	}
+	group, err := w.esi.GetUniverseGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.store.GetEveCategory(ctx, group.CategoryID); err != nil {
+		category, err := w.esi.GetUniverseCategory(ctx, group.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		if err := w.store.InsertEveCategory(ctx, store.InsertEveCategoryParams{
+			ID:   category.CategoryID,
+			Name: category.Name,
+		}); err != nil {
+			return nil, fmt.Errorf("inserting eve_category %d: %w", category.CategoryID, err)
+		}
+	}
+	if err := w.store.InsertEveGroup(ctx, store.InsertEveGroupParams{
+		ID:         groupID,
+		CategoryID: group.CategoryID,
+		Name:       group.Name,
+	}); err != nil {
+		return nil, fmt.Errorf("inserting eve_group %d: %w", groupID, err)
+	}
+	return group.TypeIDs, nil
+}
+
+// POST /universe/names/ categories of the IDs resolved by the sync worker.
+const (
+	universeNameCategoryType        = "inventory_type"
+	universeNameCategoryStation     = "station"
+	universeNameCategorySolarSystem = "solar_system"
+)
+
+// universeNames resolves ids to names with as few POST /universe/names/ calls
+// as possible (ESI takes 1000 IDs per call), keeping only the entries of the
+// given categories. IDs ESI does not know are left out. Names are an
+// optimization only: on failure the error is logged and an empty map returned.
+func (w *Worker) universeNames(ctx context.Context, ids []int64, categories ...string) map[int64]string {
+	names := make(map[int64]string)
+	if len(ids) == 0 {
+		return names
+	}
+	entries, err := w.esi.PostUniverseNames(ctx, ids)
+	if err != nil {
+		log.Printf("sync: resolving %d universe names: %v", len(ids), err)
+		return names
+	}
+	for _, e := range entries {
+		if slices.Contains(categories, e.Category) {
+			names[e.ID] = e.Name
+		}
+	}
+	return names
+}
+
 const (
 	// npcStationMin and npcStationMax define the inclusive-exclusive range of
 	// resolvable NPC station IDs recognized by POST /universe/names/.
@@ -374,32 +1005,62 @@ const (
 	npcStationMin = int64(60_000_000)
 	npcStationMax = int64(64_000_000)
 
-	// corpHangarSentinel is the display name stored for corporation office/hangar
-	// item IDs (range [64_000_000, 1_000_000_000_000)) that cannot be resolved
-	// via /universe/names/ and have no dedicated ESI lookup.
-	corpHangarSentinel = "Corporation Hangar"
+	// solarSystemMin and solarSystemMax bound the IDs of solar systems, the
+	// root of the asset tree for items in space.
+	solarSystemMin = int64(30_000_000)
+	solarSystemMax = int64(33_000_000)
+
+	// unknownLocationSentinel is the display name stored for a station or
+	// structure ID that ESI does not know.
+	unknownLocationSentinel = "Unknown location"
 )
 
-// corpHangarFlags are location_flag values that indicate a blueprint is stored
-// in a corporation office slot within an NPC station. The location_id in these
-// cases is the office item ID, which is not resolvable via any universe endpoint.
-var corpHangarFlags = map[string]bool{
-	"CorpSAG1": true, "CorpSAG2": true, "CorpSAG3": true,
-	"CorpSAG4": true, "CorpSAG5": true, "CorpSAG6": true, "CorpSAG7": true,
-	"CorpDeliveries": true,
-}
+// Asset location flags that location paths are built from.
+const (
+	officeFolderFlag       = "OfficeFolder"   // a corporation office in a station or structure
+	corpDeliveriesFlag     = "CorpDeliveries" // the deliveries hangar of a corporation office
+	corpDivisionFlagPrefix = "CorpSAG"        // CorpSAG1–CorpSAG7: a hangar division of a corporation office
+)
 
-// resolveLocationIDs resolves location_ids for all blueprints owned by ownerType/ownerID
-// and populates eve_locations with human-readable names.
-//
-// For corp blueprint flags (CorpSAG*, CorpDeliveries) the location_id is an office item ID;
-// the real station/structure is looked up in corp_assets. If the asset is not yet synced,
-// the sentinel "Corporation Hangar" is stored and will be replaced on the next cycle.
+// locationPathSeparator separates the station and the divisions and containers
+// in a location path: "Jita IV - Moon 4 › Corp Hangar 3 › Station Container".
+const locationPathSeparator = " \u203a "
+
+// maxAssetDepth bounds the walk up the asset tree. A blueprint is at most in a
+// container in a ship in a division of an office; the bound only guards
+// against a cycle in inconsistent data.
+const maxAssetDepth = 8
+
+// locationTTL is how long a resolved location or solar system is used before it
+// is resolved again. sentinelTTL is the shorter period after which an ID stored
+// as unknownLocationSentinel is retried.
+const (
+	locationTTL = 7 * 24 * time.Hour
+	sentinelTTL = 24 * time.Hour
+)
+
+// structure_access.status values.
+const (
+	structureAccessGranted   = "granted"
+	structureAccessForbidden = "forbidden"
+)
+
+// structureForbiddenCooldown is how long a character refused access to a
+// structure is skipped for it. Docking rights rarely change, so a forbidden
+// pair is retried about once a day rather than on every cycle.
+const structureForbiddenCooldown = 24 * time.Hour
+
+// resolveLocationIDs resolves the location of every blueprint owned by
+// ownerType/ownerID: the station or structure it is in, and the path of
+// corporation hangar division and containers below it, stored in
+// blueprint_locations. Stations and structures that are not cached in
+// eve_locations yet, or have gone stale (see locationFresh), are queued for
+// resolvePendingLocations.
 //
-// For direct location_id entries ("Hangar" flag, character blueprints):
-// NPC stations (60 000 000–64 000 000) are resolved via GetStation;
-// player structures (all other IDs) via GetUniverseStructure.
-// Already-cached IDs are skipped. 403 responses for structures are not cached.
+// The location_id of a blueprint in a corporation office or a container is an
+// item ID, followed up the asset tree stored by syncAssets (see locationPath).
+// If the item is not in the tree yet, the blueprint is left unresolved and
+// retried on the next cycle.
 func (w *Worker) resolveLocationIDs(ctx context.Context, ownerType string, ownerID int64) {
 	rows, err := w.store.ListBlueprintLocationsByOwner(ctx, store.ListBlueprintLocationsByOwnerParams{
 		OwnerType: ownerType,
@@ -410,186 +1071,447 @@ func (w *Worker) resolveLocationIDs(ctx context.Context, ownerType string, owner
 		return
 	}
 
-	// Lazily fetch a character token only when needed for structure lookups.
-	var token string
-	getToken := func() string {
-		if token == "" {
-			token = w.anyCharacterToken(ctx)
-		}
-		return token
-	}
-
 	now := w.now()
 	for _, row := range rows {
 		if ctx.Err() != nil {
 			return
 		}
-		if corpHangarFlags[row.LocationFlag] {
-			w.resolveCorpHangarLocation(ctx, row.LocationID, now, getToken)
+		rootID, path, ok := w.locationPath(ctx, row.LocationID, row.LocationFlag)
+		if !ok {
+			// The asset tree is not synced yet — clear any outdated location
+			// and retry next cycle.
+			if err := w.store.DeleteBlueprintLocations(ctx, store.DeleteBlueprintLocationsParams{
+				OwnerType:    ownerType,
+				OwnerID:      ownerID,
+				LocationID:   row.LocationID,
+				LocationFlag: row.LocationFlag,
+			}); err != nil {
+				log.Printf("sync: clearing location %d of %s %d blueprints: %v", row.LocationID, ownerType, ownerID, err)
+			}
+			continue
+		}
+		if err := w.store.UpsertBlueprintLocations(ctx, store.UpsertBlueprintLocationsParams{
+			RootLocationID: rootID,
+			Path:           path,
+			OwnerType:      ownerType,
+			OwnerID:        ownerID,
+			LocationID:     row.LocationID,
+			LocationFlag:   row.LocationFlag,
+		}); err != nil {
+			log.Printf("sync: storing location %d of %s %d blueprints: %v", row.LocationID, ownerType, ownerID, err)
+		}
+
+		if _, queued := w.pendingLocations[rootID]; queued {
 			continue
 		}
-		// Direct location_id ("Hangar" or other flag with a real station/structure ID).
-		if _, err := w.store.GetLocation(ctx, row.LocationID); err == nil {
-			continue // already cached
+		if loc, err := w.store.GetLocation(ctx, rootID); err == nil && locationFresh(loc, now) {
+			continue
 		}
-		w.resolveDirectLocation(ctx, row.LocationID, now, getToken)
+		w.pendingLocations[rootID] = locationOwner{ownerType: ownerType, ownerID: ownerID}
 	}
 }
 
-// resolveCorpHangarLocation resolves a corp blueprint office item ID to a human-readable name.
-// It looks up the real station/structure ID from corp_assets, then fetches the name.
-// If the asset is not found (not yet synced), stores corpHangarSentinel.
-func (w *Worker) resolveCorpHangarLocation(ctx context.Context, itemID int64, now time.Time, getToken func() string) {
-	if _, err := w.store.GetLocation(ctx, itemID); err == nil {
-		return // already cached
-	}
+// resolvePendingLocations resolves every location queued by resolveLocationIDs
+// since the last call, across all owners, and stores it in eve_locations.
+//
+// The names of the NPC stations and solar systems among them are first
+// resolved together via POST /universe/names/. A station cached before keeps
+// its solar system, since stations never move, and takes the bulk-resolved
+// name; only stations seen for the first time, or whose name did not resolve,
+// are fetched on their own via GetStation, the one ESI route that gives a
+// station's system. Player structures (all other IDs) are always looked up on
+// their own via GetUniverseStructure, trying the token of each character in
+// structureCandidates order for the location's owner (see fetchStructure). A
+// lookup that fails leaves the other locations unaffected; a location never
+// cached before is then stored under its bulk-resolved name without a solar
+// system, so that it is shown and retried on the next cycle. A stale entry that cannot be resolved again keeps
+// its cached name; structures no character can access are not cached.
+func (w *Worker) resolvePendingLocations(ctx context.Context) {
+	pending := w.pendingLocations
+	w.pendingLocations = make(map[int64]locationOwner)
 
-	asset, err := w.store.GetCorpAsset(ctx, itemID)
-	if err != nil {
-		// Corp assets not yet synced for this office item — skip and retry next cycle.
-		// Do not store a sentinel: it would be cached in eve_locations and block future resolution.
-		return
+	ids := slices.Sorted(maps.Keys(pending))
+	var named []int64
+	for _, id := range ids {
+		if (id >= npcStationMin && id < npcStationMax) || (id >= solarSystemMin && id < solarSystemMax) {
+			named = append(named, id)
+		}
 	}
+	names := w.universeNames(ctx, named, universeNameCategoryStation, universeNameCategorySolarSystem)
 
-	realID := asset.LocationID
-	var name string
-	switch {
-	case realID >= npcStationMin && realID < npcStationMax:
-		n, err := w.esi.GetStation(ctx, realID)
-		if err != nil {
-			log.Printf("sync: resolving NPC station %d for corp hangar %d: %v", realID, itemID, err)
+	// Lazily list candidate characters per owner, only when needed for
+	// structure lookups.
+	candidates := make(map[locationOwner][]int64)
+	now := w.now()
+	for _, id := range ids {
+		if ctx.Err() != nil {
 			return
 		}
-		name = n
-	default: // player structure
-		tok := getToken()
-		if tok == "" {
-			log.Printf("sync: no character token for structure %d (corp hangar %d)", realID, itemID)
-			return
+		owner := pending[id]
+		getCandidates := func() []int64 {
+			c, ok := candidates[owner]
+			if !ok {
+				c = w.structureCandidates(ctx, owner.ownerType, owner.ownerID)
+				candidates[owner] = c
+			}
+			return c
 		}
-		structure, err := w.esi.GetUniverseStructure(ctx, realID, tok)
-		if errors.Is(err, esi.ErrForbidden) {
-			log.Printf("sync: structure %d: access denied, skipping cache", realID)
-			return
+		w.storeLocation(ctx, id, now, names[id], getCandidates)
+	}
+}
+
+// rebuildSearchIndex replaces the contents of search_index with the current
+// blueprints, characters, corporations, and blueprint locations, if any of
+// them may have changed during the cycle (see Worker.searchStale). The index
+// is cleared and repopulated in one transaction, so /api/search never sees it
+// partly built; if a step fails, the old index is kept and the rebuild is
+// retried next cycle. Entries whose row was deleted since are filtered out by
+// the search query itself.
+func (w *Worker) rebuildSearchIndex(ctx context.Context) {
+	if ctx.Err() != nil || !w.searchStale {
+		return
+	}
+	err := w.inTx(ctx, func(q store.Querier) error {
+		if err := q.ClearSearchIndex(ctx); err != nil {
+			return fmt.Errorf("clearing search index: %w", err)
 		}
-		if errors.Is(err, esi.ErrNotFound) {
-			log.Printf("sync: structure %d: not found in ESI, storing sentinel", realID)
-			_ = w.store.InsertLocation(ctx, store.InsertLocationParams{
-				ID:         itemID,
-				Name:       corpHangarSentinel,
-				ResolvedAt: now,
-			})
-			return
+		for _, step := range []struct {
+			what  string
+			index func(context.Context) error
+		}{
+			{"blueprints", q.IndexBlueprints},
+			{"characters", q.IndexCharacters},
+			{"corporations", q.IndexCorporations},
+			{"locations", q.IndexLocations},
+		} {
+			if err := step.index(ctx); err != nil {
+				return fmt.Errorf("indexing %s for search: %w", step.what, err)
+			}
 		}
-		if err != nil {
-			log.Printf("sync: fetching structure %d for corp hangar %d: %v", realID, itemID, err)
-			return
+		return nil
+	})
+	if err != nil {
+		log.Printf("sync: rebuilding search index: %v", err)
+		return
+	}
+	w.searchStale = false
+}
+
+// locationPath follows a blueprint's location_id and location_flag up the
+// asset tree. It returns the station, structure, or solar system at the root
+// and the path below it — hangar division and containers, outermost first,
+// joined by locationPathSeparator; empty for a blueprint directly in a hangar.
+// ok is false if the location is an item not in the asset tree yet, or if the
+// tree cannot be read.
+func (w *Worker) locationPath(ctx context.Context, locationID int64, flag string) (rootID int64, path string, ok bool) {
+	var segments []string // innermost first
+	id := locationID
+	for range maxAssetDepth {
+		asset, err := w.store.GetAsset(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Not an item we know of. In a corporation hangar it is an office
+			// whose assets are not synced yet; otherwise a station or structure.
+			if flag == corpDeliveriesFlag || strings.HasPrefix(flag, corpDivisionFlagPrefix) {
+				return 0, "", false
+			}
+			return id, joinLocationPath(segments), true
 		}
-		sysName, err := w.getSystemName(ctx, structure.SolarSystemID)
 		if err != nil {
-			log.Printf("sync: fetching system %d for structure %d: %v", structure.SolarSystemID, realID, err)
-			return
+			log.Printf("sync: looking up asset %d: %v", id, err)
+			return 0, "", false
 		}
-		name = sysName + " \u2014 " + structure.Name
+		if asset.LocationFlag == officeFolderFlag {
+			segments = append(segments, w.divisionLabel(ctx, asset.OwnerID, flag))
+			return asset.LocationID, joinLocationPath(segments), true
+		}
+		segments = append(segments, w.assetLabel(ctx, asset))
+		id, flag = asset.LocationID, asset.LocationFlag
 	}
+	log.Printf("sync: location %d: asset tree deeper than %d levels", locationID, maxAssetDepth)
+	return 0, "", false
+}
 
-	if err := w.store.InsertLocation(ctx, store.InsertLocationParams{
-		ID:         itemID,
-		Name:       name,
-		ResolvedAt: now,
-	}); err != nil {
-		log.Printf("sync: inserting corp hangar location %d: %v", itemID, err)
+// joinLocationPath joins path segments collected innermost first into a path
+// outermost first, leaving out empty segments.
+func joinLocationPath(segments []string) string {
+	var parts []string
+	for _, seg := range slices.Backward(segments) {
+		if seg != "" {
+			parts = append(parts, seg)
+		}
+	}
+	return strings.Join(parts, locationPathSeparator)
+}
+
+// divisionLabel names the part of a corporation office that flag refers to:
+// "Corp Hangar 3 (Research BPOs)", or "Corp Hangar 3" while the division is
+// unnamed or its name unknown, and "Corp Deliveries". An unknown flag yields
+// an empty label.
+func (w *Worker) divisionLabel(ctx context.Context, corpID int64, flag string) string {
+	if flag == corpDeliveriesFlag {
+		return "Corp Deliveries"
 	}
+	division, err := strconv.ParseInt(strings.TrimPrefix(flag, corpDivisionFlagPrefix), 10, 64)
+	if !strings.HasPrefix(flag, corpDivisionFlagPrefix) || err != nil {
+		return ""
+	}
+	label := fmt.Sprintf("Corp Hangar %d", division)
+	name, err := w.store.GetCorpDivisionName(ctx, store.GetCorpDivisionNameParams{
+		CorporationID: corpID,
+		Division:      division,
+	})
+	if err == nil && name != "" {
+		label += " (" + name + ")"
+	}
+	return label
+}
+
+// assetLabel names a container or ship in a location path by its type and, if
+// it has one, its player-given name: "Station Container 'T2 BPOs'". A type not
+// resolved yet is shown as "Container".
+func (w *Worker) assetLabel(ctx context.Context, asset store.Asset) string {
+	label := "Container"
+	if t, err := w.store.GetEveType(ctx, asset.TypeID); err == nil {
+		label = t.Name
+	}
+	if asset.Name.Valid {
+		label += " '" + asset.Name.String + "'"
+	}
+	return label
+}
+
+// locationFresh reports whether a cached location can be used at now without
+// resolving it again. Entries are refreshed after locationTTL so that renamed
+// structures pick up their new name, the unknownLocationSentinel after the shorter
+// sentinelTTL, and entries without a solar system (resolved before systems were
+// stored) on the next cycle.
+func locationFresh(loc store.EveLocation, now time.Time) bool {
+	if loc.Name == unknownLocationSentinel {
+		return now.Sub(loc.ResolvedAt) < sentinelTTL
+	}
+	return loc.SolarSystemID.Valid && now.Sub(loc.ResolvedAt) < locationTTL
 }
 
-// resolveDirectLocation resolves a direct station or structure ID to a human-readable name.
-// NPC stations (60 000 000–64 000 000) are fetched via GetStation.
-// All other IDs are treated as player structures and fetched via GetUniverseStructure.
-func (w *Worker) resolveDirectLocation(ctx context.Context, id int64, now time.Time, getToken func() string) {
+// storeLocation resolves the NPC station, player structure, or solar system id
+// and stores its name and solar system in eve_locations. A structure ESI does
+// not know (404) is stored as unknownLocationSentinel. On any other failure
+// nothing is stored, unless the location is not cached yet and bulkName, its
+// name from POST /universe/names/, is known: then that name is stored without
+// a solar system.
+func (w *Worker) storeLocation(ctx context.Context, id int64, now time.Time, bulkName string, getCandidates func() []int64) {
+	name, systemID, err := w.lookupLocation(ctx, id, bulkName, getCandidates)
 	switch {
-	case id >= npcStationMin && id < npcStationMax:
-		name, err := w.esi.GetStation(ctx, id)
-		if err != nil {
-			log.Printf("sync: resolving NPC station %d: %v", id, err)
-			return
-		}
+	case errors.Is(err, esi.ErrForbidden):
+		log.Printf("sync: structure %d: no character has access, skipping cache", id)
+		return
+	case errors.Is(err, esi.ErrNotFound):
+		log.Printf("sync: structure %d: not found in ESI, storing sentinel", id)
 		if err := w.store.InsertLocation(ctx, store.InsertLocationParams{
 			ID:         id,
-			Name:       name,
+			Name:       unknownLocationSentinel,
 			ResolvedAt: now,
 		}); err != nil {
 			log.Printf("sync: inserting location %d: %v", id, err)
 		}
-	default: // player structure
-		tok := getToken()
-		if tok == "" {
-			log.Printf("sync: no character token available for structure resolution")
+		return
+	case err != nil:
+		log.Printf("sync: resolving location %d: %v", id, err)
+		if bulkName == "" {
 			return
 		}
-		structure, err := w.esi.GetUniverseStructure(ctx, id, tok)
-		if errors.Is(err, esi.ErrForbidden) {
-			log.Printf("sync: structure %d: access denied, skipping cache", id)
+		if _, err := w.store.GetLocation(ctx, id); !errors.Is(err, sql.ErrNoRows) {
 			return
 		}
-		if errors.Is(err, esi.ErrNotFound) {
-			log.Printf("sync: structure %d: not found in ESI, storing sentinel", id)
-			_ = w.store.InsertLocation(ctx, store.InsertLocationParams{
-				ID:         id,
-				Name:       corpHangarSentinel,
-				ResolvedAt: now,
-			})
-			return
+		if err := w.store.InsertLocation(ctx, store.InsertLocationParams{
+			ID:         id,
+			Name:       bulkName,
+			ResolvedAt: now,
+		}); err != nil {
+			log.Printf("sync: inserting location %d: %v", id, err)
 		}
+		return
+	}
+
+	if err := w.store.InsertLocation(ctx, store.InsertLocationParams{
+		ID:            id,
+		Name:          name,
+		ResolvedAt:    now,
+		SolarSystemID: sql.NullInt64{Int64: systemID, Valid: true},
+	}); err != nil {
+		log.Printf("sync: inserting location %d: %v", id, err)
+	}
+}
+
+// lookupLocation fetches the display name and solar system of a station,
+// structure, or solar system ID and makes sure the system is cached in
+// eve_systems. NPC stations (60 000 000–64 000 000) keep their ESI name: with
+// bulkName, their name from POST /universe/names/, and a cached solar system
+// nothing is fetched; otherwise they are fetched via GetStation. Solar systems
+// (items in space) are named after the system; all other IDs are treated as
+// player structures, fetched via fetchStructure and named "System — Structure".
+// Returns esi.ErrForbidden or esi.ErrNotFound from fetchStructure unwrapped.
+func (w *Worker) lookupLocation(ctx context.Context, id int64, bulkName string, getCandidates func() []int64) (string, int64, error) {
+	if id >= solarSystemMin && id < solarSystemMax {
+		sys, err := w.resolveSystem(ctx, id)
 		if err != nil {
-			log.Printf("sync: fetching structure %d: %v", id, err)
-			return
+			return "", 0, fmt.Errorf("resolving system %d: %w", id, err)
+		}
+		return sys.Name, id, nil
+	}
+	if id >= npcStationMin && id < npcStationMax {
+		if cached, err := w.store.GetLocation(ctx, id); err == nil && cached.SolarSystemID.Valid && bulkName != "" {
+			systemID := cached.SolarSystemID.Int64
+			if _, err := w.resolveSystem(ctx, systemID); err != nil {
+				return "", 0, fmt.Errorf("resolving system %d of station %d: %w", systemID, id, err)
+			}
+			return bulkName, systemID, nil
 		}
-		sysName, err := w.getSystemName(ctx, structure.SolarSystemID)
+		station, err := w.esi.GetStation(ctx, id)
 		if err != nil {
-			log.Printf("sync: fetching system %d for structure %d: %v", structure.SolarSystemID, id, err)
-			return
+			return "", 0, err
 		}
-		name := sysName + " \u2014 " + structure.Name
-		if err := w.store.InsertLocation(ctx, store.InsertLocationParams{
-			ID:         id,
-			Name:       name,
-			ResolvedAt: now,
-		}); err != nil {
-			log.Printf("sync: inserting structure location %d: %v", id, err)
+		if _, err := w.resolveSystem(ctx, station.SystemID); err != nil {
+			return "", 0, fmt.Errorf("resolving system %d of station %d: %w", station.SystemID, id, err)
 		}
+		return station.Name, station.SystemID, nil
+	}
+
+	structure, err := w.fetchStructure(ctx, id, getCandidates())
+	if err != nil {
+		return "", 0, err
+	}
+	sys, err := w.resolveSystem(ctx, structure.SolarSystemID)
+	if err != nil {
+		return "", 0, fmt.Errorf("resolving system %d of structure %d: %w", structure.SolarSystemID, id, err)
 	}
+	return sys.Name + " \u2014 " + structure.Name, structure.SolarSystemID, nil
 }
 
-// getSystemName returns the name of a solar system, using eve_locations as a cache.
-// If the system name is not cached, it is fetched from ESI and stored.
-func (w *Worker) getSystemName(ctx context.Context, systemID int64) (string, error) {
-	if loc, err := w.store.GetLocation(ctx, systemID); err == nil {
-		return loc.Name, nil
+// resolveSystem returns a solar system with its constellation and region,
+// using eve_systems as a cache that is refreshed after locationTTL. If the
+// refresh fails, the cached row is returned.
+func (w *Worker) resolveSystem(ctx context.Context, systemID int64) (store.EveSystem, error) {
+	cached, cacheErr := w.store.GetEveSystem(ctx, systemID)
+	if cacheErr == nil && w.now().Sub(cached.ResolvedAt) < locationTTL {
+		return cached, nil
 	}
 
-	name, err := w.esi.GetUniverseSystem(ctx, systemID)
+	sys, err := w.esi.GetUniverseSystem(ctx, systemID)
 	if err != nil {
-		return "", err
+		if cacheErr == nil {
+			log.Printf("sync: refreshing system %d: %v", systemID, err)
+			return cached, nil
+		}
+		return store.EveSystem{}, err
 	}
 
-	if err := w.store.InsertLocation(ctx, store.InsertLocationParams{
-		ID:         systemID,
-		Name:       name,
-		ResolvedAt: w.now(),
-	}); err != nil {
-		log.Printf("sync: caching system name %d: %v", systemID, err)
+	row := store.EveSystem{
+		ID:                systemID,
+		Name:              sys.Name,
+		SecurityStatus:    sys.SecurityStatus,
+		ConstellationID:   sys.ConstellationID,
+		ConstellationName: sys.ConstellationName,
+		RegionID:          sys.RegionID,
+		RegionName:        sys.RegionName,
+		ResolvedAt:        w.now(),
+	}
+	if err := w.store.UpsertEveSystem(ctx, store.UpsertEveSystemParams(row)); err != nil {
+		log.Printf("sync: caching system %d: %v", systemID, err)
 	}
-	return name, nil
+	return row, nil
 }
 
-// anyCharacterToken returns the access token of any available character,
-// or empty string if no characters are registered.
-func (w *Worker) anyCharacterToken(ctx context.Context) string {
+// structureCandidates returns the IDs of the characters whose tokens may be
+// used to look up structures holding ownerType/ownerID's blueprints: the owning
+// character, or the members of the owning corporation, first — they are the
+// likeliest to have docking access — then every other character. Characters
+// that need re-authorization or are flagged as transferred are left out.
+func (w *Worker) structureCandidates(ctx context.Context, ownerType string, ownerID int64) []int64 {
 	chars, err := w.store.ListCharacters(ctx)
-	if err != nil || len(chars) == 0 {
-		return ""
+	if err != nil {
+		log.Printf("sync: listing characters for structure lookups: %v", err)
+		return nil
+	}
+	var owners, others []int64
+	for _, char := range chars {
+		if char.NeedsReauth != 0 || char.Transferred != 0 {
+			continue
+		}
+		if (ownerType == ownerTypeCharacter && char.ID == ownerID) ||
+			(ownerType == ownerTypeCorporation && char.CorporationID == ownerID) {
+			owners = append(owners, char.ID)
+		} else {
+			others = append(others, char.ID)
+		}
+	}
+	return append(owners, others...)
+}
+
+// fetchStructure fetches a player structure with the token of each candidate
+// character in turn until one is granted access. Characters recorded in
+// structure_access as granted are tried first; characters recorded as
+// forbidden are skipped until structureForbiddenCooldown has passed. Each 403
+// and the success are recorded. Returns esi.ErrForbidden if no character was
+// granted access; any other error, such as esi.ErrNotFound, does not depend on
+// the character and is returned at once.
+func (w *Worker) fetchStructure(ctx context.Context, structureID int64, candidates []int64) (esi.UniverseStructure, error) {
+	if w.tokens == nil || len(candidates) == 0 {
+		return esi.UniverseStructure{}, errors.New("no character available for structure lookups")
+	}
+	access, err := w.store.ListStructureAccess(ctx, structureID)
+	if err != nil {
+		return esi.UniverseStructure{}, fmt.Errorf("listing access to structure %d: %w", structureID, err)
+	}
+	known := make(map[int64]store.StructureAccess, len(access))
+	for _, a := range access {
+		known[a.CharacterID] = a
+	}
+
+	now := w.now()
+	var granted, untried []int64
+	for _, id := range candidates {
+		a, ok := known[id]
+		switch {
+		case !ok:
+			untried = append(untried, id)
+		case a.Status == structureAccessGranted:
+			granted = append(granted, id)
+		case now.Sub(a.CheckedAt) >= structureForbiddenCooldown:
+			untried = append(untried, id)
+		}
+	}
+
+	for _, id := range append(granted, untried...) {
+		token, err := w.tokens.FreshToken(ctx, id)
+		if err != nil {
+			log.Printf("sync: getting token of character %d for structure %d: %v", id, structureID, err)
+			continue
+		}
+		structure, err := w.esi.GetUniverseStructure(ctx, structureID, token)
+		if errors.Is(err, esi.ErrForbidden) {
+			w.recordStructureAccess(ctx, structureID, id, structureAccessForbidden, now)
+			continue
+		}
+		if err != nil {
+			return esi.UniverseStructure{}, err
+		}
+		w.recordStructureAccess(ctx, structureID, id, structureAccessGranted, now)
+		return structure, nil
+	}
+	return esi.UniverseStructure{}, esi.ErrForbidden
+}
+
+// recordStructureAccess stores the outcome of a structure lookup made with a
+// character's token. Failures are logged: they only cost a retry.
+func (w *Worker) recordStructureAccess(ctx context.Context, structureID, characterID int64, status string, now time.Time) {
+	if err := w.store.UpsertStructureAccess(ctx, store.UpsertStructureAccessParams{
+		StructureID: structureID,
+		CharacterID: characterID,
+		Status:      status,
+		CheckedAt:   now,
+	}); err != nil {
+		log.Printf("sync: recording access of character %d to structure %d: %v", characterID, structureID, err)
 	}
-	return chars[0].AccessToken
 }
 
 // resolveTypeIDs loads type_ids already stored for the given owner and calls
@@ -608,8 +1530,25 @@ func (w *Worker) resolveTypeIDs(ctx context.Context, ownerType string, ownerID i
 	w.resolveTypeIDsList(ctx, typeIDs)
 }
 
+// syncRoles fetches the corporation roles of a character and stores them.
+// Returns the ESI cache expiry time on success.
+func (w *Worker) syncRoles(ctx context.Context, characterID int64) (time.Time, error) {
+	roles, cacheUntil, err := w.esi.GetCharacterRoles(ctx, characterID, "")
+	if err != nil {
+		return cacheUntil, fmt.Errorf("fetching roles: %w", err)
+	}
+	if err := w.store.UpdateCharacterRoles(ctx, store.UpdateCharacterRolesParams{
+		Roles: sql.NullString{String: strings.Join(roles, " "), Valid: true},
+		ID:    characterID,
+	}); err != nil {
+		return cacheUntil, fmt.Errorf("storing roles: %w", err)
+	}
+	return cacheUntil, nil
+}
+
 // syncJobs fetches active/ready jobs from ESI, upserts them into the store,
 // and deletes any jobs that were previously stored but are no longer in the ESI response.
+// A job_ready event is published for each job that became ready since the last sync.
 // Returns the ESI cache expiry time on success.
 func (w *Worker) syncJobs(ctx context.Context, ownerType string, ownerID int64) (time.Time, error) {
 	var jobs []esi.Job
@@ -634,14 +1573,22 @@ func (w *Worker) syncJobs(ctx context.Context, ownerType string, ownerID int64)
 		incoming[j.JobID] = true
 	}
 
-	// Get job IDs currently in the store for this owner so we can prune stale ones.
-	existing, err := w.store.ListJobIDsByOwner(ctx, store.ListJobIDsByOwnerParams{
+	// Get jobs currently in the store for this owner so we can prune stale
+	// ones and tell which jobs have just become ready.
+	existing, err := w.store.ListJobsByOwner(ctx, store.ListJobsByOwnerParams{
 		OwnerType: ownerType,
 		OwnerID:   ownerID,
 	})
 	if err != nil {
 		return cacheUntil, fmt.Errorf("listing existing jobs: %w", err)
 	}
+	stored := make(map[int64]store.Job, len(existing))
+	for _, j := range existing {
+		stored[j.ID] = j
+	}
+	// As with blueprints, jobs found on an owner's first sync are not
+	// announced as ready.
+	initialImport := len(existing) == 0
 
 	// Upsert all incoming jobs.
 	// A job whose blueprint_id is not in the blueprints table (e.g. a
@@ -665,16 +1612,37 @@ func (w *Worker) syncJobs(ctx context.Context, ownerType string, ownerID int64)
 			log.Printf("sync: jobs %s %d: upserting job %d: %v", ownerType, ownerID, j.JobID, err)
 			continue
 		}
+
+		prev, known := stored[j.JobID]
+		wasReady := known && jobReady(prev.Status, prev.EndDate, prev.UpdatedAt)
+		if jobReady(j.Status, j.EndDate, now) && !wasReady && (known || !initialImport) {
+			w.bus.Publish(events.TypeJobReady, events.Job{
+				JobID:       j.JobID,
+				BlueprintID: j.BlueprintID,
+				OwnerType:   ownerType,
+				OwnerID:     ownerID,
+				InstallerID: j.InstallerID,
+				Activity:    j.Activity,
+				EndDate:     j.EndDate,
+			})
+		}
 	}
 
 	// Delete stale jobs (in store but no longer in ESI response).
-	for _, id := range existing {
-		if !incoming[id] {
-			if err := w.store.DeleteJobByID(ctx, id); err != nil {
-				return cacheUntil, fmt.Errorf("deleting stale job %d: %w", id, err)
+	for _, j := range existing {
+		if !incoming[j.ID] {
+			if err := w.store.DeleteJobByID(ctx, j.ID); err != nil {
+				return cacheUntil, fmt.Errorf("deleting stale job %d: %w", j.ID, err)
 			}
 		}
 	}
 
 	return cacheUntil, nil
 }
+
+// jobReady reports whether a job was ready to deliver at t: ESI reports it
+// "ready", or it is "active" past its end date (ESI flips the status only
+// after a while). Mirrors CountReadyJobs.
+func jobReady(status string, endDate, t time.Time) bool {
+	return status == "ready" || (status == "active" && !endDate.After(t))
+}
@@ -2,11 +2,16 @@ package sync
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/dpleshakov/auspex/internal/esi"
 	"github.com/dpleshakov/auspex/internal/store"
 )
 
@@ -21,16 +26,27 @@ type mockQuerier struct {
 	getSyncFunc   func(store.GetSyncStateParams) (store.SyncState, error)
 
 	// TASK-10: syncSubject
-	upsertBlueprintFunc      func(store.UpsertBlueprintParams) error
-	upsertJobFunc            func(store.UpsertJobParams) error
-	listJobIDsByOwnerFunc    func(store.ListJobIDsByOwnerParams) ([]int64, error)
-	deleteJobByIDFunc        func(int64) error
-	upsertSyncStateFunc      func(store.UpsertSyncStateParams) error
-	updateSyncStateErrorFunc func(store.UpdateSyncStateErrorParams) error
+	upsertBlueprintFunc       func(store.UpsertBlueprintParams) error
+	listBlueprintsByOwnerFunc func(store.ListBlueprintsByOwnerParams) ([]store.Blueprint, error)
+	getBlueprintFunc          func(int64) (store.Blueprint, error)
+	deleteBlueprintByIDFunc   func(int64) error
+	deleteJobsByBlueprintFunc func(int64) error
+	insertBlueprintEventFunc  func(store.InsertBlueprintEventParams) error
+	removals                  map[int64]store.BlueprintRemoval // blueprint_removals, kept like the table
+	upsertJobFunc             func(store.UpsertJobParams) error
+	listJobsByOwnerFunc       func(store.ListJobsByOwnerParams) ([]store.Job, error)
+	deleteJobByIDFunc         func(int64) error
+	upsertSyncStateFunc       func(store.UpsertSyncStateParams) error
+	updateSyncStateErrorFunc  func(store.UpdateSyncStateErrorParams) error
+
+	updateSyncStateMissingScopeFunc func(store.UpdateSyncStateMissingScopeParams) error
+	updateCharacterRolesFunc        func(store.UpdateCharacterRolesParams) error
 
 	// TASK-11: type resolution
 	listBlueprintTypeIDsByOwnerFunc func(store.ListBlueprintTypeIDsByOwnerParams) ([]int64, error)
 	getEveTypeFunc                  func(int64) (store.EveType, error)
+	getEveGroupFunc                 func(int64) (store.EveGroup, error)
+	getEveCategoryFunc              func(int64) (store.EveCategory, error)
 	insertEveCategoryFunc           func(store.InsertEveCategoryParams) error
 	insertEveGroupFunc              func(store.InsertEveGroupParams) error
 	insertEveTypeFunc               func(store.InsertEveTypeParams) error
@@ -38,9 +54,22 @@ type mockQuerier struct {
 	// location resolution
 	listBlueprintLocationIDsByOwnerFunc func(store.ListBlueprintLocationIDsByOwnerParams) ([]int64, error)
 	listBlueprintLocationsByOwnerFunc   func(store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error)
-	getCorpAssetFunc                    func(int64) (store.GetCorpAssetRow, error)
+	getAssetFunc                        func(int64) (store.Asset, error)
+	upsertAssetFunc                     func(store.UpsertAssetParams) error
+	upsertCorpDivisionFunc              func(store.UpsertCorpDivisionParams) error
+	getCorpDivisionNameFunc             func(store.GetCorpDivisionNameParams) (string, error)
+	upsertBlueprintLocationsFunc        func(store.UpsertBlueprintLocationsParams) error
+	deleteBlueprintLocationsFunc        func(store.DeleteBlueprintLocationsParams) error
 	getLocationFunc                     func(int64) (store.EveLocation, error)
 	insertLocationFunc                  func(store.InsertLocationParams) error
+	getEveSystemFunc                    func(int64) (store.EveSystem, error)
+	upsertEveSystemFunc                 func(store.UpsertEveSystemParams) error
+	listStructureAccessFunc             func(int64) ([]store.StructureAccess, error)
+	upsertStructureAccessFunc           func(store.UpsertStructureAccessParams) error
+
+	// search index
+	searchIndexCalls  []string
+	indexLocationsErr error
 }
 
 func (m *mockQuerier) ListCharacters(_ context.Context) ([]store.Character, error) {
@@ -98,12 +127,34 @@ func (m *mockQuerier) GetCharacter(_ context.Context, _ int64) (store.Character,
 func (m *mockQuerier) GetCorporation(_ context.Context, _ int64) (store.Corporation, error) {
 	panic("unexpected call to GetCorporation")
 }
+func (m *mockQuerier) GetEveSystem(_ context.Context, id int64) (store.EveSystem, error) {
+	if m.getEveSystemFunc != nil {
+		return m.getEveSystemFunc(id)
+	}
+	panic("unexpected call to GetEveSystem")
+}
 func (m *mockQuerier) GetEveType(_ context.Context, id int64) (store.EveType, error) {
 	if m.getEveTypeFunc != nil {
 		return m.getEveTypeFunc(id)
 	}
 	panic("unexpected call to GetEveType")
 }
+
+func (m *mockQuerier) GetEveGroup(_ context.Context, id int64) (store.EveGroup, error) {
+	if m.getEveGroupFunc != nil {
+		return m.getEveGroupFunc(id)
+	}
+	// Default: no group stored yet.
+	return store.EveGroup{}, sql.ErrNoRows
+}
+
+func (m *mockQuerier) GetEveCategory(_ context.Context, id int64) (store.EveCategory, error) {
+	if m.getEveCategoryFunc != nil {
+		return m.getEveCategoryFunc(id)
+	}
+	// Default: no category stored yet.
+	return store.EveCategory{}, sql.ErrNoRows
+}
 func (m *mockQuerier) InsertCorporation(_ context.Context, _ store.InsertCorporationParams) error {
 	panic("unexpected call to InsertCorporation")
 }
@@ -138,18 +189,87 @@ func (m *mockQuerier) ListBlueprintTypeIDsByOwner(_ context.Context, arg store.L
 func (m *mockQuerier) ListBlueprints(_ context.Context, _ store.ListBlueprintsParams) ([]store.ListBlueprintsRow, error) {
 	panic("unexpected call to ListBlueprints")
 }
+func (m *mockQuerier) ListBlueprintOwnerCounts(_ context.Context) ([]store.ListBlueprintOwnerCountsRow, error) {
+	panic("unexpected call to ListBlueprintOwnerCounts")
+}
+func (m *mockQuerier) ListBlueprintCategoryCounts(_ context.Context) ([]store.ListBlueprintCategoryCountsRow, error) {
+	panic("unexpected call to ListBlueprintCategoryCounts")
+}
+func (m *mockQuerier) ListBlueprintRegionCounts(_ context.Context) ([]store.ListBlueprintRegionCountsRow, error) {
+	panic("unexpected call to ListBlueprintRegionCounts")
+}
 func (m *mockQuerier) ListCharacterSlotUsage(_ context.Context) ([]store.ListCharacterSlotUsageRow, error) {
 	panic("unexpected call to ListCharacterSlotUsage")
 }
-func (m *mockQuerier) ListJobIDsByOwner(_ context.Context, arg store.ListJobIDsByOwnerParams) ([]int64, error) {
-	if m.listJobIDsByOwnerFunc != nil {
-		return m.listJobIDsByOwnerFunc(arg)
+func (m *mockQuerier) ListJobsByOwner(_ context.Context, arg store.ListJobsByOwnerParams) ([]store.Job, error) {
+	if m.listJobsByOwnerFunc != nil {
+		return m.listJobsByOwnerFunc(arg)
+	}
+	panic("unexpected call to ListJobsByOwner")
+}
+func (m *mockQuerier) ListStructureAccess(_ context.Context, structureID int64) ([]store.StructureAccess, error) {
+	if m.listStructureAccessFunc != nil {
+		return m.listStructureAccessFunc(structureID)
 	}
-	panic("unexpected call to ListJobIDsByOwner")
+	panic("unexpected call to ListStructureAccess")
 }
 func (m *mockQuerier) ListSyncStatus(_ context.Context) ([]store.ListSyncStatusRow, error) {
 	panic("unexpected call to ListSyncStatus")
 }
+func (m *mockQuerier) MarkCharacterNeedsReauth(_ context.Context, _ int64) error {
+	panic("unexpected call to MarkCharacterNeedsReauth")
+}
+func (m *mockQuerier) MarkCharacterTransferred(_ context.Context, _ int64) error {
+	panic("unexpected call to MarkCharacterTransferred")
+}
+func (m *mockQuerier) ClearCharacterTransferred(_ context.Context, _ int64) error {
+	panic("unexpected call to ClearCharacterTransferred")
+}
+func (m *mockQuerier) GetTokenEncryption(_ context.Context) (store.TokenEncryption, error) {
+	panic("unexpected call to GetTokenEncryption")
+}
+func (m *mockQuerier) ListCharacterTokens(_ context.Context) ([]store.ListCharacterTokensRow, error) {
+	panic("unexpected call to ListCharacterTokens")
+}
+func (m *mockQuerier) SetCharacterTokenColumns(_ context.Context, _ store.SetCharacterTokenColumnsParams) error {
+	panic("unexpected call to SetCharacterTokenColumns")
+}
+func (m *mockQuerier) UpsertTokenEncryption(_ context.Context, _ store.UpsertTokenEncryptionParams) error {
+	panic("unexpected call to UpsertTokenEncryption")
+}
+func (m *mockQuerier) InsertAffiliationEvent(_ context.Context, _ store.InsertAffiliationEventParams) error {
+	panic("unexpected call to InsertAffiliationEvent")
+}
+func (m *mockQuerier) ListAffiliationEventsSince(_ context.Context, _ time.Time) ([]store.AffiliationEvent, error) {
+	panic("unexpected call to ListAffiliationEventsSince")
+}
+func (m *mockQuerier) UpdateCharacterAffiliation(_ context.Context, _ store.UpdateCharacterAffiliationParams) error {
+	panic("unexpected call to UpdateCharacterAffiliation")
+}
+func (m *mockQuerier) AdoptCorporation(_ context.Context, _ store.AdoptCorporationParams) error {
+	panic("unexpected call to AdoptCorporation")
+}
+func (m *mockQuerier) OrphanCorporation(_ context.Context, _ int64) error {
+	panic("unexpected call to OrphanCorporation")
+}
+func (m *mockQuerier) SetCorporationActiveCharacter(_ context.Context, _ store.SetCorporationActiveCharacterParams) error {
+	panic("unexpected call to SetCorporationActiveCharacter")
+}
+func (m *mockQuerier) UpdateCharacterRoles(_ context.Context, arg store.UpdateCharacterRolesParams) error {
+	if m.updateCharacterRolesFunc != nil {
+		return m.updateCharacterRolesFunc(arg)
+	}
+	panic("unexpected call to UpdateCharacterRoles")
+}
+func (m *mockQuerier) UpdateCharacterTokens(_ context.Context, _ store.UpdateCharacterTokensParams) error {
+	panic("unexpected call to UpdateCharacterTokens")
+}
+func (m *mockQuerier) UpdateSyncStateMissingScope(_ context.Context, arg store.UpdateSyncStateMissingScopeParams) error {
+	if m.updateSyncStateMissingScopeFunc != nil {
+		return m.updateSyncStateMissingScopeFunc(arg)
+	}
+	panic("unexpected call to UpdateSyncStateMissingScope")
+}
 func (m *mockQuerier) UpsertBlueprint(_ context.Context, arg store.UpsertBlueprintParams) error {
 	if m.upsertBlueprintFunc != nil {
 		return m.upsertBlueprintFunc(arg)
@@ -159,12 +279,24 @@ func (m *mockQuerier) UpsertBlueprint(_ context.Context, arg store.UpsertBluepri
 func (m *mockQuerier) UpsertCharacter(_ context.Context, _ store.UpsertCharacterParams) error {
 	panic("unexpected call to UpsertCharacter")
 }
+func (m *mockQuerier) UpsertEveSystem(_ context.Context, arg store.UpsertEveSystemParams) error {
+	if m.upsertEveSystemFunc != nil {
+		return m.upsertEveSystemFunc(arg)
+	}
+	panic("unexpected call to UpsertEveSystem")
+}
 func (m *mockQuerier) UpsertJob(_ context.Context, arg store.UpsertJobParams) error {
 	if m.upsertJobFunc != nil {
 		return m.upsertJobFunc(arg)
 	}
 	panic("unexpected call to UpsertJob")
 }
+func (m *mockQuerier) UpsertStructureAccess(_ context.Context, arg store.UpsertStructureAccessParams) error {
+	if m.upsertStructureAccessFunc != nil {
+		return m.upsertStructureAccessFunc(arg)
+	}
+	panic("unexpected call to UpsertStructureAccess")
+}
 func (m *mockQuerier) UpsertSyncState(_ context.Context, arg store.UpsertSyncStateParams) error {
 	if m.upsertSyncStateFunc != nil {
 		return m.upsertSyncStateFunc(arg)
@@ -209,15 +341,183 @@ func (m *mockQuerier) InsertLocation(_ context.Context, arg store.InsertLocation
 	panic("unexpected call to InsertLocation")
 }
 
-func (m *mockQuerier) DeleteCorpAssetsByOwner(_ context.Context, _ int64) error {
+func (m *mockQuerier) ClearSearchIndex(_ context.Context) error {
+	m.searchIndexCalls = append(m.searchIndexCalls, "ClearSearchIndex")
+	return nil
+}
+func (m *mockQuerier) IndexBlueprints(_ context.Context) error {
+	m.searchIndexCalls = append(m.searchIndexCalls, "IndexBlueprints")
+	return nil
+}
+func (m *mockQuerier) IndexCharacters(_ context.Context) error {
+	m.searchIndexCalls = append(m.searchIndexCalls, "IndexCharacters")
+	return nil
+}
+func (m *mockQuerier) IndexCorporations(_ context.Context) error {
+	m.searchIndexCalls = append(m.searchIndexCalls, "IndexCorporations")
+	return nil
+}
+func (m *mockQuerier) IndexLocations(_ context.Context) error {
+	m.searchIndexCalls = append(m.searchIndexCalls, "IndexLocations")
+	return m.indexLocationsErr
+}
+func (m *mockQuerier) SearchIndex(_ context.Context, _ store.SearchIndexParams) ([]store.SearchIndexRow, error) {
+	panic("unexpected call to SearchIndex")
+}
+
+func (m *mockQuerier) CreateCalendarFeed(_ context.Context, _ store.CreateCalendarFeedParams) (store.CalendarFeed, error) {
+	panic("unexpected call to CreateCalendarFeed")
+}
+func (m *mockQuerier) ListCalendarFeeds(_ context.Context) ([]store.CalendarFeed, error) {
+	panic("unexpected call to ListCalendarFeeds")
+}
+func (m *mockQuerier) GetCalendarFeedByToken(_ context.Context, _ string) (store.CalendarFeed, error) {
+	panic("unexpected call to GetCalendarFeedByToken")
+}
+func (m *mockQuerier) DeleteCalendarFeed(_ context.Context, _ int64) (int64, error) {
+	panic("unexpected call to DeleteCalendarFeed")
+}
+
+func (m *mockQuerier) GetNotification(_ context.Context, _ store.GetNotificationParams) (store.NotificationLog, error) {
+	panic("unexpected call to GetNotification")
+}
+func (m *mockQuerier) RecordNotification(_ context.Context, _ store.RecordNotificationParams) error {
+	panic("unexpected call to RecordNotification")
+}
+func (m *mockQuerier) DeleteNotification(_ context.Context, _ store.DeleteNotificationParams) error {
+	panic("unexpected call to DeleteNotification")
+}
+func (m *mockQuerier) DeleteNotificationsBefore(_ context.Context, _ time.Time) error {
+	panic("unexpected call to DeleteNotificationsBefore")
+}
+func (m *mockQuerier) MarkIdleBlueprints(_ context.Context, _ time.Time) error {
+	panic("unexpected call to MarkIdleBlueprints")
+}
+func (m *mockQuerier) ClearBusyBlueprints(_ context.Context) error {
+	panic("unexpected call to ClearBusyBlueprints")
+}
+func (m *mockQuerier) ListIdleBlueprintsSince(_ context.Context, _ time.Time) ([]store.BlueprintIdle, error) {
+	panic("unexpected call to ListIdleBlueprintsSince")
+}
+
+func (m *mockQuerier) CreateWebhook(_ context.Context, _ store.CreateWebhookParams) (store.Webhook, error) {
+	panic("unexpected call to CreateWebhook")
+}
+func (m *mockQuerier) GetWebhook(_ context.Context, _ int64) (store.Webhook, error) {
+	panic("unexpected call to GetWebhook")
+}
+func (m *mockQuerier) ListWebhooks(_ context.Context) ([]store.Webhook, error) {
+	panic("unexpected call to ListWebhooks")
+}
+func (m *mockQuerier) UpdateWebhook(_ context.Context, _ store.UpdateWebhookParams) (store.Webhook, error) {
+	panic("unexpected call to UpdateWebhook")
+}
+func (m *mockQuerier) SetWebhookSecret(_ context.Context, _ store.SetWebhookSecretParams) (store.Webhook, error) {
+	panic("unexpected call to SetWebhookSecret")
+}
+func (m *mockQuerier) DeleteWebhook(_ context.Context, _ int64) (int64, error) {
+	panic("unexpected call to DeleteWebhook")
+}
+func (m *mockQuerier) CreateWebhookDelivery(_ context.Context, _ store.CreateWebhookDeliveryParams) (store.WebhookDelivery, error) {
+	panic("unexpected call to CreateWebhookDelivery")
+}
+func (m *mockQuerier) ListWebhookDeliveries(_ context.Context, _ store.ListWebhookDeliveriesParams) ([]store.WebhookDelivery, error) {
+	panic("unexpected call to ListWebhookDeliveries")
+}
+func (m *mockQuerier) DeleteWebhookDeliveriesBefore(_ context.Context, _ time.Time) error {
+	panic("unexpected call to DeleteWebhookDeliveriesBefore")
+}
+func (m *mockQuerier) GetPushKeys(_ context.Context) (store.PushKey, error) {
+	panic("unexpected call to GetPushKeys")
+}
+func (m *mockQuerier) InsertPushKeys(_ context.Context, _ store.InsertPushKeysParams) error {
+	panic("unexpected call to InsertPushKeys")
+}
+func (m *mockQuerier) UpsertPushSubscription(_ context.Context, _ store.UpsertPushSubscriptionParams) (store.PushSubscription, error) {
+	panic("unexpected call to UpsertPushSubscription")
+}
+func (m *mockQuerier) ListPushSubscriptions(_ context.Context) ([]store.PushSubscription, error) {
+	panic("unexpected call to ListPushSubscriptions")
+}
+func (m *mockQuerier) DeletePushSubscription(_ context.Context, _ int64) (int64, error) {
+	panic("unexpected call to DeletePushSubscription")
+}
+func (m *mockQuerier) DeletePushSubscriptionByEndpoint(_ context.Context, _ string) error {
+	panic("unexpected call to DeletePushSubscriptionByEndpoint")
+}
+func (m *mockQuerier) CreateAlertRule(_ context.Context, _ store.CreateAlertRuleParams) (store.AlertRule, error) {
+	panic("unexpected call to CreateAlertRule")
+}
+func (m *mockQuerier) GetAlertRule(_ context.Context, _ int64) (store.AlertRule, error) {
+	panic("unexpected call to GetAlertRule")
+}
+func (m *mockQuerier) ListAlertRules(_ context.Context) ([]store.AlertRule, error) {
+	panic("unexpected call to ListAlertRules")
+}
+func (m *mockQuerier) UpdateAlertRule(_ context.Context, _ store.UpdateAlertRuleParams) (store.AlertRule, error) {
+	panic("unexpected call to UpdateAlertRule")
+}
+func (m *mockQuerier) DeleteAlertRule(_ context.Context, _ int64) (int64, error) {
+	panic("unexpected call to DeleteAlertRule")
+}
+func (m *mockQuerier) CreateAlertHistory(_ context.Context, _ store.CreateAlertHistoryParams) error {
+	panic("unexpected call to CreateAlertHistory")
+}
+func (m *mockQuerier) GetLastAlertFiring(_ context.Context, _ store.GetLastAlertFiringParams) (time.Time, error) {
+	panic("unexpected call to GetLastAlertFiring")
+}
+func (m *mockQuerier) ListAlertHistory(_ context.Context, _ int64) ([]store.AlertHistory, error) {
+	panic("unexpected call to ListAlertHistory")
+}
+func (m *mockQuerier) ListAlertHistoryByRule(_ context.Context, _ store.ListAlertHistoryByRuleParams) ([]store.AlertHistory, error) {
+	panic("unexpected call to ListAlertHistoryByRule")
+}
+func (m *mockQuerier) DeleteAlertHistoryBefore(_ context.Context, _ time.Time) error {
+	panic("unexpected call to DeleteAlertHistoryBefore")
+}
+
+func (m *mockQuerier) DeleteAssetsByOwner(_ context.Context, _ store.DeleteAssetsByOwnerParams) error {
+	return nil
+}
+
+func (m *mockQuerier) GetAsset(_ context.Context, itemID int64) (store.Asset, error) {
+	if m.getAssetFunc != nil {
+		return m.getAssetFunc(itemID)
+	}
+	// Default: empty asset tree — every location_id is a station or structure.
+	return store.Asset{}, sql.ErrNoRows
+}
+
+func (m *mockQuerier) DeleteCorpDivisions(_ context.Context, _ int64) error {
 	return nil
 }
 
-func (m *mockQuerier) GetCorpAsset(_ context.Context, itemID int64) (store.GetCorpAssetRow, error) {
-	if m.getCorpAssetFunc != nil {
-		return m.getCorpAssetFunc(itemID)
+func (m *mockQuerier) GetCorpDivisionName(_ context.Context, arg store.GetCorpDivisionNameParams) (string, error) {
+	if m.getCorpDivisionNameFunc != nil {
+		return m.getCorpDivisionNameFunc(arg)
 	}
-	return store.GetCorpAssetRow{}, nil
+	return "", sql.ErrNoRows
+}
+
+func (m *mockQuerier) UpsertCorpDivision(_ context.Context, arg store.UpsertCorpDivisionParams) error {
+	if m.upsertCorpDivisionFunc != nil {
+		return m.upsertCorpDivisionFunc(arg)
+	}
+	return nil
+}
+
+func (m *mockQuerier) UpsertBlueprintLocations(_ context.Context, arg store.UpsertBlueprintLocationsParams) error {
+	if m.upsertBlueprintLocationsFunc != nil {
+		return m.upsertBlueprintLocationsFunc(arg)
+	}
+	return nil
+}
+
+func (m *mockQuerier) DeleteBlueprintLocations(_ context.Context, arg store.DeleteBlueprintLocationsParams) error {
+	if m.deleteBlueprintLocationsFunc != nil {
+		return m.deleteBlueprintLocationsFunc(arg)
+	}
+	return nil
 }
 
 func (m *mockQuerier) ListBlueprintLocationsByOwner(_ context.Context, arg store.ListBlueprintLocationsByOwnerParams) ([]store.ListBlueprintLocationsByOwnerRow, error) {
@@ -228,10 +528,85 @@ func (m *mockQuerier) ListBlueprintLocationsByOwner(_ context.Context, arg store
 	return nil, nil
 }
 
-func (m *mockQuerier) UpsertCorpAsset(_ context.Context, _ store.UpsertCorpAssetParams) error {
+func (m *mockQuerier) UpsertAsset(_ context.Context, arg store.UpsertAssetParams) error {
+	if m.upsertAssetFunc != nil {
+		return m.upsertAssetFunc(arg)
+	}
+	return nil
+}
+
+func (m *mockQuerier) ListBlueprintsByOwner(_ context.Context, arg store.ListBlueprintsByOwnerParams) ([]store.Blueprint, error) {
+	if m.listBlueprintsByOwnerFunc != nil {
+		return m.listBlueprintsByOwnerFunc(arg)
+	}
+	// Default: nothing stored — every incoming blueprint is an initial import.
+	return nil, nil
+}
+
+func (m *mockQuerier) GetBlueprint(_ context.Context, id int64) (store.Blueprint, error) {
+	if m.getBlueprintFunc != nil {
+		return m.getBlueprintFunc(id)
+	}
+	return store.Blueprint{}, sql.ErrNoRows
+}
+
+func (m *mockQuerier) GetBlueprintTypeName(_ context.Context, _ int64) (string, error) {
+	panic("unexpected call to GetBlueprintTypeName")
+}
+
+func (m *mockQuerier) DeleteBlueprintByID(_ context.Context, id int64) error {
+	if m.deleteBlueprintByIDFunc != nil {
+		return m.deleteBlueprintByIDFunc(id)
+	}
+	panic("unexpected call to DeleteBlueprintByID")
+}
+
+func (m *mockQuerier) DeleteJobsByBlueprintID(_ context.Context, blueprintID int64) error {
+	if m.deleteJobsByBlueprintFunc != nil {
+		return m.deleteJobsByBlueprintFunc(blueprintID)
+	}
+	panic("unexpected call to DeleteJobsByBlueprintID")
+}
+
+func (m *mockQuerier) InsertBlueprintEvent(_ context.Context, arg store.InsertBlueprintEventParams) error {
+	if m.insertBlueprintEventFunc != nil {
+		return m.insertBlueprintEventFunc(arg)
+	}
+	panic("unexpected call to InsertBlueprintEvent")
+}
+
+func (m *mockQuerier) InsertBlueprintRemoval(_ context.Context, arg store.InsertBlueprintRemovalParams) error {
+	if m.removals == nil {
+		m.removals = make(map[int64]store.BlueprintRemoval)
+	}
+	m.removals[arg.BlueprintID] = store.BlueprintRemoval(arg)
 	return nil
 }
 
+func (m *mockQuerier) GetBlueprintRemoval(_ context.Context, blueprintID int64) (store.BlueprintRemoval, error) {
+	if r, ok := m.removals[blueprintID]; ok {
+		return r, nil
+	}
+	return store.BlueprintRemoval{}, sql.ErrNoRows
+}
+
+func (m *mockQuerier) ListBlueprintRemovals(_ context.Context) ([]store.BlueprintRemoval, error) {
+	var removals []store.BlueprintRemoval
+	for _, id := range slices.Sorted(maps.Keys(m.removals)) {
+		removals = append(removals, m.removals[id])
+	}
+	return removals, nil
+}
+
+func (m *mockQuerier) DeleteBlueprintRemoval(_ context.Context, blueprintID int64) error {
+	delete(m.removals, blueprintID)
+	return nil
+}
+
+func (m *mockQuerier) ListBlueprintEventsSince(_ context.Context, _ time.Time) ([]store.ListBlueprintEventsSinceRow, error) {
+	panic("unexpected call to ListBlueprintEventsSince")
+}
+
 // Compile-time assertion: *mockQuerier must satisfy store.Querier.
 var _ store.Querier = (*mockQuerier)(nil)
 
@@ -243,6 +618,9 @@ func oneChar(id int64) func() ([]store.Character, error) {
 	}
 }
 
+// noAffiliations replaces Worker.affiliationFn in tests of the scheduling loop.
+func noAffiliations(context.Context) error { return nil }
+
 func noCorps() func() ([]store.ListCorporationsRow, error) {
 	return func() ([]store.ListCorporationsRow, error) { return nil, nil }
 }
@@ -269,7 +647,8 @@ func TestCacheFresh_SubjectSkipped(t *testing.T) {
 	}
 
 	var syncCalls []string
-	w := New(q, nil, time.Minute)
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
 	w.syncFn = func(_ context.Context, ownerType string, ownerID int64, endpoint string) {
 		syncCalls = append(syncCalls, fmt.Sprintf("%s:%d:%s", ownerType, ownerID, endpoint))
 	}
@@ -293,7 +672,8 @@ func TestCacheExpired_SubjectSynced(t *testing.T) {
 	}
 
 	var synced []string
-	w := New(q, nil, time.Minute)
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
 	w.syncFn = func(_ context.Context, ownerType string, ownerID int64, endpoint string) {
 		synced = append(synced, fmt.Sprintf("%s:%d:%s", ownerType, ownerID, endpoint))
 	}
@@ -302,6 +682,7 @@ func TestCacheExpired_SubjectSynced(t *testing.T) {
 
 	// Expect blueprints + jobs for the one character.
 	want := []string{
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, charID, endpointAssets),
 		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, charID, endpointBlueprints),
 		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, charID, endpointJobs),
 	}
@@ -327,13 +708,14 @@ func TestNeverSynced_TreatedAsExpired(t *testing.T) {
 	}
 
 	var syncCalls int
-	w := New(q, nil, time.Minute)
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
 	w.syncFn = func(_ context.Context, _ string, _ int64, _ string) { syncCalls++ }
 
 	w.runCycle(context.Background(), false)
 
-	if syncCalls != 2 {
-		t.Errorf("expected 2 sync calls for never-synced subject, got %d", syncCalls)
+	if syncCalls != 3 {
+		t.Errorf("expected 3 sync calls for never-synced subject, got %d", syncCalls)
 	}
 }
 
@@ -347,14 +729,15 @@ func TestForceRefresh_IgnoresFreshCache(t *testing.T) {
 	}
 
 	var syncCalls int
-	w := New(q, nil, time.Minute)
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
 	w.syncFn = func(_ context.Context, _ string, _ int64, _ string) { syncCalls++ }
 
 	w.runCycle(context.Background(), true) // force=true
 
 	// blueprints + jobs for the one character, despite fresh cache.
-	if syncCalls != 2 {
-		t.Errorf("expected 2 sync calls with force=true, got %d", syncCalls)
+	if syncCalls != 3 {
+		t.Errorf("expected 3 sync calls with force=true, got %d", syncCalls)
 	}
 }
 
@@ -366,13 +749,14 @@ func TestCorporation_CacheExpired_Synced(t *testing.T) {
 	q := &mockQuerier{
 		listCharsFunc: func() ([]store.Character, error) { return nil, nil },
 		listCorpsFunc: func() ([]store.ListCorporationsRow, error) {
-			return []store.ListCorporationsRow{{ID: corpID, Name: "TestCorp"}}, nil
+			return []store.ListCorporationsRow{{ID: corpID, Name: "TestCorp", DelegateID: sql.NullInt64{Int64: 1, Valid: true}}}, nil
 		},
 		getSyncFunc: expiredState(time.Now().Add(-time.Hour)),
 	}
 
 	var synced []string
-	w := New(q, nil, time.Minute)
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
 	w.syncFn = func(_ context.Context, ownerType string, ownerID int64, endpoint string) {
 		synced = append(synced, fmt.Sprintf("%s:%d:%s", ownerType, ownerID, endpoint))
 	}
@@ -380,6 +764,7 @@ func TestCorporation_CacheExpired_Synced(t *testing.T) {
 	w.runCycle(context.Background(), false)
 
 	want := []string{
+		fmt.Sprintf("%s:%d:%s", ownerTypeCorporation, corpID, endpointDivisions),
 		fmt.Sprintf("%s:%d:%s", ownerTypeCorporation, corpID, endpointCorpAssets),
 		fmt.Sprintf("%s:%d:%s", ownerTypeCorporation, corpID, endpointBlueprints),
 		fmt.Sprintf("%s:%d:%s", ownerTypeCorporation, corpID, endpointJobs),
@@ -394,6 +779,361 @@ func TestCorporation_CacheExpired_Synced(t *testing.T) {
 	}
 }
 
+// TestNeedsReauth_CharacterAndCorporationSkipped verifies that a character flagged
+// needs_reauth is not synced, and neither is a corporation using it as delegate.
+func TestNeedsReauth_CharacterAndCorporationSkipped(t *testing.T) {
+	q := &mockQuerier{
+		listCharsFunc: func() ([]store.Character, error) {
+			return []store.Character{
+				{ID: 1, Name: "Revoked", NeedsReauth: 1},
+				{ID: 2, Name: "Healthy"},
+			}, nil
+		},
+		listCorpsFunc: func() ([]store.ListCorporationsRow, error) {
+			return []store.ListCorporationsRow{
+				{ID: 98, Name: "RevokedCorp", DelegateID: sql.NullInt64{Int64: 1, Valid: true}},
+				{ID: 99, Name: "HealthyCorp", DelegateID: sql.NullInt64{Int64: 2, Valid: true}},
+			}, nil
+		},
+		getSyncFunc: expiredState(time.Now().Add(-time.Hour)),
+	}
+
+	var synced []string
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
+	w.syncFn = func(_ context.Context, ownerType string, ownerID int64, endpoint string) {
+		synced = append(synced, fmt.Sprintf("%s:%d:%s", ownerType, ownerID, endpoint))
+	}
+
+	w.runCycle(context.Background(), true)
+
+	want := []string{
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 2, endpointAssets),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 2, endpointBlueprints),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 2, endpointJobs),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCorporation, 99, endpointDivisions),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCorporation, 99, endpointCorpAssets),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCorporation, 99, endpointBlueprints),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCorporation, 99, endpointJobs),
+	}
+	if len(synced) != len(want) {
+		t.Fatalf("expected %d sync calls, got %d: %v", len(want), len(synced), synced)
+	}
+	for i, s := range synced {
+		if s != want[i] {
+			t.Errorf("sync call %d: got %q, want %q", i, s, want[i])
+		}
+	}
+}
+
+// TestTransferred_CharacterAndCorporationSkipped verifies that a character
+// flagged as transferred to another account is not synced, and neither is a
+// corporation using it as delegate.
+func TestTransferred_CharacterAndCorporationSkipped(t *testing.T) {
+	q := &mockQuerier{
+		listCharsFunc: func() ([]store.Character, error) {
+			return []store.Character{
+				{ID: 1, Name: "Sold", Transferred: 1},
+				{ID: 2, Name: "Kept"},
+			}, nil
+		},
+		listCorpsFunc: func() ([]store.ListCorporationsRow, error) {
+			return []store.ListCorporationsRow{
+				{ID: 98, Name: "SoldCorp", DelegateID: sql.NullInt64{Int64: 1, Valid: true}},
+			}, nil
+		},
+		getSyncFunc: expiredState(time.Now().Add(-time.Hour)),
+	}
+
+	var synced []string
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
+	w.syncFn = func(_ context.Context, ownerType string, ownerID int64, endpoint string) {
+		synced = append(synced, fmt.Sprintf("%s:%d:%s", ownerType, ownerID, endpoint))
+	}
+
+	w.runCycle(context.Background(), true)
+
+	want := []string{
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 2, endpointAssets),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 2, endpointBlueprints),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 2, endpointJobs),
+	}
+	if !slices.Equal(synced, want) {
+		t.Errorf("sync calls = %v, want %v", synced, want)
+	}
+}
+
+// TestPausedDelegate_CorporationSyncedThroughMember verifies that a
+// corporation whose delegate needs re-authorization is still synced while
+// another member can stand in for it, and that an endpoint is recorded as
+// missing_scope only when no member that is not paused granted its scope.
+func TestPausedDelegate_CorporationSyncedThroughMember(t *testing.T) {
+	var recorded []string
+	q := &mockQuerier{
+		listCharsFunc: func() ([]store.Character, error) {
+			return []store.Character{
+				{ID: 1, Name: "Delegate", CorporationID: 98, NeedsReauth: 1},
+				{ID: 2, Name: "Member", CorporationID: 98, Scopes: strings.Join([]string{
+					esi.ScopeCorporationDivisions, esi.ScopeCorporationAssets, esi.ScopeCorporationJobs,
+				}, " ")},
+			}, nil
+		},
+		listCorpsFunc: func() ([]store.ListCorporationsRow, error) {
+			return []store.ListCorporationsRow{
+				{ID: 98, Name: "Corp", DelegateID: sql.NullInt64{Int64: 1, Valid: true}},
+			}, nil
+		},
+		getSyncFunc: expiredState(time.Now().Add(-time.Hour)),
+		updateSyncStateMissingScopeFunc: func(arg store.UpdateSyncStateMissingScopeParams) error {
+			recorded = append(recorded, fmt.Sprintf("%s:%d:%s", arg.OwnerType, arg.OwnerID, arg.Endpoint))
+			return nil
+		},
+	}
+
+	var synced []string
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
+	w.syncFn = func(_ context.Context, ownerType string, ownerID int64, endpoint string) {
+		if ownerType == ownerTypeCorporation {
+			synced = append(synced, fmt.Sprintf("%s:%d:%s", ownerType, ownerID, endpoint))
+		}
+	}
+
+	w.runCycle(context.Background(), true)
+
+	wantSynced := []string{
+		fmt.Sprintf("%s:%d:%s", ownerTypeCorporation, 98, endpointDivisions),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCorporation, 98, endpointCorpAssets),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCorporation, 98, endpointJobs),
+	}
+	if !slices.Equal(synced, wantSynced) {
+		t.Errorf("corporation sync calls = %v, want %v", synced, wantSynced)
+	}
+	if want := fmt.Sprintf("%s:%d:%s", ownerTypeCorporation, 98, endpointBlueprints); !slices.Contains(recorded, want) {
+		t.Errorf("missing scopes recorded = %v, want %s among them", recorded, want)
+	}
+}
+
+// TestOrphanedCorporation_Skipped verifies that a corporation with no delegate
+// is not synced.
+func TestOrphanedCorporation_Skipped(t *testing.T) {
+	q := &mockQuerier{
+		listCharsFunc: func() ([]store.Character, error) { return nil, nil },
+		listCorpsFunc: func() ([]store.ListCorporationsRow, error) {
+			return []store.ListCorporationsRow{{ID: 98, Name: "OrphanCorp"}}, nil
+		},
+		getSyncFunc: expiredState(time.Now().Add(-time.Hour)),
+	}
+
+	var synced []string
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
+	w.syncFn = func(_ context.Context, ownerType string, ownerID int64, endpoint string) {
+		synced = append(synced, fmt.Sprintf("%s:%d:%s", ownerType, ownerID, endpoint))
+	}
+
+	w.runCycle(context.Background(), true)
+
+	if len(synced) != 0 {
+		t.Errorf("expected no sync calls for an orphaned corporation, got %v", synced)
+	}
+}
+
+// TestRoles_SyncedForTrackedCorporationMembers verifies that corporation roles
+// are synced for members of a tracked corporation only.
+func TestRoles_SyncedForTrackedCorporationMembers(t *testing.T) {
+	q := &mockQuerier{
+		listCharsFunc: func() ([]store.Character, error) {
+			return []store.Character{
+				{ID: 1, Name: "Member", CorporationID: 98},
+				{ID: 2, Name: "Rookie", CorporationID: 1000182},
+			}, nil
+		},
+		listCorpsFunc: func() ([]store.ListCorporationsRow, error) {
+			return []store.ListCorporationsRow{{ID: 98, Name: "Corp"}}, nil
+		},
+		getSyncFunc: expiredState(time.Now().Add(-time.Hour)),
+	}
+
+	var synced []string
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
+	w.syncFn = func(_ context.Context, ownerType string, ownerID int64, endpoint string) {
+		synced = append(synced, fmt.Sprintf("%s:%d:%s", ownerType, ownerID, endpoint))
+	}
+
+	w.runCycle(context.Background(), false)
+
+	want := []string{
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 1, endpointAssets),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 1, endpointBlueprints),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 1, endpointJobs),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 1, endpointRoles),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 2, endpointAssets),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 2, endpointBlueprints),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 2, endpointJobs),
+	}
+	if !slices.Equal(synced, want) {
+		t.Errorf("sync calls = %v, want %v", synced, want)
+	}
+}
+
+// TestAffiliations_RefreshedOncePerInterval verifies that affiliations are
+// refreshed on the first cycle, skipped until affiliationInterval has passed
+// unless the cycle is forced, and retried on the next cycle after a failure.
+func TestAffiliations_RefreshedOncePerInterval(t *testing.T) {
+	q := &mockQuerier{
+		listCharsFunc: func() ([]store.Character, error) { return nil, nil },
+		listCorpsFunc: noCorps(),
+	}
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var calls int
+	var fail bool
+	w := New(q, nil, nil, time.Minute)
+	w.now = func() time.Time { return now }
+	w.affiliationFn = func(context.Context) error {
+		calls++
+		if fail {
+			return errors.New("esi down")
+		}
+		return nil
+	}
+	ctx := context.Background()
+
+	w.runCycle(ctx, false)
+	w.runCycle(ctx, false)
+	if calls != 1 {
+		t.Fatalf("after two cycles: %d refreshes, want 1", calls)
+	}
+
+	w.runCycle(ctx, true)
+	if calls != 2 {
+		t.Fatalf("after forced cycle: %d refreshes, want 2", calls)
+	}
+
+	now = now.Add(affiliationInterval)
+	fail = true
+	w.runCycle(ctx, false)
+	w.runCycle(ctx, false)
+	if calls != 4 {
+		t.Errorf("after failed refreshes: %d refreshes, want 4", calls)
+	}
+}
+
+// TestMissingScope_EndpointSkippedAndRecorded verifies that endpoints whose scope
+// the character (or the corporation's delegate) has not granted are not synced and
+// are recorded as missing_scope, while granted endpoints sync normally. A character
+// with no recorded scopes is synced on every endpoint.
+func TestMissingScope_EndpointSkippedAndRecorded(t *testing.T) {
+	var recorded []string
+	q := &mockQuerier{
+		listCharsFunc: func() ([]store.Character, error) {
+			return []store.Character{
+				// Granted character blueprints only: assets, jobs and all corporation endpoints are missing.
+				{ID: 1, Name: "Partial", Scopes: esi.ScopeCharacterBlueprints},
+				{ID: 2, Name: "Legacy"}, // scopes unknown
+			}, nil
+		},
+		listCorpsFunc: func() ([]store.ListCorporationsRow, error) {
+			return []store.ListCorporationsRow{{ID: 99, Name: "Corp", DelegateID: sql.NullInt64{Int64: 1, Valid: true}}}, nil
+		},
+		getSyncFunc: expiredState(time.Now().Add(-time.Hour)),
+		updateSyncStateMissingScopeFunc: func(arg store.UpdateSyncStateMissingScopeParams) error {
+			recorded = append(recorded, fmt.Sprintf("%s:%d:%s=%s", arg.OwnerType, arg.OwnerID, arg.Endpoint, arg.MissingScope.String))
+			return nil
+		},
+	}
+
+	var synced []string
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
+	w.syncFn = func(_ context.Context, ownerType string, ownerID int64, endpoint string) {
+		synced = append(synced, fmt.Sprintf("%s:%d:%s", ownerType, ownerID, endpoint))
+	}
+
+	w.runCycle(context.Background(), false)
+
+	wantSynced := []string{
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 1, endpointBlueprints),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 2, endpointAssets),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 2, endpointBlueprints),
+		fmt.Sprintf("%s:%d:%s", ownerTypeCharacter, 2, endpointJobs),
+	}
+	if !slices.Equal(synced, wantSynced) {
+		t.Errorf("synced: got %v, want %v", synced, wantSynced)
+	}
+	wantRecorded := []string{
+		fmt.Sprintf("%s:%d:%s=%s", ownerTypeCharacter, 1, endpointAssets, esi.ScopeCharacterAssets),
+		fmt.Sprintf("%s:%d:%s=%s", ownerTypeCharacter, 1, endpointJobs, esi.ScopeCharacterJobs),
+		fmt.Sprintf("%s:%d:%s=%s", ownerTypeCorporation, 99, endpointDivisions, esi.ScopeCorporationDivisions),
+		fmt.Sprintf("%s:%d:%s=%s", ownerTypeCorporation, 99, endpointCorpAssets, esi.ScopeCorporationAssets),
+		fmt.Sprintf("%s:%d:%s=%s", ownerTypeCorporation, 99, endpointBlueprints, esi.ScopeCorporationBlueprints),
+		fmt.Sprintf("%s:%d:%s=%s", ownerTypeCorporation, 99, endpointJobs, esi.ScopeCorporationJobs),
+	}
+	if !slices.Equal(recorded, wantRecorded) {
+		t.Errorf("missing scopes recorded: got %v, want %v", recorded, wantRecorded)
+	}
+}
+
+// TestRunCycle_RebuildsSearchIndex verifies that the search index is cleared and
+// repopulated from every indexed table at the end of a cycle.
+func TestRunCycle_RebuildsSearchIndex(t *testing.T) {
+	q := &mockQuerier{
+		listCharsFunc: oneChar(1),
+		listCorpsFunc: noCorps(),
+	}
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
+	w.syncFn = func(context.Context, string, int64, string) {
+		if len(q.searchIndexCalls) != 0 {
+			t.Error("search index rebuilt before the subjects were synced")
+		}
+	}
+
+	w.runCycle(context.Background(), true)
+
+	want := []string{"ClearSearchIndex", "IndexBlueprints", "IndexCharacters", "IndexCorporations", "IndexLocations"}
+	if !slices.Equal(q.searchIndexCalls, want) {
+		t.Errorf("search index calls: got %v, want %v", q.searchIndexCalls, want)
+	}
+}
+
+// TestRunCycle_SearchIndexRebuiltOnlyWhenStale verifies that a cycle that
+// synced nothing the index is built from leaves it alone, and that a failed
+// rebuild is retried by the next cycle.
+func TestRunCycle_SearchIndexRebuiltOnlyWhenStale(t *testing.T) {
+	q := &mockQuerier{
+		listCharsFunc:     oneChar(1),
+		listCorpsFunc:     noCorps(),
+		indexLocationsErr: errors.New("disk I/O error"),
+	}
+	w := New(q, nil, nil, time.Minute)
+	w.affiliationFn = noAffiliations
+	w.syncFn = func(context.Context, string, int64, string) {}
+
+	w.runCycle(context.Background(), true)
+	if len(q.searchIndexCalls) == 0 {
+		t.Fatal("search index not rebuilt by the first cycle")
+	}
+
+	// The failed rebuild is retried.
+	q.searchIndexCalls = nil
+	q.indexLocationsErr = nil
+	w.runCycle(context.Background(), false)
+	if len(q.searchIndexCalls) == 0 {
+		t.Fatal("failed search index rebuild not retried")
+	}
+
+	// Nothing synced and affiliations not due: nothing to rebuild.
+	q.searchIndexCalls = nil
+	w.runCycle(context.Background(), false)
+	if len(q.searchIndexCalls) != 0 {
+		t.Errorf("search index rebuilt by a cycle that changed nothing: %v", q.searchIndexCalls)
+	}
+}
+
 // TestRun_StopsOnContextCancel verifies that Run returns promptly when ctx is canceled.
 func TestRun_StopsOnContextCancel(t *testing.T) {
 	q := &mockQuerier{
@@ -402,7 +1142,8 @@ func TestRun_StopsOnContextCancel(t *testing.T) {
 		listCorpsFunc: noCorps(),
 	}
 
-	w := New(q, nil, 10*time.Second)
+	w := New(q, nil, nil, 10*time.Second)
+	w.affiliationFn = noAffiliations
 	ctx, cancel := context.WithCancel(context.Background())
 
 	done := make(chan struct{})
@@ -433,7 +1174,8 @@ func TestForceRefresh_Channel(t *testing.T) {
 	}
 
 	// Use a very long ticker so only the force-refresh triggers a cycle after startup.
-	w := New(q, nil, time.Hour)
+	w := New(q, nil, nil, time.Hour)
+	w.affiliationFn = noAffiliations
 	// Override now so the initial cycle sees everything as expired — we only care
 	// about the force-refresh cycle triggering; use sync calls as the signal.
 	var callCount int
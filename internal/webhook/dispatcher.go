@@ -0,0 +1,289 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	stdsync "sync"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+const (
+	// maxAttempts bounds the attempts at delivering one event.
+	maxAttempts = 5
+	// firstBackoff is the wait before the second attempt; it doubles for each
+	// attempt after that, up to maxBackoff.
+	firstBackoff = 2 * time.Second
+	maxBackoff   = time.Minute
+
+	// queueSize is the number of events a webhook may fall behind by before
+	// further events are dropped for it.
+	queueSize = 256
+
+	// maxResponse is the number of bytes of a response body recorded.
+	maxResponse = 1024
+
+	// deliveryRetention is how long delivery records are kept.
+	deliveryRetention = 30 * 24 * time.Hour
+
+	// requestTimeout bounds one attempt when New is given no HTTP client.
+	requestTimeout = 10 * time.Second
+)
+
+// Dispatcher delivers events to the webhooks in the store. Create one with
+// New, and start it with Run.
+type Dispatcher struct {
+	q            store.Querier
+	http         *http.Client
+	now          func() time.Time
+	firstBackoff time.Duration
+
+	wg     stdsync.WaitGroup
+	queues map[int64]chan delivery // of enabled webhooks, by ID; used by Run only
+}
+
+// delivery is an event queued for a webhook.
+type delivery struct {
+	hook  store.Webhook
+	event events.Event
+}
+
+// New creates a Dispatcher that reads webhooks from q and posts with
+// httpClient. A nil httpClient means a client with a 10 second timeout.
+func New(q store.Querier, httpClient *http.Client) *Dispatcher {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: requestTimeout}
+	}
+	return &Dispatcher{
+		q:            q,
+		http:         httpClient,
+		now:          time.Now,
+		firstBackoff: firstBackoff,
+		queues:       make(map[int64]chan delivery),
+	}
+}
+
+// Run delivers the events published on bus until ctx is canceled or the bus
+// is closed. Each webhook has its own queue, so that one that is slow or down
+// does not hold up the others; events are delivered to a webhook in order.
+func (d *Dispatcher) Run(ctx context.Context, bus *events.Bus) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer d.wg.Wait()
+	defer cancel()
+
+	events.Follow(ctx, bus, func(e events.Event) { d.dispatch(ctx, e) }, func() {
+		log.Printf("webhook: missed events; some were not delivered")
+	})
+}
+
+// dispatch queues e for every enabled webhook subscribed to its type, and
+// closes the queues of webhooks deleted or disabled since the last event.
+func (d *Dispatcher) dispatch(ctx context.Context, e events.Event) {
+	if e.Type == events.TypeCycleFinished {
+		if err := d.q.DeleteWebhookDeliveriesBefore(ctx, d.now().Add(-deliveryRetention).UTC()); err != nil {
+			log.Printf("webhook: pruning deliveries: %v", err)
+		}
+	}
+
+	hooks, err := d.q.ListWebhooks(ctx)
+	if err != nil {
+		log.Printf("webhook: listing webhooks: %v", err)
+		return
+	}
+	enabled := make(map[int64]bool, len(hooks))
+	for _, hook := range hooks {
+		enabled[hook.ID] = hook.Enabled != 0
+	}
+	for id, queue := range d.queues {
+		if !enabled[id] {
+			// Its worker finishes the events already queued, which it
+			// skips, and exits.
+			close(queue)
+			delete(d.queues, id)
+		}
+	}
+
+	for _, hook := range hooks {
+		if hook.Enabled == 0 || !subscribes(hook, e.Type) {
+			continue
+		}
+		queue, ok := d.queues[hook.ID]
+		if !ok {
+			queue = make(chan delivery, queueSize)
+			d.queues[hook.ID] = queue
+			d.wg.Add(1)
+			go d.work(ctx, queue)
+		}
+		select {
+		case queue <- delivery{hook: hook, event: e}:
+		default:
+			log.Printf("webhook: %s: %d events queued, dropping %s event %d", hook.Name, queueSize, e.Type, e.ID)
+		}
+	}
+}
+
+// work delivers the events queued for one webhook until ctx is canceled or
+// the queue is closed.
+func (d *Dispatcher) work(ctx context.Context, queue <-chan delivery) {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job, ok := <-queue:
+			if !ok {
+				return
+			}
+			// Read the webhook again: it may have been changed, disabled or
+			// deleted while the event was queued.
+			hook, err := d.q.GetWebhook(ctx, job.hook.ID)
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && hook.Enabled == 0) {
+				continue
+			}
+			if err != nil {
+				log.Printf("webhook: %s: getting webhook: %v", job.hook.Name, err)
+				continue
+			}
+			if rec, err := d.deliver(ctx, hook, job.event, maxAttempts); err != nil {
+				log.Printf("webhook: %s: %s event %d: %v", hook.Name, job.event.Type, job.event.ID, err)
+			} else if rec.Error.Valid {
+				log.Printf("webhook: %s: %s event %d not delivered after %d attempts: %s",
+					hook.Name, job.event.Type, job.event.ID, rec.Attempts, rec.Error.String)
+			}
+		}
+	}
+}
+
+// Test sends a sample event of eventType — a plain test event if empty — to
+// hook in a single attempt, whether or not hook is enabled or subscribed to
+// eventType, and returns the recorded delivery. It may be called while Run
+// is running.
+func (d *Dispatcher) Test(ctx context.Context, hook store.Webhook, eventType string) (store.WebhookDelivery, error) {
+	if eventType == "" {
+		eventType = TypeTest
+	}
+	return d.deliver(ctx, hook, SampleEvent(eventType, d.now()), 1)
+}
+
+// deliver posts e to hook, retrying up to attempts times, and records the
+// outcome. The returned error is about recording it; a failed delivery is
+// reported in the record.
+func (d *Dispatcher) deliver(ctx context.Context, hook store.Webhook, e events.Event, attempts int) (store.WebhookDelivery, error) {
+	start := d.now()
+	rec := store.CreateWebhookDeliveryParams{
+		WebhookID: hook.ID,
+		EventID:   int64(e.ID),
+		EventType: e.Type,
+		CreatedAt: start.UTC(),
+	}
+
+	body, header, err := render(hook, e)
+	if err != nil {
+		rec.Error = sql.NullString{String: err.Error(), Valid: true}
+	} else {
+		d.attempt(ctx, hook, body, header, attempts, &rec)
+	}
+	rec.DurationMs = d.now().Sub(start).Milliseconds()
+
+	// Record the outcome even if ctx was canceled during the delivery.
+	saved, err := d.q.CreateWebhookDelivery(context.WithoutCancel(ctx), rec)
+	if err != nil {
+		return store.WebhookDelivery{}, fmt.Errorf("recording delivery: %w", err)
+	}
+	return saved, nil
+}
+
+// attempt posts body to hook up to attempts times, backing off between
+// attempts, and records the last attempt in rec.
+func (d *Dispatcher) attempt(ctx context.Context, hook store.Webhook, body []byte, header http.Header, attempts int, rec *store.CreateWebhookDeliveryParams) {
+	for attempt := 1; ; attempt++ {
+		status, response, wait, err := d.post(ctx, hook, body, header)
+		rec.Attempts = int64(attempt)
+		rec.StatusCode = sql.NullInt64{Int64: int64(status), Valid: status != 0}
+		rec.Response = response
+		switch {
+		case err != nil:
+			rec.Error = sql.NullString{String: err.Error(), Valid: true}
+		case status < 200 || status >= 300:
+			rec.Error = sql.NullString{String: fmt.Sprintf("responded %d", status), Valid: true}
+		default:
+			rec.Error = sql.NullString{}
+			return
+		}
+		if attempt == attempts || !retryable(status, err) {
+			return
+		}
+		delay := min(d.firstBackoff<<(attempt-1), maxBackoff)
+		if wait > delay {
+			delay = min(wait, maxBackoff)
+		}
+		if sleep(ctx, delay) != nil {
+			return // shutting down; rec keeps the error of the last attempt
+		}
+	}
+}
+
+// post makes one attempt at delivering body, and returns the response status,
+// the start of the response body, and the wait the receiver asked for in
+// Retry-After.
+func (d *Dispatcher) post(ctx context.Context, hook store.Webhook, body []byte, header http.Header) (int, string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", 0, err
+	}
+	req.Header = header.Clone()
+	timestamp := d.now().Unix()
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // let the connection be reused
+
+	var wait time.Duration
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+		wait = time.Duration(s) * time.Second
+	}
+	return resp.StatusCode, validUTF8(respBody), wait, nil
+}
+
+// retryable reports whether an attempt that ended with status or err may
+// succeed if repeated.
+func retryable(status int, err error) bool {
+	if err != nil {
+		return true
+	}
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// validUTF8 returns b as a string without invalid UTF-8, such as a character
+// cut off by maxResponse.
+func validUTF8(b []byte) string {
+	return strings.ToValidUTF8(string(b), "")
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,249 @@
+// Package webhook delivers events from the events bus to HTTP endpoints
+// configured by the user.
+//
+// Webhooks are rows of the webhooks table, managed through /api/webhooks.
+// Every event of a type a webhook subscribes to is POSTed to its URL with a
+// body rendered from its text/template — or, without one, the event as a
+// JSON envelope — its extra headers, and an HMAC-SHA256 signature. Failed
+// deliveries are retried with exponential backoff, and the outcome of each is
+// recorded in webhook_deliveries.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// TypeTest is the type of the events sent by Dispatcher.Test.
+const TypeTest = "test"
+
+// Request headers set on every delivery. They cannot be overridden by a
+// webhook's headers.
+const (
+	HeaderEvent     = "X-Auspex-Event"     // event type
+	HeaderEventID   = "X-Auspex-Event-Id"  // events bus ID; 0 for test events
+	HeaderTimestamp = "X-Auspex-Timestamp" // Unix time of the attempt
+	// HeaderSignature is "sha256=" followed by the hex HMAC-SHA256, keyed with
+	// the webhook secret, of the timestamp, a ".", and the body.
+	HeaderSignature = "X-Auspex-Signature"
+)
+
+// reservedHeaders are set by the HTTP client or by Auspex.
+var reservedHeaders = []string{"Host", "Content-Length", "Transfer-Encoding", "Connection", "User-Agent"}
+
+// Payload is the template data of a delivery, and its body without a
+// template.
+type Payload struct {
+	ID   uint64    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"` // the data struct of the event type, see package events
+}
+
+// templateFuncs are available to body templates.
+var templateFuncs = template.FuncMap{
+	// json encodes a value as JSON, e.g. a string with its quotes and escapes.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ParseEvents returns the event types stored in a webhook's events column.
+// An empty list means every type.
+func ParseEvents(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+// FormatEvents returns the events column value for types.
+func FormatEvents(types []string) string {
+	return strings.Join(types, ",")
+}
+
+// ParseHeaders returns the headers stored in a webhook's headers column.
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	if s == "" {
+		return headers, nil
+	}
+	if err := json.Unmarshal([]byte(s), &headers); err != nil {
+		return nil, fmt.Errorf("decoding headers: %w", err)
+	}
+	return headers, nil
+}
+
+// Validate checks the event types, body template and headers of a webhook.
+// The template is rendered with a sample of every event type it receives, so
+// that a reference to a field an event does not have is caught here rather
+// than at delivery.
+func Validate(types []string, body string, headers map[string]string) error {
+	for _, t := range types {
+		if !slices.Contains(events.Types, t) {
+			return fmt.Errorf("unknown event type %q", t)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		if err := validateHeader(name, headers[name]); err != nil {
+			return err
+		}
+	}
+	if body == "" {
+		return nil
+	}
+	tmpl, err := parseTemplate(body)
+	if err != nil {
+		return err
+	}
+	if len(types) == 0 {
+		types = events.Types
+	}
+	for _, t := range types {
+		if err := tmpl.Execute(&bytes.Buffer{}, payload(SampleEvent(t, time.Now()))); err != nil {
+			return fmt.Errorf("template: %s event: %w", t, err)
+		}
+	}
+	return nil
+}
+
+func validateHeader(name, value string) error {
+	if name == "" || strings.IndexFunc(name, func(r rune) bool { return !isTokenChar(r) }) >= 0 {
+		return fmt.Errorf("invalid header name %q", name)
+	}
+	canonical := http.CanonicalHeaderKey(name)
+	if slices.Contains(reservedHeaders, canonical) || strings.HasPrefix(canonical, "X-Auspex-") {
+		return fmt.Errorf("header %s cannot be set", canonical)
+	}
+	if strings.ContainsAny(value, "\r\n\x00") {
+		return fmt.Errorf("invalid value for header %s", canonical)
+	}
+	return nil
+}
+
+// isTokenChar reports whether r may appear in a header name (RFC 9110 token).
+func isTokenChar(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
+
+func parseTemplate(body string) (*template.Template, error) {
+	tmpl, err := template.New("body").Funcs(templateFuncs).Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// SampleEvent returns an event of eventType with made-up data, as sent by
+// Dispatcher.Test.
+func SampleEvent(eventType string, now time.Time) events.Event {
+	e := events.Event{Type: eventType, Time: now.UTC()}
+	switch eventType {
+	case events.TypeCycleStarted:
+		e.Data = events.Cycle{}
+	case events.TypeCycleFinished:
+		e.Data = events.Cycle{DurationMS: 1234}
+	case events.TypeSubjectSynced:
+		e.Data = events.Subject{OwnerType: "character", OwnerID: 90000001, Endpoint: "blueprints"}
+	case events.TypeSubjectFailed:
+		e.Data = events.Subject{OwnerType: "character", OwnerID: 90000001, Endpoint: "blueprints", Error: "ESI responded 502"}
+	case events.TypeJobReady:
+		e.Data = events.Job{
+			JobID: 500000001, BlueprintID: 1000000000001, OwnerType: "character", OwnerID: 90000001,
+			InstallerID: 90000001, Activity: "me_research", EndDate: now.UTC().Truncate(time.Second),
+		}
+	case events.TypeBlueprintAdded, events.TypeBlueprintRemoved:
+		e.Data = events.Blueprint{BlueprintID: 1000000000001, TypeID: 691, OwnerType: "character", OwnerID: 90000001}
+	case events.TypeAlertFired:
+		e.Data = events.Alert{RuleID: 1, Rule: "Copies ending soon", Matches: []events.AlertMatch{
+			{Subject: "job:500000001", Description: "Rifter Blueprint (Main Corp): Copying, 1h30m left"},
+		}}
+	default:
+		e.Data = map[string]string{"message": "Test event from Auspex"}
+	}
+	return e
+}
+
+func payload(e events.Event) Payload {
+	return Payload{ID: e.ID, Type: e.Type, Time: e.Time, Data: e.Data}
+}
+
+// render returns the request body and headers of delivering e to hook,
+// without the per-attempt timestamp and signature.
+func render(hook store.Webhook, e events.Event) ([]byte, http.Header, error) {
+	var body []byte
+	if hook.Template == "" {
+		var err error
+		if body, err = json.Marshal(payload(e)); err != nil {
+			return nil, nil, fmt.Errorf("encoding event: %w", err)
+		}
+	} else {
+		tmpl, err := parseTemplate(hook.Template)
+		if err != nil {
+			return nil, nil, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, payload(e)); err != nil {
+			return nil, nil, fmt.Errorf("template: %w", err)
+		}
+		body = buf.Bytes()
+	}
+
+	custom, err := ParseHeaders(hook.Headers)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	for name, value := range custom {
+		if err := validateHeader(name, value); err != nil {
+			return nil, nil, err
+		}
+		header.Set(name, value)
+	}
+	header.Set("User-Agent", "Auspex-Webhook")
+	header.Set(HeaderEvent, e.Type)
+	header.Set(HeaderEventID, strconv.FormatUint(e.ID, 10))
+	return body, header, nil
+}
+
+// Sign returns the HeaderSignature value of body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the HeaderSignature of body sent at
+// timestamp. Receivers written in Go can use it; they should also reject old
+// timestamps.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// subscribes reports whether hook receives events of eventType.
+func subscribes(hook store.Webhook, eventType string) bool {
+	types := ParseEvents(hook.Events)
+	return len(types) == 0 || slices.Contains(types, eventType)
+}
@@ -0,0 +1,365 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	stdsync "sync"
+	"testing"
+	"time"
+
+	"github.com/dpleshakov/auspex/internal/db"
+	"github.com/dpleshakov/auspex/internal/events"
+	"github.com/dpleshakov/auspex/internal/store"
+)
+
+// received is a request recorded by a receiver.
+type received struct {
+	header http.Header
+	body   string
+}
+
+// receiver is a local webhook endpoint. It answers with the statuses in
+// order, then 204.
+type receiver struct {
+	*httptest.Server
+
+	mu       stdsync.Mutex
+	requests []received
+	statuses []int
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	t.Helper()
+	rcv := &receiver{statuses: statuses}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		rcv.requests = append(rcv.requests, received{header: r.Header, body: string(body)})
+		status := http.StatusNoContent
+		if len(rcv.statuses) > 0 {
+			status, rcv.statuses = rcv.statuses[0], rcv.statuses[1:]
+		}
+		rcv.mu.Unlock()
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *receiver) received() []received {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]received(nil), rcv.requests...)
+}
+
+func newTestStore(t *testing.T) store.Querier {
+	t.Helper()
+	sqlDB, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return store.New(sqlDB)
+}
+
+func createWebhook(t *testing.T, q store.Querier, params store.CreateWebhookParams) store.Webhook {
+	t.Helper()
+	if params.Name == "" {
+		params.Name = "test"
+	}
+	if params.Secret == "" {
+		params.Secret = "s3cret"
+	}
+	if params.Headers == "" {
+		params.Headers = "{}"
+	}
+	params.Enabled = 1
+	hook, err := q.CreateWebhook(context.Background(), params)
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	return hook
+}
+
+func newTestDispatcher(q store.Querier) *Dispatcher {
+	d := New(q, nil)
+	d.firstBackoff = time.Millisecond
+	return d
+}
+
+func TestDeliver_JSONEnvelopeSigned(t *testing.T) {
+	q := newTestStore(t)
+	rcv := newReceiver(t)
+	hook := createWebhook(t, q, store.CreateWebhookParams{Url: rcv.URL, Headers: `{"Authorization":"Bearer abc"}`})
+	d := newTestDispatcher(q)
+	e := events.Event{
+		ID: 42, Type: events.TypeSubjectFailed, Time: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+		Data: events.Subject{OwnerType: "character", OwnerID: 1001, Endpoint: "jobs", Error: "ESI responded 502"},
+	}
+
+	rec, err := d.deliver(context.Background(), hook, e, maxAttempts)
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if rec.Error.Valid || rec.Attempts != 1 || rec.StatusCode.Int64 != http.StatusNoContent {
+		t.Errorf("delivery = %+v", rec)
+	}
+
+	got := rcv.received()
+	if len(got) != 1 {
+		t.Fatalf("got %d requests, want 1", len(got))
+	}
+	want := `{"id":42,"type":"subject_failed","time":"2026-10-19T12:00:00Z","data":{"owner_type":"character","owner_id":1001,"endpoint":"jobs","error":"ESI responded 502"}}`
+	if got[0].body != want {
+		t.Errorf("body = %s\nwant   %s", got[0].body, want)
+	}
+	h := got[0].header
+	if h.Get("Content-Type") != "application/json" || h.Get("Authorization") != "Bearer abc" {
+		t.Errorf("headers = %v", h)
+	}
+	if h.Get(HeaderEvent) != "subject_failed" || h.Get(HeaderEventID) != "42" {
+		t.Errorf("event headers = %v", h)
+	}
+	timestamp, err := strconv.ParseInt(h.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp %q: %v", h.Get(HeaderTimestamp), err)
+	}
+	if !Verify("s3cret", timestamp, []byte(got[0].body), h.Get(HeaderSignature)) {
+		t.Errorf("signature %q does not verify", h.Get(HeaderSignature))
+	}
+	if Verify("other", timestamp, []byte(got[0].body), h.Get(HeaderSignature)) {
+		t.Error("signature verifies with another secret")
+	}
+}
+
+func TestDeliver_Template(t *testing.T) {
+	q := newTestStore(t)
+	rcv := newReceiver(t)
+	hook := createWebhook(t, q, store.CreateWebhookParams{
+		Url:      rcv.URL,
+		Template: `{"text": {{json (printf "Job %d ready (%s)" .Data.JobID .Data.Activity)}}}`,
+		Headers:  `{"content-type":"text/plain"}`,
+	})
+
+	e := SampleEvent(events.TypeJobReady, time.Now())
+	if _, err := newTestDispatcher(q).deliver(context.Background(), hook, e, 1); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	got := rcv.received()
+	if len(got) != 1 || got[0].body != `{"text": "Job 500000001 ready (me_research)"}` {
+		t.Fatalf("requests = %v", got)
+	}
+	if ct := got[0].header.Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("Content-Type = %q, want the webhook's", ct)
+	}
+}
+
+func TestDeliver_RetriesWithBackoff(t *testing.T) {
+	q := newTestStore(t)
+	rcv := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	hook := createWebhook(t, q, store.CreateWebhookParams{Url: rcv.URL})
+
+	rec, err := newTestDispatcher(q).deliver(context.Background(), hook, SampleEvent(TypeTest, time.Now()), maxAttempts)
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if rec.Attempts != 3 || rec.Error.Valid || rec.StatusCode.Int64 != http.StatusNoContent {
+		t.Errorf("delivery = %+v, want success on the third attempt", rec)
+	}
+	reqs := rcv.received()
+	if len(reqs) != 3 {
+		t.Fatalf("got %d requests, want 3", len(reqs))
+	}
+	if reqs[0].body != reqs[2].body {
+		t.Error("retry sent a different body")
+	}
+}
+
+func TestDeliver_GivesUp(t *testing.T) {
+	q := newTestStore(t)
+	rcv := newReceiver(t, 500, 500, 500, 500, 500, 500)
+	hook := createWebhook(t, q, store.CreateWebhookParams{Url: rcv.URL})
+
+	rec, err := newTestDispatcher(q).deliver(context.Background(), hook, SampleEvent(TypeTest, time.Now()), maxAttempts)
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if rec.Attempts != maxAttempts || rec.Error.String != "responded 500" || rec.Response != "ok" {
+		t.Errorf("delivery = %+v", rec)
+	}
+}
+
+func TestDeliver_ClientErrorNotRetried(t *testing.T) {
+	q := newTestStore(t)
+	rcv := newReceiver(t, http.StatusUnauthorized)
+	hook := createWebhook(t, q, store.CreateWebhookParams{Url: rcv.URL})
+
+	rec, _ := newTestDispatcher(q).deliver(context.Background(), hook, SampleEvent(TypeTest, time.Now()), maxAttempts)
+	if rec.Attempts != 1 || rec.StatusCode.Int64 != http.StatusUnauthorized {
+		t.Errorf("delivery = %+v, want one attempt", rec)
+	}
+}
+
+func TestDeliver_Unreachable(t *testing.T) {
+	q := newTestStore(t)
+	rcv := newReceiver(t)
+	url := rcv.URL
+	rcv.Close()
+	hook := createWebhook(t, q, store.CreateWebhookParams{Url: url})
+
+	rec, _ := newTestDispatcher(q).deliver(context.Background(), hook, SampleEvent(TypeTest, time.Now()), 2)
+	if rec.Attempts != 2 || rec.StatusCode.Valid || !rec.Error.Valid {
+		t.Errorf("delivery = %+v, want two failed attempts without a status", rec)
+	}
+}
+
+func TestRun_DeliversSubscribedEvents(t *testing.T) {
+	q := newTestStore(t)
+	rcv := newReceiver(t)
+	failures := createWebhook(t, q, store.CreateWebhookParams{Name: "failures", Url: rcv.URL + "/failures", Events: "subject_failed"})
+	all := createWebhook(t, q, store.CreateWebhookParams{Name: "all", Url: rcv.URL + "/all"})
+	disabled := createWebhook(t, q, store.CreateWebhookParams{Name: "off", Url: rcv.URL + "/off"})
+	if _, err := q.UpdateWebhook(context.Background(), store.UpdateWebhookParams{
+		ID: disabled.ID, Name: disabled.Name, Url: disabled.Url, Headers: "{}", Enabled: 0,
+	}); err != nil {
+		t.Fatalf("UpdateWebhook: %v", err)
+	}
+
+	bus := events.NewBus(events.DefaultHistorySize)
+	d := newTestDispatcher(q)
+	done := make(chan struct{})
+	go func() {
+		d.Run(context.Background(), bus)
+		close(done)
+	}()
+	for bus.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	bus.Publish(events.TypeSubjectSynced, events.Subject{OwnerType: "character", OwnerID: 1, Endpoint: "jobs"})
+	bus.Publish(events.TypeSubjectFailed, events.Subject{OwnerType: "character", OwnerID: 1, Endpoint: "jobs", Error: "boom"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rcv.received()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	bus.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the bus was closed")
+	}
+
+	paths := map[string][]string{}
+	for _, r := range rcv.received() {
+		paths[r.header.Get(HeaderEvent)] = append(paths[r.header.Get(HeaderEvent)], r.body)
+	}
+	if len(paths["subject_synced"]) != 1 || len(paths["subject_failed"]) != 2 {
+		t.Errorf("deliveries by event = %v", paths)
+	}
+
+	for _, hook := range []store.Webhook{failures, all} {
+		recs, err := q.ListWebhookDeliveries(context.Background(), store.ListWebhookDeliveriesParams{WebhookID: hook.ID, Limit: 10})
+		if err != nil {
+			t.Fatalf("ListWebhookDeliveries: %v", err)
+		}
+		if len(recs) == 0 || recs[0].EventType != "subject_failed" || recs[0].EventID == 0 {
+			t.Errorf("%s: deliveries = %+v", hook.Name, recs)
+		}
+	}
+}
+
+func TestDispatch_ClosesQueueOfDeletedWebhook(t *testing.T) {
+	q := newTestStore(t)
+	rcv := newReceiver(t)
+	hook := createWebhook(t, q, store.CreateWebhookParams{Url: rcv.URL})
+	ctx := context.Background()
+	d := newTestDispatcher(q)
+
+	d.dispatch(ctx, events.Event{ID: 1, Type: events.TypeSubjectSynced, Data: events.Subject{OwnerType: "character", OwnerID: 1}})
+	if len(d.queues) != 1 {
+		t.Fatalf("queues = %d, want 1", len(d.queues))
+	}
+	if _, err := q.DeleteWebhook(ctx, hook.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	d.dispatch(ctx, events.Event{ID: 2, Type: events.TypeSubjectSynced, Data: events.Subject{OwnerType: "character", OwnerID: 1}})
+	if len(d.queues) != 0 {
+		t.Errorf("queues = %d after the webhook was deleted, want 0", len(d.queues))
+	}
+
+	// The worker exits without ctx being canceled.
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker of the deleted webhook did not exit")
+	}
+}
+
+func TestTest_SendsSampleEvent(t *testing.T) {
+	q := newTestStore(t)
+	rcv := newReceiver(t)
+	hook := createWebhook(t, q, store.CreateWebhookParams{Url: rcv.URL, Events: "job_ready"})
+
+	rec, err := newTestDispatcher(q).Test(context.Background(), hook, "")
+	if err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+	if rec.EventType != TypeTest || rec.EventID != 0 || rec.Error.Valid {
+		t.Errorf("delivery = %+v", rec)
+	}
+	got := rcv.received()
+	if len(got) != 1 || !strings.Contains(got[0].body, `"message":"Test event from Auspex"`) {
+		t.Errorf("requests = %v", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		types    []string
+		template string
+		headers  map[string]string
+		wantErr  string
+	}{
+		{name: "defaults"},
+		{name: "template for subscribed event", types: []string{"job_ready"}, template: `{{.Data.JobID}}`},
+		{name: "unknown event", types: []string{"job_done"}, wantErr: `unknown event type "job_done"`},
+		{name: "template syntax", template: `{{.Data`, wantErr: "template:"},
+		{name: "field of another event", types: []string{"job_ready", "subject_failed"}, template: `{{.Data.JobID}}`, wantErr: "subject_failed event"},
+		{name: "reserved header", headers: map[string]string{"x-auspex-signature": "x"}, wantErr: "cannot be set"},
+		{name: "header name", headers: map[string]string{"Bad Header": "x"}, wantErr: "invalid header name"},
+		{name: "header value", headers: map[string]string{"X-Token": "a\r\nHost: evil"}, wantErr: "invalid value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.types, tt.template, tt.headers)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSign_KnownValue(t *testing.T) {
+	// printf '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	if got := Sign("secret", 1700000000, []byte("{}")); got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+	if !Verify("secret", 1700000000, []byte("{}"), want) {
+		t.Error("Verify rejects a valid signature")
+	}
+}